@@ -39,6 +39,7 @@ func bootstrapLoop(logStream *logging.LogStream, game GameInterface, platformSta
 		if build.Ai_driver {
 			startAIDriver(container.Host)
 		}
+		startInputReplay(container.Host)
 		container.Host.PrimaryCamera().SetPosition(matrix.Vec3{0, 0, 5})
 		if build.Debug {
 			profiler.SetupConsole(container.Host)
@@ -0,0 +1,40 @@
+//go:build !masterServer
+
+/******************************************************************************/
+/* bootstrap_replay.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package bootstrap
+
+import (
+	"log/slog"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/replay"
+)
+
+// startInputReplay records or replays the input of the session when it was
+// asked for on the command line with -record or -replay. A replay takes
+// priority over a recording as the replayed input is already on file.
+func startInputReplay(host *engine.Host) {
+	if path := engine.LaunchParams.ReplayInput; path != "" {
+		if _, err := replay.PlayFile(host, path); err != nil {
+			slog.Error("failed to play the input replay", "file", path, "error", err)
+		}
+		return
+	}
+	if path := engine.LaunchParams.RecordInput; path != "" {
+		recorder, err := replay.StartRecording(host, path)
+		if err != nil {
+			slog.Error("failed to start recording input", "file", path, "error", err)
+			return
+		}
+		host.OnClose.Add(func() {
+			if err := recorder.Stop(host); err != nil {
+				slog.Error("failed to save the input recording", "file", path, "error", err)
+			}
+		})
+	}
+}
@@ -35,6 +35,7 @@ func init() {
 	RegisterType[engine.EntityId]()
 	RegisterType[engine.Host]()
 	RegisterType[engine.UpdateId]()
+	RegisterType[content_id.AnimationGraph]()
 	RegisterType[content_id.Css]()
 	RegisterType[content_id.Font]()
 	RegisterType[content_id.Html]()
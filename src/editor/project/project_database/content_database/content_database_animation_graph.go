@@ -0,0 +1,36 @@
+/******************************************************************************/
+/* content_database_animation_graph.go                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package content_database
+
+import (
+	"kaijuengine.com/editor/project/project_file_system"
+	"kaijuengine.com/platform/profiler/tracing"
+)
+
+func init() { addCategory(AnimationGraph{}) }
+
+// AnimationGraph is a [ContentCategory] represented by a JSON ".animgraph" file.
+type AnimationGraph struct{}
+type AnimationGraphConfig struct{}
+
+func (AnimationGraph) Path() string       { return project_file_system.ContentAnimationGraphFolder }
+func (AnimationGraph) TypeName() string   { return "AnimationGraph" }
+func (AnimationGraph) ExtNames() []string { return []string{".animgraph"} }
+
+func (AnimationGraph) Import(src string, _ *project_file_system.FileSystem) (ProcessedImport, error) {
+	defer tracing.NewRegion("AnimationGraph.Import").End()
+	return pathToTextData(src)
+}
+
+func (c AnimationGraph) Reimport(id string, cache *Cache, fs *project_file_system.FileSystem) (ProcessedImport, error) {
+	defer tracing.NewRegion("AnimationGraph.Reimport").End()
+	return reimportByNameMatching(c, id, cache, fs)
+}
+
+func (AnimationGraph) PostImportProcessing(proc ProcessedImport, res *ImportResult, fs *project_file_system.FileSystem, cache *Cache, linkedId string) error {
+	return nil
+}
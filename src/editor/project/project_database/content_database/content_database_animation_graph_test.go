@@ -0,0 +1,31 @@
+package content_database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"kaijuengine.com/editor/project/project_file_system"
+)
+
+func TestAnimationGraphCategoryRegistration(t *testing.T) {
+	cat, ok := CategoryFromTypeName("AnimationGraph")
+	if !ok {
+		t.Fatal("AnimationGraph category was not registered")
+	}
+	if got := cat.Path(); got != project_file_system.ContentAnimationGraphFolder {
+		t.Fatalf("Path() = %q, want %q", got, project_file_system.ContentAnimationGraphFolder)
+	}
+	if got := cat.ExtNames(); len(got) != 1 || got[0] != ".animgraph" {
+		t.Fatalf("ExtNames() = %v, want [.animgraph]", got)
+	}
+}
+
+func TestAnimationGraphCategorySelectedByExtension(t *testing.T) {
+	cat, ok := selectCategoryForFile(filepath.FromSlash("characters/hero.animgraph"))
+	if !ok {
+		t.Fatal("selectCategoryForFile() did not find .animgraph")
+	}
+	if got := cat.TypeName(); got != "AnimationGraph" {
+		t.Fatalf("TypeName() = %q, want AnimationGraph", got)
+	}
+}
@@ -39,6 +39,8 @@ var (
 		DebugFolder,
 	}, srcFolders...)
 	contentStructure = []string{
+		ContentAnimationFolder,
+		ContentAnimationGraphFolder,
 		ContentAudioFolder,
 		ContentMusicFolder,
 		ContentSoundFolder,
@@ -38,6 +38,8 @@ const (
 )
 
 const (
+	ContentAnimationFolder       = "animation"
+	ContentAnimationGraphFolder  = ContentAnimationFolder + "/graph"
 	ContentAudioFolder           = "audio"
 	ContentMusicFolder           = ContentAudioFolder + "/music"
 	ContentSoundFolder           = ContentAudioFolder + "/sound"
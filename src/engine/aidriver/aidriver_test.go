@@ -417,3 +417,34 @@ func TestInputValidationOverHTTP(t *testing.T) {
 		}
 	}
 }
+
+func TestReplayValidationOverHTTP(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+	for _, body := range []string{
+		`{"action":"record"}`,
+		`{"action":"play","path":""}`,
+		`{"action":"rewind","path":"a.krpl"}`,
+		`not json`,
+	} {
+		resp, err := http.Post(srv.URL+"/v1/replay", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: POST /v1/replay: %v", body, err)
+		}
+		var env errorEnvelope
+		if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+			resp.Body.Close()
+			t.Fatalf("%s: decode envelope: %v", body, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, resp.StatusCode, http.StatusBadRequest)
+		}
+		if env.Error == nil || env.Error.Code != codeInvalidRequest {
+			t.Errorf("%s: expected an invalid_request error", body)
+		}
+	}
+	if err := validateReplay(&replayRequest{Action: replayActionStop}); err != nil {
+		t.Errorf("stop should not need a path: %v", err)
+	}
+}
@@ -22,6 +22,7 @@ package aidriver
 
 import (
 	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/replay"
 )
 
 // command is one unit of work executed on the game-loop thread by the drain.
@@ -39,6 +40,10 @@ type driver struct {
 	// OnActivate/OnDeactivate (game-loop thread) and read only by stateCommand
 	// (also game-loop thread), so it needs no synchronization.
 	focused bool
+	// recorder and player are the input recording or replay started through
+	// POST /v1/replay, only touched on the game-loop thread
+	recorder *replay.Recorder
+	player   *replay.Player
 }
 
 const queueDepth = 256
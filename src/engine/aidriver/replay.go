@@ -0,0 +1,142 @@
+/******************************************************************************/
+/* replay.go                                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package aidriver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"kaijuengine.com/engine/replay"
+)
+
+// Action discriminators for the POST /v1/replay payload.
+const (
+	replayActionRecord = "record"
+	replayActionPlay   = "play"
+	replayActionStop   = "stop"
+)
+
+const codeReplayFailed = "replay_failed"
+
+// replayRequest is the decoded body of POST /v1/replay. Recording captures the
+// input injected through /v1/input along with the OS input, so a scripted
+// session can be saved and later played back without the driver.
+type replayRequest struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+}
+
+func validateReplay(req *replayRequest) *apiError {
+	switch req.Action {
+	case replayActionRecord, replayActionPlay:
+		if req.Path == "" {
+			return reqErr(req.Action + " requires a path")
+		}
+	case replayActionStop:
+	default:
+		return reqErr("action must be 'record', 'play' or 'stop'")
+	}
+	return nil
+}
+
+type replayResult struct {
+	frame  uint64
+	frames int
+	err    *apiError
+}
+
+// replayCommand starts or stops a recording or a playback on the game-loop
+// thread. Only one of the two can run at a time as both take the host's
+// frame input.
+type replayCommand struct {
+	req   *replayRequest
+	reply chan replayResult
+}
+
+func (c *replayCommand) apply(d *driver) {
+	res := replayResult{}
+	switch c.req.Action {
+	case replayActionRecord, replayActionPlay:
+		if d.host.FrameInput() != nil {
+			res.err = &apiError{Code: codeBusy, Message: "a recording or replay is already running", Status: 409}
+			break
+		}
+		var err error
+		if c.req.Action == replayActionRecord {
+			d.recorder, err = replay.StartRecording(d.host, c.req.Path)
+		} else {
+			d.player, err = replay.PlayFile(d.host, c.req.Path)
+			if err == nil {
+				res.frames = d.player.Len()
+			}
+		}
+		if err != nil {
+			res.err = &apiError{Code: codeReplayFailed, Message: err.Error(), Status: 500}
+		}
+	case replayActionStop:
+		res.err = d.stopReplay(&res)
+	}
+	res.frame = d.host.Frame()
+	c.reply <- res
+}
+
+// stopReplay ends the recording or playback that the driver started
+func (d *driver) stopReplay(res *replayResult) *apiError {
+	var err error
+	switch {
+	case d.recorder != nil:
+		res.frames = int(d.recorder.Frames())
+		err = d.recorder.Stop(d.host)
+	case d.player != nil:
+		res.frames = d.player.Frame()
+		d.player.Stop(d.host)
+	default:
+		return reqErr("no recording or replay is running")
+	}
+	d.recorder, d.player = nil, nil
+	if err != nil {
+		return &apiError{Code: codeReplayFailed, Message: err.Error(), Status: 500}
+	}
+	return nil
+}
+
+type replayResponse struct {
+	OK     bool   `json:"ok"`
+	Action string `json:"action"`
+	Frame  uint64 `json:"frame"`
+	Frames int    `json:"frames"`
+}
+
+func (d *driver) handleReplay(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req replayRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeAPIError(w, reqErr("invalid JSON body: "+err.Error()))
+		return
+	}
+	if err := validateReplay(&req); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	reply := make(chan replayResult, 1)
+	if !d.enqueue(&replayCommand{req: &req, reply: reply}) {
+		writeAPIError(w, &apiError{Code: codeBusy, Message: "driver command queue is full", Status: 503})
+		return
+	}
+	select {
+	case res := <-reply:
+		if res.err != nil {
+			writeAPIError(w, res.err)
+			return
+		}
+		writeJSON(w, http.StatusOK, replayResponse{OK: true, Action: req.Action, Frame: res.frame, Frames: res.frames})
+	case <-time.After(commandTimeout):
+		writeAPIError(w, &apiError{Code: codeTimeout, Message: "replay request timed out", Status: 504})
+	}
+}
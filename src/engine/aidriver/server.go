@@ -71,6 +71,11 @@ func Start(host *engine.Host) {
 	host.RunNextFrame(d.drainOnce)
 	host.OnClose.Add(func() {
 		close(d.closing)
+		if d.recorder != nil {
+			if err := d.recorder.Stop(host); err != nil {
+				slog.Error("AI Driver failed to save the input recording", "error", err)
+			}
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -80,7 +85,7 @@ func Start(host *engine.Host) {
 
 	go func() {
 		slog.Info("AI Driver started", "addr", addr,
-			"endpoints", "/v1/health /v1/help /v1/state /v1/screenshot /v1/input /v1/resize /v1/quit")
+			"endpoints", "/v1/health /v1/help /v1/state /v1/screenshot /v1/input /v1/resize /v1/quit /v1/replay")
 		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("AI Driver server stopped unexpectedly", "error", err)
 		}
@@ -95,6 +100,7 @@ func (d *driver) routes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /v1/input", d.handleInput)
 	mux.HandleFunc("POST /v1/resize", d.handleResize)
 	mux.HandleFunc("POST /v1/quit", d.handleQuit)
+	mux.HandleFunc("POST /v1/replay", d.handleReplay)
 }
 
 // withGuards rejects browser-originated requests as a cheap anti-CSRF measure.
@@ -59,6 +59,7 @@ var helpRoutes = []routeHelp{
 	{http.MethodPost, "/v1/input", "Batched input actions. See coordinate_space, settle_frames, return_screenshot, actions[]."},
 	{http.MethodPost, "/v1/resize", "Resize the window: {width, height, settle_frames?, return_screenshot?}."},
 	{http.MethodPost, "/v1/quit", "Gracefully close the game."},
+	{http.MethodPost, "/v1/replay", "Record or play back input: {action: record|play|stop, path}."},
 }
 
 func computeScale(winW, winH, fbW, fbH int) scaleXY {
@@ -7,6 +7,9 @@
 package assets
 
 import (
+	"io"
+	"log/slog"
+	"os"
 	"path/filepath"
 	"runtime"
 
@@ -76,4 +79,29 @@ func (a *ArchiveDatabase) Exists(key string) bool {
 	return a.archive.Exists(key)
 }
 
-func (a *ArchiveDatabase) Close() {}
+// Open streams the asset out of the archive without reading all of it into
+// memory first, this is preferred for large assets such as audio and video
+func (a *ArchiveDatabase) Open(key string) (io.ReadCloser, error) {
+	defer tracing.NewRegion("ArchiveDatabase.Open: " + key).End()
+	if filepath.IsAbs(key) {
+		return os.Open(key[1:])
+	}
+	return a.archive.Open(key)
+}
+
+// Keys returns the keys of all of the assets in the archive
+func (a *ArchiveDatabase) Keys() []string {
+	if a.archive == nil {
+		return []string{}
+	}
+	return a.archive.Keys()
+}
+
+func (a *ArchiveDatabase) Close() {
+	if a.archive == nil {
+		return
+	}
+	if err := a.archive.Close(); err != nil {
+		slog.Error("failed to close the content archive", "error", err)
+	}
+}
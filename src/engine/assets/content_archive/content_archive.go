@@ -12,50 +12,114 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
-	"unsafe"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
 
-	"kaijuengine.com/platform/filesystem"
 	"kaijuengine.com/platform/profiler/tracing"
 )
 
+const (
+	archiveVersion1 = 1
+	archiveVersion2 = 2
+	// ArchiveVersion is the version of the archives that are written
+	ArchiveVersion = archiveVersion2
+	// magic, version, file count, index size
+	headerSize = 4 + 2 + 4 + 8
+	// offset, size, crc
+	indexEntrySizeV1 = 8 + 4 + 4
+	// offset, stored size, size, crc, compression
+	indexEntrySizeV2 = 8 + 8 + 8 + 4 + 1
+)
+
 // Asset holds metadata.
 type Asset struct {
-	Name   string
-	Offset uint64
-	Size   uint32
-	CRC    uint32 // CRC32 of original (deobf) data.
-	Data   []byte // Loaded/cached deobf data.
+	Name        string
+	Offset      uint64
+	Size        uint64      // Size of the original data.
+	StoredSize  uint64      // Size of the data in the archive.
+	CRC         uint32      // CRC32 of original (deobf) data.
+	Compression Compression // Compression of the stored data.
+	Data        []byte      // Stored (compressed and obf) data when packing.
 }
 
-// Archive manages the packed assets.
+// Archive manages the packed assets. Only the index of the archive is held in
+// memory, the asset data is read from the archive's [io.ReaderAt] as it is
+// requested, so reads are safe to make from multiple goroutines.
 type Archive struct {
-	mmap   []byte
-	assets map[string]Asset
-	obfKey []byte
+	reader  io.ReaderAt
+	closer  io.Closer
+	size    int64
+	version uint16
+	assets  map[string]Asset
+	obfKey  []byte
 }
 
+// OpenArchiveFile opens the archive file at the path. The file is kept open
+// for reading the assets until [Archive.Close] is called.
 func OpenArchiveFile(path string, key []byte) (*Archive, error) {
 	defer tracing.NewRegion("content_archive.OpenArchiveFile").End()
-	data, err := filesystem.ReadFile(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	arc, err := OpenArchive(f, stat.Size(), key)
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
-	return OpenArchiveFromBytes(data, key)
+	arc.closer = f
+	return arc, nil
 }
 
 func OpenArchiveFromBytes(data []byte, key []byte) (*Archive, error) {
 	defer tracing.NewRegion("content_archive.OpenArchiveFromBytes").End()
-	if len(data) < 18 || !bytes.Equal(data[:4], title()) {
+	return OpenArchive(bytes.NewReader(data), int64(len(data)), key)
+}
+
+// OpenArchive reads the index of the archive that is held in the reader, size
+// is the total size of the archive in bytes
+func OpenArchive(r io.ReaderAt, size int64, key []byte) (*Archive, error) {
+	defer tracing.NewRegion("content_archive.OpenArchive").End()
+	header := make([]byte, headerSize)
+	if size < headerSize {
+		return nil, errors.New("invalid content archive")
+	}
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.Equal(header[:4], title()) {
 		return nil, errors.New("invalid content archive")
 	}
 	arc := &Archive{
-		mmap:   data,
-		assets: make(map[string]Asset),
-		obfKey: key,
+		reader:  r,
+		size:    size,
+		version: binary.LittleEndian.Uint16(header[4:6]),
+		assets:  make(map[string]Asset),
+		obfKey:  key,
+	}
+	entrySize := uint64(0)
+	switch arc.version {
+	case archiveVersion1:
+		entrySize = indexEntrySizeV1
+	case archiveVersion2:
+		entrySize = indexEntrySizeV2
+	default:
+		return nil, fmt.Errorf("unsupported content archive version %d", arc.version)
+	}
+	numFiles := binary.LittleEndian.Uint32(header[6:10])
+	indexEnd := binary.LittleEndian.Uint64(header[10:18])
+	if indexEnd > uint64(size-headerSize) {
+		return nil, errors.New("the content archive index is larger than the archive")
+	}
+	readMapArea := make([]byte, indexEnd)
+	if _, err := r.ReadAt(readMapArea, headerSize); err != nil {
+		return nil, fmt.Errorf("failed to read the content archive index: %w", err)
 	}
-	numFiles := binary.LittleEndian.Uint32(arc.mmap[6:10])
-	indexEnd := binary.LittleEndian.Uint64(arc.mmap[10:18])
-	readMapArea := arc.mmap[18:]
 	pos := uint64(0)
 	for i := uint32(0); i < numFiles; i++ {
 		nameEnd := bytes.IndexByte(readMapArea[pos:], 0)
@@ -65,49 +129,147 @@ func OpenArchiveFromBytes(data []byte, key []byte) (*Archive, error) {
 		a := Asset{}
 		a.Name = string(readMapArea[pos : pos+uint64(nameEnd)])
 		pos += uint64(nameEnd + 1)
-		a.Offset = binary.LittleEndian.Uint64(readMapArea[pos : pos+8])
-		pos += uint64(unsafe.Sizeof(a.Offset))
-		a.Size = binary.LittleEndian.Uint32(readMapArea[pos : pos+4])
-		pos += uint64(unsafe.Sizeof(a.Size))
-		a.CRC = binary.LittleEndian.Uint32(readMapArea[pos : pos+4])
-		pos += uint64(unsafe.Sizeof(a.CRC))
-		arc.assets[a.Name] = a
-		if pos > indexEnd {
+		if pos+entrySize > indexEnd {
 			return nil, fmt.Errorf("index overflow at file %d", i)
 		}
+		entry := readMapArea[pos : pos+entrySize]
+		pos += entrySize
+		a.Offset = binary.LittleEndian.Uint64(entry[0:8])
+		if arc.version == archiveVersion1 {
+			a.Size = uint64(binary.LittleEndian.Uint32(entry[8:12]))
+			a.StoredSize = a.Size
+			a.CRC = binary.LittleEndian.Uint32(entry[12:16])
+		} else {
+			a.StoredSize = binary.LittleEndian.Uint64(entry[8:16])
+			a.Size = binary.LittleEndian.Uint64(entry[16:24])
+			a.CRC = binary.LittleEndian.Uint32(entry[24:28])
+			a.Compression = Compression(entry[28])
+		}
+		if a.Offset > uint64(size) || a.StoredSize > uint64(size)-a.Offset {
+			return nil, fmt.Errorf("the data for %s is outside of the archive", a.Name)
+		}
+		arc.assets[a.Name] = a
 	}
 	return arc, nil
 }
 
+// Version returns the format version of the opened archive
+func (a *Archive) Version() int { return int(a.version) }
+
+// Close closes the archive file if it was opened with [OpenArchiveFile]
+func (a *Archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	err := a.closer.Close()
+	a.closer = nil
+	return err
+}
+
 func (a *Archive) Exists(name string) bool {
 	_, ok := a.assets[name]
 	return ok
 }
 
+// Keys returns the names of all of the assets in the archive, sorted
+func (a *Archive) Keys() []string {
+	return slices.Sorted(maps.Keys(a.assets))
+}
+
+// Stat returns the metadata of the asset in the archive
+func (a *Archive) Stat(name string) (Asset, bool) {
+	asset, ok := a.assets[name]
+	return asset, ok
+}
+
+// Read reads the whole of the asset into memory, use [Archive.Open] to stream
+// large assets instead
 func (a *Archive) Read(name string) ([]byte, error) {
 	defer tracing.NewRegion("content_archive.Read").End()
 	asset, ok := a.assets[name]
 	if !ok {
 		return nil, fmt.Errorf("asset %q not found", name)
 	}
-	if asset.Data != nil {
-		return asset.Data, nil
-	}
-	start := int(asset.Offset)
-	end := start + int(asset.Size)
-	obfData := a.mmap[start:end]
-	deobfData := make([]byte, asset.Size)
-	copy(deobfData, obfData)
-	keyLen := len(a.obfKey)
-	if keyLen > 0 {
-		for i, b := range deobfData {
-			deobfData[i] = b ^ a.obfKey[i%keyLen]
-		}
+	stream, closer, err := a.stream(asset)
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	// The size is only as trustworthy as the index it came from, so the buffer
+	// grows as the data is read rather than being made that size up front
+	buf := bytes.NewBuffer(make([]byte, 0, min(asset.Size, asset.StoredSize)))
+	if _, err = buf.ReadFrom(io.LimitReader(stream, int64(min(asset.Size, math.MaxInt64)))); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if uint64(buf.Len()) != asset.Size {
+		return nil, fmt.Errorf("failed to read %s: %w", name, io.ErrUnexpectedEOF)
 	}
+	deobfData := buf.Bytes()
 	computedCRC := crc32.ChecksumIEEE(deobfData)
 	if computedCRC != asset.CRC {
 		return nil, fmt.Errorf("CRC mismatch for %s (expected %08x, got %08x)", name, asset.CRC, computedCRC)
 	}
-	asset.Data = deobfData
 	return deobfData, nil
 }
+
+// Open streams the asset out of the archive, the data is decompressed as it
+// is read. The CRC of the asset is checked once the end of the asset is read,
+// a mismatch is returned as the error of the final read.
+func (a *Archive) Open(name string) (io.ReadCloser, error) {
+	defer tracing.NewRegion("content_archive.Open").End()
+	asset, ok := a.assets[name]
+	if !ok {
+		return nil, fmt.Errorf("asset %q not found", name)
+	}
+	stream, closer, err := a.stream(asset)
+	if err != nil {
+		return nil, err
+	}
+	return &assetStream{
+		asset:     asset,
+		reader:    stream,
+		closer:    closer,
+		crc:       crc32.NewIEEE(),
+		remaining: asset.Size,
+	}, nil
+}
+
+// ReaderAt returns a reader that can read from anywhere in the asset without
+// reading the rest of it. Only uncompressed assets can be read this way, and
+// as the whole asset is never read, its CRC is not checked.
+func (a *Archive) ReaderAt(name string) (*AssetReader, error) {
+	asset, ok := a.assets[name]
+	if !ok {
+		return nil, fmt.Errorf("asset %q not found", name)
+	}
+	if asset.Compression != CompressionNone {
+		return nil, fmt.Errorf("asset %q is compressed with %s and can only be streamed with Open",
+			name, asset.Compression)
+	}
+	return a.storedReader(asset), nil
+}
+
+func (a *Archive) storedReader(asset Asset) *AssetReader {
+	return &AssetReader{
+		section: io.NewSectionReader(a.reader, int64(asset.Offset), int64(asset.StoredSize)),
+		key:     a.obfKey,
+	}
+}
+
+func (a *Archive) stream(asset Asset) (io.Reader, io.Closer, error) {
+	stored := a.storedReader(asset)
+	if asset.Compression == CompressionNone {
+		return stored, nil, nil
+	}
+	codec, err := codecFor(asset.Compression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", asset.Name, err)
+	}
+	r, err := codec.NewReader(stored)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", asset.Name, err)
+	}
+	return r, r, nil
+}
@@ -0,0 +1,89 @@
+/******************************************************************************/
+/* content_archive_compression.go                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package content_archive
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression identifies how an asset is compressed inside of a version 2
+// archive. The value is written into the archive index, so the values must
+// never change.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionDeflate
+	// CompressionZstd and CompressionLZ4 are reserved for codecs that are not
+	// built into the engine, a game that wants to use them registers the codec
+	// with [RegisterCodec] both when packing and when running.
+	CompressionZstd
+	CompressionLZ4
+)
+
+// Codec compresses and decompresses asset data for a [Compression]
+type Codec struct {
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecs = map[Compression]Codec{
+		CompressionDeflate: {
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(w, flate.BestCompression)
+			},
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return flate.NewReader(r), nil
+			},
+		},
+	}
+	codecsMutex sync.RWMutex
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionDeflate:
+		return "deflate"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionLZ4:
+		return "lz4"
+	default:
+		return fmt.Sprintf("compression(%d)", uint8(c))
+	}
+}
+
+// RegisterCodec sets the codec that is used for the compression, replacing
+// any codec that was already registered for it
+func RegisterCodec(compression Compression, codec Codec) error {
+	if compression == CompressionNone {
+		return fmt.Errorf("a codec can't be registered for %s", compression)
+	}
+	if codec.NewWriter == nil || codec.NewReader == nil {
+		return fmt.Errorf("the %s codec requires both a writer and a reader", compression)
+	}
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[compression] = codec
+	return nil
+}
+
+func codecFor(compression Compression) (Codec, error) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	c, ok := codecs[compression]
+	if !ok {
+		return c, fmt.Errorf("no codec has been registered for %s", compression)
+	}
+	return c, nil
+}
@@ -7,16 +7,17 @@
 package content_archive
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"fmt"
 	"hash/crc32"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
+	"slices"
 	"strings"
-	"unsafe"
 
 	"kaijuengine.com/debug"
 	"kaijuengine.com/platform/profiler/tracing"
@@ -64,23 +65,62 @@ func CreateArchiveFromFolder(reader FileReader, inPath, outPath string, key []by
 	return CreateArchiveFromFiles(reader, outPath, files, key)
 }
 
+// PackOptions control how the assets are written into an archive
+type PackOptions struct {
+	// Compression is used for each asset that gets smaller by compressing it,
+	// the rest are stored uncompressed
+	Compression Compression
+}
+
+// DefaultPackOptions are the options used by [CreateArchiveFromFiles]
+func DefaultPackOptions() PackOptions {
+	return PackOptions{Compression: CompressionDeflate}
+}
+
 func CreateArchiveFromFiles(reader FileReader, outPath string, files []SourceContent, key []byte) error {
+	return CreateArchiveFromFilesWithOptions(reader, outPath, files, key, DefaultPackOptions())
+}
+
+// CreateArchiveFromFilesWithOptions writes the files into a new archive at the
+// out path. Each asset is written to the archive as soon as it has been read,
+// so only one asset is held in memory at a time.
+func CreateArchiveFromFilesWithOptions(reader FileReader, outPath string, files []SourceContent, key []byte, opts PackOptions) error {
 	defer tracing.NewRegion("content_archive.CreateArchiveFromFiles").End()
 	if len(files) == 0 {
 		return fmt.Errorf("no assets were provided to archive")
 	}
+	files = slices.Clone(files)
+	slices.SortFunc(files, func(a, b SourceContent) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	indexSize := uint64(0)
+	for i := range files {
+		indexSize += uint64(len([]byte(files[i].Key))+1) + indexEntrySizeV2
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dataStart := uint64(headerSize) + indexSize
+	if _, err = f.Seek(int64(dataStart), io.SeekStart); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
 	entries := make([]Asset, 0, len(files))
 	buff := bytes.NewBuffer([]byte{})
+	offset := dataStart
+	totalSize := uint64(0)
+	compressedCount := 0
 	for i := range files {
-		var err error
 		buff.Reset()
 		if len(files[i].RawData) > 0 {
 			_, err = buff.ReadFrom(bytes.NewReader(files[i].RawData))
 		} else {
-			var f *os.File
-			if f, err = os.Open(files[i].FullPath); err == nil {
-				_, err = buff.ReadFrom(f)
-				closeErr := f.Close()
+			var src *os.File
+			if src, err = os.Open(files[i].FullPath); err == nil {
+				_, err = buff.ReadFrom(src)
+				closeErr := src.Close()
 				if err == nil {
 					err = closeErr
 				}
@@ -96,74 +136,90 @@ func CreateArchiveFromFiles(reader FileReader, outPath string, files []SourceCon
 		if err != nil {
 			return err
 		}
-		crc := crc32.ChecksumIEEE(srcData)
-		obfData := make([]byte, len(srcData))
-		copy(obfData, srcData)
+		entry := Asset{
+			Name:   files[i].Key,
+			Offset: offset,
+			Size:   uint64(len(srcData)),
+			CRC:    crc32.ChecksumIEEE(srcData),
+		}
+		entry.Data, entry.Compression, err = compressAsset(srcData, opts.Compression)
+		if err != nil {
+			return fmt.Errorf("failed to compress %s: %w", entry.Name, err)
+		}
+		if entry.Compression != CompressionNone {
+			compressedCount++
+		}
+		entry.StoredSize = uint64(len(entry.Data))
 		keyLen := len(key)
 		if keyLen > 0 {
-			for i, b := range obfData {
-				obfData[i] = b ^ key[i%keyLen]
+			for i, b := range entry.Data {
+				entry.Data[i] = b ^ key[i%keyLen]
 			}
 		}
-		entries = append(entries, Asset{
-			Name: files[i].Key,
-			Data: obfData,
-			Size: uint32(len(srcData)),
-			CRC:  crc,
-		})
-	}
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].Name < entries[j].Name
-	})
-	indexSize := uint64(0)
-	for i := range entries {
-		indexSize += uint64(len([]byte(entries[i].Name))+1) +
-			uint64(unsafe.Sizeof(entries[i].Offset)+
-				unsafe.Sizeof(entries[i].Size)+unsafe.Sizeof(entries[i].CRC))
-	}
-	indexBuf := make([]byte, 0, indexSize)
-	for i := range entries {
-		indexBuf = append(indexBuf, []byte(entries[i].Name)...)
-		indexBuf = append(indexBuf, byte(0))                     // Name null terminator
-		indexBuf = binary.LittleEndian.AppendUint64(indexBuf, 0) // Offset placeholder
-		indexBuf = binary.LittleEndian.AppendUint32(indexBuf, entries[i].Size)
-		indexBuf = binary.LittleEndian.AppendUint32(indexBuf, entries[i].CRC)
+		pad := (4 - int(entry.StoredSize)%4) % 4
+		if _, err = w.Write(entry.Data); err == nil && pad > 0 {
+			_, err = w.Write(make([]byte, pad))
+		}
+		if err != nil {
+			return err
+		}
+		offset += entry.StoredSize + uint64(pad)
+		totalSize += entry.StoredSize + uint64(pad)
+		entry.Data = nil
+		entries = append(entries, entry)
 	}
-	debug.Ensure(indexSize == uint64(len(indexBuf)))
-	dataStart := uint64(18) + indexSize
-	offset := dataStart
-	for i := range entries {
-		entries[i].Offset = offset
-		pad := (4 - int(entries[i].Size)%4) % 4
-		offset += uint64(entries[i].Size) + uint64(pad)
+	if err = w.Flush(); err != nil {
+		return err
 	}
-	indexWritePos := uint64(0)
+	header := make([]byte, 0, dataStart)
+	header = append(header, title()...)
+	header = binary.LittleEndian.AppendUint16(header, ArchiveVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(entries)))
+	header = binary.LittleEndian.AppendUint64(header, indexSize)
 	for i := range entries {
-		indexWritePos += uint64(len([]byte(entries[i].Name)) + 1)
-		binary.LittleEndian.PutUint64(indexBuf[indexWritePos:], entries[i].Offset)
-		indexWritePos += uint64(unsafe.Sizeof(entries[i].Offset) +
-			unsafe.Sizeof(entries[i].Size) + unsafe.Sizeof(entries[i].CRC))
+		header = append(header, []byte(entries[i].Name)...)
+		header = append(header, byte(0)) // Name null terminator
+		header = binary.LittleEndian.AppendUint64(header, entries[i].Offset)
+		header = binary.LittleEndian.AppendUint64(header, entries[i].StoredSize)
+		header = binary.LittleEndian.AppendUint64(header, entries[i].Size)
+		header = binary.LittleEndian.AppendUint32(header, entries[i].CRC)
+		header = append(header, byte(entries[i].Compression))
 	}
-	f, err := os.Create(outPath)
-	if err != nil {
+	debug.Ensure(dataStart == uint64(len(header)))
+	if _, err = f.WriteAt(header, 0); err != nil {
 		return err
 	}
-	defer f.Close()
-	f.Write(title())
-	binary.Write(f, binary.LittleEndian, uint16(1))
-	binary.Write(f, binary.LittleEndian, uint32(len(entries)))
-	binary.Write(f, binary.LittleEndian, indexSize)
-	f.Write(indexBuf)
-	totalSize := uint64(0)
-	for i := range entries {
-		f.Write(entries[i].Data)
-		pad := (4 - int(entries[i].Size)%4) % 4
-		if pad > 0 {
-			f.Write(make([]byte, pad))
-		}
-		totalSize += uint64(len(entries[i].Data) + pad)
+	if err = f.Close(); err != nil {
+		return err
 	}
 	slog.Info("packaged content archive", "count", len(entries),
-		"size", totalSize, "obfuscated", len(key) > 0)
+		"size", totalSize, "compressed", compressedCount, "obfuscated", len(key) > 0)
 	return nil
 }
+
+// compressAsset returns the data as it should be stored in the archive. The
+// data is only stored compressed if compressing it made it smaller, already
+// compressed content such as images and audio often do not.
+func compressAsset(data []byte, compression Compression) ([]byte, Compression, error) {
+	if compression != CompressionNone && len(data) > 0 {
+		codec, err := codecFor(compression)
+		if err != nil {
+			return nil, CompressionNone, err
+		}
+		out := bytes.Buffer{}
+		cw, err := codec.NewWriter(&out)
+		if err != nil {
+			return nil, CompressionNone, err
+		}
+		if _, err = cw.Write(data); err == nil {
+			err = cw.Close()
+		}
+		if err != nil {
+			return nil, CompressionNone, err
+		}
+		if out.Len() < len(data) {
+			return out.Bytes(), compression, nil
+		}
+	}
+	return slices.Clone(data), CompressionNone, nil
+}
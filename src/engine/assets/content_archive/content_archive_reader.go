@@ -0,0 +1,89 @@
+/******************************************************************************/
+/* content_archive_reader.go                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package content_archive
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// AssetReader reads the data of a single asset straight out of the archive,
+// removing the obfuscation as it goes. It implements [io.ReaderAt] and
+// [io.ReadSeeker] over the asset.
+type AssetReader struct {
+	section *io.SectionReader
+	key     []byte
+}
+
+// Size returns the size of the asset in bytes
+func (r *AssetReader) Size() int64 { return r.section.Size() }
+
+func (r *AssetReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.section.ReadAt(p, off)
+	r.deobfuscate(p[:n], off)
+	return n, err
+}
+
+func (r *AssetReader) Read(p []byte) (int, error) {
+	off, _ := r.section.Seek(0, io.SeekCurrent)
+	n, err := r.section.Read(p)
+	r.deobfuscate(p[:n], off)
+	return n, err
+}
+
+func (r *AssetReader) Seek(offset int64, whence int) (int64, error) {
+	return r.section.Seek(offset, whence)
+}
+
+// deobfuscate removes the key from data that was read starting at the offset
+// into the asset
+func (r *AssetReader) deobfuscate(data []byte, off int64) {
+	keyLen := int64(len(r.key))
+	if keyLen == 0 {
+		return
+	}
+	for i := range data {
+		data[i] ^= r.key[(off+int64(i))%keyLen]
+	}
+}
+
+// assetStream is the reader returned from [Archive.Open], it checks the size
+// and CRC of the asset once it has been fully read
+type assetStream struct {
+	asset     Asset
+	reader    io.Reader
+	closer    io.Closer
+	crc       hash.Hash32
+	remaining uint64
+}
+
+func (s *assetStream) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	if uint64(n) > s.remaining {
+		return 0, fmt.Errorf("asset %s is larger than its recorded size", s.asset.Name)
+	}
+	s.remaining -= uint64(n)
+	s.crc.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if s.remaining > 0 {
+			return n, fmt.Errorf("asset %s ended %d bytes early: %w", s.asset.Name, s.remaining, io.ErrUnexpectedEOF)
+		}
+		if sum := s.crc.Sum32(); sum != s.asset.CRC {
+			return n, fmt.Errorf("CRC mismatch for %s (expected %08x, got %08x)", s.asset.Name, s.asset.CRC, sum)
+		}
+	}
+	return n, err
+}
+
+func (s *assetStream) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,224 @@
+/******************************************************************************/
+/* content_archive_test.go                                                    */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package content_archive
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("secret")
+
+func testFiles() []SourceContent {
+	noise := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(noise)
+	return []SourceContent{
+		{Key: "text/story.txt", RawData: []byte(strings.Repeat("once upon a time ", 500))},
+		{Key: "noise.bin", RawData: noise},
+		{Key: "small.txt", RawData: []byte("hi")},
+	}
+}
+
+func packTestArchive(t *testing.T, opts PackOptions) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "content.dat")
+	if err := CreateArchiveFromFilesWithOptions(nil, path, testFiles(), testKey, opts); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeArchiveV1 writes the files in the original archive layout, which was
+// uncompressed with the index entries using a 32 bit size
+func writeArchiveV1(files []SourceContent, key []byte) []byte {
+	indexSize := 0
+	for _, f := range files {
+		indexSize += len(f.Key) + 1 + indexEntrySizeV1
+	}
+	out := append([]byte{}, title()...)
+	out = binary.LittleEndian.AppendUint16(out, archiveVersion1)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(files)))
+	out = binary.LittleEndian.AppendUint64(out, uint64(indexSize))
+	offset := uint64(headerSize + indexSize)
+	data := []byte{}
+	for _, f := range files {
+		out = append(out, append([]byte(f.Key), 0)...)
+		out = binary.LittleEndian.AppendUint64(out, offset)
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(f.RawData)))
+		out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(f.RawData))
+		for i, b := range f.RawData {
+			data = append(data, b^key[i%len(key)])
+		}
+		offset += uint64(len(f.RawData))
+	}
+	return append(out, data...)
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	arc, err := OpenArchiveFile(packTestArchive(t, DefaultPackOptions()), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arc.Close()
+	if arc.Version() != ArchiveVersion {
+		t.Errorf("version = %d, want %d", arc.Version(), ArchiveVersion)
+	}
+	for _, f := range testFiles() {
+		data, err := arc.Read(f.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, f.RawData) {
+			t.Errorf("read data for %s does not match", f.Key)
+		}
+		r, err := arc.Open(f.Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || !bytes.Equal(streamed, f.RawData) {
+			t.Errorf("streamed data for %s does not match, %v", f.Key, err)
+		}
+	}
+	if story, _ := arc.Stat("text/story.txt"); story.Compression != CompressionDeflate || story.StoredSize >= story.Size {
+		t.Errorf("expected the story to be stored compressed, got %+v", story)
+	}
+	if noise, _ := arc.Stat("noise.bin"); noise.Compression != CompressionNone {
+		t.Errorf("incompressible data should be stored as is, got %s", noise.Compression)
+	}
+}
+
+func TestArchiveReaderAt(t *testing.T) {
+	arc, err := OpenArchiveFile(packTestArchive(t, DefaultPackOptions()), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arc.Close()
+	noise := testFiles()[1].RawData
+	r, err := arc.ReaderAt("noise.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(noise)) {
+		t.Errorf("size = %d, want %d", r.Size(), len(noise))
+	}
+	part := make([]byte, 100)
+	if _, err = r.ReadAt(part, 1003); err != nil || !bytes.Equal(part, noise[1003:1103]) {
+		t.Errorf("ReadAt returned the wrong data, %v", err)
+	}
+	if _, err = r.Seek(2000, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadFull(r, part); err != nil || !bytes.Equal(part, noise[2000:2100]) {
+		t.Errorf("Read after Seek returned the wrong data, %v", err)
+	}
+	if _, err = arc.ReaderAt("text/story.txt"); err == nil {
+		t.Error("expected an error for random access into a compressed asset")
+	}
+}
+
+func TestArchiveReadsVersion1(t *testing.T) {
+	files := testFiles()
+	arc, err := OpenArchiveFromBytes(writeArchiveV1(files, testKey), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arc.Version() != archiveVersion1 {
+		t.Errorf("version = %d, want 1", arc.Version())
+	}
+	for _, f := range files {
+		if data, err := arc.Read(f.Key); err != nil || !bytes.Equal(data, f.RawData) {
+			t.Errorf("version 1 data for %s does not match, %v", f.Key, err)
+		}
+	}
+}
+
+func TestArchiveDetectsCorruption(t *testing.T) {
+	path := packTestArchive(t, PackOptions{Compression: CompressionNone})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arc, err := OpenArchiveFromBytes(data, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, _ := arc.Stat("small.txt")
+	data[asset.Offset] ^= 0xFF
+	if _, err = arc.Read("small.txt"); err == nil {
+		t.Error("expected a CRC error from Read")
+	}
+	r, _ := arc.Open("small.txt")
+	if _, err = io.ReadAll(r); err == nil {
+		t.Error("expected a CRC error at the end of the stream")
+	}
+	if _, err = OpenArchiveFromBytes(data[:headerSize+4], testKey); err == nil {
+		t.Error("expected an error for a truncated index")
+	}
+}
+
+func TestArchiveReadRejectsOversizedIndexSize(t *testing.T) {
+	data, err := os.ReadFile(packTestArchive(t, DefaultPackOptions()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The size of the data once it is decompressed follows the offset and the
+	// stored size of the index entry
+	name := []byte("text/story.txt\x00")
+	entry := bytes.Index(data, name) + len(name)
+	binary.LittleEndian.PutUint64(data[entry+16:], 1<<62)
+	arc, err := OpenArchiveFromBytes(data, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = arc.Read("text/story.txt"); err == nil {
+		t.Error("expected an error reading an asset that is smaller than its index says")
+	}
+}
+
+func TestArchiveRegisteredCodec(t *testing.T) {
+	if err := RegisterCodec(CompressionNone, Codec{}); err == nil {
+		t.Error("expected an error registering a codec for no compression")
+	}
+	if err := CreateArchiveFromFilesWithOptions(nil, filepath.Join(t.TempDir(), "a.dat"),
+		testFiles(), nil, PackOptions{Compression: CompressionLZ4}); err == nil {
+		t.Error("expected an error packing with a codec that is not registered")
+	}
+	err := RegisterCodec(CompressionZstd, Codec{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.BestSpeed) },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		codecsMutex.Lock()
+		delete(codecs, CompressionZstd)
+		codecsMutex.Unlock()
+	}()
+	arc, err := OpenArchiveFile(packTestArchive(t, PackOptions{Compression: CompressionZstd}), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arc.Close()
+	story := testFiles()[0]
+	if a, _ := arc.Stat(story.Key); a.Compression != CompressionZstd {
+		t.Errorf("compression = %s, want zstd", a.Compression)
+	}
+	if data, err := arc.Read(story.Key); err != nil || !bytes.Equal(data, story.RawData) {
+		t.Errorf("data read with the registered codec does not match, %v", err)
+	}
+}
@@ -7,6 +7,7 @@
 package assets
 
 import (
+	"io/fs"
 	"os"
 
 	"kaijuengine.com/platform/profiler/tracing"
@@ -52,6 +53,22 @@ func (a *FileDatabase) Exists(key string) bool {
 	return err == nil
 }
 
+// Keys returns the slash separated paths of all of the files in the root
+// folder, sorted
+func (a *FileDatabase) Keys() []string {
+	keys := []string{}
+	if a.root == nil {
+		return keys
+	}
+	fs.WalkDir(a.root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			keys = append(keys, path)
+		}
+		return nil
+	})
+	return keys
+}
+
 func (a *FileDatabase) Close() {
 	if a.root != nil {
 		a.root.Close()
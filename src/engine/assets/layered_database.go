@@ -0,0 +1,295 @@
+/******************************************************************************/
+/* layered_database.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"kaijuengine.com/platform/profiler/tracing"
+)
+
+// KeyLister can be implemented by a [Database] that is able to list all of the
+// keys that it holds. It is used to build the [LayeredDatabase.Manifest].
+type KeyLister interface {
+	Keys() []string
+}
+
+// Layer is a single database in a [LayeredDatabase]
+type Layer struct {
+	Name     string
+	Priority int
+	Database Database
+}
+
+// LayeredDatabase stacks multiple databases so that patches, DLC and mods can
+// override or add to the content of the base game without rebuilding it. When
+// a key is read, the layer with the highest priority that holds the key is
+// used, layers that have the same priority are checked with the most recently
+// added first.
+type LayeredDatabase struct {
+	layers []Layer
+	cache  map[string][]byte
+	mutex  sync.RWMutex
+}
+
+// ManifestEntry describes which layer supplies a key, and which lower layers
+// also hold the key but are overridden
+type ManifestEntry struct {
+	Key        string   `json:"key"`
+	Layer      string   `json:"layer"`
+	Overridden []string `json:"overridden,omitempty"`
+}
+
+// Manifest lists the keys of a [LayeredDatabase] and the layer that supplies
+// each of them, sorted by key. Only layers that implement [KeyLister] can be
+// included.
+type Manifest struct {
+	Layers   []string        `json:"layers"`
+	Unlisted []string        `json:"unlisted,omitempty"`
+	Entries  []ManifestEntry `json:"entries"`
+}
+
+// NewLayeredDatabase creates a database from the given layers
+func NewLayeredDatabase(layers ...Layer) *LayeredDatabase {
+	l := &LayeredDatabase{cache: make(map[string][]byte)}
+	for i := range layers {
+		l.AddLayer(layers[i].Name, layers[i].Priority, layers[i].Database)
+	}
+	return l
+}
+
+// AddLayer adds the database as a layer with the given priority. The name is
+// used to identify the layer, it should be unique within the database.
+func (l *LayeredDatabase) AddLayer(name string, priority int, db Database) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if slices.ContainsFunc(l.layers, func(layer Layer) bool { return layer.Name == name }) {
+		slog.Warn("a layer with the same name is already in the layered database", "name", name)
+	}
+	idx := slices.IndexFunc(l.layers, func(layer Layer) bool { return layer.Priority <= priority })
+	if idx < 0 {
+		idx = len(l.layers)
+	}
+	l.layers = slices.Insert(l.layers, idx, Layer{Name: name, Priority: priority, Database: db})
+	clear(l.cache)
+}
+
+// RemoveLayer removes the layer with the name and returns its database, it is
+// up to the caller to close the database if it is no longer needed
+func (l *LayeredDatabase) RemoveLayer(name string) (Database, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	idx := slices.IndexFunc(l.layers, func(layer Layer) bool { return layer.Name == name })
+	if idx < 0 {
+		return nil, false
+	}
+	db := l.layers[idx].Database
+	l.layers = slices.Delete(l.layers, idx, idx+1)
+	clear(l.cache)
+	return db, true
+}
+
+// Layers returns the layers in the order that they are checked
+func (l *LayeredDatabase) Layers() []Layer {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return slices.Clone(l.layers)
+}
+
+// AddModFolders adds each folder within the directory as a layer, this is
+// meant for user mod folders. The folders are added in name order with
+// increasing priority starting at the given priority. A missing directory
+// adds no layers and is not an error.
+func (l *LayeredDatabase) AddModFolders(dir string, priority int) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		db, err := NewFileDatabase(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		l.AddLayer("mod:"+entry.Name(), priority, db)
+		priority++
+	}
+	return errors.Join(errs...)
+}
+
+// AddArchives adds each archive that matches the glob pattern as a layer,
+// this is meant for patch and DLC archives. The archives are added in name
+// order with increasing priority starting at the given priority, so later
+// patches override earlier ones when they are named in order.
+func (l *LayeredDatabase) AddArchives(pattern string, key []byte, priority int) error {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	slices.Sort(paths)
+	errs := []error{}
+	for _, path := range paths {
+		db, err := NewArchiveDatabase(path, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to open archive %s: %w", path, err))
+			continue
+		}
+		l.AddLayer("archive:"+filepath.Base(path), priority, db)
+		priority++
+	}
+	return errors.Join(errs...)
+}
+
+// LayerFor returns the layer that supplies the key
+func (l *LayeredDatabase) LayerFor(key string) (Layer, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for i := range l.layers {
+		if l.layers[i].Database.Exists(key) {
+			return l.layers[i], true
+		}
+	}
+	return Layer{}, false
+}
+
+// Manifest builds the list of keys held by the layers and which layer each
+// key comes from
+func (l *LayeredDatabase) Manifest() Manifest {
+	defer tracing.NewRegion("LayeredDatabase.Manifest").End()
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	m := Manifest{Layers: []string{}, Entries: []ManifestEntry{}}
+	entries := map[string]*ManifestEntry{}
+	for i := range l.layers {
+		layer := &l.layers[i]
+		m.Layers = append(m.Layers, layer.Name)
+		lister, ok := layer.Database.(KeyLister)
+		if !ok {
+			m.Unlisted = append(m.Unlisted, layer.Name)
+			continue
+		}
+		for _, key := range lister.Keys() {
+			if e, ok := entries[key]; ok {
+				e.Overridden = append(e.Overridden, layer.Name)
+			} else {
+				entries[key] = &ManifestEntry{Key: key, Layer: layer.Name}
+			}
+		}
+	}
+	for _, e := range entries {
+		m.Entries = append(m.Entries, *e)
+	}
+	slices.SortFunc(m.Entries, func(a, b ManifestEntry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return m
+}
+
+// Keys returns all of the keys from the layers that can list their keys
+func (l *LayeredDatabase) Keys() []string {
+	entries := l.Manifest().Entries
+	keys := make([]string, len(entries))
+	for i := range entries {
+		keys[i] = entries[i].Key
+	}
+	return keys
+}
+
+// Write writes the manifest as indented JSON
+func (m Manifest) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(m)
+}
+
+func (l *LayeredDatabase) PostWindowCreate(windowHandle PostWindowCreateHandle) error {
+	defer tracing.NewRegion("LayeredDatabase.PostWindowCreate").End()
+	errs := []error{}
+	for _, layer := range l.Layers() {
+		if err := layer.Database.PostWindowCreate(windowHandle); err != nil {
+			errs = append(errs, fmt.Errorf("layer %s: %w", layer.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l *LayeredDatabase) Cache(key string, data []byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.cache[key] = data
+}
+
+func (l *LayeredDatabase) CacheRemove(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.cache, key)
+}
+
+func (l *LayeredDatabase) CacheClear() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	clear(l.cache)
+}
+
+func (l *LayeredDatabase) ReadText(key string) (string, error) {
+	defer tracing.NewRegion("LayeredDatabase.ReadText: " + key).End()
+	data, err := l.Read(key)
+	return string(data), err
+}
+
+func (l *LayeredDatabase) Read(key string) ([]byte, error) {
+	defer tracing.NewRegion("LayeredDatabase.Read: " + key).End()
+	l.mutex.RLock()
+	if data, ok := l.cache[key]; ok {
+		l.mutex.RUnlock()
+		return data, nil
+	}
+	l.mutex.RUnlock()
+	layer, ok := l.LayerFor(key)
+	if !ok {
+		return nil, fmt.Errorf("asset %q was not found in any layer", key)
+	}
+	return layer.Database.Read(key)
+}
+
+func (l *LayeredDatabase) Exists(key string) bool {
+	defer tracing.NewRegion("LayeredDatabase.Exists: " + key).End()
+	l.mutex.RLock()
+	_, ok := l.cache[key]
+	l.mutex.RUnlock()
+	if ok {
+		return true
+	}
+	_, ok = l.LayerFor(key)
+	return ok
+}
+
+// Close closes all of the layers
+func (l *LayeredDatabase) Close() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for i := range l.layers {
+		l.layers[i].Database.Close()
+	}
+	l.layers = l.layers[:0]
+	clear(l.cache)
+}
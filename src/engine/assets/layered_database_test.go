@@ -0,0 +1,113 @@
+/******************************************************************************/
+/* layered_database_test.go                                                   */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package assets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kaijuengine.com/engine/assets/content_archive"
+)
+
+func newTestLayers() *LayeredDatabase {
+	base := NewMockDB(map[string][]byte{
+		"hero.png":  []byte("base hero"),
+		"level.txt": []byte("base level"),
+	})
+	patch := NewMockDB(map[string][]byte{
+		"level.txt": []byte("patched level"),
+	})
+	dlc := NewMockDB(map[string][]byte{
+		"dlc.txt": []byte("dlc"),
+	})
+	return NewLayeredDatabase(
+		Layer{Name: "base", Priority: 0, Database: base},
+		Layer{Name: "dlc", Priority: 10, Database: dlc},
+		Layer{Name: "patch", Priority: 10, Database: patch},
+	)
+}
+
+func TestLayeredDatabaseOverrides(t *testing.T) {
+	db := newTestLayers()
+	for key, want := range map[string]string{
+		"hero.png":  "base hero",
+		"level.txt": "patched level",
+		"dlc.txt":   "dlc",
+	} {
+		if got, err := db.ReadText(key); err != nil || got != want {
+			t.Errorf("%s = %q (%v), want %q", key, got, err, want)
+		}
+	}
+	if _, err := db.Read("missing"); err == nil || db.Exists("missing") {
+		t.Error("expected a missing key to not be found")
+	}
+	if names := []string{db.Layers()[0].Name, db.Layers()[1].Name}; names[0] != "patch" || names[1] != "dlc" {
+		t.Errorf("layers with the same priority should check the newest first, got %v", names)
+	}
+	if _, ok := db.RemoveLayer("patch"); !ok {
+		t.Fatal("expected the patch layer to be removed")
+	}
+	if got, _ := db.ReadText("level.txt"); got != "base level" {
+		t.Errorf("level.txt = %q after removing the patch", got)
+	}
+}
+
+func TestLayeredDatabaseManifest(t *testing.T) {
+	db := newTestLayers()
+	m := db.Manifest()
+	if len(m.Entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(m.Entries))
+	}
+	level := m.Entries[2]
+	if level.Key != "level.txt" || level.Layer != "patch" || len(level.Overridden) != 1 || level.Overridden[0] != "base" {
+		t.Errorf("unexpected manifest entry %+v", level)
+	}
+	db.AddLayer("debug", -1, DebugContentDatabase{})
+	if m = db.Manifest(); len(m.Unlisted) != 1 || m.Unlisted[0] != "debug" {
+		t.Errorf("expected the debug layer to be unlisted, got %v", m.Unlisted)
+	}
+	buf := bytes.Buffer{}
+	if err := m.Write(&buf); err != nil || !bytes.Contains(buf.Bytes(), []byte(`"overridden"`)) {
+		t.Errorf("unexpected manifest json %s, %v", buf.String(), err)
+	}
+}
+
+func TestLayeredDatabaseModsAndArchives(t *testing.T) {
+	dir := t.TempDir()
+	for mod, content := range map[string]string{"a_mod": "from a", "b_mod": "from b"} {
+		os.MkdirAll(filepath.Join(dir, "mods", mod), os.ModePerm)
+		os.WriteFile(filepath.Join(dir, "mods", mod, "level.txt"), []byte(content), 0644)
+	}
+	err := content_archive.CreateArchiveFromFiles(nil, filepath.Join(dir, "patch_01.dat"),
+		[]content_archive.SourceContent{{Key: "level.txt", RawData: []byte("from patch")},
+			{Key: "new.txt", RawData: []byte("new")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestLayers()
+	defer db.Close()
+	if err = db.AddArchives(filepath.Join(dir, "patch_*.dat"), nil, 20); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.ReadText("level.txt"); got != "from patch" {
+		t.Errorf("level.txt = %q, want the archive to override the base", got)
+	}
+	if err = db.AddModFolders(filepath.Join(dir, "mods"), 100); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.ReadText("level.txt"); got != "from b" {
+		t.Errorf("level.txt = %q, want the last mod to win", got)
+	}
+	if layer, _ := db.LayerFor("new.txt"); layer.Name != "archive:patch_01.dat" {
+		t.Errorf("new.txt comes from %q", layer.Name)
+	}
+	if err = db.AddModFolders(filepath.Join(dir, "missing"), 0); err != nil {
+		t.Errorf("a missing mod folder should not be an error, %v", err)
+	}
+}
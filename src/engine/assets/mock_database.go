@@ -8,6 +8,8 @@ package assets
 
 import (
 	"errors"
+	"maps"
+	"slices"
 )
 
 // MockDatabase implements the assets.Database interface for testing.
@@ -38,6 +40,10 @@ func (m *MockDatabase) ReadText(key string) (string, error) {
 	return "", errors.New("file not found")
 }
 
+func (m *MockDatabase) Keys() []string {
+	return slices.Sorted(maps.Keys(m.files))
+}
+
 func (m *MockDatabase) AddFile(key string, data []byte) {
 	m.files[key] = data
 }
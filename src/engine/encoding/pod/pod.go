@@ -83,6 +83,12 @@ func RegisterGenerated(pkg, name string, genType reflect.Type) error {
 	return nil
 }
 
+// IsRegistered returns true if the type of the layout has been registered
+func IsRegistered(layout any) bool {
+	_, ok := registry.Load(qualifiedName(reflect.TypeOf(layout)))
+	return ok
+}
+
 func QualifiedNameForLayout(layout any) string {
 	return qualifiedName(reflect.TypeOf(layout))
 }
@@ -40,6 +40,7 @@ type Entity struct {
 	Parent                *Entity
 	Children              []*Entity
 	namedData             sync.Map
+	entityData            []EntityData
 	OnDestroy             events.Event
 	OnDestroyRequested    events.Event
 	OnActivate            events.Event
@@ -292,6 +293,18 @@ func (e *Entity) RemoveNamedDataByName(key string) {
 	e.namedData.Delete(key)
 }
 
+// NamedDataKeys returns the keys of all of the named data on the entity, the
+// keys are sorted so that they are always in the same order
+func (e *Entity) NamedDataKeys() []string {
+	keys := []string{}
+	e.namedData.Range(func(key, _ any) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	slices.Sort(keys)
+	return keys
+}
+
 // NamedData will return the data associated with the specified key. If the key
 // does not exist, nil will be returned.
 func (e *Entity) NamedData(key string) []any {
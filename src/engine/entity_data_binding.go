@@ -42,6 +42,59 @@ func EntityDataInitPhase(data EntityData) EntityDataPhase {
 	return EntityDataPhaseDefault
 }
 
+// EntityDataBinder can be implemented by entity data that should stay bound to
+// its entity when it is loaded from a stage, so that its fields can be found
+// through [Entity.EntityData] by gameplay code or the save system. The bound
+// data is what is initialized, so an Init with a pointer receiver can keep the
+// data and change it during play. Entity data that doesn't implement it is
+// only initialized and isn't kept.
+type EntityDataBinder interface {
+	BindToEntity() bool
+}
+
+func EntityDataBindsToEntity(data EntityData) bool {
+	binder, ok := data.(EntityDataBinder)
+	return ok && binder.BindToEntity()
+}
+
+// AsEntityData returns the value as entity data. A value that only implements
+// [EntityData] through a pointer receiver is copied into a new pointer.
+func AsEntityData(value any) (EntityData, bool) {
+	if data, ok := value.(EntityData); ok {
+		return data, true
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.Kind() == reflect.Pointer {
+		return nil, false
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	data, ok := ptr.Interface().(EntityData)
+	return data, ok
+}
+
+// BindEntityData keeps the entity data with the entity so that its fields can
+// be read and changed after it has been initialized, such as by gameplay code
+// or the save system. Data that is not a pointer is copied into a new pointer
+// so that changes are made to the bound data, the bound data is returned and
+// is what should be initialized.
+func (e *Entity) BindEntityData(data EntityData) EntityData {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		if bound, ok := ptr.Interface().(EntityData); ok {
+			data = bound
+		}
+	}
+	e.entityData = append(e.entityData, data)
+	return data
+}
+
+// EntityData returns the entity data that has been bound to the entity through
+// [Entity.BindEntityData], in the order that it was bound
+func (e *Entity) EntityData() []EntityData { return e.entityData }
+
 func RegisterEntityData(value EntityData) error {
 	var err error
 	defer func() {
@@ -8,8 +8,10 @@ package engine
 
 import (
 	"log/slog"
+	"maps"
 	"math"
 	"runtime"
+	"slices"
 	"sync"
 	"time"
 	"weak"
@@ -101,13 +103,17 @@ type Host struct {
 	RenderTargets     rendering.RenderTargetManager
 	RenderViews       rendering.RenderViewManager
 	Localization      localization.Localization
+	Strings           *localization.Strings
 	frame             FrameId
 	frameTime         float64
 	Closing           bool
 	UIUpdater         Updater
 	UILateUpdater     Updater
 	Updater           Updater
+	FixedUpdater      Updater
 	LateUpdater       Updater
+	fixedTimestep     fixedTimestep
+	frameInput        FrameInput
 	assetDatabase     assets.Database
 	physics           StagePhysics
 	OnClose           events.Event
@@ -144,6 +150,7 @@ func NewHost(name string, logStream *logging.LogStream, assetDb assets.Database)
 			Clear:     true,
 		}),
 		Localization: localization.Select(),
+		Strings:      localization.NewStrings(assetDb, localization.String()),
 		entitiesById: make(map[EntityId]*Entity),
 		CloseSignal:  make(chan struct{}, 1),
 		LogStream:    logStream,
@@ -158,6 +165,7 @@ func NewHost(name string, logStream *logging.LogStream, assetDb assets.Database)
 	host.updateThreads.Initialize()
 	host.uiThreads.Initialize()
 	host.Updater = NewConcurrentUpdater(&host.updateThreads)
+	host.FixedUpdater = NewOrderedUpdater()
 	host.LateUpdater = NewConcurrentUpdater(&host.updateThreads)
 	host.UIUpdater = NewConcurrentUpdater(&host.updateThreads)
 	host.UILateUpdater = NewConcurrentUpdater(&host.updateThreads)
@@ -384,6 +392,17 @@ func (host *Host) EntityById(id EntityId) *Entity {
 	return host.entitiesById[id]
 }
 
+// EntitiesWithId returns all of the entities that currently have an identifier
+// registered with the host, sorted by their identifier.
+func (host *Host) EntitiesWithId() []*Entity {
+	ids := slices.Sorted(maps.Keys(host.entitiesById))
+	entities := make([]*Entity, 0, len(ids))
+	for _, id := range ids {
+		entities = append(entities, host.entitiesById[id])
+	}
+	return entities
+}
+
 // Plugins returns all of the loaded plugins for the host
 func (host *Host) Plugins() []*plugins.LuaVM {
 	return host.plugins
@@ -406,9 +425,12 @@ func (host *Host) Lighting() *lighting.LightingInformation {
 // The update order is FrameRunner -> Update -> LateUpdate -> EndUpdate:
 //
 // [-] FrameRunner: Functions added to RunAfterFrames
+// [-] FrameInput: The input processor given to SetFrameInput, if any
 // [-] UIUpdate: Functions added to UIUpdater
 // [-] UILateUpdate: Functions added to UILateUpdater
 // [-] Update: Functions added to Updater
+// [-] FixedUpdate: Functions added to FixedUpdater followed by physics, once
+// per fixed step when [Host.EnableFixedTimestep] is used
 // [-] LateUpdate: Functions added to LateUpdater
 // [-] EndUpdate: Internal functions for preparing for the next frame
 //
@@ -416,6 +438,9 @@ func (host *Host) Lighting() *lighting.LightingInformation {
 // tick the editor entities for cleanup.
 func (host *Host) Update(deltaTime float64) {
 	defer tracing.NewRegion("Host.Update").End()
+	if host.frameInput != nil {
+		deltaTime = host.frameInput.FrameDelta(host.frame+1, deltaTime)
+	}
 	host.Cameras.NewFrame()
 	debug.Ensure(deltaTime >= 0)
 	host.runnerMutex.Lock()
@@ -426,15 +451,14 @@ func (host *Host) Update(deltaTime float64) {
 	host.Window.Poll()
 	host.runFrameCallbacks()
 	host.runTimeCallbacks()
+	if host.frameInput != nil {
+		host.frameInput.ProcessInput(host)
+	}
 	host.UIUpdater.Update(deltaTime)
 	host.UILateUpdater.Update(deltaTime)
 	tweening.Update(deltaTime)
 	host.Updater.Update(deltaTime)
-	if !build.Editor {
-		if host.physics.IsActive() {
-			host.physics.Update(host.WorkGroup(), &host.threads, deltaTime)
-		}
-	}
+	host.fixedUpdate(deltaTime)
 	host.LateUpdater.Update(deltaTime)
 	host.collisionManager.Update(deltaTime)
 	if host.Window.IsClosed() || host.Window.IsCrashed() {
@@ -563,6 +587,7 @@ func (host *Host) Teardown() {
 	host.UIUpdater.Destroy()
 	host.UILateUpdater.Destroy()
 	host.Updater.Destroy()
+	host.FixedUpdater.Destroy()
 	host.LateUpdater.Destroy()
 	if host.renderThread != nil {
 		if err := host.renderThread.Stop(); err != nil {
@@ -0,0 +1,143 @@
+/******************************************************************************/
+/* host_fixed_timestep.go                                                     */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine
+
+import (
+	"math"
+
+	"kaijuengine.com/build"
+	"kaijuengine.com/platform/profiler/tracing"
+)
+
+const (
+	// DefaultFixedStep is the simulation step, in seconds, that is used when
+	// the fixed timestep is enabled without a step
+	DefaultFixedStep = 1.0 / 60.0
+	// DefaultMaxCatchUpSteps is the number of fixed steps that can run within
+	// a single frame when the fixed timestep is enabled without a limit
+	DefaultMaxCatchUpSteps = 5
+)
+
+// fixedTimestep accumulates the variable frame time and splits it into
+// whole simulation steps. The time left over is kept for the next frame and
+// is exposed as the interpolation alpha. The physics step from before it was
+// enabled is kept to be put back when it is disabled.
+type fixedTimestep struct {
+	enabled     bool
+	step        float64
+	maxCatchUp  int
+	accumulator float64
+	alpha       float64
+	tick        uint64
+	physicsStep float64
+}
+
+// advance adds the frame time to the accumulator and returns how many fixed
+// steps are to be run this frame. When more than the max catch-up steps are
+// owed, the extra whole steps are dropped so that a long stall (loading,
+// debugger, window drag) doesn't lead to a spiral of ever longer frames.
+func (f *fixedTimestep) advance(deltaTime float64) int {
+	f.accumulator += max(0, deltaTime)
+	steps := int(f.accumulator / f.step)
+	if steps > f.maxCatchUp {
+		steps = f.maxCatchUp
+		f.accumulator = math.Mod(f.accumulator, f.step)
+	} else {
+		f.accumulator -= float64(steps) * f.step
+	}
+	f.alpha = f.accumulator / f.step
+	return steps
+}
+
+// EnableFixedTimestep switches the FixedUpdater and physics over to a fixed
+// simulation step. Each frame, the frame time is accumulated and the
+// FixedUpdater followed by physics are run once for every whole step owed, up
+// to maxCatchUpSteps times. A step or max catch-up steps of 0 or less will use
+// [DefaultFixedStep] and [DefaultMaxCatchUpSteps] respectively.
+//
+// The physics step is set to the same step so that the two tick together,
+// until [Host.DisableFixedTimestep] puts the previous physics step back.
+// Rendering should use [Host.InterpolationAlpha] to smooth transforms between
+// the last two ticks.
+func (host *Host) EnableFixedTimestep(step float64, maxCatchUpSteps int) {
+	if step <= 0 {
+		step = DefaultFixedStep
+	}
+	if maxCatchUpSteps <= 0 {
+		maxCatchUpSteps = DefaultMaxCatchUpSteps
+	}
+	physicsStep := host.fixedTimestep.physicsStep
+	if !host.fixedTimestep.enabled {
+		physicsStep = host.physics.FixedTimeStep()
+	}
+	host.fixedTimestep = fixedTimestep{
+		enabled:     true,
+		step:        step,
+		maxCatchUp:  maxCatchUpSteps,
+		tick:        host.fixedTimestep.tick,
+		physicsStep: physicsStep,
+	}
+	host.physics.SetFixedTimeStep(step)
+}
+
+// DisableFixedTimestep goes back to running the FixedUpdater and physics once
+// per frame with the variable frame time, physics goes back to the step it
+// had before the fixed timestep was enabled
+func (host *Host) DisableFixedTimestep() {
+	if host.fixedTimestep.enabled {
+		host.physics.SetFixedTimeStep(host.fixedTimestep.physicsStep)
+	}
+	host.fixedTimestep.enabled = false
+	host.fixedTimestep.accumulator = 0
+	host.fixedTimestep.alpha = 0
+}
+
+// IsFixedTimestep returns true if [Host.EnableFixedTimestep] is in use
+func (host *Host) IsFixedTimestep() bool { return host.fixedTimestep.enabled }
+
+// FixedStep returns the length of a fixed step in seconds, or 0 if the fixed
+// timestep is not enabled
+func (host *Host) FixedStep() float64 {
+	if !host.fixedTimestep.enabled {
+		return 0
+	}
+	return host.fixedTimestep.step
+}
+
+// FixedTick returns the number of fixed steps that have been run. This is
+// only counted while the fixed timestep is enabled, it makes for a
+// deterministic clock for gameplay, networking and replays.
+func (host *Host) FixedTick() uint64 { return host.fixedTimestep.tick }
+
+// InterpolationAlpha returns how far, from 0 to 1, the current frame is
+// between the last fixed step and the next one. Rendering can use it to
+// blend between the previous and current simulated transforms. This is always
+// 0 when the fixed timestep is not enabled.
+func (host *Host) InterpolationAlpha() float64 { return host.fixedTimestep.alpha }
+
+func (host *Host) fixedUpdate(deltaTime float64) {
+	defer tracing.NewRegion("Host.fixedUpdate").End()
+	if !host.fixedTimestep.enabled {
+		host.FixedUpdater.Update(deltaTime)
+		host.updatePhysics(deltaTime)
+		return
+	}
+	step := host.fixedTimestep.step
+	for range host.fixedTimestep.advance(deltaTime) {
+		host.FixedUpdater.Update(step)
+		host.updatePhysics(step)
+		host.fixedTimestep.tick++
+	}
+}
+
+func (host *Host) updatePhysics(deltaTime float64) {
+	if !build.Editor {
+		if host.physics.IsActive() {
+			host.physics.Update(host.WorkGroup(), &host.threads, deltaTime)
+		}
+	}
+}
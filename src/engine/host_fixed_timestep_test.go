@@ -0,0 +1,122 @@
+/******************************************************************************/
+/* host_fixed_timestep_test.go                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func newFixedTestHost() *Host {
+	return &Host{FixedUpdater: NewOrderedUpdater()}
+}
+
+func TestFixedTimestepAccumulatesPartialSteps(t *testing.T) {
+	f := fixedTimestep{enabled: true, step: 0.1, maxCatchUp: 5}
+	if steps := f.advance(0.05); steps != 0 {
+		t.Fatalf("steps = %d, want 0", steps)
+	}
+	if math.Abs(f.alpha-0.5) > 1e-9 {
+		t.Errorf("alpha = %f, want 0.5", f.alpha)
+	}
+	if steps := f.advance(0.17); steps != 2 {
+		t.Fatalf("steps = %d, want 2", steps)
+	}
+	if math.Abs(f.alpha-0.2) > 1e-9 {
+		t.Errorf("alpha = %f, want 0.2", f.alpha)
+	}
+}
+
+func TestFixedTimestepCapsCatchUp(t *testing.T) {
+	f := fixedTimestep{enabled: true, step: 0.1, maxCatchUp: 3}
+	if steps := f.advance(1.05); steps != 3 {
+		t.Fatalf("steps = %d, want 3", steps)
+	}
+	if math.Abs(f.accumulator-0.05) > 1e-9 {
+		t.Errorf("accumulator = %f, want the extra steps dropped", f.accumulator)
+	}
+	if steps := f.advance(0.06); steps != 1 {
+		t.Errorf("steps = %d, want 1 after the stall", steps)
+	}
+}
+
+func TestFixedUpdateRunsOncePerStep(t *testing.T) {
+	host := newFixedTestHost()
+	host.EnableFixedTimestep(0.25, 4)
+	var deltas []float64
+	host.FixedUpdater.AddUpdate(func(dt float64) { deltas = append(deltas, dt) })
+	host.fixedUpdate(0.6)
+	if !slices.Equal(deltas, []float64{0.25, 0.25}) {
+		t.Fatalf("deltas = %v, want two fixed steps", deltas)
+	}
+	if host.FixedTick() != 2 {
+		t.Errorf("FixedTick = %d, want 2", host.FixedTick())
+	}
+	if math.Abs(host.InterpolationAlpha()-0.4) > 1e-9 {
+		t.Errorf("InterpolationAlpha = %f, want 0.4", host.InterpolationAlpha())
+	}
+}
+
+func TestFixedUpdateVariableWhenDisabled(t *testing.T) {
+	host := newFixedTestHost()
+	var deltas []float64
+	host.FixedUpdater.AddUpdate(func(dt float64) { deltas = append(deltas, dt) })
+	host.fixedUpdate(0.033)
+	if !slices.Equal(deltas, []float64{0.033}) {
+		t.Fatalf("deltas = %v, want the frame time", deltas)
+	}
+	if host.FixedTick() != 0 || host.InterpolationAlpha() != 0 {
+		t.Error("the fixed clock should not advance while disabled")
+	}
+}
+
+func TestEnableFixedTimestepDefaults(t *testing.T) {
+	host := newFixedTestHost()
+	host.EnableFixedTimestep(0, 0)
+	if host.FixedStep() != DefaultFixedStep {
+		t.Errorf("FixedStep = %f, want %f", host.FixedStep(), DefaultFixedStep)
+	}
+	if host.fixedTimestep.maxCatchUp != DefaultMaxCatchUpSteps {
+		t.Errorf("maxCatchUp = %d, want %d", host.fixedTimestep.maxCatchUp, DefaultMaxCatchUpSteps)
+	}
+	if host.Physics().FixedTimeStep() != DefaultFixedStep {
+		t.Error("the physics step should match the fixed step")
+	}
+	host.DisableFixedTimestep()
+	if host.IsFixedTimestep() || host.FixedStep() != 0 {
+		t.Error("expected the fixed timestep to be disabled")
+	}
+}
+
+func TestDisableFixedTimestepRestoresPhysicsStep(t *testing.T) {
+	host := newFixedTestHost()
+	before := host.Physics().FixedTimeStep()
+	host.EnableFixedTimestep(0.01, 0)
+	host.EnableFixedTimestep(0.02, 0)
+	if host.Physics().FixedTimeStep() != 0.02 {
+		t.Fatalf("physics step = %f, want 0.02", host.Physics().FixedTimeStep())
+	}
+	host.DisableFixedTimestep()
+	if host.Physics().FixedTimeStep() != before {
+		t.Errorf("physics step = %f, want the %f from before it was enabled", host.Physics().FixedTimeStep(), before)
+	}
+}
+
+func TestOrderedUpdaterKeepsRegistrationOrder(t *testing.T) {
+	u := NewOrderedUpdater()
+	var order []int
+	ids := make([]UpdateId, 0, 8)
+	for i := range 8 {
+		ids = append(ids, u.AddUpdate(func(float64) { order = append(order, i) }))
+	}
+	u.RemoveUpdate(&ids[3])
+	u.Update(0)
+	if !slices.Equal(order, []int{0, 1, 2, 4, 5, 6, 7}) {
+		t.Errorf("order = %v, want registration order", order)
+	}
+}
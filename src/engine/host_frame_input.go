@@ -0,0 +1,28 @@
+/******************************************************************************/
+/* host_frame_input.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine
+
+// FrameInput is given the input and the delta time of every frame before any
+// of the updaters see them. It is how input is recorded and replayed (see the
+// replay package), an implementation can read the state of the window devices
+// or replace it entirely.
+type FrameInput interface {
+	// FrameDelta is called at the very start of [Host.Update] with the frame
+	// that is about to run, it returns the delta time to run the frame with
+	FrameDelta(frame FrameId, deltaTime float64) float64
+	// ProcessInput is called once the window has been polled and the frame
+	// callbacks have ran, so it sees the OS input along with any input that
+	// was injected through [Host.RunNextFrame] and friends
+	ProcessInput(host *Host)
+}
+
+// SetFrameInput sets the input processor that is run each frame, only one can
+// be set at a time, nil removes the current one
+func (host *Host) SetFrameInput(input FrameInput) { host.frameInput = input }
+
+// FrameInput returns the input processor that is run each frame, or nil
+func (host *Host) FrameInput() FrameInput { return host.frameInput }
@@ -27,6 +27,8 @@ type LaunchParameters struct {
 	RecordPGO       bool
 	AutoTest        bool
 	RenderThread    bool
+	RecordInput     string
+	ReplayInput     string
 }
 
 func LoadLaunchParams() {
@@ -41,6 +43,8 @@ func LoadLaunchParams() {
 		flag.StringVar(&LaunchParams.StartStage, "startStage", "", "Used to force the build to start on a specific stage")
 		flag.BoolVar(&LaunchParams.AutoTest, "autotest", false, "If supplied, runs automated integration tests and exits")
 	}
+	flag.StringVar(&LaunchParams.RecordInput, "record", "", "Record the input of the session into the specified replay file")
+	flag.StringVar(&LaunchParams.ReplayInput, "replay", "", "Drive the session with the input from the specified replay file")
 	flag.BoolVar(&LaunchParams.RecordPGO, "record_pgo", false, "If supplied, a default.pgo will be captured for this run")
 	flag.BoolVar(&LaunchParams.RenderThread, "renderthread", runtime.GOOS == "windows", "Run GPU rendering on a dedicated render thread when supported")
 	flag.Parse()
@@ -0,0 +1,185 @@
+/******************************************************************************/
+/* loading.go                                                                 */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+// Package loading loads content in the background so that the game keeps
+// running, and a loading screen keeps drawing, while the content is read.
+//
+// Reading and decoding is done on the loader's worker goroutines. Work that
+// has to happen on the main thread, such as compiling materials and creating
+// the entities of a stage, is run through [engine.Host.RunOnMainThread], and
+// GPU uploads are then handed to [engine.Host.RunOnRenderThread]. Every load
+// returns a [Handle] that can be waited on, polled, or added to a [Batch] to
+// report the progress of a group of loads.
+package loading
+
+import (
+	"log/slog"
+	"runtime"
+	"sync"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering"
+	"kaijuengine.com/rendering/loaders/kaiju_mesh"
+)
+
+// Loader schedules content loads for a host
+type Loader struct {
+	host      *engine.Host
+	work      chan func()
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	runOnMain func(func())
+	runOnGPU  func(call func(*rendering.GPUDevice), done func())
+}
+
+// NewLoader creates a loader with the given number of worker goroutines, if
+// workers is 0 or less, half of the CPUs are used
+func NewLoader(host *engine.Host, workers int) *Loader {
+	if workers <= 0 {
+		workers = max(1, runtime.NumCPU()/2)
+	}
+	l := &Loader{
+		host:      host,
+		work:      make(chan func(), 256),
+		stop:      make(chan struct{}),
+		runOnMain: host.RunOnMainThread,
+	}
+	l.runOnGPU = l.uploadOnRenderThread
+	for range workers {
+		l.wg.Add(1)
+		go l.worker()
+	}
+	return l
+}
+
+// Close stops the worker goroutines once the loads that are already running
+// have finished. Loads that have not started are never resolved.
+func (l *Loader) Close() {
+	l.closeOnce.Do(func() {
+		close(l.stop)
+		l.wg.Wait()
+	})
+}
+
+func (l *Loader) worker() {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case work := <-l.work:
+			work()
+		}
+	}
+}
+
+// schedule queues the work to run on a worker goroutine. The channel is
+// buffered, but if it fills up the work is started on its own goroutine so
+// that scheduling never blocks the caller.
+func (l *Loader) schedule(work func()) {
+	select {
+	case l.work <- work:
+	default:
+		go work()
+	}
+}
+
+// uploadOnRenderThread runs the GPU call from the main thread, as the render
+// thread call runs in place when the host has no render thread. Hosts without
+// a GPU, such as servers, skip the call.
+func (l *Loader) uploadOnRenderThread(call func(*rendering.GPUDevice), done func()) {
+	l.runOnMain(func() {
+		w := l.host.Window
+		if l.host.RenderThreadActive() || (w != nil && w.GpuInstance != nil && w.GpuInstance.IsValid()) {
+			l.host.RunOnRenderThread(call)
+		}
+		done()
+	})
+}
+
+// Then calls the function on the main thread once the handle has finished
+func Then[T any](l *Loader, h *Handle[T], call func(T, error)) {
+	h.Then(func(v T, err error) {
+		l.runOnMain(func() { call(v, err) })
+	})
+}
+
+// Read reads the raw data of the asset
+func (l *Loader) Read(key string) *Handle[[]byte] {
+	h := newHandle[[]byte](key)
+	l.schedule(func() {
+		defer tracing.NewRegion("loading.Read: " + key).End()
+		h.resolve(l.host.AssetDatabase().Read(key))
+	})
+	return h
+}
+
+// Texture reads and decodes the texture into the host's texture cache on a
+// worker, then uploads it on the render thread. The handle is done once the
+// texture has been uploaded.
+func (l *Loader) Texture(key string, filter rendering.TextureFilter) *Handle[*rendering.Texture] {
+	h := newHandle[*rendering.Texture](key)
+	l.schedule(func() {
+		defer tracing.NewRegion("loading.Texture: " + key).End()
+		tex, err := l.host.TextureCache().Texture(key, filter)
+		if err != nil {
+			h.resolve(nil, err)
+			return
+		}
+		l.runOnGPU(func(device *rendering.GPUDevice) {
+			tex.DelayedCreate(device)
+		}, func() { h.resolve(tex, nil) })
+	})
+	return h
+}
+
+// Mesh reads the mesh into the host's mesh cache on a worker, then creates it
+// on the render thread. The reference can be a built in mesh or a mesh asset
+// reference (see [kaiju_mesh.ParseMeshRef]).
+func (l *Loader) Mesh(ref string) *Handle[*rendering.Mesh] {
+	h := newHandle[*rendering.Mesh](ref)
+	l.schedule(func() {
+		defer tracing.NewRegion("loading.Mesh: " + ref).End()
+		cache := l.host.MeshCache()
+		mesh, ok := cache.FindMesh(ref)
+		if !ok {
+			var km kaiju_mesh.KaijuMesh
+			var builtIn bool
+			if kaiju_mesh.ParseMeshRef(ref).Key == "" {
+				km.Verts, km.Indexes, builtIn = rendering.BuiltInMeshData(ref)
+			}
+			if !builtIn {
+				var err error
+				if km, err = kaiju_mesh.ReadMesh(ref, l.host); err != nil {
+					h.resolve(nil, err)
+					return
+				}
+			}
+			mesh = cache.Mesh(ref, km.Verts, km.Indexes)
+		}
+		l.runOnGPU(func(*rendering.GPUDevice) {
+			cache.ProcessPending()
+		}, func() { h.resolve(mesh, nil) })
+	})
+	return h
+}
+
+// Material loads the material on the main thread, as compiling the shaders of
+// the material needs to happen there
+func (l *Loader) Material(key string) *Handle[*rendering.Material] {
+	h := newHandle[*rendering.Material](key)
+	l.runOnMain(func() {
+		defer tracing.NewRegion("loading.Material: " + key).End()
+		mat, err := l.host.MaterialCache().Material(key)
+		if err != nil {
+			slog.Error("failed to load the material", "material", key, "error", err)
+		}
+		h.resolve(mat, err)
+	})
+	return h
+}
@@ -0,0 +1,184 @@
+/******************************************************************************/
+/* loading_handle.go                                                          */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package loading
+
+import (
+	"errors"
+	"sync"
+)
+
+// Tracked is anything that finishes at some point and can be added to a
+// [Batch] to report its progress
+type Tracked interface {
+	Key() string
+	Done() <-chan struct{}
+	Err() error
+	onDone(call func())
+}
+
+// Handle is the future for a value that is being loaded in the background.
+// The value and error are only valid once the handle is done.
+type Handle[T any] struct {
+	key       string
+	done      chan struct{}
+	value     T
+	err       error
+	listeners []func()
+	mutex     sync.Mutex
+}
+
+func newHandle[T any](key string) *Handle[T] {
+	return &Handle[T]{key: key, done: make(chan struct{})}
+}
+
+// Key returns the asset key that the handle is loading
+func (h *Handle[T]) Key() string { return h.key }
+
+// Done returns a channel that is closed once the handle has finished loading
+func (h *Handle[T]) Done() <-chan struct{} { return h.done }
+
+// IsDone returns true if the handle has finished loading, successfully or not
+func (h *Handle[T]) IsDone() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait blocks until the handle has finished loading and returns its result.
+// This should not be called on the main thread while the load still needs the
+// main thread to finish, use [Handle.Then] instead.
+func (h *Handle[T]) Wait() (T, error) {
+	<-h.done
+	return h.value, h.err
+}
+
+// Result returns the result of the handle without blocking, ok is false if
+// the handle has not finished loading yet
+func (h *Handle[T]) Result() (value T, err error, ok bool) {
+	if !h.IsDone() {
+		return value, nil, false
+	}
+	return h.value, h.err, true
+}
+
+// Err returns the error of the load, it is nil until the handle is done
+func (h *Handle[T]) Err() error {
+	if !h.IsDone() {
+		return nil
+	}
+	return h.err
+}
+
+// Then calls the function with the result of the handle once it has finished
+// loading. The function is called on the thread that finished the load, use
+// [Loader.Then] to have it called on the main thread.
+func (h *Handle[T]) Then(call func(T, error)) {
+	h.onDone(func() { call(h.value, h.err) })
+}
+
+func (h *Handle[T]) onDone(call func()) {
+	h.mutex.Lock()
+	if !h.IsDone() {
+		h.listeners = append(h.listeners, call)
+		h.mutex.Unlock()
+		return
+	}
+	h.mutex.Unlock()
+	call()
+}
+
+func (h *Handle[T]) resolve(value T, err error) {
+	h.mutex.Lock()
+	if h.IsDone() {
+		h.mutex.Unlock()
+		return
+	}
+	h.value, h.err = value, err
+	close(h.done)
+	listeners := h.listeners
+	h.listeners = nil
+	h.mutex.Unlock()
+	for _, call := range listeners {
+		call()
+	}
+}
+
+// Batch reports the combined progress of a group of loads, such as all of the
+// dependencies of a stage. More loads can be added to a batch while it is
+// running, so the progress can go down as well as up.
+type Batch struct {
+	total     int
+	completed int
+	errs      []error
+	listeners []func(*Batch)
+	mutex     sync.Mutex
+}
+
+// NewBatch creates an empty batch
+func NewBatch() *Batch { return &Batch{} }
+
+// Track adds the load to the batch
+func (b *Batch) Track(t Tracked) {
+	b.mutex.Lock()
+	b.total++
+	b.mutex.Unlock()
+	t.onDone(func() {
+		b.mutex.Lock()
+		b.completed++
+		if err := t.Err(); err != nil {
+			b.errs = append(b.errs, err)
+		}
+		listeners := append([]func(*Batch){}, b.listeners...)
+		b.mutex.Unlock()
+		for _, call := range listeners {
+			call(b)
+		}
+	})
+}
+
+// OnProgress calls the function each time a load in the batch finishes, the
+// function is called on the thread that finished the load
+func (b *Batch) OnProgress(call func(*Batch)) {
+	b.mutex.Lock()
+	b.listeners = append(b.listeners, call)
+	b.mutex.Unlock()
+}
+
+// Progress returns the fraction of the loads in the batch that have finished,
+// from 0 to 1. An empty batch has a progress of 1.
+func (b *Batch) Progress() float32 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.total == 0 {
+		return 1
+	}
+	return float32(b.completed) / float32(b.total)
+}
+
+// Counts returns the number of finished loads and the total number of loads
+func (b *Batch) Counts() (completed, total int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.completed, b.total
+}
+
+// IsDone returns true if every load in the batch has finished
+func (b *Batch) IsDone() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.completed == b.total
+}
+
+// Err returns the errors of all of the loads that failed joined together
+func (b *Batch) Err() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return errors.Join(b.errs...)
+}
@@ -0,0 +1,106 @@
+/******************************************************************************/
+/* loading_stage.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package loading
+
+import (
+	"log/slog"
+	"sync"
+
+	"kaijuengine.com/engine/stages"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering"
+)
+
+// StageLoad is the progress of loading a stage and all of its dependencies
+type StageLoad struct {
+	*Handle[stages.LoadResult]
+	// Batch holds the reading of the stage, the loads of each of its meshes,
+	// materials and textures, and the creation of its entities
+	Batch *Batch
+	read  *Handle[stages.Stage]
+}
+
+// Stage returns the stage that was read, ok is false until the stage has been
+// read successfully
+func (s *StageLoad) Stage() (stage stages.Stage, ok bool) {
+	stage, err, done := s.read.Result()
+	return stage, done && err == nil
+}
+
+// Stage reads the stage with the key, loads all of its dependencies in the
+// background, and then creates the stage's entities on the main thread. The
+// batch of the returned load can be used to drive a loading screen, and the
+// handle is done once the entities have been created.
+//
+// A dependency that fails to load is reported in the batch's errors, the
+// stage is still created, the same as when it is loaded directly.
+func (l *Loader) Stage(key string) *StageLoad {
+	sl := &StageLoad{
+		Handle: newHandle[stages.LoadResult](key),
+		Batch:  NewBatch(),
+	}
+	read := newHandle[stages.Stage](key)
+	sl.read = read
+	sl.Batch.Track(read)
+	created := newHandle[struct{}](key)
+	sl.Batch.Track(created)
+	l.schedule(func() {
+		defer tracing.NewRegion("loading.Stage: " + key).End()
+		data, err := l.host.AssetDatabase().Read(key)
+		var stage stages.Stage
+		if err == nil {
+			stage, err = stages.Deserialize(data)
+		}
+		if err != nil {
+			slog.Error("failed to read the stage", "stage", key, "error", err)
+			read.resolve(stage, err)
+			created.resolve(struct{}{}, nil)
+			sl.resolve(stages.LoadResult{}, err)
+			return
+		}
+		deps := stage.Dependencies()
+		pending := NewBatch()
+		track := func(t Tracked) {
+			sl.Batch.Track(t)
+			pending.Track(t)
+		}
+		for i := range deps.Meshes {
+			track(l.Mesh(deps.Meshes[i]))
+		}
+		for i := range deps.Materials {
+			track(l.Material(deps.Materials[i]))
+		}
+		for i := range deps.Textures {
+			// TODO:  Should be reading the filter from the configuration file,
+			// matching stages.SetupEntityFromDescription
+			track(l.Texture(deps.Textures[i], rendering.TextureFilterLinear))
+		}
+		var createOnce sync.Once
+		create := func() {
+			createOnce.Do(func() {
+				l.runOnMain(func() {
+					defer tracing.NewRegion("loading.Stage.Create: " + key).End()
+					res := stage.Load(l.host)
+					created.resolve(struct{}{}, nil)
+					sl.resolve(res, nil)
+				})
+			})
+		}
+		pending.OnProgress(func(b *Batch) {
+			if b.IsDone() {
+				create()
+			}
+		})
+		// The stage read is resolved after the dependencies are tracked so the
+		// batch can't be seen as done before they have been added to it
+		read.resolve(stage, nil)
+		if pending.IsDone() {
+			create()
+		}
+	})
+	return sl
+}
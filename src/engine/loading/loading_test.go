@@ -0,0 +1,174 @@
+/******************************************************************************/
+/* loading_test.go                                                            */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package loading
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"kaijuengine.com/build"
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/assets"
+	"kaijuengine.com/engine/encoding/pod"
+	"kaijuengine.com/engine/stages"
+	"kaijuengine.com/rendering"
+)
+
+// mainQueue stands in for the host's frame callbacks, which need a window to
+// run, so that the tests can pump the main thread themselves
+type mainQueue struct {
+	calls []func()
+	mutex sync.Mutex
+}
+
+func (q *mainQueue) add(call func()) {
+	q.mutex.Lock()
+	q.calls = append(q.calls, call)
+	q.mutex.Unlock()
+}
+
+func (q *mainQueue) pump() {
+	q.mutex.Lock()
+	calls := q.calls
+	q.calls = nil
+	q.mutex.Unlock()
+	for _, call := range calls {
+		call()
+	}
+}
+
+func newTestLoader(t *testing.T, files map[string][]byte) (*Loader, *mainQueue) {
+	t.Helper()
+	host := engine.NewHost("test", nil, assets.NewMockDB(files))
+	l := NewLoader(host, 2)
+	t.Cleanup(l.Close)
+	q := &mainQueue{}
+	l.runOnMain = q.add
+	l.runOnGPU = func(call func(*rendering.GPUDevice), done func()) { q.add(done) }
+	return l, q
+}
+
+func waitFor(t *testing.T, q *mainQueue, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the load")
+		}
+		q.pump()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func encodeStage(t *testing.T, s stages.Stage) []byte {
+	t.Helper()
+	if build.Debug {
+		data, err := json.Marshal(s.ToMinimized())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	buf := bytes.Buffer{}
+	if err := pod.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHandleResolvesOnce(t *testing.T) {
+	h := newHandle[int]("key")
+	if _, _, ok := h.Result(); ok || h.IsDone() {
+		t.Fatal("a new handle should not be done")
+	}
+	calls := 0
+	h.Then(func(v int, err error) { calls += v })
+	h.resolve(3, nil)
+	h.resolve(5, errors.New("ignored"))
+	if v, err := h.Wait(); v != 3 || err != nil {
+		t.Errorf("Wait = %d, %v", v, err)
+	}
+	h.Then(func(v int, err error) { calls += v })
+	if calls != 6 {
+		t.Errorf("expected both callbacks to see the first result, got %d", calls)
+	}
+}
+
+func TestBatchProgress(t *testing.T) {
+	b := NewBatch()
+	if b.Progress() != 1 || !b.IsDone() {
+		t.Error("an empty batch should be done")
+	}
+	handles := []*Handle[int]{newHandle[int]("a"), newHandle[int]("b"), newHandle[int]("c"), newHandle[int]("d")}
+	for _, h := range handles {
+		b.Track(h)
+	}
+	reported := []float32{}
+	b.OnProgress(func(b *Batch) { reported = append(reported, b.Progress()) })
+	handles[0].resolve(1, nil)
+	handles[1].resolve(0, errors.New("missing"))
+	if b.Progress() != 0.5 || b.IsDone() {
+		t.Errorf("progress = %f, want 0.5", b.Progress())
+	}
+	handles[2].resolve(1, nil)
+	handles[3].resolve(1, nil)
+	if !b.IsDone() || b.Err() == nil {
+		t.Error("expected the batch to be done with the failed load reported")
+	}
+	if len(reported) != 4 || reported[3] != 1 {
+		t.Errorf("reported progress %v", reported)
+	}
+}
+
+func TestLoaderRead(t *testing.T) {
+	l, q := newTestLoader(t, map[string][]byte{"a.txt": []byte("hello")})
+	h := l.Read("a.txt")
+	missing := l.Read("missing.txt")
+	var onMain string
+	Then(l, h, func(data []byte, err error) { onMain = string(data) })
+	waitFor(t, q, func() bool { return onMain != "" && missing.IsDone() })
+	if onMain != "hello" {
+		t.Errorf("read %q", onMain)
+	}
+	if _, err := missing.Wait(); err == nil {
+		t.Error("expected an error for a missing asset")
+	}
+}
+
+func TestLoaderStage(t *testing.T) {
+	stage := stages.Stage{Id: "level", Entities: []stages.EntityDescription{
+		{Id: "root", Name: "Root", Children: []stages.EntityDescription{{Id: "child", Name: "Child"}}},
+	}}
+	l, q := newTestLoader(t, map[string][]byte{"level": encodeStage(t, stage)})
+	sl := l.Stage("level")
+	if sl.IsDone() {
+		t.Fatal("the stage can't be created before the main thread runs")
+	}
+	waitFor(t, q, sl.IsDone)
+	res, err := sl.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entities) != 2 || res.EntitiesById["child"] == nil {
+		t.Errorf("unexpected load result %+v", res)
+	}
+	if !sl.Batch.IsDone() || sl.Batch.Progress() != 1 {
+		t.Errorf("batch progress = %f", sl.Batch.Progress())
+	}
+	if s, ok := sl.Stage(); !ok || s.Id != "level" {
+		t.Error("expected the read stage to be available")
+	}
+	failed := l.Stage("missing")
+	waitFor(t, q, failed.IsDone)
+	if _, err := failed.Wait(); err == nil || !failed.Batch.IsDone() {
+		t.Error("expected a missing stage to fail and finish its batch")
+	}
+}
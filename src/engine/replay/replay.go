@@ -0,0 +1,263 @@
+/******************************************************************************/
+/* replay.go                                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+// Package replay records the input of a [engine.Host] into a file and later
+// drives a host from that file in place of the window, so that a session can
+// be reproduced frame for frame.
+//
+// The state of the keyboard, mouse, touch and each controller is captured
+// every frame right before the updaters run (see [engine.FrameInput]), so
+// input that is injected by the AI driver or by frame callbacks is recorded
+// along with the OS input. Only the devices that changed are written for a
+// frame, along with the frame's delta time so that the timing of the replay
+// matches the recording.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"kaijuengine.com/platform/hid"
+	"kaijuengine.com/platform/windowing"
+)
+
+// Version is the version of the replay file format that is written
+const Version = 1
+
+var replayMagic = []byte{'K', 'R', 'P', 'L'}
+
+const (
+	changeKeyboard = 1 << iota
+	changeMouse
+	changeTouch
+	changeControllerFirst
+)
+
+// Header is written once at the start of a replay file
+type Header struct {
+	Version      uint16
+	WindowWidth  int32
+	WindowHeight int32
+}
+
+type frameHeader struct {
+	Frame     uint64
+	DeltaTime float64
+	Changes   uint16
+}
+
+// Frame is the input of a single recorded frame. Only the devices that changed
+// during the frame are set, the rest are nil.
+type Frame struct {
+	DeltaTime   float64
+	Keyboard    *hid.KeyboardSnapshot
+	Mouse       *hid.MouseSnapshot
+	Touch       *hid.TouchSnapshot
+	Controllers [hid.ControllerMaxDevices]*hid.ControllerDeviceSnapshot
+}
+
+// Replay is a recording that has been read back into memory
+type Replay struct {
+	Header
+	Frames []Frame
+}
+
+// inputState is the state of all of the recorded devices at the end of a frame
+type inputState struct {
+	keyboard   hid.KeyboardSnapshot
+	mouse      hid.MouseSnapshot
+	touch      hid.TouchSnapshot
+	controller hid.ControllerSnapshot
+}
+
+func captureState(w *windowing.Window) inputState {
+	return inputState{
+		keyboard:   w.Keyboard.Snapshot(),
+		mouse:      w.Mouse.Snapshot(),
+		touch:      w.Touch.Snapshot(),
+		controller: w.Controller.Snapshot(),
+	}
+}
+
+func (s *inputState) restore(w *windowing.Window) {
+	w.Keyboard.Restore(s.keyboard)
+	w.Mouse.Restore(s.mouse)
+	w.Touch.Restore(s.touch)
+	w.Controller.Restore(s.controller)
+}
+
+// diff returns the frame that takes this state to the next one
+func (s *inputState) diff(next *inputState, deltaTime float64) Frame {
+	f := Frame{DeltaTime: deltaTime}
+	if s.keyboard != next.keyboard {
+		f.Keyboard = &next.keyboard
+	}
+	if s.mouse != next.mouse {
+		f.Mouse = &next.mouse
+	}
+	if s.touch != next.touch {
+		f.Touch = &next.touch
+	}
+	for i := range next.controller.Devices {
+		if s.controller.Devices[i] != next.controller.Devices[i] {
+			f.Controllers[i] = &next.controller.Devices[i]
+		}
+	}
+	return f
+}
+
+func (s *inputState) apply(f *Frame) {
+	if f.Keyboard != nil {
+		s.keyboard = *f.Keyboard
+	}
+	if f.Mouse != nil {
+		s.mouse = *f.Mouse
+	}
+	if f.Touch != nil {
+		s.touch = *f.Touch
+	}
+	for i, c := range f.Controllers {
+		if c != nil {
+			s.controller.Devices[i] = *c
+		}
+	}
+}
+
+func (f *Frame) changes() uint16 {
+	changes := uint16(0)
+	if f.Keyboard != nil {
+		changes |= changeKeyboard
+	}
+	if f.Mouse != nil {
+		changes |= changeMouse
+	}
+	if f.Touch != nil {
+		changes |= changeTouch
+	}
+	for i, c := range f.Controllers {
+		if c != nil {
+			changes |= changeControllerFirst << i
+		}
+	}
+	return changes
+}
+
+func writeHeader(w io.Writer, header Header) error {
+	if _, err := w.Write(replayMagic); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, header)
+}
+
+func writeFrame(w io.Writer, frame uint64, f *Frame) error {
+	fh := frameHeader{Frame: frame, DeltaTime: f.DeltaTime, Changes: f.changes()}
+	if err := binary.Write(w, binary.LittleEndian, fh); err != nil {
+		return err
+	}
+	snapshots := []any{f.Keyboard, f.Mouse, f.Touch}
+	for _, c := range f.Controllers {
+		snapshots = append(snapshots, c)
+	}
+	for i, s := range snapshots {
+		if fh.Changes&(1<<i) == 0 {
+			continue
+		}
+		if err := binary.Write(w, binary.LittleEndian, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads a whole replay from the reader
+func Read(r io.Reader) (*Replay, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(replayMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, replayMagic) {
+		return nil, errors.New("the data is not a replay file")
+	}
+	rp := &Replay{}
+	if err := binary.Read(br, binary.LittleEndian, &rp.Header); err != nil {
+		return nil, fmt.Errorf("failed to read the replay header: %w", err)
+	}
+	if rp.Version != Version {
+		return nil, fmt.Errorf("unsupported replay version %d, expected %d", rp.Version, Version)
+	}
+	for {
+		fh := frameHeader{}
+		err := binary.Read(br, binary.LittleEndian, &fh)
+		f := Frame{DeltaTime: fh.DeltaTime}
+		if err == nil {
+			if fh.Frame != uint64(len(rp.Frames)) {
+				return nil, fmt.Errorf("replay frame %d is out of order, expected %d", fh.Frame, len(rp.Frames))
+			}
+			err = readFrame(br, fh.Changes, &f)
+		}
+		switch {
+		case err == nil:
+			rp.Frames = append(rp.Frames, f)
+		case errors.Is(err, io.EOF):
+			return rp, nil
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			// A recording that was cut short by a crash ends part way through
+			// a frame, everything up to that frame is still good
+			slog.Warn("the replay ends part way through a frame, the frame was dropped", "frame", len(rp.Frames))
+			return rp, nil
+		default:
+			return nil, fmt.Errorf("failed to read replay frame %d: %w", len(rp.Frames), err)
+		}
+	}
+}
+
+func readFrame(r io.Reader, changes uint16, f *Frame) error {
+	read := func(bit uint16, out any) (bool, error) {
+		if changes&bit == 0 {
+			return false, nil
+		}
+		return true, binary.Read(r, binary.LittleEndian, out)
+	}
+	kb, mouse, touch := hid.KeyboardSnapshot{}, hid.MouseSnapshot{}, hid.TouchSnapshot{}
+	if ok, err := read(changeKeyboard, &kb); err != nil {
+		return err
+	} else if ok {
+		f.Keyboard = &kb
+	}
+	if ok, err := read(changeMouse, &mouse); err != nil {
+		return err
+	} else if ok {
+		f.Mouse = &mouse
+	}
+	if ok, err := read(changeTouch, &touch); err != nil {
+		return err
+	} else if ok {
+		f.Touch = &touch
+	}
+	for i := range f.Controllers {
+		c := hid.ControllerDeviceSnapshot{}
+		if ok, err := read(changeControllerFirst<<i, &c); err != nil {
+			return err
+		} else if ok {
+			f.Controllers[i] = &c
+		}
+	}
+	return nil
+}
+
+// Load reads the replay file at the path
+func Load(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
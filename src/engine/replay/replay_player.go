@@ -0,0 +1,109 @@
+/******************************************************************************/
+/* replay_player.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replay
+
+import (
+	"log/slog"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/events"
+)
+
+// Player drives a host with the input of a replay in place of the window. Each
+// frame the recorded delta time is used and the devices of the window are
+// overwritten with the recorded state. Once the last frame has been played the
+// player removes itself from the host and the window has control again.
+type Player struct {
+	// OnFinished is executed once the last frame of the replay has been played
+	OnFinished events.Event
+	replay     *Replay
+	state      inputState
+	next       int
+	finished   bool
+}
+
+// NewPlayer creates a player for the replay
+func NewPlayer(replay *Replay) *Player {
+	return &Player{replay: replay}
+}
+
+// Play starts driving the host with the replay from the next frame
+func Play(host *engine.Host, replay *Replay) *Player {
+	p := NewPlayer(replay)
+	if w, h := host.Window.Width(), host.Window.Height(); int32(w) != replay.WindowWidth || int32(h) != replay.WindowHeight {
+		slog.Warn("the window size differs from the recording, the replay may not match",
+			"window", [2]int{w, h}, "recorded", [2]int32{replay.WindowWidth, replay.WindowHeight})
+	}
+	host.SetFrameInput(p)
+	return p
+}
+
+// PlayFile loads the replay file at the path and starts driving the host with it
+func PlayFile(host *engine.Host, path string) (*Player, error) {
+	replay, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("playing input replay", "file", path, "frames", len(replay.Frames))
+	return Play(host, replay), nil
+}
+
+// Frame returns the index of the next frame to be played
+func (p *Player) Frame() int { return p.next }
+
+// Len returns the number of frames in the replay
+func (p *Player) Len() int { return len(p.replay.Frames) }
+
+// IsFinished returns true once every frame has been played or it was stopped
+func (p *Player) IsFinished() bool { return p.finished }
+
+// FrameDelta returns the recorded delta time of the frame
+func (p *Player) FrameDelta(_ engine.FrameId, deltaTime float64) float64 {
+	if p.finished || p.next >= len(p.replay.Frames) {
+		return deltaTime
+	}
+	return p.replay.Frames[p.next].DeltaTime
+}
+
+// ProcessInput replaces the state of the window devices with the recorded
+// state of the frame
+func (p *Player) ProcessInput(host *engine.Host) {
+	if p.finished {
+		return
+	}
+	if p.step() {
+		p.state.restore(host.Window)
+	}
+	if p.next >= len(p.replay.Frames) {
+		p.Stop(host)
+	}
+}
+
+// step applies the next frame to the replayed state, it returns false when
+// there are no frames left
+func (p *Player) step() bool {
+	if p.next >= len(p.replay.Frames) {
+		return false
+	}
+	p.state.apply(&p.replay.Frames[p.next])
+	p.next++
+	return true
+}
+
+// Stop gives control of the input back to the window and executes
+// [Player.OnFinished]
+func (p *Player) Stop(host *engine.Host) {
+	if p.finished {
+		return
+	}
+	p.finished = true
+	if host.FrameInput() == p {
+		host.SetFrameInput(nil)
+	}
+	slog.Info("finished playing input replay", "frames", p.next)
+	p.OnFinished.Execute()
+}
@@ -0,0 +1,121 @@
+/******************************************************************************/
+/* replay_recorder.go                                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replay
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"os"
+
+	"kaijuengine.com/engine"
+)
+
+// flushInterval is how many frames are buffered before they are written out,
+// this bounds how much of the recording is lost if the game crashes
+const flushInterval = 60
+
+// Recorder writes the input of each frame of a host to a replay. It is an
+// [engine.FrameInput] and doesn't change the input that it records.
+type Recorder struct {
+	out       io.WriteCloser
+	writer    *bufio.Writer
+	last      inputState
+	frame     uint64
+	deltaTime float64
+	err       error
+}
+
+// NewRecorder creates a recorder that writes the replay to out. The header is
+// written right away, out is closed by [Recorder.Close].
+func NewRecorder(out io.WriteCloser, header Header) (*Recorder, error) {
+	r := &Recorder{out: out, writer: bufio.NewWriter(out)}
+	header.Version = Version
+	if err := writeHeader(r.writer, header); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// StartRecording creates the replay file at the path and starts recording the
+// input of the host into it, starting with the next frame
+func StartRecording(host *engine.Host, path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRecorder(file, Header{
+		WindowWidth:  int32(host.Window.Width()),
+		WindowHeight: int32(host.Window.Height()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	host.SetFrameInput(r)
+	slog.Info("started recording input", "file", path)
+	return r, nil
+}
+
+// Frames returns the number of frames that have been recorded
+func (r *Recorder) Frames() uint64 { return r.frame }
+
+// FrameDelta holds on to the delta time so it can be written with the input
+// of the frame
+func (r *Recorder) FrameDelta(_ engine.FrameId, deltaTime float64) float64 {
+	r.deltaTime = deltaTime
+	return deltaTime
+}
+
+// ProcessInput writes the devices that have changed since the last frame
+func (r *Recorder) ProcessInput(host *engine.Host) {
+	r.record(captureState(host.Window))
+}
+
+func (r *Recorder) record(state inputState) {
+	if r.err != nil {
+		return
+	}
+	f := r.last.diff(&state, r.deltaTime)
+	if r.err = writeFrame(r.writer, r.frame, &f); r.err == nil {
+		r.frame++
+		if r.frame%flushInterval == 0 {
+			r.err = r.writer.Flush()
+		}
+	}
+	if r.err != nil {
+		slog.Error("failed to write the input recording, recording stopped", "error", r.err)
+		return
+	}
+	r.last = state
+}
+
+// Stop stops recording the host and closes the replay
+func (r *Recorder) Stop(host *engine.Host) error {
+	if host.FrameInput() == r {
+		host.SetFrameInput(nil)
+	}
+	slog.Info("stopped recording input", "frames", r.frame)
+	return r.Close()
+}
+
+// Close writes out the rest of the replay and closes it
+func (r *Recorder) Close() error {
+	if r.out == nil {
+		return r.err
+	}
+	flushErr := r.writer.Flush()
+	closeErr := r.out.Close()
+	r.out = nil
+	if r.err != nil {
+		return r.err
+	}
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
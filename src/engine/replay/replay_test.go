@@ -0,0 +1,167 @@
+/******************************************************************************/
+/* replay_test.go                                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replay
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"kaijuengine.com/platform/hid"
+	"kaijuengine.com/platform/windowing"
+)
+
+type bufferCloser struct{ bytes.Buffer }
+
+func (b *bufferCloser) Close() error { return nil }
+
+func newTestWindow() *windowing.Window {
+	return &windowing.Window{
+		Keyboard:   hid.NewKeyboard(),
+		Mouse:      hid.NewMouse(),
+		Touch:      hid.NewTouch(),
+		Controller: hid.NewController(),
+	}
+}
+
+// recordSession plays a short session on a window, recording the state of
+// each frame, and returns the replay data and the state that was seen
+func recordSession(t *testing.T) ([]byte, []inputState) {
+	t.Helper()
+	out := &bufferCloser{}
+	r, err := NewRecorder(out, Header{WindowWidth: 640, WindowHeight: 480})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWindow()
+	steps := []func(){
+		func() { w.Keyboard.SetKeyDown(hid.KeyboardKeyW) },
+		func() { w.Mouse.SetPosition(10, 20, 640, 480) },
+		func() {
+			w.Mouse.SetDown(hid.MouseButtonLeft)
+			w.Controller.Connected(1)
+			w.Controller.SetAxis(1, hid.ControllerAxisLeftHorizontal, 0.5)
+		},
+		func() {},
+		func() {
+			w.Keyboard.SetKeyUp(hid.KeyboardKeyW)
+			w.Touch.SetDown(7, 5, 6, 480)
+		},
+	}
+	seen := []inputState{}
+	for i, step := range steps {
+		step()
+		r.FrameDelta(0, float64(i+1)/100)
+		r.record(captureState(w))
+		seen = append(seen, captureState(w))
+		w.EndUpdate()
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes(), seen
+}
+
+func TestRecordAndReplayMatches(t *testing.T) {
+	data, seen := recordSession(t)
+	rp, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp.WindowWidth != 640 || rp.WindowHeight != 480 || rp.Version != Version {
+		t.Errorf("unexpected header %+v", rp.Header)
+	}
+	if len(rp.Frames) != len(seen) {
+		t.Fatalf("frames = %d, want %d", len(rp.Frames), len(seen))
+	}
+	p := NewPlayer(rp)
+	w := newTestWindow()
+	for i := range seen {
+		if dt := p.FrameDelta(0, 1); dt != float64(i+1)/100 {
+			t.Errorf("frame %d delta = %f, want %f", i, dt, float64(i+1)/100)
+		}
+		if !p.step() {
+			t.Fatalf("frame %d was not played", i)
+		}
+		p.state.restore(w)
+		if got := captureState(w); got != seen[i] {
+			t.Errorf("frame %d replayed state does not match the recording", i)
+		}
+		w.EndUpdate()
+	}
+	if p.step() {
+		t.Error("expected the replay to be over")
+	}
+}
+
+func TestRecordOnlyWritesChangedDevices(t *testing.T) {
+	data, _ := recordSession(t)
+	rp, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle := rp.Frames[3]
+	if idle.Keyboard != nil || idle.Touch != nil || idle.Controllers[1] != nil {
+		t.Error("an idle frame should only hold the devices that changed on their own")
+	}
+	if idle.Mouse == nil || idle.Mouse.Buttons[hid.MouseButtonLeft] != hid.MouseRepeat {
+		t.Error("expected the held mouse button to be recorded")
+	}
+	if rp.Frames[2].Controllers[1] == nil || rp.Frames[2].Controllers[0] != nil {
+		t.Error("only the connected controller should be written")
+	}
+	if rp.Frames[4].Touch == nil || rp.Frames[4].Touch.Count != 1 {
+		t.Error("expected the touch pointer to be recorded")
+	}
+}
+
+func TestKeyboardRestoreRunsCallbacks(t *testing.T) {
+	rp := &Replay{Frames: []Frame{{}, {}}}
+	down := hid.NewKeyboard()
+	down.SetKeyDown(hid.KeyboardKeySpace)
+	snap := down.Snapshot()
+	rp.Frames[0].Keyboard = &snap
+	w := newTestWindow()
+	states := []hid.KeyState{}
+	w.Keyboard.AddKeyCallback(func(key int, state hid.KeyState) {
+		if key == hid.KeyboardKeySpace {
+			states = append(states, state)
+		}
+	})
+	p := NewPlayer(rp)
+	p.step()
+	p.state.restore(w)
+	p.step()
+	p.state.restore(w)
+	if len(states) != 1 || states[0] != hid.KeyStateDown {
+		t.Errorf("callback states = %v, want a single down", states)
+	}
+	if !w.Keyboard.KeyDown(hid.KeyboardKeySpace) {
+		t.Error("expected the key to be down")
+	}
+}
+
+func TestReadRejectsOtherData(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("not a replay"))); err == nil {
+		t.Error("expected an error for data that is not a replay")
+	}
+	data, _ := recordSession(t)
+	if _, err := Read(io.LimitReader(bytes.NewReader(data), 6)); err == nil {
+		t.Error("expected an error for a truncated header")
+	}
+}
+
+func TestReadKeepsFramesBeforeTruncation(t *testing.T) {
+	data, seen := recordSession(t)
+	rp, err := Read(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.Frames) != len(seen)-1 {
+		t.Errorf("frames = %d, want the cut off frame dropped", len(rp.Frames))
+	}
+}
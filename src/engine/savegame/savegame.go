@@ -0,0 +1,427 @@
+/******************************************************************************/
+/* savegame.go                                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+// Package savegame snapshots the live state of the entities in a running
+// [engine.Host] so that it can be written to a save slot and later restored
+// into a host that has loaded the same stage.
+//
+// Only entities that have an identifier (see [engine.Host.SetEntityId]) are
+// saved, as the identifier is what is used to find the entity again when the
+// save is restored. For each entity the name, parent, active state, local
+// transform, named data and the fields of its bound entity data are saved.
+// Entity data loaded from a stage is only bound when it implements
+// [engine.EntityDataBinder], otherwise it can be bound with
+// [engine.Entity.BindEntityData].
+// Named data and fields are only saved if their type has been registered with
+// [pod], named basic types (such as [engine.EntityId]) are saved as their
+// underlying type.
+package savegame
+
+import (
+	"log/slog"
+	"reflect"
+	"slices"
+	"time"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/encoding/pod"
+	"kaijuengine.com/matrix"
+)
+
+// Save is a snapshot of the state of a host. Version is the version of the
+// game's save schema that the save was written with, see [Manager.AddMigration]
+// for upgrading older saves.
+type Save struct {
+	Version  int
+	Stage    string
+	Time     int64
+	Entities []EntitySnapshot
+	Globals  []NamedDataSnapshot
+}
+
+// EntitySnapshot is the saved state of a single entity. HasParent is set when
+// the entity had a parent, as Parent is left empty when that parent has no id.
+type EntitySnapshot struct {
+	Id         string
+	Name       string
+	Parent     string
+	HasParent  bool
+	Active     bool
+	Position   matrix.Vec3
+	Rotation   matrix.Vec3
+	Scale      matrix.Vec3
+	NamedData  []NamedDataSnapshot
+	EntityData []EntityDataSnapshot
+}
+
+// NamedDataSnapshot holds the values that were stored under a key, it is used
+// for an entity's named data and for a save's globals
+type NamedDataSnapshot struct {
+	Key    string
+	Values []any
+}
+
+// EntityDataSnapshot holds the saved fields of an entity data bound to an
+// entity. Key is the registration key of the entity data, the fields and
+// values are parallel slices.
+type EntityDataSnapshot struct {
+	Key    string
+	Fields []string
+	Values []any
+}
+
+// EntityDataRestorer can be implemented by entity data that needs to update
+// its runtime state after its fields have been restored from a save
+type EntityDataRestorer interface {
+	RestoreSave(entity *engine.Entity, host *engine.Host)
+}
+
+// RestoreResult reports the saved entities that could not be found in the
+// host when the save was restored
+type RestoreResult struct {
+	Restored int
+	Missing  []string
+}
+
+func init() {
+	pod.Register(Save{})
+	pod.Register(EntitySnapshot{})
+	pod.Register(NamedDataSnapshot{})
+	pod.Register(EntityDataSnapshot{})
+}
+
+// New creates an empty save for the given schema version and stage
+func New(version int, stage string) *Save {
+	return &Save{
+		Version: version,
+		Stage:   stage,
+		Time:    time.Now().Unix(),
+	}
+}
+
+// Capture creates a save holding a snapshot of every entity in the host that
+// has an identifier
+func Capture(host *engine.Host, version int, stage string) *Save {
+	s := New(version, stage)
+	for _, e := range host.EntitiesWithId() {
+		if e.IsDestroyed() {
+			continue
+		}
+		s.Entities = append(s.Entities, CaptureEntity(e))
+	}
+	return s
+}
+
+// CaptureEntity creates a snapshot of the given entity
+func CaptureEntity(e *engine.Entity) EntitySnapshot {
+	snap := EntitySnapshot{
+		Id:       string(e.Id()),
+		Name:     e.Name(),
+		Active:   e.IsActive(),
+		Position: e.Transform.Position(),
+		Rotation: e.Transform.Rotation(),
+		Scale:    e.Transform.Scale(),
+	}
+	if e.Parent != nil {
+		snap.Parent = string(e.Parent.Id())
+		snap.HasParent = true
+	}
+	for _, key := range e.NamedDataKeys() {
+		nd := NamedDataSnapshot{Key: key}
+		for _, v := range e.NamedData(key) {
+			if sv, ok := saveValue(reflect.ValueOf(v)); ok {
+				nd.Values = append(nd.Values, sv)
+			}
+		}
+		if len(nd.Values) > 0 {
+			snap.NamedData = append(snap.NamedData, nd)
+		}
+	}
+	for _, data := range e.EntityData() {
+		v := reflect.Indirect(reflect.ValueOf(data))
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		ed := EntityDataSnapshot{Key: pod.QualifiedNameForLayout(v.Interface())}
+		t := v.Type()
+		for i := range t.NumField() {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if sv, ok := saveValue(v.Field(i)); ok {
+				ed.Fields = append(ed.Fields, t.Field(i).Name)
+				ed.Values = append(ed.Values, sv)
+			}
+		}
+		snap.EntityData = append(snap.EntityData, ed)
+	}
+	return snap
+}
+
+// SetGlobal stores values in the save that are not tied to an entity, such as
+// the player's inventory or quest progress. The values must be pod registered.
+func (s *Save) SetGlobal(key string, values ...any) {
+	nd := NamedDataSnapshot{Key: key}
+	for _, v := range values {
+		if sv, ok := saveValue(reflect.ValueOf(v)); ok {
+			nd.Values = append(nd.Values, sv)
+		} else {
+			slog.Warn("the save global value type is not registered with pod, it will not be saved",
+				"key", key, "type", reflect.TypeOf(v))
+		}
+	}
+	idx := slices.IndexFunc(s.Globals, func(g NamedDataSnapshot) bool { return g.Key == key })
+	if idx >= 0 {
+		s.Globals[idx] = nd
+	} else {
+		s.Globals = append(s.Globals, nd)
+	}
+}
+
+// Global returns the values stored with [Save.SetGlobal] for the key, nil is
+// returned if there are none
+func (s *Save) Global(key string) []any {
+	for i := range s.Globals {
+		if s.Globals[i].Key == key {
+			return s.Globals[i].Values
+		}
+	}
+	return nil
+}
+
+// Entity returns the snapshot of the entity with the given id, or nil if the
+// entity was not saved
+func (s *Save) Entity(id engine.EntityId) *EntitySnapshot {
+	for i := range s.Entities {
+		if s.Entities[i].Id == string(id) {
+			return &s.Entities[i]
+		}
+	}
+	return nil
+}
+
+// Restore applies the save to the entities in the host. Entities are matched
+// by their identifier, any saved entity that is not found in the host is
+// reported in the result and skipped.
+func (s *Save) Restore(host *engine.Host) RestoreResult {
+	res := RestoreResult{}
+	restored := make([]*engine.Entity, 0, len(s.Entities))
+	snaps := make([]*EntitySnapshot, 0, len(s.Entities))
+	for i := range s.Entities {
+		snap := &s.Entities[i]
+		e := host.EntityById(engine.EntityId(snap.Id))
+		if e == nil || e.IsDestroyed() {
+			res.Missing = append(res.Missing, snap.Id)
+			continue
+		}
+		restored = append(restored, e)
+		snaps = append(snaps, snap)
+	}
+	for i, e := range restored {
+		snaps[i].restoreState(e, host)
+	}
+	// Active state is applied once the hierarchy is in place, parents first,
+	// so that children that are only inactive because of their parent keep
+	// following their parent
+	order := make([]int, len(restored))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return entityDepth(restored[a]) - entityDepth(restored[b])
+	})
+	for _, i := range order {
+		if restored[i].IsActive() != snaps[i].Active {
+			restored[i].SetActive(snaps[i].Active)
+		}
+	}
+	for _, e := range restored {
+		for _, data := range e.EntityData() {
+			if r, ok := data.(EntityDataRestorer); ok {
+				r.RestoreSave(e, host)
+			}
+		}
+	}
+	res.Restored = len(restored)
+	return res
+}
+
+func (snap *EntitySnapshot) restoreState(e *engine.Entity, host *engine.Host) {
+	e.SetName(snap.Name)
+	if !snap.HasParent && snap.Parent == "" {
+		if e.Parent != nil {
+			e.SetParent(nil)
+		}
+	} else if snap.Parent == "" {
+		// The parent has no id to find it by, so the current one is kept
+	} else if p := host.EntityById(engine.EntityId(snap.Parent)); p != nil && p != e.Parent {
+		e.SetParent(p)
+	} else if p == nil {
+		slog.Warn("the saved parent of the entity could not be found", "id", snap.Id, "parent", snap.Parent)
+	}
+	e.Transform.SetPosition(snap.Position)
+	e.Transform.SetRotation(snap.Rotation)
+	e.Transform.SetScale(snap.Scale)
+	for i := range snap.NamedData {
+		restoreNamedData(e, &snap.NamedData[i])
+	}
+	seen := map[string]int{}
+	bound := e.EntityData()
+	for i := range snap.EntityData {
+		ed := &snap.EntityData[i]
+		// Entities can have more than one of the same entity data, they are
+		// matched up in the order that they were bound
+		nth := seen[ed.Key]
+		seen[ed.Key]++
+		data := nthEntityData(bound, ed.Key, nth)
+		if data == nil {
+			slog.Warn("the saved entity data is not bound to the entity", "id", snap.Id, "key", ed.Key)
+			continue
+		}
+		v := reflect.ValueOf(data)
+		if v.Kind() != reflect.Pointer {
+			slog.Warn("the entity data was not bound by pointer and can't be restored", "id", snap.Id, "key", ed.Key)
+			continue
+		}
+		v = v.Elem()
+		for j := range ed.Fields {
+			if j >= len(ed.Values) {
+				break
+			}
+			f := v.FieldByName(ed.Fields[j])
+			if !f.IsValid() || !f.CanSet() {
+				slog.Warn("the saved entity data field no longer exists", "key", ed.Key, "field", ed.Fields[j])
+				continue
+			}
+			if !assignValue(f, ed.Values[j]) {
+				slog.Warn("the saved entity data field type no longer matches", "key", ed.Key,
+					"field", ed.Fields[j], "type", reflect.TypeOf(ed.Values[j]))
+			}
+		}
+	}
+}
+
+// restoreNamedData writes the saved values back into the named data of the
+// entity. When the existing named data is a pointer of the same type, the
+// value is written into it so that anything holding the pointer sees the
+// restored value, otherwise the key is replaced with the saved values.
+func restoreNamedData(e *engine.Entity, nd *NamedDataSnapshot) {
+	existing := e.NamedData(nd.Key)
+	if len(existing) == len(nd.Values) {
+		inPlace := true
+		for i, v := range existing {
+			ptr := reflect.ValueOf(v)
+			if ptr.Kind() != reflect.Pointer || ptr.IsNil() || !assignValue(ptr.Elem(), nd.Values[i]) {
+				inPlace = false
+				break
+			}
+		}
+		if inPlace {
+			return
+		}
+	}
+	e.RemoveNamedDataByName(nd.Key)
+	for _, v := range nd.Values {
+		e.AddNamedData(nd.Key, v)
+	}
+}
+
+func nthEntityData(bound []engine.EntityData, key string, nth int) engine.EntityData {
+	for _, data := range bound {
+		v := reflect.Indirect(reflect.ValueOf(data))
+		if !v.IsValid() || pod.QualifiedNameForLayout(v.Interface()) != key {
+			continue
+		}
+		if nth == 0 {
+			return data
+		}
+		nth--
+	}
+	return nil
+}
+
+func entityDepth(e *engine.Entity) int {
+	depth := 0
+	for p := e.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// saveValue returns the value as it should be written into a save. Pointers
+// are followed, registered types are kept as is and named basic types are
+// converted to their underlying type. Types that can't be encoded are skipped.
+func saveValue(v reflect.Value) (any, bool) {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	val := v.Interface()
+	if pod.IsRegistered(val) {
+		return val, true
+	}
+	if basic, ok := basicTypes[v.Kind()]; ok {
+		return v.Convert(basic).Interface(), true
+	}
+	return nil, false
+}
+
+// assignValue sets the field to the saved value, converting it back from its
+// underlying type if needed
+func assignValue(f reflect.Value, saved any) bool {
+	if saved == nil || !f.CanSet() {
+		return false
+	}
+	sv := reflect.ValueOf(saved)
+	switch {
+	case sv.Type().AssignableTo(f.Type()):
+		f.Set(sv)
+	case kindClass(sv.Kind()) != 0 && kindClass(sv.Kind()) == kindClass(f.Kind()):
+		f.Set(sv.Convert(f.Type()))
+	default:
+		return false
+	}
+	return true
+}
+
+// kindClass groups the kinds that a saved value can be converted between, pod
+// writes an int as an int32 for example
+func kindClass(k reflect.Kind) int {
+	switch {
+	case k == reflect.Bool:
+		return 1
+	case k >= reflect.Int && k <= reflect.Uint64:
+		return 2
+	case k == reflect.Float32 || k == reflect.Float64:
+		return 3
+	case k == reflect.String:
+		return 4
+	}
+	return 0
+}
+
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:       reflect.TypeFor[bool](),
+	reflect.Int:        reflect.TypeFor[int](),
+	reflect.Int8:       reflect.TypeFor[int8](),
+	reflect.Int16:      reflect.TypeFor[int16](),
+	reflect.Int32:      reflect.TypeFor[int32](),
+	reflect.Int64:      reflect.TypeFor[int64](),
+	reflect.Uint8:      reflect.TypeFor[uint8](),
+	reflect.Uint16:     reflect.TypeFor[uint16](),
+	reflect.Uint32:     reflect.TypeFor[uint32](),
+	reflect.Uint64:     reflect.TypeFor[uint64](),
+	reflect.Float32:    reflect.TypeFor[float32](),
+	reflect.Float64:    reflect.TypeFor[float64](),
+	reflect.Complex64:  reflect.TypeFor[complex64](),
+	reflect.Complex128: reflect.TypeFor[complex128](),
+	reflect.String:     reflect.TypeFor[string](),
+}
@@ -0,0 +1,259 @@
+/******************************************************************************/
+/* savegame_manager.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package savegame
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/encoding/pod"
+)
+
+// FileExtension is the extension of the save slot files
+const FileExtension = ".ksav"
+
+var saveMagic = []byte{'K', 'S', 'A', 'V'}
+
+// ErrSlotNotFound is returned when reading a slot that has not been written
+var ErrSlotNotFound = errors.New("the save slot does not exist")
+
+// Migration upgrades a save by a single version, from the version it was
+// registered for to the next one
+type Migration func(save *Save) error
+
+// SlotInfo describes a save slot on disk
+type SlotInfo struct {
+	Name     string
+	Modified time.Time
+	Size     int64
+}
+
+// Manager reads and writes saves into named slots in a folder. Saves are
+// written with the manager's version, saves with an older version are brought
+// up to date by the registered migrations when they are read.
+type Manager struct {
+	dir        string
+	version    int
+	migrations map[int]Migration
+}
+
+// NewManager creates a manager for the save slots in the folder, the folder is
+// created when the first save is written. Version is the current version of
+// the game's save schema.
+func NewManager(dir string, version int) *Manager {
+	return &Manager{
+		dir:        dir,
+		version:    version,
+		migrations: make(map[int]Migration),
+	}
+}
+
+// Dir returns the folder that the save slots are written to
+func (m *Manager) Dir() string { return m.dir }
+
+// Version returns the save schema version that saves are written with
+func (m *Manager) Version() int { return m.version }
+
+// AddMigration registers the function that upgrades a save from the given
+// version to the version after it. Upgrading a save across several versions
+// runs each of the migrations in order.
+func (m *Manager) AddMigration(from int, migration Migration) {
+	if _, ok := m.migrations[from]; ok {
+		slog.Warn("replacing the existing save migration", "from", from)
+	}
+	m.migrations[from] = migration
+}
+
+// Capture creates a save of the host with the manager's version
+func (m *Manager) Capture(host *engine.Host, stage string) *Save {
+	return Capture(host, m.version, stage)
+}
+
+// Write writes the save into the slot, replacing the slot's previous save. The
+// save is written to a temporary file first and then moved over the slot, so
+// a crash part way through never leaves a broken slot behind.
+func (m *Manager) Write(slot string, save *Save) error {
+	path, err := m.slotPath(slot)
+	if err != nil {
+		return err
+	}
+	buf := bytes.Buffer{}
+	if err = Encode(&buf, save); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(m.dir, os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(m.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf.Bytes()); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Read reads the save in the slot and migrates it to the manager's version
+func (m *Manager) Read(slot string) (*Save, error) {
+	path, err := m.slotPath(slot)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrSlotNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	save, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read save slot '%s': %w", slot, err)
+	}
+	if err = m.Migrate(save); err != nil {
+		return nil, fmt.Errorf("failed to migrate save slot '%s': %w", slot, err)
+	}
+	return save, nil
+}
+
+// Migrate runs the migrations needed to bring the save up to the manager's
+// version. Saves from a newer version of the game can't be migrated.
+func (m *Manager) Migrate(save *Save) error {
+	if save.Version > m.version {
+		return fmt.Errorf("the save version %d is newer than the supported version %d", save.Version, m.version)
+	}
+	for save.Version < m.version {
+		migrate, ok := m.migrations[save.Version]
+		if !ok {
+			return fmt.Errorf("there is no migration from save version %d", save.Version)
+		}
+		if err := migrate(save); err != nil {
+			return fmt.Errorf("migration from save version %d failed: %w", save.Version, err)
+		}
+		save.Version++
+	}
+	return nil
+}
+
+// SaveHost captures the host and writes it into the slot
+func (m *Manager) SaveHost(slot string, host *engine.Host, stage string) error {
+	return m.Write(slot, m.Capture(host, stage))
+}
+
+// LoadIntoHost reads the save in the slot and restores it into the host. The
+// host is expected to already have the save's stage loaded.
+func (m *Manager) LoadIntoHost(slot string, host *engine.Host) (*Save, RestoreResult, error) {
+	save, err := m.Read(slot)
+	if err != nil {
+		return nil, RestoreResult{}, err
+	}
+	res := save.Restore(host)
+	if len(res.Missing) > 0 {
+		slog.Warn("some saved entities were not found in the host", "slot", slot, "missing", res.Missing)
+	}
+	return save, res, nil
+}
+
+// Exists returns true if the slot has a save written to it
+func (m *Manager) Exists(slot string) bool {
+	path, err := m.slotPath(slot)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Delete removes the save in the slot, deleting a slot that doesn't exist is
+// not an error
+func (m *Manager) Delete(slot string) error {
+	path, err := m.slotPath(slot)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Slots returns the slots that have a save written to them, the most recently
+// written slot is first
+func (m *Manager) Slots() ([]SlotInfo, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []SlotInfo{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	slots := []SlotInfo{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != FileExtension {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		slots = append(slots, SlotInfo{
+			Name:     strings.TrimSuffix(name, FileExtension),
+			Modified: info.ModTime(),
+			Size:     info.Size(),
+		})
+	}
+	slices.SortStableFunc(slots, func(a, b SlotInfo) int {
+		return b.Modified.Compare(a.Modified)
+	})
+	return slots, nil
+}
+
+func (m *Manager) slotPath(slot string) (string, error) {
+	if slot == "" || slot == "." || slot == ".." || strings.ContainsAny(slot, `/\:`) {
+		return "", fmt.Errorf("invalid save slot name '%s'", slot)
+	}
+	return filepath.Join(m.dir, slot+FileExtension), nil
+}
+
+// Encode writes the save to the writer
+func Encode(w io.Writer, save *Save) error {
+	if _, err := w.Write(saveMagic); err != nil {
+		return err
+	}
+	return pod.NewEncoder(w).Encode(*save)
+}
+
+// Decode reads a save that was written with [Encode]
+func Decode(r io.Reader) (*Save, error) {
+	magic := make([]byte, len(saveMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, saveMagic) {
+		return nil, errors.New("the data is not a save file")
+	}
+	save := &Save{}
+	if err := pod.NewDecoder(r).Decode(save); err != nil {
+		return nil, err
+	}
+	return save, nil
+}
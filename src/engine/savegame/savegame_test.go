@@ -0,0 +1,241 @@
+/******************************************************************************/
+/* savegame_test.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package savegame
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/matrix"
+)
+
+type testHealth struct {
+	Hp       int
+	Owner    engine.EntityId
+	Speed    float32
+	restored bool
+}
+
+func (testHealth) Init(*engine.Entity, *engine.Host) {}
+
+func (h *testHealth) RestoreSave(*engine.Entity, *engine.Host) { h.restored = true }
+
+type testUnsaved struct{}
+
+func init() {
+	engine.RegisterEntityData(testHealth{})
+}
+
+func newTestEntity(t *testing.T, host *engine.Host, id string) *engine.Entity {
+	t.Helper()
+	e := engine.NewEntity(host.WorkGroup())
+	if !host.SetEntityId(e, engine.EntityId(id)) {
+		t.Fatalf("failed to set the entity id %s", id)
+	}
+	return e
+}
+
+// buildScene creates a player with a child sword, both with ids, and an
+// entity without an id that should not be saved
+func buildScene(t *testing.T) (*engine.Host, *engine.Entity, *engine.Entity) {
+	t.Helper()
+	host := engine.NewHost("test", nil, nil)
+	player := newTestEntity(t, host, "player")
+	player.SetName("Player")
+	player.BindEntityData(testHealth{Hp: 100, Speed: 2})
+	player.AddNamedData("coins", new(int32))
+	player.AddNamedData("ignored", &testUnsaved{})
+	sword := newTestEntity(t, host, "sword")
+	sword.SetParent(player)
+	engine.NewEntity(host.WorkGroup()).SetName("no id")
+	return host, player, sword
+}
+
+func health(e *engine.Entity) *testHealth {
+	return e.EntityData()[0].(*testHealth)
+}
+
+func TestBindEntityDataIsMutable(t *testing.T) {
+	_, player, _ := buildScene(t)
+	health(player).Hp = 40
+	if health(player).Hp != 40 {
+		t.Error("expected the bound entity data to be changed in place")
+	}
+}
+
+func TestCaptureAndRestore(t *testing.T) {
+	host, player, sword := buildScene(t)
+	h := health(player)
+	h.Hp, h.Owner = 35, "sword"
+	*player.NamedData("coins")[0].(*int32) = 12
+	player.Transform.SetPosition(matrix.Vec3{1, 2, 3})
+	sword.SetActive(false)
+	save := Capture(host, 1, "level_1")
+	if len(save.Entities) != 2 {
+		t.Fatalf("saved %d entities, want 2", len(save.Entities))
+	}
+	if nd := save.Entity("player").NamedData; len(nd) != 1 || nd[0].Key != "coins" {
+		t.Errorf("unexpected named data %+v", nd)
+	}
+	buf := bytes.Buffer{}
+	if err := Encode(&buf, save); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Change everything back before restoring
+	coins := player.NamedData("coins")[0].(*int32)
+	*coins = 0
+	h.Hp, h.Owner = 100, ""
+	player.Transform.SetPosition(matrix.Vec3Zero())
+	sword.SetParent(nil)
+	sword.SetActive(true)
+	res := loaded.Restore(host)
+	if res.Restored != 2 || len(res.Missing) != 0 {
+		t.Errorf("unexpected restore result %+v", res)
+	}
+	if h.Hp != 35 || h.Owner != "sword" || h.Speed != 2 {
+		t.Errorf("entity data was not restored, got %+v", *h)
+	}
+	if !h.restored {
+		t.Error("expected the restore hook to be called")
+	}
+	if *coins != 12 {
+		t.Errorf("coins = %d, want the named data pointer to be restored in place", *coins)
+	}
+	if !player.Transform.Position().Equals(matrix.Vec3{1, 2, 3}) {
+		t.Errorf("position = %v", player.Transform.Position())
+	}
+	if sword.Parent != player || sword.IsActive() {
+		t.Error("expected the sword to be an inactive child of the player")
+	}
+}
+
+func TestRestoreKeepsChildrenFollowingParent(t *testing.T) {
+	host, player, sword := buildScene(t)
+	player.SetActive(false)
+	save := Capture(host, 1, "")
+	player.SetActive(true)
+	save.Restore(host)
+	if player.IsActive() || sword.IsActive() {
+		t.Fatal("expected the player and sword to be inactive")
+	}
+	player.SetActive(true)
+	if !sword.IsActive() {
+		t.Error("the sword should become active with its parent")
+	}
+}
+
+func TestRestoreKeepsParentWithoutId(t *testing.T) {
+	host := engine.NewHost("test", nil, nil)
+	root := engine.NewEntity(host.WorkGroup())
+	child := newTestEntity(t, host, "child")
+	child.SetParent(root)
+	save := Capture(host, 1, "")
+	if !save.Entities[0].HasParent || save.Entities[0].Parent != "" {
+		t.Fatalf("unexpected snapshot %+v", save.Entities[0])
+	}
+	save.Restore(host)
+	if child.Parent != root {
+		t.Error("restoring should not detach an entity whose parent has no id")
+	}
+}
+
+func TestRestoreReportsMissingEntities(t *testing.T) {
+	host, _, _ := buildScene(t)
+	save := Capture(host, 1, "")
+	other, _, _ := buildScene(t)
+	other.SetEntityId(other.EntityById("sword"), "")
+	res := save.Restore(other)
+	if res.Restored != 1 || len(res.Missing) != 1 || res.Missing[0] != "sword" {
+		t.Errorf("unexpected restore result %+v", res)
+	}
+}
+
+func TestManagerSlots(t *testing.T) {
+	host, player, _ := buildScene(t)
+	m := NewManager(filepath.Join(t.TempDir(), "saves"), 1)
+	if slots, err := m.Slots(); err != nil || len(slots) != 0 {
+		t.Fatalf("expected no slots, got %v %v", slots, err)
+	}
+	if _, err := m.Read("one"); err != ErrSlotNotFound {
+		t.Errorf("expected ErrSlotNotFound, got %v", err)
+	}
+	save := m.Capture(host, "level_1")
+	save.SetGlobal("quest", "find the sword", int32(2))
+	if err := m.Write("one", save); err != nil {
+		t.Fatal(err)
+	}
+	health(player).Hp = 1
+	if err := m.SaveHost("two", host, "level_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write("../escape", save); err == nil {
+		t.Error("expected an invalid slot name to be rejected")
+	}
+	slots, err := m.Slots()
+	if err != nil || len(slots) != 2 {
+		t.Fatalf("expected 2 slots, got %v %v", slots, err)
+	}
+	entries, _ := os.ReadDir(m.Dir())
+	if len(entries) != 2 {
+		t.Errorf("expected no temporary files to be left, found %d entries", len(entries))
+	}
+	loaded, res, err := m.LoadIntoHost("one", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Restored != 2 || loaded.Stage != "level_1" || health(player).Hp != 100 {
+		t.Errorf("unexpected load %+v, hp %d", res, health(player).Hp)
+	}
+	if g := loaded.Global("quest"); len(g) != 2 || g[0] != "find the sword" || g[1] != int32(2) {
+		t.Errorf("unexpected globals %v", g)
+	}
+	if err := m.Delete("one"); err != nil || m.Exists("one") {
+		t.Errorf("expected the slot to be deleted, %v", err)
+	}
+}
+
+func TestManagerMigrations(t *testing.T) {
+	host, _, _ := buildScene(t)
+	dir := t.TempDir()
+	old := NewManager(dir, 1)
+	if err := old.SaveHost("slot", host, "level_1"); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(dir, 3)
+	if _, err := m.Read("slot"); err == nil {
+		t.Error("expected an error for a missing migration")
+	}
+	m.AddMigration(1, func(s *Save) error {
+		s.Stage = "level_1_remade"
+		return nil
+	})
+	m.AddMigration(2, func(s *Save) error {
+		s.Entity("player").EntityData[0].Values[0] = int32(150)
+		return nil
+	})
+	save, err := m.Read("slot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if save.Version != 3 || save.Stage != "level_1_remade" {
+		t.Errorf("unexpected migrated save version %d stage %s", save.Version, save.Stage)
+	}
+	save.Restore(host)
+	if hp := health(host.EntityById("player")).Hp; hp != 150 {
+		t.Errorf("hp = %d, want the migrated value", hp)
+	}
+	if _, err := NewManager(dir, 0).Read("slot"); err == nil {
+		t.Error("expected an error reading a save from a newer version")
+	}
+}
@@ -239,6 +239,46 @@ func EntityDescriptionArchiveDeserializer(rawData []byte) (EntityDescription, er
 	return desc, err
 }
 
+// Dependencies lists the content that the entities of a stage use, each key
+// is only listed once
+type Dependencies struct {
+	Meshes    []string
+	Materials []string
+	Textures  []string
+}
+
+// Dependencies collects the meshes, materials and textures of all of the
+// entities in the stage, this is what needs to be loaded before the stage can
+// be created without reading any more content
+func (s *Stage) Dependencies() Dependencies {
+	deps := Dependencies{}
+	seen := map[string]bool{}
+	add := func(list *[]string, kind, key string) {
+		if key == "" || seen[kind+key] {
+			return
+		}
+		seen[kind+key] = true
+		*list = append(*list, key)
+	}
+	var proc func(se *EntityDescription)
+	proc = func(se *EntityDescription) {
+		if se.Mesh != "" {
+			add(&deps.Meshes, "mesh:", se.Mesh)
+			add(&deps.Materials, "mat:", se.Material)
+			for i := range se.Textures {
+				add(&deps.Textures, "tex:", se.Textures[i])
+			}
+		}
+		for i := range se.Children {
+			proc(&se.Children[i])
+		}
+	}
+	for i := range s.Entities {
+		proc(&s.Entities[i])
+	}
+	return deps
+}
+
 func (s *Stage) Load(host *engine.Host) LoadResult {
 	res := LoadResult{
 		EntitiesById: make(map[engine.EntityId]*engine.Entity),
@@ -249,6 +289,9 @@ func (s *Stage) Load(host *engine.Host) LoadResult {
 	}
 	entityBindings := []entityBindingInit{}
 	addEntityBinding := func(data engine.EntityData, entity *engine.Entity) {
+		if engine.EntityDataBindsToEntity(data) {
+			data = entity.BindEntityData(data)
+		}
 		entityBindings = append(entityBindings, entityBindingInit{
 			phase: engine.EntityDataInitPhase(data),
 			init: func() {
@@ -294,7 +337,7 @@ func (s *Stage) Load(host *engine.Host) LoadResult {
 			}
 		} else {
 			for i := range se.RawDataBinding {
-				if data, ok := se.RawDataBinding[i].(engine.EntityData); ok {
+				if data, ok := engine.AsEntityData(se.RawDataBinding[i]); ok {
 					addEntityBinding(data, e)
 				} else {
 					slog.Error("raw data binding does not implement engine.EntityData",
@@ -332,17 +375,20 @@ func SetupEntityFromDescription(e *engine.Entity, host *engine.Host, se *EntityD
 	var err error
 	var builtIn bool
 	meshRef := kaiju_mesh.ParseMeshRef(meshId)
-	if meshRef.Key == "" {
-		km.Verts, km.Indexes, builtIn = rendering.BuiltInMeshData(meshId)
-	}
-	if !builtIn {
-		km, err = kaiju_mesh.ReadMesh(meshId, host)
-	}
-	if err != nil {
-		slog.Error("failed to deserialize the mesh data", "id", meshId, "error", err)
-		return nil, err
+	mesh, cached := host.MeshCache().FindMesh(meshId)
+	if !cached {
+		if meshRef.Key == "" {
+			km.Verts, km.Indexes, builtIn = rendering.BuiltInMeshData(meshId)
+		}
+		if !builtIn {
+			km, err = kaiju_mesh.ReadMesh(meshId, host)
+		}
+		if err != nil {
+			slog.Error("failed to deserialize the mesh data", "id", meshId, "error", err)
+			return nil, err
+		}
+		mesh = host.MeshCache().Mesh(meshId, km.Verts, km.Indexes)
 	}
-	mesh := host.MeshCache().Mesh(meshId, km.Verts, km.Indexes)
 	var mat *rendering.Material
 	if materialId == "" {
 		slog.Warn("no material provided for SpawnMesh, will use fallback material")
@@ -355,6 +401,11 @@ func SetupEntityFromDescription(e *engine.Entity, host *engine.Host, se *EntityD
 	}
 	texs := make([]*rendering.Texture, 0, len(textureIds))
 	for i := range textureIds {
+		// TODO:  Should be reading the filter from the configuration file
+		if tex, ok := host.TextureCache().Find(textureIds[i], rendering.TextureFilterLinear); ok {
+			texs = append(texs, tex)
+			continue
+		}
 		texData, err := ad.Read(textureIds[i])
 		if err != nil {
 			slog.Error("failed to read the texture file", "id", textureIds[i], "error", err)
@@ -0,0 +1,39 @@
+/******************************************************************************/
+/* stage_dependencies_test.go                                                 */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package stages
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStageDependenciesAreUnique(t *testing.T) {
+	stage := Stage{
+		Entities: []EntityDescription{
+			{
+				Mesh:     "cube",
+				Material: "basic.material",
+				Textures: []string{"a.png", "b.png"},
+				Children: []EntityDescription{
+					{Mesh: "cube", Material: "basic.material", Textures: []string{"b.png"}},
+					{Mesh: "hero.msh", Material: "skinned.material"},
+				},
+			},
+			{Name: "empty", Material: "unused.material", Textures: []string{"unused.png"}},
+		},
+	}
+	deps := stage.Dependencies()
+	if !slices.Equal(deps.Meshes, []string{"cube", "hero.msh"}) {
+		t.Errorf("meshes = %v", deps.Meshes)
+	}
+	if !slices.Equal(deps.Materials, []string{"basic.material", "skinned.material"}) {
+		t.Errorf("materials = %v", deps.Materials)
+	}
+	if !slices.Equal(deps.Textures, []string{"a.png", "b.png"}) {
+		t.Errorf("textures = %v", deps.Textures)
+	}
+}
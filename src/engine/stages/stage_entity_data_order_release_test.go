@@ -14,6 +14,7 @@ import (
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/encoding/pod"
+	"kaijuengine.com/engine/savegame"
 	"kaijuengine.com/engine_entity_data/engine_entity_data_physics"
 	"kaijuengine.com/matrix"
 )
@@ -71,6 +72,76 @@ func (d stageOrderConstraintData) EntityDataInitPhase() engine.EntityDataPhase {
 	return engine.EntityDataPhasePhysicsConstraint
 }
 
+type stageBoundData struct {
+	Hp int
+}
+
+func (d stageBoundData) Init(e *engine.Entity, host *engine.Host) {
+	stageOrderLog = append(stageOrderLog, "bound")
+}
+
+func (stageBoundData) BindToEntity() bool { return true }
+
+func TestStageLoadOnlyBindsEntityDataBinders(t *testing.T) {
+	resetStageOrderTestState()
+	stage := Stage{
+		Entities: []EntityDescription{
+			{
+				Id: "entity",
+				RawDataBinding: []any{
+					stageOrderRecordData{Name: "first"},
+					stageBoundData{Hp: 10},
+				},
+			},
+		},
+	}
+	res := stage.Load(engine.NewHost("test", nil, nil))
+	if want := []string{"first", "bound"}; !sameStrings(stageOrderLog, want) {
+		t.Fatalf("expected init order %v, got %v", want, stageOrderLog)
+	}
+	bound := res.Entities[0].EntityData()
+	if len(bound) != 1 {
+		t.Fatalf("expected only the binder to be bound, got %d entity data", len(bound))
+	}
+	if d, ok := bound[0].(*stageBoundData); !ok || d.Hp != 10 {
+		t.Errorf("bound data = %#v, want a pointer copy of the loaded data", bound[0])
+	}
+}
+
+// stageSavedData keeps the pointer it is initialized with and changes it
+// during play, like a health component taking damage
+type stageSavedData struct {
+	Hp int
+}
+
+var stageSavedInit *stageSavedData
+
+func (d *stageSavedData) Init(e *engine.Entity, host *engine.Host) { stageSavedInit = d }
+
+func (stageSavedData) BindToEntity() bool { return true }
+
+func TestStageLoadSavesEntityDataChangedDuringPlay(t *testing.T) {
+	stageSavedInit = nil
+	stage := Stage{
+		Entities: []EntityDescription{
+			{Id: "player", RawDataBinding: []any{stageSavedData{Hp: 10}}},
+		},
+	}
+	host := engine.NewHost("test", nil, nil)
+	res := stage.Load(host)
+	bound := res.Entities[0].EntityData()
+	if stageSavedInit == nil || len(bound) != 1 || bound[0] != engine.EntityData(stageSavedInit) {
+		t.Fatalf("expected the bound data to be initialized, bound %#v", bound)
+	}
+	stageSavedInit.Hp = 4
+	save := savegame.Capture(host, 1, "stage")
+	stageSavedInit.Hp = 9
+	save.Restore(host)
+	if stageSavedInit.Hp != 4 {
+		t.Errorf("Hp = %d, want the 4 that it was changed to before the save", stageSavedInit.Hp)
+	}
+}
+
 func TestStageLoadRawDataBindingOrdersConstraintAfterLaterRigidBody(t *testing.T) {
 	resetStageOrderTestState()
 	stage := Stage{
@@ -0,0 +1,522 @@
+/******************************************************************************/
+/* crowd.go                                                                   */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+)
+
+const (
+	// Penalty weights for choosing the avoidance velocity, a candidate is
+	// scored by how far it is from the desired and current velocities and
+	// how soon it would collide with something
+	avoidWeightDesired = 2.0
+	avoidWeightCurrent = 0.75
+	avoidWeightImpact  = 2.5
+	avoidRings         = 3
+	avoidDirections    = 16
+	// crowdParallelAgents is the number of agents above which the avoidance
+	// of the agents is solved across multiple goroutines
+	crowdParallelAgents = 64
+)
+
+type AgentState int
+
+const (
+	AgentIdle AgentState = iota
+	AgentMoving
+	AgentArrived
+	AgentNoPath
+)
+
+// AgentSettings describe how an [Agent] moves
+type AgentSettings struct {
+	Radius          matrix.Float
+	MaxSpeed        matrix.Float
+	MaxAcceleration matrix.Float
+	// SlowingDistance is how far from the destination the agent starts to
+	// slow down
+	SlowingDistance matrix.Float
+	// ArriveDistance is how close the agent has to get to the destination to
+	// have arrived
+	ArriveDistance matrix.Float
+	// AvoidanceTime is how many seconds ahead the agent looks for collisions
+	// with other agents and obstacles
+	AvoidanceTime matrix.Float
+	// NeighborDistance is how far away other agents and obstacles can be and
+	// still be avoided
+	NeighborDistance matrix.Float
+}
+
+// DefaultAgentSettings returns the settings for a human sized agent
+func DefaultAgentSettings() AgentSettings {
+	return AgentSettings{
+		Radius:           0.5,
+		MaxSpeed:         3.5,
+		MaxAcceleration:  8,
+		SlowingDistance:  1.5,
+		ArriveDistance:   0.1,
+		AvoidanceTime:    2,
+		NeighborDistance: 5,
+	}
+}
+
+// Obstacle is a moving circle, such as a vehicle or physics object, that the
+// agents of a crowd avoid but that doesn't avoid the agents itself
+type Obstacle struct {
+	Position matrix.Vec3
+	Velocity matrix.Vec3
+	Radius   matrix.Float
+}
+
+type ObstacleId int32
+
+// Agent is a member of a [Crowd] that follows a path to its destination while
+// steering around the other agents and obstacles. The methods of an agent can
+// be called from any goroutine.
+type Agent struct {
+	crowd        *Crowd
+	settings     AgentSettings
+	position     matrix.Vec3
+	velocity     matrix.Vec3
+	desired      matrix.Vec3
+	next         matrix.Vec3
+	path         []matrix.Vec3
+	corner       int
+	segmentStart matrix.Vec3
+	state        AgentState
+	active       bool
+}
+
+// Crowd moves a group of agents together so that they can avoid each other.
+// The avoidance is reciprocal, each agent takes half of the responsibility of
+// avoiding another agent, so agents pass each other smoothly rather than
+// both swerving the full distance.
+type Crowd struct {
+	mutex          sync.Mutex
+	finder         PathFinder
+	agents         []*Agent
+	obstacles      []crowdObstacle
+	nextObstacleId ObstacleId
+	cells          map[[2]int32][]int32
+	cellSize       matrix.Float
+	active         []*Agent
+}
+
+type crowdObstacle struct {
+	id ObstacleId
+	Obstacle
+}
+
+// NewCrowd creates a crowd that finds the paths of its agents with the path
+// finder. The path finder can be nil, then agents move straight to their
+// destination.
+func NewCrowd(finder PathFinder) *Crowd {
+	return &Crowd{
+		finder: finder,
+		cells:  make(map[[2]int32][]int32),
+	}
+}
+
+// SetPathFinder changes the path finder used for new destinations, the
+// agents that are moving keep their current paths
+func (c *Crowd) SetPathFinder(finder PathFinder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.finder = finder
+}
+
+// AddAgent adds an agent at the position, it is idle until it is given a
+// destination
+func (c *Crowd) AddAgent(position matrix.Vec3, settings AgentSettings) *Agent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	a := &Agent{
+		crowd:    c,
+		settings: settings,
+		position: position,
+		active:   true,
+	}
+	c.agents = append(c.agents, a)
+	return a
+}
+
+// RemoveAgent removes the agent from the crowd, it should not be used after
+func (c *Crowd) RemoveAgent(agent *Agent) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if idx := slices.Index(c.agents, agent); idx >= 0 {
+		c.agents = slices.Delete(c.agents, idx, idx+1)
+	}
+}
+
+// Agents returns the agents in the crowd
+func (c *Crowd) Agents() []*Agent {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return slices.Clone(c.agents)
+}
+
+// AddObstacle adds an obstacle for the agents to avoid, the returned id is
+// used to move and remove the obstacle
+func (c *Crowd) AddObstacle(obstacle Obstacle) ObstacleId {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.nextObstacleId++
+	c.obstacles = append(c.obstacles, crowdObstacle{c.nextObstacleId, obstacle})
+	return c.nextObstacleId
+}
+
+// MoveObstacle updates the position and velocity of the obstacle
+func (c *Crowd) MoveObstacle(id ObstacleId, position, velocity matrix.Vec3) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := range c.obstacles {
+		if c.obstacles[i].id == id {
+			c.obstacles[i].Position = position
+			c.obstacles[i].Velocity = velocity
+			return
+		}
+	}
+}
+
+// RemoveObstacle removes the obstacle from the crowd
+func (c *Crowd) RemoveObstacle(id ObstacleId) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.obstacles = slices.DeleteFunc(c.obstacles, func(o crowdObstacle) bool { return o.id == id })
+}
+
+// SetDestination finds a path to the target and starts moving along it. If
+// no path can be found the agent stops and the error is returned.
+func (a *Agent) SetDestination(target matrix.Vec3) error {
+	a.crowd.mutex.Lock()
+	finder, start := a.crowd.finder, a.position
+	a.crowd.mutex.Unlock()
+	path := []matrix.Vec3{start, target}
+	var err error
+	if finder != nil {
+		path, err = finder.FindPath(start, target)
+	}
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	if err != nil {
+		a.path, a.state = nil, AgentNoPath
+		return err
+	}
+	a.path, a.corner, a.segmentStart = path, 0, a.position
+	a.state = AgentMoving
+	return nil
+}
+
+// Stop clears the path of the agent, it slows down to a stop
+func (a *Agent) Stop() {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	a.path, a.state = nil, AgentIdle
+}
+
+// Teleport moves the agent to the position without moving through the space
+// in between, its path is cleared
+func (a *Agent) Teleport(position matrix.Vec3) {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	a.position = position
+	a.velocity = matrix.Vec3Zero()
+	a.path, a.state = nil, AgentIdle
+}
+
+// SetActive sets if the agent is updated, inactive agents don't move and are
+// not avoided by the other agents
+func (a *Agent) SetActive(active bool) {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	a.active = active
+}
+
+// SetSettings changes how the agent moves
+func (a *Agent) SetSettings(settings AgentSettings) {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	a.settings = settings
+}
+
+func (a *Agent) Settings() AgentSettings {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	return a.settings
+}
+
+func (a *Agent) Position() matrix.Vec3 {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	return a.position
+}
+
+func (a *Agent) Velocity() matrix.Vec3 {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	return a.velocity
+}
+
+func (a *Agent) State() AgentState {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	return a.state
+}
+
+// Path returns the corners of the path the agent is following
+func (a *Agent) Path() []matrix.Vec3 {
+	a.crowd.mutex.Lock()
+	defer a.crowd.mutex.Unlock()
+	return slices.Clone(a.path)
+}
+
+// Update moves all of the active agents along their paths for the elapsed
+// time while avoiding each other and the obstacles
+func (c *Crowd) Update(deltaTime float64) {
+	defer tracing.NewRegion("Crowd.Update").End()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	dt := matrix.Float(deltaTime)
+	if dt <= 0 {
+		return
+	}
+	c.active = c.active[:0]
+	for _, a := range c.agents {
+		if a.active {
+			c.active = append(c.active, a)
+			a.desired = a.followPath()
+		}
+	}
+	c.buildCells()
+	if len(c.active) < crowdParallelAgents {
+		for i := range c.active {
+			c.active[i].next = c.avoid(int32(i))
+		}
+	} else {
+		workers := runtime.GOMAXPROCS(0)
+		chunk := (len(c.active) + workers - 1) / workers
+		var wg sync.WaitGroup
+		for start := 0; start < len(c.active); start += chunk {
+			end := min(start+chunk, len(c.active))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := start; i < end; i++ {
+					c.active[i].next = c.avoid(int32(i))
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	surface, _ := c.finder.(surfaceFinder)
+	for _, a := range c.active {
+		a.move(dt, surface)
+	}
+}
+
+// followPath returns the velocity the agent wants to move at to follow its
+// path, corners that have been reached are skipped
+func (a *Agent) followPath() matrix.Vec3 {
+	if a.state != AgentMoving || len(a.path) == 0 {
+		return matrix.Vec3Zero()
+	}
+	reach := max(a.settings.Radius, a.settings.ArriveDistance)
+	for a.corner < len(a.path)-1 {
+		if flatten(a.path[a.corner].Subtract(a.position)).Length() > reach {
+			break
+		}
+		a.segmentStart = a.path[a.corner]
+		a.corner++
+	}
+	target := a.path[a.corner]
+	if a.corner < len(a.path)-1 {
+		return Seek(a.position, target, a.settings.MaxSpeed)
+	}
+	return Arrive(a.position, target, a.settings.MaxSpeed, a.settings.SlowingDistance)
+}
+
+func (c *Crowd) cellOf(p matrix.Vec3) [2]int32 {
+	return [2]int32{
+		int32(matrix.Floor(p.X() / c.cellSize)),
+		int32(matrix.Floor(p.Z() / c.cellSize)),
+	}
+}
+
+func (c *Crowd) buildCells() {
+	for k, v := range c.cells {
+		c.cells[k] = v[:0]
+	}
+	c.cellSize = 1
+	for _, a := range c.active {
+		c.cellSize = max(c.cellSize, a.settings.NeighborDistance)
+	}
+	for i, a := range c.active {
+		key := c.cellOf(a.position)
+		c.cells[key] = append(c.cells[key], int32(i))
+	}
+}
+
+// avoid picks the velocity for the agent by sampling velocities around its
+// desired velocity and scoring each on how close it is to the desired and
+// current velocity and how soon it would collide with a neighbor
+func (c *Crowd) avoid(index int32) matrix.Vec3 {
+	a := c.active[index]
+	s := &a.settings
+	desired := a.desired
+	horizon := max(s.AvoidanceTime, 0.1)
+	var neighbors [32]int32
+	near := neighbors[:0]
+	center := c.cellOf(a.position)
+	for z := center[1] - 1; z <= center[1]+1; z++ {
+		for x := center[0] - 1; x <= center[0]+1; x++ {
+			for _, ni := range c.cells[[2]int32{x, z}] {
+				if ni == index {
+					continue
+				}
+				if flatten(c.active[ni].position.Subtract(a.position)).Length() <= s.NeighborDistance {
+					near = append(near, ni)
+				}
+			}
+		}
+	}
+	obstacles := false
+	for i := range c.obstacles {
+		o := &c.obstacles[i]
+		if flatten(o.Position.Subtract(a.position)).Length() <= s.NeighborDistance+o.Radius {
+			obstacles = true
+			break
+		}
+	}
+	if len(near) == 0 && !obstacles {
+		return desired
+	}
+	maxSpeed := max(s.MaxSpeed, matrix.FloatSmallestNonzero)
+	// On the way to the destination, collisions that would happen after the
+	// agent has stopped there are not avoided, otherwise agents can't settle
+	// in next to each other
+	remaining := matrix.FloatMax
+	if a.state == AgentMoving && a.corner == len(a.path)-1 {
+		remaining = flatten(a.path[a.corner].Subtract(a.position)).Length()
+	}
+	penalty := func(candidate matrix.Vec3) matrix.Float {
+		p := avoidWeightDesired * candidate.Subtract(desired).Length() / maxSpeed
+		p += avoidWeightCurrent * candidate.Subtract(a.velocity).Length() / maxSpeed
+		limit := horizon
+		if speed := candidate.Length(); speed > matrix.FloatSmallestNonzero {
+			limit = min(horizon, remaining/speed)
+		}
+		impact := limit
+		for _, ni := range near {
+			b := c.active[ni]
+			// Reciprocal, the other agent is expected to take half of the
+			// avoidance as well
+			rel := candidate.Scale(2).Subtract(a.velocity).Subtract(b.velocity)
+			impact = min(impact, timeToImpact(b.position.Subtract(a.position), rel,
+				s.Radius+b.settings.Radius, limit))
+		}
+		for i := range c.obstacles {
+			o := &c.obstacles[i]
+			impact = min(impact, timeToImpact(o.Position.Subtract(a.position),
+				candidate.Subtract(o.Velocity), s.Radius+o.Radius, limit))
+		}
+		if impact >= limit {
+			impact = horizon
+		}
+		return p + avoidWeightImpact/(0.1+impact/horizon)
+	}
+	best, bestPenalty := desired, penalty(desired)
+	if p := penalty(matrix.Vec3Zero()); p < bestPenalty {
+		best, bestPenalty = matrix.Vec3Zero(), p
+	}
+	heading := matrix.Float(0)
+	if desired.LengthSquared() > matrix.FloatSmallestNonzero {
+		heading = matrix.Atan2(desired.X(), desired.Z())
+	}
+	for ring := avoidRings; ring > 0; ring-- {
+		speed := maxSpeed * matrix.Float(ring) / avoidRings
+		// Directions alternate either side of the heading, so that agents
+		// that meet head on turn to the same side and pass each other
+		for i := range avoidDirections {
+			step := matrix.Float((i+1)/2) * 2 * math.Pi / avoidDirections
+			if i%2 == 0 {
+				step = -step
+			}
+			angle := heading + step
+			candidate := matrix.NewVec3(matrix.Sin(angle)*speed, 0, matrix.Cos(angle)*speed)
+			if p := penalty(candidate); p < bestPenalty {
+				best, bestPenalty = candidate, p
+			}
+		}
+	}
+	return best
+}
+
+// timeToImpact returns how long it takes for a circle moving with the
+// velocity to touch the circle at the offset, or the horizon if it never
+// does. Circles that already overlap only collide if they move closer.
+func timeToImpact(offset, velocity matrix.Vec3, radius, horizon matrix.Float) matrix.Float {
+	offset, velocity = flatten(offset), flatten(velocity)
+	c := offset.Dot(offset) - radius*radius
+	b := offset.Dot(velocity)
+	if c < 0 {
+		if b > 0 {
+			return 0
+		}
+		return horizon
+	}
+	a := velocity.Dot(velocity)
+	if a <= matrix.FloatSmallestNonzero || b <= 0 {
+		return horizon
+	}
+	disc := b*b - a*c
+	if disc < 0 {
+		return horizon
+	}
+	return min(horizon, (b-matrix.Sqrt(disc))/a)
+}
+
+func (a *Agent) move(dt matrix.Float, surface surfaceFinder) {
+	change := clampLength(a.next.Subtract(a.velocity), a.settings.MaxAcceleration*dt)
+	a.velocity = clampLength(a.velocity.Add(change), a.settings.MaxSpeed)
+	a.position.AddAssign(a.velocity.Scale(dt))
+	if surface != nil {
+		extents := matrix.NewVec3(a.settings.Radius*2+1, 2, a.settings.Radius*2+1)
+		if p, ok := surface.FindNearest(a.position, extents); ok {
+			a.position = p
+		}
+	} else if a.state == AgentMoving {
+		a.position.SetY(a.pathHeight())
+	}
+	if a.state != AgentMoving || a.corner < len(a.path)-1 {
+		return
+	}
+	if flatten(a.path[a.corner].Subtract(a.position)).Length() <= a.settings.ArriveDistance {
+		a.state = AgentArrived
+		a.velocity = matrix.Vec3Zero()
+	}
+}
+
+// pathHeight returns the height of the path segment that the agent is on,
+// for path finders that don't know about the walkable surface
+func (a *Agent) pathHeight() matrix.Float {
+	from, to := a.segmentStart, a.path[a.corner]
+	seg := flatten(to.Subtract(from))
+	lenSq := seg.LengthSquared()
+	if lenSq <= matrix.FloatSmallestNonzero {
+		return to.Y()
+	}
+	t := matrix.Clamp(flatten(a.position.Subtract(from)).Dot(seg)/lenSq, 0, 1)
+	return matrix.Lerp(from.Y(), to.Y(), t)
+}
@@ -0,0 +1,167 @@
+/******************************************************************************/
+/* crowd_test.go                                                              */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"testing"
+
+	"kaijuengine.com/matrix"
+)
+
+const crowdTestStep = 1.0 / 60.0
+
+// simulate updates the crowd until every agent has arrived or the time runs
+// out, calling the check after each update
+func simulate(c *Crowd, seconds float64, check func()) bool {
+	for t := 0.0; t < seconds; t += crowdTestStep {
+		c.Update(crowdTestStep)
+		if check != nil {
+			check()
+		}
+		arrived := true
+		for _, a := range c.Agents() {
+			arrived = arrived && a.State() == AgentArrived
+		}
+		if arrived {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCrowdAgentArrives(t *testing.T) {
+	c := NewCrowd(nil)
+	a := c.AddAgent(matrix.Vec3Zero(), DefaultAgentSettings())
+	target := matrix.NewVec3(10, 0, 0)
+	if err := a.SetDestination(target); err != nil {
+		t.Fatal(err)
+	}
+	maxSpeed := matrix.Float(0)
+	if !simulate(c, 10, func() { maxSpeed = max(maxSpeed, a.Velocity().Length()) }) {
+		t.Fatalf("agent did not arrive, it is at %v", a.Position())
+	}
+	if d := a.Position().Distance(target); d > a.Settings().ArriveDistance {
+		t.Fatalf("agent stopped %f from the target", d)
+	}
+	if maxSpeed > a.Settings().MaxSpeed+0.001 {
+		t.Fatalf("agent went faster than its max speed: %f", maxSpeed)
+	}
+	if !a.Velocity().IsZero() {
+		t.Fatalf("agent should stop once it arrives, velocity %v", a.Velocity())
+	}
+}
+
+func TestCrowdAgentsPassHeadOn(t *testing.T) {
+	c := NewCrowd(nil)
+	settings := DefaultAgentSettings()
+	a := c.AddAgent(matrix.NewVec3(-6, 0, 0), settings)
+	b := c.AddAgent(matrix.NewVec3(6, 0, 0), settings)
+	a.SetDestination(matrix.NewVec3(6, 0, 0))
+	b.SetDestination(matrix.NewVec3(-6, 0, 0))
+	closest := matrix.FloatMax
+	ok := simulate(c, 20, func() {
+		closest = min(closest, a.Position().Distance(b.Position()))
+	})
+	if !ok {
+		t.Fatalf("agents did not arrive, they are at %v and %v", a.Position(), b.Position())
+	}
+	if closest < settings.Radius*2*0.9 {
+		t.Fatalf("agents came within %f of each other", closest)
+	}
+}
+
+func TestCrowdAvoidsMovingObstacle(t *testing.T) {
+	c := NewCrowd(nil)
+	a := c.AddAgent(matrix.NewVec3(-6, 0, 0), DefaultAgentSettings())
+	obstaclePos := matrix.NewVec3(0, 0, -4)
+	obstacleVel := matrix.NewVec3(0, 0, 1)
+	id := c.AddObstacle(Obstacle{Position: obstaclePos, Velocity: obstacleVel, Radius: 1})
+	a.SetDestination(matrix.NewVec3(6, 0, 0))
+	closest := matrix.FloatMax
+	ok := simulate(c, 20, func() {
+		obstaclePos.AddAssign(obstacleVel.Scale(crowdTestStep))
+		c.MoveObstacle(id, obstaclePos, obstacleVel)
+		closest = min(closest, a.Position().Distance(obstaclePos))
+	})
+	if !ok {
+		t.Fatalf("agent did not arrive, it is at %v", a.Position())
+	}
+	if closest < 1.5*0.9 {
+		t.Fatalf("agent came within %f of the obstacle", closest)
+	}
+	c.RemoveObstacle(id)
+}
+
+func TestCrowdFollowsNavMesh(t *testing.T) {
+	m := wallLevel(t)
+	c := NewCrowd(m)
+	a := c.AddAgent(matrix.NewVec3(3, 0, 3), DefaultAgentSettings())
+	if err := a.SetDestination(matrix.NewVec3(17, 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	ok := simulate(c, 30, func() {
+		p := a.Position()
+		if p.X() > 8 && p.X() < 12 && p.Z() < 14 {
+			t.Fatalf("agent walked through the wall at %v", p)
+		}
+	})
+	if !ok {
+		t.Fatalf("agent did not arrive, it is at %v", a.Position())
+	}
+	if matrix.Abs(a.Position().Y()) > 0.5 {
+		t.Fatalf("agent should be on the floor, it is at %v", a.Position())
+	}
+	if err := a.SetDestination(matrix.NewVec3(10, 0, 5)); err == nil || a.State() != AgentNoPath {
+		t.Fatalf("expected no path into the wall, got %v and state %d", err, a.State())
+	}
+}
+
+func TestGridPathFinder(t *testing.T) {
+	grid := NewGrid(5, 1, 5)
+	for z := int32(0); z < 4; z++ {
+		grid.BlockCell(matrix.Vec3i{2, 0, z}, 1)
+	}
+	finder := GridPathFinder{Grid: grid, CellSize: 2}
+	start, end := matrix.NewVec3(1, 1, 1), matrix.NewVec3(9, 1, 1)
+	path, err := finder.FindPath(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[0] != start || path[len(path)-1] != end {
+		t.Fatalf("path should run from the start to the end, got %v", path)
+	}
+	for _, p := range path {
+		if cell := finder.CellAt(p); grid.IsBlocked(cell) {
+			t.Fatalf("path point %v is in a blocked cell", p)
+		}
+	}
+	if _, err := finder.FindPath(matrix.NewVec3(5, 1, 1), end); err != ErrPointOffGrid {
+		t.Fatalf("expected the blocked start to fail, got %v", err)
+	}
+}
+
+func TestCrowdManyAgents(t *testing.T) {
+	c := NewCrowd(nil)
+	settings := DefaultAgentSettings()
+	settings.ArriveDistance = 0.5
+	agents := []*Agent{}
+	for i := range crowdParallelAgents + 16 {
+		x, z := matrix.Float(i%10)*2, matrix.Float(i/10)*2
+		a := c.AddAgent(matrix.NewVec3(x, 0, z), settings)
+		a.SetDestination(matrix.NewVec3(x+30, 0, z))
+		agents = append(agents, a)
+	}
+	if !simulate(c, 60, nil) {
+		arrived := 0
+		for _, a := range agents {
+			if a.State() == AgentArrived {
+				arrived++
+			}
+		}
+		t.Fatalf("only %d of %d agents arrived", arrived, len(agents))
+	}
+}
@@ -0,0 +1,75 @@
+/******************************************************************************/
+/* grid_path_finder.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"errors"
+
+	"kaijuengine.com/matrix"
+)
+
+var ErrPointOffGrid = errors.New("the point is not in an open cell of the navigation grid")
+
+// GridPathFinder places a [Grid] in the world so that [AStar] can be used as
+// a [PathFinder]. Cell (0, 0, 0) starts at the origin and each cell is the
+// cell size wide on every axis.
+type GridPathFinder struct {
+	Grid     Grid
+	Origin   matrix.Vec3
+	CellSize matrix.Float
+}
+
+// CellAt returns the cell that holds the point
+func (g GridPathFinder) CellAt(point matrix.Vec3) matrix.Vec3i {
+	local := point.Subtract(g.Origin).Shrink(g.CellSize)
+	return matrix.Vec3i{
+		int32(matrix.Floor(local.X())),
+		int32(matrix.Floor(local.Y())),
+		int32(matrix.Floor(local.Z())),
+	}
+}
+
+// CellCenter returns the point at the center of the cell
+func (g GridPathFinder) CellCenter(cell matrix.Vec3i) matrix.Vec3 {
+	return g.Origin.Add(matrix.NewVec3(
+		matrix.Float(cell.X())+0.5,
+		matrix.Float(cell.Y())+0.5,
+		matrix.Float(cell.Z())+0.5).Scale(g.CellSize))
+}
+
+// FindPath finds the path through the open cells of the grid. The path runs
+// through the cell centers, only keeping the cells where it changes direction,
+// and ends on the end point unless the end is in a blocked cell, in which
+// case it ends on the nearest open cell.
+func (g GridPathFinder) FindPath(start, end matrix.Vec3) ([]matrix.Vec3, error) {
+	from, to := g.CellAt(start), g.CellAt(end)
+	if g.Grid.IsBlocked(from) || !g.Grid.IsValid(to) {
+		return nil, ErrPointOffGrid
+	}
+	nodes := AStar(g.Grid, from, to)
+	if len(nodes) == 0 {
+		return nil, ErrNoNavPath
+	}
+	path := make([]matrix.Vec3, 0, len(nodes)+1)
+	path = append(path, start)
+	for i := 1; i < len(nodes)-1; i++ {
+		prev, cur, next := nodes[i-1].XYZ(), nodes[i].XYZ(), nodes[i+1].XYZ()
+		if cellStep(prev, cur) != cellStep(cur, next) {
+			path = append(path, g.CellCenter(cur))
+		}
+	}
+	if last := nodes[len(nodes)-1].XYZ(); last == to {
+		path = append(path, end)
+	} else {
+		path = append(path, g.CellCenter(last))
+	}
+	return path, nil
+}
+
+func cellStep(from, to matrix.Vec3i) matrix.Vec3i {
+	return matrix.Vec3i{to.X() - from.X(), to.Y() - from.Y(), to.Z() - from.Z()}
+}
@@ -0,0 +1,176 @@
+/******************************************************************************/
+/* nav_mesh.go                                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	"kaijuengine.com/matrix"
+)
+
+var (
+	ErrNavMeshNoGeometry = errors.New("the navigation mesh bake input has no triangles")
+	ErrPointOffNavMesh   = errors.New("the point is not on the navigation mesh")
+	ErrNoNavPath         = errors.New("there is no path between the points on the navigation mesh")
+)
+
+// NavMeshSettings describes the agent that the navigation mesh is baked for
+// and the size of the voxels that the geometry is sampled with
+type NavMeshSettings struct {
+	// CellSize is the width and depth of a voxel, smaller cells follow the
+	// geometry closer but take longer to bake
+	CellSize matrix.Float
+	// CellHeight is the height of a voxel
+	CellHeight matrix.Float
+	// AgentRadius is how far the walkable area is kept away from walls and
+	// ledges
+	AgentRadius matrix.Float
+	// AgentHeight is the clearance needed above the floor for it to be walkable
+	AgentHeight matrix.Float
+	// MaxStep is the highest ledge the agent can step up or down
+	MaxStep matrix.Float
+	// MaxSlope is the steepest slope the agent can walk on in degrees
+	MaxSlope matrix.Float
+}
+
+// DefaultNavMeshSettings returns the settings for a human sized agent
+func DefaultNavMeshSettings() NavMeshSettings {
+	return NavMeshSettings{
+		CellSize:    0.3,
+		CellHeight:  0.2,
+		AgentRadius: 0.5,
+		AgentHeight: 2,
+		MaxStep:     0.4,
+		MaxSlope:    45,
+	}
+}
+
+func (s NavMeshSettings) validate() error {
+	if s.CellSize <= 0 || s.CellHeight <= 0 {
+		return errors.New("the navigation mesh cell size and height must be greater than zero")
+	}
+	if s.AgentHeight <= 0 || s.AgentRadius < 0 || s.MaxStep < 0 {
+		return errors.New("the navigation mesh agent height must be greater than zero and the radius and step can't be negative")
+	}
+	if s.MaxSlope < 0 || s.MaxSlope >= 90 {
+		return errors.New("the navigation mesh max slope must be from 0 up to 90 degrees")
+	}
+	return nil
+}
+
+type navPoly struct {
+	min, max matrix.Vec2
+	// heights are at the corners (min.x, min.z), (max.x, min.z),
+	// (max.x, max.z) and (min.x, max.z)
+	heights   [4]matrix.Float
+	firstLink int32
+	linkCount int32
+}
+
+// navLink is the portal from one polygon into another, left and right are
+// the ends of the portal as seen when moving through it
+type navLink struct {
+	to          int32
+	left, right matrix.Vec3
+}
+
+// NavMesh is a baked set of walkable polygons, see [BakeNavMesh]. A baked
+// navigation mesh is never changed, so it can be queried from any number of
+// goroutines at the same time.
+type NavMesh struct {
+	settings NavMeshSettings
+	origin   matrix.Vec3
+	width    int32
+	depth    int32
+	polys    []navPoly
+	links    []navLink
+	// colStart and colPolys index the polygons that cover each column of
+	// the bake grid, for finding the polygon under a point
+	colStart []int32
+	colPolys []int32
+	queries  sync.Pool
+}
+
+// Settings returns the settings the navigation mesh was baked with
+func (m *NavMesh) Settings() NavMeshSettings { return m.settings }
+
+// PolygonCount returns the number of walkable polygons in the mesh
+func (m *NavMesh) PolygonCount() int { return len(m.polys) }
+
+// Polygon returns the corners of the polygon, this is mostly for drawing
+// the navigation mesh while debugging
+func (m *NavMesh) Polygon(index int) [4]matrix.Vec3 {
+	p := &m.polys[index]
+	return [4]matrix.Vec3{
+		matrix.NewVec3(p.min.X(), p.heights[0], p.min.Y()),
+		matrix.NewVec3(p.max.X(), p.heights[1], p.min.Y()),
+		matrix.NewVec3(p.max.X(), p.heights[2], p.max.Y()),
+		matrix.NewVec3(p.min.X(), p.heights[3], p.max.Y()),
+	}
+}
+
+// FindNearest finds the closest point on the navigation mesh to the point.
+// Only polygons within the half extents of the point are checked.
+func (m *NavMesh) FindNearest(point, extents matrix.Vec3) (matrix.Vec3, bool) {
+	_, p, ok := m.nearestPoly(point, extents)
+	return p, ok
+}
+
+func (m *NavMesh) queryExtents() matrix.Vec3 {
+	xz := m.settings.AgentRadius + m.settings.CellSize*2
+	return matrix.NewVec3(xz, m.settings.AgentHeight, xz)
+}
+
+func (m *NavMesh) nearestPoly(point, extents matrix.Vec3) (int32, matrix.Vec3, bool) {
+	cs := m.settings.CellSize
+	x0 := max(0, int32(matrix.Floor((point.X()-extents.X()-m.origin.X())/cs)))
+	x1 := min(m.width-1, int32(matrix.Floor((point.X()+extents.X()-m.origin.X())/cs)))
+	z0 := max(0, int32(matrix.Floor((point.Z()-extents.Z()-m.origin.Z())/cs)))
+	z1 := min(m.depth-1, int32(matrix.Floor((point.Z()+extents.Z()-m.origin.Z())/cs)))
+	best := int32(-1)
+	bestDist := matrix.Float(math.MaxFloat32)
+	var bestPoint matrix.Vec3
+	for z := z0; z <= z1; z++ {
+		for x := x0; x <= x1; x++ {
+			col := z*m.width + x
+			for _, pi := range m.colPolys[m.colStart[col]:m.colStart[col+1]] {
+				if pi == best {
+					continue
+				}
+				p := m.polys[pi].closest(point)
+				if matrix.Abs(p.Y()-point.Y()) > extents.Y() {
+					continue
+				}
+				if d := p.SquareDistance(point); d < bestDist {
+					best, bestDist, bestPoint = pi, d, p
+				}
+			}
+		}
+	}
+	return best, bestPoint, best >= 0
+}
+
+func (p *navPoly) closest(point matrix.Vec3) matrix.Vec3 {
+	x := matrix.Clamp(point.X(), p.min.X(), p.max.X())
+	z := matrix.Clamp(point.Z(), p.min.Y(), p.max.Y())
+	return matrix.NewVec3(x, p.heightAt(x, z), z)
+}
+
+func (p *navPoly) heightAt(x, z matrix.Float) matrix.Float {
+	u := matrix.Clamp((x-p.min.X())/(p.max.X()-p.min.X()), 0, 1)
+	v := matrix.Clamp((z-p.min.Y())/(p.max.Y()-p.min.Y()), 0, 1)
+	near := matrix.Lerp(p.heights[0], p.heights[1], u)
+	far := matrix.Lerp(p.heights[3], p.heights[2], u)
+	return matrix.Lerp(near, far, v)
+}
+
+func (m *NavMesh) polyLinks(poly int32) []navLink {
+	p := &m.polys[poly]
+	return m.links[p.firstLink : p.firstLink+p.linkCount]
+}
@@ -0,0 +1,554 @@
+/******************************************************************************/
+/* nav_mesh_bake.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"slices"
+
+	"kaijuengine.com/engine/graviton"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering"
+)
+
+const (
+	// navMaxPolyCells limits how many cells wide or deep a single polygon can
+	// be, so that the polygon centers stay a reasonable guide for the search
+	navMaxPolyCells = 32
+	// navMaxGridCells guards against baking with a cell size that is far too
+	// small for the size of the level
+	navMaxGridCells = 1 << 26
+)
+
+const (
+	navDirNegX = iota
+	navDirPosZ
+	navDirPosX
+	navDirNegZ
+)
+
+var (
+	navDirX = [4]int32{-1, 0, 1, 0}
+	navDirZ = [4]int32{0, 1, 0, -1}
+)
+
+// BakeInput collects the static geometry, in world space, that a navigation
+// mesh is baked from. Triangles can be wound either way.
+type BakeInput struct {
+	points []matrix.Vec3
+}
+
+// AddTriangle adds a single triangle
+func (b *BakeInput) AddTriangle(p0, p1, p2 matrix.Vec3) {
+	b.points = append(b.points, p0, p1, p2)
+}
+
+// AddMesh adds the triangles of the mesh data, the transform can be nil if
+// the vertices are already in world space
+func (b *BakeInput) AddMesh(verts []rendering.Vertex, indexes []uint32, transform *matrix.Transform) {
+	world := matrix.Mat4Identity()
+	if transform != nil {
+		world = transform.WorldMatrix()
+	}
+	for i := 0; i+2 < len(indexes); i += 3 {
+		b.AddTriangle(
+			world.TransformPoint(verts[indexes[i]].Position),
+			world.TransformPoint(verts[indexes[i+1]].Position),
+			world.TransformPoint(verts[indexes[i+2]].Position))
+	}
+}
+
+// AddTerrain adds the triangles of the terrain collision, the transform is
+// the terrain's transform and can be nil if the terrain is at the origin
+func (b *BakeInput) AddTerrain(collision *graviton.TerrainCollision, transform *matrix.Transform) {
+	if collision == nil || collision.Resolution < 2 {
+		return
+	}
+	world := matrix.Mat4Identity()
+	if transform != nil {
+		world = transform.WorldMatrix()
+	}
+	point := func(x, z int) matrix.Vec3 {
+		return world.TransformPoint(collision.GridToLocal(matrix.Float(x), matrix.Float(z)))
+	}
+	for z := 0; z < collision.Resolution-1; z++ {
+		for x := 0; x < collision.Resolution-1; x++ {
+			p0, p1 := point(x, z), point(x, z+1)
+			p2, p3 := point(x+1, z+1), point(x+1, z)
+			b.AddTriangle(p0, p1, p2)
+			b.AddTriangle(p0, p2, p3)
+		}
+	}
+}
+
+// TriangleCount returns the number of triangles that have been added
+func (b *BakeInput) TriangleCount() int { return len(b.points) / 3 }
+
+type navSpan struct {
+	min, max int32
+	walkable bool
+}
+
+type navCell struct {
+	x, z    int32
+	y, ceil int32
+	links   [4]int32
+}
+
+type navBuilder struct {
+	settings    NavMeshSettings
+	origin      matrix.Vec3
+	width       int32
+	depth       int32
+	columns     [][]navSpan
+	cells       []navCell
+	colStart    []int32
+	owner       []int32
+	climb       int32
+	clearance   int32
+	erodeRadius int32
+}
+
+// BakeNavMesh voxelizes the input geometry and builds the walkable polygons
+// for an agent with the given settings. Surfaces steeper than the max slope,
+// without enough head room for the agent, or within the agent's radius of a
+// wall or ledge are left out. Neighboring surfaces are connected when the
+// height between them is no more than the max step.
+func BakeNavMesh(input *BakeInput, settings NavMeshSettings) (*NavMesh, error) {
+	defer tracing.NewRegion("navigation.BakeNavMesh").End()
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+	if input == nil || input.TriangleCount() == 0 {
+		return nil, ErrNavMeshNoGeometry
+	}
+	b := &navBuilder{
+		settings:    settings,
+		climb:       int32(matrix.Floor(settings.MaxStep / settings.CellHeight)),
+		clearance:   int32(matrix.Ceil(settings.AgentHeight / settings.CellHeight)),
+		erodeRadius: int32(matrix.Ceil(settings.AgentRadius / settings.CellSize)),
+	}
+	if err := b.rasterize(input.points); err != nil {
+		return nil, err
+	}
+	b.filterLowObstacles()
+	b.buildCells()
+	b.erode()
+	return b.buildMesh(), nil
+}
+
+func (b *navBuilder) rasterize(points []matrix.Vec3) error {
+	defer tracing.NewRegion("navigation.navBuilder.rasterize").End()
+	bMin, bMax := points[0], points[0]
+	for i := 1; i < len(points); i++ {
+		bMin = matrix.Vec3Min(bMin, points[i])
+		bMax = matrix.Vec3Max(bMax, points[i])
+	}
+	cs, ch := b.settings.CellSize, b.settings.CellHeight
+	b.origin = bMin
+	b.width = max(1, int32(matrix.Ceil((bMax.X()-bMin.X())/cs)))
+	b.depth = max(1, int32(matrix.Ceil((bMax.Z()-bMin.Z())/cs)))
+	if int64(b.width)*int64(b.depth) > navMaxGridCells {
+		return errors.New("the navigation mesh bake area is too large for the cell size")
+	}
+	b.columns = make([][]navSpan, b.width*b.depth)
+	walkableY := matrix.Cos(matrix.Deg2Rad(b.settings.MaxSlope))
+	var tri, rowA, rowB, cellA, cellB [7]matrix.Vec3
+	for i := 0; i+2 < len(points); i += 3 {
+		p0, p1, p2 := points[i], points[i+1], points[i+2]
+		normal := p1.Subtract(p0).Cross(p2.Subtract(p0)).Normal()
+		walkable := matrix.Abs(normal.Y()) >= walkableY
+		tMin := matrix.Vec3Min(p0, p1, p2)
+		tMax := matrix.Vec3Max(p0, p1, p2)
+		z0 := clampCell((tMin.Z()-b.origin.Z())/cs, b.depth)
+		z1 := clampCell((tMax.Z()-b.origin.Z())/cs, b.depth)
+		poly := append(tri[:0], p0, p1, p2)
+		for z := z0; z <= z1; z++ {
+			cz := b.origin.Z() + matrix.Float(z)*cs
+			row := clipPoly(poly, matrix.Vz, cz, true, rowA[:0])
+			row = clipPoly(row, matrix.Vz, cz+cs, false, rowB[:0])
+			if len(row) < 3 {
+				continue
+			}
+			rMin, rMax := row[0].X(), row[0].X()
+			for j := 1; j < len(row); j++ {
+				rMin, rMax = min(rMin, row[j].X()), max(rMax, row[j].X())
+			}
+			x0 := clampCell((rMin-b.origin.X())/cs, b.width)
+			x1 := clampCell((rMax-b.origin.X())/cs, b.width)
+			for x := x0; x <= x1; x++ {
+				cx := b.origin.X() + matrix.Float(x)*cs
+				cell := clipPoly(row, matrix.Vx, cx, true, cellA[:0])
+				cell = clipPoly(cell, matrix.Vx, cx+cs, false, cellB[:0])
+				if len(cell) < 3 {
+					continue
+				}
+				yMin, yMax := cell[0].Y(), cell[0].Y()
+				for j := 1; j < len(cell); j++ {
+					yMin, yMax = min(yMin, cell[j].Y()), max(yMax, cell[j].Y())
+				}
+				sMin := int32(matrix.Floor((yMin - b.origin.Y()) / ch))
+				sMax := max(sMin+1, int32(matrix.Ceil((yMax-b.origin.Y())/ch)))
+				b.addSpan(z*b.width+x, navSpan{sMin, sMax, walkable})
+			}
+		}
+	}
+	return nil
+}
+
+func clampCell(v matrix.Float, count int32) int32 {
+	return max(0, min(count-1, int32(matrix.Floor(v))))
+}
+
+// clipPoly clips the convex polygon against the plane on the axis at v,
+// keeping the side above or below it
+func clipPoly(in []matrix.Vec3, axis int, v matrix.Float, keepAbove bool, out []matrix.Vec3) []matrix.Vec3 {
+	for i := range in {
+		a, c := in[i], in[(i+1)%len(in)]
+		da, dc := a[axis]-v, c[axis]-v
+		if !keepAbove {
+			da, dc = -da, -dc
+		}
+		if da >= 0 {
+			out = append(out, a)
+		}
+		if (da >= 0) != (dc >= 0) {
+			out = append(out, a.Add(c.Subtract(a).Scale(da/(da-dc))))
+		}
+	}
+	return out
+}
+
+// addSpan adds the span to the column, merging it with any spans that it
+// overlaps. When spans are merged the walkable flag of the top most surface
+// is kept.
+func (b *navBuilder) addSpan(col int32, s navSpan) {
+	spans := b.columns[col]
+	i := 0
+	for i < len(spans) && spans[i].max < s.min {
+		i++
+	}
+	j := i
+	for ; j < len(spans) && spans[j].min <= s.max; j++ {
+		o := spans[j]
+		if matrix.Abs(o.max-s.max) <= 1 {
+			s.walkable = s.walkable || o.walkable
+		} else if o.max > s.max {
+			s.walkable = o.walkable
+		}
+		s.min, s.max = min(s.min, o.min), max(s.max, o.max)
+	}
+	b.columns[col] = slices.Replace(spans, i, j, s)
+}
+
+// filterLowObstacles allows the agent to step onto low obstacles, such as
+// curbs and stairs, that are too steep to walk on but within a step of the
+// walkable surface below them
+func (b *navBuilder) filterLowObstacles() {
+	for _, spans := range b.columns {
+		prevWalkable := false
+		prevMax := int32(0)
+		for i := range spans {
+			walkable := spans[i].walkable
+			if !walkable && prevWalkable && spans[i].max-prevMax <= b.climb {
+				spans[i].walkable = true
+			}
+			prevWalkable, prevMax = walkable, spans[i].max
+		}
+	}
+}
+
+// buildCells turns the tops of the walkable spans that have enough head room
+// into cells and links each cell to the cells next to it that can be stepped
+// onto
+func (b *navBuilder) buildCells() {
+	defer tracing.NewRegion("navigation.navBuilder.buildCells").End()
+	b.colStart = make([]int32, len(b.columns)+1)
+	for col, spans := range b.columns {
+		b.colStart[col] = int32(len(b.cells))
+		for i := range spans {
+			if !spans[i].walkable {
+				continue
+			}
+			ceil := int32(math.MaxInt32)
+			if i+1 < len(spans) {
+				ceil = spans[i+1].min
+			}
+			if ceil-spans[i].max < b.clearance {
+				continue
+			}
+			b.cells = append(b.cells, navCell{
+				x:     int32(col) % b.width,
+				z:     int32(col) / b.width,
+				y:     spans[i].max,
+				ceil:  ceil,
+				links: [4]int32{-1, -1, -1, -1},
+			})
+		}
+	}
+	b.colStart[len(b.columns)] = int32(len(b.cells))
+	b.columns = nil
+	for ci := range b.cells {
+		c := &b.cells[ci]
+		for d := range 4 {
+			nx, nz := c.x+navDirX[d], c.z+navDirZ[d]
+			if nx < 0 || nz < 0 || nx >= b.width || nz >= b.depth {
+				continue
+			}
+			col := nz*b.width + nx
+			for ni := b.colStart[col]; ni < b.colStart[col+1]; ni++ {
+				n := &b.cells[ni]
+				if matrix.Abs(n.y-c.y) <= b.climb &&
+					min(c.ceil, n.ceil)-max(c.y, n.y) >= b.clearance {
+					c.links[d] = ni
+					break
+				}
+			}
+		}
+	}
+}
+
+// erode removes the cells that are closer than the agent radius to the edge
+// of the walkable area, using a chamfer distance where a straight step is 2
+// and a diagonal step is 3
+func (b *navBuilder) erode() {
+	defer tracing.NewRegion("navigation.navBuilder.erode").End()
+	b.owner = make([]int32, len(b.cells))
+	for i := range b.owner {
+		b.owner[i] = -1
+	}
+	if b.erodeRadius <= 0 {
+		return
+	}
+	dist := make([]int32, len(b.cells))
+	for i := range b.cells {
+		dist[i] = math.MaxInt16
+		for _, l := range b.cells[i].links {
+			if l < 0 {
+				dist[i] = 0
+				break
+			}
+		}
+	}
+	relax := func(ci int32, d, diag int) {
+		n := b.cells[ci].links[d]
+		if n < 0 {
+			return
+		}
+		dist[ci] = min(dist[ci], dist[n]+2)
+		if nn := b.cells[n].links[diag]; nn >= 0 {
+			dist[ci] = min(dist[ci], dist[nn]+3)
+		}
+	}
+	for ci := int32(0); ci < int32(len(b.cells)); ci++ {
+		relax(ci, navDirNegX, navDirNegZ)
+		relax(ci, navDirNegZ, navDirPosX)
+	}
+	for ci := int32(len(b.cells)) - 1; ci >= 0; ci-- {
+		relax(ci, navDirPosX, navDirPosZ)
+		relax(ci, navDirPosZ, navDirNegX)
+	}
+	threshold := b.erodeRadius * 2
+	for ci := range b.cells {
+		if dist[ci] >= threshold {
+			continue
+		}
+		b.owner[ci] = -2
+		c := &b.cells[ci]
+		for d, n := range c.links {
+			if n >= 0 {
+				b.cells[n].links[(d+2)%4] = -1
+				c.links[d] = -1
+			}
+		}
+	}
+}
+
+// buildMesh greedily merges the remaining cells into rectangles that lie
+// close to a plane, and then links the rectangles that share an edge
+func (b *navBuilder) buildMesh() *NavMesh {
+	defer tracing.NewRegion("navigation.navBuilder.buildMesh").End()
+	m := &NavMesh{
+		settings: b.settings,
+		origin:   b.origin,
+		width:    b.width,
+		depth:    b.depth,
+	}
+	rect := make([]int32, 0, navMaxPolyCells*navMaxPolyCells)
+	for seed := range b.cells {
+		if b.owner[seed] != -1 {
+			continue
+		}
+		rect, width, rows := b.growRect(int32(seed), rect[:0])
+		pi := int32(len(m.polys))
+		for _, ci := range rect {
+			b.owner[ci] = pi
+		}
+		first := &b.cells[rect[0]]
+		last := &b.cells[rect[len(rect)-1]]
+		cs, ch := b.settings.CellSize, b.settings.CellHeight
+		height := func(ci int32) matrix.Float {
+			return b.origin.Y() + matrix.Float(b.cells[ci].y)*ch
+		}
+		m.polys = append(m.polys, navPoly{
+			min: matrix.NewVec2(b.origin.X()+matrix.Float(first.x)*cs,
+				b.origin.Z()+matrix.Float(first.z)*cs),
+			max: matrix.NewVec2(b.origin.X()+matrix.Float(last.x+1)*cs,
+				b.origin.Z()+matrix.Float(last.z+1)*cs),
+			heights: [4]matrix.Float{
+				height(rect[0]),
+				height(rect[width-1]),
+				height(rect[len(rect)-1]),
+				height(rect[(rows-1)*width]),
+			},
+		})
+	}
+	// The column index only holds the cells that were not eroded away
+	m.colStart = make([]int32, len(b.colStart))
+	m.colPolys = make([]int32, 0, len(b.cells))
+	for col := range len(b.colStart) - 1 {
+		m.colStart[col] = int32(len(m.colPolys))
+		for ci := b.colStart[col]; ci < b.colStart[col+1]; ci++ {
+			if b.owner[ci] >= 0 {
+				m.colPolys = append(m.colPolys, b.owner[ci])
+			}
+		}
+	}
+	m.colStart[len(m.colStart)-1] = int32(len(m.colPolys))
+	b.buildLinks(m)
+	m.queries.New = func() any { return &navQuery{} }
+	return m
+}
+
+// growRect grows a rectangle of unclaimed cells from the seed, first along
+// +x and then row by row along +z. It returns the cells of the rectangle in
+// row order along with its width and number of rows.
+func (b *navBuilder) growRect(seed int32, rect []int32) ([]int32, int, int) {
+	free := func(ci int32) bool { return ci >= 0 && b.owner[ci] == -1 }
+	y := func(ci int32) matrix.Float { return matrix.Float(b.cells[ci].y) }
+	// onLine checks that the next cell continues the slope of the cells
+	// before it, so that the rectangle stays close to a plane
+	onLine := func(line []int32, stride int, next int32) bool {
+		count := (len(line)-1)/stride + 1
+		if count < 2 {
+			return true
+		}
+		y0 := y(line[0])
+		slope := (y(line[(count-1)*stride]) - y0) / matrix.Float(count-1)
+		return matrix.Abs(y(next)-(y0+slope*matrix.Float(count))) <= 1
+	}
+	rect = append(rect, seed)
+	for len(rect) < navMaxPolyCells {
+		next := b.cells[rect[len(rect)-1]].links[navDirPosX]
+		if !free(next) || !onLine(rect, 1, next) {
+			break
+		}
+		rect = append(rect, next)
+	}
+	width := len(rect)
+	rows := 1
+	for rows < navMaxPolyCells {
+		prev := rect[len(rect)-width:]
+		first := b.cells[prev[0]].links[navDirPosZ]
+		if !free(first) || !onLine(rect[:len(rect)-width+1], width, first) {
+			break
+		}
+		rise := y(first) - y(rect[0])
+		row := append(rect, first)
+		ok := true
+		for i := 1; i < width && ok; i++ {
+			next := b.cells[row[len(row)-1]].links[navDirPosX]
+			ok = free(next) && b.cells[prev[i]].links[navDirPosZ] == next &&
+				matrix.Abs(y(next)-(y(rect[i])+rise)) <= 1
+			row = append(row, next)
+		}
+		if !ok {
+			break
+		}
+		rect = row
+		rows++
+	}
+	return rect, width, rows
+}
+
+// buildLinks creates the portals between the polygons, a portal covers the
+// part of the shared edge where the cells on both sides are linked
+func (b *navBuilder) buildLinks(m *NavMesh) {
+	type portalKey struct {
+		from, to int32
+		dir      int
+	}
+	type portalSpan struct {
+		edge, lo, hi int32
+	}
+	portals := map[portalKey]portalSpan{}
+	for ci := range b.cells {
+		c := &b.cells[ci]
+		from := b.owner[ci]
+		if from < 0 {
+			continue
+		}
+		for d, n := range c.links {
+			if n < 0 || b.owner[n] == from {
+				continue
+			}
+			key := portalKey{from, b.owner[n], d}
+			edge, along := c.x, c.z
+			if d == navDirPosZ || d == navDirNegZ {
+				edge, along = c.z, c.x
+			}
+			if p, ok := portals[key]; ok {
+				portals[key] = portalSpan{edge, min(p.lo, along), max(p.hi, along)}
+			} else {
+				portals[key] = portalSpan{edge, along, along}
+			}
+		}
+	}
+	keys := make([]portalKey, 0, len(portals))
+	for k := range portals {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b portalKey) int {
+		return cmp.Or(cmp.Compare(a.from, b.from), cmp.Compare(a.to, b.to), cmp.Compare(a.dir, b.dir))
+	})
+	cs := b.settings.CellSize
+	m.links = make([]navLink, 0, len(keys))
+	for _, k := range keys {
+		p := portals[k]
+		from, to := &m.polys[k.from], &m.polys[k.to]
+		point := func(x, z matrix.Float) matrix.Vec3 {
+			y := (from.heightAt(x, z) + to.heightAt(x, z)) * 0.5
+			return matrix.NewVec3(x, y, z)
+		}
+		edge, lo, hi := matrix.Float(p.edge), matrix.Float(p.lo)*cs, matrix.Float(p.hi+1)*cs
+		var left, right matrix.Vec3
+		// Left and right are as seen when moving through the portal in the
+		// direction of the link
+		switch k.dir {
+		case navDirPosX:
+			x := b.origin.X() + (edge+1)*cs
+			left, right = point(x, b.origin.Z()+hi), point(x, b.origin.Z()+lo)
+		case navDirNegX:
+			x := b.origin.X() + edge*cs
+			left, right = point(x, b.origin.Z()+lo), point(x, b.origin.Z()+hi)
+		case navDirPosZ:
+			z := b.origin.Z() + (edge+1)*cs
+			left, right = point(b.origin.X()+lo, z), point(b.origin.X()+hi, z)
+		case navDirNegZ:
+			z := b.origin.Z() + edge*cs
+			left, right = point(b.origin.X()+hi, z), point(b.origin.X()+lo, z)
+		}
+		if from.linkCount == 0 {
+			from.firstLink = int32(len(m.links))
+		}
+		from.linkCount++
+		m.links = append(m.links, navLink{to: k.to, left: left, right: right})
+	}
+}
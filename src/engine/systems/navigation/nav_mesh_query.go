@@ -0,0 +1,227 @@
+/******************************************************************************/
+/* nav_mesh_query.go                                                          */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+)
+
+// navQuery holds the search state of a single path query. Queries are pooled
+// by the navigation mesh so that searches don't allocate per polygon and
+// multiple searches can run at the same time.
+type navQuery struct {
+	generation uint32
+	visited    []uint32
+	closed     []uint32
+	cost       []matrix.Float
+	parent     []int32
+	entry      []matrix.Vec3
+	open       []navOpenNode
+	corridor   []int32
+	lefts      []matrix.Vec3
+	rights     []matrix.Vec3
+}
+
+type navOpenNode struct {
+	total matrix.Float
+	poly  int32
+}
+
+// FindPath finds the shortest path across the navigation mesh between the
+// points. The points are moved onto the navigation mesh if they are slightly
+// off of it, and the returned path starts and ends with the moved points. The
+// path is string pulled, so it only has points where the path turns around a
+// corner. It is safe to call from multiple goroutines at the same time.
+func (m *NavMesh) FindPath(start, end matrix.Vec3) ([]matrix.Vec3, error) {
+	defer tracing.NewRegion("NavMesh.FindPath").End()
+	extents := m.queryExtents()
+	startPoly, start, ok := m.nearestPoly(start, extents)
+	if !ok {
+		return nil, ErrPointOffNavMesh
+	}
+	endPoly, end, ok := m.nearestPoly(end, extents)
+	if !ok {
+		return nil, ErrPointOffNavMesh
+	}
+	q := m.queries.Get().(*navQuery)
+	defer m.queries.Put(q)
+	if !q.search(m, startPoly, endPoly, start, end) {
+		return nil, ErrNoNavPath
+	}
+	return q.stringPull(m, start, end), nil
+}
+
+func (q *navQuery) begin(polyCount int) {
+	if len(q.visited) < polyCount {
+		q.visited = make([]uint32, polyCount)
+		q.closed = make([]uint32, polyCount)
+		q.cost = make([]matrix.Float, polyCount)
+		q.parent = make([]int32, polyCount)
+		q.entry = make([]matrix.Vec3, polyCount)
+		q.generation = 0
+	}
+	q.generation++
+	if q.generation == 0 {
+		clear(q.visited)
+		clear(q.closed)
+		q.generation = 1
+	}
+	q.open = q.open[:0]
+}
+
+// search runs A* across the polygons, entering each polygon at the middle of
+// the portal that it was reached through. The corridor of polygons from the
+// start to the end is left in the query.
+func (q *navQuery) search(m *NavMesh, startPoly, endPoly int32, start, end matrix.Vec3) bool {
+	q.begin(len(m.polys))
+	gen := q.generation
+	q.visited[startPoly] = gen
+	q.cost[startPoly] = 0
+	q.parent[startPoly] = -1
+	q.entry[startPoly] = start
+	q.push(navOpenNode{start.Distance(end), startPoly})
+	found := false
+	for len(q.open) > 0 {
+		current := q.pop().poly
+		if q.closed[current] == gen {
+			continue
+		}
+		q.closed[current] = gen
+		if current == endPoly {
+			found = true
+			break
+		}
+		for _, l := range m.polyLinks(current) {
+			if q.closed[l.to] == gen {
+				continue
+			}
+			mid := l.left.Add(l.right).Scale(0.5)
+			cost := q.cost[current] + q.entry[current].Distance(mid)
+			remaining := mid.Distance(end)
+			if l.to == endPoly {
+				cost += remaining
+				remaining = 0
+			}
+			if q.visited[l.to] == gen && cost >= q.cost[l.to] {
+				continue
+			}
+			q.visited[l.to] = gen
+			q.cost[l.to] = cost
+			q.parent[l.to] = current
+			q.entry[l.to] = mid
+			q.push(navOpenNode{cost + remaining, l.to})
+		}
+	}
+	if !found {
+		return false
+	}
+	q.corridor = q.corridor[:0]
+	for p := endPoly; p >= 0; p = q.parent[p] {
+		q.corridor = append(q.corridor, p)
+	}
+	for i, j := 0, len(q.corridor)-1; i < j; i, j = i+1, j-1 {
+		q.corridor[i], q.corridor[j] = q.corridor[j], q.corridor[i]
+	}
+	return true
+}
+
+func (q *navQuery) push(n navOpenNode) {
+	q.open = append(q.open, n)
+	for i := len(q.open) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if q.open[parent].total <= q.open[i].total {
+			break
+		}
+		q.open[parent], q.open[i] = q.open[i], q.open[parent]
+		i = parent
+	}
+}
+
+func (q *navQuery) pop() navOpenNode {
+	top := q.open[0]
+	last := len(q.open) - 1
+	q.open[0] = q.open[last]
+	q.open = q.open[:last]
+	for i := 0; ; {
+		smallest := i
+		if l := 2*i + 1; l < last && q.open[l].total < q.open[smallest].total {
+			smallest = l
+		}
+		if r := 2*i + 2; r < last && q.open[r].total < q.open[smallest].total {
+			smallest = r
+		}
+		if smallest == i {
+			break
+		}
+		q.open[i], q.open[smallest] = q.open[smallest], q.open[i]
+		i = smallest
+	}
+	return top
+}
+
+// stringPull runs the simple stupid funnel algorithm through the portals of
+// the corridor to find the corners of the shortest path
+func (q *navQuery) stringPull(m *NavMesh, start, end matrix.Vec3) []matrix.Vec3 {
+	q.lefts = append(q.lefts[:0], start)
+	q.rights = append(q.rights[:0], start)
+	for i := 0; i+1 < len(q.corridor); i++ {
+		for _, l := range m.polyLinks(q.corridor[i]) {
+			if l.to == q.corridor[i+1] {
+				q.lefts = append(q.lefts, l.left)
+				q.rights = append(q.rights, l.right)
+				break
+			}
+		}
+	}
+	q.lefts = append(q.lefts, end)
+	q.rights = append(q.rights, end)
+	path := []matrix.Vec3{start}
+	apex, left, right := start, start, start
+	leftIndex, rightIndex := 0, 0
+	restart := func(corner matrix.Vec3, index int) int {
+		if !navSamePoint(path[len(path)-1], corner) {
+			path = append(path, corner)
+		}
+		apex, left, right = corner, corner, corner
+		leftIndex, rightIndex = index, index
+		return index
+	}
+	for i := 1; i < len(q.lefts); i++ {
+		l, r := q.lefts[i], q.rights[i]
+		if navTriArea2(apex, right, r) <= 0 {
+			if navSamePoint(apex, right) || navTriArea2(apex, left, r) > 0 {
+				right, rightIndex = r, i
+			} else {
+				i = restart(left, leftIndex)
+				continue
+			}
+		}
+		if navTriArea2(apex, left, l) >= 0 {
+			if navSamePoint(apex, left) || navTriArea2(apex, right, l) < 0 {
+				left, leftIndex = l, i
+			} else {
+				i = restart(right, rightIndex)
+				continue
+			}
+		}
+	}
+	if !navSamePoint(path[len(path)-1], end) {
+		path = append(path, end)
+	}
+	return path
+}
+
+func navTriArea2(a, b, c matrix.Vec3) matrix.Float {
+	return (c.X()-a.X())*(b.Z()-a.Z()) - (b.X()-a.X())*(c.Z()-a.Z())
+}
+
+func navSamePoint(a, b matrix.Vec3) bool {
+	const epsilon = 0.0001
+	dx, dz := a.X()-b.X(), a.Z()-b.Z()
+	return dx*dx+dz*dz < epsilon*epsilon
+}
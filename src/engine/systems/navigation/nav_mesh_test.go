@@ -0,0 +1,212 @@
+/******************************************************************************/
+/* nav_mesh_test.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"kaijuengine.com/engine/graviton"
+	"kaijuengine.com/matrix"
+)
+
+func addQuad(in *BakeInput, minX, minZ, maxX, maxZ, y matrix.Float) {
+	p0 := matrix.NewVec3(minX, y, minZ)
+	p1 := matrix.NewVec3(maxX, y, minZ)
+	p2 := matrix.NewVec3(maxX, y, maxZ)
+	p3 := matrix.NewVec3(minX, y, maxZ)
+	in.AddTriangle(p0, p1, p2)
+	in.AddTriangle(p0, p2, p3)
+}
+
+func addBox(in *BakeInput, min, max matrix.Vec3) {
+	c := [8]matrix.Vec3{}
+	for i := range c {
+		c[i] = matrix.NewVec3(
+			[2]matrix.Float{min.X(), max.X()}[i&1],
+			[2]matrix.Float{min.Y(), max.Y()}[(i>>1)&1],
+			[2]matrix.Float{min.Z(), max.Z()}[(i>>2)&1])
+	}
+	faces := [6][4]int{
+		{0, 1, 3, 2}, {4, 6, 7, 5}, {0, 4, 5, 1},
+		{2, 3, 7, 6}, {0, 2, 6, 4}, {1, 5, 7, 3},
+	}
+	for _, f := range faces {
+		in.AddTriangle(c[f[0]], c[f[1]], c[f[2]])
+		in.AddTriangle(c[f[0]], c[f[2]], c[f[3]])
+	}
+}
+
+func pathLength(path []matrix.Vec3) matrix.Float {
+	length := matrix.Float(0)
+	for i := 1; i < len(path); i++ {
+		length += path[i-1].Distance(path[i])
+	}
+	return length
+}
+
+func wallLevel(t *testing.T) *NavMesh {
+	t.Helper()
+	in := &BakeInput{}
+	addQuad(in, 0, 0, 20, 20, 0)
+	addBox(in, matrix.NewVec3(8, 0, 0), matrix.NewVec3(12, 3, 14))
+	m, err := BakeNavMesh(in, DefaultNavMeshSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestNavMeshPathAroundWall(t *testing.T) {
+	m := wallLevel(t)
+	if m.PolygonCount() == 0 {
+		t.Fatal("expected walkable polygons")
+	}
+	start, end := matrix.NewVec3(3, 0, 3), matrix.NewVec3(17, 0, 3)
+	path, err := m.FindPath(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[0].Distance(start) > 0.5 || path[len(path)-1].Distance(end) > 0.5 {
+		t.Fatalf("path should start and end at the points, got %v", path)
+	}
+	// The path has to go around the end of the wall at z 14, pulled tight
+	// that is only a couple of corners
+	if len(path) < 3 || len(path) > 6 {
+		t.Fatalf("expected a string pulled path with a few corners, got %v", path)
+	}
+	if l := pathLength(path); l < 2*11+4 || l > 35 {
+		t.Fatalf("unexpected path length %f for %v", l, path)
+	}
+	radius := m.Settings().AgentRadius
+	for i := 1; i < len(path); i++ {
+		for s := matrix.Float(0); s <= 1; s += 0.05 {
+			p := matrix.Vec3Lerp(path[i-1], path[i], s)
+			if p.X() > 8-radius+0.01 && p.X() < 12+radius-0.01 && p.Z() < 14+radius-0.01 {
+				t.Fatalf("path goes through the wall at %v: %v", p, path)
+			}
+			if matrix.Abs(p.Y()) > 0.5 {
+				t.Fatalf("path should stay on the floor, got %v", p)
+			}
+		}
+	}
+}
+
+func TestNavMeshStepAndSlope(t *testing.T) {
+	settings := DefaultNavMeshSettings()
+	in := &BakeInput{}
+	addQuad(in, 0, 0, 10, 10, 0)
+	addQuad(in, 10, 0, 20, 10, 0.3)
+	addQuad(in, 20, 0, 30, 10, 2)
+	m, err := BakeNavMesh(in, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.FindPath(matrix.NewVec3(2, 0, 5), matrix.NewVec3(18, 0.3, 5)); err != nil {
+		t.Fatalf("expected the step up to be walkable: %v", err)
+	}
+	_, err = m.FindPath(matrix.NewVec3(2, 0, 5), matrix.NewVec3(28, 2, 5))
+	if !errors.Is(err, ErrNoNavPath) {
+		t.Fatalf("expected the ledge to be too high, got %v", err)
+	}
+	steep := &BakeInput{}
+	steep.AddTriangle(matrix.NewVec3(0, 0, 0), matrix.NewVec3(10, 0, 0), matrix.NewVec3(10, 17, 10))
+	steep.AddTriangle(matrix.NewVec3(0, 0, 0), matrix.NewVec3(10, 17, 10), matrix.NewVec3(0, 17, 10))
+	if m, err = BakeNavMesh(steep, settings); err != nil {
+		t.Fatal(err)
+	}
+	if m.PolygonCount() != 0 {
+		t.Fatalf("a 60 degree slope should not be walkable, got %d polygons", m.PolygonCount())
+	}
+}
+
+func TestNavMeshClearance(t *testing.T) {
+	in := &BakeInput{}
+	addQuad(in, 0, 0, 20, 10, 0)
+	addBox(in, matrix.NewVec3(8, 1.2, -1), matrix.NewVec3(12, 1.4, 11))
+	m, err := BakeNavMesh(in, DefaultNavMeshSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.FindNearest(matrix.NewVec3(10, 0, 5), matrix.NewVec3(0.1, 0.5, 0.1)); ok {
+		t.Fatal("the floor under the low ceiling should not be walkable")
+	}
+	_, err = m.FindPath(matrix.NewVec3(2, 0, 5), matrix.NewVec3(18, 0, 5))
+	if !errors.Is(err, ErrNoNavPath) {
+		t.Fatalf("expected no path under the low ceiling, got %v", err)
+	}
+	if _, err = m.FindPath(matrix.NewVec3(50, 0, 5), matrix.NewVec3(2, 0, 5)); !errors.Is(err, ErrPointOffNavMesh) {
+		t.Fatalf("expected the start to be off the mesh, got %v", err)
+	}
+}
+
+func TestNavMeshTerrain(t *testing.T) {
+	const res = 33
+	heights := make([]matrix.Float, res*res)
+	for z := range res {
+		for x := range res {
+			heights[z*res+x] = matrix.Float(x) * 0.1
+		}
+	}
+	collision, err := graviton.NewTerrainCollision(res, matrix.NewVec2(32, 32), heights, 0, 3.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := &BakeInput{}
+	in.AddTerrain(collision, nil)
+	m, err := BakeNavMesh(in, DefaultNavMeshSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := matrix.NewVec3(-12, 0.4, -12), matrix.NewVec3(12, 2.8, 12)
+	path, err := m.FindPath(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := pathLength(path); l > start.Distance(end)*1.05 {
+		t.Fatalf("the path across open terrain should be nearly straight, got %f for %v", l, path)
+	}
+	for _, p := range path {
+		ground := collision.HeightAtLocal(matrix.NewVec2(p.X(), p.Z()))
+		if matrix.Abs(p.Y()-ground) > 0.5 {
+			t.Fatalf("path point %v is not on the terrain at height %f", p, ground)
+		}
+	}
+}
+
+func TestNavMeshConcurrentQueries(t *testing.T) {
+	m := wallLevel(t)
+	want, err := m.FindPath(matrix.NewVec3(3, 0, 3), matrix.NewVec3(17, 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				path, err := m.FindPath(matrix.NewVec3(3, 0, 3), matrix.NewVec3(17, 0, 3))
+				if err != nil || len(path) != len(want) {
+					errs <- "concurrent query returned a different path"
+					return
+				}
+				if _, err := m.FindPath(matrix.NewVec3(17, 0, 17), matrix.NewVec3(2, 0, 10)); err != nil {
+					errs <- err.Error()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,58 @@
+/******************************************************************************/
+/* steering.go                                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package navigation
+
+import "kaijuengine.com/matrix"
+
+// PathFinder finds a path between two points in the world, both [NavMesh]
+// and [GridPathFinder] can be used
+type PathFinder interface {
+	FindPath(start, end matrix.Vec3) ([]matrix.Vec3, error)
+}
+
+// surfaceFinder is implemented by path finders that can place a point onto
+// the walkable surface, agents use it to stay on the navigation mesh
+type surfaceFinder interface {
+	FindNearest(point, extents matrix.Vec3) (matrix.Vec3, bool)
+}
+
+// Seek returns the velocity, on the XZ plane, that moves from the position
+// straight towards the target at full speed
+func Seek(position, target matrix.Vec3, maxSpeed matrix.Float) matrix.Vec3 {
+	to := flatten(target.Subtract(position))
+	dist := to.Length()
+	if dist <= matrix.FloatSmallestNonzero {
+		return matrix.Vec3Zero()
+	}
+	return to.Scale(maxSpeed / dist)
+}
+
+// Arrive is like [Seek] but slows down once the position is within the
+// slowing distance of the target, so that it comes to a stop on the target
+func Arrive(position, target matrix.Vec3, maxSpeed, slowingDistance matrix.Float) matrix.Vec3 {
+	to := flatten(target.Subtract(position))
+	dist := to.Length()
+	if dist <= matrix.FloatSmallestNonzero {
+		return matrix.Vec3Zero()
+	}
+	speed := maxSpeed
+	if slowingDistance > 0 && dist < slowingDistance {
+		speed = maxSpeed * dist / slowingDistance
+	}
+	return to.Scale(speed / dist)
+}
+
+func flatten(v matrix.Vec3) matrix.Vec3 {
+	return matrix.NewVec3(v.X(), 0, v.Z())
+}
+
+func clampLength(v matrix.Vec3, length matrix.Float) matrix.Vec3 {
+	if l := v.Length(); l > length && l > 0 {
+		return v.Scale(length / l)
+	}
+	return v
+}
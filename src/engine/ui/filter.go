@@ -0,0 +1,96 @@
+/******************************************************************************/
+/* filter.go                                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package ui
+
+import (
+	"kaijuengine.com/matrix"
+)
+
+// Filter holds the filter effects of an element, they are applied to the
+// element and to everything drawn within it. The color matrix is applied to
+// the colors of the panels and text, the blur softens text and panel
+// backgrounds and each drop shadow is drawn behind whatever the element
+// paints.
+type Filter struct {
+	Color       matrix.ColorMatrix
+	Blur        float32
+	DropShadows []Shadow
+}
+
+// appliedFilter is the result of combining the filters of an element and
+// all of its ancestors
+type appliedFilter struct {
+	color       matrix.ColorMatrix
+	blur        float32
+	dropShadows []Shadow
+}
+
+func NewFilter() Filter {
+	return Filter{Color: matrix.ColorMatrixIdentity()}
+}
+
+func (f *Filter) IsEmpty() bool {
+	return f.Color.IsIdentity() && f.Blur <= 0 && len(f.DropShadows) == 0
+}
+
+func (ui *UI) Filter() Filter {
+	if ui.filter == nil {
+		return NewFilter()
+	}
+	return *ui.filter
+}
+
+func (ui *UI) SetFilter(filter Filter) {
+	if filter.IsEmpty() {
+		ui.ClearFilter()
+		return
+	}
+	ui.filter = &filter
+	ui.SetDirty(DirtyTypeGenerated)
+}
+
+func (ui *UI) ClearFilter() {
+	if ui.filter == nil {
+		return
+	}
+	ui.filter = nil
+	ui.SetDirty(DirtyTypeGenerated)
+}
+
+// appliedFilter walks up the tree combining the filters. The blur of nested
+// filters adds up like consecutive gaussian blurs. The drop shadows of an
+// ancestor are only cast by this element when nothing in between paints a
+// background, otherwise that background is what casts the shadow.
+func (ui *UI) appliedFilter() appliedFilter {
+	out := appliedFilter{color: matrix.ColorMatrixIdentity()}
+	castsShadows := true
+	for e := &ui.entity; e != nil; e = e.Parent {
+		target := FirstOnEntity(e)
+		if target == nil {
+			break
+		}
+		if target != ui && target.paintsBackground() {
+			castsShadows = false
+		}
+		if target.filter == nil {
+			continue
+		}
+		out.color = out.color.Then(target.filter.Color)
+		if target.filter.Blur > 0 {
+			out.blur = matrix.Sqrt(out.blur*out.blur +
+				target.filter.Blur*target.filter.Blur)
+		}
+		if castsShadows {
+			out.dropShadows = append(out.dropShadows, target.filter.DropShadows...)
+		}
+	}
+	return out
+}
+
+func (ui *UI) paintsBackground() bool {
+	return !ui.IsType(ElementTypeLabel) && ui.ToPanel().ownCalculatedFill().A() > 0
+}
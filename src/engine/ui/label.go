@@ -49,6 +49,11 @@ type labelData struct {
 	renderRequired       bool
 	transparentBG        bool
 	textOverflowEllipsis bool
+	textKey              labelTextKey
+	textShadows          []Shadow
+	renderedShadows      []Shadow
+	shadowDrawings       []rendering.Drawing
+	filter               appliedFilter
 }
 
 func (l *labelData) innerPanelData() *panelData { panic("label isn't a panel") }
@@ -79,6 +84,7 @@ func (label *Label) Init(text string) {
 		wordWrap:        true,
 		renderRequired:  true,
 		lastRenderWidth: 0,
+		filter:          appliedFilter{color: matrix.ColorMatrixIdentity()},
 	}
 	label.elmType = ElementTypeLabel
 	label.postLayoutUpdate = label.labelPostLayoutUpdate
@@ -97,6 +103,7 @@ func (label *Label) Init(text string) {
 	label.Base().AddEvent(EventTypeDestroy, func() {
 		if label.elmData != nil {
 			label.clearDrawings()
+			label.stopTranslating()
 		}
 	})
 }
@@ -114,6 +121,9 @@ func (label *Label) activateDrawings() {
 	for i := range ld.runeDrawings {
 		ld.runeDrawings[i].ShaderData.Activate()
 	}
+	for i := range ld.shadowDrawings {
+		ld.shadowDrawings[i].ShaderData.Activate()
+	}
 }
 
 func (label *Label) deactivateDrawings() {
@@ -121,6 +131,9 @@ func (label *Label) deactivateDrawings() {
 	for i := range ld.runeDrawings {
 		ld.runeDrawings[i].ShaderData.Deactivate()
 	}
+	for i := range ld.shadowDrawings {
+		ld.shadowDrawings[i].ShaderData.Deactivate()
+	}
 }
 
 func (label *Label) FontFace() rendering.FontFace { return label.LabelData().fontFace }
@@ -142,6 +155,10 @@ func (label *Label) clearDrawings() {
 	}
 	ld.runeShaderData = ld.runeShaderData[:0]
 	ld.runeDrawings = ld.runeDrawings[:0]
+	for i := range ld.shadowDrawings {
+		ld.shadowDrawings[i].ShaderData.Destroy()
+	}
+	ld.shadowDrawings = ld.shadowDrawings[:0]
 }
 
 func (label *Label) labelPostLayoutUpdate() {
@@ -177,6 +194,9 @@ func (label *Label) renderText() {
 	defer tracing.NewRegion("Label.renderText").End()
 	ld := label.LabelData()
 	label.clearDrawings()
+	// The shadows decide which colors the text is drawn with, so they are
+	// picked before any of the text is
+	ld.renderedShadows = slices.Clone(label.appliedShadows())
 	if ld.textLength > 0 {
 		maxWidth := label.MaxWidth()
 		if label.entity.Parent != nil && !matrix.Approx(label.entity.Transform.Scale().X(), 0) {
@@ -251,11 +271,14 @@ func (label *Label) renderText() {
 				rd.Material = host.FontCache().OpaqueMaterial(rd.Material)
 			}
 			ld.runeShaderData[i] = rd.ShaderData.(*rendering.TextShaderData)
+			ld.runeShaderData[i].PxRange = softenedPxRange(
+				ld.runeShaderData[i].PxRange, ld.filter.blur)
 		}
 		for i := 0; i < len(ld.colorRanges); i++ {
 			label.colorRange(ld.colorRanges[i])
 		}
 		host.Drawings.AddDrawings(ld.runeDrawings)
+		label.renderTextShadows(host, renderText, renderMaxWidth)
 	}
 }
 
@@ -265,6 +288,10 @@ func (label *Label) labelRender() {
 	label.events[EventTypeRender].Execute()
 	maxWidth := label.nonOverrideMaxWidth()
 	ld := label.LabelData()
+	ld.filter = label.Base().appliedFilter()
+	if !slices.Equal(label.appliedShadows(), ld.renderedShadows) {
+		ld.renderRequired = true
+	}
 	if !matrix.Approx(ld.lastRenderWidth, maxWidth) {
 		ld.lastRenderWidth = maxWidth
 		if ld.wordWrap {
@@ -316,8 +343,15 @@ func (label *Label) calculatedSurface() matrix.Color {
 // surface color outside the glyph — but since the surface IS the real backdrop,
 // that fill is invisible and the anti-aliased edges blend toward the correct
 // color with no halo.
+//
+// Text with shadows or a blur keeps a transparent background instead, the
+// surface color filling the glyph quads would otherwise cover the shadows
+// and the softened edges. Both colors are passed through the color filter.
 func (label *Label) resolveFontColors(fg, bg matrix.Color) (matrix.Color, matrix.Color) {
-	if label.LabelData().transparentBG && bg.A() < 1.0 {
+	ld := label.LabelData()
+	fg = ld.filter.color.Apply(fg)
+	bg = ld.filter.color.Apply(bg)
+	if ld.transparentBG && bg.A() < 1.0 {
 		// The negative background alpha selects the opaque cutout branch in the
 		// text shader. RGB matches the foreground for consistency if this value is
 		// inspected before material selection.
@@ -327,7 +361,10 @@ func (label *Label) resolveFontColors(fg, bg matrix.Color) (matrix.Color, matrix
 	if bg.A() >= 1.0 {
 		return fg, bg
 	}
-	surface := label.calculatedSurface()
+	if len(ld.renderedShadows) > 0 || ld.filter.blur > 0 {
+		return fg, matrix.NewColor(fg.R(), fg.G(), fg.B(), 0)
+	}
+	surface := ld.filter.color.Apply(label.calculatedSurface())
 	return matrix.ColorOver(fg, surface), surface
 }
 
@@ -377,6 +414,13 @@ func (label *Label) LetterSpacing() float32 { return label.LabelData().letterSpa
 func (label *Label) Text() string { return label.LabelData().text }
 
 func (label *Label) SetText(text string) {
+	if label.LabelData().textKey.key != "" {
+		label.stopTranslating()
+	}
+	label.setText(text)
+}
+
+func (label *Label) setText(text string) {
 	ld := label.LabelData()
 	if ld.text == text {
 		return
@@ -399,6 +443,9 @@ func (label *Label) setLabelScissors() {
 	for i := 0; i < len(ld.runeDrawings); i++ {
 		ld.runeDrawings[i].ShaderData.(*rendering.TextShaderData).Scissor = s
 	}
+	for i := 0; i < len(ld.shadowDrawings); i++ {
+		ld.shadowDrawings[i].ShaderData.(*rendering.TextShaderData).Scissor = s
+	}
 }
 
 func (label *Label) SetColor(newColor matrix.Color) {
@@ -693,4 +740,14 @@ func (label *Label) Clone(to *Label) {
 	to.SetBaseline(ld.baseline)
 	// TODO:  Set font face?
 	to.SetWrap(ld.wordWrap)
+	if ld.textKey.key != "" {
+		to.LabelData().textKey = labelTextKey{
+			key:      ld.textKey.key,
+			args:     ld.textKey.args,
+			count:    ld.textKey.count,
+			plural:   ld.textKey.plural,
+			fallback: ld.textKey.fallback,
+		}
+		to.translate()
+	}
 }
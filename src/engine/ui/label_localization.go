@@ -0,0 +1,85 @@
+/******************************************************************************/
+/* label_localization.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package ui
+
+import (
+	"cmp"
+
+	"kaijuengine.com/engine/systems/events"
+	"kaijuengine.com/localization"
+)
+
+type labelTextKey struct {
+	key         string
+	args        map[string]any
+	count       int
+	plural      bool
+	fallback    string
+	strings     *localization.Strings
+	localeEvent events.Id
+}
+
+// SetTextKey sets the text to the translation of the key from the host's
+// string tables, with the named placeholders filled in from args. The text
+// is translated again whenever the locale changes, until [Label.SetText] is
+// used to set plain text.
+func (label *Label) SetTextKey(key string, args map[string]any) {
+	tk := &label.LabelData().textKey
+	tk.key, tk.args, tk.plural = key, args, false
+	label.translate()
+}
+
+// SetTextKeyPlural is [Label.SetTextKey] for text that uses the plural form
+// of the locale for the count, the count is also the {count} placeholder
+func (label *Label) SetTextKeyPlural(key string, count int, args map[string]any) {
+	tk := &label.LabelData().textKey
+	tk.key, tk.args, tk.count, tk.plural = key, args, count, true
+	label.translate()
+}
+
+// SetTextKeyFallback sets the text that is shown when none of the string
+// tables have the key, rather than the key itself
+func (label *Label) SetTextKeyFallback(fallback string) {
+	label.LabelData().textKey.fallback = fallback
+	label.translate()
+}
+
+// TextKey returns the string table key of the text, or an empty string if
+// the text is not translated
+func (label *Label) TextKey() string { return label.LabelData().textKey.key }
+
+func (label *Label) translate() {
+	ld := label.LabelData()
+	tk := &ld.textKey
+	if tk.key == "" {
+		return
+	}
+	if tk.strings == nil {
+		host := label.Base().Host()
+		if host == nil || host.Strings == nil {
+			label.setText(cmp.Or(tk.fallback, tk.key))
+			return
+		}
+		tk.strings = host.Strings
+		tk.localeEvent = tk.strings.OnLocaleChanged.Add(label.translate)
+	}
+	if tk.fallback != "" && !tk.strings.Has(tk.key) {
+		label.setText(tk.fallback)
+	} else if tk.plural {
+		label.setText(tk.strings.Plural(tk.key, tk.count, tk.args))
+	} else {
+		label.setText(tk.strings.Format(tk.key, tk.args))
+	}
+}
+
+func (label *Label) stopTranslating() {
+	tk := &label.LabelData().textKey
+	if tk.strings != nil {
+		tk.strings.OnLocaleChanged.Remove(tk.localeEvent)
+	}
+	*tk = labelTextKey{}
+}
@@ -0,0 +1,101 @@
+/******************************************************************************/
+/* label_shadow.go                                                            */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package ui
+
+import (
+	"math"
+	"slices"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering"
+)
+
+// textShadowSamples are the offsets, in units of the blur radius, that a
+// blurred text shadow is drawn at. Layering the copies spreads the shadow
+// over the blur radius while the softer glyph edges smooth it out.
+var textShadowSamples = [...]matrix.Vec2{
+	{0, 0}, {-0.35, -0.35}, {0.35, -0.35}, {-0.35, 0.35}, {0.35, 0.35},
+}
+
+func (label *Label) TextShadows() []Shadow { return label.LabelData().textShadows }
+
+// SetTextShadows sets the shadows drawn behind the text, the first shadow in
+// the list is drawn on top of the others. The spread and inset of the shadows
+// are not used for text.
+func (label *Label) SetTextShadows(shadows []Shadow) {
+	ld := label.LabelData()
+	if slices.Equal(ld.textShadows, shadows) {
+		return
+	}
+	ld.textShadows = slices.Clone(shadows)
+	ld.renderRequired = true
+	label.Base().SetDirty(DirtyTypeGenerated)
+}
+
+// appliedShadows are the text shadows of the label followed by the drop
+// shadows of the filters that apply to it
+func (label *Label) appliedShadows() []Shadow {
+	ld := label.LabelData()
+	if len(ld.filter.dropShadows) == 0 {
+		return ld.textShadows
+	}
+	return append(slices.Clone(ld.textShadows), ld.filter.dropShadows...)
+}
+
+// softenedPxRange lowers the distance range of the glyph so that its edges
+// are drawn softer, which is how the text approximates a blur
+func softenedPxRange(pxRange matrix.Vec2, blur float32) matrix.Vec2 {
+	if blur <= 0 {
+		return pxRange
+	}
+	return pxRange.Scale(1 / (1 + blur))
+}
+
+func (label *Label) renderTextShadows(host *engine.Host, text string, maxWidth float32) {
+	ld := label.LabelData()
+	shadows := ld.renderedShadows
+	if len(shadows) == 0 {
+		return
+	}
+	defer tracing.NewRegion("Label.renderTextShadows").End()
+	step := float32(shadowDepthRange) / float32(len(shadows)+1)
+	for i, s := range shadows {
+		if s.Color.A() <= 0 {
+			continue
+		}
+		blur := float32(math.Hypot(float64(max(s.Blur, 0)), float64(ld.filter.blur)))
+		samples := textShadowSamples[:1]
+		if blur > 0 {
+			samples = textShadowSamples[:]
+		}
+		fg := ld.filter.color.Apply(s.Color)
+		// Each layered copy is given the alpha that adds up to the shadow's
+		// alpha where all of them overlap
+		fg.SetA(1 - float32(math.Pow(float64(1-fg.A()), 1/float64(len(samples)))))
+		bg := fg
+		bg.SetA(0)
+		for _, sample := range samples {
+			x := s.Offset.X() + sample.X()*blur
+			y := -(s.Offset.Y() + sample.Y()*blur)
+			drawings := host.FontCache().RenderMeshesWithLetterSpacing(
+				host, text, x, y, -step*float32(i+1), ld.fontSize,
+				maxWidth, fg, bg, ld.justify, ld.baseline,
+				label.entity.Transform.WorldScale(), true, false, ld.fontFace,
+				ld.lineHeight, ld.letterSpacing, &host.Cameras.UI)
+			for j := range drawings {
+				sd := drawings[j].ShaderData.(*rendering.TextShaderData)
+				sd.PxRange = softenedPxRange(sd.PxRange, blur)
+				drawings[j].Transform = &label.entity.Transform
+				drawings[j].Layer = rendering.RenderLayerUI
+			}
+			ld.shadowDrawings = append(ld.shadowDrawings, drawings...)
+		}
+	}
+	host.Drawings.AddDrawings(ld.shadowDrawings)
+}
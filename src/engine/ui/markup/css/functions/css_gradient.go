@@ -0,0 +1,594 @@
+/******************************************************************************/
+/* css_gradient.go                                                            */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"kaijuengine.com/engine/ui/markup/css/helpers"
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/matrix"
+)
+
+type GradientKind int
+
+const (
+	GradientLinear = GradientKind(iota)
+	GradientRadial
+	GradientConic
+)
+
+// gradientKinds are the gradient functions, the bool is true for the
+// repeating variant of the gradient
+var gradientKinds = map[string]struct {
+	kind      GradientKind
+	repeating bool
+}{
+	"linear-gradient":           {GradientLinear, false},
+	"radial-gradient":           {GradientRadial, false},
+	"conic-gradient":            {GradientConic, false},
+	"repeating-linear-gradient": {GradientLinear, true},
+	"repeating-radial-gradient": {GradientRadial, true},
+	"repeating-conic-gradient":  {GradientConic, true},
+}
+
+type GradientStop struct {
+	Color matrix.Color
+	// Offset is the position of the stop as it was written ("25%", "10px",
+	// "90deg"), it is empty for stops that are spaced out automatically
+	Offset string
+	// Hint is set for a lone offset between two colors that moves the point
+	// where the two colors are blended half way
+	Hint bool
+}
+
+// Gradient is a parsed linear, radial or conic gradient function that can be
+// drawn into the pixels of a texture of any size
+type Gradient struct {
+	Kind      GradientKind
+	Repeating bool
+	// Angle is the direction of a linear gradient, or the starting angle of a
+	// conic gradient, in degrees clockwise from the top
+	Angle float32
+	// Corner is the side or corner a linear gradient goes "to", x is -1 for
+	// left and 1 for right, y is -1 for top and 1 for bottom. It is zero when
+	// the Angle is used instead.
+	Corner [2]int
+	Circle bool
+	// Extent is the size keyword of a radial gradient, like "closest-side",
+	// it is not used when the Radius is set
+	Extent   string
+	Radius   []string
+	Position [2]string
+	Stops    []GradientStop
+	key      string
+}
+
+type resolvedStop struct {
+	color  matrix.Color
+	offset float32
+	// hint is where between this stop and the next the colors are blended
+	// half way, it is negative when there is no hint
+	hint float32
+}
+
+// IsGradient reports if the name is one of the gradient functions
+func IsGradient(name string) bool {
+	_, ok := gradientKinds[name]
+	return ok
+}
+
+// ParseGradient reads the arguments of one of the gradient functions
+func ParseGradient(value rules.PropertyValue) (Gradient, error) {
+	kind, ok := gradientKinds[value.Str]
+	if !ok {
+		return Gradient{}, fmt.Errorf("%q is not a gradient", value.Str)
+	}
+	g := Gradient{
+		Kind:      kind.kind,
+		Repeating: kind.repeating,
+		Angle:     180,
+		Extent:    "farthest-corner",
+		Position:  [2]string{"50%", "50%"},
+		key:       value.Str + "(" + strings.Join(value.Args, " ") + ")",
+	}
+	if g.Kind == GradientConic {
+		g.Angle = 0
+	}
+	groups := splitGradientArgs(value.Args)
+	if len(groups) > 0 && len(groups[0]) > 0 {
+		if _, ok := parseGradientColor(groups[0][0]); !ok {
+			var err error
+			switch g.Kind {
+			case GradientLinear:
+				err = g.readLinearConfig(groups[0])
+			case GradientRadial:
+				err = g.readRadialConfig(groups[0])
+			case GradientConic:
+				err = g.readConicConfig(groups[0])
+			}
+			if err != nil {
+				return g, err
+			}
+			groups = groups[1:]
+		}
+	}
+	for _, group := range groups {
+		if err := g.readStop(group); err != nil {
+			return g, err
+		}
+	}
+	colors := 0
+	for i := range g.Stops {
+		if !g.Stops[i].Hint {
+			colors++
+		}
+	}
+	if colors < 2 {
+		return g, errors.New("a gradient needs at least 2 color stops")
+	}
+	if g.Stops[0].Hint || g.Stops[len(g.Stops)-1].Hint {
+		return g, errors.New("a gradient color hint must be between 2 color stops")
+	}
+	return g, nil
+}
+
+// Key is a string that is the same for gradients that draw the same pixels
+func (g *Gradient) Key() string { return g.key }
+
+func splitGradientArgs(args []string) [][]string {
+	groups := [][]string{{}}
+	for _, a := range args {
+		if a == "," {
+			groups = append(groups, []string{})
+		} else {
+			groups[len(groups)-1] = append(groups[len(groups)-1], a)
+		}
+	}
+	return groups
+}
+
+func (g *Gradient) readLinearConfig(args []string) error {
+	if args[0] != "to" {
+		if len(args) != 1 {
+			return fmt.Errorf("unexpected linear gradient direction %q", strings.Join(args, " "))
+		}
+		angle, ok := parseAngle(args[0])
+		if !ok {
+			return fmt.Errorf("invalid linear gradient angle %q", args[0])
+		}
+		g.Angle = angle
+		return nil
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return errors.New("expected a side or corner after \"to\" in linear gradient")
+	}
+	for _, side := range args[1:] {
+		switch side {
+		case "left":
+			g.Corner[0] = -1
+		case "right":
+			g.Corner[0] = 1
+		case "top":
+			g.Corner[1] = -1
+		case "bottom":
+			g.Corner[1] = 1
+		default:
+			return fmt.Errorf("invalid linear gradient side %q", side)
+		}
+	}
+	return nil
+}
+
+func (g *Gradient) readRadialConfig(args []string) error {
+	shape := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "circle", "ellipse":
+			shape = args[i]
+		case "closest-side", "closest-corner", "farthest-side", "farthest-corner":
+			g.Extent = args[i]
+		case "at":
+			return g.readPosition(args[i+1:], shape)
+		default:
+			if _, ok := parseGradientLength(args[i]); !ok {
+				return fmt.Errorf("unexpected radial gradient value %q", args[i])
+			}
+			g.Radius = append(g.Radius, args[i])
+		}
+	}
+	return g.setRadialShape(shape)
+}
+
+func (g *Gradient) setRadialShape(shape string) error {
+	g.Circle = shape == "circle" || (shape == "" && len(g.Radius) == 1)
+	if len(g.Radius) > 2 || (g.Circle && len(g.Radius) > 1) ||
+		(!g.Circle && len(g.Radius) == 1) {
+		return fmt.Errorf("invalid radial gradient size %q", strings.Join(g.Radius, " "))
+	}
+	if g.Circle && len(g.Radius) == 1 && strings.HasSuffix(g.Radius[0], "%") {
+		return errors.New("a circle radial gradient can't use a percentage size")
+	}
+	return nil
+}
+
+func (g *Gradient) readConicConfig(args []string) error {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "from":
+			if i+1 >= len(args) {
+				return errors.New("expected an angle after \"from\" in conic gradient")
+			}
+			angle, ok := parseAngle(args[i+1])
+			if !ok {
+				return fmt.Errorf("invalid conic gradient angle %q", args[i+1])
+			}
+			g.Angle = angle
+			i++
+		case "at":
+			return g.readPosition(args[i+1:], "")
+		default:
+			return fmt.Errorf("unexpected conic gradient value %q", args[i])
+		}
+	}
+	return nil
+}
+
+// readPosition reads the "at" position of a radial or conic gradient and
+// then sets the shape, if any, that was read before it
+func (g *Gradient) readPosition(args []string, shape string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("expected 1 or 2 values for the gradient position")
+	}
+	x, y := args[0], "center"
+	if len(args) == 2 {
+		y = args[1]
+	}
+	if x == "top" || x == "bottom" || y == "left" || y == "right" {
+		x, y = y, x
+	}
+	for i, v := range [2]string{x, y} {
+		switch v {
+		case "left", "top":
+			g.Position[i] = "0%"
+		case "center":
+			g.Position[i] = "50%"
+		case "right", "bottom":
+			g.Position[i] = "100%"
+		default:
+			if _, ok := parseGradientLength(v); !ok {
+				return fmt.Errorf("invalid gradient position %q", v)
+			}
+			g.Position[i] = v
+		}
+	}
+	if g.Kind == GradientRadial {
+		return g.setRadialShape(shape)
+	}
+	return nil
+}
+
+func (g *Gradient) readStop(args []string) error {
+	if len(args) == 0 {
+		return errors.New("empty gradient color stop")
+	}
+	color, ok := parseGradientColor(args[0])
+	if !ok {
+		if len(args) != 1 || !g.validOffset(args[0]) {
+			return fmt.Errorf("invalid gradient color stop %q", strings.Join(args, " "))
+		}
+		g.Stops = append(g.Stops, GradientStop{Offset: args[0], Hint: true})
+		return nil
+	}
+	if len(args) > 3 {
+		return fmt.Errorf("too many values in gradient color stop %q", strings.Join(args, " "))
+	}
+	if len(args) == 1 {
+		g.Stops = append(g.Stops, GradientStop{Color: color})
+	}
+	for _, offset := range args[1:] {
+		if !g.validOffset(offset) {
+			return fmt.Errorf("invalid gradient color stop offset %q", offset)
+		}
+		g.Stops = append(g.Stops, GradientStop{Color: color, Offset: offset})
+	}
+	return nil
+}
+
+func (g *Gradient) validOffset(offset string) bool {
+	if g.Kind == GradientConic {
+		if _, ok := parseAngle(offset); ok {
+			return true
+		}
+		return strings.HasSuffix(offset, "%")
+	}
+	_, ok := parseGradientLength(offset)
+	return ok
+}
+
+// Pixels draws the gradient into RGBA pixels with the top row first
+func (g *Gradient) Pixels(width, height int) []byte {
+	pixels := make([]byte, width*height*4)
+	w, h := float32(width), float32(height)
+	center := matrix.NewVec2(
+		resolveGradientLength(g.Position[0], w), resolveGradientLength(g.Position[1], h))
+	var stops []resolvedStop
+	var sample func(x, y float32) float32
+	switch g.Kind {
+	case GradientLinear:
+		angle := g.Angle
+		if g.Corner != [2]int{} {
+			angle = matrix.Rad2Deg(matrix.Atan2(float32(g.Corner[0])*h, -float32(g.Corner[1])*w))
+		}
+		rad := matrix.Deg2Rad(angle)
+		dir := matrix.NewVec2(matrix.Sin(rad), -matrix.Cos(rad))
+		length := max(matrix.Abs(w*dir.X())+matrix.Abs(h*dir.Y()), 0.0001)
+		stops = g.resolveStops(length)
+		sample = func(x, y float32) float32 {
+			return ((x-w*0.5)*dir.X()+(y-h*0.5)*dir.Y())/length + 0.5
+		}
+	case GradientRadial:
+		rx, ry := g.radii(center, w, h)
+		stops = g.resolveStops(rx)
+		sample = func(x, y float32) float32 {
+			dx, dy := (x-center.X())/rx, (y-center.Y())/ry
+			return matrix.Sqrt(dx*dx + dy*dy)
+		}
+	case GradientConic:
+		stops = g.resolveStops(1)
+		sample = func(x, y float32) float32 {
+			deg := matrix.Rad2Deg(matrix.Atan2(x-center.X(), center.Y()-y))
+			return float32(math.Mod(float64(deg-g.Angle)+720, 360)) / 360
+		}
+	}
+	for y := range height {
+		for x := range width {
+			c := gradientColorAt(stops, sample(float32(x)+0.5, float32(y)+0.5), g.Repeating)
+			i := (y*width + x) * 4
+			for j := range 4 {
+				pixels[i+j] = uint8(matrix.Clamp(c[j], 0, 1)*255 + 0.5)
+			}
+		}
+	}
+	return pixels
+}
+
+// radii are the x and y radius of the ending shape of a radial gradient
+func (g *Gradient) radii(center matrix.Vec2, w, h float32) (float32, float32) {
+	if len(g.Radius) > 0 {
+		rx := resolveGradientLength(g.Radius[0], w)
+		ry := rx
+		if len(g.Radius) > 1 {
+			ry = resolveGradientLength(g.Radius[1], h)
+		}
+		return max(rx, 0.0001), max(ry, 0.0001)
+	}
+	nearX := min(matrix.Abs(center.X()), matrix.Abs(w-center.X()))
+	nearY := min(matrix.Abs(center.Y()), matrix.Abs(h-center.Y()))
+	farX := max(matrix.Abs(center.X()), matrix.Abs(w-center.X()))
+	farY := max(matrix.Abs(center.Y()), matrix.Abs(h-center.Y()))
+	var rx, ry float32
+	switch g.Extent {
+	case "closest-side":
+		rx, ry = nearX, nearY
+		if g.Circle {
+			rx = min(nearX, nearY)
+			ry = rx
+		}
+	case "farthest-side":
+		rx, ry = farX, farY
+		if g.Circle {
+			rx = max(farX, farY)
+			ry = rx
+		}
+	case "closest-corner":
+		rx, ry = nearX*math.Sqrt2, nearY*math.Sqrt2
+		if g.Circle {
+			rx = matrix.Sqrt(nearX*nearX + nearY*nearY)
+			ry = rx
+		}
+	default:
+		rx, ry = farX*math.Sqrt2, farY*math.Sqrt2
+		if g.Circle {
+			rx = matrix.Sqrt(farX*farX + farY*farY)
+			ry = rx
+		}
+	}
+	return max(rx, 0.0001), max(ry, 0.0001)
+}
+
+// resolveStops turns the stop offsets into fractions of the gradient line,
+// filling in the offsets that were left out. The length is the size of the
+// gradient line in pixels, it isn't used by conic gradients.
+func (g *Gradient) resolveStops(length float32) []resolvedStop {
+	stops := make([]resolvedStop, 0, len(g.Stops))
+	set := make([]bool, 0, len(g.Stops))
+	hints := make([]float32, 0, len(g.Stops))
+	for _, s := range g.Stops {
+		if s.Hint {
+			hints[len(hints)-1] = g.stopOffset(s.Offset, length)
+			continue
+		}
+		c := s.Color
+		// Blending happens with the alpha premultiplied so that fading into
+		// a transparent color doesn't darken the other color
+		stops = append(stops, resolvedStop{
+			color: matrix.NewColor(c.R()*c.A(), c.G()*c.A(), c.B()*c.A(), c.A()),
+			hint:  -1,
+		})
+		set = append(set, s.Offset != "")
+		hints = append(hints, -1)
+		if s.Offset != "" {
+			stops[len(stops)-1].offset = g.stopOffset(s.Offset, length)
+		}
+	}
+	if !set[0] {
+		stops[0].offset, set[0] = 0, true
+	}
+	last := len(stops) - 1
+	if !set[last] {
+		stops[last].offset, set[last] = 1, true
+	}
+	largest := stops[0].offset
+	for i := range stops {
+		if set[i] {
+			stops[i].offset = max(stops[i].offset, largest)
+			largest = stops[i].offset
+		}
+	}
+	for i := 1; i < last; i++ {
+		if set[i] {
+			continue
+		}
+		end := i
+		for !set[end] {
+			end++
+		}
+		from, to := stops[i-1].offset, stops[end].offset
+		for j := i; j < end; j++ {
+			stops[j].offset = from + (to-from)*float32(j-i+1)/float32(end-i+1)
+			set[j] = true
+		}
+	}
+	for i := range stops {
+		if hints[i] >= 0 && i < last {
+			stops[i].hint = matrix.Clamp(hints[i], stops[i].offset, stops[i+1].offset)
+		}
+	}
+	return stops
+}
+
+func (g *Gradient) stopOffset(offset string, length float32) float32 {
+	if strings.HasSuffix(offset, "%") {
+		v, _ := parseGradientLength(offset)
+		return v / 100
+	}
+	if g.Kind == GradientConic {
+		angle, _ := parseAngle(offset)
+		return angle / 360
+	}
+	v, _ := parseGradientLength(offset)
+	return v / max(length, 0.0001)
+}
+
+func gradientColorAt(stops []resolvedStop, t float32, repeating bool) matrix.Color {
+	first, last := stops[0].offset, stops[len(stops)-1].offset
+	if repeating && last-first > 0 {
+		period := last - first
+		t = first + float32(math.Mod(float64(t-first), float64(period)))
+		if t < first {
+			t += period
+		}
+	}
+	var c matrix.Color
+	if t <= first {
+		c = stops[0].color
+	} else if t >= last {
+		c = stops[len(stops)-1].color
+	} else {
+		i := 0
+		for i < len(stops)-2 && t >= stops[i+1].offset {
+			i++
+		}
+		a, b := stops[i], stops[i+1]
+		p := float32(1)
+		if span := b.offset - a.offset; span > 0 {
+			p = (t - a.offset) / span
+			if a.hint >= 0 {
+				p = applyGradientHint(p, (a.hint-a.offset)/span)
+			}
+		}
+		c = matrix.ColorMix(a.color, b.color, p)
+	}
+	if c.A() > 0 {
+		c = matrix.NewColor(c.R()/c.A(), c.G()/c.A(), c.B()/c.A(), c.A())
+	}
+	return c
+}
+
+func applyGradientHint(p, hint float32) float32 {
+	switch {
+	case hint <= 0:
+		return 1
+	case hint >= 1:
+		return 0
+	default:
+		return matrix.Pow(p, float32(math.Log(0.5)/math.Log(float64(hint))))
+	}
+}
+
+// parseGradientColor reads a color from a gradient argument, nested color
+// functions are kept whole by the parser like "rgba(0,0,0,0.5)"
+func parseGradientColor(str string) (matrix.Color, bool) {
+	if name, args, ok := strings.Cut(str, "("); ok {
+		fn, found := FunctionMap[name]
+		if !found || (name != "rgb" && name != "rgba") {
+			return matrix.Color{}, false
+		}
+		v := rules.PropertyValue{Str: name, Args: strings.Split(strings.TrimSuffix(args, ")"), ",")}
+		if len(v.Args) != map[string]int{"rgb": 3, "rgba": 4}[name] {
+			return matrix.Color{}, false
+		}
+		hex, err := fn.Process(nil, nil, v)
+		if err != nil {
+			return matrix.Color{}, false
+		}
+		str = hex
+	}
+	hex := strings.ToLower(str)
+	if hex == "transparent" {
+		return matrix.ColorTransparent(), true
+	} else if named, ok := helpers.ColorMap[hex]; ok {
+		hex = named
+	}
+	if !strings.HasPrefix(hex, "#") {
+		return matrix.Color{}, false
+	}
+	c, err := matrix.ColorFromHexString(hex)
+	return c, err == nil
+}
+
+// parseAngle reads a CSS angle into degrees
+func parseAngle(str string) (float32, bool) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"grad", 0.9},
+		{"turn", 360},
+		{"deg", 1},
+		{"rad", 180 / math.Pi},
+	}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(str, u.suffix); ok {
+			v, err := strconv.ParseFloat(num, 32)
+			return float32(v * u.scale), err == nil
+		}
+	}
+	return 0, str == "0"
+}
+
+// parseGradientLength reads a px, percentage or unitless length
+func parseGradientLength(str string) (float32, bool) {
+	num := strings.TrimSuffix(strings.TrimSuffix(str, "px"), "%")
+	v, err := strconv.ParseFloat(num, 32)
+	return float32(v), err == nil
+}
+
+// resolveGradientLength turns a length into pixels, percentages are of the
+// given size
+func resolveGradientLength(str string, size float32) float32 {
+	v, _ := parseGradientLength(str)
+	if strings.HasSuffix(str, "%") {
+		return v / 100 * size
+	}
+	return v
+}
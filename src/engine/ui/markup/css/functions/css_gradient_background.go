@@ -0,0 +1,197 @@
+/******************************************************************************/
+/* css_gradient_background.go                                                 */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package functions
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/events"
+	"kaijuengine.com/engine/ui"
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/engine/ui/markup/document"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering"
+)
+
+const (
+	// gradientSizeStep is the number of pixels the size of a gradient texture
+	// is rounded up to, so that a panel that is being resized doesn't make a
+	// new texture every frame
+	gradientSizeStep = 8
+	maxGradientSize  = 1024
+	// defaultGradientSize is used until the panel has been laid out
+	defaultGradientSize = 64
+)
+
+type gradientBinding struct {
+	gradient  Gradient
+	width     int
+	height    int
+	texture   gradientTextureRef
+	renderId  events.Id
+	destroyId events.Id
+}
+
+// gradientTextureRef identifies a gradient texture within the texture cache
+// of the host it was created for
+type gradientTextureRef struct {
+	cache *rendering.TextureCache
+	key   string
+}
+
+var gradientBindings = struct {
+	sync.Mutex
+	panels map[*ui.UI]*gradientBinding
+}{panels: map[*ui.UI]*gradientBinding{}}
+
+// gradientTextures counts the panels using each gradient texture, so that the
+// texture can be removed from the cache once no panel is drawing it at that
+// size anymore
+var gradientTextures = struct {
+	sync.Mutex
+	refs map[gradientTextureRef]int
+}{refs: map[gradientTextureRef]int{}}
+
+// GradientTexture returns the texture for the gradient drawn at the given
+// size, the texture is cached by the gradient and size
+func GradientTexture(host *engine.Host, g *Gradient, width, height int) (*rendering.Texture, error) {
+	return gradientTexture(host.TextureCache(), g, width, height)
+}
+
+func gradientTexture(cache *rendering.TextureCache, g *Gradient, width, height int) (*rendering.Texture, error) {
+	defer tracing.NewRegion("functions.GradientTexture").End()
+	key := gradientTextureKey(g, width, height)
+	if tex, ok := cache.Find(key, rendering.TextureFilterLinear); ok {
+		return tex, nil
+	}
+	return cache.InsertRawTexture(key, g.Pixels(width, height),
+		width, height, rendering.TextureFilterLinear)
+}
+
+func gradientTextureKey(g *Gradient, width, height int) string {
+	return fmt.Sprintf("%s@%dx%d", g.Key(), width, height)
+}
+
+// acquireGradientTexture is [GradientTexture] for a panel, the texture must be
+// given back with [releaseGradientTexture] once the panel stops using it
+func acquireGradientTexture(cache *rendering.TextureCache, g *Gradient, width, height int) (*rendering.Texture, gradientTextureRef, error) {
+	tex, err := gradientTexture(cache, g, width, height)
+	if err != nil {
+		return nil, gradientTextureRef{}, err
+	}
+	ref := gradientTextureRef{cache, gradientTextureKey(g, width, height)}
+	gradientTextures.Lock()
+	gradientTextures.refs[ref]++
+	gradientTextures.Unlock()
+	return tex, ref, nil
+}
+
+func releaseGradientTexture(ref gradientTextureRef) {
+	if ref.cache == nil {
+		return
+	}
+	gradientTextures.Lock()
+	defer gradientTextures.Unlock()
+	if gradientTextures.refs[ref]--; gradientTextures.refs[ref] > 0 {
+		return
+	}
+	delete(gradientTextures.refs, ref)
+	ref.cache.ForceRemoveTexture(ref.key, rendering.TextureFilterLinear)
+}
+
+// ApplyGradientBackground parses the gradient function value and sets it as
+// the background of the panel. The gradient is drawn again whenever the size
+// of the panel changes.
+func ApplyGradientBackground(panel *ui.Panel, value rules.PropertyValue) (string, error) {
+	g, err := ParseGradient(value)
+	if err != nil {
+		return "", err
+	}
+	base := panel.Base()
+	b := &gradientBinding{gradient: g}
+	if err := b.update(panel); err != nil {
+		return "", err
+	}
+	// The previous gradient is cleared after the new texture is taken so that
+	// a texture the two share isn't removed and made again
+	ClearGradientBackground(panel)
+	panel.SetColor(matrix.ColorWhite())
+	b.renderId = base.AddEvent(ui.EventTypeRender, func() {
+		if err := b.update(panel); err != nil {
+			slog.Error("failed to update the gradient background", "error", err)
+		}
+	})
+	b.destroyId = base.AddEvent(ui.EventTypeDestroy, func() {
+		gradientBindings.Lock()
+		if gradientBindings.panels[base] == b {
+			delete(gradientBindings.panels, base)
+		}
+		gradientBindings.Unlock()
+		releaseGradientTexture(b.texture)
+	})
+	gradientBindings.Lock()
+	gradientBindings.panels[base] = b
+	gradientBindings.Unlock()
+	return g.Key(), nil
+}
+
+// ClearGradientBackground stops the panel from drawing its gradient again as
+// it changes size, it returns true if the panel had a gradient background
+func ClearGradientBackground(panel *ui.Panel) bool {
+	base := panel.Base()
+	gradientBindings.Lock()
+	b, ok := gradientBindings.panels[base]
+	delete(gradientBindings.panels, base)
+	gradientBindings.Unlock()
+	if ok {
+		base.RemoveEvent(ui.EventTypeRender, b.renderId)
+		base.RemoveEvent(ui.EventTypeDestroy, b.destroyId)
+		releaseGradientTexture(b.texture)
+	}
+	return ok
+}
+
+func (b *gradientBinding) update(panel *ui.Panel) error {
+	size := panel.Base().Layout().PixelSize()
+	w, h := gradientTextureSize(size.X()), gradientTextureSize(size.Y())
+	if w == b.width && h == b.height {
+		return nil
+	}
+	host := panel.Base().Host()
+	if host == nil {
+		return nil
+	}
+	tex, ref, err := acquireGradientTexture(host.TextureCache(), &b.gradient, w, h)
+	if err != nil {
+		return err
+	}
+	b.width, b.height = w, h
+	panel.SetBackground(tex)
+	releaseGradientTexture(b.texture)
+	b.texture = ref
+	return nil
+}
+
+func gradientTextureSize(size float32) int {
+	if size <= 0 {
+		return defaultGradientSize
+	}
+	steps := (int(matrix.Ceil(size)) + gradientSizeStep - 1) / gradientSizeStep
+	return min(max(steps*gradientSizeStep, gradientSizeStep), maxGradientSize)
+}
+
+func gradientProcess(panel *ui.Panel, _ *document.Element, value rules.PropertyValue) (string, error) {
+	if panel == nil {
+		g, err := ParseGradient(value)
+		return g.Key(), err
+	}
+	return ApplyGradientBackground(panel, value)
+}
@@ -0,0 +1,198 @@
+/******************************************************************************/
+/* css_gradient_test.go                                                       */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	"kaijuengine.com/engine/assets"
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/rendering"
+)
+
+// testGradientValue splits the arguments the way the parser does, keeping
+// nested functions whole
+func testGradientValue(name string, args string) rules.PropertyValue {
+	value := rules.PropertyValue{Str: name}
+	depth := 0
+	arg := strings.Builder{}
+	flush := func() {
+		if arg.Len() > 0 {
+			value.Args = append(value.Args, arg.String())
+			arg.Reset()
+		}
+	}
+	for _, r := range args {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth == 0 && r == ',':
+			flush()
+			value.Args = append(value.Args, ",")
+			continue
+		case depth == 0 && r == ' ':
+			flush()
+			continue
+		}
+		arg.WriteRune(r)
+	}
+	flush()
+	return value
+}
+
+func testGradient(t *testing.T, name string, args string) Gradient {
+	t.Helper()
+	g, err := ParseGradient(testGradientValue(name, args))
+	if err != nil {
+		t.Fatalf("failed to parse %s(%s): %v", name, args, err)
+	}
+	return g
+}
+
+func testPixel(t *testing.T, pixels []byte, width, x, y int, want [4]byte) {
+	t.Helper()
+	i := (y*width + x) * 4
+	for j := range 4 {
+		diff := int(pixels[i+j]) - int(want[j])
+		if diff < -1 || diff > 1 {
+			t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, pixels[i:i+4], want)
+		}
+	}
+}
+
+func TestLinearGradientPixels(t *testing.T) {
+	g := testGradient(t, "linear-gradient", "to right, red, blue")
+	pixels := g.Pixels(2, 1)
+	testPixel(t, pixels, 2, 0, 0, [4]byte{191, 0, 64, 255})
+	testPixel(t, pixels, 2, 1, 0, [4]byte{64, 0, 191, 255})
+	// 0deg goes to the top, so the top row is the end color
+	g = testGradient(t, "linear-gradient", "0deg, red 50%, blue 50%")
+	pixels = g.Pixels(1, 4)
+	testPixel(t, pixels, 1, 0, 0, [4]byte{0, 0, 255, 255})
+	testPixel(t, pixels, 1, 0, 1, [4]byte{0, 0, 255, 255})
+	testPixel(t, pixels, 1, 0, 2, [4]byte{255, 0, 0, 255})
+	testPixel(t, pixels, 1, 0, 3, [4]byte{255, 0, 0, 255})
+}
+
+func TestLinearGradientCornerFollowsAspect(t *testing.T) {
+	// Going to the top right corner of a wide box, the other two corners are
+	// on the half way line of the gradient
+	g := testGradient(t, "linear-gradient", "to top right, black, white")
+	pixels := g.Pixels(6, 2)
+	testPixel(t, pixels, 6, 1, 0, [4]byte{128, 128, 128, 255})
+	testPixel(t, pixels, 6, 4, 1, [4]byte{128, 128, 128, 255})
+}
+
+func TestRepeatingLinearGradientPixels(t *testing.T) {
+	g := testGradient(t, "repeating-linear-gradient", "90deg, red 0px, red 1px, blue 1px, blue 2px")
+	pixels := g.Pixels(4, 1)
+	for x, want := range [][4]byte{{255, 0, 0, 255}, {0, 0, 255, 255}, {255, 0, 0, 255}, {0, 0, 255, 255}} {
+		testPixel(t, pixels, 4, x, 0, want)
+	}
+}
+
+func TestGradientBlendsTransparentWithoutDarkening(t *testing.T) {
+	g := testGradient(t, "linear-gradient", "to right, rgba(255,0,0,1), transparent")
+	pixels := g.Pixels(2, 1)
+	testPixel(t, pixels, 2, 1, 0, [4]byte{255, 0, 0, 64})
+}
+
+func TestRadialGradientPixels(t *testing.T) {
+	g := testGradient(t, "radial-gradient", "circle closest-side, white, black")
+	if !g.Circle || g.Extent != "closest-side" {
+		t.Fatalf("expected a closest-side circle, got %#v", g)
+	}
+	pixels := g.Pixels(8, 8)
+	// The pixel centers next to the middle are half a pixel along each axis
+	// away from it, out of a radius of 4
+	testPixel(t, pixels, 8, 4, 4, [4]byte{210, 210, 210, 255})
+	testPixel(t, pixels, 8, 0, 0, [4]byte{0, 0, 0, 255})
+	g = testGradient(t, "radial-gradient", "10px 4px at left top, red, blue")
+	if g.Circle || g.Position != [2]string{"0%", "0%"} {
+		t.Fatalf("expected an ellipse at the top left, got %#v", g)
+	}
+}
+
+func TestConicGradientPixels(t *testing.T) {
+	g := testGradient(t, "conic-gradient", "from 90deg, red 0deg 90deg, blue 90deg")
+	pixels := g.Pixels(4, 4)
+	// Starting from the right side, the first quarter turn is the bottom right
+	testPixel(t, pixels, 4, 3, 3, [4]byte{255, 0, 0, 255})
+	testPixel(t, pixels, 4, 3, 0, [4]byte{0, 0, 255, 255})
+	testPixel(t, pixels, 4, 0, 3, [4]byte{0, 0, 255, 255})
+}
+
+func TestGradientStopsFillMissingOffsets(t *testing.T) {
+	g := testGradient(t, "linear-gradient", "red, green 80%, blue 20%, yellow, black")
+	stops := g.resolveStops(100)
+	want := []float32{0, 0.8, 0.8, 0.9, 1}
+	for i := range want {
+		if d := stops[i].offset - want[i]; d < -0.0001 || d > 0.0001 {
+			t.Fatalf("stop %d offset = %f, want %f", i, stops[i].offset, want[i])
+		}
+	}
+	g = testGradient(t, "linear-gradient", "red, 25%, blue")
+	if stops = g.resolveStops(100); len(stops) != 2 || stops[0].hint != 0.25 {
+		t.Fatalf("expected a hint at 25%%, got %#v", stops)
+	}
+}
+
+func TestParseGradientErrors(t *testing.T) {
+	for _, bad := range []struct{ name, args string }{
+		{"linear-gradient", "red"},
+		{"linear-gradient", "sideways, red, blue"},
+		{"linear-gradient", "to middle, red, blue"},
+		{"radial-gradient", "circle 10px 20px, red, blue"},
+		{"conic-gradient", "red, 10%"},
+		{"linear-gradient", "red, blue 10deg"},
+	} {
+		if _, err := ParseGradient(testGradientValue(bad.name, bad.args)); err == nil {
+			t.Fatalf("expected %s(%s) to fail to parse", bad.name, bad.args)
+		}
+	}
+}
+
+func TestGradientTextureSize(t *testing.T) {
+	for size, want := range map[float32]int{0: 64, 1: 8, 9: 16, 100.2: 104, 5000: 1024} {
+		if got := gradientTextureSize(size); got != want {
+			t.Fatalf("gradientTextureSize(%f) = %d, want %d", size, got, want)
+		}
+	}
+}
+
+func TestGradientTextureReleasedWhenUnused(t *testing.T) {
+	cache := rendering.NewTextureCache(nil, assets.NewMockDB(map[string][]byte{}))
+	g := testGradient(t, "linear-gradient", "red, blue")
+	cached := func(w, h int) bool {
+		_, ok := cache.Find(gradientTextureKey(&g, w, h), rendering.TextureFilterLinear)
+		return ok
+	}
+	_, first, err := acquireGradientTexture(&cache, &g, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, _ := acquireGradientTexture(&cache, &g, 8, 8)
+	releaseGradientTexture(first)
+	if !cached(8, 8) {
+		t.Fatal("the texture should stay cached while another panel uses it")
+	}
+	_, resized, _ := acquireGradientTexture(&cache, &g, 16, 8)
+	releaseGradientTexture(second)
+	if cached(8, 8) {
+		t.Error("the texture should be removed once no panel uses it")
+	}
+	if !cached(16, 8) {
+		t.Error("the texture of the new size should be cached")
+	}
+	releaseGradientTexture(resized)
+	if cached(16, 8) || len(gradientTextures.refs) != 0 {
+		t.Error("every gradient texture should have been released")
+	}
+}
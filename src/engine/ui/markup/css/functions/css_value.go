@@ -0,0 +1,16 @@
+/******************************************************************************/
+/* css_value.go                                                               */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package functions
+
+import "kaijuengine.com/matrix"
+
+// ParseColor reads a color from a value or function argument, it takes the
+// same colors as the color stops of a gradient
+func ParseColor(str string) (matrix.Color, bool) { return parseGradientColor(str) }
+
+// ParseAngle reads a CSS angle, like "90deg" or "0.25turn", into degrees
+func ParseAngle(str string) (float32, bool) { return parseAngle(str) }
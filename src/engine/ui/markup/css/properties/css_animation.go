@@ -8,6 +8,10 @@ package properties
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -15,8 +19,128 @@ import (
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+var (
+	animationDirections = map[string]document.AnimationDirection{
+		"normal":            document.AnimationDirectionNormal,
+		"reverse":           document.AnimationDirectionReverse,
+		"alternate":         document.AnimationDirectionAlternate,
+		"alternate-reverse": document.AnimationDirectionAlternateReverse,
+	}
+	animationFillModes = map[string]document.AnimationFillMode{
+		"none":      document.AnimationFillModeNone,
+		"forwards":  document.AnimationFillModeForwards,
+		"backwards": document.AnimationFillModeBackwards,
+		"both":      document.AnimationFillModeBoth,
+	}
+	animationPlayStates = map[string]bool{
+		"running": false,
+		"paused":  true,
+	}
+)
+
+// parseTime reads a CSS time like "2s" or "250ms" as seconds
+func parseTime(str string) (float64, bool) {
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(str, "ms"):
+		str, unit = strings.TrimSuffix(str, "ms"), 0.001
+	case strings.HasSuffix(str, "s"):
+		str = strings.TrimSuffix(str, "s")
+	case str != "0":
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * unit, true
+}
+
+func parseIterationCount(str string) (float64, bool) {
+	if str == "infinite" {
+		return math.Inf(1), true
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// name? || duration || timing-function || delay || iteration-count ||
+// direction || fill-mode || play-state, [...]
+func parseAnimationShorthand(values []rules.PropertyValue) ([]document.StyleAnimation, error) {
+	if len(values) == 1 && values[0].Str == "none" {
+		return nil, nil
+	}
+	out := make([]document.StyleAnimation, 0)
+	for _, entry := range rules.SplitValueList(values) {
+		a := document.NewStyleAnimation("")
+		times := 0
+		for _, v := range entry {
+			if t, ok := parseTime(v.Str); ok && !v.IsFunction() {
+				if times == 0 {
+					a.Duration = t
+				} else {
+					a.Delay = t
+				}
+				times++
+			} else if timing, err := parseTimingFunction(v); err == nil {
+				a.Timing = timing
+			} else if count, ok := parseIterationCount(v.Str); ok {
+				a.IterationCount = count
+			} else if dir, ok := animationDirections[v.Str]; ok {
+				a.Direction = dir
+			} else if fill, ok := animationFillModes[v.Str]; ok {
+				a.FillMode = fill
+			} else if paused, ok := animationPlayStates[v.Str]; ok {
+				a.Paused = paused
+			} else if a.Name == "" && !v.IsFunction() {
+				a.Name = strings.Trim(v.Str, `"'`)
+			} else {
+				return nil, fmt.Errorf("unexpected animation value %q", v.Str)
+			}
+		}
+		if a.Name == "" {
+			return nil, errors.New("animation is missing the name of its @keyframes")
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// updateAnimations applies the entries of an animation-* list property to the
+// animations of the element, repeating the list if it is short
+func updateAnimations(elm *document.Element, values []rules.PropertyValue, set func(a *document.StyleAnimation, entry []rules.PropertyValue) error) error {
+	list := rules.SplitValueList(values)
+	animations := elm.Stylizer.Animations()
+	for i := range animations {
+		if err := set(&animations[i], list[i%len(list)]); err != nil {
+			return err
+		}
+	}
+	elm.Stylizer.SetAnimations(animations)
+	return nil
+}
+
+// singleValue returns the only value of an entry of a list property
+func singleValue(key string, entry []rules.PropertyValue) (rules.PropertyValue, error) {
+	if len(entry) != 1 {
+		return rules.PropertyValue{}, fmt.Errorf("expected 1 value for each entry of %s but got %d", key, len(entry))
+	}
+	return entry[0], nil
+}
+
 func (p Animation) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("Animation not implemented")}
+	animations, err := parseAnimationShorthand(values)
+	if err != nil {
+		return err
+	}
+	elm.Stylizer.SetAnimations(animations)
+	return nil
+}
 
-	return problems[0]
+func (Animation) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	elm.Stylizer.SetAnimations(nil)
+	return nil
 }
@@ -7,7 +7,7 @@
 package properties
 
 import (
-	"errors"
+	"fmt"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -16,7 +16,23 @@ import (
 )
 
 func (p AnimationDuration) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("AnimationDuration not implemented")}
+	return updateAnimations(elm, values, func(a *document.StyleAnimation, entry []rules.PropertyValue) error {
+		v, err := singleValue(p.Key(), entry)
+		if err != nil {
+			return err
+		}
+		duration, ok := parseTime(v.Str)
+		if !ok || duration < 0 {
+			return fmt.Errorf("invalid animation duration %q", v.Str)
+		}
+		a.Duration = duration
+		return nil
+	})
+}
 
-	return problems[0]
+func (AnimationDuration) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	return updateAnimations(elm, nil, func(a *document.StyleAnimation, _ []rules.PropertyValue) error {
+		a.Duration = 0
+		return nil
+	})
 }
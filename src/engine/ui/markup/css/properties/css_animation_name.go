@@ -7,7 +7,7 @@
 package properties
 
 import (
-	"errors"
+	"strings"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -15,8 +15,30 @@ import (
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// none|keyframename|initial|inherit
 func (p AnimationName) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("AnimationName not implemented")}
+	current := elm.Stylizer.Animations()
+	animations := make([]document.StyleAnimation, 0, len(current))
+	for i, entry := range rules.SplitValueList(values) {
+		v, err := singleValue(p.Key(), entry)
+		if err != nil {
+			return err
+		}
+		if v.Str == "none" {
+			continue
+		}
+		a := document.NewStyleAnimation("")
+		if i < len(current) {
+			a = current[i]
+		}
+		a.Name = strings.Trim(v.Str, `"'`)
+		animations = append(animations, a)
+	}
+	elm.Stylizer.SetAnimations(animations)
+	return nil
+}
 
-	return problems[0]
+func (AnimationName) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	elm.Stylizer.SetAnimations(nil)
+	return nil
 }
@@ -0,0 +1,102 @@
+/******************************************************************************/
+/* css_animation_test.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package properties
+
+import (
+	"math"
+	"testing"
+
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/engine/ui/markup/document"
+)
+
+func TestParseAnimationShorthandList(t *testing.T) {
+	values := []rules.PropertyValue{
+		{Str: "fade"},
+		{Str: "1s"},
+		{Str: "ease-in"},
+		{Str: "infinite"},
+		{Str: "alternate"},
+		{Str: ","},
+		{Str: "slide"},
+		{Str: "250ms"},
+		{Str: "2s"},
+		{Str: "steps", Args: []string{"4", "start"}},
+		{Str: "both"},
+		{Str: "paused"},
+	}
+	got, err := parseAnimationShorthand(values)
+	if err != nil {
+		t.Fatalf("parseAnimationShorthand returned error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2", len(got))
+	}
+	fade := got[0]
+	if fade.Name != "fade" || fade.Duration != 1 || !math.IsInf(fade.IterationCount, 1) {
+		t.Fatalf("fade = %#v, want a 1s infinite animation", fade)
+	}
+	if fade.Direction != document.AnimationDirectionAlternate {
+		t.Fatalf("fade direction = %v, want alternate", fade.Direction)
+	}
+	slide := got[1]
+	if slide.Name != "slide" || slide.Duration != 0.25 || slide.Delay != 2 {
+		t.Fatalf("slide = %#v, want 250ms with a 2s delay", slide)
+	}
+	if slide.Timing.Steps != 4 || !slide.Timing.StepStart {
+		t.Fatalf("slide timing = %#v, want 4 steps jumping at the start", slide.Timing)
+	}
+	if slide.FillMode != document.AnimationFillModeBoth || !slide.Paused {
+		t.Fatalf("slide = %#v, want a paused animation filling both ways", slide)
+	}
+}
+
+func TestParseAnimationShorthandRequiresName(t *testing.T) {
+	if _, err := parseAnimationShorthand([]rules.PropertyValue{{Str: "1s"}}); err == nil {
+		t.Fatal("expected an error for an animation without a name")
+	}
+	got, err := parseAnimationShorthand([]rules.PropertyValue{{Str: "none"}})
+	if err != nil || got != nil {
+		t.Fatalf("none = (%#v, %v), want no animations", got, err)
+	}
+}
+
+func TestParseTransitionShorthandList(t *testing.T) {
+	values := []rules.PropertyValue{
+		{Str: "opacity"},
+		{Str: "0.5s"},
+		{Str: ","},
+		{Str: "300ms"},
+		{Str: "cubic-bezier", Args: []string{"0.1", "0.7", "1.0", "0.1"}},
+		{Str: "1s"},
+	}
+	got, err := parseTransitionShorthand(values)
+	if err != nil {
+		t.Fatalf("parseTransitionShorthand returned error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2", len(got))
+	}
+	if got[0].Property != "opacity" || got[0].Duration != 0.5 {
+		t.Fatalf("first = %#v, want a 0.5s opacity transition", got[0])
+	}
+	if got[1].Property != "all" || got[1].Duration != 0.3 || got[1].Delay != 1 {
+		t.Fatalf("second = %#v, want a 300ms transition of all properties after 1s", got[1])
+	}
+	if len(got[1].Timing.Bezier) != 4 || got[1].Timing.Bezier[1] != 0.7 {
+		t.Fatalf("second timing = %#v, want the cubic-bezier points", got[1].Timing)
+	}
+}
+
+func TestParseTimingFunctionRejectsUnknown(t *testing.T) {
+	if _, err := parseTimingFunction(rules.PropertyValue{Str: "wobble"}); err == nil {
+		t.Fatal("expected an error for an unknown timing keyword")
+	}
+	if _, err := parseTimingFunction(rules.PropertyValue{Str: "cubic-bezier", Args: []string{"0", "1"}}); err == nil {
+		t.Fatal("expected an error for a cubic-bezier with too few points")
+	}
+}
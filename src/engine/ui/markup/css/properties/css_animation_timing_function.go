@@ -7,7 +7,8 @@
 package properties
 
 import (
-	"errors"
+	"fmt"
+	"strconv"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -15,8 +16,56 @@ import (
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// parseTimingFunction reads a timing keyword like "ease-in-out" or one of the
+// cubic-bezier() and steps() functions
+func parseTimingFunction(v rules.PropertyValue) (document.TimingFunction, error) {
+	switch v.Str {
+	case "cubic-bezier":
+		if len(v.Args) != 4 {
+			return document.TimingFunction{}, fmt.Errorf("expected 4 arguments to cubic-bezier but got %d", len(v.Args))
+		}
+		points := make([]float32, 4)
+		for i := range v.Args {
+			p, err := strconv.ParseFloat(v.Args[i], 32)
+			if err != nil {
+				return document.TimingFunction{}, err
+			}
+			points[i] = float32(p)
+		}
+		return document.TimingFunction{Bezier: points}, nil
+	case "steps":
+		if len(v.Args) < 1 || len(v.Args) > 2 {
+			return document.TimingFunction{}, fmt.Errorf("expected 1 or 2 arguments to steps but got %d", len(v.Args))
+		}
+		steps, err := strconv.Atoi(v.Args[0])
+		if err != nil || steps < 1 {
+			return document.TimingFunction{}, fmt.Errorf("invalid number of steps %q", v.Args[0])
+		}
+		f := document.TimingFunction{Steps: steps}
+		if len(v.Args) == 2 {
+			f.StepStart = v.Args[1] == "start" || v.Args[1] == "jump-start"
+		}
+		return f, nil
+	}
+	if f, ok := document.TimingFunctionByName(v.Str); ok && !v.IsFunction() {
+		return f, nil
+	}
+	return document.TimingFunction{}, fmt.Errorf("unknown timing function %q", v.Str)
+}
+
 func (p AnimationTimingFunction) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("AnimationTimingFunction not implemented")}
+	return updateAnimations(elm, values, func(a *document.StyleAnimation, entry []rules.PropertyValue) error {
+		v, err := singleValue(p.Key(), entry)
+		if err == nil {
+			a.Timing, err = parseTimingFunction(v)
+		}
+		return err
+	})
+}
 
-	return problems[0]
+func (AnimationTimingFunction) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	return updateAnimations(elm, nil, func(a *document.StyleAnimation, _ []rules.PropertyValue) error {
+		a.Timing = document.NewStyleAnimation("").Timing
+		return nil
+	})
 }
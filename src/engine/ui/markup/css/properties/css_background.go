@@ -12,6 +12,7 @@ import (
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
+	"kaijuengine.com/engine/ui/markup/css/functions"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
@@ -22,14 +23,16 @@ func (p Background) Process(panel *ui.Panel, elm *document.Element, values []rul
 	}
 
 	// Support:
-	// 1) Single color token:
+	// 1) Single color or gradient token:
 	//    background: #314052;
 	//    background: rgb(49, 64, 82);
 	// 2) Single image token:
 	//    background: url("panel_bg.png");
+	//    background: linear-gradient(to right, #314052, #202733);
 	// 3) Multi-token values that include a color token:
 	//    background: no-repeat center/cover #314052;
 	//    background: fixed url("panel_bg.png") #202733;
+	//    background: radial-gradient(circle, #314052, transparent) #202733;
 	//
 	// NOTE: this is intentionally partial shorthand support. Non-color/non-url
 	// components are not fully expanded into individual background-* properties.
@@ -40,12 +43,24 @@ func (p Background) Process(panel *ui.Panel, elm *document.Element, values []rul
 	// - repeat/attachment/origin/clip token decomposition
 	if len(values) == 1 {
 		v := values[0]
-		if strings.HasPrefix(v.Str, "url(") || (v.IsFunction() && v.Str == "url") {
+		if strings.HasPrefix(v.Str, "url(") || (v.IsFunction() && v.Str == "url") ||
+			functions.IsGradient(v.Str) {
 			return BackgroundImage{}.Process(panel, elm, values, host)
 		}
 		return BackgroundColor{}.Process(panel, elm, values, host)
 	}
 
+	// A gradient is drawn over the color, so it is applied first and the
+	// color is left to show through its transparent parts
+	for i := range values {
+		if functions.IsGradient(values[i].Str) {
+			if err := (BackgroundImage{}).Process(panel, elm, values[i:i+1], host); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
 	// CSS allows the color token to appear among other tokens.
 	// Example: background: url("panel.png") no-repeat center / cover #314052;
 	// Scan from right-to-left and apply the first parseable color.
@@ -12,7 +12,9 @@ import (
 	"strings"
 
 	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/assets"
 	"kaijuengine.com/engine/ui"
+	"kaijuengine.com/engine/ui/markup/css/functions"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 	"kaijuengine.com/matrix"
@@ -24,6 +26,14 @@ func (p BackgroundImage) Process(panel *ui.Panel, elm *document.Element, values
 		return fmt.Errorf("Expected exactly 1 value but got %d", len(values))
 	}
 
+	if functions.IsGradient(values[0].Str) {
+		_, err := functions.ApplyGradientBackground(panel, values[0])
+		return err
+	}
+	if values[0].Str == "none" {
+		return BackgroundImage{}.Reset(panel, elm, host)
+	}
+
 	reg := regexp.MustCompile(`url\s{0,}\(\s{0,}"(.*?)"\s{0,}\)`)
 	parts := reg.FindStringSubmatch(values[0].Str)
 	if len(parts) != 2 {
@@ -36,7 +46,21 @@ func (p BackgroundImage) Process(panel *ui.Panel, elm *document.Element, values
 		return err
 	}
 
+	functions.ClearGradientBackground(panel)
 	panel.SetBackground(tex)
 	panel.SetColor(matrix.ColorWhite())
 	return nil
 }
+
+// Reset puts back the plain background that a gradient replaced
+func (BackgroundImage) Reset(panel *ui.Panel, _ *document.Element, host *engine.Host) error {
+	if !functions.ClearGradientBackground(panel) {
+		return nil
+	}
+	tex, err := host.TextureCache().Texture(assets.TextureSquare, rendering.TextureFilterLinear)
+	if err != nil {
+		return err
+	}
+	panel.SetBackground(tex)
+	return nil
+}
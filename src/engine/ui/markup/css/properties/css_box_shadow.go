@@ -8,15 +8,104 @@ package properties
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
+	"kaijuengine.com/engine/ui/markup/css/functions"
+	"kaijuengine.com/engine/ui/markup/css/helpers"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
+	"kaijuengine.com/matrix"
 )
 
+// shadowValueString turns a property value back into a single token, color
+// functions like rgba(0, 0, 0, 0.5) are joined as "rgba(0,0,0,0.5)"
+func shadowValueString(v rules.PropertyValue) string {
+	if len(v.Args) == 0 {
+		return v.Str
+	}
+	return v.Str + "(" + strings.Join(v.Args, ",") + ")"
+}
+
+func isShadowLength(str string) bool {
+	return str != "" && strings.ContainsAny(str[:1], "0123456789+-.")
+}
+
+// parseShadow reads a single shadow from its tokens. The lengths are the X
+// offset, Y offset, blur and (for box shadows) spread in that order, the
+// color and the inset keyword can come before or after them.
+func parseShadow(parts []string, box bool, window helpers.WindowDimensions) (ui.Shadow, error) {
+	s := ui.Shadow{Color: matrix.ColorBlack()}
+	lengths := make([]float32, 0, 4)
+	hasColor := false
+	for _, p := range parts {
+		if box && p == "inset" && !s.Inset {
+			s.Inset = true
+		} else if isShadowLength(p) {
+			lengths = append(lengths, helpers.NumFromLength(p, window))
+		} else if c, ok := functions.ParseColor(p); ok && !hasColor {
+			s.Color = c
+			hasColor = true
+		} else {
+			return s, fmt.Errorf("invalid shadow value '%s'", p)
+		}
+	}
+	maxLengths := 3
+	if box {
+		maxLengths = 4
+	}
+	if len(lengths) < 2 || len(lengths) > maxLengths {
+		return s, fmt.Errorf("expected 2 to %d shadow lengths but got %d",
+			maxLengths, len(lengths))
+	}
+	s.Offset = matrix.NewVec2(lengths[0], lengths[1])
+	if len(lengths) > 2 {
+		if lengths[2] < 0 {
+			return s, errors.New("the shadow blur can not be negative")
+		}
+		s.Blur = lengths[2]
+	}
+	if len(lengths) > 3 {
+		s.Spread = lengths[3]
+	}
+	return s, nil
+}
+
+// parseShadowList reads the comma separated shadows of a box-shadow or a
+// text-shadow, "none" results in no shadows
+func parseShadowList(values []rules.PropertyValue, box bool, window helpers.WindowDimensions) ([]ui.Shadow, error) {
+	if len(values) == 1 && values[0].Str == "none" {
+		return nil, nil
+	}
+	shadows := []ui.Shadow{}
+	parts := []string{}
+	for i := 0; i <= len(values); i++ {
+		if i < len(values) && values[i].Str != "," {
+			parts = append(parts, shadowValueString(values[i]))
+			continue
+		}
+		s, err := parseShadow(parts, box, window)
+		if err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, s)
+		parts = parts[:0]
+	}
+	return shadows, nil
+}
+
 func (p BoxShadow) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("BoxShadow not implemented")}
+	shadows, err := parseShadowList(values, true, host.Window)
+	if err != nil {
+		return err
+	}
+	panel.SetBoxShadows(shadows)
+	return nil
+}
 
-	return problems[0]
+func (BoxShadow) Reset(panel *ui.Panel, _ *document.Element, _ *engine.Host) error {
+	panel.SetBoxShadows(nil)
+	return nil
 }
@@ -0,0 +1,76 @@
+/******************************************************************************/
+/* css_box_shadow_test.go                                                     */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package properties
+
+import (
+	"testing"
+
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/matrix"
+)
+
+type shadowTestWindow struct{}
+
+func (shadowTestWindow) DotsPerMillimeter() float64 { return 1 }
+func (shadowTestWindow) Width() int                 { return 800 }
+func (shadowTestWindow) Height() int                { return 600 }
+
+func TestParseBoxShadowList(t *testing.T) {
+	values := []rules.PropertyValue{
+		{Str: "inset"},
+		{Str: "2px"},
+		{Str: "4px"},
+		{Str: "6px"},
+		{Str: "-1px"},
+		{Str: "rgba", Args: []string{"0", "0", "0", "0.5"}},
+		{Str: ","},
+		{Str: "red"},
+		{Str: "0"},
+		{Str: "3px"},
+	}
+	got, err := parseShadowList(values, true, shadowTestWindow{})
+	if err != nil {
+		t.Fatalf("parseShadowList returned error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2", len(got))
+	}
+	first := got[0]
+	if !first.Inset || !first.Offset.Equals(matrix.NewVec2(2, 4)) ||
+		first.Blur != 6 || first.Spread != -1 {
+		t.Errorf("unexpected first shadow %+v", first)
+	}
+	if matrix.Abs(first.Color.A()-0.5) > 0.01 {
+		t.Errorf("first shadow alpha = %f, want 0.5", first.Color.A())
+	}
+	second := got[1]
+	if second.Inset || !second.Offset.Equals(matrix.NewVec2(0, 3)) || second.Blur != 0 {
+		t.Errorf("unexpected second shadow %+v", second)
+	}
+	if !second.Color.Equals(matrix.ColorRed()) {
+		t.Errorf("second shadow color = %v, want red", second.Color)
+	}
+}
+
+func TestParseShadowListErrors(t *testing.T) {
+	tests := map[string][]rules.PropertyValue{
+		"one length":    {{Str: "2px"}},
+		"negative blur": {{Str: "2px"}, {Str: "2px"}, {Str: "-2px"}},
+		"two colors":    {{Str: "red"}, {Str: "1px"}, {Str: "1px"}, {Str: "blue"}},
+		"text spread":   {{Str: "1px"}, {Str: "1px"}, {Str: "1px"}, {Str: "1px"}},
+		"text inset":    {{Str: "inset"}, {Str: "1px"}, {Str: "1px"}},
+	}
+	for name, values := range tests {
+		if _, err := parseShadowList(values, false, shadowTestWindow{}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	got, err := parseShadowList([]rules.PropertyValue{{Str: "none"}}, true, shadowTestWindow{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("none = %v, %v; want no shadows", got, err)
+	}
+}
@@ -7,16 +7,110 @@
 package properties
 
 import (
-	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
+	"kaijuengine.com/engine/ui/markup/css/functions"
+	"kaijuengine.com/engine/ui/markup/css/helpers"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
+	"kaijuengine.com/matrix"
 )
 
+var filterColorFunctions = map[string]func(float32) matrix.ColorMatrix{
+	"brightness": matrix.ColorMatrixBrightness,
+	"contrast":   matrix.ColorMatrixContrast,
+	"grayscale":  matrix.ColorMatrixGrayscale,
+	"invert":     matrix.ColorMatrixInvert,
+	"opacity":    matrix.ColorMatrixOpacity,
+	"saturate":   matrix.ColorMatrixSaturate,
+	"sepia":      matrix.ColorMatrixSepia,
+}
+
+// parseFilterAmount reads a number or percentage, a missing amount is 1
+func parseFilterAmount(args []string) (float32, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	if len(args) > 1 {
+		return 0, fmt.Errorf("expected 1 filter amount but got %d", len(args))
+	}
+	num, percent := strings.CutSuffix(args[0], "%")
+	v, err := strconv.ParseFloat(num, 32)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid filter amount '%s'", args[0])
+	}
+	if percent {
+		v /= 100
+	}
+	return float32(v), nil
+}
+
+// parseFilter reads the filter functions in order, the color functions are
+// combined into a single color matrix that applies them one after another
+func parseFilter(values []rules.PropertyValue, window helpers.WindowDimensions) (ui.Filter, error) {
+	f := ui.NewFilter()
+	if len(values) == 1 && values[0].Str == "none" {
+		return f, nil
+	}
+	for _, v := range values {
+		if fn, ok := filterColorFunctions[v.Str]; ok {
+			amount, err := parseFilterAmount(v.Args)
+			if err != nil {
+				return f, err
+			}
+			f.Color = f.Color.Then(fn(amount))
+			continue
+		}
+		switch v.Str {
+		case "hue-rotate":
+			angle := float32(0)
+			if len(v.Args) > 0 {
+				var ok bool
+				if angle, ok = functions.ParseAngle(v.Args[0]); !ok || len(v.Args) > 1 {
+					return f, fmt.Errorf("invalid hue-rotate angle '%s'", strings.Join(v.Args, " "))
+				}
+			}
+			f.Color = f.Color.Then(matrix.ColorMatrixHueRotate(angle))
+		case "blur":
+			if len(v.Args) > 1 {
+				return f, fmt.Errorf("expected 1 blur length but got %d", len(v.Args))
+			}
+			blur := float32(0)
+			if len(v.Args) == 1 {
+				blur = helpers.NumFromLength(v.Args[0], window)
+			}
+			if blur < 0 {
+				return f, fmt.Errorf("invalid blur length '%s'", v.Args[0])
+			}
+			f.Blur = float32(math.Hypot(float64(f.Blur), float64(blur)))
+		case "drop-shadow":
+			s, err := parseShadow(v.Args, false, window)
+			if err != nil {
+				return f, err
+			}
+			f.DropShadows = append(f.DropShadows, s)
+		default:
+			return f, fmt.Errorf("unsupported filter function '%s'", v.Str)
+		}
+	}
+	return f, nil
+}
+
 func (p Filter) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("Filter not implemented")}
+	f, err := parseFilter(values, host.Window)
+	if err != nil {
+		return err
+	}
+	panel.Base().SetFilter(f)
+	return nil
+}
 
-	return problems[0]
+func (Filter) Reset(panel *ui.Panel, _ *document.Element, _ *engine.Host) error {
+	panel.Base().ClearFilter()
+	return nil
 }
@@ -0,0 +1,63 @@
+/******************************************************************************/
+/* css_filter_test.go                                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package properties
+
+import (
+	"testing"
+
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/matrix"
+)
+
+func TestParseFilterFunctions(t *testing.T) {
+	values := []rules.PropertyValue{
+		{Str: "grayscale", Args: []string{"100%"}},
+		{Str: "brightness", Args: []string{"0.5"}},
+		{Str: "blur", Args: []string{"3px"}},
+		{Str: "blur", Args: []string{"4px"}},
+		{Str: "drop-shadow", Args: []string{"1px", "2px", "3px", "rgba(0,0,0,0.25)"}},
+	}
+	f, err := parseFilter(values, shadowTestWindow{})
+	if err != nil {
+		t.Fatalf("parseFilter returned error: %v", err)
+	}
+	got := f.Color.Apply(matrix.NewColor(1, 0, 0, 1))
+	want := matrix.ColorMatrixGrayscale(1).Then(matrix.ColorMatrixBrightness(0.5)).
+		Apply(matrix.NewColor(1, 0, 0, 1))
+	if !got.Equals(want) {
+		t.Errorf("filtered color = %v, want %v", got, want)
+	}
+	if !matrix.Approx(f.Blur, 5) {
+		t.Errorf("blur = %f, want the blurs combined to 5", f.Blur)
+	}
+	if len(f.DropShadows) != 1 {
+		t.Fatalf("len(DropShadows) = %d, want 1", len(f.DropShadows))
+	}
+	s := f.DropShadows[0]
+	if !s.Offset.Equals(matrix.NewVec2(1, 2)) || s.Blur != 3 ||
+		matrix.Abs(s.Color.A()-0.25) > 0.01 {
+		t.Errorf("unexpected drop shadow %+v", s)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := map[string]rules.PropertyValue{
+		"unknown":       {Str: "url", Args: []string{"#filter"}},
+		"negative":      {Str: "contrast", Args: []string{"-1"}},
+		"bad angle":     {Str: "hue-rotate", Args: []string{"90px"}},
+		"shadow length": {Str: "drop-shadow", Args: []string{"1px"}},
+	}
+	for name, v := range tests {
+		if _, err := parseFilter([]rules.PropertyValue{v}, shadowTestWindow{}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	f, err := parseFilter([]rules.PropertyValue{{Str: "none"}}, shadowTestWindow{})
+	if err != nil || !f.IsEmpty() {
+		t.Errorf("none = %+v, %v; want an empty filter", f, err)
+	}
+}
@@ -72,6 +72,9 @@ func (TextShadow) StyleImpact() document.StyleImpact              { return docum
 func (UserSelect) StyleImpact() document.StyleImpact              { return document.StyleImpactPaint }
 
 func (Background) Reset(panel *ui.Panel, elm *document.Element, host *engine.Host) error {
+	if err := (BackgroundImage{}).Reset(panel, elm, host); err != nil {
+		return err
+	}
 	return (BackgroundColor{}).Reset(panel, elm, host)
 }
 
@@ -62,41 +62,49 @@ func (p Animation) Key() string { return "animation" }
 type AnimationDelay struct{ PropertyBase }
 
 func (p AnimationDelay) Key() string { return "animation-delay" }
+func (p AnimationDelay) Sort() int   { return 2 }
 
 // Specifies whether an animation should be played forwards, backwards or in alternate cycles
 type AnimationDirection struct{ PropertyBase }
 
 func (p AnimationDirection) Key() string { return "animation-direction" }
+func (p AnimationDirection) Sort() int   { return 2 }
 
 // Specifies how long an animation should take to complete one cycle
 type AnimationDuration struct{ PropertyBase }
 
 func (p AnimationDuration) Key() string { return "animation-duration" }
+func (p AnimationDuration) Sort() int   { return 2 }
 
 // Specifies a style for the element when the animation is not playing (before it starts, after it ends, or both)
 type AnimationFillMode struct{ PropertyBase }
 
 func (p AnimationFillMode) Key() string { return "animation-fill-mode" }
+func (p AnimationFillMode) Sort() int   { return 2 }
 
 // Specifies the number of times an animation should be played
 type AnimationIterationCount struct{ PropertyBase }
 
 func (p AnimationIterationCount) Key() string { return "animation-iteration-count" }
+func (p AnimationIterationCount) Sort() int   { return 2 }
 
 // Specifies a name for the @keyframes animation
 type AnimationName struct{ PropertyBase }
 
 func (p AnimationName) Key() string { return "animation-name" }
+func (p AnimationName) Sort() int   { return 1 }
 
 // Specifies whether the animation is running or paused
 type AnimationPlayState struct{ PropertyBase }
 
 func (p AnimationPlayState) Key() string { return "animation-play-state" }
+func (p AnimationPlayState) Sort() int   { return 2 }
 
 // Specifies the speed curve of an animation
 type AnimationTimingFunction struct{ PropertyBase }
 
 func (p AnimationTimingFunction) Key() string { return "animation-timing-function" }
+func (p AnimationTimingFunction) Sort() int   { return 2 }
 
 // Specifies preferred aspect ratio of an element
 type AspectRatio struct{ PropertyBase }
@@ -1710,21 +1718,25 @@ func (p Transition) Key() string { return "transition" }
 type TransitionDelay struct{ PropertyBase }
 
 func (p TransitionDelay) Key() string { return "transition-delay" }
+func (p TransitionDelay) Sort() int   { return 2 }
 
 // Specifies how many seconds or milliseconds a transition effect takes to complete
 type TransitionDuration struct{ PropertyBase }
 
 func (p TransitionDuration) Key() string { return "transition-duration" }
+func (p TransitionDuration) Sort() int   { return 2 }
 
 // Specifies the name of the CSS property the transition effect is for
 type TransitionProperty struct{ PropertyBase }
 
 func (p TransitionProperty) Key() string { return "transition-property" }
+func (p TransitionProperty) Sort() int   { return 1 }
 
 // Specifies the speed curve of the transition effect
 type TransitionTimingFunction struct{ PropertyBase }
 
 func (p TransitionTimingFunction) Key() string { return "transition-timing-function" }
+func (p TransitionTimingFunction) Sort() int   { return 2 }
 
 // Specifies the position of an element
 type Translate struct{ PropertyBase }
@@ -7,16 +7,31 @@
 package properties
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+func setChildTextShadows(elm *document.Element, shadows []ui.Shadow) {
+	for _, c := range elm.Children {
+		if c.IsText() {
+			c.UI.ToLabel().SetTextShadows(shadows)
+		}
+		setChildTextShadows(c, shadows)
+	}
+}
+
 func (p TextShadow) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("TextShadow not implemented")}
+	shadows, err := parseShadowList(values, false, host.Window)
+	if err != nil {
+		return err
+	}
+	setChildTextShadows(elm, shadows)
+	return nil
+}
 
-	return problems[0]
+func (TextShadow) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	setChildTextShadows(elm, nil)
+	return nil
 }
@@ -7,7 +7,7 @@
 package properties
 
 import (
-	"errors"
+	"fmt"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -15,8 +15,66 @@ import (
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// property || duration || timing-function || delay, [...]
+func parseTransitionShorthand(values []rules.PropertyValue) ([]document.StyleTransition, error) {
+	if len(values) == 1 && values[0].Str == "none" {
+		return nil, nil
+	}
+	out := make([]document.StyleTransition, 0)
+	for _, entry := range rules.SplitValueList(values) {
+		t := document.NewStyleTransition("all")
+		times := 0
+		hasProperty := false
+		for _, v := range entry {
+			if s, ok := parseTime(v.Str); ok && !v.IsFunction() {
+				if times == 0 {
+					t.Duration = s
+				} else {
+					t.Delay = s
+				}
+				times++
+			} else if timing, err := parseTimingFunction(v); err == nil {
+				t.Timing = timing
+			} else if !hasProperty && !v.IsFunction() {
+				t.Property = v.Str
+				hasProperty = true
+			} else {
+				return nil, fmt.Errorf("unexpected transition value %q", v.Str)
+			}
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// updateTransitions applies the entries of a transition-* list property to
+// the transitions of the element, repeating the list if it is short
+func updateTransitions(elm *document.Element, values []rules.PropertyValue, set func(t *document.StyleTransition, entry []rules.PropertyValue) error) error {
+	list := rules.SplitValueList(values)
+	transitions := elm.Stylizer.Transitions()
+	if len(transitions) == 0 && len(values) > 0 {
+		// Without a transition-property every property is transitioned
+		transitions = append(transitions, document.NewStyleTransition("all"))
+	}
+	for i := range transitions {
+		if err := set(&transitions[i], list[i%len(list)]); err != nil {
+			return err
+		}
+	}
+	elm.Stylizer.SetTransitions(transitions)
+	return nil
+}
+
 func (p Transition) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("Transition not implemented")}
+	transitions, err := parseTransitionShorthand(values)
+	if err != nil {
+		return err
+	}
+	elm.Stylizer.SetTransitions(transitions)
+	return nil
+}
 
-	return problems[0]
+func (Transition) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	elm.Stylizer.SetTransitions(nil)
+	return nil
 }
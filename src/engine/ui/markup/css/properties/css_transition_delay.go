@@ -7,7 +7,7 @@
 package properties
 
 import (
-	"errors"
+	"fmt"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -16,7 +16,23 @@ import (
 )
 
 func (p TransitionDelay) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("TransitionDelay not implemented")}
+	return updateTransitions(elm, values, func(t *document.StyleTransition, entry []rules.PropertyValue) error {
+		v, err := singleValue(p.Key(), entry)
+		if err != nil {
+			return err
+		}
+		delay, ok := parseTime(v.Str)
+		if !ok {
+			return fmt.Errorf("invalid transition delay %q", v.Str)
+		}
+		t.Delay = delay
+		return nil
+	})
+}
 
-	return problems[0]
+func (TransitionDelay) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	return updateTransitions(elm, nil, func(t *document.StyleTransition, _ []rules.PropertyValue) error {
+		t.Delay = 0
+		return nil
+	})
 }
@@ -7,7 +7,7 @@
 package properties
 
 import (
-	"errors"
+	"fmt"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
@@ -16,7 +16,23 @@ import (
 )
 
 func (p TransitionDuration) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("TransitionDuration not implemented")}
+	return updateTransitions(elm, values, func(t *document.StyleTransition, entry []rules.PropertyValue) error {
+		v, err := singleValue(p.Key(), entry)
+		if err != nil {
+			return err
+		}
+		duration, ok := parseTime(v.Str)
+		if !ok || duration < 0 {
+			return fmt.Errorf("invalid transition duration %q", v.Str)
+		}
+		t.Duration = duration
+		return nil
+	})
+}
 
-	return problems[0]
+func (TransitionDuration) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	return updateTransitions(elm, nil, func(t *document.StyleTransition, _ []rules.PropertyValue) error {
+		t.Duration = 0
+		return nil
+	})
 }
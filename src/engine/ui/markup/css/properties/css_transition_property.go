@@ -7,16 +7,36 @@
 package properties
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// none|all|property|initial|inherit
 func (p TransitionProperty) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("TransitionProperty not implemented")}
+	current := elm.Stylizer.Transitions()
+	transitions := make([]document.StyleTransition, 0, len(current))
+	for i, entry := range rules.SplitValueList(values) {
+		v, err := singleValue(p.Key(), entry)
+		if err != nil {
+			return err
+		}
+		if v.Str == "none" {
+			continue
+		}
+		t := document.NewStyleTransition("")
+		if i < len(current) {
+			t = current[i]
+		}
+		t.Property = v.Str
+		transitions = append(transitions, t)
+	}
+	elm.Stylizer.SetTransitions(transitions)
+	return nil
+}
 
-	return problems[0]
+func (TransitionProperty) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	elm.Stylizer.SetTransitions(nil)
+	return nil
 }
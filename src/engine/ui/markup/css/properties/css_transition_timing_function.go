@@ -7,8 +7,6 @@
 package properties
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/ui"
 	"kaijuengine.com/engine/ui/markup/css/rules"
@@ -16,7 +14,21 @@ import (
 )
 
 func (p TransitionTimingFunction) Process(panel *ui.Panel, elm *document.Element, values []rules.PropertyValue, host *engine.Host) error {
-	problems := []error{errors.New("TransitionTimingFunction not implemented")}
+	return updateTransitions(elm, values, func(t *document.StyleTransition, entry []rules.PropertyValue) error {
+		v, err := singleValue(p.Key(), entry)
+		if err != nil {
+			return err
+		}
+		if t.Timing, err = parseTimingFunction(v); err != nil {
+			return err
+		}
+		return nil
+	})
+}
 
-	return problems[0]
+func (TransitionTimingFunction) Reset(_ *ui.Panel, elm *document.Element, _ *engine.Host) error {
+	return updateTransitions(elm, nil, func(t *document.StyleTransition, _ []rules.PropertyValue) error {
+		t.Timing = document.NewStyleTransition("").Timing
+		return nil
+	})
 }
@@ -7,12 +7,14 @@
 package pseudos
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+func isLink(elm *document.Element) bool {
+	return (elm.Data == "a" || elm.Data == "area") && elm.HasAttribute("href")
+}
+
 func (p AnyLink) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	return selectIf(elm, isLink(elm)), nil
 }
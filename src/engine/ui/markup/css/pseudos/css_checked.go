@@ -7,12 +7,33 @@
 package pseudos
 
 import (
-	"errors"
+	"strings"
 
+	"kaijuengine.com/engine/ui"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+func isChecked(elm *document.Element) bool {
+	switch {
+	case elm.IsInput():
+		if elm.UI != nil && elm.UI.IsType(ui.ElementTypeCheckbox) {
+			return elm.UI.ToCheckbox().IsChecked()
+		}
+		switch strings.ToLower(elm.Attribute("type")) {
+		case "checkbox", "radio":
+			return elm.HasAttribute("checked")
+		}
+	case elm.IsSelectOption():
+		return elm.HasAttribute("selected")
+	}
+	return false
+}
+
 func (p Checked) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	return selectIf(elm, isChecked(elm)), nil
+}
+
+func (p Checked) StateEvents() []ui.EventType {
+	return []ui.EventType{ui.EventTypeChange}
 }
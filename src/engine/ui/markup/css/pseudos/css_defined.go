@@ -7,12 +7,12 @@
 package pseudos
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// Defined matches every element, there are no custom elements that could be
+// waiting to be defined
 func (p Defined) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	return selectIf(elm, isElementNode(elm)), nil
 }
@@ -7,12 +7,21 @@
 package pseudos
 
 import (
-	"errors"
+	"strings"
 
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
+
+	"golang.org/x/net/html"
 )
 
+// Empty matches elements without children, text that is only white space is
+// ignored as it is by the selectors level 4 spec
 func (p Empty) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	for _, c := range elm.Children {
+		if isElementNode(c) || (c.Type == html.TextNode && strings.TrimSpace(c.Data) != "") {
+			return []*document.Element{}, nil
+		}
+	}
+	return selectIf(elm, isElementNode(elm)), nil
 }
@@ -7,12 +7,40 @@
 package pseudos
 
 import (
-	"errors"
-
+	"kaijuengine.com/engine/ui"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+func isFocused(elm *document.Element) bool {
+	if elm.UI == nil {
+		return false
+	}
+	switch elm.UI.Type() {
+	case ui.ElementTypeInput:
+		return elm.UI.ToInput().IsFocused()
+	case ui.ElementTypeTextArea:
+		return elm.UI.ToTextArea().IsFocused()
+	}
+	return false
+}
+
+func hasFocusWithin(elm *document.Element) bool {
+	if isFocused(elm) {
+		return true
+	}
+	for _, c := range elm.Children {
+		if hasFocusWithin(c) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p FocusWithin) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	return selectIf(elm, isElementNode(elm) && hasFocusWithin(elm)), nil
+}
+
+func (p FocusWithin) StateEvents() []ui.EventType {
+	return []ui.EventType{ui.EventTypeFocus, ui.EventTypeBlur}
 }
@@ -13,6 +13,36 @@ import (
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// hasCandidates are the elements a relative selector of the element could
+// match, which are its descendants and the descendants of its siblings
+func hasCandidates(elm *document.Element, yield func(*document.Element) bool) bool {
+	var walk func(e *document.Element) bool
+	walk = func(e *document.Element) bool {
+		for _, c := range e.Children {
+			if isElementNode(c) && (yield(c) || walk(c)) {
+				return true
+			}
+		}
+		return false
+	}
+	if p := elm.Parent.Value(); p != nil {
+		return walk(p)
+	}
+	return walk(elm)
+}
+
 func (p Has) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	list := rules.ParseSelectorList(value.Args)
+	if len(list) == 0 {
+		return []*document.Element{}, errors.New(":has requires a selector argument")
+	}
+	found := hasCandidates(elm, func(c *document.Element) bool {
+		for i := range list {
+			if selectorMatches(c, list[i].Parts, elm) {
+				return true
+			}
+		}
+		return false
+	})
+	return selectIf(elm, found), nil
 }
@@ -14,5 +14,9 @@ import (
 )
 
 func (p Is) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	list := rules.ParseSelectorList(value.Args)
+	if len(list) == 0 {
+		return []*document.Element{}, errors.New(":is requires a selector argument")
+	}
+	return selectIf(elm, selectorListMatches(elm, list)), nil
 }
@@ -9,48 +9,126 @@ package pseudos
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
-	"kaijuengine.com/engine/ui/markup/css/helpers"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
-func nth(args []string, count int) (int, int, error) {
-	if len(args) == 0 {
-		return 0, 0, errors.New("no arguments supplied")
-	} else if count == 0 {
-		return 0, 0, errors.New("no children")
-	} else {
-		start := 0
-		skip := 0
-		prevArg := args[0]
-		defer func() { args[0] = prevArg }()
-		var err error
-		switch args[0] {
-		case "odd":
-			start = 1
-			fallthrough
-		case "even":
-			args[0] = "2"
+// nthPattern is the An+B argument of the :nth-*() pseudo classes, it selects
+// every element whose 1 based position is A*n+B for some n >= 0
+type nthPattern struct {
+	a, b int
+}
+
+func parseNth(args []string) (nthPattern, error) {
+	str := strings.ToLower(strings.Join(strings.Fields(strings.Join(args, "")), ""))
+	if str == "" {
+		return nthPattern{}, errors.New("no arguments supplied")
+	}
+	switch str {
+	case "odd":
+		return nthPattern{2, 1}, nil
+	case "even":
+		return nthPattern{2, 0}, nil
+	}
+	aStr, bStr, hasN := strings.Cut(str, "n")
+	if !hasN {
+		b, err := strconv.Atoi(str)
+		if err != nil {
+			return nthPattern{}, fmt.Errorf("invalid nth value: %s", str)
 		}
-		helpers.ChangeNToChildCount(args, count)
-		if skip, err = helpers.ArithmeticString(args); err != nil {
-			return 0, 0, err
-		} else if skip <= 0 {
-			return 0, 0, fmt.Errorf("invalid skip value: %d", skip)
+		return nthPattern{0, b}, nil
+	}
+	n := nthPattern{}
+	var err error
+	switch aStr {
+	case "", "+":
+		n.a = 1
+	case "-":
+		n.a = -1
+	default:
+		if n.a, err = strconv.Atoi(aStr); err != nil {
+			return nthPattern{}, fmt.Errorf("invalid nth value: %s", str)
+		}
+	}
+	if bStr != "" {
+		if n.b, err = strconv.Atoi(bStr); err != nil {
+			return nthPattern{}, fmt.Errorf("invalid nth value: %s", str)
 		}
-		return start, skip, nil
 	}
+	return n, nil
 }
 
-func (p NthChild) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	if start, skip, err := nth(value.Args, len(elm.Children)); err == nil {
-		selected := make([]*document.Element, 0)
-		for i := start; i < len(elm.Children); i += skip {
-			selected = append(selected, elm.Children[i])
+func (n nthPattern) matches(position int) bool {
+	if n.a == 0 {
+		return position == n.b
+	}
+	offset := position - n.b
+	return offset/n.a >= 0 && offset%n.a == 0
+}
+
+// nthPosition is the 1 based position of the element among the siblings that
+// are kept, counting from the last sibling when fromEnd is set. It is 0 if
+// the element itself isn't kept.
+func nthPosition(elm *document.Element, keep func(*document.Element) bool, fromEnd bool) int {
+	if !isElementNode(elm) || !keep(elm) {
+		return 0
+	}
+	siblings := elementSiblings(elm)
+	position := 0
+	for i := range siblings {
+		s := siblings[i]
+		if fromEnd {
+			s = siblings[len(siblings)-1-i]
 		}
-		return selected, nil
-	} else {
+		if keep(s) {
+			position++
+		}
+		if s == elm {
+			return position
+		}
+	}
+	return 0
+}
+
+func anyElement(*document.Element) bool { return true }
+
+func sameTypeAs(elm *document.Element) func(*document.Element) bool {
+	return func(s *document.Element) bool { return strings.EqualFold(s.Data, elm.Data) }
+}
+
+// nthChildMatches reads the An+B [of S] argument of :nth-child() and
+// :nth-last-child() and matches it against the element
+func nthChildMatches(elm *document.Element, value rules.SelectorPart, fromEnd bool) ([]*document.Element, error) {
+	args, of := rules.SplitNthOf(value.Args)
+	n, err := parseNth(args)
+	if err != nil {
 		return []*document.Element{}, err
 	}
+	keep := anyElement
+	if of != nil {
+		keep = func(s *document.Element) bool { return selectorListMatches(s, of) }
+	}
+	return selectIf(elm, n.matches(nthPosition(elm, keep, fromEnd))), nil
+}
+
+func nthOfTypeMatches(elm *document.Element, value rules.SelectorPart, fromEnd bool) ([]*document.Element, error) {
+	n, err := parseNth(value.Args)
+	if err != nil {
+		return []*document.Element{}, err
+	}
+	return selectIf(elm, n.matches(nthPosition(elm, sameTypeAs(elm), fromEnd))), nil
+}
+
+func selectIf(elm *document.Element, matches bool) []*document.Element {
+	if matches {
+		return []*document.Element{elm}
+	}
+	return []*document.Element{}
+}
+
+func (p NthChild) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
+	return nthChildMatches(elm, value, false)
 }
@@ -12,13 +12,5 @@ import (
 )
 
 func (p NthLastChild) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	if start, skip, err := nth(value.Args, len(elm.Children)); err == nil {
-		selected := make([]*document.Element, 0)
-		for i := len(elm.Children) - 1 - start; i >= 0; i -= skip {
-			selected = append(selected, elm.Children[i])
-		}
-		return selected, nil
-	} else {
-		return []*document.Element{}, err
-	}
+	return nthChildMatches(elm, value, true)
 }
@@ -7,12 +7,11 @@
 package pseudos
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
 func (p OnlyChild) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	return selectIf(elm, nthPosition(elm, anyElement, false) == 1 &&
+		nthPosition(elm, anyElement, true) == 1), nil
 }
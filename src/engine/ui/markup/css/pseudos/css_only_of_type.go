@@ -7,12 +7,12 @@
 package pseudos
 
 import (
-	"errors"
-
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
 func (p OnlyOfType) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	sameType := sameTypeAs(elm)
+	return selectIf(elm, nthPosition(elm, sameType, false) == 1 &&
+		nthPosition(elm, sameType, true) == 1), nil
 }
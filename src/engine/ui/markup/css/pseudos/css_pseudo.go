@@ -7,6 +7,9 @@
 package pseudos
 
 import (
+	"slices"
+
+	"kaijuengine.com/engine/ui"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
@@ -18,6 +21,41 @@ type Pseudo interface {
 	AlterRules(rules []rules.Rule) []rules.Rule
 }
 
+// StatePseudo is a pseudo class that matches on the live state of an element,
+// like :checked, rather than on the document. The styles of the document need
+// to be applied again whenever one of its events fires on an element.
+type StatePseudo interface {
+	StateEvents() []ui.EventType
+}
+
+// SelectorStateEvents collects the events of the state pseudo classes used by
+// the selector, including the ones nested in functions like :is() or :has()
+func SelectorStateEvents(parts []rules.SelectorPart) []ui.EventType {
+	out := []ui.EventType{}
+	for i := range parts {
+		switch parts[i].SelectType {
+		case rules.ReadingPseudo, rules.ReadingPseudoFunction:
+		default:
+			continue
+		}
+		if sp, ok := PseudoMap[parts[i].Name].(StatePseudo); ok {
+			for _, e := range sp.StateEvents() {
+				if !slices.Contains(out, e) {
+					out = append(out, e)
+				}
+			}
+		}
+		for _, sel := range rules.ParseSelectorList(parts[i].Args) {
+			for _, e := range SelectorStateEvents(sel.Parts) {
+				if !slices.Contains(out, e) {
+					out = append(out, e)
+				}
+			}
+		}
+	}
+	return out
+}
+
 var PseudoMap = map[string]Pseudo{
 	"active":             Active{},
 	"any-link":           AnyLink{},
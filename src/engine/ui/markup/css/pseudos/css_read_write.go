@@ -7,12 +7,40 @@
 package pseudos
 
 import (
-	"errors"
+	"strings"
 
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// readOnlyInputTypes are the input types that don't take typed text
+var readOnlyInputTypes = map[string]struct{}{
+	"button":   {},
+	"checkbox": {},
+	"color":    {},
+	"file":     {},
+	"hidden":   {},
+	"image":    {},
+	"radio":    {},
+	"range":    {},
+	"reset":    {},
+	"submit":   {},
+}
+
+func isReadWrite(elm *document.Element) bool {
+	switch {
+	case elm.IsInput():
+		if _, ok := readOnlyInputTypes[strings.ToLower(elm.Attribute("type"))]; ok {
+			return false
+		}
+	case elm.IsTextArea():
+	default:
+		editable := strings.ToLower(elm.Attribute("contenteditable"))
+		return elm.HasAttribute("contenteditable") && editable != "false"
+	}
+	return !elm.HasAttribute("readonly") && !elm.HasAttribute("disabled")
+}
+
 func (p ReadWrite) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	return selectIf(elm, isElementNode(elm) && isReadWrite(elm)), nil
 }
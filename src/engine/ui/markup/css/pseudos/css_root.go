@@ -7,12 +7,16 @@
 package pseudos
 
 import (
-	"errors"
+	"strings"
 
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// Root matches the top of the styled tree. The <html> element isn't styled
+// by a document so the <body> below it is treated as the root as well.
 func (p Root) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	parent := parentElement(elm)
+	return selectIf(elm, isElementNode(elm) &&
+		(parent == nil || strings.EqualFold(parent.Data, "html"))), nil
 }
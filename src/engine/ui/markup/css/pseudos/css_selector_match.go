@@ -0,0 +1,194 @@
+/******************************************************************************/
+/* css_selector_match.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package pseudos
+
+import (
+	"slices"
+	"strings"
+
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/engine/ui/markup/document"
+
+	"golang.org/x/net/html"
+)
+
+type matchStep struct {
+	parts []rules.SelectorPart
+	// combinator joins this step to the one before it, for the first step of
+	// a relative selector it joins the step to the scope element
+	combinator rules.RuleState
+}
+
+func isElementNode(elm *document.Element) bool {
+	return elm != nil && elm.Type == html.ElementNode
+}
+
+func parentElement(elm *document.Element) *document.Element {
+	if p := elm.Parent.Value(); isElementNode(p) {
+		return p
+	}
+	return nil
+}
+
+// elementChildren are the children of the element without the text nodes
+func elementChildren(elm *document.Element) []*document.Element {
+	out := make([]*document.Element, 0, len(elm.Children))
+	for _, c := range elm.Children {
+		if isElementNode(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// elementSiblings are the element children of the element's parent, which
+// includes the element itself. An element without a parent is its own only
+// sibling.
+func elementSiblings(elm *document.Element) []*document.Element {
+	if p := elm.Parent.Value(); p != nil {
+		return elementChildren(p)
+	}
+	return []*document.Element{elm}
+}
+
+func isCombinator(part rules.SelectorPart) bool {
+	switch part.SelectType {
+	case rules.ReadingDescendant, rules.ReadingChild, rules.ReadingSibling, rules.ReadingAdjacent:
+		return true
+	default:
+		return false
+	}
+}
+
+func previousSibling(elm *document.Element) *document.Element {
+	siblings := elementSiblings(elm)
+	if idx := slices.Index(siblings, elm); idx > 0 {
+		return siblings[idx-1]
+	}
+	return nil
+}
+
+// matchSteps splits the selector into its compounds, it also returns if the
+// selector starts with a combinator
+func matchSteps(parts []rules.SelectorPart) ([]matchStep, bool) {
+	steps := make([]matchStep, 0, len(parts))
+	current := matchStep{combinator: rules.ReadingDescendant}
+	leading := len(parts) > 0 && isCombinator(parts[0])
+	for i := range parts {
+		if isCombinator(parts[i]) {
+			if len(current.parts) > 0 {
+				steps = append(steps, current)
+				current = matchStep{}
+			}
+			current.combinator = parts[i].SelectType
+		} else {
+			current.parts = append(current.parts, parts[i])
+		}
+	}
+	if len(current.parts) > 0 {
+		steps = append(steps, current)
+	}
+	return steps, leading
+}
+
+// selectorListMatches reports if the element matches any of the selectors
+func selectorListMatches(elm *document.Element, list []rules.Selector) bool {
+	for i := range list {
+		if selectorMatches(elm, list[i].Parts, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorMatches matches a complex selector against the element starting
+// from its right most compound. When a scope is given the selector is
+// relative to it, like the arguments of :has(), and its left most compound
+// has to be joined to the scope by its leading combinator.
+func selectorMatches(elm *document.Element, parts []rules.SelectorPart, scope *document.Element) bool {
+	steps, leading := matchSteps(parts)
+	if len(steps) == 0 || (leading && scope == nil) {
+		// A leading combinator is only valid for relative selectors
+		return false
+	}
+	return stepMatches(elm, steps, len(steps)-1, scope)
+}
+
+func stepMatches(elm *document.Element, steps []matchStep, idx int, scope *document.Element) bool {
+	if !isElementNode(elm) || !compoundMatches(elm, steps[idx].parts) {
+		return false
+	}
+	if idx == 0 && scope == nil {
+		return true
+	}
+	next := func(target *document.Element) bool {
+		if idx == 0 {
+			return target != nil && target == scope
+		}
+		return stepMatches(target, steps, idx-1, scope)
+	}
+	switch steps[idx].combinator {
+	case rules.ReadingChild:
+		return next(parentElement(elm))
+	case rules.ReadingDescendant:
+		for p := parentElement(elm); p != nil; p = parentElement(p) {
+			if next(p) {
+				return true
+			}
+		}
+	case rules.ReadingAdjacent:
+		return next(previousSibling(elm))
+	case rules.ReadingSibling:
+		for s := previousSibling(elm); s != nil; s = previousSibling(s) {
+			if next(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compoundMatches(elm *document.Element, parts []rules.SelectorPart) bool {
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		switch part.SelectType {
+		case rules.ReadingTag:
+			if part.Name != "*" && !strings.EqualFold(elm.Data, part.Name) {
+				return false
+			}
+		case rules.ReadingId:
+			if elm.Attribute("id") != part.Name {
+				return false
+			}
+		case rules.ReadingClass:
+			if !elm.HasClass(part.Name) {
+				return false
+			}
+		case rules.ReadingCondition:
+			if i+1 < len(parts) && parts[i+1].SelectType == rules.ReadingConditionAssignment {
+				i++
+				if elm.Attribute(part.Name) != parts[i].Name {
+					return false
+				}
+			} else if !elm.HasAttribute(part.Name) {
+				return false
+			}
+		case rules.ReadingPseudo, rules.ReadingPseudoFunction:
+			p, ok := PseudoMap[part.Name]
+			if !ok {
+				return false
+			}
+			selects, err := p.Process(elm, part)
+			if err != nil || !slices.Contains(selects, elm) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
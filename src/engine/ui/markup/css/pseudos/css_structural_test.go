@@ -0,0 +1,136 @@
+/******************************************************************************/
+/* css_structural_test.go                                                     */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package pseudos
+
+import (
+	"slices"
+	"testing"
+
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/engine/ui/markup/document"
+)
+
+const structuralTestHTML = `<div id="list">
+	<h2 id="title">Title</h2>
+	<p id="p1" class="item"></p>
+	<span id="s1"></span>
+	<p id="p2" class="item"><img id="icon" /></p>
+	<p id="p3"> </p>
+	<input id="check" type="checkbox" checked />
+	<input id="name" type="text" readonly />
+</div>`
+
+func testStructuralRoot(t *testing.T) *document.Element {
+	t.Helper()
+	root := document.NewHTML(structuralTestHTML)
+	if root.FindElementById("list") == nil {
+		t.Fatal("failed to create the test document")
+	}
+	return root
+}
+
+func pseudoMatches(t *testing.T, elm *document.Element, name string, args ...string) bool {
+	t.Helper()
+	p, ok := PseudoMap[name]
+	if !ok {
+		t.Fatalf("missing pseudo %s", name)
+	}
+	part := rules.SelectorPart{Name: name, Args: args}
+	got, err := p.Process(elm, part)
+	if err != nil {
+		t.Fatalf(":%s(%v) returned an error: %v", name, args, err)
+	}
+	return slices.Contains(got, elm)
+}
+
+func TestStructuralPseudos(t *testing.T) {
+	root := testStructuralRoot(t)
+	tests := []struct {
+		id   string
+		name string
+		args []string
+		want bool
+	}{
+		{"title", "first-child", nil, true},
+		{"p1", "first-child", nil, false},
+		{"name", "last-child", nil, true},
+		{"p1", "first-of-type", nil, true},
+		{"p2", "first-of-type", nil, false},
+		{"p3", "last-of-type", nil, true},
+		{"s1", "only-of-type", nil, true},
+		{"p1", "only-of-type", nil, false},
+		{"icon", "only-child", nil, true},
+		{"title", "only-child", nil, false},
+		{"p1", "nth-child", []string{"2"}, true},
+		{"s1", "nth-child", []string{"odd"}, true},
+		{"s1", "nth-child", []string{"even"}, false},
+		{"p2", "nth-child", []string{"2n", "+", "0"}, true},
+		{"p2", "nth-of-type", []string{"2"}, true},
+		{"p3", "nth-last-of-type", []string{"1"}, true},
+		{"check", "nth-last-child", []string{"2"}, true},
+		{"p2", "nth-child", []string{"2", " ", "of", " ", ".", "item"}, true},
+		{"p1", "empty", nil, true},
+		{"p3", "empty", nil, true},
+		{"p2", "empty", nil, false},
+		{"list", "root", nil, false},
+	}
+	for _, test := range tests {
+		elm := root.FindElementById(test.id)
+		if got := pseudoMatches(t, elm, test.name, test.args...); got != test.want {
+			t.Errorf("#%s:%s(%v) matched %t, want %t",
+				test.id, test.name, test.args, got, test.want)
+		}
+	}
+	if !pseudoMatches(t, root.FindElementByTag("body"), "root") {
+		t.Error("the body below the html element should match :root")
+	}
+}
+
+func TestLogicalPseudos(t *testing.T) {
+	root := testStructuralRoot(t)
+	tests := []struct {
+		id   string
+		name string
+		args []string
+		want bool
+	}{
+		{"p1", "is", []string{"span", ",", ".", "item"}, true},
+		{"s1", "is", []string{"h2", ",", ".", "item"}, false},
+		{"p2", "where", []string{"#", "list", " ", "p"}, true},
+		{"p2", "where", []string{"#", "list", ">", "span"}, false},
+		{"s1", "is", []string{".", "item", "+", "span"}, true},
+		{"check", "is", []string{"h2", "~", "input"}, true},
+		{"p2", "has", []string{">", "img"}, true},
+		{"p1", "has", []string{">", "img"}, false},
+		{"p1", "has", []string{"+", "span"}, true},
+		{"list", "has", []string{"img"}, true},
+		{"p1", "not", []string{":", "first-child"}, true},
+	}
+	for _, test := range tests {
+		elm := root.FindElementById(test.id)
+		if got := pseudoMatches(t, elm, test.name, test.args...); got != test.want {
+			t.Errorf("#%s:%s(%v) matched %t, want %t",
+				test.id, test.name, test.args, got, test.want)
+		}
+	}
+}
+
+func TestStatePseudosWithoutUI(t *testing.T) {
+	root := testStructuralRoot(t)
+	if !pseudoMatches(t, root.FindElementById("check"), "checked") {
+		t.Error("a checkbox with the checked attribute should match :checked")
+	}
+	if pseudoMatches(t, root.FindElementById("name"), "read-write") {
+		t.Error("a readonly input should not match :read-write")
+	}
+	if !pseudoMatches(t, root.FindElementById("name"), "read-only") {
+		t.Error("a readonly input should match :read-only")
+	}
+	if pseudoMatches(t, root.FindElementById("p1"), "read-write") {
+		t.Error("a paragraph should not match :read-write")
+	}
+}
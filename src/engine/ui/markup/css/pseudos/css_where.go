@@ -13,6 +13,12 @@ import (
 	"kaijuengine.com/engine/ui/markup/document"
 )
 
+// Where matches the same as :is, it only differs by not adding to the
+// specificity of the selector
 func (p Where) Process(elm *document.Element, value rules.SelectorPart) ([]*document.Element, error) {
-	return []*document.Element{elm}, errors.New("not implemented")
+	list := rules.ParseSelectorList(value.Args)
+	if len(list) == 0 {
+		return []*document.Element{}, errors.New(":where requires a selector argument")
+	}
+	return selectIf(elm, selectorListMatches(elm, list)), nil
 }
@@ -7,13 +7,14 @@
 package css
 
 import (
+	"runtime"
 	"slices"
 
 	"kaijuengine.com/engine/ui"
-	"kaijuengine.com/engine/ui/markup/css/helpers"
 	"kaijuengine.com/engine/ui/markup/css/pseudos"
 	"kaijuengine.com/engine/ui/markup/css/rules"
 	"kaijuengine.com/engine/ui/markup/document"
+	"kaijuengine.com/platform/hid"
 	"kaijuengine.com/platform/windowing"
 )
 
@@ -90,29 +91,46 @@ func cssPropertyOverrides(later, earlier string) bool {
 	return false
 }
 
+// add puts the rules into the cascade of the element. The rules are kept in
+// cascade order, sorted by their specificity and then by the order they were
+// added, so that a rule always wins over the ones before it.
 func (m CSSMap) add(elm *ui.UI, inRules []rules.Rule) {
 	addRules := rules.CloneRules(inRules)
-	if c, ok := m[elm]; !ok {
-		m[elm] = addRules
-	} else {
-		for i := len(c) - 1; i >= 0; i-- {
-			for j := range addRules {
-				if c[i].Invocation == addRules[j].Invocation &&
-					cssPropertyOverrides(addRules[j].Property, c[i].Property) {
-					c = slices.Delete(c, i, i+1)
-					break
-				}
+	c := m[elm]
+	for i := len(c) - 1; i >= 0; i-- {
+		for j := range addRules {
+			if c[i].Invocation == addRules[j].Invocation &&
+				c[i].Specificity <= addRules[j].Specificity &&
+				cssPropertyOverrides(addRules[j].Property, c[i].Property) {
+				c = slices.Delete(c, i, i+1)
+				break
 			}
 		}
-		c = append(c, addRules...)
-		m[elm] = c
 	}
+	for i := range addRules {
+		at := len(c)
+		for at > 0 && c[at-1].Specificity > addRules[i].Specificity {
+			at--
+		}
+		c = slices.Insert(c, at, addRules[i])
+	}
+	m[elm] = c
 }
 
-func applyMappings(doc *document.Document, cssMap map[*ui.UI][]rules.Rule) {
+// withSpecificity clones the rules of a group for one of its selectors
+func withSpecificity(inRules []rules.Rule, specificity rules.Specificity) []rules.Rule {
+	out := rules.CloneRules(inRules)
+	for i := range out {
+		out[i].Specificity = specificity
+	}
+	return out
+}
+
+func applyMappings(doc *document.Document, cssMap map[*ui.UI][]rules.Rule, keyframes map[string]rules.Keyframes) {
 	for _, e := range doc.Elements {
 		// TODO:  Make sure this is applying in order from parent to child
 		// Since this array is intrinsically ordered, it should be fine
+		e.Stylizer.SetKeyframes(keyframes)
 		e.Stylizer.ReplaceRules(cssMap[e.UI])
 		e.UI.Layout().Stylizer = &e.Stylizer
 	}
@@ -286,39 +304,53 @@ type Stylizer struct {
 	Window *windowing.Window
 }
 
+// preferredInput is the value of the prefers-input media feature, a connected
+// controller wins over a touch screen which wins over the mouse
+func preferredInput(w *windowing.Window) string {
+	for i := range hid.ControllerMaxDevices {
+		if w.Controller.Available(i) {
+			return rules.MediaInputGamepad
+		}
+	}
+	if runtime.GOOS == "android" || runtime.GOOS == "ios" {
+		return rules.MediaInputTouch
+	}
+	return rules.MediaInputMouse
+}
+
 func (z Stylizer) ApplyStyles(s rules.StyleSheet, doc *document.Document) {
 	cssMap := CSSMap(make(map[*ui.UI][]rules.Rule))
+	stateEvents := []ui.EventType{}
+	media := rules.MediaEnvironment{Window: z.Window, Input: preferredInput(z.Window)}
 	for _, group := range s.Groups {
-		if group.MediaQuery.IsValid() {
-			switch group.MediaQuery.Key {
-			case "screen":
-			case "max-width":
-				v := helpers.NumFromLength(group.MediaQuery.Value, z.Window)
-				if int(v) <= z.Window.Width() {
-					continue
-				}
-			default:
-				continue
-			}
+		if !group.MediaQuery.Matches(media) {
+			continue
 		}
 		for _, sel := range group.Selectors {
+			selRules := withSpecificity(group.Rules, sel.Specificity())
 			if len(sel.Parts) == 1 && (sel.Parts[0].SelectType == rules.ReadingId ||
 				sel.Parts[0].SelectType == rules.ReadingClass ||
 				sel.Parts[0].SelectType == rules.ReadingTag) {
-				applyDirect(sel.Parts[0], group.Rules, doc, cssMap)
+				applyDirect(sel.Parts[0], selRules, doc, cssMap)
 			} else if len(sel.Parts) > 1 {
-				applyIndirect(sel.Parts, group.Rules, doc, cssMap)
+				applyIndirect(sel.Parts, selRules, doc, cssMap)
 			} else if len(sel.Parts) == 1 {
-				applyIndirect(sel.Parts, group.Rules, doc, cssMap)
+				applyIndirect(sel.Parts, selRules, doc, cssMap)
+			}
+			for _, e := range pseudos.SelectorStateEvents(sel.Parts) {
+				if !slices.Contains(stateEvents, e) {
+					stateEvents = append(stateEvents, e)
+				}
 			}
 		}
 	}
 	for _, elm := range doc.Elements {
 		if inlineStyle := elm.Attribute("style"); inlineStyle != "" {
 			group := s.ParseInline(inlineStyle, z.Window)
-			cssMap.add(elm.UI, group.Rules)
+			cssMap.add(elm.UI, withSpecificity(group.Rules, rules.SpecificityInline))
 		}
 	}
 	cleanMapDuplicates(cssMap)
-	applyMappings(doc, cssMap)
+	applyMappings(doc, cssMap, s.Keyframes)
+	doc.SetStyleStateEvents(stateEvents)
 }
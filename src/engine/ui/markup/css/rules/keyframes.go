@@ -0,0 +1,163 @@
+/******************************************************************************/
+/* keyframes.go                                                               */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"kaijuengine.com/engine/ui/markup/css/helpers"
+
+	"github.com/tdewolff/parse/v2/css"
+)
+
+// Keyframe is a single step of a @keyframes block. Offset is how far through
+// the animation the step is, from 0 (from) to 1 (to).
+type Keyframe struct {
+	Offset float32
+	Rules  []Rule
+}
+
+// Keyframes holds the steps of a @keyframes block sorted by their offset
+type Keyframes struct {
+	Name   string
+	Frames []Keyframe
+}
+
+// listProperties are the properties that take a comma separated list of
+// values. The commas are kept as "," values for these so that each entry of
+// the list can be told apart.
+var listProperties = map[string]struct{}{
+	"animation":                  {},
+	"animation-delay":            {},
+	"animation-direction":        {},
+	"animation-duration":         {},
+	"animation-fill-mode":        {},
+	"animation-iteration-count":  {},
+	"animation-name":             {},
+	"animation-play-state":       {},
+	"animation-timing-function":  {},
+	"transition":                 {},
+	"transition-delay":           {},
+	"transition-duration":        {},
+	"transition-property":        {},
+	"transition-timing-function": {},
+}
+
+// IsListSeparator reports if the value is the comma between two entries of a
+// comma separated list property, like animation or transition
+func (p PropertyValue) IsListSeparator() bool { return p.Str == "," && !p.IsFunction() }
+
+// SplitValueList splits the values of a comma separated list property into
+// the values of each of its entries
+func SplitValueList(values []PropertyValue) [][]PropertyValue {
+	out := [][]PropertyValue{{}}
+	for i := range values {
+		if values[i].IsListSeparator() {
+			out = append(out, []PropertyValue{})
+		} else {
+			out[len(out)-1] = append(out[len(out)-1], values[i])
+		}
+	}
+	return out
+}
+
+func isKeyframesAtRule(keyword string) bool {
+	return strings.HasSuffix(strings.ToLower(keyword), "keyframes")
+}
+
+func keyframeOffset(selector string) (float32, bool) {
+	switch strings.ToLower(selector) {
+	case "from":
+		return 0, true
+	case "to":
+		return 1, true
+	}
+	if !strings.HasSuffix(selector, "%") {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(selector, "%"), 32)
+	if err != nil || v < 0 || v > 100 {
+		return 0, false
+	}
+	return float32(v / 100), true
+}
+
+func (s *StyleSheet) beginKeyframes(name string) {
+	s.keyframes = &Keyframes{Name: name}
+	s.keyframeOffsets = s.keyframeOffsets[:0]
+}
+
+func keyframesName(values []css.Token) string {
+	for _, val := range values {
+		if val.TokenType == css.IdentToken || val.TokenType == css.StringToken {
+			return strings.Trim(string(val.Data), `"'`)
+		}
+	}
+	return ""
+}
+
+// readKeyframesGrammar reads the contents of a @keyframes block, it returns
+// true if the end of the style sheet was reached before the block was closed
+func (s *StyleSheet) readKeyframesGrammar(gt css.GrammarType, propData string, cssParser *css.Parser, window helpers.WindowDimensions) bool {
+	switch gt {
+	case css.ErrorGrammar:
+		s.keyframes = nil
+		return true
+	case css.QualifiedRuleGrammar:
+		s.readKeyframeSelector(cssParser.Values())
+	case css.BeginRulesetGrammar:
+		s.readKeyframeSelector(cssParser.Values())
+		s.state = ReadingProperty
+	case css.DeclarationGrammar:
+		s.readProperty(propData, cssParser, window)
+	case css.EndRulesetGrammar:
+		s.endKeyframe()
+		s.state = ReadingTag
+	case css.EndAtRuleGrammar:
+		s.endKeyframes()
+		s.state = ReadingTag
+	}
+	return false
+}
+
+func (s *StyleSheet) readKeyframeSelector(values []css.Token) {
+	for _, val := range values {
+		if offset, ok := keyframeOffset(string(val.Data)); ok {
+			s.keyframeOffsets = append(s.keyframeOffsets, offset)
+		}
+	}
+}
+
+func (s *StyleSheet) endKeyframe() {
+	for _, offset := range s.keyframeOffsets {
+		s.keyframes.Frames = append(s.keyframes.Frames, Keyframe{
+			Offset: offset,
+			Rules:  CloneRules(s.keyframeRules),
+		})
+	}
+	s.keyframeOffsets = s.keyframeOffsets[:0]
+	s.keyframeRules = s.keyframeRules[:0]
+}
+
+func (s *StyleSheet) endKeyframes() {
+	if s.keyframes.Name != "" {
+		slices.SortStableFunc(s.keyframes.Frames, func(a, b Keyframe) int {
+			switch {
+			case a.Offset < b.Offset:
+				return -1
+			case a.Offset > b.Offset:
+				return 1
+			default:
+				return 0
+			}
+		})
+		s.Keyframes[s.keyframes.Name] = *s.keyframes
+	}
+	s.keyframes = nil
+}
@@ -0,0 +1,263 @@
+/******************************************************************************/
+/* media_query.go                                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"kaijuengine.com/engine/ui/markup/css/helpers"
+
+	"github.com/tdewolff/parse/v2/css"
+)
+
+const (
+	MediaInputMouse   = "mouse"
+	MediaInputTouch   = "touch"
+	MediaInputGamepad = "gamepad"
+)
+
+// MediaFeature is a single test within the parenthesis of a media query. The
+// min-/max- prefixes and the range syntax are read into Op, so
+// (min-width: 600px) and (width >= 600px) are the same feature. Op is empty
+// for features written without a value, like (orientation).
+type MediaFeature struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+// MediaCondition is one of the comma separated queries of a @media rule
+type MediaCondition struct {
+	Not       bool
+	MediaType string
+	Features  []MediaFeature
+}
+
+// MediaQuery holds the conditions of a @media rule, the query matches when
+// any one of its conditions matches
+type MediaQuery struct {
+	Conditions []MediaCondition
+}
+
+// MediaEnvironment is what media queries are evaluated against
+type MediaEnvironment struct {
+	Window helpers.WindowDimensions
+	// Input is the value of the custom prefers-input feature, one of the
+	// MediaInput* values
+	Input string
+}
+
+func (m *MediaQuery) IsValid() bool { return len(m.Conditions) > 0 }
+
+func (m *MediaQuery) Clear() { m.Conditions = nil }
+
+// Matches reports if the styles of the media query should be applied in the
+// given environment, an empty query always matches
+func (m *MediaQuery) Matches(env MediaEnvironment) bool {
+	if !m.IsValid() {
+		return true
+	}
+	for i := range m.Conditions {
+		if m.Conditions[i].matches(env) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *MediaCondition) matches(env MediaEnvironment) bool {
+	match := true
+	switch c.MediaType {
+	case "", "all", "screen":
+	default:
+		match = false
+	}
+	for i := 0; i < len(c.Features) && match; i++ {
+		match = c.Features[i].matches(env)
+	}
+	return match != c.Not
+}
+
+func (f *MediaFeature) matches(env MediaEnvironment) bool {
+	w, h := float64(env.Window.Width()), float64(env.Window.Height())
+	switch f.Name {
+	case "width":
+		return f.compare(w, func(v string) float64 {
+			return float64(helpers.NumFromLength(v, env.Window))
+		})
+	case "height":
+		return f.compare(h, func(v string) float64 {
+			return float64(helpers.NumFromLength(v, env.Window))
+		})
+	case "aspect-ratio":
+		if h <= 0 {
+			return false
+		}
+		return f.compare(w/h, mediaRatio)
+	case "resolution":
+		// CSS pixels are 1/96th of an inch
+		dppx := env.Window.DotsPerMillimeter() * 25.4 / 96
+		return f.compare(dppx, mediaResolution)
+	case "orientation":
+		switch f.Value {
+		case "":
+			return true
+		case "portrait":
+			return h >= w
+		case "landscape":
+			return w > h
+		}
+	case "prefers-input":
+		return env.Input != "" && (f.Value == "" || f.Value == env.Input)
+	}
+	return false
+}
+
+func (f *MediaFeature) compare(actual float64, parse func(string) float64) bool {
+	if f.Op == "" {
+		return actual != 0
+	}
+	const epsilon = 0.001
+	expected := parse(f.Value)
+	switch f.Op {
+	case "=":
+		return math.Abs(actual-expected) < epsilon
+	case "<":
+		return actual < expected-epsilon
+	case "<=":
+		return actual <= expected+epsilon
+	case ">":
+		return actual > expected+epsilon
+	case ">=":
+		return actual >= expected-epsilon
+	}
+	return false
+}
+
+func mediaNumber(str string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// mediaRatio reads an aspect ratio written as "16/9" or as a single number
+func mediaRatio(str string) float64 {
+	num, den, ok := strings.Cut(str, "/")
+	if !ok {
+		return mediaNumber(num)
+	}
+	if d := mediaNumber(den); d != 0 {
+		return mediaNumber(num) / d
+	}
+	return 0
+}
+
+// mediaResolution reads a resolution into dots per CSS pixel
+func mediaResolution(str string) float64 {
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"dppx", 1}, {"dpcm", 2.54 / 96}, {"dpi", 1.0 / 96}, {"x", 1}} {
+		if v, ok := strings.CutSuffix(str, unit.suffix); ok {
+			return mediaNumber(v) * unit.scale
+		}
+	}
+	return mediaNumber(str)
+}
+
+var mediaRangeFlip = map[string]string{
+	"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "=",
+}
+
+func isMediaRangeOp(token string) bool {
+	_, ok := mediaRangeFlip[token]
+	return ok
+}
+
+// parseMediaQuery reads the prelude of a @media rule, like
+// "screen and (min-width: 600px), (orientation: portrait)"
+func parseMediaQuery(values []css.Token) MediaQuery {
+	q := MediaQuery{}
+	cond := MediaCondition{}
+	var feature []string
+	inFeature := false
+	for _, val := range values {
+		data := string(val.Data)
+		switch {
+		case val.TokenType == css.WhitespaceToken:
+		case val.TokenType == css.LeftParenthesisToken:
+			inFeature = true
+			feature = feature[:0]
+		case val.TokenType == css.RightParenthesisToken:
+			inFeature = false
+			cond.Features = append(cond.Features, parseMediaFeature(feature)...)
+		case inFeature:
+			last := len(feature) - 1
+			if last >= 0 && data == "=" && (feature[last] == "<" || feature[last] == ">") {
+				feature[last] += data
+			} else if last >= 0 && (data == "/" || strings.HasSuffix(feature[last], "/")) {
+				feature[last] += data
+			} else {
+				feature = append(feature, data)
+			}
+		case val.TokenType == css.CommaToken:
+			q.Conditions = append(q.Conditions, cond)
+			cond = MediaCondition{}
+		default:
+			switch word := strings.ToLower(data); word {
+			case "not":
+				cond.Not = true
+			case "only", "and":
+			default:
+				cond.MediaType = word
+			}
+		}
+	}
+	q.Conditions = append(q.Conditions, cond)
+	return q
+}
+
+// parseMediaFeature reads the tokens within a set of parenthesis, a range
+// like (400px <= width < 800px) is read into a feature for each bound
+func parseMediaFeature(tokens []string) []MediaFeature {
+	switch {
+	case len(tokens) == 1:
+		return []MediaFeature{{Name: strings.ToLower(tokens[0])}}
+	case len(tokens) >= 3 && tokens[1] == ":":
+		name := strings.ToLower(tokens[0])
+		f := MediaFeature{Name: name, Op: "=", Value: strings.Join(tokens[2:], "")}
+		if n, ok := strings.CutPrefix(name, "min-"); ok {
+			f.Name, f.Op = n, ">="
+		} else if n, ok := strings.CutPrefix(name, "max-"); ok {
+			f.Name, f.Op = n, "<="
+		}
+		return []MediaFeature{f}
+	case len(tokens) == 3 && isMediaRangeOp(tokens[1]):
+		if isMediaValue(tokens[0]) {
+			return []MediaFeature{{Name: strings.ToLower(tokens[2]),
+				Op: mediaRangeFlip[tokens[1]], Value: tokens[0]}}
+		}
+		return []MediaFeature{{Name: strings.ToLower(tokens[0]),
+			Op: tokens[1], Value: tokens[2]}}
+	case len(tokens) == 5 && isMediaRangeOp(tokens[1]) && isMediaRangeOp(tokens[3]):
+		name := strings.ToLower(tokens[2])
+		return []MediaFeature{
+			{Name: name, Op: mediaRangeFlip[tokens[1]], Value: tokens[0]},
+			{Name: name, Op: tokens[3], Value: tokens[4]},
+		}
+	}
+	// Unknown syntax, the feature name can't match so the condition fails
+	return []MediaFeature{{Name: strings.Join(tokens, "")}}
+}
+
+func isMediaValue(token string) bool {
+	return token != "" && (token[0] == '.' || (token[0] >= '0' && token[0] <= '9'))
+}
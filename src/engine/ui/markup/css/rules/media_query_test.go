@@ -0,0 +1,128 @@
+/******************************************************************************/
+/* media_query_test.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+import "testing"
+
+type mediaTestWindow struct {
+	width, height int
+	dpmm          float64
+}
+
+func (w mediaTestWindow) DotsPerMillimeter() float64 { return w.dpmm }
+func (w mediaTestWindow) Width() int                 { return w.width }
+func (w mediaTestWindow) Height() int                { return w.height }
+
+const testCSSMedia = `.a { color: red; }
+@media screen and (max-width: 800px) {
+	.a { color: blue; }
+	.b { color: green; }
+}
+.c { color: black; }`
+
+func parseTestMediaQuery(t *testing.T, query string) MediaQuery {
+	t.Helper()
+	s := NewStyleSheet()
+	s.Parse("@media "+query+" { .a { color: red; } }", dummyWindow{})
+	groups := selectedTestGroups(s)
+	if len(groups) != 1 {
+		t.Fatalf("expected %q to parse into a single group, got %d", query, len(groups))
+	}
+	return groups[0].MediaQuery
+}
+
+// selectedTestGroups skips the empty group left behind by the end of a @media
+func selectedTestGroups(s StyleSheet) []SelectorGroup {
+	out := []SelectorGroup{}
+	for _, g := range s.Groups {
+		if len(g.Selectors) > 0 {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+func TestParseMediaGroups(t *testing.T) {
+	s := NewStyleSheet()
+	s.Parse(testCSSMedia, dummyWindow{})
+	groups := selectedTestGroups(s)
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(groups))
+	}
+	if groups[0].MediaQuery.IsValid() || groups[3].MediaQuery.IsValid() {
+		t.Error("the rules outside of @media should not have a media query")
+	}
+	for _, g := range groups[1:3] {
+		q := g.MediaQuery
+		if len(q.Conditions) != 1 || q.Conditions[0].MediaType != "screen" ||
+			len(q.Conditions[0].Features) != 1 {
+			t.Fatalf("unexpected media query %#v", q)
+		}
+		if f := q.Conditions[0].Features[0]; f.Name != "width" || f.Op != "<=" || f.Value != "800px" {
+			t.Errorf("expected max-width to read as width <= 800px, got %#v", f)
+		}
+	}
+}
+
+func TestParseMediaRangeSyntax(t *testing.T) {
+	q := parseTestMediaQuery(t, "(400px <= width < 70em), not print")
+	if len(q.Conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %#v", q.Conditions)
+	}
+	f := q.Conditions[0].Features
+	if len(f) != 2 || f[0] != (MediaFeature{"width", ">=", "400px"}) ||
+		f[1] != (MediaFeature{"width", "<", "70em"}) {
+		t.Errorf("unexpected range features %#v", f)
+	}
+	if c := q.Conditions[1]; !c.Not || c.MediaType != "print" {
+		t.Errorf("unexpected second condition %#v", c)
+	}
+	q = parseTestMediaQuery(t, "(aspect-ratio: 16/9)")
+	if f := q.Conditions[0].Features[0]; f.Value != "16/9" {
+		t.Errorf("expected the ratio to be 16/9, got %q", f.Value)
+	}
+}
+
+func TestMediaQueryMatches(t *testing.T) {
+	handheld := MediaEnvironment{Window: mediaTestWindow{1280, 720, 96 / 25.4}, Input: MediaInputGamepad}
+	tv := MediaEnvironment{Window: mediaTestWindow{3840, 2160, 2 * 96 / 25.4}, Input: MediaInputGamepad}
+	phone := MediaEnvironment{Window: mediaTestWindow{390, 844, 3 * 96 / 25.4}, Input: MediaInputTouch}
+	tests := []struct {
+		query string
+		want  [3]bool
+	}{
+		{"screen", [3]bool{true, true, true}},
+		{"print", [3]bool{false, false, false}},
+		{"not print", [3]bool{true, true, true}},
+		{"(max-width: 1280px)", [3]bool{true, false, true}},
+		{"(min-width: 1281px)", [3]bool{false, true, false}},
+		{"(width < 1280px)", [3]bool{false, false, true}},
+		{"(1000px <= width <= 2000px)", [3]bool{true, false, false}},
+		{"(min-height: 1000px)", [3]bool{false, true, false}},
+		{"(orientation: portrait)", [3]bool{false, false, true}},
+		{"(orientation: landscape)", [3]bool{true, true, false}},
+		{"(aspect-ratio: 16/9)", [3]bool{true, true, false}},
+		{"(max-aspect-ratio: 1/1)", [3]bool{false, false, true}},
+		{"(min-resolution: 2dppx)", [3]bool{false, true, true}},
+		{"(resolution >= 192dpi)", [3]bool{false, true, true}},
+		{"(prefers-input: gamepad)", [3]bool{true, true, false}},
+		{"(prefers-input: touch)", [3]bool{false, false, true}},
+		{"screen and (orientation: landscape) and (max-width: 1920px)", [3]bool{true, false, false}},
+		{"(prefers-input: touch), (min-width: 3000px)", [3]bool{false, true, true}},
+		{"not screen and (prefers-input: gamepad)", [3]bool{false, false, true}},
+		{"(hover-color: red)", [3]bool{false, false, false}},
+	}
+	for _, test := range tests {
+		q := parseTestMediaQuery(t, test.query)
+		for i, env := range []MediaEnvironment{handheld, tv, phone} {
+			if got := q.Matches(env); got != test.want[i] {
+				t.Errorf("@media %s: environment %d matched %t, want %t",
+					test.query, i, got, test.want[i])
+			}
+		}
+	}
+}
@@ -18,10 +18,18 @@ import (
 )
 
 type StyleSheet struct {
-	Groups         []SelectorGroup
-	CustomVars     map[string][]string
-	state          RuleState
-	stateFuncDepth int
+	Groups          []SelectorGroup
+	CustomVars      map[string][]string
+	Keyframes       map[string]Keyframes
+	state           RuleState
+	stateFuncDepth  int
+	keyframes       *Keyframes
+	keyframeOffsets []float32
+	keyframeRules   []Rule
+	// openSelector is a selector that was cut by a comma inside of a pseudo
+	// function like :is(.a, .b), it is continued by the next selector read
+	openSelector      *Selector
+	openSelectorDepth int
 }
 
 // varRefSentinel prefixes a deferred custom-property reference that is stored
@@ -42,6 +50,32 @@ func parseVarRef(s string) (string, bool) {
 	return "", false
 }
 
+// nestingFunctions are the functions whose arguments are a comma separated
+// list that can hold other functions, like the color stops of a gradient. The
+// commas are kept as "," arguments and nested functions, other than var(), are
+// kept whole as a single argument like "rgba(0,0,0,0.5)".
+var nestingFunctions = map[string]struct{}{
+	"conic-gradient":            {},
+	"linear-gradient":           {},
+	"radial-gradient":           {},
+	"repeating-conic-gradient":  {},
+	"repeating-linear-gradient": {},
+	"repeating-radial-gradient": {},
+}
+
+// nestingFunction returns the function value that is currently being read if
+// it keeps its nested functions and commas as arguments
+func (s *StyleSheet) nestingFunction(values []PropertyValue) *PropertyValue {
+	if s.stateFuncDepth == 0 || len(values) == 0 {
+		return nil
+	}
+	last := &values[len(values)-1]
+	if _, ok := nestingFunctions[last.Str]; !ok {
+		return nil
+	}
+	return last
+}
+
 func (s *StyleSheet) addGroup() {
 	g := SelectorGroup{
 		Selectors: make([]Selector, 0),
@@ -100,6 +134,12 @@ func (s *StyleSheet) readSelector(cssParser *css.Parser) {
 		sel.Parts[idx].Args = append(sel.Parts[idx].Args, data)
 		return true
 	}
+	if s.openSelector != nil {
+		sel = *s.openSelector
+		pseudoFunctionDepth = s.openSelectorDepth
+		s.openSelector = nil
+		appendPseudoArg(",")
+	}
 	for _, val := range cssParser.Values() {
 		switch val.TokenType {
 		case css.IdentToken:
@@ -118,6 +158,9 @@ func (s *StyleSheet) readSelector(cssParser *css.Parser) {
 					SelectType: s.state,
 				})
 			}
+		case css.DimensionToken:
+			// Only the An+B arguments of the :nth-*() functions, like "2n"
+			appendPseudoArg(string(val.Data))
 		case css.HashToken:
 			id := strings.TrimPrefix(string(val.Data), "#")
 			if appendPseudoArg("#" + id) {
@@ -196,6 +239,11 @@ func (s *StyleSheet) readSelector(cssParser *css.Parser) {
 			}
 		}
 	}
+	if pseudoFunctionDepth > 0 {
+		s.openSelector = &sel
+		s.openSelectorDepth = pseudoFunctionDepth
+		return
+	}
 	idx := len(s.Groups) - 1
 	s.Groups[idx].Selectors = append(s.Groups[idx].Selectors, sel)
 }
@@ -206,6 +254,11 @@ func (s *StyleSheet) readProperty(prop string, cssParser *css.Parser, _ helpers.
 		Values:   make([]PropertyValue, 0),
 	}
 	for _, val := range cssParser.Values() {
+		if fn := s.nestingFunction(r.Values); fn != nil {
+			if s.readNestedArgument(fn, val) {
+				continue
+			}
+		}
 		switch val.TokenType {
 		case css.FunctionToken:
 			s.stateFuncDepth++
@@ -214,6 +267,9 @@ func (s *StyleSheet) readProperty(prop string, cssParser *css.Parser, _ helpers.
 				Str: strings.TrimSuffix(string(val.Data), "("),
 			})
 		case css.CommaToken:
+			if _, ok := listProperties[prop]; ok && s.stateFuncDepth == 0 {
+				r.Values = append(r.Values, PropertyValue{Str: ","})
+			}
 		case css.CommentToken:
 		case css.WhitespaceToken:
 		case css.RightParenthesisToken:
@@ -257,7 +313,11 @@ func (s *StyleSheet) readProperty(prop string, cssParser *css.Parser, _ helpers.
 	}
 	// Numeric resolution is intentionally deferred to resolveRuleVars (called
 	// post-parse) because deferred var references are not yet substituted here.
-	s.currentGroup().AddRule(r)
+	if s.keyframes != nil {
+		s.keyframeRules = append(s.keyframeRules, r)
+	} else {
+		s.currentGroup().AddRule(r)
+	}
 }
 
 // resolveVars walks every parsed rule in the sheet and substitutes the final
@@ -271,6 +331,13 @@ func (s *StyleSheet) resolveVars(window helpers.WindowDimensions) {
 			s.resolveRuleVars(&g.Rules[ri], window)
 		}
 	}
+	for _, k := range s.Keyframes {
+		for fi := range k.Frames {
+			for ri := range k.Frames[fi].Rules {
+				s.resolveRuleVars(&k.Frames[fi].Rules[ri], window)
+			}
+		}
+	}
 }
 
 // resolveRuleVars substitutes deferred var references in a single rule and then
@@ -318,11 +385,59 @@ func (s *StyleSheet) resolveRuleVars(r *Rule, window helpers.WindowDimensions) {
 	}
 }
 
+// readNestedArgument reads a token inside one of the nestingFunctions into
+// its arguments, it returns false if the token should be read as normal
+func (s *StyleSheet) readNestedArgument(fn *PropertyValue, val css.Token) bool {
+	data := string(val.Data)
+	nested := s.stateFuncDepth > 1
+	switch val.TokenType {
+	case css.FunctionToken:
+		if nested {
+			s.appendNestedArgument(fn, data)
+		} else if data == "var(" {
+			// Resolved with the rest of the var references once the whole
+			// sheet has been parsed
+			fn.Args = append(fn.Args, makeVarRef(""))
+		} else {
+			fn.Args = append(fn.Args, data)
+		}
+		s.stateFuncDepth++
+	case css.RightParenthesisToken:
+		if !nested {
+			return false
+		}
+		s.appendNestedArgument(fn, data)
+		s.stateFuncDepth--
+	case css.CommentToken, css.WhitespaceToken:
+	default:
+		if nested {
+			s.appendNestedArgument(fn, data)
+		} else {
+			fn.Args = append(fn.Args, data)
+		}
+	}
+	return true
+}
+
+func (s *StyleSheet) appendNestedArgument(fn *PropertyValue, data string) {
+	last := &fn.Args[len(fn.Args)-1]
+	if name, ok := parseVarRef(*last); ok {
+		// Only the name of the custom property is kept, a fallback value of
+		// the var() is ignored
+		if name == "" {
+			*last = makeVarRef(data)
+		}
+		return
+	}
+	*last += data
+}
+
 func NewStyleSheet() StyleSheet {
 	return StyleSheet{
 		Groups:     make([]SelectorGroup, 0),
 		state:      ReadingTag,
 		CustomVars: make(map[string][]string),
+		Keyframes:  make(map[string]Keyframes),
 	}
 }
 
@@ -333,29 +448,23 @@ func (s *StyleSheet) Parse(cssStr string, window helpers.WindowDimensions) {
 	qualifiedGroupStart := -1
 	for !exit {
 		gt, _, propData := cssParser.Next()
+		if s.keyframes != nil {
+			exit = s.readKeyframesGrammar(gt, string(propData), cssParser, window)
+			continue
+		}
 		switch gt {
 		case css.ErrorGrammar:
 			exit = true
 		case css.CommentGrammar:
 			// Do nothing
 		case css.BeginAtRuleGrammar:
-			q := MediaQuery{}
-			for _, val := range cssParser.Values() {
-				if val.TokenType == css.WhitespaceToken {
-					continue
-				}
-				v := string(val.Data)
-				switch v {
-				case "(", ":", ")":
-				default:
-					if q.Key == "" {
-						q.Key = v
-					} else {
-						q.Value = v
-					}
-				}
+			if isKeyframesAtRule(string(propData)) {
+				s.beginKeyframes(keyframesName(cssParser.Values()))
+				break
+			}
+			if strings.EqualFold(string(propData), "@media") {
+				s.setGroupMediaQuery(parseMediaQuery(cssParser.Values()))
 			}
-			s.setGroupMediaQuery(q)
 		case css.AtRuleGrammar:
 		case css.QualifiedRuleGrammar:
 			if qualifiedGroupStart < 0 {
@@ -364,7 +473,9 @@ func (s *StyleSheet) Parse(cssStr string, window helpers.WindowDimensions) {
 			if s.state < ReadingProperty {
 				s.readSelector(cssParser)
 			}
-			s.addGroup()
+			if s.openSelector == nil {
+				s.addGroup()
+			}
 		case css.BeginRulesetGrammar:
 			s.readSelector(cssParser)
 			s.state = ReadingProperty
@@ -39,6 +39,14 @@ const testCSSVarMultiToken = `:root { --pad: 1px 2px 3px 4px; }
 const testCSSVarInCalcLaterRoot = `.test { height: calc(100% - var(--h)); }
 :root { --h: 24px; }`
 
+const testCSSKeyframes = `@keyframes pulse {
+	from { opacity: 0; width: var(--w); }
+	50%, 75% { opacity: 0.5; }
+	to { opacity: 1; }
+}
+:root { --w: 10px; }
+.pulse { animation: pulse 1s steps(4, end), spin 2s; color: red; }`
+
 type dummyWindow struct{}
 
 func (dummyWindow) DotsPerMillimeter() float64 { return 1 }
@@ -398,3 +406,92 @@ func TestParseVariableInCalcLaterRoot(t *testing.T) {
 		}
 	}
 }
+
+func TestParseKeyframes(t *testing.T) {
+	s := NewStyleSheet()
+	s.Parse(testCSSKeyframes, dummyWindow{})
+	k, ok := s.Keyframes["pulse"]
+	if !ok {
+		t.Fatalf("expected the pulse keyframes to be parsed, got %#v", s.Keyframes)
+	}
+	expectedOffsets := []float32{0, 0.5, 0.75, 1}
+	if len(k.Frames) != len(expectedOffsets) {
+		t.Fatalf("expected %d frames, got %d", len(expectedOffsets), len(k.Frames))
+	}
+	for i := range expectedOffsets {
+		if k.Frames[i].Offset != expectedOffsets[i] {
+			t.Fatalf("frame %d expected offset %f, got %f", i, expectedOffsets[i], k.Frames[i].Offset)
+		}
+	}
+	if len(k.Frames[0].Rules) != 2 || k.Frames[0].Rules[1].Values[0].Str != "10px" {
+		t.Fatalf("expected the from frame to resolve its variable, got %#v", k.Frames[0].Rules)
+	}
+	if len(k.Frames[2].Rules) != 1 || k.Frames[2].Rules[0].Values[0].Str != "0.5" {
+		t.Fatalf("expected the 75%% frame to share the 50%% rules, got %#v", k.Frames[2].Rules)
+	}
+	for _, g := range s.Groups {
+		for _, r := range g.Rules {
+			if r.Property == "opacity" {
+				t.Fatal("keyframe rules should not be added to the selector groups")
+			}
+		}
+	}
+}
+
+func TestParseListPropertyKeepsSeparators(t *testing.T) {
+	s := NewStyleSheet()
+	s.Parse(testCSSKeyframes, dummyWindow{})
+	var animation, color Rule
+	for _, g := range s.Groups {
+		for _, r := range g.Rules {
+			switch r.Property {
+			case "animation":
+				animation = r
+			case "color":
+				color = r
+			}
+		}
+	}
+	list := SplitValueList(animation.Values)
+	if len(list) != 2 {
+		t.Fatalf("expected 2 animations, got %d: %#v", len(list), animation.Values)
+	}
+	if len(list[0]) != 3 || list[0][2].Str != "steps" || len(list[0][2].Args) != 2 {
+		t.Fatalf("expected the commas inside steps() to be kept as arguments, got %#v", list[0])
+	}
+	if len(list[1]) != 2 || list[1][0].Str != "spin" {
+		t.Fatalf("unexpected second animation %#v", list[1])
+	}
+	if color.Property != "color" || len(color.Values) != 1 {
+		t.Fatalf("expected the rule after the animation to parse, got %#v", color)
+	}
+}
+
+func TestParseGradientKeepsStops(t *testing.T) {
+	s := NewStyleSheet()
+	s.Parse(`:root { --edge: blue; }
+.banner {
+	background-image: linear-gradient(to right, rgba(0, 0, 0, 0.5) 10%, var(--edge));
+}`, dummyWindow{})
+	var rule Rule
+	for _, g := range s.Groups {
+		for _, r := range g.Rules {
+			if r.Property == "background-image" {
+				rule = r
+			}
+		}
+	}
+	if len(rule.Values) != 1 || rule.Values[0].Str != "linear-gradient" {
+		t.Fatalf("expected a single gradient value, got %#v", rule.Values)
+	}
+	want := []string{"to", "right", ",", "rgba(0,0,0,0.5)", "10%", ",", "blue"}
+	args := rule.Values[0].Args
+	if len(args) != len(want) {
+		t.Fatalf("expected the arguments %q, got %q", want, args)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Fatalf("expected the arguments %q, got %q", want, args)
+		}
+	}
+}
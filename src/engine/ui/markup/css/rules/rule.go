@@ -54,6 +54,7 @@ type Rule struct {
 	Invocation   RuleInvoke
 	Sort         int
 	SelfDestruct bool
+	Specificity  Specificity
 }
 
 func (r *Rule) Clone() Rule {
@@ -62,6 +63,7 @@ func (r *Rule) Clone() Rule {
 		Invocation:   r.Invocation,
 		Sort:         r.Sort,
 		SelfDestruct: r.SelfDestruct,
+		Specificity:  r.Specificity,
 		Values:       make([]PropertyValue, len(r.Values)),
 	}
 	for i := range r.Values {
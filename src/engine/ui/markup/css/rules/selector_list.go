@@ -0,0 +1,153 @@
+/******************************************************************************/
+/* selector_list.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+import "strings"
+
+func isCombinatorState(state RuleState) bool {
+	switch state {
+	case ReadingDescendant, ReadingChild, ReadingSibling, ReadingAdjacent:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseSelectorList reads the arguments of a pseudo function like :is() or
+// :has() into the selectors they list. The arguments are the raw tokens kept
+// by the parser, so nested functions are an opening "name(" token followed by
+// their arguments and a closing ")". A selector may start with a combinator,
+// which is how the relative selectors of :has() are written.
+func ParseSelectorList(args []string) []Selector {
+	list := make([]Selector, 0, 1)
+	sel := Selector{Parts: make([]SelectorPart, 0)}
+	state := ReadingTag
+	pendingSpace := false
+	addCombinator := func(selectType RuleState, name string) {
+		if last := len(sel.Parts) - 1; last >= 0 && isCombinatorState(sel.Parts[last].SelectType) {
+			sel.Parts[last] = SelectorPart{Name: name, SelectType: selectType}
+		} else {
+			sel.Parts = append(sel.Parts, SelectorPart{Name: name, SelectType: selectType})
+		}
+	}
+	finish := func() {
+		if len(sel.Parts) > 0 {
+			list = append(list, sel)
+		}
+		sel = Selector{Parts: make([]SelectorPart, 0)}
+		state = ReadingTag
+		pendingSpace = false
+	}
+	for i := 0; i < len(args); i++ {
+		token := strings.TrimSpace(args[i])
+		switch token {
+		case "":
+			pendingSpace = true
+			continue
+		case ",":
+			finish()
+			continue
+		case ">":
+			addCombinator(ReadingChild, token)
+			pendingSpace = false
+			continue
+		case "+":
+			addCombinator(ReadingAdjacent, token)
+			pendingSpace = false
+			continue
+		case "~":
+			addCombinator(ReadingSibling, token)
+			pendingSpace = false
+			continue
+		}
+		if pendingSpace && len(sel.Parts) > 0 {
+			addCombinator(ReadingDescendant, " ")
+		}
+		pendingSpace = false
+		switch {
+		case token == ".":
+			state = ReadingClass
+		case token == "#":
+			state = ReadingId
+		case token == ":":
+			state = ReadingPseudo
+		case token == "[":
+			end := i + 1
+			for end < len(args) && strings.TrimSpace(args[end]) != "]" {
+				end++
+			}
+			sel.Parts = append(sel.Parts, conditionParts(args[i+1:min(end, len(args))])...)
+			i = end
+			state = ReadingTag
+		case strings.HasSuffix(token, "("):
+			depth := 1
+			end := i + 1
+			for ; end < len(args) && depth > 0; end++ {
+				switch t := strings.TrimSpace(args[end]); {
+				case strings.HasSuffix(t, "("):
+					depth++
+				case t == ")":
+					depth--
+				}
+			}
+			inner := args[i+1 : max(end-1, i+1)]
+			sel.Parts = append(sel.Parts, SelectorPart{
+				Name:       strings.TrimSuffix(token, "("),
+				Args:       append([]string(nil), inner...),
+				SelectType: ReadingPseudoFunction,
+			})
+			i = end - 1
+			state = ReadingTag
+		case state == ReadingTag && strings.HasPrefix(token, "#"):
+			sel.Parts = append(sel.Parts, SelectorPart{
+				Name:       strings.TrimPrefix(token, "#"),
+				SelectType: ReadingId,
+			})
+		case state == ReadingTag && strings.HasPrefix(token, "."):
+			sel.Parts = append(sel.Parts, SelectorPart{
+				Name:       strings.TrimPrefix(token, "."),
+				SelectType: ReadingClass,
+			})
+		default:
+			sel.Parts = append(sel.Parts, SelectorPart{Name: token, SelectType: state})
+			state = ReadingTag
+		}
+	}
+	finish()
+	return list
+}
+
+// conditionParts reads the tokens between the brackets of an attribute
+// selector, only the presence and equality forms are supported
+func conditionParts(args []string) []SelectorPart {
+	parts := make([]SelectorPart, 0, 2)
+	for i := range args {
+		token := strings.TrimSpace(args[i])
+		switch {
+		case token == "" || token == "=":
+		case len(parts) == 0:
+			parts = append(parts, SelectorPart{Name: token, SelectType: ReadingCondition})
+		case len(parts) == 1:
+			parts = append(parts, SelectorPart{
+				Name:       strings.Trim(token, `"'`),
+				SelectType: ReadingConditionAssignment,
+			})
+		}
+	}
+	return parts
+}
+
+// SplitNthOf splits the arguments of :nth-child() and :nth-last-child() into
+// the An+B expression and the selectors that follow an "of" keyword
+func SplitNthOf(args []string) ([]string, []Selector) {
+	for i := range args {
+		if strings.TrimSpace(args[i]) == "of" {
+			return args[:i], ParseSelectorList(args[i+1:])
+		}
+	}
+	return args, nil
+}
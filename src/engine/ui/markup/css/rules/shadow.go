@@ -0,0 +1,15 @@
+/******************************************************************************/
+/* shadow.go                                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+// Shadows are a comma separated list of shadows, and the color of a
+// drop-shadow() filter can itself be a function like "rgba(0,0,0,0.5)"
+func init() {
+	listProperties["box-shadow"] = struct{}{}
+	listProperties["text-shadow"] = struct{}{}
+	nestingFunctions["drop-shadow"] = struct{}{}
+}
@@ -0,0 +1,76 @@
+/******************************************************************************/
+/* specificity.go                                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+// Specificity is the weight of the selector a rule came from. The number of
+// ids, classes and tags are packed so that comparing two specificities
+// compares the ids first, then the classes and then the tags.
+type Specificity int32
+
+const (
+	specificityTag   Specificity = 1
+	specificityClass Specificity = 1 << 10
+	specificityId    Specificity = 1 << 20
+	// SpecificityInline is the weight of the style attribute of an element,
+	// it is above anything a selector can reach
+	SpecificityInline Specificity = 1 << 30
+)
+
+func NewSpecificity(ids, classes, tags int) Specificity {
+	clamp := func(v int) Specificity { return Specificity(min(max(v, 0), 1<<10-1)) }
+	return clamp(ids)*specificityId + clamp(classes)*specificityClass + clamp(tags)
+}
+
+func (s Specificity) Ids() int     { return int(s/specificityId) % (1 << 10) }
+func (s Specificity) Classes() int { return int(s/specificityClass) % (1 << 10) }
+func (s Specificity) Tags() int    { return int(s) % (1 << 10) }
+
+func (s Specificity) add(other Specificity) Specificity {
+	return NewSpecificity(s.Ids()+other.Ids(), s.Classes()+other.Classes(),
+		s.Tags()+other.Tags())
+}
+
+// Specificity follows the selectors level 4 rules, :where() adds nothing,
+// :is(), :not() and :has() add their most specific argument and
+// :nth-child(An+B of S) adds the most specific selector of S on top of
+// itself.
+func (s Selector) Specificity() Specificity {
+	total := Specificity(0)
+	for i := range s.Parts {
+		part := &s.Parts[i]
+		switch part.SelectType {
+		case ReadingId:
+			total = total.add(specificityId)
+		case ReadingClass, ReadingCondition, ReadingPseudo:
+			total = total.add(specificityClass)
+		case ReadingTag:
+			if part.Name != "*" {
+				total = total.add(specificityTag)
+			}
+		case ReadingPseudoFunction:
+			switch part.Name {
+			case "where":
+			case "is", "not", "has":
+				total = total.add(maxSpecificity(ParseSelectorList(part.Args)))
+			case "nth-child", "nth-last-child":
+				_, of := SplitNthOf(part.Args)
+				total = total.add(specificityClass).add(maxSpecificity(of))
+			default:
+				total = total.add(specificityClass)
+			}
+		}
+	}
+	return total
+}
+
+func maxSpecificity(list []Selector) Specificity {
+	out := Specificity(0)
+	for i := range list {
+		out = max(out, list[i].Specificity())
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+/******************************************************************************/
+/* specificity_test.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func parseTestSelector(t *testing.T, selector string) Selector {
+	t.Helper()
+	s := NewStyleSheet()
+	s.Parse(selector+" { display: none; }", dummyWindow{})
+	if len(s.Groups) != 1 || len(s.Groups[0].Selectors) != 1 {
+		t.Fatalf("expected %q to parse into a single selector", selector)
+	}
+	return s.Groups[0].Selectors[0]
+}
+
+func TestSelectorSpecificity(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     Specificity
+	}{
+		{"div", NewSpecificity(0, 0, 1)},
+		{".a .b > span", NewSpecificity(0, 2, 1)},
+		{"#id.a[type]", NewSpecificity(1, 2, 0)},
+		{"li:first-child", NewSpecificity(0, 1, 1)},
+		{":where(#id, .a) span", NewSpecificity(0, 0, 1)},
+		{":is(#id, .a) span", NewSpecificity(1, 0, 1)},
+		{"div:not(.a, .b.c)", NewSpecificity(0, 2, 1)},
+		{"div:has(> img)", NewSpecificity(0, 0, 2)},
+		{"li:nth-child(2n+1 of .item)", NewSpecificity(0, 2, 1)},
+		{"li:nth-of-type(2)", NewSpecificity(0, 1, 1)},
+	}
+	for _, test := range tests {
+		if got := parseTestSelector(t, test.selector).Specificity(); got != test.want {
+			t.Errorf("%s: specificity (%d,%d,%d), want (%d,%d,%d)", test.selector,
+				got.Ids(), got.Classes(), got.Tags(),
+				test.want.Ids(), test.want.Classes(), test.want.Tags())
+		}
+	}
+	if SpecificityInline <= NewSpecificity(1023, 1023, 1023) {
+		t.Error("inline styles should be more specific than any selector")
+	}
+}
+
+func TestParseSelectorListReadsNestedSelectors(t *testing.T) {
+	sel := parseTestSelector(t, "div:is(.a > span, :not(#b) [data-x=\"1\"])")
+	list := ParseSelectorList(sel.Parts[1].Args)
+	if len(list) != 2 {
+		t.Fatalf("expected 2 selectors, got %d: %#v", len(list), list)
+	}
+	first := list[0].Parts
+	if len(first) != 3 || first[0].SelectType != ReadingClass ||
+		first[1].SelectType != ReadingChild || first[2].Name != "span" {
+		t.Errorf("unexpected first selector %#v", first)
+	}
+	second := list[1].Parts
+	if len(second) != 4 || second[0].Name != "not" ||
+		second[0].SelectType != ReadingPseudoFunction ||
+		strings.Join(second[0].Args, "") != "#b" ||
+		second[1].SelectType != ReadingDescendant ||
+		second[2].Name != "data-x" || second[3].Name != "1" {
+		t.Errorf("unexpected second selector %#v", second)
+	}
+}
+
+func TestParseSelectorListKeepsRelativeCombinators(t *testing.T) {
+	sel := parseTestSelector(t, "div:has(> img, + .next)")
+	list := ParseSelectorList(sel.Parts[1].Args)
+	if len(list) != 2 {
+		t.Fatalf("expected 2 selectors, got %d", len(list))
+	}
+	if list[0].Parts[0].SelectType != ReadingChild ||
+		list[1].Parts[0].SelectType != ReadingAdjacent {
+		t.Errorf("expected the leading combinators to be kept, got %#v", list)
+	}
+}
+
+func TestParseNthArguments(t *testing.T) {
+	sel := parseTestSelector(t, "li:nth-child(2n+1 of .item)")
+	nth, of := SplitNthOf(sel.Parts[1].Args)
+	if got := strings.Join(nth, ""); strings.ReplaceAll(got, " ", "") != "2n+1" {
+		t.Errorf("expected the An+B to be 2n+1, got %q", got)
+	}
+	if len(of) != 1 || of[0].Parts[0].Name != "item" {
+		t.Errorf("expected the of selector to be .item, got %#v", of)
+	}
+}
@@ -30,6 +30,9 @@ type Element struct {
 	Children   []*Element
 	Stylizer   ElementLayoutStylizer
 	UIEventIds [ui.EventTypeEnd][]events.Id
+	// styleStateEvtIds are the events that apply the styles of the document
+	// again, see [Document.SetStyleStateEvents]
+	styleStateEvtIds [ui.EventTypeEnd]events.Id
 }
 
 func (e *Element) ClassList() []string {
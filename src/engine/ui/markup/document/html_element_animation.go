@@ -0,0 +1,524 @@
+/******************************************************************************/
+/* html_element_animation.go                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package document
+
+import (
+	"math"
+	"slices"
+
+	"kaijuengine.com/engine/systems/tweening"
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/klib"
+	"kaijuengine.com/matrix"
+)
+
+type AnimationDirection int
+type AnimationFillMode int
+
+const (
+	AnimationDirectionNormal = AnimationDirection(iota)
+	AnimationDirectionReverse
+	AnimationDirectionAlternate
+	AnimationDirectionAlternateReverse
+)
+
+const (
+	AnimationFillModeNone = AnimationFillMode(iota)
+	AnimationFillModeForwards
+	AnimationFillModeBackwards
+	AnimationFillModeBoth
+)
+
+// TimingFunction shapes the progress of an animation or transition. Unless
+// Bezier or Steps are set, the progress is shaped by the tweening Easing.
+type TimingFunction struct {
+	Easing tweening.Easing
+	// Bezier holds the x1, y1, x2 and y2 points of a cubic-bezier() curve
+	Bezier []float32
+	// Steps jumps between this many values rather than easing when above 0
+	Steps int
+	// StepStart jumps at the start of each step rather than at the end
+	StepStart bool
+}
+
+// timingKeywords maps the CSS timing function keywords on to the closest of
+// the tweening easing curves. The names of the other tweening curves are also
+// accepted, written like "ease-in-out-back" or "ease-out-bounce".
+var timingKeywords = map[string]TimingFunction{
+	"linear":              {Easing: tweening.EasingLinear},
+	"ease":                {Easing: tweening.EasingOutQuad},
+	"ease-in":             {Easing: tweening.EasingInSine},
+	"ease-out":            {Easing: tweening.EasingOutSine},
+	"ease-in-out":         {Easing: tweening.EasingInAndOutSine},
+	"step-start":          {Steps: 1, StepStart: true},
+	"step-end":            {Steps: 1},
+	"ease-in-sine":        {Easing: tweening.EasingInSine},
+	"ease-out-sine":       {Easing: tweening.EasingOutSine},
+	"ease-in-out-sine":    {Easing: tweening.EasingInAndOutSine},
+	"ease-in-quad":        {Easing: tweening.EasingInQuad},
+	"ease-out-quad":       {Easing: tweening.EasingOutQuad},
+	"ease-in-out-quad":    {Easing: tweening.EasingInAndOutQuad},
+	"ease-in-cubic":       {Easing: tweening.EasingInCubic},
+	"ease-out-cubic":      {Easing: tweening.EasingOutCubic},
+	"ease-in-out-cubic":   {Easing: tweening.EasingInAndOutCubic},
+	"ease-in-quart":       {Easing: tweening.EasingInQuart},
+	"ease-out-quart":      {Easing: tweening.EasingOutQuart},
+	"ease-in-out-quart":   {Easing: tweening.EasingInAndOutQuart},
+	"ease-in-quint":       {Easing: tweening.EasingInQuint},
+	"ease-out-quint":      {Easing: tweening.EasingOutQuint},
+	"ease-in-out-quint":   {Easing: tweening.EasingInAndOutQuint},
+	"ease-in-expo":        {Easing: tweening.EasingInExpo},
+	"ease-out-expo":       {Easing: tweening.EasingOutExpo},
+	"ease-in-out-expo":    {Easing: tweening.EasingInAndOutExpo},
+	"ease-in-circ":        {Easing: tweening.EasingInCirc},
+	"ease-out-circ":       {Easing: tweening.EasingOutCirc},
+	"ease-in-out-circ":    {Easing: tweening.EasingInAndOutCirc},
+	"ease-in-back":        {Easing: tweening.EasingInBack},
+	"ease-out-back":       {Easing: tweening.EasingOutBack},
+	"ease-in-out-back":    {Easing: tweening.EasingInAndOutBack},
+	"ease-in-elastic":     {Easing: tweening.EasingInElastic},
+	"ease-out-elastic":    {Easing: tweening.EasingOutElastic},
+	"ease-in-out-elastic": {Easing: tweening.EasingInAndOutElastic},
+	"ease-in-bounce":      {Easing: tweening.EasingInBounce},
+	"ease-out-bounce":     {Easing: tweening.EasingOutBounce},
+	"ease-in-out-bounce":  {Easing: tweening.EasingInAndOutBounce},
+}
+
+// TimingFunctionByName returns the timing function for a keyword like "ease"
+// or "ease-in-out"
+func TimingFunctionByName(name string) (TimingFunction, bool) {
+	f, ok := timingKeywords[name]
+	return f, ok
+}
+
+// Apply shapes the progress t, which goes from 0 to 1
+func (f TimingFunction) Apply(t float32) float32 {
+	t = klib.Clamp(t, 0, 1)
+	switch {
+	case f.Steps > 0:
+		steps := float32(f.Steps)
+		if f.StepStart {
+			return min(1, matrix.Ceil(t*steps)/steps)
+		}
+		return matrix.Floor(t*steps) / steps
+	case len(f.Bezier) == 4:
+		return cubicBezier(f.Bezier[0], f.Bezier[1], f.Bezier[2], f.Bezier[3], t)
+	default:
+		return f.Easing.Apply(t)
+	}
+}
+
+// cubicBezier finds the y of the curve (0,0) (x1,y1) (x2,y2) (1,1) for x
+func cubicBezier(x1, y1, x2, y2, x float32) float32 {
+	curve := func(a, b, u float32) float32 {
+		return 3*a*u*(1-u)*(1-u) + 3*b*u*u*(1-u) + u*u*u
+	}
+	lo, hi, u := float32(0), float32(1), x
+	for range 20 {
+		cx := curve(x1, x2, u)
+		if matrix.Abs(cx-x) < 0.0001 {
+			break
+		}
+		if cx < x {
+			lo = u
+		} else {
+			hi = u
+		}
+		u = (lo + hi) / 2
+	}
+	return curve(y1, y2, u)
+}
+
+// StyleAnimation is a @keyframes animation that is played on an element
+type StyleAnimation struct {
+	Name string
+	// Duration and Delay are in seconds, a negative delay starts the
+	// animation part way through
+	Duration float64
+	Delay    float64
+	Timing   TimingFunction
+	// IterationCount is how many times the animation plays, use math.Inf(1)
+	// for it to play forever
+	IterationCount float64
+	Direction      AnimationDirection
+	FillMode       AnimationFillMode
+	Paused         bool
+}
+
+// StyleTransition blends a property from its old value to its new value
+// when the value changes, Property can be "all" to blend every property
+type StyleTransition struct {
+	Property string
+	Duration float64
+	Delay    float64
+	Timing   TimingFunction
+}
+
+// NewStyleAnimation creates an animation with the CSS defaults
+func NewStyleAnimation(name string) StyleAnimation {
+	return StyleAnimation{
+		Name:           name,
+		Timing:         timingKeywords["ease"],
+		IterationCount: 1,
+	}
+}
+
+// NewStyleTransition creates a transition with the CSS defaults
+func NewStyleTransition(property string) StyleTransition {
+	return StyleTransition{
+		Property: property,
+		Timing:   timingKeywords["ease"],
+	}
+}
+
+// progress returns how far through its keyframes the animation is at the
+// elapsed time, false is returned when the animation doesn't change anything
+func (a *StyleAnimation) progress(elapsed float64) (float32, bool) {
+	t := elapsed - a.Delay
+	iterations := max(0, a.IterationCount)
+	active := 0.0
+	if a.Duration > 0 {
+		active = a.Duration * iterations
+	}
+	iteration, p := 0.0, 0.0
+	switch {
+	case t < 0:
+		if a.FillMode != AnimationFillModeBackwards && a.FillMode != AnimationFillModeBoth {
+			return 0, false
+		}
+	case t >= active:
+		if a.FillMode != AnimationFillModeForwards && a.FillMode != AnimationFillModeBoth {
+			return 0, false
+		}
+		if iterations > 0 && !math.IsInf(iterations, 1) {
+			iteration = math.Ceil(iterations) - 1
+			p = iterations - iteration
+		}
+	default:
+		iteration = math.Floor(t / a.Duration)
+		p = t/a.Duration - iteration
+	}
+	odd := math.Mod(iteration, 2) == 1
+	switch a.Direction {
+	case AnimationDirectionReverse:
+		p = 1 - p
+	case AnimationDirectionAlternate:
+		if odd {
+			p = 1 - p
+		}
+	case AnimationDirectionAlternateReverse:
+		if !odd {
+			p = 1 - p
+		}
+	}
+	return float32(p), true
+}
+
+// isRunning is true until the animation has played all of its iterations
+func (a *StyleAnimation) isRunning(elapsed float64) bool {
+	if a.Paused || a.Duration <= 0 {
+		return false
+	}
+	return elapsed < a.Delay+a.Duration*max(0, a.IterationCount)
+}
+
+type runningTransition struct {
+	property string
+	from     []rules.PropertyValue
+	to       []rules.PropertyValue
+	spec     StyleTransition
+	elapsed  float64
+}
+
+func (t *runningTransition) values() []rules.PropertyValue {
+	p := float32(1)
+	if t.spec.Duration > 0 {
+		p = float32((t.elapsed - t.spec.Delay) / t.spec.Duration)
+	}
+	out, _ := interpolateValues(t.from, t.to, t.spec.Timing.Apply(p))
+	return out
+}
+
+func (t *runningTransition) isDone() bool {
+	return t.elapsed >= t.spec.Delay+t.spec.Duration
+}
+
+// styleAnimator holds the keyframe animations and transitions of an element
+// and overrides the computed rules of the element with their current values
+type styleAnimator struct {
+	keyframes   map[string]rules.Keyframes
+	animations  []StyleAnimation
+	elapsed     []float64
+	transitions []StyleTransition
+	running     []runningTransition
+	// lastValues are the values each transitioned property had the last time
+	// the rules were computed, a change to them starts a transition
+	lastValues map[string][]rules.PropertyValue
+}
+
+func (a *styleAnimator) isIdle() bool {
+	return len(a.animations) == 0 && len(a.transitions) == 0 && len(a.running) == 0
+}
+
+func (a *styleAnimator) setAnimations(animations []StyleAnimation) {
+	elapsed := make([]float64, len(animations))
+	for i := range animations {
+		for j := range a.animations {
+			if a.animations[j].Name == animations[i].Name {
+				elapsed[i] = a.elapsed[j]
+				break
+			}
+		}
+	}
+	a.animations = slices.Clone(animations)
+	a.elapsed = elapsed
+}
+
+func (a *styleAnimator) setTransitions(transitions []StyleTransition) {
+	a.transitions = slices.Clone(transitions)
+	a.running = slices.DeleteFunc(a.running, func(t runningTransition) bool {
+		_, ok := a.transitionFor(t.property)
+		return !ok
+	})
+	if len(a.transitions) == 0 {
+		a.lastValues = nil
+	}
+}
+
+func (a *styleAnimator) transitionFor(property string) (StyleTransition, bool) {
+	for i := len(a.transitions) - 1; i >= 0; i-- {
+		if a.transitions[i].Property == property || a.transitions[i].Property == "all" {
+			return a.transitions[i], a.transitions[i].Duration > 0 || a.transitions[i].Delay > 0
+		}
+	}
+	return StyleTransition{}, false
+}
+
+// update moves the animations and transitions forward by the delta time, it
+// returns true if the values of the element changed
+func (a *styleAnimator) update(deltaTime float64) bool {
+	changed := false
+	for i := range a.animations {
+		if _, ok := a.keyframes[a.animations[i].Name]; ok && a.animations[i].isRunning(a.elapsed[i]) {
+			a.elapsed[i] += deltaTime
+			changed = true
+		}
+	}
+	for i := 0; i < len(a.running); i++ {
+		a.running[i].elapsed += deltaTime
+		changed = true
+		if a.running[i].isDone() {
+			a.running = slices.Delete(a.running, i, i+1)
+			i--
+		}
+	}
+	return changed
+}
+
+// apply replaces the values of the computed rules with the current values of
+// the animations and transitions. Transitions win over animations.
+func (a *styleAnimator) apply(all []rules.Rule) []rules.Rule {
+	if a.isIdle() {
+		return all
+	}
+	a.startTransitions(all)
+	base := rules.CloneRules(all)
+	for i := range a.animations {
+		anim := &a.animations[i]
+		frames, ok := a.keyframes[anim.Name]
+		if !ok {
+			continue
+		}
+		p, ok := anim.progress(a.elapsed[i])
+		if !ok {
+			continue
+		}
+		for _, property := range keyframeProperties(frames) {
+			baseValues, _ := findRuleValues(base, property)
+			all = setRuleValues(all, property, keyframeValues(frames, property, baseValues, p, anim.Timing))
+		}
+	}
+	for i := range a.running {
+		all = setRuleValues(all, a.running[i].property, a.running[i].values())
+	}
+	return all
+}
+
+// startTransitions looks for changes to the values of transitioned
+// properties and starts blending them from what is currently shown
+func (a *styleAnimator) startTransitions(all []rules.Rule) {
+	if len(a.transitions) == 0 {
+		return
+	}
+	if a.lastValues == nil {
+		a.lastValues = make(map[string][]rules.PropertyValue)
+	}
+	seen := make(map[string]struct{}, len(all))
+	for i := range all {
+		property := all[i].Property
+		spec, ok := a.transitionFor(property)
+		if !ok {
+			continue
+		}
+		seen[property] = struct{}{}
+		last, hadLast := a.lastValues[property]
+		a.lastValues[property] = clonePropertyValues(all[i].Values)
+		if !hadLast || propertyValuesEqual(last, all[i].Values) {
+			continue
+		}
+		from := last
+		idx := slices.IndexFunc(a.running, func(t runningTransition) bool {
+			return t.property == property
+		})
+		if idx >= 0 {
+			from = a.running[idx].values()
+			a.running = slices.Delete(a.running, idx, idx+1)
+		}
+		if _, ok := interpolateValues(from, all[i].Values, 0); !ok {
+			continue
+		}
+		a.running = append(a.running, runningTransition{
+			property: property,
+			from:     from,
+			to:       clonePropertyValues(all[i].Values),
+			spec:     spec,
+		})
+	}
+	for property := range a.lastValues {
+		if _, ok := seen[property]; !ok {
+			delete(a.lastValues, property)
+			a.running = slices.DeleteFunc(a.running, func(t runningTransition) bool {
+				return t.property == property
+			})
+		}
+	}
+}
+
+func keyframeProperties(frames rules.Keyframes) []string {
+	out := make([]string, 0)
+	for i := range frames.Frames {
+		for j := range frames.Frames[i].Rules {
+			if p := frames.Frames[i].Rules[j].Property; !slices.Contains(out, p) {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+type keyframeStop struct {
+	offset float32
+	values []rules.PropertyValue
+}
+
+// keyframeValues finds the value of the property at the progress p. When the
+// keyframes have no 0% or 100% step for the property, the value the element
+// would have without the animation is used in its place.
+func keyframeValues(frames rules.Keyframes, property string, baseValues []rules.PropertyValue, p float32, timing TimingFunction) []rules.PropertyValue {
+	stops := make([]keyframeStop, 0, len(frames.Frames)+2)
+	for i := range frames.Frames {
+		if values, ok := findRuleValues(frames.Frames[i].Rules, property); ok {
+			stops = append(stops, keyframeStop{frames.Frames[i].Offset, values})
+		}
+	}
+	if baseValues != nil {
+		if stops[0].offset > 0 {
+			stops = slices.Insert(stops, 0, keyframeStop{0, baseValues})
+		}
+		if stops[len(stops)-1].offset < 1 {
+			stops = append(stops, keyframeStop{1, baseValues})
+		}
+	}
+	if p <= stops[0].offset {
+		return clonePropertyValues(stops[0].values)
+	}
+	for i := 1; i < len(stops); i++ {
+		if p > stops[i].offset {
+			continue
+		}
+		from, to := stops[i-1], stops[i]
+		t := float32(1)
+		if to.offset > from.offset {
+			t = timing.Apply((p - from.offset) / (to.offset - from.offset))
+		}
+		if out, ok := interpolateValues(from.values, to.values, t); ok {
+			return out
+		}
+		return steppedValues(from.values, to.values, t)
+	}
+	return clonePropertyValues(stops[len(stops)-1].values)
+}
+
+func findRuleValues(all []rules.Rule, property string) ([]rules.PropertyValue, bool) {
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Property == property {
+			return all[i].Values, true
+		}
+	}
+	return nil, false
+}
+
+func setRuleValues(all []rules.Rule, property string, values []rules.PropertyValue) []rules.Rule {
+	for i := range all {
+		if all[i].Property == property {
+			all[i].Values = values
+			return all
+		}
+	}
+	r := rules.Rule{Property: property, Values: values}
+	if p, ok := LinkedPropertyMap[property]; ok {
+		r.Sort = p.Sort()
+	}
+	return append(all, r)
+}
+
+// SetKeyframes sets the @keyframes blocks that the animations of the element
+// are played from, this is done by the style sheet as it is applied
+func (s *ElementLayoutStylizer) SetKeyframes(keyframes map[string]rules.Keyframes) {
+	s.animator.keyframes = keyframes
+}
+
+// Animations returns a copy of the keyframe animations set on the element
+func (s *ElementLayoutStylizer) Animations() []StyleAnimation {
+	return slices.Clone(s.animator.animations)
+}
+
+// SetAnimations replaces the keyframe animations of the element. Animations
+// that were already playing with the same name carry on from where they are.
+func (s *ElementLayoutStylizer) SetAnimations(animations []StyleAnimation) {
+	s.animator.setAnimations(animations)
+}
+
+// Transitions returns a copy of the transitions set on the element
+func (s *ElementLayoutStylizer) Transitions() []StyleTransition {
+	return slices.Clone(s.animator.transitions)
+}
+
+// SetTransitions replaces the transitions of the element
+func (s *ElementLayoutStylizer) SetTransitions(transitions []StyleTransition) {
+	s.animator.setTransitions(transitions)
+}
+
+// IsAnimating is true while any keyframe animation or transition is changing
+// the values of the element
+func (s *ElementLayoutStylizer) IsAnimating() bool {
+	if len(s.animator.running) > 0 {
+		return true
+	}
+	for i := range s.animator.animations {
+		if s.animator.animations[i].isRunning(s.animator.elapsed[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ElementLayoutStylizer) updateAnimations(deltaTime float64) {
+	if s.animator.update(deltaTime) {
+		s.queueComputedDiff(s.computedRules())
+	}
+}
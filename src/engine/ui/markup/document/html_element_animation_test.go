@@ -0,0 +1,180 @@
+/******************************************************************************/
+/* html_element_animation_test.go                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package document
+
+import (
+	"math"
+	"testing"
+
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/matrix"
+)
+
+func testKeyframes() map[string]rules.Keyframes {
+	return map[string]rules.Keyframes{
+		"grow": {Name: "grow", Frames: []rules.Keyframe{
+			{Offset: 0, Rules: []rules.Rule{testComputedRule("width", "10px")}},
+			{Offset: 1, Rules: []rules.Rule{testComputedRule("width", "20px")}},
+		}},
+		"fade": {Name: "fade", Frames: []rules.Keyframe{
+			{Offset: 0.5, Rules: []rules.Rule{testComputedRule("opacity", "0")}},
+		}},
+	}
+}
+
+func testRuleValue(t *testing.T, all []rules.Rule, property string) string {
+	t.Helper()
+	values, ok := findRuleValues(all, property)
+	if !ok || len(values) != 1 {
+		t.Fatalf("expected a single %s value in %#v", property, all)
+	}
+	return values[0].Str
+}
+
+func TestAnimationProgressHonorsDirectionAndFill(t *testing.T) {
+	a := NewStyleAnimation("grow")
+	a.Duration = 1
+	a.Delay = 1
+	a.IterationCount = 2
+	a.Direction = AnimationDirectionAlternate
+	if _, ok := a.progress(0.5); ok {
+		t.Fatal("expected no effect during the delay without a backwards fill")
+	}
+	if p, ok := a.progress(1.25); !ok || !matrix.Approx(p, 0.25) {
+		t.Fatalf("expected the first iteration to play forwards, got %f", p)
+	}
+	if p, ok := a.progress(2.25); !ok || !matrix.Approx(p, 0.75) {
+		t.Fatalf("expected the second iteration to play backwards, got %f", p)
+	}
+	if _, ok := a.progress(3.5); ok {
+		t.Fatal("expected no effect after the end without a forwards fill")
+	}
+	a.FillMode = AnimationFillModeBoth
+	if p, ok := a.progress(0.5); !ok || p != 0 {
+		t.Fatalf("expected a backwards fill to hold the start, got %f", p)
+	}
+	if p, ok := a.progress(3.5); !ok || p != 0 {
+		t.Fatalf("expected a forwards fill to hold the end of the reversed iteration, got %f", p)
+	}
+	a.IterationCount = math.Inf(1)
+	if !a.isRunning(1000) {
+		t.Fatal("expected an infinite animation to keep running")
+	}
+}
+
+func TestAnimatorInterpolatesKeyframes(t *testing.T) {
+	a := styleAnimator{keyframes: testKeyframes()}
+	grow := NewStyleAnimation("grow")
+	grow.Duration = 2
+	grow.Timing, _ = TimingFunctionByName("linear")
+	fade := NewStyleAnimation("fade")
+	fade.Duration = 2
+	fade.Timing = grow.Timing
+	a.setAnimations([]StyleAnimation{grow, fade})
+	base := []rules.Rule{testComputedRule("opacity", "1")}
+	a.update(0.5)
+	all := a.apply(rules.CloneRules(base))
+	if w := testRuleValue(t, all, "width"); w != "12.5px" {
+		t.Fatalf("expected the width to be a quarter of the way through, got %s", w)
+	}
+	if o := testRuleValue(t, all, "opacity"); o != "0.5" {
+		t.Fatalf("expected the opacity to blend from its own value to the 50%% step, got %s", o)
+	}
+	a.update(1)
+	all = a.apply(rules.CloneRules(base))
+	if o := testRuleValue(t, all, "opacity"); o != "0.5" {
+		t.Fatalf("expected the opacity to blend back to its own value, got %s", o)
+	}
+	a.update(1)
+	if a.update(1) {
+		t.Fatal("expected the animations to have stopped changing")
+	}
+	all = a.apply(rules.CloneRules(base))
+	if _, ok := findRuleValues(all, "width"); ok {
+		t.Fatal("expected the finished animation to stop changing the width")
+	}
+	// Changing the animation list keeps the time of animations still in it
+	a.setAnimations([]StyleAnimation{fade})
+	if a.elapsed[0] != 2.5 {
+		t.Fatalf("expected the fade animation to keep its time, got %f", a.elapsed[0])
+	}
+}
+
+func TestAnimatorTransitionsChangedValues(t *testing.T) {
+	a := styleAnimator{}
+	tr := NewStyleTransition("background-color")
+	tr.Duration = 1
+	tr.Timing, _ = TimingFunctionByName("linear")
+	a.setTransitions([]StyleTransition{tr})
+	a.apply([]rules.Rule{testComputedRule("background-color", "#000000")})
+	all := a.apply([]rules.Rule{testComputedRule("background-color", "#ffffff")})
+	if c := testRuleValue(t, all, "background-color"); c != "#000000ff" {
+		t.Fatalf("expected the transition to start from the old color, got %s", c)
+	}
+	a.update(0.5)
+	all = a.apply([]rules.Rule{testComputedRule("background-color", "#ffffff")})
+	if c := testRuleValue(t, all, "background-color"); c != "#7f7f7fff" {
+		t.Fatalf("expected the color to be half way, got %s", c)
+	}
+	// Going back part way through blends from where the color currently is
+	all = a.apply([]rules.Rule{testComputedRule("background-color", "#000000")})
+	if c := testRuleValue(t, all, "background-color"); c != "#7f7f7fff" {
+		t.Fatalf("expected the reversed transition to start half way, got %s", c)
+	}
+	a.update(1)
+	all = a.apply([]rules.Rule{testComputedRule("background-color", "#000000")})
+	if c := testRuleValue(t, all, "background-color"); c != "#000000" {
+		t.Fatalf("expected the finished transition to leave the new color, got %s", c)
+	}
+	all = a.apply([]rules.Rule{testComputedRule("width", "10px")})
+	if len(all) != 1 || len(a.running) != 0 {
+		t.Fatalf("expected properties without a transition to be left alone, got %#v", all)
+	}
+}
+
+func TestInterpolateValues(t *testing.T) {
+	from := []rules.PropertyValue{{Str: "translateX", Args: []string{"0px"}, ArgNums: []float32{0}}}
+	to := []rules.PropertyValue{{Str: "translateX", Args: []string{"20px"}, ArgNums: []float32{20}}}
+	out, ok := interpolateValues(from, to, 0.25)
+	if !ok || out[0].Args[0] != "5px" || out[0].ArgNums[0] != 5 {
+		t.Fatalf("expected the transform to blend, got %#v", out)
+	}
+	out, ok = interpolateValues([]rules.PropertyValue{{Str: "none"}}, to, 0.5)
+	if !ok || out[0].Args[0] != "10px" {
+		t.Fatalf("expected none to blend as an identity transform, got %#v", out)
+	}
+	out, ok = interpolateValues([]rules.PropertyValue{{Str: "red"}},
+		[]rules.PropertyValue{{Str: "rgba", Args: []string{"0", "0", "255", "0.5"}}}, 1)
+	if !ok || out[0].Str != "#0000ff7f" {
+		t.Fatalf("expected the colors to blend, got %#v", out)
+	}
+	if _, ok = interpolateValues([]rules.PropertyValue{{Str: "10px"}}, []rules.PropertyValue{{Str: "50%"}}, 0.5); ok {
+		t.Fatal("expected lengths with different units not to blend")
+	}
+	if _, ok = interpolateValues([]rules.PropertyValue{{Str: "block"}}, []rules.PropertyValue{{Str: "none"}}, 0.5); ok {
+		t.Fatal("expected keywords not to blend")
+	}
+}
+
+func TestTimingFunctions(t *testing.T) {
+	steps := TimingFunction{Steps: 4}
+	if v := steps.Apply(0.3); v != 0.25 {
+		t.Fatalf("expected steps to jump at the end of each step, got %f", v)
+	}
+	steps.StepStart = true
+	if v := steps.Apply(0.3); v != 0.5 {
+		t.Fatalf("expected steps to jump at the start of each step, got %f", v)
+	}
+	linear := TimingFunction{Bezier: []float32{0, 0, 1, 1}}
+	if v := linear.Apply(0.3); !matrix.ApproxTo(v, 0.3, 0.001) {
+		t.Fatalf("expected a straight bezier to be linear, got %f", v)
+	}
+	easeIn, _ := TimingFunctionByName("ease-in")
+	if v := easeIn.Apply(0.5); v >= 0.5 {
+		t.Fatalf("expected ease-in to start slow, got %f", v)
+	}
+}
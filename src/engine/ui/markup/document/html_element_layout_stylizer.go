@@ -83,6 +83,7 @@ type ElementLayoutStylizer struct {
 	currentState     rules.RuleInvoke
 	interestedStates rules.RuleInvoke
 	appliedRules     []rules.Rule
+	animator         styleAnimator
 	pendingLayout    bool
 	pendingPaint     bool
 }
@@ -377,7 +378,7 @@ func (s *ElementLayoutStylizer) computedRules() []rules.Rule {
 			}
 		}
 	}
-	all := append(a, b...)
+	all := s.animator.apply(append(a, b...))
 	// Look ahead to see if any upcoming properties can be merged
 	for i := 0; i < len(all); i++ {
 		if p, ok := LinkedPropertyMap[all[i].Property]; ok {
@@ -565,6 +566,7 @@ func (s *ElementLayoutStylizer) clone(newElm *Element) ElementLayoutStylizer {
 	out := ElementLayoutStylizer{
 		element: weak.Make(newElm),
 	}
+	out.animator.keyframes = s.animator.keyframes
 	out.ReplaceRules(s.styleRules)
 	return out
 }
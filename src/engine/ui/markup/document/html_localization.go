@@ -0,0 +1,62 @@
+/******************************************************************************/
+/* html_localization.go                                                       */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package document
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"kaijuengine.com/engine/ui"
+)
+
+const (
+	// localizationKeyAttr marks an element whose text is translated from the
+	// string tables, <span data-i18n="menu.play">Play</span>. The text inside
+	// of the element is shown when no table has the key.
+	localizationKeyAttr = "data-i18n"
+	// localizationArgPrefix is the prefix of the attributes that fill the
+	// named placeholders, data-i18n-name="Bob" fills {name}
+	localizationArgPrefix = localizationKeyAttr + "-"
+	// localizationCountAttr picks the plural form of the text and fills the
+	// {count} placeholder
+	localizationCountAttr = localizationArgPrefix + "count"
+)
+
+// localizeLabel sets up the label of a text element to be translated when its
+// parent element has a string table key
+func localizeLabel(label *ui.Label, parent *Element, fallback string) {
+	if parent == nil || !parent.HasAttribute(localizationKeyAttr) {
+		return
+	}
+	key := parent.Attribute(localizationKeyAttr)
+	var args map[string]any
+	count, isPlural := 0, false
+	for _, a := range parent.attr {
+		if !strings.HasPrefix(a.Key, localizationArgPrefix) {
+			continue
+		}
+		if a.Key == localizationCountAttr {
+			var err error
+			if count, err = strconv.Atoi(a.Val); err != nil {
+				slog.Warn("the localization count is not a number", "key", key, "count", a.Val)
+			}
+			isPlural = err == nil
+			continue
+		}
+		if args == nil {
+			args = map[string]any{}
+		}
+		args[strings.TrimPrefix(a.Key, localizationArgPrefix)] = a.Val
+	}
+	label.SetTextKeyFallback(fallback)
+	if isPlural {
+		label.SetTextKeyPlural(key, count, args)
+	} else {
+		label.SetTextKey(key, args)
+	}
+}
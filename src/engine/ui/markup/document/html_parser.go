@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"weak"
 
 	xhtml "golang.org/x/net/html"
@@ -109,6 +110,7 @@ type Document struct {
 	TopElements       []*Element
 	HeadElements      []*Element
 	onWindowResizeId  events.Id
+	animationUpdateId engine.UpdateId
 	groups            map[string][]*Element
 	ids               map[string]*Element
 	idsMutex          sync.RWMutex
@@ -119,6 +121,7 @@ type Document struct {
 	firstFocusElement *ui.UI
 	lastFocusElement  *ui.UI
 	funcMap           map[string]func(*Element)
+	stylesQueued      atomic.Bool
 	//Debug      struct {
 	//	ReloadEventId events.Id
 	//}
@@ -130,23 +133,40 @@ func (d *Document) SetupStyle(style rules.StyleSheet, host *engine.Host, stylize
 	d.host = weak.Make(host)
 	d.stylizer.ApplyStyles(d.style, d)
 	wd := weak.Make(d)
+	// Re-styling on resize is what re-evaluates the @media rules
 	d.onWindowResizeId = host.Window.OnResize.Add(func() {
-		sd := wd.Value()
-		h := sd.host.Value()
-		if sd != nil && h != nil {
-			h.RunOnMainThread(sd.ApplyStyles)
+		if sd := wd.Value(); sd != nil {
+			sd.queueApplyStyles()
+		}
+	})
+	// Animations are stepped in the regular update rather than the UI update
+	// so that they never run at the same time as the UI is being cleaned
+	host.Updater.RemoveUpdate(&d.animationUpdateId)
+	d.animationUpdateId = host.Updater.AddUpdate(func(deltaTime float64) {
+		if sd := wd.Value(); sd != nil {
+			sd.updateAnimations(deltaTime)
 		}
 	})
 	type documentCleanup struct {
-		host weak.Pointer[engine.Host]
-		eid  events.Id
+		host     weak.Pointer[engine.Host]
+		eid      events.Id
+		updateId engine.UpdateId
 	}
 	runtime.AddCleanup(d, func(dc documentCleanup) {
 		h := dc.host.Value()
+		if h != nil {
+			h.Updater.RemoveUpdate(&dc.updateId)
+		}
 		if h != nil && h.Window != nil {
 			h.Window.OnResize.Remove(dc.eid)
 		}
-	}, documentCleanup{d.host, d.onWindowResizeId})
+	}, documentCleanup{d.host, d.onWindowResizeId, d.animationUpdateId})
+}
+
+func (d *Document) updateAnimations(deltaTime float64) {
+	for i := range d.Elements {
+		d.Elements[i].Stylizer.updateAnimations(deltaTime)
+	}
 }
 
 func (h *Document) GetElementById(id string) (*Element, bool) {
@@ -280,6 +300,7 @@ func (d *Document) createUIElement(uiMan *ui.Manager, e *Element, parent *ui.Pan
 		label.SetJustify(rendering.FontJustifyLeft)
 		label.SetBaseline(rendering.FontBaselineTop)
 		label.SetBGColor(matrix.ColorTransparent())
+		localizeLabel(label, e.Parent.Value(), txt)
 		if parent := e.Parent.Value(); parent != nil && parent.IsButton() && parent.UI != nil {
 			label.Base().Layout().Stylizer = ui.StretchCenterStylizer{
 				BasicStylizer: ui.BasicStylizer{Parent: weak.Make(parent.UI)},
@@ -613,6 +634,7 @@ func (d *Document) Destroy() {
 		}
 	}
 	if host := d.host.Value(); host != nil {
+		host.Updater.RemoveUpdate(&d.animationUpdateId)
 		for _, e := range d.Elements {
 			host.DestroyEntity(e.UI.Entity())
 		}
@@ -945,6 +967,53 @@ func inlineStyleDeclarationName(declaration string) (string, bool) {
 // styles of many elements at the same time, then apply styles after.
 func (d *Document) ApplyStyles() { d.stylizer.ApplyStyles(d.style, d) }
 
+// SetStyleStateEvents makes the styles of the document apply again when one
+// of the given events fires on any of its elements. The stylizer sets these
+// for selectors that match on the live state of an element, like :checked or
+// :focus-within, which are otherwise only checked when styles are applied.
+func (d *Document) SetStyleStateEvents(evtTypes []ui.EventType) {
+	// The UI of each element holds its events, so the handler only holds the
+	// document weakly to not keep it from being cleaned up
+	wd := weak.Make(d)
+	queueApplyStyles := func() {
+		if sd := wd.Value(); sd != nil {
+			sd.queueApplyStyles()
+		}
+	}
+	for _, elm := range d.Elements {
+		if elm.UI == nil {
+			continue
+		}
+		for i := range elm.styleStateEvtIds {
+			evtType := ui.EventType(i)
+			want := slices.Contains(evtTypes, evtType)
+			if id := elm.styleStateEvtIds[i]; want && id == 0 {
+				elm.styleStateEvtIds[i] = elm.UI.AddEvent(evtType, queueApplyStyles)
+			} else if !want && id != 0 {
+				elm.UI.RemoveEvent(evtType, id)
+				elm.styleStateEvtIds[i] = 0
+			}
+		}
+	}
+}
+
+// queueApplyStyles applies the styles on the main thread once, no matter how
+// many changes happened before then, like a blur followed by a focus or the
+// many resize events of dragging the window border
+func (d *Document) queueApplyStyles() {
+	host := d.host.Value()
+	if host == nil || !d.stylesQueued.CompareAndSwap(false, true) {
+		return
+	}
+	wd := weak.Make(d)
+	host.RunOnMainThread(func() {
+		if sd := wd.Value(); sd != nil && sd.stylizer != nil {
+			sd.stylesQueued.Store(false)
+			sd.ApplyStyles()
+		}
+	})
+}
+
 // DuplicateElement will create a duplicate of a given element, nesting it under
 // the same parent as the given element (at the end). If you wish to just
 // duplicate an element and use one of the Insert functions, then use
@@ -0,0 +1,189 @@
+/******************************************************************************/
+/* html_style_interpolation.go                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package document
+
+import (
+	"strconv"
+	"strings"
+
+	"kaijuengine.com/engine/ui/markup/css/helpers"
+	"kaijuengine.com/engine/ui/markup/css/rules"
+	"kaijuengine.com/matrix"
+)
+
+// interpolateValues blends the values of a property between two of its
+// states. Numbers and lengths with the same unit, colors and transform
+// functions can be blended, false is returned for anything else.
+func interpolateValues(from, to []rules.PropertyValue, t float32) ([]rules.PropertyValue, bool) {
+	if len(from) != len(to) {
+		return nil, false
+	}
+	out := make([]rules.PropertyValue, len(from))
+	for i := range from {
+		v, ok := interpolateValue(from[i], to[i], t)
+		if !ok {
+			return nil, false
+		}
+		out[i] = v
+	}
+	return out, true
+}
+
+// steppedValues is used for values that can't be blended, they flip from one
+// to the other half way through
+func steppedValues(from, to []rules.PropertyValue, t float32) []rules.PropertyValue {
+	if t < 0.5 {
+		return clonePropertyValues(from)
+	}
+	return clonePropertyValues(to)
+}
+
+func interpolateValue(from, to rules.PropertyValue, t float32) (rules.PropertyValue, bool) {
+	if a, ok := colorFromValue(from); ok {
+		if b, ok := colorFromValue(to); ok {
+			var c matrix.Color
+			for i := range c {
+				c[i] = matrix.Lerp(a[i], b[i], t)
+			}
+			return rules.PropertyValue{Str: c.Hex()}, true
+		}
+		return rules.PropertyValue{}, false
+	}
+	if from.IsFunction() || to.IsFunction() {
+		from, to = identityTransformFor(from, to), identityTransformFor(to, from)
+		if from.Str != to.Str || len(from.Args) != len(to.Args) {
+			return rules.PropertyValue{}, false
+		}
+		out := rules.PropertyValue{
+			Str:     from.Str,
+			Args:    make([]string, len(from.Args)),
+			ArgNums: make([]float32, len(from.Args)),
+		}
+		for i := range from.Args {
+			arg, ok := interpolateNumber(from.Args[i], to.Args[i], t)
+			if !ok {
+				return rules.PropertyValue{}, false
+			}
+			out.Args[i] = arg
+			if i < len(from.ArgNums) && i < len(to.ArgNums) {
+				out.ArgNums[i] = matrix.Lerp(from.ArgNums[i], to.ArgNums[i], t)
+			}
+		}
+		return out, true
+	}
+	str, ok := interpolateNumber(from.Str, to.Str, t)
+	if !ok {
+		return rules.PropertyValue{}, false
+	}
+	return rules.PropertyValue{Str: str, Num: matrix.Lerp(from.Num, to.Num, t)}, true
+}
+
+// interpolateNumber blends two numbers that share a unit, a unitless zero
+// is allowed to blend with any unit (0 to 10px)
+func interpolateNumber(from, to string, t float32) (string, bool) {
+	a, aUnit, ok := splitNumber(from)
+	if !ok {
+		return "", false
+	}
+	b, bUnit, ok := splitNumber(to)
+	if !ok {
+		return "", false
+	}
+	if aUnit != bUnit {
+		switch {
+		case aUnit == "" && a == 0:
+			aUnit = bUnit
+		case bUnit == "" && b == 0:
+			bUnit = aUnit
+		default:
+			return "", false
+		}
+	}
+	v := matrix.Lerp(a, b, t)
+	return strconv.FormatFloat(float64(v), 'f', -1, 32) + aUnit, true
+}
+
+func splitNumber(str string) (float32, string, bool) {
+	end := 0
+	for end < len(str) && strings.IndexByte("+-.0123456789", str[end]) >= 0 {
+		end++
+	}
+	if end == 0 {
+		return 0, "", false
+	}
+	v, err := strconv.ParseFloat(str[:end], 32)
+	if err != nil {
+		return 0, "", false
+	}
+	return float32(v), str[end:], true
+}
+
+// identityTransformFor swaps a "none" transform for the function of the other
+// value that leaves the element as it is, so that "none" can blend with
+// something like "translateX(20px)"
+func identityTransformFor(value, other rules.PropertyValue) rules.PropertyValue {
+	if value.Str != "none" || !other.IsFunction() {
+		return value
+	}
+	identity := "0"
+	if strings.HasPrefix(other.Str, "scale") {
+		identity = "1"
+	}
+	out := rules.PropertyValue{
+		Str:     other.Str,
+		Args:    make([]string, len(other.Args)),
+		ArgNums: make([]float32, len(other.Args)),
+	}
+	for i := range out.Args {
+		out.Args[i] = identity
+	}
+	return out
+}
+
+func colorFromValue(v rules.PropertyValue) (matrix.Color, bool) {
+	switch v.Str {
+	case "rgb", "rgba":
+		if len(v.Args) < 3 {
+			return matrix.Color{}, false
+		}
+		c := matrix.ColorWhite()
+		for i := range min(len(v.Args), 4) {
+			n, unit, ok := splitNumber(v.Args[i])
+			if !ok {
+				return matrix.Color{}, false
+			}
+			switch {
+			case unit == "%":
+				c[i] = n / 100
+			case i == 3:
+				c[i] = n
+			default:
+				c[i] = n / 255
+			}
+		}
+		return c, true
+	}
+	hex := strings.ToLower(v.Str)
+	if hex == "transparent" {
+		hex = "#00000000"
+	} else if named, ok := helpers.ColorMap[hex]; ok {
+		hex = named
+	}
+	if !strings.HasPrefix(hex, "#") {
+		return matrix.Color{}, false
+	}
+	c, err := matrix.ColorFromHexString(hex)
+	return c, err == nil
+}
+
+func clonePropertyValues(values []rules.PropertyValue) []rules.PropertyValue {
+	out := make([]rules.PropertyValue, len(values))
+	for i := range values {
+		out[i] = values[i].Clone()
+	}
+	return out
+}
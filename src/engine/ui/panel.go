@@ -111,6 +111,7 @@ const (
 	panelBitsAllowDragScroll
 	panelBitsAllowClickThrough
 	panelBitsWasDirtied
+	panelBitsBlurredFill
 )
 
 var UIScrollSpeed float32 = 20
@@ -153,6 +154,11 @@ type panelData struct {
 	maxSize             matrix.Vec2
 	aspectRatio         float32
 	usesBorderBox       bool
+	boxShadows          []Shadow
+	shadowDrawings      []shadowDrawing
+	blurSource          *rendering.Texture
+	blurTexture         *rendering.Texture
+	blurAmount          float32
 }
 
 func (b panelBits) isScrolling() bool        { return b&panelBitsIsScrolling != 0 }
@@ -161,18 +167,21 @@ func (b panelBits) isFrozen() bool           { return b&panelBitsIsFrozen != 0 }
 func (b panelBits) allowDragScroll() bool    { return b&panelBitsAllowDragScroll != 0 }
 func (b panelBits) allowClickThrough() bool  { return b&panelBitsAllowClickThrough != 0 }
 func (b panelBits) wasDirtied() bool         { return b&panelBitsWasDirtied != 0 }
+func (b panelBits) blurredFill() bool        { return b&panelBitsBlurredFill != 0 }
 func (b *panelBits) setIsScrolling()         { *b |= panelBitsIsScrolling }
 func (b *panelBits) setDragging()            { *b |= panelBitsIsDragging }
 func (b *panelBits) setFrozen()              { *b |= panelBitsIsFrozen }
 func (b *panelBits) setAllowDragScroll()     { *b |= panelBitsAllowDragScroll }
 func (b *panelBits) setAllowClickThrough()   { *b |= panelBitsAllowClickThrough }
 func (b *panelBits) setWasDirtied()          { *b |= panelBitsWasDirtied }
+func (b *panelBits) setBlurredFill()         { *b |= panelBitsBlurredFill }
 func (b *panelBits) resetIsScrolling()       { *b &= ^panelBitsIsScrolling }
 func (b *panelBits) resetDragging()          { *b &= ^panelBitsIsDragging }
 func (b *panelBits) resetFrozen()            { *b &= ^panelBitsIsFrozen }
 func (b *panelBits) resetAllowDragScroll()   { *b &= ^panelBitsAllowDragScroll }
 func (b *panelBits) resetAllowClickThrough() { *b &= ^panelBitsAllowClickThrough }
 func (b *panelBits) resetWasDirtied()        { *b &= ^panelBitsWasDirtied }
+func (b *panelBits) resetBlurredFill()       { *b &= ^panelBitsBlurredFill }
 
 func (p *panelData) innerPanelData() *panelData { return p }
 func (p *panelData) HasMinWidth() bool          { return p.minSize.X() >= 0 }
@@ -226,10 +235,21 @@ func (panel *Panel) Init(texture *rendering.Texture, elmType ElementType) {
 		panel.ensureBGExists(texture)
 	}
 	panel.entity.OnActivate.Add(func() {
-		panel.shaderData.Activate()
+		if !panel.PanelData().flags.blurredFill() {
+			panel.shaderData.Activate()
+		}
+		panel.activateShadows()
 		base.SetDirty(DirtyTypeLayout)
 	})
-	panel.entity.OnDeactivate.Add(func() { panel.shaderData.Deactivate() })
+	panel.entity.OnDeactivate.Add(func() {
+		panel.shaderData.Deactivate()
+		panel.deactivateShadows()
+	})
+	base.AddEvent(EventTypeDestroy, func() {
+		if panel.elmData != nil {
+			panel.clearShadows()
+		}
+	})
 }
 
 func (p *Panel) MaxScroll() matrix.Vec2  { return p.PanelData().maxScroll }
@@ -541,7 +561,7 @@ func (p *Panel) updateShaderVisibility() {
 	// If the panel is completely outside the scissor, deactivate its shader data.
 	if right < scissor.X() || left > scissor.Z() || top < scissor.Y() || bottom > scissor.W() {
 		p.shaderData.Deactivate()
-	} else {
+	} else if !p.PanelData().flags.blurredFill() {
 		p.shaderData.Activate()
 	}
 }
@@ -754,6 +774,9 @@ func (p *Panel) panelRender() {
 	if pd.drawing.IsValid() {
 		p.shaderData.setSize2d(p.Base())
 	}
+	filter := p.Base().appliedFilter()
+	p.shaderData.setColorFilter(filter.color)
+	p.updateShadows(filter)
 	pd.requestScrollX.requested = false
 	pd.requestScrollY.requested = false
 }
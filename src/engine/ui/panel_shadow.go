@@ -0,0 +1,341 @@
+/******************************************************************************/
+/* panel_shadow.go                                                            */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/assets"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering"
+)
+
+// shadowDepthRange is how far in front of or behind the panel its shadows are
+// placed, it must stay below the depth step between a panel and its children
+const shadowDepthRange = 0.005
+
+type shadowDrawing struct {
+	shaderData *ShaderData
+	key        string
+}
+
+type plannedShadow struct {
+	shape shadowShape
+	quad  matrix.Vec2
+	edge  matrix.Vec2
+	// offset is from the center of the panel in world units
+	offset matrix.Vec3
+	color  matrix.Color
+}
+
+func (p *Panel) BoxShadows() []Shadow { return p.PanelData().boxShadows }
+
+// SetBoxShadows sets the shadows drawn around the panel, or within it for the
+// inset shadows. The first shadow in the list is drawn on top of the others.
+func (p *Panel) SetBoxShadows(shadows []Shadow) {
+	pd := p.PanelData()
+	if slices.Equal(pd.boxShadows, shadows) {
+		return
+	}
+	pd.boxShadows = slices.Clone(shadows)
+	p.Base().SetDirty(DirtyTypeGenerated)
+}
+
+// shadowMargin is how far the blur of a shadow reaches past its edge, the
+// blur is a gaussian with a standard deviation of half the blur radius which
+// fades out after three deviations
+func shadowMargin(blur float32) float32 {
+	return matrix.Ceil(max(blur, 0) * 1.5)
+}
+
+func outerShadowPlan(size matrix.Vec2, radius matrix.Vec4, s Shadow) (plannedShadow, bool) {
+	w := size.X() + s.Spread*2
+	h := size.Y() + s.Spread*2
+	if w <= 0 || h <= 0 || s.Color.A() <= 0 {
+		return plannedShadow{}, false
+	}
+	margin := shadowMargin(s.Blur)
+	radius = spreadRadius(radius, s.Spread)
+	corner := margin*2 + max(radius[0], radius[1], radius[2], radius[3])
+	quad := matrix.NewVec2(w+margin*2, h+margin*2)
+	tw, ex := nineSliceAxis(quad.X(), corner)
+	th, ey := nineSliceAxis(quad.Y(), corner)
+	return plannedShadow{
+		shape: shadowShape{
+			width:  tw,
+			height: th,
+			rect:   matrix.NewVec4(margin, margin, float32(tw)-margin, float32(th)-margin),
+			radius: radius,
+			blur:   max(s.Blur, 0),
+		},
+		quad:   quad,
+		edge:   matrix.NewVec2(ex, ey),
+		offset: matrix.NewVec3(s.Offset.X(), -s.Offset.Y(), 0),
+		color:  s.Color,
+	}, true
+}
+
+func insetShadowPlan(size matrix.Vec2, radius matrix.Vec4, s Shadow) (plannedShadow, bool) {
+	if size.X() <= 0 || size.Y() <= 0 || s.Color.A() <= 0 {
+		return plannedShadow{}, false
+	}
+	margin := shadowMargin(s.Blur)
+	corner := margin*2 + max(s.Spread, 0) + max(radius[0], radius[1], radius[2], radius[3])
+	tw, ex := nineSliceAxis(size.X(), corner+matrix.Abs(s.Offset.X()))
+	th, ey := nineSliceAxis(size.Y(), corner+matrix.Abs(s.Offset.Y()))
+	ox, oy := s.Offset.X(), s.Offset.Y()
+	return plannedShadow{
+		shape: shadowShape{
+			width:  tw,
+			height: th,
+			rect: matrix.NewVec4(s.Spread+ox, s.Spread+oy,
+				float32(tw)-s.Spread+ox, float32(th)-s.Spread+oy),
+			radius:    spreadRadius(radius, -s.Spread),
+			blur:      max(s.Blur, 0),
+			inset:     true,
+			box:       matrix.NewVec4(0, 0, float32(tw), float32(th)),
+			boxRadius: radius,
+		},
+		quad:  size,
+		edge:  matrix.NewVec2(ex, ey),
+		color: s.Color,
+	}, true
+}
+
+// updateShadows creates, updates and removes the drawings of the box shadows,
+// the drop shadows of the filter and the blurred fill of the panel
+func (p *Panel) updateShadows(filter appliedFilter) {
+	pd := p.PanelData()
+	if len(pd.boxShadows) == 0 && len(pd.shadowDrawings) == 0 &&
+		len(filter.dropShadows) == 0 && filter.blur <= 0 &&
+		!pd.flags.blurredFill() && pd.blurSource == nil {
+		return
+	}
+	defer tracing.NewRegion("Panel.updateShadows").End()
+	ws := p.entity.Transform.WorldScale()
+	size := matrix.NewVec2(ws.X(), ws.Y())
+	radius := shadowRadius(p.shaderData.BorderRadius)
+	fill := p.ownCalculatedFill()
+	var outer, inset []plannedShadow
+	for _, s := range pd.boxShadows {
+		if s.Inset {
+			if plan, ok := insetShadowPlan(size, radius, s); ok {
+				inset = append(inset, plan)
+			}
+		} else if plan, ok := outerShadowPlan(size, radius, s); ok {
+			outer = append(outer, plan)
+		}
+	}
+	if fill.A() > 0 {
+		for _, s := range filter.dropShadows {
+			s.Spread, s.Inset = 0, false
+			s.Color.SetA(s.Color.A() * fill.A())
+			if plan, ok := outerShadowPlan(size, radius, s); ok {
+				outer = append(outer, plan)
+			}
+		}
+	}
+	plans := make([]plannedShadow, 0, len(outer)+len(inset)+1)
+	step := float32(shadowDepthRange) / float32(len(outer)+len(inset)+1)
+	for i := range outer {
+		outer[i].offset.SetZ(-step * float32(i+1))
+		plans = append(plans, outer[i])
+	}
+	blurredFill := false
+	if p.updateBackgroundBlur(filter.blur) {
+		blurred := Shadow{Color: fill, Blur: filter.blur}
+		if plan, ok := outerShadowPlan(size, radius, blurred); ok {
+			plans = append(plans, plan)
+			blurredFill = true
+		}
+	}
+	for i := range inset {
+		inset[i].offset.SetZ(step * float32(len(inset)-i))
+		plans = append(plans, inset[i])
+	}
+	p.syncShadowDrawings(plans, filter.color)
+	p.setBlurredFill(blurredFill)
+}
+
+func (p *Panel) syncShadowDrawings(plans []plannedShadow, colorFilter matrix.ColorMatrix) {
+	pd := p.PanelData()
+	host := p.Base().Host()
+	if host == nil {
+		return
+	}
+	old := pd.shadowDrawings
+	drawings := make([]shadowDrawing, 0, len(plans))
+	pos := p.entity.Transform.WorldPosition()
+	scissor := p.Base().clipScissor()
+	for i := range plans {
+		key := plans[i].shape.key()
+		var sd *ShaderData
+		if i < len(old) && old[i].key == key {
+			sd = old[i].shaderData
+		} else {
+			if i < len(old) {
+				old[i].shaderData.Destroy()
+			}
+			if sd = addShadowDrawing(host, plans[i].shape, key); sd == nil {
+				continue
+			}
+		}
+		plans[i].apply(sd, pos, scissor, colorFilter)
+		drawings = append(drawings, shadowDrawing{shaderData: sd, key: key})
+	}
+	for i := len(plans); i < len(old); i++ {
+		old[i].shaderData.Destroy()
+	}
+	pd.shadowDrawings = drawings
+}
+
+func (s *plannedShadow) apply(sd *ShaderData, pos matrix.Vec3, scissor matrix.Vec4, colorFilter matrix.ColorMatrix) {
+	model := matrix.Mat4Identity()
+	model.Scale(matrix.NewVec3(s.quad.X(), s.quad.Y(), 1))
+	model.Translate(pos.Add(s.offset))
+	sd.SetModel(model)
+	sd.FgColor = s.color
+	sd.Size2D = matrix.NewVec4(s.quad.X(), s.quad.Y(),
+		float32(s.shape.width), float32(s.shape.height))
+	sd.BorderLen = s.edge
+	sd.Scissor = scissor
+	sd.setColorFilter(colorFilter)
+}
+
+func addShadowDrawing(host *engine.Host, shape shadowShape, key string) *ShaderData {
+	cache := host.TextureCache()
+	tex, ok := cache.Find(key, rendering.TextureFilterLinear)
+	if !ok {
+		var err error
+		tex, err = cache.InsertRawTexture(key, shape.pixels(),
+			shape.width, shape.height, rendering.TextureFilterLinear)
+		if err != nil {
+			slog.Error("failed to create the shadow texture", "error", err)
+			return nil
+		}
+	}
+	tex.MipLevels = 1
+	material, err := host.MaterialCache().Material(assets.MaterialDefinitionUITransparent)
+	if err != nil {
+		slog.Error("failed to load the material",
+			"material", assets.MaterialDefinitionUITransparent, "error", err)
+		return nil
+	}
+	sd := &ShaderData{
+		ShaderDataBase: rendering.NewShaderDataBase(),
+		UVs:            matrix.Vec4{0.0, 0.0, 1.0, 1.0},
+	}
+	host.Drawings.AddDrawing(rendering.Drawing{
+		Material:   material.CreateInstance([]*rendering.Texture{tex}),
+		Mesh:       rendering.NewMeshQuad(host.MeshCache()),
+		ShaderData: sd,
+		Layer:      rendering.RenderLayerUI,
+		ViewCuller: &host.Cameras.UI,
+	})
+	return sd
+}
+
+// updateBackgroundBlur swaps the background texture of the panel with a
+// blurred copy of it. It returns true when the background is a plain fill,
+// which can't be blurred past the edges of the panel, so it has to be drawn
+// as a blurred shadow instead.
+func (p *Panel) updateBackgroundBlur(blur float32) bool {
+	pd := p.PanelData()
+	bg := p.Background()
+	if blur <= 0 || bg == nil || p.ownCalculatedFill().A() <= 0 {
+		if pd.blurTexture != nil && bg == pd.blurTexture {
+			p.SetBackground(pd.blurSource)
+		}
+		pd.blurSource, pd.blurTexture, pd.blurAmount = nil, nil, 0
+		return false
+	}
+	if bg.Key == assets.TextureSquare {
+		return true
+	}
+	if bg == pd.blurTexture && blur == pd.blurAmount {
+		return false
+	}
+	source := bg
+	if bg == pd.blurTexture {
+		source = pd.blurSource
+	} else if pd.blurTexture == nil && bg == pd.blurSource && blur == pd.blurAmount {
+		// Blurring this background already failed
+		return false
+	}
+	pd.blurSource, pd.blurTexture, pd.blurAmount = source, nil, blur
+	tex, err := blurredTexture(p.Base().Host(), source, blur)
+	if err != nil {
+		slog.Warn("the background of the panel can't be blurred",
+			"texture", source.Key, "error", err)
+		return false
+	}
+	pd.blurTexture = tex
+	p.SetBackground(tex)
+	return false
+}
+
+// blurredTexture returns a blurred copy of the texture, this requires the
+// pixels of the texture to be readable and uncompressed
+func blurredTexture(host *engine.Host, source *rendering.Texture, blur float32) (*rendering.Texture, error) {
+	key := fmt.Sprintf("%s#blur=%g", source.Key, blur)
+	cache := host.TextureCache()
+	if tex, ok := cache.Find(key, rendering.TextureFilterLinear); ok {
+		return tex, nil
+	}
+	data, err := cache.TexturePixels(source.Key, source.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Mem) != data.Width*data.Height*4 {
+		return nil, errors.New("only uncompressed RGBA textures can be blurred")
+	}
+	return cache.InsertRawTexture(key, blurPixels(data.Mem, data.Width, data.Height, blur*0.5),
+		data.Width, data.Height, rendering.TextureFilterLinear)
+}
+
+// setBlurredFill hides the drawing of the panel while its fill is being drawn
+// blurred by one of its shadow drawings
+func (p *Panel) setBlurredFill(blurred bool) {
+	pd := p.PanelData()
+	if blurred == pd.flags.blurredFill() {
+		return
+	}
+	if blurred {
+		pd.flags.setBlurredFill()
+		p.shaderData.Deactivate()
+	} else {
+		pd.flags.resetBlurredFill()
+		if p.entity.IsActive() {
+			p.shaderData.Activate()
+		}
+	}
+}
+
+func (p *Panel) activateShadows() {
+	for _, s := range p.PanelData().shadowDrawings {
+		s.shaderData.Activate()
+	}
+}
+
+func (p *Panel) deactivateShadows() {
+	for _, s := range p.PanelData().shadowDrawings {
+		s.shaderData.Deactivate()
+	}
+}
+
+func (p *Panel) clearShadows() {
+	pd := p.PanelData()
+	for _, s := range pd.shadowDrawings {
+		s.shaderData.Destroy()
+	}
+	pd.shadowDrawings = nil
+}
@@ -26,6 +26,10 @@ type ShaderData struct {
 	BorderLen    matrix.Vec2
 	OutlineColor matrix.Color
 	OutlineSize  matrix.Vec2
+	// Not sent to the GPU, these are used to pass the colors above through
+	// the filter of the element when the data is read for rendering
+	colorFilter *matrix.ColorMatrix
+	filtered    []byte
 }
 
 func (ShaderData) Size() int {
@@ -72,3 +76,39 @@ func (s *ShaderData) setSize2d(ui *UI) {
 	//	s.Size2D[3] = ui.textureSize.Y()
 	//}
 }
+
+// DataPointer returns the instance data that is copied to the GPU. When the
+// element has a color filter, the colors are filtered into a copy of the data
+// so that the colors set on the element are left untouched.
+func (s *ShaderData) DataPointer() unsafe.Pointer {
+	ptr := s.ShaderDataBase.DataPointer()
+	if s.colorFilter == nil {
+		return ptr
+	}
+	size := s.Size()
+	if len(s.filtered) != size {
+		s.filtered = make([]byte, size)
+	}
+	copy(s.filtered, unsafe.Slice((*byte)(ptr), size))
+	start := uintptr(ptr) - uintptr(unsafe.Pointer(s))
+	s.writeFiltered(unsafe.Offsetof(s.FgColor)-start, s.FgColor)
+	s.writeFiltered(unsafe.Offsetof(s.BgColor)-start, s.BgColor)
+	for i := range s.BorderColor {
+		s.writeFiltered(unsafe.Offsetof(s.BorderColor)-start+
+			uintptr(i)*unsafe.Sizeof(s.BorderColor[i]), s.BorderColor[i])
+	}
+	s.writeFiltered(unsafe.Offsetof(s.OutlineColor)-start, s.OutlineColor)
+	return unsafe.Pointer(&s.filtered[0])
+}
+
+func (s *ShaderData) writeFiltered(offset uintptr, color matrix.Color) {
+	*(*matrix.Color)(unsafe.Pointer(&s.filtered[offset])) = s.colorFilter.Apply(color)
+}
+
+func (s *ShaderData) setColorFilter(filter matrix.ColorMatrix) {
+	if filter.IsIdentity() {
+		s.colorFilter = nil
+	} else {
+		s.colorFilter = &filter
+	}
+}
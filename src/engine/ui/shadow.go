@@ -0,0 +1,205 @@
+/******************************************************************************/
+/* shadow.go                                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package ui
+
+import (
+	"fmt"
+	"math"
+
+	"kaijuengine.com/matrix"
+)
+
+// Shadow describes a box shadow of a panel or a text shadow of a label. The
+// offset is in pixels where a positive Y moves the shadow down. Spread and
+// Inset are only used by box shadows.
+type Shadow struct {
+	Offset matrix.Vec2
+	Color  matrix.Color
+	Blur   float32
+	Spread float32
+	Inset  bool
+}
+
+// shadowShape is everything needed to draw the alpha mask of a shadow, it is
+// also used as the key of the generated texture. All units are texture pixels
+// with the first row being the top of the shadow.
+type shadowShape struct {
+	width, height int
+	// rect is the left, top, right and bottom of the shape casting the shadow
+	rect matrix.Vec4
+	// radius is the top left, top right, bottom right and bottom left radius
+	radius matrix.Vec4
+	blur   float32
+	// Inset shadows are drawn outside of the rect, within the box
+	inset     bool
+	box       matrix.Vec4
+	boxRadius matrix.Vec4
+}
+
+func (s shadowShape) key() string {
+	return fmt.Sprintf("ui-shadow:%dx%d:%v:%v:%g:%t:%v:%v", s.width, s.height,
+		s.rect, s.radius, s.blur, s.inset, s.box, s.boxRadius)
+}
+
+// pixels draws the shadow as a white RGBA image where the alpha is the
+// strength of the shadow, the color of the shadow is applied when drawing
+func (s shadowShape) pixels() []byte {
+	alpha := make([]float32, s.width*s.height)
+	for y := range s.height {
+		for x := range s.width {
+			a := roundedRectCoverage(float32(x)+0.5, float32(y)+0.5, s.rect, s.radius)
+			if s.inset {
+				a = 1 - a
+			}
+			alpha[y*s.width+x] = a
+		}
+	}
+	blurChannel(alpha, s.width, s.height, s.blur*0.5)
+	out := make([]byte, len(alpha)*4)
+	for i, a := range alpha {
+		if s.inset {
+			x, y := i%s.width, i/s.width
+			a *= roundedRectCoverage(float32(x)+0.5, float32(y)+0.5, s.box, s.boxRadius)
+		}
+		out[i*4] = 255
+		out[i*4+1] = 255
+		out[i*4+2] = 255
+		out[i*4+3] = uint8(matrix.Clamp(a, 0, 1)*255 + 0.5)
+	}
+	return out
+}
+
+// roundedRectCoverage returns how much of the pixel centered at x, y is
+// covered by the rounded rectangle
+func roundedRectCoverage(x, y float32, rect, radius matrix.Vec4) float32 {
+	hw := (rect.Z() - rect.X()) * 0.5
+	hh := (rect.W() - rect.Y()) * 0.5
+	if hw <= 0 || hh <= 0 {
+		return 0
+	}
+	px := x - (rect.X() + hw)
+	py := y - (rect.Y() + hh)
+	var r float32
+	switch {
+	case px < 0 && py < 0:
+		r = radius[0]
+	case py < 0:
+		r = radius[1]
+	case px >= 0:
+		r = radius[2]
+	default:
+		r = radius[3]
+	}
+	r = min(max(r, 0), hw, hh)
+	qx := matrix.Abs(px) - hw + r
+	qy := matrix.Abs(py) - hh + r
+	outside := float32(math.Hypot(float64(max(qx, 0)), float64(max(qy, 0))))
+	dist := min(max(qx, qy), 0) + outside - r
+	return matrix.Clamp(0.5-dist, 0, 1)
+}
+
+// blurChannel runs a gaussian blur over the values in place, the edges of the
+// image are extended to fill the kernel
+func blurChannel(alpha []float32, width, height int, sigma float32) {
+	if sigma <= 0 || width == 0 || height == 0 {
+		return
+	}
+	radius := int(matrix.Ceil(sigma * 3))
+	kernel := make([]float32, radius*2+1)
+	total := float32(0)
+	for i := range kernel {
+		d := float32(i - radius)
+		kernel[i] = float32(math.Exp(float64(-(d * d) / (2 * sigma * sigma))))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+	tmp := make([]float32, len(alpha))
+	for y := range height {
+		row := alpha[y*width : (y+1)*width]
+		for x := range width {
+			v := float32(0)
+			for k, w := range kernel {
+				v += w * row[min(max(x+k-radius, 0), width-1)]
+			}
+			tmp[y*width+x] = v
+		}
+	}
+	for x := range width {
+		for y := range height {
+			v := float32(0)
+			for k, w := range kernel {
+				v += w * tmp[min(max(y+k-radius, 0), height-1)*width+x]
+			}
+			alpha[y*width+x] = v
+		}
+	}
+}
+
+// shadowRadius converts the panel's border radius into the top left, top
+// right, bottom right and bottom left order used by shadow shapes
+func shadowRadius(borderRadius matrix.Vec4) matrix.Vec4 {
+	return matrix.NewVec4(borderRadius.W(), borderRadius.Z(),
+		borderRadius.Y(), borderRadius.X())
+}
+
+// spreadRadius grows (or shrinks) the rounded corners along with the spread,
+// square corners stay square
+func spreadRadius(radius matrix.Vec4, spread float32) matrix.Vec4 {
+	for i := range radius {
+		if radius[i] > 0 {
+			radius[i] = max(radius[i]+spread, 0)
+		}
+	}
+	return radius
+}
+
+// nineSliceAxis returns the size of the texture and the edge length used to
+// nine slice it along one axis. The texture only needs to hold the corners
+// (which are the non-uniform part of the shadow) plus a center pixel that is
+// stretched over the rest of the quad.
+func nineSliceAxis(quad, corner float32) (int, float32) {
+	c := int(matrix.Ceil(corner)) + 1
+	if size := int(matrix.Ceil(quad)); size <= c*2+1 {
+		return max(size, 1), 0
+	}
+	return c*2 + 1, float32(c)
+}
+
+// blurPixels returns a gaussian blurred copy of the RGBA pixels, the colors
+// are blurred premultiplied so transparent pixels don't darken the edges
+func blurPixels(pixels []byte, width, height int, sigma float32) []byte {
+	count := width * height
+	channels := [4][]float32{}
+	for c := range channels {
+		channels[c] = make([]float32, count)
+	}
+	for i := range count {
+		a := float32(pixels[i*4+3]) / 255
+		for c := range 3 {
+			channels[c][i] = float32(pixels[i*4+c]) / 255 * a
+		}
+		channels[3][i] = a
+	}
+	for c := range channels {
+		blurChannel(channels[c], width, height, sigma)
+	}
+	out := make([]byte, count*4)
+	for i := range count {
+		a := channels[3][i]
+		for c := range 3 {
+			v := float32(0)
+			if a > 0 {
+				v = channels[c][i] / a
+			}
+			out[i*4+c] = uint8(matrix.Clamp(v, 0, 1)*255 + 0.5)
+		}
+		out[i*4+3] = uint8(matrix.Clamp(a, 0, 1)*255 + 0.5)
+	}
+	return out
+}
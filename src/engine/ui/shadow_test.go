@@ -0,0 +1,115 @@
+/******************************************************************************/
+/* shadow_test.go                                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package ui
+
+import (
+	"testing"
+	"unsafe"
+
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/rendering"
+)
+
+func shadowAlphaAt(pixels []byte, width, x, y int) uint8 {
+	return pixels[(y*width+x)*4+3]
+}
+
+func TestOuterShadowPlanCoversBlurAndSpread(t *testing.T) {
+	s := Shadow{Offset: matrix.NewVec2(4, 6), Blur: 3, Spread: 2, Color: matrix.ColorBlack()}
+	plan, ok := outerShadowPlan(matrix.NewVec2(200, 100), matrix.Vec4{}, s)
+	if !ok {
+		t.Fatal("expected the shadow to be planned")
+	}
+	if !plan.quad.Equals(matrix.NewVec2(214, 114)) {
+		t.Errorf("expected the quad to grow by the spread and blur, got %v", plan.quad)
+	}
+	if !plan.offset.Equals(matrix.NewVec3(4, -6, 0)) {
+		t.Errorf("expected a positive Y offset to move the shadow down, got %v", plan.offset)
+	}
+	if plan.shape.width >= 200 || plan.edge.X() == 0 {
+		t.Errorf("expected the texture to be nine sliced, got %d wide with edge %v",
+			plan.shape.width, plan.edge)
+	}
+	if _, ok := outerShadowPlan(matrix.NewVec2(10, 10), matrix.Vec4{},
+		Shadow{Spread: -6, Color: matrix.ColorBlack()}); ok {
+		t.Error("a spread that shrinks the shadow away should not be drawn")
+	}
+}
+
+func TestShadowPixelsBlurTheEdge(t *testing.T) {
+	plan, _ := outerShadowPlan(matrix.NewVec2(100, 100), matrix.Vec4{},
+		Shadow{Blur: 8, Color: matrix.ColorBlack()})
+	w, h := plan.shape.width, plan.shape.height
+	pixels := plan.shape.pixels()
+	if len(pixels) != w*h*4 {
+		t.Fatalf("expected %d bytes, got %d", w*h*4, len(pixels))
+	}
+	if a := shadowAlphaAt(pixels, w, 0, h/2); a != 0 {
+		t.Errorf("expected the outer edge to be clear, got %d", a)
+	}
+	if a := shadowAlphaAt(pixels, w, w/2, h/2); a != 255 {
+		t.Errorf("expected the center to be solid, got %d", a)
+	}
+	edge := shadowAlphaAt(pixels, w, int(plan.shape.rect.X()), h/2)
+	if edge < 100 || edge > 155 {
+		t.Errorf("expected the edge of the shape to be half covered, got %d", edge)
+	}
+}
+
+func TestInsetShadowPixelsStayInsideTheBox(t *testing.T) {
+	plan, ok := insetShadowPlan(matrix.NewVec2(40, 40), matrix.Vec4{},
+		Shadow{Spread: 5, Color: matrix.ColorBlack(), Inset: true})
+	if !ok {
+		t.Fatal("expected the shadow to be planned")
+	}
+	w, h := plan.shape.width, plan.shape.height
+	pixels := plan.shape.pixels()
+	if a := shadowAlphaAt(pixels, w, 1, h/2); a != 255 {
+		t.Errorf("expected the spread to be shadowed, got %d", a)
+	}
+	if a := shadowAlphaAt(pixels, w, w/2, h/2); a != 0 {
+		t.Errorf("expected the middle of the box to be clear, got %d", a)
+	}
+}
+
+func TestRoundedRectCoverageCorners(t *testing.T) {
+	rect := matrix.NewVec4(0, 0, 20, 20)
+	radius := matrix.NewVec4(10, 0, 0, 0)
+	if a := roundedRectCoverage(0.5, 0.5, rect, radius); a != 0 {
+		t.Errorf("expected the rounded top left corner to be clear, got %f", a)
+	}
+	if a := roundedRectCoverage(19.5, 0.5, rect, radius); a != 1 {
+		t.Errorf("expected the square top right corner to be covered, got %f", a)
+	}
+}
+
+func TestShaderDataFiltersColors(t *testing.T) {
+	sd := &ShaderData{ShaderDataBase: rendering.NewShaderDataBase()}
+	sd.FgColor = matrix.NewColor(1, 0, 0, 1)
+	sd.OutlineColor = matrix.NewColor(0, 0, 1, 1)
+	if sd.DataPointer() != sd.ShaderDataBase.DataPointer() {
+		t.Fatal("expected the data to be used directly without a filter")
+	}
+	sd.setColorFilter(matrix.ColorMatrixInvert(1))
+	data := unsafe.Slice((*byte)(sd.DataPointer()), sd.Size())
+	start := uintptr(sd.ShaderDataBase.DataPointer()) - uintptr(unsafe.Pointer(sd))
+	fg := *(*matrix.Color)(unsafe.Pointer(&data[unsafe.Offsetof(sd.FgColor)-start]))
+	if !fg.Equals(matrix.NewColor(0, 1, 1, 1)) {
+		t.Errorf("expected the foreground to be inverted, got %v", fg)
+	}
+	outline := *(*matrix.Color)(unsafe.Pointer(&data[unsafe.Offsetof(sd.OutlineColor)-start]))
+	if !outline.Equals(matrix.NewColor(1, 1, 0, 1)) {
+		t.Errorf("expected the outline to be inverted, got %v", outline)
+	}
+	if !sd.FgColor.Equals(matrix.NewColor(1, 0, 0, 1)) {
+		t.Error("the color of the element should not be changed by the filter")
+	}
+	sd.setColorFilter(matrix.ColorMatrixIdentity())
+	if sd.colorFilter != nil {
+		t.Error("an identity filter should be removed")
+	}
+}
@@ -159,6 +159,7 @@ type UI struct {
 	elmType          ElementType
 	dirtyType        DirtyType
 	shaderData       *ShaderData
+	filter           *Filter
 	textureSize      matrix.Vec2
 	lastClick        float64
 	poolId           pooling.PoolGroupId
@@ -257,6 +258,7 @@ func (ui *UI) init(textureSize matrix.Vec2) {
 		ui.shaderData.Destroy()
 		ui.events[EventTypeDestroy].Execute()
 		ui.elmData = nil
+		ui.filter = nil
 		ui.postLayoutUpdate = nil
 		ui.render = nil
 		ui.layout.ui = nil
@@ -690,6 +692,19 @@ func (ui *UI) GenerateScissor() {
 	ui.setScissor(bounds)
 }
 
+// clipScissor is the scissor of the nearest ancestor that clips its content,
+// it is used for drawings that go past the bounds of the element
+func (ui *UI) clipScissor() matrix.Vec4 {
+	if ui.entity.IsRoot() {
+		return matrix.Vec4{-matrix.FloatMax, -matrix.FloatMax, matrix.FloatMax, matrix.FloatMax}
+	}
+	p := FirstPanelOnEntity(ui.entity.Parent)
+	for p.PanelData().overflow == OverflowVisible && !p.entity.IsRoot() {
+		p = FirstPanelOnEntity(p.entity.Parent)
+	}
+	return p.Base().selfScissor()
+}
+
 func (ui *UI) setScissor(scissor matrix.Vec4) {
 	ui.setScissorInternal(scissor)
 }
@@ -711,6 +726,9 @@ func (ui *UI) setScissorInternal(scissor matrix.Vec4) {
 		for i := range ld.runeDrawings {
 			ld.runeDrawings[i].ShaderData.(*rendering.TextShaderData).Scissor = scissor
 		}
+		for i := range ld.shadowDrawings {
+			ld.shadowDrawings[i].ShaderData.(*rendering.TextShaderData).Scissor = scissor
+		}
 	}
 }
 
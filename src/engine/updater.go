@@ -7,6 +7,7 @@
 package engine
 
 import (
+	"slices"
 	"sync"
 	"sync/atomic"
 
@@ -34,6 +35,8 @@ type Updater struct {
 	backRemove []UpdateId
 	nextId     atomic.Int32
 	lastDelta  float64
+	order      []UpdateId
+	ordered    bool
 }
 
 // IsConcurrent will return if this updater is a concurrent updater
@@ -46,6 +49,15 @@ func NewUpdater() Updater {
 	return Updater{updates: make(map[UpdateId]engineUpdate)}
 }
 
+// NewOrderedUpdater creates a new [Updater] that calls the update functions in
+// the order that they were added. This is slower than an unordered updater but
+// is needed for deterministic simulation, such as the host's FixedUpdater.
+func NewOrderedUpdater() Updater {
+	u := NewUpdater()
+	u.ordered = true
+	return u
+}
+
 // NewConcurrentUpdater creates a new concurrent #Updater struct and returns it
 func NewConcurrentUpdater(threads *concurrent.Threads) Updater {
 	u := NewUpdater()
@@ -101,6 +113,7 @@ func (u *Updater) Update(deltaTime float64) {
 // updates map.
 func (u *Updater) Destroy() {
 	clear(u.updates)
+	u.order = u.order[:0]
 	u.backAdd = make([]engineUpdate, 0)
 	u.backRemove = make([]UpdateId, 0)
 }
@@ -108,6 +121,12 @@ func (u *Updater) Destroy() {
 func (u *UpdateId) IsValid() bool { return *u != 0 }
 
 func (u *Updater) inlineUpdate(deltaTime float64) {
+	if u.ordered {
+		for _, id := range u.order {
+			u.updates[id].update(deltaTime)
+		}
+		return
+	}
 	for i := range u.updates {
 		u.updates[i].update(deltaTime)
 	}
@@ -130,6 +149,9 @@ func (u *Updater) concurrentUpdate(deltaTime float64) {
 func (u *Updater) addInternal() {
 	for _, update := range u.backAdd {
 		u.updates[update.id] = update
+		if u.ordered {
+			u.order = append(u.order, update.id)
+		}
 	}
 	u.backAdd = klib.WipeSlice(u.backAdd)
 }
@@ -137,6 +159,9 @@ func (u *Updater) addInternal() {
 func (u *Updater) removeInternal() {
 	for _, id := range u.backRemove {
 		delete(u.updates, id)
+		if u.ordered {
+			u.order = slices.DeleteFunc(u.order, func(o UpdateId) bool { return o == id })
+		}
 	}
 	u.backRemove = klib.WipeSlice(u.backRemove)
 }
@@ -0,0 +1,114 @@
+/******************************************************************************/
+/* nav_agent_entity_data.go                                                   */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine_entity_data_navigation
+
+import (
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/encoding/pod"
+	"kaijuengine.com/engine/systems/navigation"
+	"kaijuengine.com/matrix"
+)
+
+// NamedDataKey is the key of the [NavAgent] in the named data of the entity
+const NamedDataKey = "NavAgent"
+
+var bindingKey = ""
+
+func init() {
+	engine.RegisterEntityData(NavAgentEntityData{})
+}
+
+func BindingKey() string {
+	if bindingKey == "" {
+		bindingKey = pod.QualifiedNameForLayout(NavAgentEntityData{})
+	}
+	return bindingKey
+}
+
+type NavAgentEntityData struct {
+	Radius           float32 `default:"0.5"`
+	MaxSpeed         float32 `default:"3.5"`
+	MaxAcceleration  float32 `default:"8"`
+	SlowingDistance  float32 `default:"1.5"`
+	ArriveDistance   float32 `default:"0.1"`
+	AvoidanceTime    float32 `default:"2"`
+	NeighborDistance float32 `default:"5"`
+	// TurnSpeed is how fast, in degrees per second, the entity turns to face
+	// the way it is moving, 0 leaves the rotation of the entity alone
+	TurnSpeed float32 `default:"540"`
+}
+
+// NavAgent moves its entity with a [navigation.Agent] in the crowd of the
+// host's [NavSystem]. Give it somewhere to go with SetDestination.
+type NavAgent struct {
+	*navigation.Agent
+	entity *engine.Entity
+	Data   NavAgentEntityData
+}
+
+// AgentFor returns the nav agent that was created for the entity
+func AgentFor(e *engine.Entity) (*NavAgent, bool) {
+	for _, d := range e.NamedData(NamedDataKey) {
+		if a, ok := d.(*NavAgent); ok {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+func (d NavAgentEntityData) Init(e *engine.Entity, host *engine.Host) {
+	sys := System(host)
+	agent := &NavAgent{
+		Agent:  sys.crowd.AddAgent(e.Transform.WorldPosition(), d.agentSettings()),
+		entity: e,
+		Data:   d,
+	}
+	sys.add(agent)
+	e.AddNamedData(NamedDataKey, agent)
+	e.OnDestroy.Add(func() { sys.remove(agent) })
+}
+
+func (d NavAgentEntityData) agentSettings() navigation.AgentSettings {
+	return navigation.AgentSettings{
+		Radius:           matrix.Float(d.Radius),
+		MaxSpeed:         matrix.Float(d.MaxSpeed),
+		MaxAcceleration:  matrix.Float(d.MaxAcceleration),
+		SlowingDistance:  matrix.Float(d.SlowingDistance),
+		ArriveDistance:   matrix.Float(d.ArriveDistance),
+		AvoidanceTime:    matrix.Float(d.AvoidanceTime),
+		NeighborDistance: matrix.Float(d.NeighborDistance),
+	}
+}
+
+// Entity returns the entity that the agent moves
+func (a *NavAgent) Entity() *engine.Entity { return a.entity }
+
+// applyTransform moves the entity to the agent and turns it towards the way
+// the agent is moving
+func (a *NavAgent) applyTransform(deltaTime float64) {
+	t := &a.entity.Transform
+	t.SetWorldPosition(a.Position())
+	if a.Data.TurnSpeed <= 0 {
+		return
+	}
+	v := a.Velocity()
+	if v.X()*v.X()+v.Z()*v.Z() < 0.01 {
+		return
+	}
+	rot := t.WorldRotation()
+	target := matrix.Rad2Deg(matrix.Atan2(v.X(), v.Z()))
+	delta := target - rot.Y()
+	for delta > 180 {
+		delta -= 360
+	}
+	for delta < -180 {
+		delta += 360
+	}
+	step := matrix.Float(a.Data.TurnSpeed) * matrix.Float(deltaTime)
+	rot.SetY(rot.Y() + matrix.Clamp(delta, -step, step))
+	t.SetWorldRotation(rot)
+}
@@ -0,0 +1,77 @@
+/******************************************************************************/
+/* nav_agent_entity_data_test.go                                              */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine_entity_data_navigation
+
+import (
+	"testing"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/matrix"
+)
+
+func testAgentData() NavAgentEntityData {
+	return NavAgentEntityData{
+		Radius:           0.5,
+		MaxSpeed:         3.5,
+		MaxAcceleration:  8,
+		SlowingDistance:  1.5,
+		ArriveDistance:   0.1,
+		AvoidanceTime:    2,
+		NeighborDistance: 5,
+		TurnSpeed:        540,
+	}
+}
+
+func TestNavAgentMovesEntity(t *testing.T) {
+	host := engine.NewHost("test", nil, nil)
+	e := engine.NewEntity(host.WorkGroup())
+	testAgentData().Init(e, host)
+	agent, ok := AgentFor(e)
+	if !ok {
+		t.Fatal("expected the nav agent in the entity's named data")
+	}
+	sys := System(host)
+	if len(sys.Agents()) != 1 || agent.Entity() != e {
+		t.Fatal("expected the agent to be added to the host's nav system")
+	}
+	target := matrix.NewVec3(6, 0, 0)
+	if err := agent.SetDestination(target); err != nil {
+		t.Fatal(err)
+	}
+	for range 60 {
+		sys.update(1.0 / 60.0)
+	}
+	if f := e.Transform.Forward(); f.Dot(matrix.NewVec3(1, 0, 0)) < 0.99 {
+		t.Fatalf("entity should face the way it is moving, forward is %v", f)
+	}
+	for range 5 * 60 {
+		sys.update(1.0 / 60.0)
+	}
+	if d := e.Transform.WorldPosition().Distance(target); d > 0.2 {
+		t.Fatalf("entity should have been moved to the destination, it is %f away", d)
+	}
+	e.ForceCleanup()
+	if len(sys.Agents()) != 0 || len(sys.Crowd().Agents()) != 0 {
+		t.Fatal("expected the agent to be removed once the entity is destroyed")
+	}
+}
+
+func TestNavAgentInactiveEntityDoesNotMove(t *testing.T) {
+	host := engine.NewHost("test", nil, nil)
+	e := engine.NewEntity(host.WorkGroup())
+	testAgentData().Init(e, host)
+	agent, _ := AgentFor(e)
+	agent.SetDestination(matrix.NewVec3(5, 0, 0))
+	e.Deactivate()
+	sys := System(host)
+	for range 60 {
+		sys.update(1.0 / 60.0)
+	}
+	if !e.Transform.WorldPosition().IsZero() {
+		t.Fatalf("an inactive entity should not be moved, it is at %v", e.Transform.WorldPosition())
+	}
+}
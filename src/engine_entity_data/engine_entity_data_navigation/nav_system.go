@@ -0,0 +1,100 @@
+/******************************************************************************/
+/* nav_system.go                                                              */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine_entity_data_navigation
+
+import (
+	"slices"
+	"sync"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/navigation"
+	"kaijuengine.com/platform/profiler/tracing"
+)
+
+var (
+	systems      = map[*engine.Host]*NavSystem{}
+	systemsMutex sync.Mutex
+)
+
+// NavSystem owns the crowd that all of the nav agents of a host belong to.
+// The crowd is stepped once per frame on the host's Updater and then each
+// agent's entity is moved to match its agent.
+type NavSystem struct {
+	crowd    *navigation.Crowd
+	agents   []*NavAgent
+	mutex    sync.Mutex
+	updateId engine.UpdateId
+}
+
+// System returns the navigation system of the host, it is created the first
+// time it is asked for
+func System(host *engine.Host) *NavSystem {
+	systemsMutex.Lock()
+	defer systemsMutex.Unlock()
+	if s, ok := systems[host]; ok {
+		return s
+	}
+	s := &NavSystem{crowd: navigation.NewCrowd(nil)}
+	s.updateId = host.Updater.AddUpdate(s.update)
+	systems[host] = s
+	host.OnClose.Add(func() {
+		systemsMutex.Lock()
+		defer systemsMutex.Unlock()
+		host.Updater.RemoveUpdate(&s.updateId)
+		delete(systems, host)
+	})
+	return s
+}
+
+// Crowd returns the crowd that the agents are in, it can be used to add
+// obstacles for the agents to avoid
+func (s *NavSystem) Crowd() *navigation.Crowd { return s.crowd }
+
+// SetPathFinder sets what the agents use to find their paths, usually the
+// [navigation.NavMesh] of the stage or a [navigation.GridPathFinder]
+func (s *NavSystem) SetPathFinder(finder navigation.PathFinder) {
+	s.crowd.SetPathFinder(finder)
+}
+
+// Agents returns the nav agents of the host
+func (s *NavSystem) Agents() []*NavAgent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.agents)
+}
+
+func (s *NavSystem) add(agent *NavAgent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.agents = append(s.agents, agent)
+}
+
+func (s *NavSystem) remove(agent *NavAgent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if idx := slices.Index(s.agents, agent); idx >= 0 {
+		s.agents = slices.Delete(s.agents, idx, idx+1)
+	}
+	s.crowd.RemoveAgent(agent.Agent)
+}
+
+func (s *NavSystem) update(deltaTime float64) {
+	defer tracing.NewRegion("NavSystem.update").End()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// Destroyed entities are removed from the crowd once they are cleaned up,
+	// until then they are left out the same as inactive entities
+	for _, a := range s.agents {
+		a.SetActive(a.entity.IsActive() && !a.entity.IsDestroyed())
+	}
+	s.crowd.Update(deltaTime)
+	for _, a := range s.agents {
+		if a.entity.IsActive() && !a.entity.IsDestroyed() {
+			a.applyTransform(deltaTime)
+		}
+	}
+}
@@ -8,11 +8,14 @@ package engine_entity_data_skin_animation
 
 import (
 	"log/slog"
+	"slices"
 	"strings"
 	"weak"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/engine/encoding/pod"
+	"kaijuengine.com/engine/graviton"
+	"kaijuengine.com/engine/systems/events"
 	"kaijuengine.com/engine_entity_data/content_id"
 	"kaijuengine.com/framework"
 	"kaijuengine.com/klib"
@@ -24,7 +27,21 @@ import (
 
 var bindingKey = ""
 
+// RootMotion is what the motion of the root bone of the skeleton is applied
+// to, anything other than none takes the motion out of the skeleton itself
+type RootMotion int
+
+const (
+	RootMotionNone RootMotion = iota
+	RootMotionEntity
+	// RootMotionRigidBody moves the rigid body of the entity, dynamic bodies
+	// are given the velocity of the motion so they still collide and fall.
+	// The entity is moved instead if it doesn't have a rigid body.
+	RootMotionRigidBody
+)
+
 func init() {
+	pod.Register(RootMotion(0))
 	engine.RegisterEntityData(SkinAnimationEntityData{})
 }
 
@@ -38,9 +55,15 @@ func BindingKey() string {
 type SkinAnimationEntityData struct {
 	MeshId   content_id.Mesh
 	AnimName string `options:"animations"`
+	// GraphId is the animation graph that drives the skin, when it is set the
+	// AnimName is not used
+	GraphId    content_id.AnimationGraph
+	RootMotion RootMotion
 }
 
 type MeshSkinningAnimation struct {
+	// OnEvent is called for the events of the animations as they play
+	OnEvent        events.EventWithArg[framework.SkinAnimationEvent]
 	frame          int
 	animIdx        int
 	anims          []kaiju_mesh.KaijuMeshAnimation
@@ -50,9 +73,23 @@ type MeshSkinningAnimation struct {
 	skin           weak.Pointer[rendering.SkinnedShaderDataHeader]
 	shaderDataBase weak.Pointer[rendering.ShaderDataBase]
 	current        framework.SkinAnimation
+	graph          *framework.AnimationGraph
+	clipEvents     map[string][]framework.SkinAnimationEvent
+	host           weak.Pointer[engine.Host]
+	rootMotion     RootMotion
 	isPlaying      bool
 }
 
+// AnimationFor returns the skin animation that was created for the entity
+func AnimationFor(e *engine.Entity) (*MeshSkinningAnimation, bool) {
+	for _, d := range e.NamedData(BindingKey()) {
+		if a, ok := d.(*MeshSkinningAnimation); ok {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
 func (c SkinAnimationEntityData) Init(e *engine.Entity, host *engine.Host) {
 	km, err := kaiju_mesh.ReadMesh(string(c.MeshId), host)
 	if err != nil {
@@ -66,8 +103,31 @@ func (c SkinAnimationEntityData) Init(e *engine.Entity, host *engine.Host) {
 		entity:         weak.Make(e),
 		skin:           weak.Make(sd.SkinningHeader()),
 		shaderDataBase: weak.Make(sd.Base()),
+		host:           weak.Make(host),
+		rootMotion:     c.RootMotion,
+	}
+	if c.GraphId != "" {
+		anim.graph, err = loadGraph(host, string(c.GraphId), km)
+		if err != nil {
+			slog.Error("failed to load the animation graph, falling back to the animation name",
+				"id", c.GraphId, "error", err)
+		}
+	}
+	if anim.graph == nil && c.RootMotion != RootMotionNone {
+		// Root motion is taken out of the skeleton by the graph, so a single
+		// animation is played through a graph of its own
+		anim.graph, err = singleAnimationGraph(c.AnimName, km)
+		if err != nil {
+			slog.Error("failed to setup root motion for the animation", "name", c.AnimName, "error", err)
+		}
+	}
+	if anim.graph != nil {
+		anim.graph.SetRootMotion(c.RootMotion != RootMotionNone)
+		anim.graph.OnEvent.Add(anim.OnEvent.Execute)
+		anim.isPlaying = true
+	} else {
+		anim.SetAnimation(c.AnimName)
 	}
-	anim.SetAnimation(c.AnimName)
 	wh := weak.Make(host)
 	e.OnDestroy.Add(func() {
 		h := wh.Value()
@@ -75,11 +135,52 @@ func (c SkinAnimationEntityData) Init(e *engine.Entity, host *engine.Host) {
 			h.Updater.RemoveUpdate(&anim.updateId)
 		}
 	})
-	e.AddNamedData(bindingKey, anim)
+	e.AddNamedData(BindingKey(), anim)
 	// The shader data hasn't been assigned yet, wait until the next frame to setup
 	host.RunNextFrame(func() { anim.setup(host) })
 }
 
+func loadGraph(host *engine.Host, id string, km kaiju_mesh.KaijuMesh) (*framework.AnimationGraph, error) {
+	spec, err := framework.LoadAnimationGraphSpec(host, id)
+	if err != nil {
+		return nil, err
+	}
+	return framework.NewAnimationGraph(spec, km.Animations, km.Joints)
+}
+
+func singleAnimationGraph(name string, km kaiju_mesh.KaijuMesh) (*framework.AnimationGraph, error) {
+	if name == "" && len(km.Animations) > 0 {
+		name = km.Animations[0].Name
+	}
+	spec := framework.AnimationGraphSpec{
+		Layers: []framework.AnimationLayerSpec{{
+			States: []framework.AnimationStateSpec{{Name: name, Clip: name, Loop: true}},
+		}},
+	}
+	return framework.NewAnimationGraph(spec, km.Animations, km.Joints)
+}
+
+// AddEvent adds an event to the named animation that fires OnEvent when the
+// animation plays past the time, in seconds from the start of the animation
+func (a *MeshSkinningAnimation) AddEvent(animName, name string, time float32) {
+	if a.graph != nil {
+		a.graph.AddEvent(animName, name, time)
+		return
+	}
+	if a.clipEvents == nil {
+		a.clipEvents = make(map[string][]framework.SkinAnimationEvent)
+	}
+	key := strings.ToLower(animName)
+	a.clipEvents[key] = append(a.clipEvents[key], framework.SkinAnimationEvent{Name: name, Time: time})
+	if strings.EqualFold(a.current.Animation.Name, animName) {
+		a.current.AddEvent(name, time)
+	}
+}
+
+// Graph returns the animation graph that drives the skin, parameters of the
+// graph are set through it. It is nil when the skin plays a single animation.
+func (a *MeshSkinningAnimation) Graph() *framework.AnimationGraph { return a.graph }
+
 func (a *MeshSkinningAnimation) SetAnimation(name string) {
 	for i := range a.anims {
 		if strings.EqualFold(a.anims[i].Name, name) {
@@ -87,6 +188,8 @@ func (a *MeshSkinningAnimation) SetAnimation(name string) {
 		}
 	}
 	a.current = framework.NewSkinAnimation(a.anims[a.animIdx])
+	a.current.Events = slices.Clone(a.clipEvents[strings.ToLower(a.current.Animation.Name)])
+	a.current.OnEvent.Add(a.OnEvent.Execute)
 	a.isPlaying = true
 }
 
@@ -95,7 +198,7 @@ func (a *MeshSkinningAnimation) setup(host *engine.Host) {
 	sd := e.ShaderData()
 	skin := sd.SkinningHeader()
 	if skin == nil {
-		e.RemoveNamedData(bindingKey, a)
+		e.RemoveNamedData(BindingKey(), a)
 		slog.Error("failed to find skinning shader data on entity for MeshSkinningAnimation", "entity", e.Id())
 		return
 	}
@@ -136,10 +239,21 @@ func (a *MeshSkinningAnimation) update(deltaTime float64) {
 	}
 	sd := a.shaderDataBase.Value()
 	skin := a.skin.Value()
+	// The animation keeps moving while out of view so that its transitions
+	// and events carry on, only the writing of the pose is skipped
+	if a.graph != nil {
+		a.graph.Update(deltaTime)
+		a.applyRootMotion(deltaTime)
+	} else {
+		a.current.Update(deltaTime)
+	}
 	if skin == nil || (sd != nil && !sd.IsInView()) {
 		return
 	}
-	a.current.Update(deltaTime)
+	if a.graph != nil {
+		a.applyPose(skin)
+		return
+	}
 	frame := a.current.CurrentFrame()
 	for i := range frame.Key.Bones {
 		frame.Bone = &frame.Key.Bones[i]
@@ -163,3 +277,64 @@ func (a *MeshSkinningAnimation) update(deltaTime float64) {
 		}
 	}
 }
+
+func (a *MeshSkinningAnimation) applyPose(skin *rendering.SkinnedShaderDataHeader) {
+	pose := a.graph.Pose()
+	skeleton := a.graph.Skeleton()
+	for i := range pose {
+		bone := skin.FindBone(skeleton.Joint(i).Id)
+		if bone == nil {
+			continue
+		}
+		bone.Transform.SetLocalPosition(pose[i].Position)
+		bone.Transform.SetRotation(pose[i].Rotation.ToEuler())
+		bone.Transform.SetScale(pose[i].Scale)
+	}
+}
+
+// applyRootMotion moves the entity, or its rigid body, by the motion that the
+// graph took out of the root bone during its last update
+func (a *MeshSkinningAnimation) applyRootMotion(deltaTime float64) {
+	if a.rootMotion == RootMotionNone {
+		return
+	}
+	e := a.entity.Value()
+	if e == nil {
+		return
+	}
+	move, yaw := a.graph.RootMotion()
+	t := &e.Transform
+	rot := t.WorldRotation()
+	move = matrix.QuaternionFromEuler(rot).MultiplyVec3(move.Multiply(t.WorldScale()))
+	if a.rootMotion == RootMotionRigidBody {
+		if h := a.host.Value(); h != nil {
+			if body, ok := h.Physics().RigidBody(e); ok {
+				moveRigidBody(body, move, yaw, deltaTime)
+				return
+			}
+		}
+	}
+	t.SetWorldPosition(t.WorldPosition().Add(move))
+	rot.SetY(rot.Y() + yaw)
+	t.SetWorldRotation(rot)
+}
+
+func moveRigidBody(body *graviton.RigidBody, move matrix.Vec3, yaw matrix.Float, deltaTime float64) {
+	if body.IsDynamic() {
+		if deltaTime <= 0 {
+			return
+		}
+		// The vertical velocity is left to the simulation so the body still
+		// falls and lands while it is being walked around
+		inv := matrix.Float(1 / deltaTime)
+		ms := &body.MotionState
+		ms.LinearVelocity = matrix.NewVec3(move.X()*inv, ms.LinearVelocity.Y(), move.Z()*inv)
+		ms.AngularVelocity.SetY(matrix.Deg2Rad(yaw) * inv)
+		body.Wake()
+		return
+	}
+	body.Transform.AddPosition(move)
+	rot := body.Transform.Rotation()
+	rot.SetY(rot.Y() + yaw)
+	body.Transform.SetRotation(rot)
+}
@@ -0,0 +1,101 @@
+/******************************************************************************/
+/* skin_animation_entity_data_test.go                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package engine_entity_data_skin_animation
+
+import (
+	"testing"
+	"weak"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/graviton"
+	"kaijuengine.com/framework"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/rendering/loaders/kaiju_mesh"
+	"kaijuengine.com/rendering/loaders/load_result"
+)
+
+// walkingMesh has a single animation that walks the root 2 forward each
+// second
+func walkingMesh() kaiju_mesh.KaijuMesh {
+	walk := kaiju_mesh.KaijuMeshAnimation{Name: "walk"}
+	for i := range 2 {
+		walk.Frames = append(walk.Frames, kaiju_mesh.AnimKeyFrame{
+			Time: matrix.Float(1 - i),
+			Bones: []kaiju_mesh.AnimBone{{
+				NodeIndex: 0,
+				PathType:  load_result.AnimPathTranslation,
+				Data:      [4]matrix.Float{0, 0, matrix.Float(i) * 2, 1},
+			}},
+		})
+	}
+	return kaiju_mesh.KaijuMesh{
+		Animations: []kaiju_mesh.KaijuMeshAnimation{walk},
+		Joints:     []kaiju_mesh.KaijuMeshJoint{{Id: 0, Parent: -1, Scale: matrix.Vec3One()}},
+	}
+}
+
+func TestRootMotionMovesEntity(t *testing.T) {
+	host := engine.NewHost("test", nil, nil)
+	e := engine.NewEntity(host.WorkGroup())
+	e.Transform.SetRotation(matrix.NewVec3(0, 90, 0))
+	graph, err := singleAnimationGraph("", walkingMesh())
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph.SetRootMotion(true)
+	a := &MeshSkinningAnimation{
+		entity:     weak.Make(e),
+		host:       weak.Make(host),
+		graph:      graph,
+		rootMotion: RootMotionEntity,
+	}
+	graph.Update(0.5)
+	a.applyRootMotion(0.5)
+	// The entity faces +X so walking forward moves it along X
+	if p := e.Transform.WorldPosition(); !matrix.Vec3ApproxTo(p, matrix.NewVec3(1, 0, 0), 0.001) {
+		t.Fatalf("expected the entity to walk to (1, 0, 0), it is at %v", p)
+	}
+}
+
+func TestRootMotionMovesRigidBody(t *testing.T) {
+	kinematic := &graviton.RigidBody{}
+	kinematic.Transform.SetupRawTransform()
+	kinematic.SetKinematic()
+	moveRigidBody(kinematic, matrix.NewVec3(1, 0, 0), 90, 0.5)
+	if p := kinematic.Transform.Position(); !matrix.Vec3ApproxTo(p, matrix.NewVec3(1, 0, 0), 0.001) {
+		t.Fatalf("expected the kinematic body to be moved, it is at %v", p)
+	}
+	if r := kinematic.Transform.Rotation(); !matrix.ApproxTo(r.Y(), 90, 0.001) {
+		t.Fatalf("expected the kinematic body to be turned, its rotation is %v", r)
+	}
+	dynamic := &graviton.RigidBody{}
+	dynamic.Transform.SetupRawTransform()
+	dynamic.SetDynamic(1, matrix.Vec3One())
+	dynamic.MotionState.LinearVelocity = matrix.NewVec3(0, -3, 0)
+	moveRigidBody(dynamic, matrix.NewVec3(1, 0, 0), 90, 0.5)
+	if v := dynamic.MotionState.LinearVelocity; !matrix.Vec3ApproxTo(v, matrix.NewVec3(2, -3, 0), 0.001) {
+		t.Fatalf("expected the dynamic body to keep falling while it walks, velocity %v", v)
+	}
+	if w := dynamic.MotionState.AngularVelocity.Y(); !matrix.ApproxTo(w, matrix.Deg2Rad(matrix.Float(180)), 0.001) {
+		t.Fatalf("expected the dynamic body to turn at 180 degrees per second, got %f radians", w)
+	}
+}
+
+func TestClipEventsFireWithoutPose(t *testing.T) {
+	a := &MeshSkinningAnimation{anims: walkingMesh().Animations}
+	a.AddEvent("walk", "step", 0.25)
+	a.SetAnimation("walk")
+	fired := false
+	a.OnEvent.Add(func(e framework.SkinAnimationEvent) { fired = e.Name == "step" })
+	// There is no skin to pose, as when the mesh is out of view. The first
+	// update only settles on the starting frame.
+	a.update(0)
+	a.update(0.5)
+	if !fired {
+		t.Error("the clip should advance and fire its events when its pose isn't written")
+	}
+}
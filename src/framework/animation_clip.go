@@ -0,0 +1,390 @@
+/******************************************************************************/
+/* animation_clip.go                                                          */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package framework
+
+import (
+	"math"
+	"sort"
+
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/rendering/loaders/kaiju_mesh"
+	"kaijuengine.com/rendering/loaders/load_result"
+)
+
+// BoneTransform is the local transform of a single bone in a pose
+type BoneTransform struct {
+	Position matrix.Vec3
+	Rotation matrix.Quaternion
+	Scale    matrix.Vec3
+}
+
+// AnimationPose holds a local transform for each joint of a skeleton, in the
+// same order as the joints of the skeleton
+type AnimationPose []BoneTransform
+
+// AnimationSkeleton is the set of joints that poses are built for
+type AnimationSkeleton struct {
+	joints  []kaiju_mesh.KaijuMeshJoint
+	indexes map[int32]int
+	bind    AnimationPose
+	root    int
+}
+
+type animationKey struct {
+	time float64
+	data [4]matrix.Float
+}
+
+type animationTrack struct {
+	joint int
+	path  kaiju_mesh.AnimationPathType
+	keys  []animationKey
+}
+
+// AnimationClip is a [kaiju_mesh.KaijuMeshAnimation] split into a track for
+// each animated bone property so that it can be sampled at any time, rather
+// than stepped through frame by frame like [SkinAnimation]
+type AnimationClip struct {
+	Name      string
+	Events    []SkinAnimationEvent
+	duration  float64
+	tracks    []animationTrack
+	reference AnimationPose
+	root      int
+	bindYaw   matrix.Float
+}
+
+func NewAnimationSkeleton(joints []kaiju_mesh.KaijuMeshJoint) *AnimationSkeleton {
+	s := &AnimationSkeleton{
+		joints:  joints,
+		indexes: make(map[int32]int, len(joints)),
+		bind:    make(AnimationPose, len(joints)),
+		root:    -1,
+	}
+	for i := range joints {
+		s.indexes[joints[i].Id] = i
+		s.bind[i] = BoneTransform{
+			Position: joints[i].Position,
+			Rotation: matrix.QuaternionFromEuler(joints[i].Rotation),
+			Scale:    joints[i].Scale,
+		}
+	}
+	for i := range joints {
+		if _, ok := s.indexes[joints[i].Parent]; !ok {
+			s.root = i
+			break
+		}
+	}
+	return s
+}
+
+func (s *AnimationSkeleton) JointCount() int { return len(s.joints) }
+
+func (s *AnimationSkeleton) Joint(index int) kaiju_mesh.KaijuMeshJoint { return s.joints[index] }
+
+// IndexOf returns the index of the joint with the given id
+func (s *AnimationSkeleton) IndexOf(id int32) (int, bool) {
+	idx, ok := s.indexes[id]
+	return idx, ok
+}
+
+// Root returns the index of the first joint without a parent, or -1 if the
+// skeleton has no joints
+func (s *AnimationSkeleton) Root() int { return s.root }
+
+// BindPose returns a copy of the pose the skeleton is in when nothing is
+// animating it
+func (s *AnimationSkeleton) BindPose() AnimationPose {
+	return append(AnimationPose(nil), s.bind...)
+}
+
+// NewAnimationClip builds the tracks of the animation for the skeleton, bones
+// of the animation that are not in the skeleton are skipped
+func NewAnimationClip(anim kaiju_mesh.KaijuMeshAnimation, skeleton *AnimationSkeleton) *AnimationClip {
+	c := &AnimationClip{Name: anim.Name, root: skeleton.Root()}
+	lookup := map[[2]int]int{}
+	for i := range anim.Frames {
+		frame := &anim.Frames[i]
+		for j := range frame.Bones {
+			b := &frame.Bones[j]
+			joint, ok := skeleton.IndexOf(int32(b.NodeIndex))
+			if !ok {
+				continue
+			}
+			switch b.PathType {
+			case load_result.AnimPathTranslation, load_result.AnimPathRotation,
+				load_result.AnimPathScale:
+			default:
+				continue
+			}
+			key := [2]int{joint, int(b.PathType)}
+			t, ok := lookup[key]
+			if !ok {
+				t = len(c.tracks)
+				lookup[key] = t
+				c.tracks = append(c.tracks, animationTrack{joint: joint, path: b.PathType})
+			}
+			c.tracks[t].keys = append(c.tracks[t].keys, animationKey{c.duration, b.Data})
+		}
+		c.duration += float64(frame.Time)
+	}
+	c.reference = skeleton.BindPose()
+	c.Sample(0, false, c.reference)
+	if c.root >= 0 {
+		c.bindYaw = quaternionYaw(skeleton.bind[c.root].Rotation)
+	}
+	return c
+}
+
+// Duration returns the length of the clip in seconds
+func (c *AnimationClip) Duration() float64 { return c.duration }
+
+// Sample writes the bones that the clip animates at the given time into the
+// pose, the other bones of the pose are left as they are. When looping, the
+// last key blends back into the first one at the end of the clip.
+func (c *AnimationClip) Sample(time float64, loop bool, pose AnimationPose) {
+	if c.duration > 0 {
+		if loop {
+			time = math.Mod(time, c.duration)
+			if time < 0 {
+				time += c.duration
+			}
+		} else {
+			time = min(max(time, 0), c.duration)
+		}
+	}
+	for i := range c.tracks {
+		t := &c.tracks[i]
+		data := t.sample(time, c.duration, loop)
+		bone := &pose[t.joint]
+		switch t.path {
+		case load_result.AnimPathTranslation:
+			bone.Position = matrix.Vec3FromSlice(data[:])
+		case load_result.AnimPathRotation:
+			bone.Rotation = matrix.Quaternion(data)
+		case load_result.AnimPathScale:
+			bone.Scale = matrix.Vec3FromSlice(data[:])
+		}
+	}
+}
+
+// sampleAdditive writes the difference between the clip at the given time
+// and its first frame into the pose, for every bone of the pose
+func (c *AnimationClip) sampleAdditive(time float64, loop bool, pose AnimationPose) {
+	copy(pose, c.reference)
+	c.Sample(time, loop, pose)
+	for i := range pose {
+		pose[i] = boneDelta(c.reference[i], pose[i])
+	}
+}
+
+// AddEvent adds an event that fires when the clip plays past the time, in
+// seconds from the start of the clip
+func (c *AnimationClip) AddEvent(name string, time float32) {
+	c.Events = append(c.Events, SkinAnimationEvent{Name: name, Time: time})
+}
+
+// rootAt returns the position and the yaw, in radians, of the root bone at
+// the time without looping back to the first frame
+func (c *AnimationClip) rootAt(time float64) (matrix.Vec3, matrix.Float) {
+	bone := c.reference[c.root]
+	for i := range c.tracks {
+		t := &c.tracks[i]
+		if t.joint != c.root {
+			continue
+		}
+		data := t.sample(time, c.duration, false)
+		switch t.path {
+		case load_result.AnimPathTranslation:
+			bone.Position = matrix.Vec3FromSlice(data[:])
+		case load_result.AnimPathRotation:
+			bone.Rotation = matrix.Quaternion(data)
+		}
+	}
+	return bone.Position, quaternionYaw(bone.Rotation)
+}
+
+// rootMotion returns how far the root bone moves horizontally and how much
+// it turns, in radians, between the two times. The movement is relative to
+// the way the root was facing at the start time, turned to the way it faces
+// in the bind pose since that is the way it is left facing once its motion is
+// taken out of the pose. A looping clip moves by the difference between its
+// last and first frames each time it loops.
+func (c *AnimationClip) rootMotion(from, to float64, loop bool) (matrix.Vec3, matrix.Float) {
+	var move matrix.Vec3
+	yaw := matrix.Float(0)
+	if c.root < 0 || c.duration <= 0 || to <= from {
+		return move, yaw
+	}
+	segment := func(a, b float64) {
+		pa, ya := c.rootAt(a)
+		pb, yb := c.rootAt(b)
+		d := pb.Subtract(pa)
+		d.SetY(0)
+		move.AddAssign(rotateYaw(d, c.bindYaw+yaw-ya))
+		yaw += wrapAngle(yb - ya)
+	}
+	if !loop {
+		segment(min(from, c.duration), min(to, c.duration))
+		return move, yaw
+	}
+	// Large steps are limited to a few loops, there is no point in walking
+	// through hundreds of them for a single frame
+	to = min(to, from+c.duration*4)
+	for from < to {
+		cycle := math.Floor(from/c.duration) * c.duration
+		end := min(to, cycle+c.duration)
+		segment(from-cycle, end-cycle)
+		from = end
+	}
+	return move, yaw
+}
+
+func (t *animationTrack) sample(time, duration float64, loop bool) [4]matrix.Float {
+	keys := t.keys
+	next := sort.Search(len(keys), func(i int) bool { return keys[i].time > time })
+	from, to := next-1, next
+	fromTime, toTime := 0.0, 0.0
+	switch {
+	case len(keys) == 1:
+		return keys[0].data
+	case from < 0:
+		if !loop {
+			return keys[0].data
+		}
+		from = len(keys) - 1
+		fromTime, toTime = keys[from].time-duration, keys[to].time
+	case to == len(keys):
+		if !loop {
+			return keys[from].data
+		}
+		to = 0
+		fromTime, toTime = keys[from].time, keys[to].time+duration
+	default:
+		fromTime, toTime = keys[from].time, keys[to].time
+	}
+	if toTime <= fromTime {
+		return keys[from].data
+	}
+	factor := matrix.Float((time - fromTime) / (toTime - fromTime))
+	a, b := keys[from].data, keys[to].data
+	if t.path == load_result.AnimPathRotation {
+		return matrix.QuaternionSlerp(matrix.Quaternion(a), matrix.Quaternion(b), factor)
+	}
+	p := matrix.Vec3Lerp(matrix.Vec3FromSlice(a[:]), matrix.Vec3FromSlice(b[:]), factor)
+	return [4]matrix.Float{p.X(), p.Y(), p.Z(), 1}
+}
+
+// quaternionYaw returns the rotation about the up axis, in radians
+func quaternionYaw(q matrix.Quaternion) matrix.Float {
+	return matrix.Atan2(2*(q.W()*q.Y()+q.X()*q.Z()), 1-2*(q.X()*q.X()+q.Y()*q.Y()))
+}
+
+func rotateYaw(v matrix.Vec3, yaw matrix.Float) matrix.Vec3 {
+	return matrix.QuaternionAxisAngle(matrix.Vec3Up(), yaw).MultiplyVec3(v)
+}
+
+func wrapAngle(a matrix.Float) matrix.Float {
+	for a > math.Pi {
+		a -= 2 * math.Pi
+	}
+	for a < -math.Pi {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+// boneDelta returns the change from the reference to the bone, adding it back
+// onto the reference with [boneAdd] gives the bone
+func boneDelta(reference, bone BoneTransform) BoneTransform {
+	inv := reference.Rotation
+	inv.Inverse()
+	d := BoneTransform{
+		Position: bone.Position.Subtract(reference.Position),
+		Rotation: inv.Multiply(bone.Rotation),
+		Scale:    matrix.Vec3One(),
+	}
+	for i := range d.Scale {
+		if !matrix.Approx(reference.Scale[i], 0) {
+			d.Scale[i] = bone.Scale[i] / reference.Scale[i]
+		}
+	}
+	return d
+}
+
+// boneAdd applies the weighted delta from [boneDelta] onto the bone
+func boneAdd(bone, delta BoneTransform, weight matrix.Float) BoneTransform {
+	rot := matrix.QuaternionSlerp(matrix.QuaternionIdentity(), delta.Rotation, weight)
+	scale := matrix.Vec3Lerp(matrix.Vec3One(), delta.Scale, weight)
+	return BoneTransform{
+		Position: bone.Position.Add(delta.Position.Scale(weight)),
+		Rotation: bone.Rotation.Multiply(rot),
+		Scale:    bone.Scale.Multiply(scale),
+	}
+}
+
+func boneLerp(from, to BoneTransform, weight matrix.Float) BoneTransform {
+	return BoneTransform{
+		Position: matrix.Vec3Lerp(from.Position, to.Position, weight),
+		Rotation: matrix.QuaternionSlerp(from.Rotation, to.Rotation, weight),
+		Scale:    matrix.Vec3Lerp(from.Scale, to.Scale, weight),
+	}
+}
+
+// poseBlender builds the weighted average of any number of poses
+type poseBlender struct {
+	pose  AnimationPose
+	total matrix.Float
+}
+
+func (b *poseBlender) reset(size int) {
+	if cap(b.pose) < size {
+		b.pose = make(AnimationPose, size)
+	}
+	b.pose = b.pose[:size]
+	clear(b.pose)
+	b.total = 0
+}
+
+func (b *poseBlender) add(pose AnimationPose, weight matrix.Float) {
+	if weight <= 0 {
+		return
+	}
+	b.total += weight
+	for i := range b.pose {
+		acc, src := &b.pose[i], &pose[i]
+		acc.Position.AddAssign(src.Position.Scale(weight))
+		acc.Scale.AddAssign(src.Scale.Scale(weight))
+		// Keep the rotations in the same hemisphere so they don't cancel out
+		q := src.Rotation
+		if acc.Rotation[0]*q[0]+acc.Rotation[1]*q[1]+acc.Rotation[2]*q[2]+acc.Rotation[3]*q[3] < 0 {
+			weight = -weight
+		}
+		for j := range q {
+			acc.Rotation[j] += q[j] * weight
+		}
+		weight = matrix.Abs(weight)
+	}
+}
+
+// finish writes the blended pose into the output
+func (b *poseBlender) finish(out AnimationPose) {
+	if b.total <= 0 {
+		return
+	}
+	inv := 1 / b.total
+	for i := range b.pose {
+		acc := &b.pose[i]
+		out[i].Position = acc.Position.Scale(inv)
+		out[i].Scale = acc.Scale.Scale(inv)
+		if acc.Rotation.IsZero() {
+			out[i].Rotation = matrix.QuaternionIdentity()
+		} else {
+			out[i].Rotation = acc.Rotation.Normal()
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/******************************************************************************/
+/* animation_event.go                                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package framework
+
+import "math"
+
+// SkinAnimationEvent is a named moment of an animation clip, like a foot
+// hitting the ground or the frame a sword lands, that fires each time the
+// playback of the clip passes its time
+type SkinAnimationEvent struct {
+	Name string
+	// Time is the number of seconds from the start of the clip
+	Time float32
+}
+
+// eachAnimationEvent calls the function for every event with a time from
+// the start time up to, but not including, the end time. When looping, times
+// past the duration wrap back around to the start of the clip, though each
+// event is only called once no matter how many loops the times cover.
+func eachAnimationEvent(events []SkinAnimationEvent, from, to, duration float64, loop bool, call func(SkinAnimationEvent)) {
+	if len(events) == 0 || to <= from {
+		return
+	}
+	if !loop || duration <= 0 {
+		for _, e := range events {
+			if t := float64(e.Time); t >= from && t < to {
+				call(e)
+			}
+		}
+		return
+	}
+	if to-from >= duration {
+		for _, e := range events {
+			call(e)
+		}
+		return
+	}
+	cycle := math.Floor(from/duration) * duration
+	from, to = from-cycle, to-cycle
+	for _, e := range events {
+		t := float64(e.Time)
+		if (t >= from && t < to) || (t+duration >= from && t+duration < to) {
+			call(e)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/******************************************************************************/
+/* animation_event_test.go                                                    */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package framework
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSkinAnimationEvents(t *testing.T) {
+	anim := NewSkinAnimation(testAnim("walk", 0, 1, 0))
+	anim.AddEvent("left", 0.5)
+	anim.AddEvent("right", 1.5)
+	fired := []string{}
+	anim.OnEvent.Add(func(e SkinAnimationEvent) { fired = append(fired, e.Name) })
+	anim.Update(0)
+	for range 6 {
+		anim.Update(0.5)
+	}
+	if want := []string{"left", "right", "left"}; !slices.Equal(fired, want) {
+		t.Fatalf("expected the events %v, got %v", want, fired)
+	}
+}
+
+func TestAnimationEventsWrap(t *testing.T) {
+	events := []SkinAnimationEvent{{Name: "a", Time: 0}, {Name: "b", Time: 1}}
+	collect := func(from, to float64, loop bool) []string {
+		names := []string{}
+		eachAnimationEvent(events, from, to, 2, loop, func(e SkinAnimationEvent) {
+			names = append(names, e.Name)
+		})
+		return names
+	}
+	if got := collect(1.5, 2.5, true); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("expected the first event after looping, got %v", got)
+	}
+	if got := collect(5, 5.5, true); !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("expected the second event on a later loop, got %v", got)
+	}
+	if got := collect(1.5, 2.5, false); len(got) != 0 {
+		t.Fatalf("events should not wrap without looping, got %v", got)
+	}
+	if got := collect(0, 10, true); len(got) != 2 {
+		t.Fatalf("each event should fire once for a long step, got %v", got)
+	}
+}
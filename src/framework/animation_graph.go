@@ -0,0 +1,142 @@
+/******************************************************************************/
+/* animation_graph.go                                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package framework
+
+import (
+	"encoding/json"
+
+	"kaijuengine.com/engine"
+)
+
+type AnimationParameterType string
+type AnimationLayerBlend string
+type AnimationConditionOp string
+
+const (
+	AnimationParameterFloat   = AnimationParameterType("float")
+	AnimationParameterBool    = AnimationParameterType("bool")
+	AnimationParameterTrigger = AnimationParameterType("trigger")
+)
+
+const (
+	AnimationLayerOverride = AnimationLayerBlend("override")
+	AnimationLayerAdditive = AnimationLayerBlend("additive")
+)
+
+const (
+	AnimationConditionEqual          = AnimationConditionOp("==")
+	AnimationConditionNotEqual       = AnimationConditionOp("!=")
+	AnimationConditionGreater        = AnimationConditionOp(">")
+	AnimationConditionLess           = AnimationConditionOp("<")
+	AnimationConditionGreaterOrEqual = AnimationConditionOp(">=")
+	AnimationConditionLessOrEqual    = AnimationConditionOp("<=")
+)
+
+// AnimationAnyState can be used as the from state of a transition so that it
+// can be taken from any state of the layer
+const AnimationAnyState = "*"
+
+// AnimationGraphSpec is the description of an animation graph as it is saved
+// in a ".animgraph" content file. The graph is played with [AnimationGraph].
+type AnimationGraphSpec struct {
+	Parameters []AnimationParameterSpec
+	// Clips holds the events of the clips that the graph plays, clips without
+	// events don't need to be listed
+	Clips []AnimationClipSpec
+	// Layers are blended on top of each other in order, the first layer is the
+	// base pose and is always fully applied to every bone
+	Layers []AnimationLayerSpec
+}
+
+type AnimationParameterSpec struct {
+	Name string
+	Type AnimationParameterType
+	// Default is the starting value of the parameter, bools use 0 and 1
+	Default float32
+}
+
+type AnimationClipSpec struct {
+	Name   string
+	Events []SkinAnimationEvent
+}
+
+type AnimationLayerSpec struct {
+	Name   string
+	Blend  AnimationLayerBlend
+	Weight float32
+	// Mask limits the bones that the layer changes, leave it empty for the
+	// layer to change every bone
+	Mask         []AnimationBoneMaskSpec
+	DefaultState string
+	States       []AnimationStateSpec
+	Transitions  []AnimationTransitionSpec
+}
+
+// AnimationBoneMaskSpec sets how much a layer changes a bone. The children
+// of the bone use the same weight unless they are in the mask themselves.
+type AnimationBoneMaskSpec struct {
+	Bone   int32
+	Weight float32
+}
+
+type AnimationStateSpec struct {
+	Name string
+	// Clip is the name of the animation in the mesh, it is not used when the
+	// state has a blend space
+	Clip string
+	// Speed scales how fast the state plays, 0 plays at the normal speed
+	Speed      float32
+	Loop       bool
+	BlendSpace *AnimationBlendSpaceSpec
+}
+
+// AnimationBlendSpaceSpec blends between the clips of its points based on
+// the value of one parameter, or two parameters when ParameterY is set. The
+// clips are kept in step with each other as they play.
+type AnimationBlendSpaceSpec struct {
+	ParameterX string
+	ParameterY string
+	Points     []AnimationBlendPointSpec
+}
+
+type AnimationBlendPointSpec struct {
+	Clip string
+	X, Y float32
+}
+
+type AnimationTransitionSpec struct {
+	From string
+	To   string
+	// Duration is how long, in seconds, the two states are blended for
+	Duration float32
+	// ExitTime is how far through the from state, where 1 is one play through
+	// of the state, it must be before the transition can be taken. A
+	// transition without conditions or an exit time is taken at the end of
+	// the from state.
+	ExitTime   float32
+	Conditions []AnimationConditionSpec
+}
+
+// AnimationConditionSpec is a test against a parameter that must pass for a
+// transition to be taken. Bool parameters are compared against 0 and 1 and
+// trigger parameters pass when they have been set, ignoring the Op.
+type AnimationConditionSpec struct {
+	Parameter string
+	Op        AnimationConditionOp
+	Value     float32
+}
+
+// LoadAnimationGraphSpec reads the animation graph content with the given id
+func LoadAnimationGraphSpec(host *engine.Host, id string) (AnimationGraphSpec, error) {
+	var spec AnimationGraphSpec
+	data, err := host.AssetDatabase().Read(id)
+	if err != nil {
+		return spec, err
+	}
+	err = json.Unmarshal(data, &spec)
+	return spec, err
+}
@@ -0,0 +1,781 @@
+/******************************************************************************/
+/* animation_graph_player.go                                                  */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package framework
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"kaijuengine.com/engine/systems/events"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/platform/profiler/tracing"
+	"kaijuengine.com/rendering/loaders/kaiju_mesh"
+)
+
+var ErrAnimationGraphInvalid = errors.New("the animation graph is invalid")
+
+type animationParameter struct {
+	name  string
+	kind  AnimationParameterType
+	value float32
+}
+
+type animationCondition struct {
+	param int
+	op    AnimationConditionOp
+	value float32
+}
+
+type animationTransition struct {
+	from       int
+	to         int
+	duration   float64
+	exitTime   float64
+	conditions []animationCondition
+}
+
+type animationState struct {
+	name    string
+	speed   float64
+	loop    bool
+	clips   []*AnimationClip
+	points  []matrix.Vec2
+	paramX  int
+	paramY  int
+	weights []matrix.Float
+}
+
+// animationPlayback is a state that is playing in a layer, only the newest
+// playback is the current state, the older ones are fading out
+type animationPlayback struct {
+	state       int
+	prevTime    float64
+	time        float64
+	weight      float64
+	startWeight float64
+}
+
+type animationLayer struct {
+	name         string
+	additive     bool
+	weight       float32
+	mask         []float32
+	states       []animationState
+	transitions  []animationTransition
+	playing      []animationPlayback
+	fade         float64
+	fadeDuration float64
+	pose         AnimationPose
+}
+
+// AnimationGraph plays an [AnimationGraphSpec] on a skeleton. Parameters are
+// set by the game to drive the transitions and blend spaces of the graph,
+// Update moves it forward in time and Pose samples it.
+type AnimationGraph struct {
+	// OnEvent is called for each clip event that is passed while updating,
+	// only the most weighted clip of a blend, or a transition, fires events
+	OnEvent    events.EventWithArg[SkinAnimationEvent]
+	skeleton   *AnimationSkeleton
+	params     []animationParameter
+	layers     []animationLayer
+	clips      []*AnimationClip
+	pose       AnimationPose
+	scratch    AnimationPose
+	clipPose   AnimationPose
+	blender    poseBlender
+	fading     poseBlender
+	rootMotion bool
+	rootMove   matrix.Vec3
+	rootYaw    matrix.Float
+	dirty      bool
+}
+
+// NewAnimationGraph creates the runtime of the graph using the animations
+// and joints of a mesh. [ErrAnimationGraphInvalid] is returned when the graph
+// refers to a clip, state or parameter that doesn't exist.
+func NewAnimationGraph(spec AnimationGraphSpec, anims []kaiju_mesh.KaijuMeshAnimation, joints []kaiju_mesh.KaijuMeshJoint) (*AnimationGraph, error) {
+	defer tracing.NewRegion("framework.NewAnimationGraph").End()
+	if len(spec.Layers) == 0 {
+		return nil, fmt.Errorf("%w: the graph has no layers", ErrAnimationGraphInvalid)
+	}
+	g := &AnimationGraph{
+		skeleton: NewAnimationSkeleton(joints),
+		params:   make([]animationParameter, len(spec.Parameters)),
+		layers:   make([]animationLayer, len(spec.Layers)),
+		dirty:    true,
+	}
+	g.pose = g.skeleton.BindPose()
+	g.scratch = g.skeleton.BindPose()
+	g.clipPose = g.skeleton.BindPose()
+	for i, p := range spec.Parameters {
+		switch p.Type {
+		case AnimationParameterFloat, AnimationParameterBool, AnimationParameterTrigger:
+		default:
+			return nil, fmt.Errorf("%w: parameter %q has the unknown type %q",
+				ErrAnimationGraphInvalid, p.Name, p.Type)
+		}
+		g.params[i] = animationParameter{name: p.Name, kind: p.Type, value: p.Default}
+	}
+	findClip := func(name string) (*AnimationClip, error) {
+		if c := g.findClip(name); c != nil {
+			return c, nil
+		}
+		for i := range anims {
+			if strings.EqualFold(anims[i].Name, name) {
+				g.clips = append(g.clips, NewAnimationClip(anims[i], g.skeleton))
+				return g.clips[len(g.clips)-1], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: the mesh has no animation named %q", ErrAnimationGraphInvalid, name)
+	}
+	for i := range spec.Layers {
+		if err := g.layers[i].setup(g, &spec.Layers[i], findClip); err != nil {
+			return nil, err
+		}
+	}
+	for _, cs := range spec.Clips {
+		c, err := findClip(cs.Name)
+		if err != nil {
+			return nil, err
+		}
+		c.Events = append(c.Events, cs.Events...)
+	}
+	return g, nil
+}
+
+func (l *animationLayer) setup(g *AnimationGraph, spec *AnimationLayerSpec, findClip func(string) (*AnimationClip, error)) error {
+	if len(spec.States) == 0 {
+		return fmt.Errorf("%w: layer %q has no states", ErrAnimationGraphInvalid, spec.Name)
+	}
+	l.name = spec.Name
+	l.additive = spec.Blend == AnimationLayerAdditive
+	l.weight = spec.Weight
+	l.pose = g.skeleton.BindPose()
+	l.states = make([]animationState, len(spec.States))
+	for i := range spec.States {
+		if err := l.states[i].setup(g, &spec.States[i], findClip); err != nil {
+			return err
+		}
+	}
+	stateIndex := func(name string) (int, error) {
+		for i := range l.states {
+			if l.states[i].name == name {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("%w: layer %q has no state named %q",
+			ErrAnimationGraphInvalid, spec.Name, name)
+	}
+	for _, ts := range spec.Transitions {
+		t := animationTransition{
+			from:     -1,
+			duration: float64(ts.Duration),
+			exitTime: float64(ts.ExitTime),
+		}
+		var err error
+		if ts.From != AnimationAnyState {
+			if t.from, err = stateIndex(ts.From); err != nil {
+				return err
+			}
+		}
+		if t.to, err = stateIndex(ts.To); err != nil {
+			return err
+		}
+		for _, cs := range ts.Conditions {
+			c := animationCondition{op: cs.Op, value: cs.Value}
+			if c.param, err = g.parameterIndex(cs.Parameter); err != nil {
+				return err
+			}
+			if c.op == "" {
+				c.op = AnimationConditionEqual
+			}
+			t.conditions = append(t.conditions, c)
+		}
+		if len(t.conditions) == 0 && t.exitTime <= 0 {
+			t.exitTime = 1
+		}
+		l.transitions = append(l.transitions, t)
+	}
+	start := 0
+	if spec.DefaultState != "" {
+		var err error
+		if start, err = stateIndex(spec.DefaultState); err != nil {
+			return err
+		}
+	}
+	l.playing = []animationPlayback{{state: start, weight: 1, startWeight: 1}}
+	if len(spec.Mask) > 0 {
+		l.mask = buildBoneMask(g.skeleton, spec.Mask)
+	}
+	return nil
+}
+
+func (s *animationState) setup(g *AnimationGraph, spec *AnimationStateSpec, findClip func(string) (*AnimationClip, error)) error {
+	s.name = spec.Name
+	s.speed = float64(spec.Speed)
+	if s.speed == 0 {
+		s.speed = 1
+	}
+	s.loop = spec.Loop
+	s.paramX, s.paramY = -1, -1
+	if spec.BlendSpace == nil {
+		clip, err := findClip(spec.Clip)
+		if err != nil {
+			return err
+		}
+		s.clips = []*AnimationClip{clip}
+		s.weights = []matrix.Float{1}
+		return nil
+	}
+	bs := spec.BlendSpace
+	if len(bs.Points) == 0 {
+		return fmt.Errorf("%w: the blend space of state %q has no points",
+			ErrAnimationGraphInvalid, spec.Name)
+	}
+	var err error
+	if s.paramX, err = g.parameterIndex(bs.ParameterX); err != nil {
+		return err
+	}
+	if bs.ParameterY != "" {
+		if s.paramY, err = g.parameterIndex(bs.ParameterY); err != nil {
+			return err
+		}
+	}
+	points := slices.Clone(bs.Points)
+	if s.paramY < 0 {
+		slices.SortStableFunc(points, func(a, b AnimationBlendPointSpec) int {
+			return cmp.Compare(a.X, b.X)
+		})
+	}
+	for _, p := range points {
+		clip, err := findClip(p.Clip)
+		if err != nil {
+			return err
+		}
+		s.clips = append(s.clips, clip)
+		s.points = append(s.points, matrix.NewVec2(p.X, p.Y))
+	}
+	s.weights = make([]matrix.Float, len(s.clips))
+	return nil
+}
+
+// buildBoneMask gives each joint the weight of the closest of itself and its
+// parents that is in the mask, joints without one in the mask get 0
+func buildBoneMask(skeleton *AnimationSkeleton, spec []AnimationBoneMaskSpec) []float32 {
+	mask := make([]float32, skeleton.JointCount())
+	resolved := make([]bool, len(mask))
+	for _, m := range spec {
+		if idx, ok := skeleton.IndexOf(m.Bone); ok {
+			mask[idx] = m.Weight
+			resolved[idx] = true
+		}
+	}
+	var resolve func(i, depth int) float32
+	resolve = func(i, depth int) float32 {
+		if resolved[i] || depth > len(mask) {
+			return mask[i]
+		}
+		if parent, ok := skeleton.IndexOf(skeleton.Joint(i).Parent); ok {
+			mask[i] = resolve(parent, depth+1)
+		}
+		resolved[i] = true
+		return mask[i]
+	}
+	for i := range mask {
+		resolve(i, 0)
+	}
+	return mask
+}
+
+func (g *AnimationGraph) parameterIndex(name string) (int, error) {
+	for i := range g.params {
+		if g.params[i].name == name {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%w: there is no parameter named %q", ErrAnimationGraphInvalid, name)
+}
+
+func (g *AnimationGraph) findClip(name string) *AnimationClip {
+	for _, c := range g.clips {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// AddEvent adds an event to a clip that the graph plays, it returns false if
+// the graph doesn't play a clip with the name
+func (g *AnimationGraph) AddEvent(clip, name string, time float32) bool {
+	c := g.findClip(clip)
+	if c == nil {
+		return false
+	}
+	c.AddEvent(name, time)
+	return true
+}
+
+// SetRootMotion sets if the horizontal movement and turning of the root bone
+// is taken out of the pose. The motion that was taken out during the last
+// Update is given by RootMotion, for it to be applied to whatever owns the
+// skeleton.
+func (g *AnimationGraph) SetRootMotion(enabled bool) {
+	g.rootMotion = enabled
+	g.dirty = true
+}
+
+// RootMotion returns how far the root bone moved, in the space of the
+// skeleton's parent, and how much it turned about the up axis, in degrees,
+// during the last Update. Only the first layer of the graph moves the root.
+func (g *AnimationGraph) RootMotion() (matrix.Vec3, matrix.Float) {
+	return g.rootMove, matrix.Rad2Deg(g.rootYaw)
+}
+
+// Skeleton returns the skeleton that the poses of the graph are for
+func (g *AnimationGraph) Skeleton() *AnimationSkeleton { return g.skeleton }
+
+func (g *AnimationGraph) findParameter(name string) *animationParameter {
+	idx, err := g.parameterIndex(name)
+	if err != nil {
+		return nil
+	}
+	return &g.params[idx]
+}
+
+// SetFloat sets the value of a float parameter, it returns false if there is
+// no parameter with the name
+func (g *AnimationGraph) SetFloat(name string, value float32) bool {
+	p := g.findParameter(name)
+	if p == nil {
+		return false
+	}
+	p.value = value
+	g.dirty = true
+	return true
+}
+
+// SetBool sets the value of a bool parameter, it returns false if there is
+// no parameter with the name
+func (g *AnimationGraph) SetBool(name string, value bool) bool {
+	if value {
+		return g.SetFloat(name, 1)
+	}
+	return g.SetFloat(name, 0)
+}
+
+// SetTrigger sets a trigger parameter, it stays set until a transition that
+// tests it is taken or it is reset
+func (g *AnimationGraph) SetTrigger(name string) bool { return g.SetFloat(name, 1) }
+
+func (g *AnimationGraph) ResetTrigger(name string) bool { return g.SetFloat(name, 0) }
+
+func (g *AnimationGraph) Float(name string) float32 {
+	if p := g.findParameter(name); p != nil {
+		return p.value
+	}
+	return 0
+}
+
+func (g *AnimationGraph) Bool(name string) bool { return g.Float(name) != 0 }
+
+func (g *AnimationGraph) findLayer(name string) *animationLayer {
+	for i := range g.layers {
+		if g.layers[i].name == name {
+			return &g.layers[i]
+		}
+	}
+	return nil
+}
+
+// SetLayerWeight sets how much of the layer is applied over the layers
+// below it, the weight of the first layer is not used
+func (g *AnimationGraph) SetLayerWeight(layer string, weight float32) bool {
+	l := g.findLayer(layer)
+	if l == nil {
+		return false
+	}
+	l.weight = weight
+	g.dirty = true
+	return true
+}
+
+// CurrentState returns the name of the state the layer is in, or is
+// transitioning to
+func (g *AnimationGraph) CurrentState(layer string) string {
+	l := g.findLayer(layer)
+	if l == nil {
+		return ""
+	}
+	return l.states[l.current().state].name
+}
+
+// IsTransitioning returns true while the layer is blending between states
+func (g *AnimationGraph) IsTransitioning(layer string) bool {
+	l := g.findLayer(layer)
+	return l != nil && len(l.playing) > 1
+}
+
+// Play transitions the layer into the state, ignoring the transitions of the
+// graph, blending into it over the given number of seconds
+func (g *AnimationGraph) Play(layer, state string, blendDuration float64) bool {
+	l := g.findLayer(layer)
+	if l == nil {
+		return false
+	}
+	for i := range l.states {
+		if l.states[i].name == state {
+			l.transition(i, blendDuration)
+			g.dirty = true
+			return true
+		}
+	}
+	return false
+}
+
+// Update moves the graph forward in time, taking any transitions whose
+// conditions are met
+func (g *AnimationGraph) Update(deltaTime float64) {
+	defer tracing.NewRegion("AnimationGraph.Update").End()
+	g.rootMove, g.rootYaw = matrix.Vec3{}, 0
+	for i := range g.layers {
+		l := &g.layers[i]
+		l.takeTransitions(g)
+		l.advance(g, deltaTime)
+		if i == 0 || l.weight > 0 {
+			l.fireEvents(g)
+		}
+	}
+	if g.rootMotion && g.skeleton.Root() >= 0 {
+		g.layers[0].collectRootMotion(g)
+	}
+	g.dirty = true
+}
+
+// Pose returns the pose of the skeleton at the current time of the graph.
+// The pose belongs to the graph and is overwritten by the next call.
+func (g *AnimationGraph) Pose() AnimationPose {
+	if !g.dirty {
+		return g.pose
+	}
+	defer tracing.NewRegion("AnimationGraph.Pose").End()
+	for i := range g.layers {
+		g.layers[i].sample(g)
+	}
+	copy(g.pose, g.layers[0].pose)
+	for i := 1; i < len(g.layers); i++ {
+		l := &g.layers[i]
+		if l.weight <= 0 {
+			continue
+		}
+		for b := range g.pose {
+			w := l.weight
+			if l.mask != nil {
+				w *= l.mask[b]
+			}
+			if w <= 0 {
+				continue
+			}
+			if l.additive {
+				g.pose[b] = boneAdd(g.pose[b], l.pose[b], w)
+			} else {
+				g.pose[b] = boneLerp(g.pose[b], l.pose[b], min(w, 1))
+			}
+		}
+	}
+	if g.rootMotion && g.skeleton.Root() >= 0 {
+		g.removeRootMotion()
+	}
+	g.dirty = false
+	return g.pose
+}
+
+// removeRootMotion leaves the root bone where it is in the bind pose on the
+// ground and facing the same way, it is still free to move up and down and
+// to lean
+func (g *AnimationGraph) removeRootMotion() {
+	root := g.skeleton.Root()
+	bind := &g.skeleton.bind[root]
+	bone := &g.pose[root]
+	bone.Position.SetX(bind.Position.X())
+	bone.Position.SetZ(bind.Position.Z())
+	yaw := wrapAngle(quaternionYaw(bone.Rotation) - quaternionYaw(bind.Rotation))
+	bone.Rotation = matrix.QuaternionAxisAngle(matrix.Vec3Up(), -yaw).Multiply(bone.Rotation)
+}
+
+func (l *animationLayer) current() *animationPlayback {
+	return &l.playing[len(l.playing)-1]
+}
+
+func (l *animationLayer) transition(to int, duration float64) {
+	if duration <= 0 {
+		l.playing = append(l.playing[:0], animationPlayback{state: to, weight: 1, startWeight: 1})
+		l.fade, l.fadeDuration = 0, 0
+		return
+	}
+	// The states that are already fading out carry on from the weight they
+	// have now, so interrupting a transition doesn't pop
+	for i := range l.playing {
+		l.playing[i].startWeight = l.playing[i].weight
+	}
+	l.playing = append(l.playing, animationPlayback{state: to})
+	l.fade, l.fadeDuration = 0, duration
+}
+
+func (l *animationLayer) takeTransitions(g *AnimationGraph) {
+	cur := l.current()
+	for i := range l.transitions {
+		t := &l.transitions[i]
+		if (t.from >= 0 && t.from != cur.state) || (t.from < 0 && t.to == cur.state) {
+			continue
+		}
+		if t.exitTime > 0 && cur.time < t.exitTime {
+			continue
+		}
+		if !t.conditionsPass(g) {
+			continue
+		}
+		for _, c := range t.conditions {
+			if p := &g.params[c.param]; p.kind == AnimationParameterTrigger {
+				p.value = 0
+			}
+		}
+		l.transition(t.to, t.duration)
+		return
+	}
+}
+
+func (t *animationTransition) conditionsPass(g *AnimationGraph) bool {
+	for _, c := range t.conditions {
+		p := &g.params[c.param]
+		if p.kind == AnimationParameterTrigger {
+			if p.value == 0 {
+				return false
+			}
+			continue
+		}
+		var pass bool
+		switch c.op {
+		case AnimationConditionEqual:
+			pass = p.value == c.value
+		case AnimationConditionNotEqual:
+			pass = p.value != c.value
+		case AnimationConditionGreater:
+			pass = p.value > c.value
+		case AnimationConditionLess:
+			pass = p.value < c.value
+		case AnimationConditionGreaterOrEqual:
+			pass = p.value >= c.value
+		case AnimationConditionLessOrEqual:
+			pass = p.value <= c.value
+		}
+		if !pass {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *animationLayer) advance(g *AnimationGraph, deltaTime float64) {
+	for i := range l.playing {
+		p := &l.playing[i]
+		s := &l.states[p.state]
+		s.updateWeights(g)
+		p.prevTime = p.time
+		if d := s.duration(); d > 0 {
+			p.time += deltaTime * s.speed / d
+		}
+		if !s.loop {
+			p.time = min(p.time, 1)
+		}
+	}
+	if len(l.playing) == 1 {
+		return
+	}
+	l.fade += deltaTime
+	w := min(l.fade/l.fadeDuration, 1)
+	last := len(l.playing) - 1
+	l.playing[last].weight = w
+	for i := range last {
+		l.playing[i].weight = l.playing[i].startWeight * (1 - w)
+	}
+	if w >= 1 {
+		l.playing[0] = l.playing[last]
+		l.playing[0].startWeight = 1
+		l.playing = l.playing[:1]
+	}
+}
+
+func (l *animationLayer) fireEvents(g *AnimationGraph) {
+	if g.OnEvent.IsEmpty() {
+		return
+	}
+	dominant := 0
+	for i := range l.playing {
+		if l.playing[i].weight > l.playing[dominant].weight {
+			dominant = i
+		}
+	}
+	p := &l.playing[dominant]
+	s := &l.states[p.state]
+	c := s.clips[s.dominantClip()]
+	d := c.Duration()
+	eachAnimationEvent(c.Events, p.prevTime*d, p.time*d, d, s.loop, g.OnEvent.Execute)
+}
+
+func (l *animationLayer) collectRootMotion(g *AnimationGraph) {
+	for i := range l.playing {
+		p := &l.playing[i]
+		s := &l.states[p.state]
+		for j, c := range s.clips {
+			w := s.weights[j] * matrix.Float(p.weight)
+			if w <= 0 {
+				continue
+			}
+			d := c.Duration()
+			move, yaw := c.rootMotion(p.prevTime*d, p.time*d, s.loop)
+			g.rootMove.AddAssign(move.Scale(w))
+			g.rootYaw += yaw * w
+		}
+	}
+}
+
+func (l *animationLayer) sample(g *AnimationGraph) {
+	if len(l.playing) == 1 {
+		l.states[l.playing[0].state].sample(g, l.playing[0].time, l.additive, l.pose)
+		return
+	}
+	g.fading.reset(len(l.pose))
+	for i := range l.playing {
+		p := &l.playing[i]
+		if p.weight <= 0 {
+			continue
+		}
+		l.states[p.state].sample(g, p.time, l.additive, g.scratch)
+		g.fading.add(g.scratch, matrix.Float(p.weight))
+	}
+	g.fading.finish(l.pose)
+}
+
+// duration returns the length of one play through of the state, which for a
+// blend space is the blended length of its clips
+func (s *animationState) duration() float64 {
+	d := 0.0
+	for i, c := range s.clips {
+		d += c.Duration() * float64(s.weights[i])
+	}
+	return d
+}
+
+func (s *animationState) dominantClip() int {
+	best := 0
+	for i := range s.weights {
+		if s.weights[i] > s.weights[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+func (s *animationState) sample(g *AnimationGraph, time float64, additive bool, out AnimationPose) {
+	sampleClip := func(c *AnimationClip, pose AnimationPose) {
+		t := time * c.Duration()
+		if additive {
+			c.sampleAdditive(t, s.loop, pose)
+		} else {
+			copy(pose, g.skeleton.bind)
+			c.Sample(t, s.loop, pose)
+		}
+	}
+	s.updateWeights(g)
+	if len(s.clips) == 1 {
+		sampleClip(s.clips[0], out)
+		return
+	}
+	g.blender.reset(len(out))
+	for i, c := range s.clips {
+		if s.weights[i] > 0 {
+			sampleClip(c, g.clipPose)
+			g.blender.add(g.clipPose, s.weights[i])
+		}
+	}
+	g.blender.finish(out)
+}
+
+func (s *animationState) updateWeights(g *AnimationGraph) {
+	if s.paramX < 0 {
+		return
+	}
+	clear(s.weights)
+	x := matrix.Float(g.params[s.paramX].value)
+	if s.paramY < 0 {
+		s.weights1D(x)
+	} else {
+		s.weights2D(matrix.NewVec2(x, g.params[s.paramY].value))
+	}
+}
+
+func (s *animationState) weights1D(x matrix.Float) {
+	last := len(s.points) - 1
+	if x <= s.points[0].X() {
+		s.weights[0] = 1
+		return
+	} else if x >= s.points[last].X() {
+		s.weights[last] = 1
+		return
+	}
+	for i := range last {
+		a, b := s.points[i].X(), s.points[i+1].X()
+		if x >= a && x <= b {
+			t := (x - a) / (b - a)
+			s.weights[i] = 1 - t
+			s.weights[i+1] = t
+			return
+		}
+	}
+}
+
+// weights2D uses gradient band interpolation, each point's weight falls off
+// towards every other point so that the weights are 1 on the points
+// themselves and change smoothly between them
+func (s *animationState) weights2D(p matrix.Vec2) {
+	total := matrix.Float(0)
+	for i, pi := range s.points {
+		w := matrix.Float(1)
+		toP := p.Subtract(pi)
+		for j, pj := range s.points {
+			if i == j {
+				continue
+			}
+			d := pj.Subtract(pi)
+			lenSq := matrix.Vec2Dot(d, d)
+			if lenSq <= 0 {
+				continue
+			}
+			w = min(w, 1-matrix.Vec2Dot(toP, d)/lenSq)
+		}
+		s.weights[i] = max(w, 0)
+		total += s.weights[i]
+	}
+	if total > 0 {
+		for i := range s.weights {
+			s.weights[i] /= total
+		}
+		return
+	}
+	nearest, best := 0, matrix.Float(math.MaxFloat32)
+	for i, pi := range s.points {
+		if d := p.Subtract(pi).Length(); d < best {
+			nearest, best = i, d
+		}
+	}
+	s.weights[nearest] = 1
+}
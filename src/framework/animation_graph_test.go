@@ -0,0 +1,322 @@
+/******************************************************************************/
+/* animation_graph_test.go                                                    */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package framework
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/rendering/loaders/kaiju_mesh"
+	"kaijuengine.com/rendering/loaders/load_result"
+)
+
+var testJoints = []kaiju_mesh.KaijuMeshJoint{
+	{Id: 4, Parent: -1, Scale: matrix.Vec3One()},
+	{Id: 7, Parent: 4, Position: matrix.NewVec3(0, 1, 0), Scale: matrix.Vec3One()},
+}
+
+// testAnim makes an animation with frames one second apart that move both
+// joints to the given x positions, the last frame has no length the same as
+// the frames of a loaded mesh
+func testAnim(name string, xs ...matrix.Float) kaiju_mesh.KaijuMeshAnimation {
+	anim := kaiju_mesh.KaijuMeshAnimation{Name: name}
+	for i, x := range xs {
+		anim.Frames = append(anim.Frames, kaiju_mesh.AnimKeyFrame{
+			Time: matrix.Float(min(len(xs)-1-i, 1)),
+			Bones: []kaiju_mesh.AnimBone{
+				{NodeIndex: 4, PathType: load_result.AnimPathTranslation, Data: [4]matrix.Float{x, 0, 0, 1}},
+				{NodeIndex: 7, PathType: load_result.AnimPathTranslation, Data: [4]matrix.Float{x, 1, 0, 1}},
+			},
+		})
+	}
+	return anim
+}
+
+var testAnims = []kaiju_mesh.KaijuMeshAnimation{
+	testAnim("idle", 0, 0),
+	testAnim("run", 10, 10),
+	testAnim("jump", 20, 20),
+	testAnim("sway", 0, 4),
+}
+
+func expectX(t *testing.T, pose AnimationPose, joint int, want matrix.Float) {
+	t.Helper()
+	if got := pose[joint].Position.X(); !matrix.ApproxTo(got, want, 0.001) {
+		t.Fatalf("joint %d x is %f, expected %f", joint, got, want)
+	}
+}
+
+func newTestGraph(t *testing.T, spec AnimationGraphSpec) *AnimationGraph {
+	t.Helper()
+	g, err := NewAnimationGraph(spec, testAnims, testJoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestAnimationClipSample(t *testing.T) {
+	skeleton := NewAnimationSkeleton(testJoints)
+	clip := NewAnimationClip(testAnim("move", 0, 10), skeleton)
+	if clip.Duration() != 1 {
+		t.Fatalf("expected a 1 second clip, got %f", clip.Duration())
+	}
+	pose := skeleton.BindPose()
+	clip.Sample(0.5, false, pose)
+	expectX(t, pose, 0, 5)
+	clip.Sample(1.5, false, pose)
+	expectX(t, pose, 0, 10)
+	clip.Sample(1.25, true, pose)
+	expectX(t, pose, 0, 2.5)
+}
+
+func TestAnimationGraphTransitions(t *testing.T) {
+	g := newTestGraph(t, AnimationGraphSpec{
+		Parameters: []AnimationParameterSpec{
+			{Name: "speed", Type: AnimationParameterFloat},
+			{Name: "stop", Type: AnimationParameterTrigger},
+		},
+		Layers: []AnimationLayerSpec{{
+			Name: "base",
+			States: []AnimationStateSpec{
+				{Name: "idle", Clip: "idle", Loop: true},
+				{Name: "run", Clip: "run", Loop: true},
+			},
+			Transitions: []AnimationTransitionSpec{
+				{From: "idle", To: "run", Duration: 1, Conditions: []AnimationConditionSpec{
+					{Parameter: "speed", Op: AnimationConditionGreater, Value: 0.5},
+				}},
+				{From: AnimationAnyState, To: "idle", Conditions: []AnimationConditionSpec{
+					{Parameter: "stop"},
+				}},
+			},
+		}},
+	})
+	g.Update(0.1)
+	expectX(t, g.Pose(), 0, 0)
+	g.SetFloat("speed", 1)
+	g.Update(0.5)
+	if g.CurrentState("base") != "run" || !g.IsTransitioning("base") {
+		t.Fatalf("expected to be transitioning to run, in %s", g.CurrentState("base"))
+	}
+	expectX(t, g.Pose(), 0, 5)
+	g.Update(0.6)
+	if g.IsTransitioning("base") {
+		t.Fatal("the transition should have finished")
+	}
+	expectX(t, g.Pose(), 0, 10)
+	g.SetFloat("speed", 0)
+	g.SetTrigger("stop")
+	g.Update(0.1)
+	if g.CurrentState("base") != "idle" {
+		t.Fatalf("the trigger should have moved to idle, in %s", g.CurrentState("base"))
+	}
+	if g.Bool("stop") {
+		t.Fatal("the trigger should be reset once it is used")
+	}
+	expectX(t, g.Pose(), 0, 0)
+}
+
+func TestAnimationGraphExitTime(t *testing.T) {
+	g := newTestGraph(t, AnimationGraphSpec{
+		Layers: []AnimationLayerSpec{{
+			States: []AnimationStateSpec{
+				{Name: "jump", Clip: "jump"},
+				{Name: "idle", Clip: "idle", Loop: true},
+			},
+			Transitions: []AnimationTransitionSpec{{From: "jump", To: "idle"}},
+		}},
+	})
+	g.Update(0.5)
+	if g.CurrentState("") != "jump" {
+		t.Fatal("the jump should play to the end before leaving")
+	}
+	g.Update(0.5)
+	g.Update(0)
+	if g.CurrentState("") != "idle" {
+		t.Fatalf("expected to leave the jump once it ended, in %s", g.CurrentState(""))
+	}
+}
+
+func TestAnimationGraphBlendSpaces(t *testing.T) {
+	g := newTestGraph(t, AnimationGraphSpec{
+		Parameters: []AnimationParameterSpec{
+			{Name: "x", Type: AnimationParameterFloat},
+			{Name: "y", Type: AnimationParameterFloat},
+		},
+		Layers: []AnimationLayerSpec{
+			{Name: "1d", States: []AnimationStateSpec{{Name: "move", Loop: true,
+				BlendSpace: &AnimationBlendSpaceSpec{ParameterX: "x", Points: []AnimationBlendPointSpec{
+					{Clip: "run", X: 1}, {Clip: "idle", X: 0},
+				}},
+			}}},
+		},
+	})
+	g.SetFloat("x", 0.25)
+	g.Update(0.1)
+	expectX(t, g.Pose(), 0, 2.5)
+	g.SetFloat("x", 3)
+	expectX(t, g.Pose(), 0, 10)
+	g = newTestGraph(t, AnimationGraphSpec{
+		Parameters: []AnimationParameterSpec{
+			{Name: "x", Type: AnimationParameterFloat},
+			{Name: "y", Type: AnimationParameterFloat},
+		},
+		Layers: []AnimationLayerSpec{
+			{Name: "2d", States: []AnimationStateSpec{{Name: "move", Loop: true,
+				BlendSpace: &AnimationBlendSpaceSpec{ParameterX: "x", ParameterY: "y", Points: []AnimationBlendPointSpec{
+					{Clip: "idle"}, {Clip: "run", X: 1}, {Clip: "jump", Y: 1},
+				}},
+			}}},
+		},
+	})
+	for _, c := range []struct{ x, y, want matrix.Float }{
+		{0, 0, 0}, {1, 0, 10}, {0, 1, 20}, {0.5, 0, 5}, {0, 0.5, 10},
+	} {
+		g.SetFloat("x", float32(c.x))
+		g.SetFloat("y", float32(c.y))
+		expectX(t, g.Pose(), 0, c.want)
+	}
+}
+
+func TestAnimationGraphLayersAndMasks(t *testing.T) {
+	g := newTestGraph(t, AnimationGraphSpec{
+		Layers: []AnimationLayerSpec{
+			{Name: "base", States: []AnimationStateSpec{{Name: "idle", Clip: "idle", Loop: true}}},
+			{
+				Name:   "upper",
+				Weight: 1,
+				Mask:   []AnimationBoneMaskSpec{{Bone: 7, Weight: 1}},
+				States: []AnimationStateSpec{{Name: "run", Clip: "run", Loop: true}},
+			},
+			{
+				Name:   "sway",
+				Blend:  AnimationLayerAdditive,
+				Weight: 0.5,
+				States: []AnimationStateSpec{{Name: "sway", Clip: "sway"}},
+			},
+		},
+	})
+	g.Update(0.5)
+	pose := g.Pose()
+	// Sway moves 4 over its second, so half a second in it has moved 2,
+	// which is halved by the weight of the layer
+	expectX(t, pose, 0, 1)
+	expectX(t, pose, 1, 11)
+	if y := pose[1].Position.Y(); !matrix.ApproxTo(y, 1, 0.001) {
+		t.Fatalf("the additive layer should not change the unmoved axis, y is %f", y)
+	}
+	g.SetLayerWeight("upper", 0)
+	expectX(t, g.Pose(), 1, 1)
+	skeleton := NewAnimationSkeleton(testJoints)
+	mask := buildBoneMask(skeleton, []AnimationBoneMaskSpec{{Bone: 4, Weight: 0.5}})
+	if mask[0] != 0.5 || mask[1] != 0.5 {
+		t.Fatalf("the child joint should use the weight of its parent, got %v", mask)
+	}
+}
+
+func TestAnimationGraphInvalid(t *testing.T) {
+	specs := []AnimationGraphSpec{
+		{},
+		{Layers: []AnimationLayerSpec{{}}},
+		{Layers: []AnimationLayerSpec{{States: []AnimationStateSpec{{Name: "a", Clip: "missing"}}}}},
+		{Layers: []AnimationLayerSpec{{
+			States:      []AnimationStateSpec{{Name: "a", Clip: "idle"}},
+			Transitions: []AnimationTransitionSpec{{From: "a", To: "b"}},
+		}}},
+		{Layers: []AnimationLayerSpec{{
+			States: []AnimationStateSpec{{Name: "a", Clip: "idle"}, {Name: "b", Clip: "run"}},
+			Transitions: []AnimationTransitionSpec{{From: "a", To: "b", Conditions: []AnimationConditionSpec{
+				{Parameter: "missing"},
+			}}},
+		}}},
+	}
+	for i, spec := range specs {
+		if _, err := NewAnimationGraph(spec, testAnims, testJoints); !errors.Is(err, ErrAnimationGraphInvalid) {
+			t.Fatalf("spec %d: expected the graph to be invalid, got %v", i, err)
+		}
+	}
+}
+
+func TestAnimationGraphEvents(t *testing.T) {
+	g := newTestGraph(t, AnimationGraphSpec{
+		Clips: []AnimationClipSpec{
+			{Name: "idle", Events: []SkinAnimationEvent{{Name: "breathe", Time: 0.5}}},
+			{Name: "run", Events: []SkinAnimationEvent{{Name: "step", Time: 0.5}}},
+		},
+		Layers: []AnimationLayerSpec{{
+			States: []AnimationStateSpec{
+				{Name: "idle", Clip: "idle", Loop: true},
+				{Name: "run", Clip: "run", Loop: true},
+			},
+		}},
+	})
+	fired := []string{}
+	g.OnEvent.Add(func(e SkinAnimationEvent) { fired = append(fired, e.Name) })
+	g.Update(1)
+	g.Play("", "run", 1)
+	// The run is blended in over a second, its events only fire once it
+	// outweighs the idle
+	g.Update(0.25)
+	g.Update(0.5)
+	if !g.AddEvent("run", "land", 0.9) || g.AddEvent("missing", "land", 0) {
+		t.Fatal("events should only be added to the clips of the graph")
+	}
+	g.Update(0.2)
+	if want := []string{"breathe", "step", "land"}; !slices.Equal(fired, want) {
+		t.Fatalf("expected the events %v, got %v", want, fired)
+	}
+}
+
+func TestAnimationGraphRootMotion(t *testing.T) {
+	walk := kaiju_mesh.KaijuMeshAnimation{Name: "walk"}
+	turn := kaiju_mesh.KaijuMeshAnimation{Name: "turn"}
+	for i := range 2 {
+		z := matrix.Float(i) * 2
+		walk.Frames = append(walk.Frames, kaiju_mesh.AnimKeyFrame{Time: matrix.Float(1 - i), Bones: []kaiju_mesh.AnimBone{
+			{NodeIndex: 4, PathType: load_result.AnimPathTranslation, Data: [4]matrix.Float{0, 0.5, z, 1}},
+		}})
+		rot := matrix.QuaternionFromEuler(matrix.NewVec3(0, matrix.Float(i)*90, 0))
+		turn.Frames = append(turn.Frames, kaiju_mesh.AnimKeyFrame{Time: matrix.Float(1 - i), Bones: []kaiju_mesh.AnimBone{
+			{NodeIndex: 4, PathType: load_result.AnimPathRotation, Data: rot},
+		}})
+	}
+	g, err := NewAnimationGraph(AnimationGraphSpec{
+		Layers: []AnimationLayerSpec{{States: []AnimationStateSpec{
+			{Name: "walk", Clip: "walk", Loop: true},
+			{Name: "turn", Clip: "turn"},
+		}}},
+	}, []kaiju_mesh.KaijuMeshAnimation{walk, turn}, testJoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetRootMotion(true)
+	// The walk moves 2 each second and carries on from where it ended each
+	// time it loops
+	for i, want := range []matrix.Float{1, 1, 1, 1} {
+		g.Update(0.5)
+		move, _ := g.RootMotion()
+		if !matrix.ApproxTo(move.Z(), want, 0.001) || !matrix.ApproxTo(move.Y(), 0, 0.001) {
+			t.Fatalf("step %d moved %v, expected %f forward", i, move, want)
+		}
+		root := g.Pose()[0]
+		if !matrix.ApproxTo(root.Position.Z(), 0, 0.001) || !matrix.ApproxTo(root.Position.Y(), 0.5, 0.001) {
+			t.Fatalf("the root should only keep its height, it is at %v", root.Position)
+		}
+	}
+	g.Play("", "turn", 0)
+	g.Update(0.5)
+	_, yaw := g.RootMotion()
+	if !matrix.ApproxTo(yaw, 45, 0.01) {
+		t.Fatalf("expected to turn 45 degrees, turned %f", yaw)
+	}
+	if r := g.Pose()[0].Rotation; !matrix.ApproxTo(matrix.Abs(r.W()), 1, 0.0001) {
+		t.Fatalf("the turn should be taken out of the root, its rotation is %v", r)
+	}
+}
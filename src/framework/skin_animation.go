@@ -9,6 +9,7 @@ package framework
 import (
 	"math"
 
+	"kaijuengine.com/engine/systems/events"
 	"kaijuengine.com/matrix"
 	"kaijuengine.com/rendering/loaders/kaiju_mesh"
 	"kaijuengine.com/rendering/loaders/load_result"
@@ -21,6 +22,10 @@ type SkinAnimation struct {
 	time          float64
 	totalTime     float64
 	absFrameTimes []float64
+	// Events are the moments of the animation that fire OnEvent as the
+	// animation plays past them
+	Events  []SkinAnimationEvent
+	OnEvent events.EventWithArg[SkinAnimationEvent]
 }
 
 type SkinAnimationFrame struct {
@@ -44,6 +49,12 @@ func NewSkinAnimation(anim kaiju_mesh.KaijuMeshAnimation) SkinAnimation {
 
 func (a *SkinAnimation) IsValid() bool { return len(a.Animation.Frames) > 0 }
 
+// AddEvent adds an event that fires when the animation plays past the time,
+// in seconds from the start of the animation
+func (a *SkinAnimation) AddEvent(name string, time float32) {
+	a.Events = append(a.Events, SkinAnimationEvent{Name: name, Time: time})
+}
+
 func (a *SkinAnimation) FindNextFrameForBone(boneId int32, pathType kaiju_mesh.AnimationPathType) (SkinAnimationFrame, bool) {
 	for i := a.frame + 1; i < len(a.Animation.Frames); i++ {
 		for j := range a.Animation.Frames[i].Bones {
@@ -95,15 +106,22 @@ func (a *SkinAnimation) Update(deltaTime float64) {
 		a.frame = 0
 		a.nextFrame = min(a.frame+1, len(a.Animation.Frames)-1)
 	} else {
+		eachAnimationEvent(a.Events, a.time, a.time+deltaTime, a.totalTime, true, a.OnEvent.Execute)
 		a.time += deltaTime
 		nextTime := a.absFrameTimes[a.nextFrame]
+		last := len(a.Animation.Frames) - 1
 		for a.time >= nextTime {
 			a.frame++
-			if a.time > a.totalTime {
+			if a.time >= a.totalTime {
 				a.time = math.Mod(a.time, a.totalTime)
 				a.frame = 0
 			}
-			a.nextFrame = min(a.frame+1, len(a.Animation.Frames)-1)
+			if a.frame >= last {
+				// Hold the last frame until the time passes the end
+				a.frame, a.nextFrame = last, last
+				break
+			}
+			a.nextFrame = a.frame + 1
 			nextTime = a.absFrameTimes[a.nextFrame]
 		}
 	}
@@ -0,0 +1,353 @@
+/******************************************************************************/
+/* localization_strings.go                                                    */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package localization
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"kaijuengine.com/engine/assets"
+	"kaijuengine.com/engine/systems/events"
+)
+
+// StringTablePath is the folder in the asset database that holds the string
+// tables, each table is a file named after its locale (en-US.json)
+const StringTablePath = "localization"
+
+// CountArg is the name of the placeholder that holds the count of a plural
+const CountArg = "count"
+
+// StringTable is the translated text for a single locale. The file is a JSON
+// object with the strings by key, a string can either be plain text or an
+// object of CLDR plural forms (zero, one, two, few, many, other):
+//
+//	{
+//		"fallback": "fr",
+//		"strings": {
+//			"greeting": "Bonjour {name}",
+//			"apples": { "one": "{count} pomme", "other": "{count} pommes" }
+//		}
+//	}
+//
+// The optional fallback is the locale that is searched next when a key is
+// missing, before the parents of the locale (fr-CA then fr).
+type StringTable struct {
+	Locale   string
+	Fallback string
+	tag      language.Tag
+	strings  map[string]tableEntry
+}
+
+type tableEntry struct {
+	text   string
+	plural map[plural.Form]string
+}
+
+type stringTableFile struct {
+	Fallback string                     `json:"fallback"`
+	Strings  map[string]json.RawMessage `json:"strings"`
+}
+
+var pluralForms = map[string]plural.Form{
+	"zero":  plural.Zero,
+	"one":   plural.One,
+	"two":   plural.Two,
+	"few":   plural.Few,
+	"many":  plural.Many,
+	"other": plural.Other,
+}
+
+// ParseStringTable reads a string table file for the locale
+func ParseStringTable(locale string, data []byte) (*StringTable, error) {
+	file := stringTableFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	table := &StringTable{
+		Locale:   locale,
+		Fallback: file.Fallback,
+		tag:      language.Make(locale),
+		strings:  make(map[string]tableEntry, len(file.Strings)),
+	}
+	for key, raw := range file.Strings {
+		entry := tableEntry{}
+		if err := json.Unmarshal(raw, &entry.text); err == nil {
+			table.strings[key] = entry
+			continue
+		}
+		forms := map[string]string{}
+		if err := json.Unmarshal(raw, &forms); err != nil {
+			return nil, fmt.Errorf("the string %q must be text or plural forms: %w", key, err)
+		}
+		entry.plural = make(map[plural.Form]string, len(forms))
+		for name, text := range forms {
+			form, ok := pluralForms[name]
+			if !ok {
+				return nil, fmt.Errorf("the string %q has an unknown plural form %q", key, name)
+			}
+			entry.plural[form] = text
+		}
+		if _, ok := entry.plural[plural.Other]; !ok {
+			return nil, fmt.Errorf("the plural string %q is missing the other form", key)
+		}
+		table.strings[key] = entry
+	}
+	return table, nil
+}
+
+func (t *StringTable) lookup(key string, count int, isPlural bool) (string, bool) {
+	entry, ok := t.strings[key]
+	if !ok {
+		return "", false
+	}
+	if entry.plural == nil {
+		return entry.text, true
+	}
+	form := plural.Other
+	if isPlural {
+		n := max(count, -count)
+		form = plural.Cardinal.MatchPlural(t.tag, n, 0, 0, 0, 0)
+	}
+	if text, ok := entry.plural[form]; ok {
+		return text, true
+	}
+	return entry.plural[plural.Other], true
+}
+
+// Strings translates text through the string tables of the current locale
+// and its fallback chain. The tables are loaded from the asset database the
+// first time they are needed. Lookups are safe from any thread, the locale
+// should be changed from the main thread as the labels that use keys are
+// translated again from within [Strings.SetLocale].
+type Strings struct {
+	// OnLocaleChanged is executed after the locale has been changed and its
+	// tables have been loaded
+	OnLocaleChanged events.Event
+	db              assets.Database
+	locale          string
+	defaultLocale   string
+	chain           []*StringTable
+	added           []*StringTable
+	loaded          bool
+	mutex           sync.RWMutex
+}
+
+// NewStrings creates the string tables for the locale, the default locale is
+// the last in every fallback chain
+func NewStrings(db assets.Database, locale string) *Strings {
+	return &Strings{
+		db:            db,
+		locale:        normalizeLocalization(locale),
+		defaultLocale: defaultLocalization,
+	}
+}
+
+// Locale returns the locale that text is currently translated into
+func (s *Strings) Locale() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.locale
+}
+
+// SetDefaultLocale changes the locale that is searched when a key is not in
+// any other table of the chain, then executes [Strings.OnLocaleChanged] as the
+// text that fell back to the default may have changed
+func (s *Strings) SetDefaultLocale(locale string) {
+	s.mutex.Lock()
+	s.defaultLocale = normalizeLocalization(locale)
+	s.loadChain()
+	s.mutex.Unlock()
+	s.OnLocaleChanged.Execute()
+}
+
+// SetLocale loads the tables for the locale and its fallbacks, then
+// executes [Strings.OnLocaleChanged] so that the text using keys is
+// translated again
+func (s *Strings) SetLocale(locale string) {
+	s.mutex.Lock()
+	s.locale = normalizeLocalization(locale)
+	s.loadChain()
+	s.mutex.Unlock()
+	s.OnLocaleChanged.Execute()
+}
+
+// AddTable puts the table into the chain ahead of the loaded table for its
+// locale, this is mostly for tables that don't come from the asset database
+// such as those built by the game or by tests. The table is kept through
+// locale changes and is searched whenever its locale is part of the chain.
+func (s *Strings) AddTable(table *StringTable) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.added = append(s.added, table)
+	s.loadChain()
+}
+
+// Text returns the translation of the key, or the key itself when none of
+// the tables have it
+func (s *Strings) Text(key string) string {
+	return s.Format(key, nil)
+}
+
+// Format returns the translation of the key with the named placeholders,
+// such as {name}, replaced by the values in args
+func (s *Strings) Format(key string, args map[string]any) string {
+	text, ok := s.lookup(key, 0, false)
+	if !ok {
+		return key
+	}
+	return replacePlaceholders(text, args)
+}
+
+// Plural returns the translation of the key using the plural form of the
+// locale for the count. The count is available to the text as {count}.
+func (s *Strings) Plural(key string, count int, args map[string]any) string {
+	text, ok := s.lookup(key, count, true)
+	if !ok {
+		return key
+	}
+	if _, ok := args[CountArg]; !ok {
+		withCount := make(map[string]any, len(args)+1)
+		for k, v := range args {
+			withCount[k] = v
+		}
+		withCount[CountArg] = count
+		args = withCount
+	}
+	return replacePlaceholders(text, args)
+}
+
+// Has returns true if any of the tables in the chain have the key
+func (s *Strings) Has(key string) bool {
+	_, ok := s.lookup(key, 0, false)
+	return ok
+}
+
+func (s *Strings) lookup(key string, count int, isPlural bool) (string, bool) {
+	s.mutex.RLock()
+	if !s.loaded {
+		s.mutex.RUnlock()
+		s.mutex.Lock()
+		s.ensureLoaded()
+		s.mutex.Unlock()
+		s.mutex.RLock()
+	}
+	defer s.mutex.RUnlock()
+	for _, table := range s.chain {
+		if text, ok := table.lookup(key, count, isPlural); ok {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (s *Strings) ensureLoaded() {
+	if !s.loaded {
+		s.loadChain()
+	}
+}
+
+// loadChain loads the table of the locale, then follows each table's
+// fallback, then the parents of the locale and finally the default locale.
+// Locales without a table are skipped. The added tables of a locale come
+// before its loaded table, the most recently added first.
+func (s *Strings) loadChain() {
+	s.chain = s.chain[:0]
+	s.loaded = true
+	visited := map[string]bool{}
+	var visit func(locale string)
+	visit = func(locale string) {
+		if locale == "" || visited[locale] {
+			return
+		}
+		visited[locale] = true
+		tables := []*StringTable{}
+		for i := len(s.added) - 1; i >= 0; i-- {
+			if normalizeLocalization(s.added[i].Locale) == locale {
+				tables = append(tables, s.added[i])
+			}
+		}
+		if table := s.loadTable(locale); table != nil {
+			tables = append(tables, table)
+		}
+		s.chain = append(s.chain, tables...)
+		for _, table := range tables {
+			visit(table.Fallback)
+		}
+	}
+	for _, locale := range localeParents(s.locale) {
+		visit(locale)
+	}
+	visit(s.defaultLocale)
+}
+
+func (s *Strings) loadTable(locale string) *StringTable {
+	if s.db == nil {
+		return nil
+	}
+	key := StringTablePath + "/" + locale + ".json"
+	if !s.db.Exists(key) {
+		return nil
+	}
+	data, err := s.db.Read(key)
+	if err != nil {
+		slog.Error("failed to read the string table", "locale", locale, "error", err)
+		return nil
+	}
+	table, err := ParseStringTable(locale, data)
+	if err != nil {
+		slog.Error("failed to parse the string table", "locale", locale, "error", err)
+		return nil
+	}
+	return table
+}
+
+// localeParents returns the locale followed by the locales it falls back to
+// by dropping subtags, zh-Hant-TW gives zh-Hant-TW, zh-Hant and zh
+func localeParents(locale string) []string {
+	out := []string{locale}
+	for i := strings.LastIndex(locale, "-"); i > 0; i = strings.LastIndex(locale, "-") {
+		locale = locale[:i]
+		out = append(out, locale)
+	}
+	return out
+}
+
+// replacePlaceholders swaps each {name} in the text for the value of the
+// named argument, "{{" and "}}" are written as literal braces and unknown
+// placeholders are left as they are
+func replacePlaceholders(text string, args map[string]any) string {
+	if !strings.ContainsAny(text, "{}") {
+		return text
+	}
+	sb := strings.Builder{}
+	sb.Grow(len(text))
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if (c == '{' || c == '}') && i+1 < len(text) && text[i+1] == c {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		if c == '{' {
+			if end := strings.IndexByte(text[i:], '}'); end > 0 {
+				name := text[i+1 : i+end]
+				if v, ok := args[name]; ok {
+					fmt.Fprint(&sb, v)
+					i += end
+					continue
+				}
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
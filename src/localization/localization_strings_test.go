@@ -0,0 +1,173 @@
+/******************************************************************************/
+/* localization_strings_test.go                                               */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package localization
+
+import (
+	"testing"
+
+	"kaijuengine.com/engine/assets"
+)
+
+func testStringsDB() *assets.MockDatabase {
+	return assets.NewMockDB(map[string][]byte{
+		"localization/en-US.json": []byte(`{"strings": {
+			"greeting": "Hello {name}",
+			"quit": "Quit",
+			"apples": {"one": "{count} apple", "other": "{count} apples"}
+		}}`),
+		"localization/fr.json": []byte(`{"strings": {
+			"greeting": "Bonjour {name}",
+			"apples": {"one": "{count} pomme", "other": "{count} pommes"}
+		}}`),
+		"localization/fr-CA.json": []byte(`{"strings": {"greeting": "Allô {name}"}}`),
+		"localization/pt-BR.json": []byte(`{"fallback": "fr", "strings": {"quit": "Sair"}}`),
+		"localization/ru.json": []byte(`{"strings": {
+			"apples": {"one": "{count} яблоко", "few": "{count} яблока", "many": "{count} яблок", "other": "{count} яблока"}
+		}}`),
+	})
+}
+
+func TestStrings_FallbackChain(t *testing.T) {
+	s := NewStrings(testStringsDB(), "fr-CA")
+	tests := map[string]string{
+		"greeting": "Allô Ana", // fr-CA
+		"quit":     "Quit",     // en-US, the default
+		"missing":  "missing",  // the key itself
+	}
+	for key, want := range tests {
+		if got := s.Format(key, map[string]any{"name": "Ana"}); got != want {
+			t.Errorf("Format(%q) = %q, want %q", key, got, want)
+		}
+	}
+	if got := s.Plural("apples", 1, nil); got != "1 pomme" {
+		t.Errorf("Plural = %q, want the fr parent's text", got)
+	}
+}
+
+func TestStrings_ExplicitFallback(t *testing.T) {
+	s := NewStrings(testStringsDB(), "pt_BR.UTF-8")
+	if got := s.Text("quit"); got != "Sair" {
+		t.Errorf("quit = %q, want Sair", got)
+	}
+	if got := s.Format("greeting", map[string]any{"name": "Ana"}); got != "Bonjour Ana" {
+		t.Errorf("greeting = %q, want the file's fallback to be used", got)
+	}
+}
+
+func TestStrings_PluralRules(t *testing.T) {
+	s := NewStrings(testStringsDB(), "ru")
+	tests := map[int]string{1: "1 яблоко", 3: "3 яблока", 5: "5 яблок", 21: "21 яблоко"}
+	for count, want := range tests {
+		if got := s.Plural("apples", count, nil); got != want {
+			t.Errorf("Plural(%d) = %q, want %q", count, got, want)
+		}
+	}
+	s.SetLocale("fr")
+	// French treats 0 as singular, English does not
+	if got := s.Plural("apples", 0, nil); got != "0 pomme" {
+		t.Errorf("fr Plural(0) = %q, want 0 pomme", got)
+	}
+	s.SetLocale("en-US")
+	if got := s.Plural("apples", 0, nil); got != "0 apples" {
+		t.Errorf("en Plural(0) = %q, want 0 apples", got)
+	}
+}
+
+func TestStrings_SetLocaleNotifies(t *testing.T) {
+	s := NewStrings(testStringsDB(), "en-US")
+	text := s.Text("greeting")
+	s.OnLocaleChanged.Add(func() { text = s.Text("greeting") })
+	s.SetLocale("fr")
+	if s.Locale() != "fr" || text != "Bonjour {name}" {
+		t.Errorf("locale, text = %q, %q; want the text to be translated again", s.Locale(), text)
+	}
+}
+
+func TestStrings_SetDefaultLocaleNotifies(t *testing.T) {
+	s := NewStrings(testStringsDB(), "ru")
+	text := s.Text("greeting")
+	s.OnLocaleChanged.Add(func() { text = s.Text("greeting") })
+	s.SetDefaultLocale("fr")
+	if text != "Bonjour {name}" {
+		t.Errorf("text = %q, want the text of the new default locale", text)
+	}
+}
+
+func TestStrings_AddTable(t *testing.T) {
+	s := NewStrings(testStringsDB(), "en-US")
+	table, err := ParseStringTable("en-US", []byte(`{"strings": {"quit": "Exit"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddTable(table)
+	if got := s.Text("quit"); got != "Exit" {
+		t.Errorf("quit = %q, want the added table to come first", got)
+	}
+}
+
+func TestStrings_AddTableSurvivesLocaleChange(t *testing.T) {
+	s := NewStrings(testStringsDB(), "en-US")
+	table, err := ParseStringTable("en-US", []byte(`{"strings": {"quit": "Exit"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddTable(table)
+	s.SetLocale("pt-BR")
+	if got := s.Text("quit"); got != "Sair" {
+		t.Errorf("quit = %q, want the pt-BR table ahead of the added en-US table", got)
+	}
+	s.SetLocale("en-US")
+	if got := s.Text("quit"); got != "Exit" {
+		t.Errorf("quit = %q, want the added table after switching back", got)
+	}
+	s.SetDefaultLocale("fr")
+	if got := s.Text("quit"); got != "Exit" {
+		t.Errorf("quit = %q, want the added table after changing the default locale", got)
+	}
+}
+
+func TestParseStringTable_Errors(t *testing.T) {
+	bad := []string{
+		`{"strings": {"a": 5}}`,
+		`{"strings": {"a": {"one": "x"}}}`,
+		`{"strings": {"a": {"several": "x", "other": "y"}}}`,
+		`not json`,
+	}
+	for _, data := range bad {
+		if _, err := ParseStringTable("en-US", []byte(data)); err == nil {
+			t.Errorf("expected %s to fail to parse", data)
+		}
+	}
+}
+
+func TestReplacePlaceholders(t *testing.T) {
+	args := map[string]any{"name": "Ana", "n": 3}
+	tests := map[string]string{
+		"Hi {name}, {n} left": "Hi Ana, 3 left",
+		"{{name}}":            "{name}",
+		"{unknown} {name":     "{unknown} {name",
+		"plain":               "plain",
+	}
+	for in, want := range tests {
+		if got := replacePlaceholders(in, args); got != want {
+			t.Errorf("replacePlaceholders(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLocaleParents(t *testing.T) {
+	got := localeParents("zh-Hant-TW")
+	want := []string{"zh-Hant-TW", "zh-Hant", "zh"}
+	if len(got) != len(want) {
+		t.Fatalf("localeParents = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("localeParents = %v, want %v", got, want)
+		}
+	}
+}
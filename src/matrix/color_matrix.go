@@ -0,0 +1,138 @@
+/******************************************************************************/
+/* color_matrix.go                                                            */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package matrix
+
+// ColorMatrix is a 4x5 row major matrix that transforms a color, the last
+// column of each row is an offset added to the resulting channel. The
+// constructors follow the color matrices in the W3C Filter Effects spec.
+type ColorMatrix [20]float32
+
+func ColorMatrixIdentity() ColorMatrix {
+	return ColorMatrix{
+		1, 0, 0, 0, 0,
+		0, 1, 0, 0, 0,
+		0, 0, 1, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixBrightness(amount float32) ColorMatrix {
+	amount = max(amount, 0)
+	return ColorMatrix{
+		amount, 0, 0, 0, 0,
+		0, amount, 0, 0, 0,
+		0, 0, amount, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixContrast(amount float32) ColorMatrix {
+	amount = max(amount, 0)
+	offset := 0.5 - 0.5*amount
+	return ColorMatrix{
+		amount, 0, 0, 0, offset,
+		0, amount, 0, 0, offset,
+		0, 0, amount, 0, offset,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixGrayscale(amount float32) ColorMatrix {
+	a := 1 - Clamp(amount, 0, 1)
+	return ColorMatrix{
+		0.2126 + 0.7874*a, 0.7152 - 0.7152*a, 0.0722 - 0.0722*a, 0, 0,
+		0.2126 - 0.2126*a, 0.7152 + 0.2848*a, 0.0722 - 0.0722*a, 0, 0,
+		0.2126 - 0.2126*a, 0.7152 - 0.7152*a, 0.0722 + 0.9278*a, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixSepia(amount float32) ColorMatrix {
+	a := 1 - Clamp(amount, 0, 1)
+	return ColorMatrix{
+		0.393 + 0.607*a, 0.769 - 0.769*a, 0.189 - 0.189*a, 0, 0,
+		0.349 - 0.349*a, 0.686 + 0.314*a, 0.168 - 0.168*a, 0, 0,
+		0.272 - 0.272*a, 0.534 - 0.534*a, 0.131 + 0.869*a, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixInvert(amount float32) ColorMatrix {
+	a := Clamp(amount, 0, 1)
+	scale := 1 - 2*a
+	return ColorMatrix{
+		scale, 0, 0, 0, a,
+		0, scale, 0, 0, a,
+		0, 0, scale, 0, a,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixSaturate(amount float32) ColorMatrix {
+	s := max(amount, 0)
+	return ColorMatrix{
+		0.213 + 0.787*s, 0.715 - 0.715*s, 0.072 - 0.072*s, 0, 0,
+		0.213 - 0.213*s, 0.715 + 0.285*s, 0.072 - 0.072*s, 0, 0,
+		0.213 - 0.213*s, 0.715 - 0.715*s, 0.072 + 0.928*s, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+// ColorMatrixHueRotate rotates the hue of the color by the given number of
+// degrees
+func ColorMatrixHueRotate(degrees float32) ColorMatrix {
+	rad := Deg2Rad(degrees)
+	c, s := float32(Cos(rad)), float32(Sin(rad))
+	return ColorMatrix{
+		0.213 + c*0.787 - s*0.213, 0.715 - c*0.715 - s*0.715, 0.072 - c*0.072 + s*0.928, 0, 0,
+		0.213 - c*0.213 + s*0.143, 0.715 + c*0.285 + s*0.140, 0.072 - c*0.072 - s*0.283, 0, 0,
+		0.213 - c*0.213 - s*0.787, 0.715 - c*0.715 + s*0.715, 0.072 + c*0.928 + s*0.072, 0, 0,
+		0, 0, 0, 1, 0,
+	}
+}
+
+func ColorMatrixOpacity(amount float32) ColorMatrix {
+	a := Clamp(amount, 0, 1)
+	return ColorMatrix{
+		1, 0, 0, 0, 0,
+		0, 1, 0, 0, 0,
+		0, 0, 1, 0, 0,
+		0, 0, 0, a, 0,
+	}
+}
+
+func (m ColorMatrix) IsIdentity() bool { return m == ColorMatrixIdentity() }
+
+// Apply transforms the color by the matrix, the resulting channels are
+// clamped between 0 and 1
+func (m ColorMatrix) Apply(c Color) Color {
+	var out Color
+	for row := range 4 {
+		i := row * 5
+		v := m[i]*c[R] + m[i+1]*c[G] + m[i+2]*c[B] + m[i+3]*c[A] + m[i+4]
+		out[row] = min(max(v, 0), 1)
+	}
+	return out
+}
+
+// Then returns a matrix that applies this matrix followed by the next one
+func (m ColorMatrix) Then(next ColorMatrix) ColorMatrix {
+	var out ColorMatrix
+	for row := range 4 {
+		for col := range 5 {
+			var v float32
+			for k := range 4 {
+				v += next[row*5+k] * m[k*5+col]
+			}
+			if col == 4 {
+				v += next[row*5+4]
+			}
+			out[row*5+col] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+/******************************************************************************/
+/* color_matrix_test.go                                                       */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package matrix
+
+import "testing"
+
+func colorsNear(a, b Color) bool {
+	for i := range a {
+		if Abs(a[i]-b[i]) > 0.002 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestColorMatrixIdentityKeepsColor(t *testing.T) {
+	c := NewColor(0.2, 0.4, 0.6, 0.8)
+	if got := ColorMatrixIdentity().Apply(c); !colorsNear(got, c) {
+		t.Errorf("expected %v, got %v", c, got)
+	}
+	if !ColorMatrixBrightness(1).IsIdentity() {
+		t.Error("brightness(1) should be the identity")
+	}
+	if !colorsNear(ColorMatrixHueRotate(0).Apply(c), c) {
+		t.Error("hue-rotate(0deg) should keep the color")
+	}
+}
+
+func TestColorMatrixFunctions(t *testing.T) {
+	red := NewColor(1, 0, 0, 1)
+	tests := []struct {
+		name   string
+		m      ColorMatrix
+		in     Color
+		expect Color
+	}{
+		{"grayscale", ColorMatrixGrayscale(1), red, NewColor(0.2126, 0.2126, 0.2126, 1)},
+		{"grayscale none", ColorMatrixGrayscale(0), red, red},
+		{"invert", ColorMatrixInvert(1), red, NewColor(0, 1, 1, 1)},
+		{"invert half", ColorMatrixInvert(0.5), red, NewColor(0.5, 0.5, 0.5, 1)},
+		{"brightness", ColorMatrixBrightness(0.5), red, NewColor(0.5, 0, 0, 1)},
+		{"brightness clamps", ColorMatrixBrightness(3), red, red},
+		{"contrast", ColorMatrixContrast(0), red, NewColor(0.5, 0.5, 0.5, 1)},
+		{"opacity", ColorMatrixOpacity(0.25), red, NewColor(1, 0, 0, 0.25)},
+		{"saturate", ColorMatrixSaturate(0), red, NewColor(0.213, 0.213, 0.213, 1)},
+		{"sepia", ColorMatrixSepia(1), NewColor(1, 1, 1, 1), NewColor(1, 1, 0.937, 1)},
+		{"hue-rotate", ColorMatrixHueRotate(360), red, red},
+	}
+	for _, test := range tests {
+		if got := test.m.Apply(test.in); !colorsNear(got, test.expect) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expect, got)
+		}
+	}
+}
+
+func TestColorMatrixThen(t *testing.T) {
+	c := NewColor(0.9, 0.3, 0.1, 1)
+	a := ColorMatrixContrast(0.5)
+	b := ColorMatrixInvert(1)
+	expect := b.Apply(a.Apply(c))
+	if got := a.Then(b).Apply(c); !colorsNear(got, expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+	if got := a.Then(ColorMatrixIdentity()); got != a {
+		t.Errorf("composing with the identity changed the matrix: %v", got)
+	}
+}
@@ -7,8 +7,12 @@
 package master_server
 
 import (
+	"cmp"
+	"crypto/ed25519"
+	"crypto/subtle"
 	"log/slog"
 	"maps"
+	"slices"
 	"time"
 	"unsafe"
 
@@ -16,6 +20,7 @@ import (
 	"kaijuengine.com/engine"
 	"kaijuengine.com/klib"
 	"kaijuengine.com/network"
+	"kaijuengine.com/platform/concurrent"
 )
 
 const (
@@ -28,15 +33,59 @@ const (
 	gameNameSize  = 64
 )
 
+// Config holds the optional settings for a [MasterServer]
+type Config struct {
+	// RegistrationTokens are the shared secrets that a game server must
+	// present in order to register. When empty (and AuthorizeRegistration is
+	// nil) any server can register.
+	RegistrationTokens []string
+	// AuthorizeRegistration is a custom check for the token a game server
+	// registers with, for things like per-server tokens. It is used in
+	// addition to the RegistrationTokens.
+	AuthorizeRegistration func(token, address string) bool
+	// PersistPath is the file that the listings are saved to so that they
+	// survive a restart of the master server. Persistence is disabled when
+	// the path is empty.
+	PersistPath string
+	// Secure enables encrypted sessions with the master server, which keeps
+	// registration tokens from being sent in the clear. Clients must also set
+	// [MasterServerClient.Secure].
+	Secure bool
+	// IdentityKey signs the handshake of secure sessions, clients verify it
+	// with the public half set as [MasterServerClient.ServerIdentity]
+	IdentityKey ed25519.PrivateKey
+	// Matchmaking controls the quick-match queue of the lobby service, the
+	// zero value uses [DefaultMatchmakingConfig]
+	Matchmaking MatchmakingConfig
+}
+
 type MasterServer struct {
-	server     network.NetworkServer
-	serverList map[int]ServerListing
+	server         network.NetworkServer
+	config         Config
+	serverList     map[uint64]ServerListing
+	clientListings map[int]uint64
+	nextListingId  uint64
+	persistDirty   bool
+	persistAt      time.Time
+	rendezvous     map[int]rendezvous
+	nextRendezvous uint64
+	lobbies        *LobbyService
+	disconnects    concurrent.MessageQueue[*network.ServerClient]
+	send           func(message []byte, client *network.ServerClient) error
 }
 
 type ServerListing struct {
+	id             uint64
 	game           string
 	name           string
 	password       string
+	address        string
+	privateAddress string
+	gamePort       uint16
+	tags           map[string]string
+	// token is the registration token the listing was registered with, a
+	// lobby host presents it to start a lobby from another connection
+	token          string
 	client         *network.ServerClient
 	maxPlayers     uint16
 	currentPlayers uint16
@@ -44,32 +93,70 @@ type ServerListing struct {
 }
 
 func New(updater *engine.Updater) (*MasterServer, error) {
-	ms := &MasterServer{
-		server:     network.NewServerUDP(),
-		serverList: make(map[int]ServerListing),
-	}
+	return NewWithConfig(updater, Config{})
+}
+
+// NewWithConfig creates a master server that uses the given config, if the
+// config has a persist path the listings saved there are restored
+func NewWithConfig(updater *engine.Updater, config Config) (*MasterServer, error) {
+	ms := newMasterServer(config)
+	ms.server.Secure = config.Secure
+	ms.server.IdentityKey = config.IdentityKey
+	ms.server.OnClientDisconnected.Add(func(d network.ClientDisconnect) {
+		ms.disconnects.Enqueue(d.Client)
+	})
 	err := ms.server.Serve(updater, masterPort)
 	updater.AddUpdate(ms.update)
 	return ms, err
 }
 
+func newMasterServer(config Config) *MasterServer {
+	ms := &MasterServer{
+		server:         network.NewServerUDP(),
+		config:         config,
+		serverList:     make(map[uint64]ServerListing),
+		clientListings: make(map[int]uint64),
+		rendezvous:     make(map[int]rendezvous),
+		lobbies:        newLobbyService(config.Matchmaking),
+	}
+	ms.send = ms.server.SendMessageReliable
+	ms.lobbies.send = ms.sendResponse
+	ms.lobbies.ownsListing = ms.ownsListing
+	if config.PersistPath != "" {
+		if err := ms.restoreListings(time.Now()); err != nil {
+			slog.Error("failed to restore the master server listings", "path", config.PersistPath, "error", err)
+		}
+	}
+	return ms
+}
+
 func (m *MasterServer) update(float64) {
 	messages := m.server.ClientMessageQueue.Flush()
 	for i := range messages {
 		m.processMessage(messages[i])
 	}
-	m.evictUnresponsiveServers()
+	for _, client := range m.disconnects.Flush() {
+		m.lobbies.removeClient(client)
+	}
+	now := time.Now()
+	m.lobbies.matchPlayers(now)
+	m.evictUnresponsiveServers(now)
+	m.expireRendezvous(now)
+	m.persistIfDirty(now)
 }
 
-func (m *MasterServer) evictUnresponsiveServers() {
-	now := time.Now()
+func (m *MasterServer) evictUnresponsiveServers(now time.Time) {
 	keys := maps.Keys(m.serverList)
 	for k := range keys {
 		serv := m.serverList[k]
 		if serv.timeoutAt.Before(now) {
-			slog.Info("Game server has timed out", "address", serv.client.Address())
-			m.server.RemoveClient(serv.client)
+			slog.Info("Game server has timed out", "address", serv.address)
+			if serv.client != nil {
+				m.server.RemoveClient(serv.client)
+				delete(m.clientListings, serv.client.Id())
+			}
 			delete(m.serverList, k)
+			m.markDirty()
 		}
 	}
 }
@@ -80,7 +167,9 @@ func (m *MasterServer) processMessage(msg network.ClientMessage) {
 		return
 	}
 	req := DeserializeRequest(buffer)
-	if _, exists := m.serverList[msg.Client.Id()]; exists {
+	if isLobbyRequest(req.Type) {
+		m.lobbies.processRequest(req, msg.Client, time.Now())
+	} else if _, exists := m.clientListings[msg.Client.Id()]; exists {
 		m.processClientMessage(req, msg)
 	} else if req.Type == RequestTypeRegisterServer {
 		debug.Log("<- Register")
@@ -91,40 +180,115 @@ func (m *MasterServer) processMessage(msg network.ClientMessage) {
 }
 
 func (m *MasterServer) processClientMessage(req Request, msg network.ClientMessage) {
-	id := msg.Client.Id()
+	id := m.clientListings[msg.Client.Id()]
 	switch req.Type {
 	case RequestTypeUnregisterServer:
 		debug.Log("<- Unregister")
 		delete(m.serverList, id)
+		delete(m.clientListings, msg.Client.Id())
+		m.markDirty()
 	case RequestTypeRegisterServer:
+		// Registering again updates the details of the listing
+		serv := m.serverList[id]
+		serv.name = klib.ByteArrayToString(req.Name[:])
+		serv.password = klib.ByteArrayToString(req.Password[:])
+		serv.maxPlayers = req.MaxPlayers
+		serv.tags = TagsToMap(req.Tags[:])
+		serv.gamePort = req.GamePort
+		serv.privateAddress = klib.ByteArrayToString(req.PrivateAddress[:])
+		m.serverList[id] = serv
+		m.markDirty()
 		fallthrough
 	case RequestTypePing:
 		debug.Log("<- Ping")
 		serv := m.serverList[id]
+		if serv.currentPlayers != req.CurrentPlayers {
+			m.markDirty()
+		}
 		serv.currentPlayers = req.CurrentPlayers
 		serv.timeoutAt = time.Now().Add(serverTimeout)
 		m.serverList[id] = serv
+	case RequestTypeServerList, RequestTypeJoinServer, RequestTypeJoinResult:
+		m.processClientRequestMessage(req, msg)
 	}
 }
 
+func (m *MasterServer) authorize(token, address string) bool {
+	if len(m.config.RegistrationTokens) == 0 && m.config.AuthorizeRegistration == nil {
+		return true
+	}
+	for _, t := range m.config.RegistrationTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return m.config.AuthorizeRegistration != nil && m.config.AuthorizeRegistration(token, address)
+}
+
 func (m *MasterServer) processNewServer(req Request, msg network.ClientMessage) {
 	if req.Type != RequestTypeRegisterServer {
 		return
 	}
+	token := klib.ByteArrayToString(req.Token[:])
+	if !m.authorize(token, msg.Client.Address()) {
+		slog.Warn("rejected an unauthorized game server registration", "address", msg.Client.Address())
+		m.sendResponse(Response{Type: ResponseTypeError, Error: ErrorUnauthorized}, msg.Client)
+		return
+	}
 	err := m.sendResponse(Response{Type: ResponseTypeConfirmRegister}, msg.Client)
 	if err == nil {
 		listing := ServerListing{
-			game:       klib.ByteArrayToString(req.Game[:]),
-			name:       klib.ByteArrayToString(req.Name[:]),
-			password:   klib.ByteArrayToString(req.Password[:]),
-			client:     msg.Client,
-			maxPlayers: req.MaxPlayers,
-			timeoutAt:  time.Now().Add(serverTimeout),
+			game:           klib.ByteArrayToString(req.Game[:]),
+			name:           klib.ByteArrayToString(req.Name[:]),
+			password:       klib.ByteArrayToString(req.Password[:]),
+			address:        msg.Client.PortlessAddress(),
+			privateAddress: klib.ByteArrayToString(req.PrivateAddress[:]),
+			gamePort:       req.GamePort,
+			tags:           TagsToMap(req.Tags[:]),
+			token:          token,
+			client:         msg.Client,
+			maxPlayers:     req.MaxPlayers,
+			timeoutAt:      time.Now().Add(serverTimeout),
+		}
+		listing.id = m.adoptRestoredListing(&listing)
+		if listing.id == 0 {
+			m.nextListingId++
+			listing.id = m.nextListingId
 		}
-		m.serverList[msg.Client.Id()] = listing
+		m.serverList[listing.id] = listing
+		m.clientListings[msg.Client.Id()] = listing.id
+		m.markDirty()
 	}
 }
 
+// ownsListing is if the client is the connection that registered the listing
+// or presents the token the listing was registered with, so that a lobby host
+// can start their lobby on a game server that they registered through another
+// connection. Sharing an address with the game server isn't enough, as every
+// player behind the same NAT has that address.
+func (m *MasterServer) ownsListing(client *network.ServerClient, listingId uint64, token string) bool {
+	serv, ok := m.serverList[listingId]
+	if !ok || serv.client == nil {
+		return false
+	}
+	if id, ok := m.clientListings[client.Id()]; ok && id == listingId {
+		return true
+	}
+	return serv.token != "" && subtle.ConstantTimeCompare([]byte(serv.token), []byte(token)) == 1
+}
+
+// adoptRestoredListing finds the listing that was restored from disk for the
+// server that is registering again, so that it keeps the same id
+func (m *MasterServer) adoptRestoredListing(listing *ServerListing) uint64 {
+	for id, serv := range m.serverList {
+		if serv.client == nil && serv.game == listing.game &&
+			serv.name == listing.name && serv.address == listing.address {
+			return id
+		}
+	}
+	return 0
+}
+
 func (m *MasterServer) processClientRequestMessage(req Request, msg network.ClientMessage) {
 	switch req.Type {
 	case RequestTypeServerList:
@@ -132,58 +296,60 @@ func (m *MasterServer) processClientRequestMessage(req Request, msg network.Clie
 		m.sendServerList(req, msg)
 	case RequestTypeJoinServer:
 		debug.Log("<- Join server")
-		if serv, ok := m.serverList[int(req.ServerId)]; ok {
-			if serv.password == klib.ByteArrayToString(req.Password[:]) {
-				res := Response{Type: ResponseTypeJoinServerInfo}
-				copy(res.Address[:], serv.client.PortlessAddress())
-				m.sendResponse(res, msg.Client)
-				servRes := Response{Type: ResponseTypeClientJoinInfo}
-				copy(servRes.Address[:], msg.Client.PortlessAddress())
-				m.sendResponse(servRes, serv.client)
-			} else {
-				m.sendResponse(Response{Type: ResponseTypeError, Error: ErrorIncorrectPassword}, msg.Client)
-			}
-		} else {
-			m.sendResponse(Response{Type: ResponseTypeError, Error: ErrorServerDoesntExist}, msg.Client)
+		m.processJoinServer(req, msg)
+	case RequestTypeJoinResult:
+		debug.Log("<- Join result")
+		m.processJoinResult(req, msg)
+	}
+}
+
+// queryListings returns the listings for the game that pass the filters, in
+// a stable order so that pages line up between requests
+func (m *MasterServer) queryListings(game string, filters []Filter) []ServerListing {
+	matches := make([]ServerListing, 0, len(m.serverList))
+	for _, serv := range m.serverList {
+		if serv.game == game && serv.matches(filters) {
+			matches = append(matches, serv)
 		}
 	}
+	slices.SortFunc(matches, func(a, b ServerListing) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	return matches
 }
 
 func (m *MasterServer) sendServerList(req Request, msg network.ClientMessage) {
-	count := 0
-	var res Response
-	game := klib.ByteArrayToString(req.Game[:])
-	totalCount := uint32(0)
-	for k := range m.serverList {
-		if m.serverList[k].game == game {
-			totalCount++
-		}
+	matches := m.queryListings(klib.ByteArrayToString(req.Game[:]), req.Filters[:])
+	sendListingPages(ResponseTypeServerList, matches, req, func(res Response) {
+		m.sendResponse(res, msg.Client)
+	})
+}
+
+// sendListingPages sends the page of listings selected by the request's offset
+// and limit, split over as many responses as it takes. At least one response
+// is always sent so that an empty result is still answered.
+func sendListingPages(resType MasterServerResponseType, matches []ServerListing, req Request, send func(Response)) {
+	totalCount := uint32(len(matches))
+	page := matches[min(int(req.Offset), len(matches)):]
+	if req.Limit > 0 {
+		page = page[:min(int(req.Limit), len(page))]
 	}
-	for k := range m.serverList {
-		if count == 0 {
-			res = Response{
-				Type:      ResponseTypeServerList,
-				TotalList: totalCount,
-			}
+	for start := 0; start < len(page) || start == 0; start += serversPerResponse {
+		res := Response{
+			Type:      resType,
+			TotalList: totalCount,
+			Offset:    req.Offset + uint32(start),
 		}
-		serv := m.serverList[k]
-		if serv.game != game {
-			continue
-		}
-		res.List[count] = ResponseServerList{
-			Id:             uint64(serv.client.Id()),
-			MaxPlayers:     serv.maxPlayers,
-			CurrentPlayers: serv.currentPlayers,
-		}
-		copy(res.List[count].Name[:], serv.name)
-		count++
-		if count == len(res.List) {
-			m.sendResponse(res, msg.Client)
-			count = 0
+		for i, serv := range page[start:min(start+serversPerResponse, len(page))] {
+			res.List[i] = ResponseServerList{
+				Id:             serv.id,
+				MaxPlayers:     serv.maxPlayers,
+				CurrentPlayers: serv.currentPlayers,
+				Tags:           TagsFromMap(serv.tags),
+			}
+			copy(res.List[i].Name[:], serv.name)
 		}
-	}
-	if count != 0 {
-		m.sendResponse(res, msg.Client)
+		send(res)
 	}
 }
 
@@ -197,10 +363,19 @@ func (m *MasterServer) sendResponse(res Response, client *network.ServerClient)
 		debug.Log("-> Join server info")
 	case ResponseTypeClientJoinInfo:
 		debug.Log("-> Client join info")
+	case ResponseTypeLobbyList:
+		debug.Log("-> Lobby list")
+	case ResponseTypeLobbyUpdate:
+		debug.Log("-> Lobby update")
+	case ResponseTypeLobbyChat:
+		debug.Log("-> Lobby chat")
+	case ResponseTypeLobbyStart:
+		debug.Log("-> Lobby start")
+	case ResponseTypeMatchFound:
+		debug.Log("-> Match found")
 	case ResponseTypeError:
 		debug.Log("-> Error", "error", res.Error)
 	}
-	buff := [unsafe.Sizeof(Response{})]byte{}
-	res.Serialize(buff[:])
-	return m.server.SendMessageReliable(buff[:], client)
+	buff := [maxResponseSize]byte{}
+	return m.send(buff[:res.serialize(buff[:])], client)
 }
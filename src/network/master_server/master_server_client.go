@@ -7,7 +7,10 @@
 package master_server
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"log/slog"
+	"time"
 	"unsafe"
 
 	"kaijuengine.com/debug"
@@ -25,10 +28,45 @@ type MasterServerClient struct {
 	pingTime     float64
 	updateId     engine.UpdateId
 	isServer     bool
+	registration Request
+	// Token is presented when registering a server, it must match one of the
+	// master server's [Config.RegistrationTokens]
+	Token string
+	// Secure enables an encrypted session with the master server, it must
+	// match the master server's [Config.Secure]
+	Secure bool
+	// ServerIdentity is the public half of the master server's
+	// [Config.IdentityKey], it is required when Secure is set
+	ServerIdentity ed25519.PublicKey
+	// GamePort is the port of the game socket. A game server registers the
+	// port it is listening on, a joining client connects from this port or
+	// from a free one when it is 0.
+	GamePort uint16
+	// GameServer is the game server that hole-punches to the clients that the
+	// master server introduces, when nil OnClientJoin is left to handle it
+	GameServer   *network.NetworkServer
 	OnServerList func([]ResponseServerList, uint32)
 	OnServerJoin func(string)
 	OnClientJoin func(string)
+	// OnRendezvous is called with the game server's endpoints after a join,
+	// they are meant to be passed to [MasterServerClient.ConnectRendezvous]
+	OnRendezvous func(Rendezvous)
 	OnError      func(uint8)
+	// PlayerName, Skill and Region describe this player to the lobbies and
+	// the quick-match queue
+	PlayerName string
+	Skill      uint32
+	Region     string
+	// The lobby callbacks are optional, see [MasterServerClient.CreateLobby]
+	OnLobbyList   func([]ResponseServerList, uint32)
+	OnLobbyUpdate func(ResponseLobby)
+	OnLobbyChat   func(sender, message string)
+	OnLobbyStart  func(ResponseLobby)
+	OnMatchFound  func(ResponseLobby)
+	joinPort      uint16
+	join          *rendezvousJoin
+	punches       []holePunch
+	punch         func(target *network.ServerClient) error
 }
 
 func (c *MasterServerClient) Connect(updater *engine.Updater) error {
@@ -36,6 +74,8 @@ func (c *MasterServerClient) Connect(updater *engine.Updater) error {
 		c.Disconnect(updater)
 	}
 	c.client = network.NewClientUDP()
+	c.client.Secure = c.Secure
+	c.client.ServerIdentity = c.ServerIdentity
 	if c.OnServerList == nil {
 		c.OnServerList = func([]ResponseServerList, uint32) {}
 	}
@@ -65,26 +105,61 @@ func (c *MasterServerClient) Disconnect(updater *engine.Updater) {
 }
 
 func (c *MasterServerClient) RegisterServer(game, name string, maxPlayers, currentPlayers uint16) error {
+	return c.RegisterServerWithTags(game, name, maxPlayers, currentPlayers, nil)
+}
+
+// RegisterServerWithTags registers the server along with tags (such as the
+// map, mode or region) that clients can filter the server list by
+func (c *MasterServerClient) RegisterServerWithTags(game, name string, maxPlayers, currentPlayers uint16, tags map[string]string) error {
 	debug.Log("Registering server with master server")
 	req := Request{
 		Type:           RequestTypeRegisterServer,
 		MaxPlayers:     maxPlayers,
 		CurrentPlayers: currentPlayers,
+		Tags:           TagsFromMap(tags),
 	}
 	copy(req.Game[:], game)
 	copy(req.Name[:], name)
+	copy(req.Token[:], c.Token)
+	req.GamePort = c.GamePort
+	copy(req.PrivateAddress[:], c.privateAddress())
 	c.isServer = true
+	c.registration = req
 	return c.sendRequest(req)
 }
 
+// UpdateTags replaces the tags of the registered server
+func (c *MasterServerClient) UpdateTags(tags map[string]string) error {
+	if !c.isServer {
+		return errors.New("the server has not been registered")
+	}
+	c.registration.Tags = TagsFromMap(tags)
+	return c.sendRequest(c.registration)
+}
+
 func (c *MasterServerClient) ListServers(game string) error {
-	req := Request{Type: RequestTypeServerList}
+	return c.QueryServers(game, ServerQuery{})
+}
+
+// QueryServers requests the page of servers for the game that pass all of
+// the filters in the query, [MasterServerClient.OnServerList] is called with
+// the results
+func (c *MasterServerClient) QueryServers(game string, query ServerQuery) error {
+	if len(query.Filters) > MaxFilters {
+		return errors.New("too many filters in the server query")
+	}
+	req := Request{
+		Type:   RequestTypeServerList,
+		Offset: query.Offset,
+		Limit:  query.Limit,
+	}
 	copy(req.Game[:], game)
+	copy(req.Filters[:], query.Filters)
 	return c.sendRequest(req)
 }
 
 func (c *MasterServerClient) JoinServer(id uint64) error {
-	return c.sendRequest(Request{Type: RequestTypeJoinServer, ServerId: id})
+	return c.JoinServerWithPassword(id, "")
 }
 
 func (c *MasterServerClient) update(deltaTime float64) {
@@ -95,10 +170,12 @@ func (c *MasterServerClient) update(deltaTime float64) {
 			c.sendRequest(Request{Type: RequestTypePing})
 		}
 	}
+	c.updatePunches(time.Now())
+	c.updateJoin()
 	messages := c.client.ServerMessageQueue.Flush()
 	for i := range messages {
 		buff := messages[i].Message()
-		if len(buff) != int(unsafe.Sizeof(Response{})) {
+		if len(buff) == 0 || len(buff) > maxResponseSize {
 			continue
 		}
 		c.processMessage(messages[i])
@@ -116,9 +193,16 @@ func (c *MasterServerClient) processMessage(msg network.ClientMessage) {
 	case ResponseTypeJoinServerInfo:
 		debug.Log("<- Join server")
 		c.OnServerJoin(klib.ByteArrayToString(res.Address[:]))
+		if c.OnRendezvous != nil {
+			c.OnRendezvous(rendezvousFromResponse(res, c.joinPort))
+		}
 	case ResponseTypeClientJoinInfo:
 		debug.Log("<- Client join")
 		c.OnClientJoin(klib.ByteArrayToString(res.Address[:]))
+		c.punchClient(rendezvousFromResponse(res, 0), time.Now())
+	case ResponseTypeLobbyList, ResponseTypeLobbyUpdate, ResponseTypeLobbyChat,
+		ResponseTypeLobbyStart, ResponseTypeMatchFound:
+		c.processLobbyMessage(res)
 	case ResponseTypeError:
 		debug.Log("<- Error", "error", res.Error)
 		c.OnError(res.Error)
@@ -137,6 +221,12 @@ func (c *MasterServerClient) sendRequest(req Request) error {
 		debug.Log("-> Server list")
 	case RequestTypeJoinServer:
 		debug.Log("-> Connect to server")
+	case RequestTypeJoinResult:
+		debug.Log("-> Join result")
+	default:
+		if isLobbyRequest(req.Type) {
+			debug.Log("-> Lobby", "type", req.Type)
+		}
 	}
 	buff := [unsafe.Sizeof(Request{})]byte{}
 	req.Serialize(buff[:])
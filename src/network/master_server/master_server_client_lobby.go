@@ -0,0 +1,136 @@
+/******************************************************************************/
+/* master_server_client_lobby.go                                              */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"errors"
+
+	"kaijuengine.com/debug"
+	"kaijuengine.com/klib"
+)
+
+// CreateLobby creates a lobby that this client hosts. Every member is sent
+// the state of the lobby through [MasterServerClient.OnLobbyUpdate] whenever
+// it changes. Once everyone is ready, the host registers a game server and
+// calls [MasterServerClient.StartLobby] with its listing id.
+func (c *MasterServerClient) CreateLobby(game, name, password string, maxPlayers uint16, tags map[string]string) error {
+	if len(password) > MaxPasswordSize {
+		return errors.New("the lobby password is too long")
+	}
+	req := c.lobbyRequest(RequestTypeCreateLobby)
+	req.MaxPlayers = maxPlayers
+	req.Tags = TagsFromMap(tags)
+	copy(req.Game[:], game)
+	copy(req.Name[:], name)
+	copy(req.Password[:], password)
+	return c.sendRequest(req)
+}
+
+// ListLobbies requests the page of lobbies for the game that pass all of the
+// filters in the query, the region of a lobby can be filtered on with
+// [TagKeyRegion]. [MasterServerClient.OnLobbyList] is called with the results.
+func (c *MasterServerClient) ListLobbies(game string, query ServerQuery) error {
+	if len(query.Filters) > MaxFilters {
+		return errors.New("too many filters in the lobby query")
+	}
+	req := c.lobbyRequest(RequestTypeListLobbies)
+	req.Offset = query.Offset
+	req.Limit = query.Limit
+	copy(req.Game[:], game)
+	copy(req.Filters[:], query.Filters)
+	return c.sendRequest(req)
+}
+
+func (c *MasterServerClient) JoinLobby(id uint64, password string) error {
+	req := c.lobbyRequest(RequestTypeJoinLobby)
+	req.LobbyId = id
+	copy(req.Password[:], password)
+	return c.sendRequest(req)
+}
+
+func (c *MasterServerClient) LeaveLobby() error {
+	return c.sendRequest(c.lobbyRequest(RequestTypeLeaveLobby))
+}
+
+func (c *MasterServerClient) SetLobbyReady(ready bool) error {
+	req := c.lobbyRequest(RequestTypeLobbyReady)
+	req.Ready = ready
+	return c.sendRequest(req)
+}
+
+// SendLobbyChat sends a line of chat to every member of the lobby, messages
+// longer than [MaxChatSize] bytes are cut short
+func (c *MasterServerClient) SendLobbyChat(message string) error {
+	req := c.lobbyRequest(RequestTypeLobbyChat)
+	copy(req.Message[:], message)
+	return c.sendRequest(req)
+}
+
+// StartLobby promotes the lobby into a game session on the game server with
+// the listing id. The listing must have been registered by this connection,
+// or with the same [MasterServerClient.Token] that is sent along with this
+// request. Every member is sent the listing id through
+// [MasterServerClient.OnLobbyStart] so that they can join it, and the lobby
+// is closed.
+func (c *MasterServerClient) StartLobby(listingId uint64) error {
+	req := c.lobbyRequest(RequestTypeStartLobby)
+	req.ServerId = listingId
+	copy(req.Token[:], c.Token)
+	return c.sendRequest(req)
+}
+
+// QuickMatch puts this player in the queue for a match of the given size with
+// players of a similar skill in the same region. The skill range and region
+// are relaxed the longer the player waits. When a match is made a lobby is
+// created for it and [MasterServerClient.OnMatchFound] is called.
+func (c *MasterServerClient) QuickMatch(game string, matchSize uint16) error {
+	req := c.lobbyRequest(RequestTypeQuickMatch)
+	req.MaxPlayers = matchSize
+	copy(req.Game[:], game)
+	return c.sendRequest(req)
+}
+
+func (c *MasterServerClient) CancelQuickMatch() error {
+	return c.sendRequest(c.lobbyRequest(RequestTypeCancelQuickMatch))
+}
+
+func (c *MasterServerClient) lobbyRequest(reqType MasterServerRequestType) Request {
+	req := Request{Type: reqType, Skill: c.Skill}
+	copy(req.PlayerName[:], c.PlayerName)
+	copy(req.Region[:], c.Region)
+	return req
+}
+
+func (c *MasterServerClient) processLobbyMessage(res Response) {
+	switch res.Type {
+	case ResponseTypeLobbyList:
+		debug.Log("<- Lobby list")
+		if c.OnLobbyList != nil {
+			c.OnLobbyList(res.List[:], res.TotalList)
+		}
+	case ResponseTypeLobbyUpdate:
+		debug.Log("<- Lobby update")
+		if c.OnLobbyUpdate != nil {
+			c.OnLobbyUpdate(res.Lobby)
+		}
+	case ResponseTypeLobbyChat:
+		debug.Log("<- Lobby chat")
+		if c.OnLobbyChat != nil {
+			c.OnLobbyChat(klib.ByteArrayToString(res.Sender[:]), klib.ByteArrayToString(res.Message[:]))
+		}
+	case ResponseTypeLobbyStart:
+		debug.Log("<- Lobby start")
+		if c.OnLobbyStart != nil {
+			c.OnLobbyStart(res.Lobby)
+		}
+	case ResponseTypeMatchFound:
+		debug.Log("<- Match found")
+		if c.OnMatchFound != nil {
+			c.OnMatchFound(res.Lobby)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+/******************************************************************************/
+/* master_server_client_rendezvous.go                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"kaijuengine.com/debug"
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/events"
+	"kaijuengine.com/klib"
+	"kaijuengine.com/network"
+)
+
+// punchInterval is how often the game server sends a hole-punch packet to a
+// joining client while the rendezvous lasts
+const punchInterval = time.Millisecond * 100
+
+const (
+	joinOutcomePending = int32(iota)
+	joinOutcomeReached
+	joinOutcomeRetry
+)
+
+// Rendezvous holds the endpoints of the game server that the master server
+// introduced this client to, see [MasterServerClient.ConnectRendezvous]
+type Rendezvous struct {
+	Id              uint64
+	Address         string
+	Port            uint16
+	FallbackAddress string
+	FallbackPort    uint16
+	// LocalPort is the port the master server was told this client connects
+	// from, it is the port that the game server is punching towards
+	LocalPort uint16
+}
+
+type holePunch struct {
+	targets []*network.ServerClient
+	nextAt  time.Time
+	endsAt  time.Time
+}
+
+type rendezvousJoin struct {
+	rv             Rendezvous
+	attempt        int
+	outcome        atomic.Int32
+	connect        func(address string, port uint16) error
+	close          func()
+	connectedId    events.Id
+	disconnectedId events.Id
+	removeEvents   func()
+}
+
+type endpoint struct {
+	address string
+	port    uint16
+}
+
+func (r Rendezvous) endpoints() []endpoint {
+	out := []endpoint{{r.Address, r.Port}}
+	if r.FallbackAddress != "" && (r.FallbackAddress != r.Address || r.FallbackPort != r.Port) {
+		out = append(out, endpoint{r.FallbackAddress, r.FallbackPort})
+	}
+	return out
+}
+
+func rendezvousFromResponse(res Response, localPort uint16) Rendezvous {
+	return Rendezvous{
+		Id:              res.RendezvousId,
+		Address:         klib.ByteArrayToString(res.Address[:]),
+		Port:            res.Port,
+		FallbackAddress: klib.ByteArrayToString(res.FallbackAddress[:]),
+		FallbackPort:    res.FallbackPort,
+		LocalPort:       localPort,
+	}
+}
+
+// JoinServerWithPassword asks the master server to introduce this client to
+// the server, [MasterServerClient.OnRendezvous] is called with the endpoints
+// to pass to [MasterServerClient.ConnectRendezvous]
+func (c *MasterServerClient) JoinServerWithPassword(id uint64, password string) error {
+	if c.join != nil {
+		return errors.New("the previous join has not finished")
+	}
+	port := c.GamePort
+	if port == 0 {
+		var err error
+		if port, err = reserveLocalPort(); err != nil {
+			slog.Error("failed to find a free local port to join the server from", "error", err)
+			return err
+		}
+	}
+	c.joinPort = port
+	req := Request{Type: RequestTypeJoinServer, ServerId: id, GamePort: port}
+	copy(req.Password[:], password)
+	copy(req.PrivateAddress[:], c.privateAddress())
+	return c.sendRequest(req)
+}
+
+// ConnectRendezvous connects the game client to the server that the master
+// server introduced, from the local port that the server is punching
+// towards. The connection requests themselves punch through this side's NAT.
+// If the first endpoint can't be reached the fallback endpoint is tried, and
+// when neither can be reached [MasterServerClient.OnError] is called with
+// [ErrorHolePunchFailed]. The outcome is reported back to the master server.
+func (c *MasterServerClient) ConnectRendezvous(updater *engine.Updater, client *network.NetworkClient, rv Rendezvous) error {
+	if c.join != nil {
+		return errors.New("already connecting to a rendezvous")
+	}
+	j := &rendezvousJoin{rv: rv}
+	j.connect = func(address string, port uint16) error {
+		client.LocalPort = rv.LocalPort
+		return client.Connect(updater, address, port)
+	}
+	j.close = func() { client.Close(updater) }
+	j.connectedId = client.OnConnected.Add(func() {
+		j.outcome.Store(joinOutcomeReached)
+	})
+	j.disconnectedId = client.OnDisconnected.Add(func(reason network.DisconnectReason) {
+		if reason == network.DisconnectReasonConnectFailed {
+			j.outcome.Store(joinOutcomeRetry)
+		} else {
+			// A rejection still means the server was reached
+			j.outcome.Store(joinOutcomeReached)
+		}
+	})
+	j.removeEvents = func() {
+		client.OnConnected.Remove(j.connectedId)
+		client.OnDisconnected.Remove(j.disconnectedId)
+	}
+	c.join = j
+	return c.connectJoin()
+}
+
+// connectJoin tries the endpoints from the current attempt onwards until one
+// of them can at least be dialed
+func (c *MasterServerClient) connectJoin() error {
+	j := c.join
+	endpoints := j.rv.endpoints()
+	var err error
+	for ; j.attempt < len(endpoints); j.attempt++ {
+		ep := endpoints[j.attempt]
+		debug.Log("Connecting to the rendezvous", "address", ep.address, "port", ep.port)
+		if err = j.connect(ep.address, ep.port); err == nil {
+			return nil
+		}
+	}
+	c.finishJoin(ErrorHolePunchFailed)
+	return err
+}
+
+func (c *MasterServerClient) updateJoin() {
+	j := c.join
+	if j == nil {
+		return
+	}
+	switch j.outcome.Swap(joinOutcomePending) {
+	case joinOutcomeReached:
+		c.finishJoin(ErrorNone)
+	case joinOutcomeRetry:
+		j.close()
+		j.attempt++
+		c.connectJoin()
+	}
+}
+
+func (c *MasterServerClient) finishJoin(errCode Error) {
+	j := c.join
+	c.join = nil
+	j.removeEvents()
+	c.sendRequest(Request{Type: RequestTypeJoinResult, RendezvousId: j.rv.Id, JoinError: errCode})
+	if errCode != ErrorNone {
+		slog.Warn("failed to reach the game server through its NAT", "address", j.rv.Address, "port", j.rv.Port)
+		c.OnError(errCode)
+	}
+}
+
+// punchClient starts sending hole-punch packets from the game server to the
+// joining client's endpoints for as long as the rendezvous lasts
+func (c *MasterServerClient) punchClient(rv Rendezvous, now time.Time) {
+	if c.GameServer == nil {
+		return
+	}
+	hp := holePunch{nextAt: now, endsAt: now.Add(rendezvousTimeout)}
+	for _, ep := range rv.endpoints() {
+		target, err := c.GameServer.HolePunchClient(ep.address, ep.port)
+		if err != nil {
+			slog.Warn("failed to resolve the joining client's endpoint", "address", ep.address, "error", err)
+			continue
+		}
+		hp.targets = append(hp.targets, target)
+	}
+	if len(hp.targets) > 0 {
+		c.punches = append(c.punches, hp)
+	}
+}
+
+func (c *MasterServerClient) updatePunches(now time.Time) {
+	for i := 0; i < len(c.punches); i++ {
+		hp := &c.punches[i]
+		if now.After(hp.endsAt) {
+			c.punches = klib.RemoveUnordered(c.punches, i)
+			i--
+			continue
+		}
+		if now.Before(hp.nextAt) {
+			continue
+		}
+		hp.nextAt = now.Add(punchInterval)
+		for _, target := range hp.targets {
+			c.sendPunch(target)
+		}
+	}
+}
+
+func (c *MasterServerClient) sendPunch(target *network.ServerClient) {
+	send := c.punch
+	if send == nil {
+		send = c.GameServer.SendHolePunch
+	}
+	if err := send(target); err != nil {
+		slog.Warn("failed to send the hole-punch", "address", target.Address(), "error", err)
+	}
+}
+
+func (c *MasterServerClient) privateAddress() string {
+	if addr := c.client.LocalAddress(); addr != nil {
+		return addr.IP.String()
+	}
+	return ""
+}
+
+// reserveLocalPort asks the system for a free UDP port, it is released again
+// straight away so that the game client can bind to it
+func reserveLocalPort() (uint16, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port), nil
+}
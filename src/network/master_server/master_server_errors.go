@@ -12,4 +12,30 @@ const (
 	ErrorNone = Error(iota)
 	ErrorIncorrectPassword
 	ErrorServerDoesntExist
+	ErrorUnauthorized
+	// ErrorServerFull is when the server to join has no free player slots
+	ErrorServerFull
+	// ErrorJoinPending is when the client asks to join while its previous
+	// join is still being set up
+	ErrorJoinPending
+	// ErrorServerUnreachable is when the server to join is listed but has no
+	// live connection or game port, so it can't be told about the client
+	ErrorServerUnreachable
+	// ErrorHolePunchFailed is when none of the server's endpoints could be
+	// reached after hole-punching, this is raised by the joining client
+	ErrorHolePunchFailed
+	ErrorLobbyDoesntExist
+	ErrorLobbyFull
+	// ErrorNotInLobby is when a lobby request is sent by a client that is not
+	// a member of any lobby
+	ErrorNotInLobby
+	// ErrorNotLobbyHost is when a request that only the host can make is sent
+	// by another member of the lobby
+	ErrorNotLobbyHost
+	// ErrorAlreadyInLobby is when a client that is in a lobby (or the
+	// quick-match queue) tries to create, join or queue for another
+	ErrorAlreadyInLobby
+	// ErrorPlayersNotReady is when the host starts a lobby before every
+	// member is ready
+	ErrorPlayersNotReady
 )
@@ -0,0 +1,313 @@
+/******************************************************************************/
+/* master_server_lobby.go                                                     */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"kaijuengine.com/debug"
+	"kaijuengine.com/klib"
+	"kaijuengine.com/network"
+)
+
+// TagKeyRegion is the tag that a lobby's region is listed under, so lobby
+// lists can be filtered by region like any other tag
+const TagKeyRegion = "region"
+
+// LobbyService holds the lobbies and the quick-match queue. It runs alongside
+// the [MasterServer] and shares its connection, the lobby requests are
+// handed to it by the master server.
+type LobbyService struct {
+	config      MatchmakingConfig
+	lobbies     map[uint64]*lobby
+	clientLobby map[int]uint64
+	queue       []queueEntry
+	nextLobbyId uint64
+	ownsListing func(client *network.ServerClient, listingId uint64, token string) bool
+	send        func(res Response, client *network.ServerClient) error
+}
+
+// lobby is a group of players waiting to play together. Quick-match lobbies
+// are made by the matchmaker for the players it matched, so they aren't
+// listed and can't be joined.
+type lobby struct {
+	id         uint64
+	game       string
+	name       string
+	password   string
+	region     string
+	tags       map[string]string
+	maxPlayers uint16
+	members    []lobbyMember
+	quickMatch bool
+}
+
+type lobbyMember struct {
+	client *network.ServerClient
+	name   string
+	skill  uint32
+	ready  bool
+}
+
+func newLobbyService(config MatchmakingConfig) *LobbyService {
+	if config == (MatchmakingConfig{}) {
+		config = DefaultMatchmakingConfig()
+	}
+	return &LobbyService{
+		config:      config,
+		lobbies:     make(map[uint64]*lobby),
+		clientLobby: make(map[int]uint64),
+	}
+}
+
+func isLobbyRequest(t MasterServerRequestType) bool {
+	return t >= RequestTypeCreateLobby && t <= RequestTypeCancelQuickMatch
+}
+
+func (s *LobbyService) processRequest(req Request, client *network.ServerClient, now time.Time) {
+	errCode := ErrorNone
+	switch req.Type {
+	case RequestTypeCreateLobby:
+		debug.Log("<- Create lobby")
+		errCode = s.createLobby(req, client)
+	case RequestTypeListLobbies:
+		debug.Log("<- List lobbies")
+		s.listLobbies(req, client)
+	case RequestTypeJoinLobby:
+		debug.Log("<- Join lobby")
+		errCode = s.joinLobby(req, client)
+	case RequestTypeLeaveLobby:
+		debug.Log("<- Leave lobby")
+		errCode = s.leaveLobby(client)
+	case RequestTypeLobbyReady:
+		debug.Log("<- Lobby ready")
+		errCode = s.setReady(client, req.Ready)
+	case RequestTypeLobbyChat:
+		debug.Log("<- Lobby chat")
+		errCode = s.chat(client, req.Message)
+	case RequestTypeStartLobby:
+		debug.Log("<- Start lobby")
+		errCode = s.startLobby(client, req.ServerId, klib.ByteArrayToString(req.Token[:]))
+	case RequestTypeQuickMatch:
+		debug.Log("<- Quick match")
+		errCode = s.enqueue(req, client, now)
+	case RequestTypeCancelQuickMatch:
+		debug.Log("<- Cancel quick match")
+		s.dequeue(client)
+	}
+	if errCode != ErrorNone {
+		s.send(Response{Type: ResponseTypeError, Error: errCode}, client)
+	}
+}
+
+func (s *LobbyService) isBusy(client *network.ServerClient) bool {
+	_, inLobby := s.clientLobby[client.Id()]
+	return inLobby || s.queueIndex(client) >= 0
+}
+
+func newLobbyMember(req Request, client *network.ServerClient) lobbyMember {
+	return lobbyMember{
+		client: client,
+		name:   klib.ByteArrayToString(req.PlayerName[:]),
+		skill:  req.Skill,
+	}
+}
+
+func (s *LobbyService) createLobby(req Request, client *network.ServerClient) Error {
+	if s.isBusy(client) {
+		return ErrorAlreadyInLobby
+	}
+	s.nextLobbyId++
+	l := &lobby{
+		id:         s.nextLobbyId,
+		game:       klib.ByteArrayToString(req.Game[:]),
+		name:       klib.ByteArrayToString(req.Name[:]),
+		password:   klib.ByteArrayToString(req.Password[:]),
+		region:     klib.ByteArrayToString(req.Region[:]),
+		tags:       TagsToMap(req.Tags[:]),
+		maxPlayers: min(max(req.MaxPlayers, 1), MaxLobbyMembers),
+		members:    []lobbyMember{newLobbyMember(req, client)},
+	}
+	s.lobbies[l.id] = l
+	s.clientLobby[client.Id()] = l.id
+	s.broadcast(l, Response{Type: ResponseTypeLobbyUpdate})
+	return ErrorNone
+}
+
+// listLobbies pages through the lobbies of the game that pass the filters,
+// the lobbies are listed the same way as game servers with the region added
+// as a tag
+func (s *LobbyService) listLobbies(req Request, client *network.ServerClient) {
+	game := klib.ByteArrayToString(req.Game[:])
+	matches := make([]ServerListing, 0, len(s.lobbies))
+	for _, l := range s.lobbies {
+		if l.quickMatch || l.game != game {
+			continue
+		}
+		if listing := l.listing(); listing.matches(req.Filters[:]) {
+			matches = append(matches, listing)
+		}
+	}
+	slices.SortFunc(matches, func(a, b ServerListing) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	sendListingPages(ResponseTypeLobbyList, matches, req, func(res Response) {
+		s.send(res, client)
+	})
+}
+
+func (s *LobbyService) joinLobby(req Request, client *network.ServerClient) Error {
+	if s.isBusy(client) {
+		return ErrorAlreadyInLobby
+	}
+	l, ok := s.lobbies[req.LobbyId]
+	if !ok || l.quickMatch {
+		return ErrorLobbyDoesntExist
+	}
+	if l.password != klib.ByteArrayToString(req.Password[:]) {
+		return ErrorIncorrectPassword
+	}
+	if len(l.members) >= int(l.maxPlayers) {
+		return ErrorLobbyFull
+	}
+	l.members = append(l.members, newLobbyMember(req, client))
+	s.clientLobby[client.Id()] = l.id
+	s.broadcast(l, Response{Type: ResponseTypeLobbyUpdate})
+	return ErrorNone
+}
+
+func (s *LobbyService) memberLobby(client *network.ServerClient) (*lobby, int) {
+	l, ok := s.lobbies[s.clientLobby[client.Id()]]
+	if !ok {
+		return nil, -1
+	}
+	return l, slices.IndexFunc(l.members, func(m lobbyMember) bool {
+		return m.client.Id() == client.Id()
+	})
+}
+
+// leaveLobby removes the client from its lobby, if the host leaves then the
+// longest standing member becomes the host and an empty lobby is closed
+func (s *LobbyService) leaveLobby(client *network.ServerClient) Error {
+	l, idx := s.memberLobby(client)
+	if l == nil {
+		return ErrorNotInLobby
+	}
+	l.members = slices.Delete(l.members, idx, idx+1)
+	delete(s.clientLobby, client.Id())
+	if len(l.members) == 0 {
+		delete(s.lobbies, l.id)
+	} else {
+		s.broadcast(l, Response{Type: ResponseTypeLobbyUpdate})
+	}
+	return ErrorNone
+}
+
+func (s *LobbyService) setReady(client *network.ServerClient, ready bool) Error {
+	l, idx := s.memberLobby(client)
+	if l == nil {
+		return ErrorNotInLobby
+	}
+	l.members[idx].ready = ready
+	s.broadcast(l, Response{Type: ResponseTypeLobbyUpdate})
+	return ErrorNone
+}
+
+func (s *LobbyService) chat(client *network.ServerClient, message [MaxChatSize]byte) Error {
+	l, idx := s.memberLobby(client)
+	if l == nil {
+		return ErrorNotInLobby
+	}
+	res := Response{Type: ResponseTypeLobbyChat, Message: message}
+	copy(res.Sender[:], l.members[idx].name)
+	s.broadcast(l, res)
+	return ErrorNone
+}
+
+// startLobby promotes the lobby into a game session on the server that the
+// host registered. The members are sent the listing to join and the lobby is
+// closed.
+func (s *LobbyService) startLobby(client *network.ServerClient, listingId uint64, token string) Error {
+	l, idx := s.memberLobby(client)
+	if l == nil {
+		return ErrorNotInLobby
+	}
+	if idx != 0 {
+		return ErrorNotLobbyHost
+	}
+	for i := range l.members {
+		if !l.members[i].ready {
+			return ErrorPlayersNotReady
+		}
+	}
+	if !s.ownsListing(client, listingId, token) {
+		return ErrorServerDoesntExist
+	}
+	res := Response{Type: ResponseTypeLobbyStart}
+	s.broadcast(l, res, func(rl *ResponseLobby) { rl.ListingId = listingId })
+	for i := range l.members {
+		delete(s.clientLobby, l.members[i].client.Id())
+	}
+	delete(s.lobbies, l.id)
+	return ErrorNone
+}
+
+// removeClient drops a client that has disconnected from its lobby and from
+// the quick-match queue
+func (s *LobbyService) removeClient(client *network.ServerClient) {
+	s.leaveLobby(client)
+	s.dequeue(client)
+}
+
+func (l *lobby) listing() ServerListing {
+	tags := make(map[string]string, len(l.tags)+1)
+	for k, v := range l.tags {
+		tags[k] = v
+	}
+	if l.region != "" {
+		tags[TagKeyRegion] = l.region
+	}
+	return ServerListing{
+		id:             l.id,
+		game:           l.game,
+		name:           l.name,
+		password:       l.password,
+		tags:           tags,
+		maxPlayers:     l.maxPlayers,
+		currentPlayers: uint16(len(l.members)),
+	}
+}
+
+func (l *lobby) response() ResponseLobby {
+	rl := ResponseLobby{
+		Id:          l.id,
+		MaxPlayers:  l.maxPlayers,
+		MemberCount: uint8(len(l.members)),
+	}
+	copy(rl.Name[:], l.name)
+	copy(rl.Region[:], l.region)
+	for i := range l.members {
+		copy(rl.Members[i].Name[:], l.members[i].name)
+		rl.Members[i].Skill = l.members[i].skill
+		rl.Members[i].Ready = l.members[i].ready
+	}
+	return rl
+}
+
+// broadcast sends the response, filled with the current state of the lobby,
+// to every member of the lobby
+func (s *LobbyService) broadcast(l *lobby, res Response, edits ...func(*ResponseLobby)) {
+	res.Lobby = l.response()
+	for _, edit := range edits {
+		edit(&res.Lobby)
+	}
+	for i := range l.members {
+		s.send(res, l.members[i].client)
+	}
+}
@@ -0,0 +1,304 @@
+/******************************************************************************/
+/* master_server_lobby_test.go                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"kaijuengine.com/klib"
+	"kaijuengine.com/network"
+)
+
+func lobbyRequest(reqType MasterServerRequestType, player string, skill uint32, region string) Request {
+	req := Request{Type: reqType, Skill: skill}
+	copy(req.Game[:], "game")
+	copy(req.PlayerName[:], player)
+	copy(req.Region[:], region)
+	return req
+}
+
+func lastResponseTo(sent []sentResponse, client *network.ServerClient) Response {
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].client == client {
+			return sent[i].res
+		}
+	}
+	return Response{}
+}
+
+func TestLobby_CreateJoinReadyStart(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	host, guest := testGameClient(1, "203.0.113.1"), testGameClient(2, "198.51.100.7")
+	create := lobbyRequest(RequestTypeCreateLobby, "host", 1000, "eu")
+	create.MaxPlayers = 4
+	copy(create.Name[:], "Friday")
+	sendTestRequest(m, create, host)
+	lobbyId := lastResponseTo(*sent, host).Lobby.Id
+	join := lobbyRequest(RequestTypeJoinLobby, "guest", 900, "eu")
+	join.LobbyId = lobbyId
+	sendTestRequest(m, join, guest)
+	update := lastResponseTo(*sent, host)
+	if update.Type != ResponseTypeLobbyUpdate || update.Lobby.MemberCount != 2 {
+		t.Fatalf("host update = %+v, want two members", update.Lobby)
+	}
+	if klib.ByteArrayToString(update.Lobby.Members[1].Name[:]) != "guest" {
+		t.Error("the guest is missing from the lobby state")
+	}
+
+	chat := lobbyRequest(RequestTypeLobbyChat, "", 0, "")
+	copy(chat.Message[:], "glhf")
+	sendTestRequest(m, chat, guest)
+	res := lastResponseTo(*sent, host)
+	if res.Type != ResponseTypeLobbyChat || klib.ByteArrayToString(res.Sender[:]) != "guest" ||
+		klib.ByteArrayToString(res.Message[:]) != "glhf" {
+		t.Errorf("chat = %+v, want the guest's message", res)
+	}
+
+	sendTestRequest(m, registerWithPortRequest(7000), host)
+	start := Request{Type: RequestTypeStartLobby, ServerId: 1}
+	sendTestRequest(m, start, guest)
+	if lastResponseTo(*sent, guest).Error != ErrorNotLobbyHost {
+		t.Error("only the host should be able to start the lobby")
+	}
+	sendTestRequest(m, start, host)
+	if lastResponseTo(*sent, host).Error != ErrorPlayersNotReady {
+		t.Error("the lobby should not start before everyone is ready")
+	}
+	for _, c := range []*network.ServerClient{host, guest} {
+		sendTestRequest(m, Request{Type: RequestTypeLobbyReady, Ready: true}, c)
+	}
+	sendTestRequest(m, Request{Type: RequestTypeStartLobby, ServerId: 99}, host)
+	if lastResponseTo(*sent, host).Error != ErrorServerDoesntExist {
+		t.Error("the lobby should only start on a listing that the host registered")
+	}
+	sendTestRequest(m, start, host)
+	res = lastResponseTo(*sent, guest)
+	if res.Type != ResponseTypeLobbyStart || res.Lobby.ListingId != 1 {
+		t.Errorf("guest response = %+v, want the lobby to start on listing 1", res)
+	}
+	if len(m.lobbies.lobbies) != 0 || len(m.lobbies.clientLobby) != 0 {
+		t.Error("a started lobby should be closed")
+	}
+}
+
+func TestLobby_StartRequiresListingOwner(t *testing.T) {
+	m, sent := newTestMasterServer(Config{RegistrationTokens: []string{"trusted"}})
+	game := testGameClient(1, "203.0.113.1")
+	req := registerRequest("game", "lobby server", "trusted", nil)
+	req.GamePort = 7000
+	sendTestRequest(m, req, game)
+	// Another player behind the same NAT shares the game server's address
+	host := testGameClient(2, "203.0.113.1")
+	sendTestRequest(m, lobbyRequest(RequestTypeCreateLobby, "host", 1000, "eu"), host)
+	sendTestRequest(m, Request{Type: RequestTypeLobbyReady, Ready: true}, host)
+	start := Request{Type: RequestTypeStartLobby, ServerId: 1}
+	sendTestRequest(m, start, host)
+	if lastResponseTo(*sent, host).Error != ErrorServerDoesntExist {
+		t.Fatal("sharing an address with the game server should not own its listing")
+	}
+	copy(start.Token[:], "wrong")
+	sendTestRequest(m, start, host)
+	if lastResponseTo(*sent, host).Error != ErrorServerDoesntExist {
+		t.Fatal("the wrong token should not own the listing")
+	}
+	start.Token = [MaxTokenSize]byte{}
+	copy(start.Token[:], "trusted")
+	sendTestRequest(m, start, host)
+	if res := lastResponseTo(*sent, host); res.Type != ResponseTypeLobbyStart || res.Lobby.ListingId != 1 {
+		t.Errorf("response = %+v, want the lobby to start with the registration token", res)
+	}
+}
+
+func registerWithPortRequest(port uint16) Request {
+	req := registerRequest("game", "lobby server", "", nil)
+	req.GamePort = port
+	return req
+}
+
+func TestLobby_JoinErrors(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	host := testGameClient(1, "203.0.113.1")
+	create := lobbyRequest(RequestTypeCreateLobby, "host", 0, "")
+	create.MaxPlayers = 1
+	sendTestRequest(m, create, host)
+	sendTestRequest(m, create, host)
+	if lastResponseTo(*sent, host).Error != ErrorAlreadyInLobby {
+		t.Error("a client should only be in one lobby at a time")
+	}
+	tests := []struct {
+		lobbyId uint64
+		want    Error
+	}{
+		{99, ErrorLobbyDoesntExist},
+		{1, ErrorLobbyFull},
+	}
+	for i, tt := range tests {
+		client := testGameClient(10+i, "198.51.100.7")
+		join := lobbyRequest(RequestTypeJoinLobby, "guest", 0, "")
+		join.LobbyId = tt.lobbyId
+		sendTestRequest(m, join, client)
+		if got := lastResponseTo(*sent, client).Error; got != tt.want {
+			t.Errorf("joining %d: error = %d, want %d", tt.lobbyId, got, tt.want)
+		}
+	}
+	outsider := testGameClient(20, "198.51.100.8")
+	sendTestRequest(m, Request{Type: RequestTypeLobbyReady, Ready: true}, outsider)
+	if lastResponseTo(*sent, outsider).Error != ErrorNotInLobby {
+		t.Error("a client outside of a lobby can't be ready")
+	}
+}
+
+func TestLobby_HostLeavesAndDisconnects(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	host, guest := testGameClient(1, "203.0.113.1"), testGameClient(2, "198.51.100.7")
+	create := lobbyRequest(RequestTypeCreateLobby, "host", 0, "")
+	create.MaxPlayers = 4
+	sendTestRequest(m, create, host)
+	join := lobbyRequest(RequestTypeJoinLobby, "guest", 0, "")
+	join.LobbyId = 1
+	sendTestRequest(m, join, guest)
+	sendTestRequest(m, Request{Type: RequestTypeLeaveLobby}, host)
+	res := lastResponseTo(*sent, guest)
+	if res.Lobby.MemberCount != 1 || klib.ByteArrayToString(res.Lobby.Members[0].Name[:]) != "guest" {
+		t.Errorf("lobby = %+v, want the guest to become the host", res.Lobby)
+	}
+	m.disconnects.Enqueue(guest)
+	m.update(0)
+	if len(m.lobbies.lobbies) != 0 {
+		t.Error("a lobby should close once its last member disconnects")
+	}
+}
+
+func TestLobby_ListFiltersByRegion(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	for i, region := range []string{"eu", "us", "eu"} {
+		create := lobbyRequest(RequestTypeCreateLobby, "host", 0, region)
+		create.MaxPlayers = 4
+		sendTestRequest(m, create, testGameClient(i+1, "203.0.113.1"))
+	}
+	client := testGameClient(10, "198.51.100.7")
+	list := lobbyRequest(RequestTypeListLobbies, "", 0, "")
+	list.Filters[0] = NewFilter(TagKeyRegion, FilterOpEqual, "eu")
+	sendTestRequest(m, list, client)
+	res := lastResponseTo(*sent, client)
+	if res.Type != ResponseTypeLobbyList || res.TotalList != 2 || res.List[0].Id != 1 || res.List[1].Id != 3 {
+		t.Errorf("lobby list = %d total, ids %d and %d; want 2 lobbies, 1 and 3",
+			res.TotalList, res.List[0].Id, res.List[1].Id)
+	}
+}
+
+func TestMatchmaking_SkillAndRegion(t *testing.T) {
+	m, sent := newTestMasterServer(Config{Matchmaking: MatchmakingConfig{
+		SkillRange:       100,
+		SkillRangeGrowth: 10,
+		RegionTimeout:    time.Second * 20,
+	}})
+	now := time.Now()
+	queue := func(id int, skill uint32, region string) *network.ServerClient {
+		client := testGameClient(id, "198.51.100.7")
+		req := lobbyRequest(RequestTypeQuickMatch, "p", skill, region)
+		req.MaxPlayers = 2
+		m.lobbies.processRequest(req, client, now)
+		return client
+	}
+	a := queue(1, 1000, "eu")
+	queue(2, 1500, "eu")
+	c := queue(3, 1080, "us")
+	d := queue(4, 1050, "eu")
+	m.lobbies.matchPlayers(now)
+	res := lastResponseTo(*sent, a)
+	if res.Type != ResponseTypeMatchFound || res.Lobby.Members[1].Skill != 1050 {
+		t.Fatalf("match = %+v, want the closest player in the same region", res.Lobby)
+	}
+	if lastResponseTo(*sent, d).Lobby.Id != res.Lobby.Id || len(m.lobbies.queue) != 2 {
+		t.Error("both matched players should be in the lobby and out of the queue")
+	}
+	// 1080 in the us and 1500 in the eu are too far apart in skill and region
+	m.lobbies.matchPlayers(now.Add(time.Second * 10))
+	if len(m.lobbies.queue) != 2 {
+		t.Fatal("players were matched outside of the skill range and region")
+	}
+	// After the region timeout the range has grown to 100+10*45 = 550
+	m.lobbies.matchPlayers(now.Add(time.Second * 45))
+	if len(m.lobbies.queue) != 0 || lastResponseTo(*sent, c).Type != ResponseTypeMatchFound {
+		t.Error("the range and region should relax for players that have waited")
+	}
+}
+
+func TestMatchmaking_EveryPlayerFitsTheMatch(t *testing.T) {
+	// Both candidates fit the anchor, but not each other
+	for _, regions := range [][2]string{{"eu", "us"}, {"", ""}} {
+		m, _ := newTestMasterServer(Config{Matchmaking: MatchmakingConfig{SkillRange: 100}})
+		now := time.Now()
+		for i, player := range []struct {
+			skill  uint32
+			region string
+		}{{1000, ""}, {1090, regions[0]}, {910, regions[1]}} {
+			req := lobbyRequest(RequestTypeQuickMatch, "p", player.skill, player.region)
+			req.MaxPlayers = 3
+			m.lobbies.processRequest(req, testGameClient(i+1, "198.51.100.7"), now)
+		}
+		m.lobbies.matchPlayers(now)
+		if len(m.lobbies.queue) != 3 {
+			t.Errorf("regions %v: players were matched with each other outside of the rules", regions)
+		}
+	}
+}
+
+func TestMatchmaking_LobbyIsPrivate(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	for i := range 2 {
+		req := lobbyRequest(RequestTypeQuickMatch, "p", 0, "")
+		req.MaxPlayers = 2
+		m.lobbies.processRequest(req, testGameClient(i+1, "198.51.100.7"), time.Now())
+	}
+	m.lobbies.matchPlayers(time.Now())
+	if len(m.lobbies.lobbies) != 1 {
+		t.Fatal("expected the players to be matched into a lobby")
+	}
+	sendTestRequest(m, Request{Type: RequestTypeLeaveLobby}, testGameClient(2, "198.51.100.7"))
+	stranger := testGameClient(10, "203.0.113.9")
+	sendTestRequest(m, lobbyRequest(RequestTypeListLobbies, "", 0, ""), stranger)
+	if res := lastResponseTo(*sent, stranger); res.TotalList != 0 {
+		t.Errorf("listed %d lobbies, a quick-match lobby should not be listed", res.TotalList)
+	}
+	join := lobbyRequest(RequestTypeJoinLobby, "stranger", 0, "")
+	join.LobbyId = 1
+	sendTestRequest(m, join, stranger)
+	if res := lastResponseTo(*sent, stranger); res.Error != ErrorLobbyDoesntExist {
+		t.Errorf("join error = %d, a quick-match lobby should not be joinable", res.Error)
+	}
+}
+
+func TestMatchmaking_Cancel(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	client := testGameClient(1, "198.51.100.7")
+	sendTestRequest(m, lobbyRequest(RequestTypeQuickMatch, "p", 0, ""), client)
+	sendTestRequest(m, lobbyRequest(RequestTypeCreateLobby, "p", 0, ""), client)
+	if lastResponseTo(*sent, client).Error != ErrorAlreadyInLobby {
+		t.Error("a queued player should not be able to create a lobby")
+	}
+	sendTestRequest(m, Request{Type: RequestTypeCancelQuickMatch}, client)
+	if len(m.lobbies.queue) != 0 {
+		t.Error("the player should have left the queue")
+	}
+}
+
+func TestResponseLobbyRoundTrip(t *testing.T) {
+	res := Response{Type: ResponseTypeLobbyUpdate, RendezvousId: 5}
+	res.Lobby = ResponseLobby{Id: 7, ListingId: 9, MaxPlayers: 8, MemberCount: 2}
+	copy(res.Lobby.Name[:], "Friday")
+	res.Lobby.Members[MaxLobbyMembers-1] = LobbyMember{Skill: 1234, Ready: true}
+	copy(res.Message[:], "hello")
+	buf := make([]byte, unsafe.Sizeof(Response{}))
+	res.Serialize(buf)
+	if got := DeserializeResponse(buf); got != res {
+		t.Errorf("response = %+v, want %+v", got, res)
+	}
+}
@@ -47,7 +47,7 @@ func TestRequestResponseSizeComparison(t *testing.T) {
 
 func TestRequestSerializationOrder(t *testing.T) {
 	// Serialize order: Game(32), Name(64), Password(16), MaxPlayers(2), CurrentPlayers(2), Type(1)
-	// ServerId is NOT serialized despite being a struct field.
+	// followed by the tags, filters, pagination, token and the join fields.
 	req := Request{
 		MaxPlayers:     100,
 		CurrentPlayers: 50,
@@ -57,7 +57,7 @@ func TestRequestSerializationOrder(t *testing.T) {
 	copy(req.Name[:], "ServerName")
 	copy(req.Password[:], "Password123")
 
-	buf := make([]byte, 117) // Game(32) + Name(64) + Password(16) + MaxPlayers(2) + CurrentPlayers(2) + Type(1)
+	buf := make([]byte, unsafe.Sizeof(Request{}))
 	req.Serialize(buf)
 
 	// Game at offset 0 (32 bytes) — use bytes.Equal to avoid null byte issues
@@ -0,0 +1,178 @@
+/******************************************************************************/
+/* master_server_matchmaking.go                                               */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"kaijuengine.com/klib"
+	"kaijuengine.com/network"
+)
+
+const quickMatchLobbyName = "Quick match"
+
+// MatchmakingConfig controls how the quick-match queue groups players
+type MatchmakingConfig struct {
+	// SkillRange is the largest difference in skill between the players of a
+	// match when they have only just joined the queue
+	SkillRange uint32
+	// SkillRangeGrowth is how much the skill range widens for each second
+	// that the longest waiting player has been in the queue
+	SkillRangeGrowth float64
+	// RegionTimeout is how long a player waits before they can be matched
+	// with players from other regions, 0 keeps them in their region
+	RegionTimeout time.Duration
+}
+
+type queueEntry struct {
+	client    *network.ServerClient
+	game      string
+	name      string
+	region    string
+	skill     uint32
+	matchSize uint16
+	queuedAt  time.Time
+}
+
+func DefaultMatchmakingConfig() MatchmakingConfig {
+	return MatchmakingConfig{
+		SkillRange:       100,
+		SkillRangeGrowth: 25,
+		RegionTimeout:    time.Second * 30,
+	}
+}
+
+func (s *LobbyService) queueIndex(client *network.ServerClient) int {
+	return slices.IndexFunc(s.queue, func(e queueEntry) bool {
+		return e.client.Id() == client.Id()
+	})
+}
+
+func (s *LobbyService) enqueue(req Request, client *network.ServerClient, now time.Time) Error {
+	if s.isBusy(client) {
+		return ErrorAlreadyInLobby
+	}
+	s.queue = append(s.queue, queueEntry{
+		client:    client,
+		game:      klib.ByteArrayToString(req.Game[:]),
+		name:      klib.ByteArrayToString(req.PlayerName[:]),
+		region:    klib.ByteArrayToString(req.Region[:]),
+		skill:     req.Skill,
+		matchSize: min(max(req.MaxPlayers, 2), MaxLobbyMembers),
+		queuedAt:  now,
+	})
+	return ErrorNone
+}
+
+func (s *LobbyService) dequeue(client *network.ServerClient) {
+	if idx := s.queueIndex(client); idx >= 0 {
+		s.queue = slices.Delete(s.queue, idx, idx+1)
+	}
+}
+
+func (s *LobbyService) skillRange(e *queueEntry, now time.Time) float64 {
+	return float64(s.config.SkillRange) + s.config.SkillRangeGrowth*now.Sub(e.queuedAt).Seconds()
+}
+
+// compatible is if the two players can be put in the same match, the anchor
+// is the longest waiting player of the match so their wait decides how far
+// the skill and region rules are relaxed
+func (s *LobbyService) compatible(anchor, a, b *queueEntry, now time.Time) bool {
+	if a.game != b.game || a.matchSize != b.matchSize {
+		return false
+	}
+	if a.region != "" && b.region != "" && a.region != b.region {
+		if s.config.RegionTimeout <= 0 || now.Sub(anchor.queuedAt) < s.config.RegionTimeout {
+			return false
+		}
+	}
+	return float64(skillDistance(a, b)) <= s.skillRange(anchor, now)
+}
+
+// fitsMatch is if the candidate is compatible with every player already in
+// the match, not only the anchor, so that the players of a match are never
+// further apart than the rules allow
+func (s *LobbyService) fitsMatch(match []int, candidate *queueEntry, now time.Time) bool {
+	anchor := &s.queue[match[0]]
+	for _, idx := range match {
+		if !s.compatible(anchor, &s.queue[idx], candidate, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPlayers forms as many matches as it can from the queue. The longest
+// waiting players are matched first, each with the closest in skill of the
+// players that are compatible with them and with each other. Every match
+// becomes a new lobby that is hosted by the longest waiting player.
+func (s *LobbyService) matchPlayers(now time.Time) {
+	slices.SortStableFunc(s.queue, func(a, b queueEntry) int {
+		return a.queuedAt.Compare(b.queuedAt)
+	})
+	for i := 0; i < len(s.queue); i++ {
+		anchor := &s.queue[i]
+		candidates := []int{}
+		for j := i + 1; j < len(s.queue); j++ {
+			if s.compatible(anchor, anchor, &s.queue[j], now) {
+				candidates = append(candidates, j)
+			}
+		}
+		size := int(anchor.matchSize)
+		if len(candidates) < size-1 {
+			continue
+		}
+		slices.SortStableFunc(candidates, func(a, b int) int {
+			return cmp.Compare(skillDistance(anchor, &s.queue[a]), skillDistance(anchor, &s.queue[b]))
+		})
+		picked := []int{i}
+		for _, c := range candidates {
+			if len(picked) == size {
+				break
+			}
+			if s.fitsMatch(picked, &s.queue[c], now) {
+				picked = append(picked, c)
+			}
+		}
+		if len(picked) < size {
+			continue
+		}
+		s.createMatch(picked)
+		slices.Sort(picked)
+		for k := len(picked) - 1; k >= 0; k-- {
+			s.queue = slices.Delete(s.queue, picked[k], picked[k]+1)
+		}
+		i--
+	}
+}
+
+func skillDistance(a, b *queueEntry) uint32 {
+	return max(a.skill, b.skill) - min(a.skill, b.skill)
+}
+
+func (s *LobbyService) createMatch(picked []int) {
+	anchor := &s.queue[picked[0]]
+	s.nextLobbyId++
+	l := &lobby{
+		id:         s.nextLobbyId,
+		game:       anchor.game,
+		name:       quickMatchLobbyName,
+		region:     anchor.region,
+		maxPlayers: anchor.matchSize,
+		quickMatch: true,
+		members:    make([]lobbyMember, 0, len(picked)),
+	}
+	for _, idx := range picked {
+		e := &s.queue[idx]
+		l.members = append(l.members, lobbyMember{client: e.client, name: e.name, skill: e.skill})
+		s.clientLobby[e.client.Id()] = l.id
+	}
+	s.lobbies[l.id] = l
+	s.broadcast(l, Response{Type: ResponseTypeMatchFound})
+}
@@ -0,0 +1,126 @@
+/******************************************************************************/
+/* master_server_persistence.go                                               */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistInterval is the most often the listings are written to disk, player
+// counts change often so writes are batched up
+const persistInterval = time.Second * 5
+
+type persistedRegistry struct {
+	NextListingId uint64             `json:"nextListingId"`
+	Listings      []persistedListing `json:"listings"`
+}
+
+type persistedListing struct {
+	Id             uint64            `json:"id"`
+	Game           string            `json:"game"`
+	Name           string            `json:"name"`
+	Password       string            `json:"password,omitempty"`
+	Address        string            `json:"address"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	MaxPlayers     uint16            `json:"maxPlayers"`
+	CurrentPlayers uint16            `json:"currentPlayers"`
+}
+
+func (m *MasterServer) markDirty() {
+	m.persistDirty = m.config.PersistPath != ""
+}
+
+func (m *MasterServer) persistIfDirty(now time.Time) {
+	if !m.persistDirty || now.Before(m.persistAt) {
+		return
+	}
+	m.persistDirty = false
+	m.persistAt = now.Add(persistInterval)
+	if err := m.persistListings(); err != nil {
+		slog.Error("failed to save the master server listings", "path", m.config.PersistPath, "error", err)
+	}
+}
+
+// persistListings writes the listings to a temporary file and then renames it
+// over the previous file, so a crash mid-write never leaves a corrupt registry
+func (m *MasterServer) persistListings() error {
+	reg := persistedRegistry{
+		NextListingId: m.nextListingId,
+		Listings:      make([]persistedListing, 0, len(m.serverList)),
+	}
+	for _, serv := range m.serverList {
+		reg.Listings = append(reg.Listings, persistedListing{
+			Id:             serv.id,
+			Game:           serv.game,
+			Name:           serv.name,
+			Password:       serv.password,
+			Address:        serv.address,
+			Tags:           serv.tags,
+			MaxPlayers:     serv.maxPlayers,
+			CurrentPlayers: serv.currentPlayers,
+		})
+	}
+	data, err := json.Marshal(reg)
+	if err != nil {
+		return err
+	}
+	path := m.config.PersistPath
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// restoreListings loads the listings that were saved before the master server
+// last stopped. The game servers have to connect again, so the listings are
+// only kept until the normal server timeout unless the server registers again
+// and takes its listing back.
+func (m *MasterServer) restoreListings(now time.Time) error {
+	data, err := os.ReadFile(m.config.PersistPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	reg := persistedRegistry{}
+	if err = json.Unmarshal(data, &reg); err != nil {
+		return err
+	}
+	m.nextListingId = reg.NextListingId
+	for _, l := range reg.Listings {
+		m.nextListingId = max(m.nextListingId, l.Id)
+		m.serverList[l.Id] = ServerListing{
+			id:             l.Id,
+			game:           l.Game,
+			name:           l.Name,
+			password:       l.Password,
+			address:        l.Address,
+			tags:           l.Tags,
+			maxPlayers:     l.MaxPlayers,
+			currentPlayers: l.CurrentPlayers,
+			timeoutAt:      now.Add(serverTimeout),
+		}
+	}
+	return nil
+}
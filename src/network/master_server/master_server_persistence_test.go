@@ -0,0 +1,168 @@
+/******************************************************************************/
+/* master_server_persistence_test.go                                          */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+	"unsafe"
+
+	"kaijuengine.com/network"
+)
+
+type sentResponse struct {
+	res    Response
+	client *network.ServerClient
+}
+
+func newTestMasterServer(config Config) (*MasterServer, *[]sentResponse) {
+	m := newMasterServer(config)
+	sent := []sentResponse{}
+	m.send = func(message []byte, client *network.ServerClient) error {
+		sent = append(sent, sentResponse{DeserializeResponse(message), client})
+		return nil
+	}
+	return m, &sent
+}
+
+func testGameClient(id int, ip string) *network.ServerClient {
+	return network.NewServerClientForTest(id, &net.UDPAddr{IP: net.ParseIP(ip), Port: 4000 + id})
+}
+
+func sendTestRequest(m *MasterServer, req Request, client *network.ServerClient) {
+	buf := make([]byte, unsafe.Sizeof(Request{}))
+	req.Serialize(buf)
+	msg := network.NewClientMessageFromBytes(buf)
+	msg.Client = client
+	m.processMessage(msg)
+}
+
+func registerRequest(game, name, token string, tags map[string]string) Request {
+	req := Request{Type: RequestTypeRegisterServer, MaxPlayers: 8, Tags: TagsFromMap(tags)}
+	copy(req.Game[:], game)
+	copy(req.Name[:], name)
+	copy(req.Token[:], token)
+	return req
+}
+
+func TestMasterServer_RegistrationToken(t *testing.T) {
+	m, sent := newTestMasterServer(Config{RegistrationTokens: []string{"trusted"}})
+	sendTestRequest(m, registerRequest("game", "bad", "wrong", nil), testGameClient(1, "10.0.0.1"))
+	if len(m.serverList) != 0 {
+		t.Fatal("a server registered with the wrong token")
+	}
+	if (*sent)[0].res.Type != ResponseTypeError || (*sent)[0].res.Error != ErrorUnauthorized {
+		t.Errorf("response = %+v, want an unauthorized error", (*sent)[0].res)
+	}
+	sendTestRequest(m, registerRequest("game", "good", "trusted", nil), testGameClient(2, "10.0.0.2"))
+	if len(m.serverList) != 1 || (*sent)[1].res.Type != ResponseTypeConfirmRegister {
+		t.Error("a server with the correct token was not registered")
+	}
+}
+
+func TestMasterServer_AuthorizeRegistration(t *testing.T) {
+	m, _ := newTestMasterServer(Config{
+		AuthorizeRegistration: func(token, address string) bool { return token == "server-"+address },
+	})
+	client := testGameClient(1, "10.0.0.1")
+	sendTestRequest(m, registerRequest("game", "a", "server-"+client.Address(), nil), client)
+	if len(m.serverList) != 1 {
+		t.Error("the custom authorization was not used")
+	}
+}
+
+func TestMasterServer_QueryFiltersAndPages(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	for i := range 25 {
+		region := "eu"
+		if i%2 == 1 {
+			region = "us"
+		}
+		sendTestRequest(m, registerRequest("game", "server", "", map[string]string{"region": region}),
+			testGameClient(i+1, "10.0.0.1"))
+	}
+	sendTestRequest(m, registerRequest("other", "server", "", map[string]string{"region": "eu"}),
+		testGameClient(100, "10.0.0.1"))
+	*sent = (*sent)[:0]
+
+	req := Request{Type: RequestTypeServerList, Offset: 2, Limit: 11}
+	copy(req.Game[:], "game")
+	req.Filters[0] = NewFilter("region", FilterOpEqual, "eu")
+	sendTestRequest(m, req, testGameClient(200, "10.0.0.9"))
+	if len(*sent) != 2 {
+		t.Fatalf("responses = %d, want 2", len(*sent))
+	}
+	first, second := (*sent)[0].res, (*sent)[1].res
+	if first.TotalList != 13 || first.Offset != 2 || second.Offset != 12 {
+		t.Errorf("total = %d, offsets = %d, %d; want 13, 2, 12", first.TotalList, first.Offset, second.Offset)
+	}
+	if second.List[0].Id == 0 || second.List[1].Id != 0 {
+		t.Error("the second page should hold a single listing")
+	}
+	// Listings are in id order, eu servers have odd ids, so the third is 5
+	if first.List[0].Id != 5 {
+		t.Errorf("first listing id = %d, want 5", first.List[0].Id)
+	}
+	if TagsToMap(first.List[0].Tags[:])["region"] != "eu" {
+		t.Error("the listing tags were not sent")
+	}
+}
+
+func TestMasterServer_EmptyQueryStillResponds(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	req := Request{Type: RequestTypeServerList}
+	copy(req.Game[:], "game")
+	sendTestRequest(m, req, testGameClient(1, "10.0.0.1"))
+	if len(*sent) != 1 || (*sent)[0].res.TotalList != 0 {
+		t.Error("an empty server list should still be answered")
+	}
+}
+
+func TestMasterServer_PersistAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "listings.json")
+	m, _ := newTestMasterServer(Config{PersistPath: path})
+	sendTestRequest(m, registerRequest("game", "alpha", "", map[string]string{"map": "harbor"}),
+		testGameClient(1, "10.0.0.1"))
+	sendTestRequest(m, registerRequest("game", "beta", "", nil), testGameClient(2, "10.0.0.2"))
+	if !m.persistDirty {
+		t.Fatal("registering should mark the listings to be saved")
+	}
+	now := time.Now()
+	m.persistIfDirty(now)
+	if m.persistDirty {
+		t.Fatal("the listings were not saved")
+	}
+
+	restored, _ := newTestMasterServer(Config{PersistPath: path})
+	if len(restored.serverList) != 2 || restored.nextListingId != 2 {
+		t.Fatalf("restored %d listings with next id %d, want 2 and 2",
+			len(restored.serverList), restored.nextListingId)
+	}
+	if restored.serverList[1].tags["map"] != "harbor" || restored.serverList[1].client != nil {
+		t.Error("the restored listing is missing its tags or has a client")
+	}
+	// The same server registering again takes back its listing
+	sendTestRequest(restored, registerRequest("game", "alpha", "", nil), testGameClient(7, "10.0.0.1"))
+	if len(restored.serverList) != 2 || restored.serverList[1].client == nil {
+		t.Error("the restored listing was not adopted by the returning server")
+	}
+	// Restored listings that nobody takes back time out as usual
+	restored.evictUnresponsiveServers(now.Add(serverTimeout * 2))
+	if len(restored.serverList) != 0 {
+		t.Errorf("listings = %d, want all to time out", len(restored.serverList))
+	}
+}
+
+func TestMasterServer_NoPersistPath(t *testing.T) {
+	m, _ := newTestMasterServer(Config{})
+	sendTestRequest(m, registerRequest("game", "alpha", "", nil), testGameClient(1, "10.0.0.1"))
+	if m.persistDirty {
+		t.Error("listings should not be saved without a persist path")
+	}
+}
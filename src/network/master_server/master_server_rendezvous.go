@@ -0,0 +1,106 @@
+/******************************************************************************/
+/* master_server_rendezvous.go                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"log/slog"
+	"time"
+
+	"kaijuengine.com/klib"
+	"kaijuengine.com/network"
+)
+
+// rendezvousTimeout is how long a join is given for both sides to punch
+// through their NATs, it covers the client trying both of the endpoints
+const rendezvousTimeout = time.Second * 10
+
+// rendezvous is a join that the master server has introduced both sides of
+type rendezvous struct {
+	id        uint64
+	listingId uint64
+	expiresAt time.Time
+}
+
+// processJoinServer introduces the joining client and the game server to each
+// other. Both are sent the other's endpoints at the same time so that they
+// hole-punch at the same time, the game server sends packets to the client to
+// open its NAT while the client's connection requests open the client's NAT.
+//
+// The endpoints are the public address seen by the master server with the
+// game port that the peer gave, which holds for NATs that keep the local port
+// or map it the same way for every destination. When both peers are behind
+// the same public address the private (LAN) endpoint is sent first, as many
+// NATs will not loop packets back to themselves.
+func (m *MasterServer) processJoinServer(req Request, msg network.ClientMessage) {
+	serv, ok := m.serverList[req.ServerId]
+	errCode := ErrorNone
+	switch {
+	case !ok:
+		errCode = ErrorServerDoesntExist
+	case serv.client == nil || serv.gamePort == 0:
+		errCode = ErrorServerUnreachable
+	case serv.password != klib.ByteArrayToString(req.Password[:]):
+		errCode = ErrorIncorrectPassword
+	case serv.maxPlayers > 0 && serv.currentPlayers >= serv.maxPlayers:
+		errCode = ErrorServerFull
+	}
+	now := time.Now()
+	if rv, pending := m.rendezvous[msg.Client.Id()]; pending && errCode == ErrorNone && now.Before(rv.expiresAt) {
+		errCode = ErrorJoinPending
+	}
+	if errCode != ErrorNone {
+		m.sendResponse(Response{Type: ResponseTypeError, Error: errCode}, msg.Client)
+		return
+	}
+	m.nextRendezvous++
+	rv := rendezvous{id: m.nextRendezvous, listingId: serv.id, expiresAt: now.Add(rendezvousTimeout)}
+	m.rendezvous[msg.Client.Id()] = rv
+	clientPublic := msg.Client.PortlessAddress()
+	clientPrivate := klib.ByteArrayToString(req.PrivateAddress[:])
+	sameNetwork := clientPublic == serv.address
+	res := joinInfo(ResponseTypeJoinServerInfo, rv.id, serv.address, serv.privateAddress,
+		serv.gamePort, sameNetwork)
+	servRes := joinInfo(ResponseTypeClientJoinInfo, rv.id, clientPublic, clientPrivate,
+		req.GamePort, sameNetwork)
+	m.sendResponse(servRes, serv.client)
+	m.sendResponse(res, msg.Client)
+}
+
+func joinInfo(resType MasterServerResponseType, id uint64, public, private string, port uint16, privateFirst bool) Response {
+	res := Response{Type: resType, RendezvousId: id, Port: port, FallbackPort: port}
+	if private == "" {
+		private = public
+	}
+	if privateFirst {
+		public, private = private, public
+	}
+	copy(res.Address[:], public)
+	copy(res.FallbackAddress[:], private)
+	return res
+}
+
+// processJoinResult is the joining client reporting how the hole-punch went,
+// which frees the client to join again
+func (m *MasterServer) processJoinResult(req Request, msg network.ClientMessage) {
+	rv, ok := m.rendezvous[msg.Client.Id()]
+	if !ok || rv.id != req.RendezvousId {
+		return
+	}
+	delete(m.rendezvous, msg.Client.Id())
+	if req.JoinError != ErrorNone {
+		slog.Warn("a client failed to reach the game server it joined",
+			"address", msg.Client.Address(), "listing", rv.listingId, "error", req.JoinError)
+	}
+}
+
+func (m *MasterServer) expireRendezvous(now time.Time) {
+	for id, rv := range m.rendezvous {
+		if now.After(rv.expiresAt) {
+			delete(m.rendezvous, id)
+		}
+	}
+}
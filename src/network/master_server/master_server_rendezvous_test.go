@@ -0,0 +1,192 @@
+/******************************************************************************/
+/* master_server_rendezvous_test.go                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kaijuengine.com/klib"
+	"kaijuengine.com/network"
+)
+
+func registerWithPort(m *MasterServer, client *network.ServerClient, port uint16, private string) {
+	req := registerRequest("game", "server", "", nil)
+	req.GamePort = port
+	copy(req.PrivateAddress[:], private)
+	sendTestRequest(m, req, client)
+}
+
+func joinRequest(id uint64, port uint16, private string) Request {
+	req := Request{Type: RequestTypeJoinServer, ServerId: id, GamePort: port}
+	copy(req.PrivateAddress[:], private)
+	return req
+}
+
+func TestMasterServer_JoinRendezvous(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	game := testGameClient(1, "203.0.113.1")
+	registerWithPort(m, game, 7000, "192.168.1.5")
+	player := testGameClient(2, "198.51.100.7")
+	*sent = (*sent)[:0]
+	sendTestRequest(m, joinRequest(1, 7100, "10.1.1.20"), player)
+	if len(*sent) != 2 {
+		t.Fatalf("responses = %d, want one for each side", len(*sent))
+	}
+	toServer, toClient := (*sent)[0], (*sent)[1]
+	if toServer.client != game || toServer.res.Type != ResponseTypeClientJoinInfo {
+		t.Fatal("the game server was not told about the client")
+	}
+	if toClient.client != player || toClient.res.Type != ResponseTypeJoinServerInfo {
+		t.Fatal("the client was not told about the game server")
+	}
+	if toServer.res.RendezvousId == 0 || toServer.res.RendezvousId != toClient.res.RendezvousId {
+		t.Error("both sides should share the rendezvous id")
+	}
+	rv := rendezvousFromResponse(toClient.res, 7100)
+	if rv.Address != "203.0.113.1" || rv.Port != 7000 || rv.FallbackAddress != "192.168.1.5" {
+		t.Errorf("server endpoints = %+v, want the public endpoint first", rv)
+	}
+	rv = rendezvousFromResponse(toServer.res, 0)
+	if rv.Address != "198.51.100.7" || rv.Port != 7100 || rv.FallbackAddress != "10.1.1.20" {
+		t.Errorf("client endpoints = %+v, want the public endpoint first", rv)
+	}
+
+	*sent = (*sent)[:0]
+	sendTestRequest(m, joinRequest(1, 7100, ""), player)
+	if (*sent)[0].res.Error != ErrorJoinPending {
+		t.Errorf("error = %d, want the join to still be pending", (*sent)[0].res.Error)
+	}
+	sendTestRequest(m, Request{Type: RequestTypeJoinResult, RendezvousId: toClient.res.RendezvousId}, player)
+	if len(m.rendezvous) != 0 {
+		t.Error("the join result should end the rendezvous")
+	}
+}
+
+func TestMasterServer_JoinSameNetwork(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	registerWithPort(m, testGameClient(1, "203.0.113.1"), 7000, "192.168.1.5")
+	*sent = (*sent)[:0]
+	sendTestRequest(m, joinRequest(1, 7100, "192.168.1.9"), testGameClient(2, "203.0.113.1"))
+	rv := rendezvousFromResponse((*sent)[1].res, 7100)
+	if rv.Address != "192.168.1.5" || rv.FallbackAddress != "203.0.113.1" {
+		t.Errorf("server endpoints = %+v, want the LAN endpoint first", rv)
+	}
+}
+
+func TestMasterServer_JoinErrors(t *testing.T) {
+	m, sent := newTestMasterServer(Config{})
+	registerWithPort(m, testGameClient(1, "203.0.113.1"), 7000, "")
+	registerWithPort(m, testGameClient(2, "203.0.113.2"), 0, "")
+	full := registerRequest("game", "full", "", nil)
+	full.GamePort, full.CurrentPlayers = 7000, 8
+	sendTestRequest(m, full, testGameClient(3, "203.0.113.3"))
+	sendTestRequest(m, Request{Type: RequestTypePing, CurrentPlayers: 8}, testGameClient(3, "203.0.113.3"))
+	locked := registerRequest("game", "locked", "", nil)
+	locked.GamePort = 7000
+	copy(locked.Password[:], "hunter2")
+	sendTestRequest(m, locked, testGameClient(4, "203.0.113.4"))
+	tests := []struct {
+		id   uint64
+		want Error
+	}{
+		{99, ErrorServerDoesntExist},
+		{2, ErrorServerUnreachable},
+		{3, ErrorServerFull},
+		{4, ErrorIncorrectPassword},
+	}
+	for i, tt := range tests {
+		*sent = (*sent)[:0]
+		sendTestRequest(m, joinRequest(tt.id, 7100, ""), testGameClient(10+i, "198.51.100.7"))
+		if len(*sent) != 1 || (*sent)[0].res.Error != tt.want {
+			t.Errorf("joining %d: responses = %+v, want error %d", tt.id, *sent, tt.want)
+		}
+	}
+	if len(m.rendezvous) != 0 {
+		t.Error("a failed join should not start a rendezvous")
+	}
+}
+
+func TestMasterServer_RendezvousExpires(t *testing.T) {
+	m, _ := newTestMasterServer(Config{})
+	registerWithPort(m, testGameClient(1, "203.0.113.1"), 7000, "")
+	sendTestRequest(m, joinRequest(1, 7100, ""), testGameClient(2, "198.51.100.7"))
+	m.expireRendezvous(time.Now().Add(rendezvousTimeout * 2))
+	if len(m.rendezvous) != 0 {
+		t.Error("the rendezvous should expire when the result never arrives")
+	}
+}
+
+func TestMasterServerClient_PunchesJoiningClient(t *testing.T) {
+	server := network.NewServerUDP()
+	punched := map[string]int{}
+	c := &MasterServerClient{
+		GameServer:   &server,
+		OnClientJoin: func(string) {},
+		punch: func(target *network.ServerClient) error {
+			punched[target.Address()]++
+			return nil
+		},
+	}
+	res := Response{Type: ResponseTypeClientJoinInfo, RendezvousId: 1, Port: 7100, FallbackPort: 7100}
+	copy(res.Address[:], "198.51.100.7")
+	copy(res.FallbackAddress[:], "10.1.1.20")
+	now := time.Now()
+	c.punchClient(rendezvousFromResponse(res, 0), now)
+	c.updatePunches(now)
+	c.updatePunches(now.Add(punchInterval / 2))
+	c.updatePunches(now.Add(punchInterval))
+	if punched["198.51.100.7:7100"] != 2 || punched["10.1.1.20:7100"] != 2 {
+		t.Errorf("punches = %v, want 2 to each endpoint", punched)
+	}
+	c.updatePunches(now.Add(rendezvousTimeout * 2))
+	if len(c.punches) != 0 {
+		t.Error("punching should stop once the rendezvous is over")
+	}
+}
+
+func TestMasterServerClient_RendezvousFallback(t *testing.T) {
+	errs := []uint8{}
+	c := &MasterServerClient{OnError: func(e uint8) { errs = append(errs, e) }}
+	tried := []string{}
+	j := &rendezvousJoin{
+		rv: Rendezvous{Id: 3, Address: "203.0.113.1", Port: 7000, FallbackAddress: "192.168.1.5", FallbackPort: 7000},
+		connect: func(address string, port uint16) error {
+			tried = append(tried, address)
+			return nil
+		},
+		close:        func() {},
+		removeEvents: func() {},
+	}
+	c.join = j
+	c.connectJoin()
+	j.outcome.Store(joinOutcomeRetry)
+	c.updateJoin()
+	if !klib.SlicesAreTheSame(tried, []string{"203.0.113.1", "192.168.1.5"}) {
+		t.Fatalf("tried = %v, want the public then fallback endpoint", tried)
+	}
+	j.outcome.Store(joinOutcomeRetry)
+	c.updateJoin()
+	if c.join != nil || len(errs) != 1 || errs[0] != ErrorHolePunchFailed {
+		t.Errorf("errors = %v, want the hole-punch to fail once both endpoints fail", errs)
+	}
+}
+
+func TestMasterServerClient_RendezvousDialError(t *testing.T) {
+	errs := []uint8{}
+	c := &MasterServerClient{OnError: func(e uint8) { errs = append(errs, e) }}
+	c.join = &rendezvousJoin{
+		rv:           Rendezvous{Address: "203.0.113.1", Port: 7000},
+		connect:      func(string, uint16) error { return errors.New("port in use") },
+		close:        func() {},
+		removeEvents: func() {},
+	}
+	if err := c.connectJoin(); err == nil || len(errs) != 1 {
+		t.Error("failing to dial every endpoint should fail the join")
+	}
+}
@@ -19,6 +19,23 @@ const (
 	RequestTypePing
 	RequestTypeServerList
 	RequestTypeJoinServer
+	RequestTypeJoinResult
+	RequestTypeCreateLobby
+	RequestTypeListLobbies
+	RequestTypeJoinLobby
+	RequestTypeLeaveLobby
+	RequestTypeLobbyReady
+	RequestTypeLobbyChat
+	RequestTypeStartLobby
+	RequestTypeQuickMatch
+	RequestTypeCancelQuickMatch
+)
+
+const (
+	MaxTokenSize   = 64
+	MaxChatSize    = 128
+	playerNameSize = 32
+	regionSize     = 16
 )
 
 type Request struct {
@@ -29,6 +46,35 @@ type Request struct {
 	MaxPlayers     uint16
 	CurrentPlayers uint16
 	Type           MasterServerRequestType
+	// Tags describe the server when registering
+	Tags [MaxTags]Tag
+	// Filters, Offset and Limit select the listings for a server list
+	Filters [MaxFilters]Filter
+	Offset  uint32
+	Limit   uint16
+	// Token is the shared secret a game server presents when registering
+	Token [MaxTokenSize]byte
+	// GamePort is the port of the game socket, the server's listening port
+	// when registering or the client's local port when joining
+	GamePort uint16
+	// PrivateAddress is the LAN address of the sender, used as a fallback when
+	// both peers are behind the same NAT
+	PrivateAddress [addressMaxLen]byte
+	// RendezvousId identifies the join that a join result is reporting on
+	RendezvousId uint64
+	// JoinError is the outcome of the join that is being reported
+	JoinError Error
+	// LobbyId is the lobby to join
+	LobbyId uint64
+	// PlayerName, Skill and Region describe the player in a lobby or in the
+	// quick-match queue
+	PlayerName [playerNameSize]byte
+	Skill      uint32
+	Region     [regionSize]byte
+	// Ready is the ready state of the player in their lobby
+	Ready bool
+	// Message is a line of lobby chat
+	Message [MaxChatSize]byte
 }
 
 func (r *Request) Serialize(buffer []byte) {
@@ -40,6 +86,43 @@ func (r *Request) Serialize(buffer []byte) {
 	binary.LittleEndian.PutUint16(buffer[offset:], r.CurrentPlayers)
 	offset += int(unsafe.Sizeof(r.CurrentPlayers))
 	buffer[offset] = r.Type
+	offset++
+	for i := range r.Tags {
+		offset += copy(buffer[offset:], r.Tags[i].Key[:])
+		offset += copy(buffer[offset:], r.Tags[i].Value[:])
+	}
+	for i := range r.Filters {
+		offset += copy(buffer[offset:], r.Filters[i].Key[:])
+		offset += copy(buffer[offset:], r.Filters[i].Value[:])
+		buffer[offset] = r.Filters[i].Op
+		offset++
+	}
+	binary.LittleEndian.PutUint32(buffer[offset:], r.Offset)
+	offset += int(unsafe.Sizeof(r.Offset))
+	binary.LittleEndian.PutUint16(buffer[offset:], r.Limit)
+	offset += int(unsafe.Sizeof(r.Limit))
+	offset += copy(buffer[offset:], r.Token[:])
+	binary.LittleEndian.PutUint64(buffer[offset:], r.ServerId)
+	offset += int(unsafe.Sizeof(r.ServerId))
+	binary.LittleEndian.PutUint16(buffer[offset:], r.GamePort)
+	offset += int(unsafe.Sizeof(r.GamePort))
+	offset += copy(buffer[offset:], r.PrivateAddress[:])
+	binary.LittleEndian.PutUint64(buffer[offset:], r.RendezvousId)
+	offset += int(unsafe.Sizeof(r.RendezvousId))
+	buffer[offset] = r.JoinError
+	offset++
+	binary.LittleEndian.PutUint64(buffer[offset:], r.LobbyId)
+	offset += int(unsafe.Sizeof(r.LobbyId))
+	offset += copy(buffer[offset:], r.PlayerName[:])
+	binary.LittleEndian.PutUint32(buffer[offset:], r.Skill)
+	offset += int(unsafe.Sizeof(r.Skill))
+	offset += copy(buffer[offset:], r.Region[:])
+	buffer[offset] = 0
+	if r.Ready {
+		buffer[offset] = 1
+	}
+	offset++
+	copy(buffer[offset:], r.Message[:])
 }
 
 func DeserializeRequest(buffer []byte) Request {
@@ -52,5 +135,39 @@ func DeserializeRequest(buffer []byte) Request {
 	r.CurrentPlayers = binary.LittleEndian.Uint16(buffer[offset:])
 	offset += int(unsafe.Sizeof(r.CurrentPlayers))
 	r.Type = buffer[offset]
+	offset++
+	for i := range r.Tags {
+		offset += copy(r.Tags[i].Key[:], buffer[offset:])
+		offset += copy(r.Tags[i].Value[:], buffer[offset:])
+	}
+	for i := range r.Filters {
+		offset += copy(r.Filters[i].Key[:], buffer[offset:])
+		offset += copy(r.Filters[i].Value[:], buffer[offset:])
+		r.Filters[i].Op = buffer[offset]
+		offset++
+	}
+	r.Offset = binary.LittleEndian.Uint32(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.Offset))
+	r.Limit = binary.LittleEndian.Uint16(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.Limit))
+	offset += copy(r.Token[:], buffer[offset:])
+	r.ServerId = binary.LittleEndian.Uint64(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.ServerId))
+	r.GamePort = binary.LittleEndian.Uint16(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.GamePort))
+	offset += copy(r.PrivateAddress[:], buffer[offset:])
+	r.RendezvousId = binary.LittleEndian.Uint64(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.RendezvousId))
+	r.JoinError = buffer[offset]
+	offset++
+	r.LobbyId = binary.LittleEndian.Uint64(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.LobbyId))
+	offset += copy(r.PlayerName[:], buffer[offset:])
+	r.Skill = binary.LittleEndian.Uint32(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.Skill))
+	offset += copy(r.Region[:], buffer[offset:])
+	r.Ready = buffer[offset] != 0
+	offset++
+	copy(r.Message[:], buffer[offset:])
 	return r
 }
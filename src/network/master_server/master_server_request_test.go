@@ -12,8 +12,6 @@ import (
 )
 
 func TestRequestSerializeDeserialize(t *testing.T) {
-	// Note: Serialize/Deserialize do NOT include ServerId — it's a struct field
-	// that's not part of the wire format.
 	req := Request{
 		MaxPlayers:     64,
 		CurrentPlayers: 42,
@@ -28,7 +26,6 @@ func TestRequestSerializeDeserialize(t *testing.T) {
 
 	got := DeserializeRequest(buffer)
 
-	// ServerId is not serialized/deserialized, so it's always 0
 	if got.MaxPlayers != req.MaxPlayers {
 		t.Errorf("MaxPlayers = %d, want %d", got.MaxPlayers, req.MaxPlayers)
 	}
@@ -89,8 +86,8 @@ func TestRequestEmptyStrings(t *testing.T) {
 	}
 }
 
-func TestRequestServerIdNotSerialized(t *testing.T) {
-	// ServerId is a struct field but is NOT included in Serialize/Deserialize.
+func TestRequestServerIdSerialized(t *testing.T) {
+	// The join request has to carry the id of the server to join
 	req := Request{
 		ServerId: 0xFFFFFFFFFFFFFFFF,
 		Type:     RequestTypeJoinServer,
@@ -98,10 +95,8 @@ func TestRequestServerIdNotSerialized(t *testing.T) {
 	buf := make([]byte, unsafe.Sizeof(Request{}))
 	req.Serialize(buf)
 	got := DeserializeRequest(buf)
-
-	// ServerId round-trips as 0 since it's not part of the wire format
-	if got.ServerId != 0 {
-		t.Errorf("ServerId = %d, want 0 (not serialized)", got.ServerId)
+	if got.ServerId != req.ServerId {
+		t.Errorf("ServerId = %d, want %d", got.ServerId, req.ServerId)
 	}
 }
 
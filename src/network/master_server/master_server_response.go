@@ -19,9 +19,20 @@ const (
 	ResponseTypeJoinServerInfo
 	ResponseTypeClientJoinInfo
 	ResponseTypeError
+	ResponseTypeLobbyList
+	ResponseTypeLobbyUpdate
+	ResponseTypeLobbyChat
+	ResponseTypeLobbyStart
+	ResponseTypeMatchFound
 
 	serversPerResponse = 10
 	addressMaxLen      = 64
+	MaxLobbyMembers    = 16
+
+	// maxResponseSize is the most bytes a serialized [Response] can take, the
+	// listings and their tags are written with a leading count so the size is
+	// at most the size of the struct plus those counts
+	maxResponseSize = int(unsafe.Sizeof(Response{})) + 1 + serversPerResponse
 )
 
 type Response struct {
@@ -30,6 +41,41 @@ type Response struct {
 	Address   [addressMaxLen]byte
 	TotalList uint32
 	Error     uint8
+	// Offset is the position of the first listing in this response within
+	// all of the listings that matched the query
+	Offset uint32
+	// Port completes the endpoint in Address for the join info responses, the
+	// fallback endpoint is tried when the first one can't be reached
+	Port            uint16
+	FallbackAddress [addressMaxLen]byte
+	FallbackPort    uint16
+	// RendezvousId is shared by the pair of join info responses so that the
+	// server and client can report on the same join
+	RendezvousId uint64
+	// Lobby is the state of the lobby that the response is about
+	Lobby ResponseLobby
+	// Sender and Message are the line of chat for a lobby chat response
+	Sender  [playerNameSize]byte
+	Message [MaxChatSize]byte
+}
+
+type ResponseLobby struct {
+	Id     uint64
+	Name   [gameNameSize]byte
+	Region [regionSize]byte
+	// ListingId is the game server that the host started the lobby on
+	ListingId  uint64
+	MaxPlayers uint16
+	// MemberCount is the number of the member slots in use, the first member
+	// is the host of the lobby
+	MemberCount uint8
+	Members     [MaxLobbyMembers]LobbyMember
+}
+
+type LobbyMember struct {
+	Name  [playerNameSize]byte
+	Skill uint32
+	Ready bool
 }
 
 type ResponseServerList struct {
@@ -37,13 +83,30 @@ type ResponseServerList struct {
 	Id             uint64
 	MaxPlayers     uint16
 	CurrentPlayers uint16
+	Tags           [MaxTags]Tag
 }
 
+// Serialize writes the response into the buffer, which must be at least
+// [maxResponseSize] bytes. Only the listings up to the last one in use, and
+// only the tags that are in use by each listing, are written.
 func (r Response) Serialize(buffer []byte) []byte {
+	r.serialize(buffer)
+	return buffer
+}
+
+// serialize writes the response into the buffer and returns the number of
+// bytes that were written
+func (r Response) serialize(buffer []byte) int {
 	offset := 0
 	buffer[offset] = r.Type
 	offset++
-	for i := range r.List {
+	listCount := len(r.List)
+	for listCount > 0 && r.List[listCount-1] == (ResponseServerList{}) {
+		listCount--
+	}
+	buffer[offset] = uint8(listCount)
+	offset++
+	for i := range r.List[:listCount] {
 		offset += copy(buffer[offset:], r.List[i].Name[:])
 		binary.LittleEndian.PutUint64(buffer[offset:], r.List[i].Id)
 		offset += int(unsafe.Sizeof(r.List[i].Id))
@@ -51,20 +114,51 @@ func (r Response) Serialize(buffer []byte) []byte {
 		offset += int(unsafe.Sizeof(r.List[i].MaxPlayers))
 		binary.LittleEndian.PutUint16(buffer[offset:], r.List[i].CurrentPlayers)
 		offset += int(unsafe.Sizeof(r.List[i].CurrentPlayers))
+		tags := r.List[i].Tags[:]
+		for len(tags) > 0 && tags[len(tags)-1] == (Tag{}) {
+			tags = tags[:len(tags)-1]
+		}
+		buffer[offset] = uint8(len(tags))
+		offset++
+		for j := range tags {
+			offset += copy(buffer[offset:], r.List[i].Tags[j].Key[:])
+			offset += copy(buffer[offset:], r.List[i].Tags[j].Value[:])
+		}
 	}
 	offset += copy(buffer[offset:], r.Address[:])
 	binary.LittleEndian.PutUint32(buffer[offset:], r.TotalList)
 	offset += int(unsafe.Sizeof(r.TotalList))
 	buffer[offset] = r.Error
-	return buffer
+	offset++
+	binary.LittleEndian.PutUint32(buffer[offset:], r.Offset)
+	offset += int(unsafe.Sizeof(r.Offset))
+	binary.LittleEndian.PutUint16(buffer[offset:], r.Port)
+	offset += int(unsafe.Sizeof(r.Port))
+	offset += copy(buffer[offset:], r.FallbackAddress[:])
+	binary.LittleEndian.PutUint16(buffer[offset:], r.FallbackPort)
+	offset += int(unsafe.Sizeof(r.FallbackPort))
+	binary.LittleEndian.PutUint64(buffer[offset:], r.RendezvousId)
+	offset += int(unsafe.Sizeof(r.RendezvousId))
+	offset += r.Lobby.serialize(buffer[offset:])
+	offset += copy(buffer[offset:], r.Sender[:])
+	offset += copy(buffer[offset:], r.Message[:])
+	return offset
 }
 
+// DeserializeResponse reads a response written by [Response.Serialize], a
+// buffer that is cut short reads as if the missing bytes were zero
 func DeserializeResponse(buffer []byte) Response {
+	if len(buffer) < maxResponseSize {
+		buffer = append(make([]byte, 0, maxResponseSize), buffer...)
+		buffer = buffer[:maxResponseSize]
+	}
 	r := Response{}
 	offset := 0
 	r.Type = buffer[offset]
 	offset++
-	for i := range r.List {
+	listCount := min(int(buffer[offset]), len(r.List))
+	offset++
+	for i := range r.List[:listCount] {
 		offset += copy(r.List[i].Name[:], buffer[offset:])
 		r.List[i].Id = binary.LittleEndian.Uint64(buffer[offset:])
 		offset += int(unsafe.Sizeof(r.List[i].Id))
@@ -72,10 +166,76 @@ func DeserializeResponse(buffer []byte) Response {
 		offset += int(unsafe.Sizeof(r.List[i].MaxPlayers))
 		r.List[i].CurrentPlayers = binary.LittleEndian.Uint16(buffer[offset:])
 		offset += int(unsafe.Sizeof(r.List[i].CurrentPlayers))
+		tagCount := min(int(buffer[offset]), len(r.List[i].Tags))
+		offset++
+		for j := range r.List[i].Tags[:tagCount] {
+			offset += copy(r.List[i].Tags[j].Key[:], buffer[offset:])
+			offset += copy(r.List[i].Tags[j].Value[:], buffer[offset:])
+		}
 	}
 	offset += copy(r.Address[:], buffer[offset:])
 	r.TotalList = binary.LittleEndian.Uint32(buffer[offset:])
 	offset += int(unsafe.Sizeof(r.TotalList))
 	r.Error = buffer[offset]
+	offset++
+	r.Offset = binary.LittleEndian.Uint32(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.Offset))
+	r.Port = binary.LittleEndian.Uint16(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.Port))
+	offset += copy(r.FallbackAddress[:], buffer[offset:])
+	r.FallbackPort = binary.LittleEndian.Uint16(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.FallbackPort))
+	r.RendezvousId = binary.LittleEndian.Uint64(buffer[offset:])
+	offset += int(unsafe.Sizeof(r.RendezvousId))
+	offset += r.Lobby.deserialize(buffer[offset:])
+	offset += copy(r.Sender[:], buffer[offset:])
+	copy(r.Message[:], buffer[offset:])
 	return r
 }
+
+func (l *ResponseLobby) serialize(buffer []byte) int {
+	offset := 0
+	binary.LittleEndian.PutUint64(buffer[offset:], l.Id)
+	offset += int(unsafe.Sizeof(l.Id))
+	offset += copy(buffer[offset:], l.Name[:])
+	offset += copy(buffer[offset:], l.Region[:])
+	binary.LittleEndian.PutUint64(buffer[offset:], l.ListingId)
+	offset += int(unsafe.Sizeof(l.ListingId))
+	binary.LittleEndian.PutUint16(buffer[offset:], l.MaxPlayers)
+	offset += int(unsafe.Sizeof(l.MaxPlayers))
+	buffer[offset] = l.MemberCount
+	offset++
+	for i := range l.Members {
+		offset += copy(buffer[offset:], l.Members[i].Name[:])
+		binary.LittleEndian.PutUint32(buffer[offset:], l.Members[i].Skill)
+		offset += int(unsafe.Sizeof(l.Members[i].Skill))
+		buffer[offset] = 0
+		if l.Members[i].Ready {
+			buffer[offset] = 1
+		}
+		offset++
+	}
+	return offset
+}
+
+func (l *ResponseLobby) deserialize(buffer []byte) int {
+	offset := 0
+	l.Id = binary.LittleEndian.Uint64(buffer[offset:])
+	offset += int(unsafe.Sizeof(l.Id))
+	offset += copy(l.Name[:], buffer[offset:])
+	offset += copy(l.Region[:], buffer[offset:])
+	l.ListingId = binary.LittleEndian.Uint64(buffer[offset:])
+	offset += int(unsafe.Sizeof(l.ListingId))
+	l.MaxPlayers = binary.LittleEndian.Uint16(buffer[offset:])
+	offset += int(unsafe.Sizeof(l.MaxPlayers))
+	l.MemberCount = buffer[offset]
+	offset++
+	for i := range l.Members {
+		offset += copy(l.Members[i].Name[:], buffer[offset:])
+		l.Members[i].Skill = binary.LittleEndian.Uint32(buffer[offset:])
+		offset += int(unsafe.Sizeof(l.Members[i].Skill))
+		l.Members[i].Ready = buffer[offset] != 0
+		offset++
+	}
+	return offset
+}
@@ -0,0 +1,181 @@
+/******************************************************************************/
+/* master_server_tags.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"kaijuengine.com/klib"
+)
+
+const (
+	MaxTags    = 6
+	MaxFilters = 6
+
+	tagKeySize   = 16
+	tagValueSize = 24
+)
+
+// Built in keys that can be filtered on without the game server having to
+// set them as tags
+const (
+	TagKeyName       = "name"
+	TagKeyPlayers    = "players"
+	TagKeyMaxPlayers = "max_players"
+	TagKeyFreeSlots  = "free_slots"
+	TagKeyPassword   = "password"
+)
+
+type FilterOp = uint8
+
+const (
+	FilterOpNone = FilterOp(iota)
+	FilterOpEqual
+	FilterOpNotEqual
+	FilterOpLess
+	FilterOpLessEqual
+	FilterOpGreater
+	FilterOpGreaterEqual
+	FilterOpContains
+)
+
+// Tag is a key/value pair that describes a listing, such as the map, game
+// mode or region. An empty key is an unused tag slot.
+type Tag struct {
+	Key   [tagKeySize]byte
+	Value [tagValueSize]byte
+}
+
+// Filter limits a server list query to the listings whose tag (or built in
+// key) passes the comparison with the value. Numeric values are compared as
+// numbers, everything else is compared as text.
+type Filter struct {
+	Key   [tagKeySize]byte
+	Value [tagValueSize]byte
+	Op    FilterOp
+}
+
+// ServerQuery selects a page of the listings that pass every filter
+type ServerQuery struct {
+	Filters []Filter
+	// Offset is the number of matching listings to skip
+	Offset uint32
+	// Limit is the most listings to return, 0 returns them all
+	Limit uint16
+}
+
+func NewTag(key, value string) Tag {
+	t := Tag{}
+	copy(t.Key[:], key)
+	copy(t.Value[:], value)
+	return t
+}
+
+func NewFilter(key string, op FilterOp, value string) Filter {
+	f := Filter{Op: op}
+	copy(f.Key[:], key)
+	copy(f.Value[:], value)
+	return f
+}
+
+func (t Tag) KeyString() string   { return klib.ByteArrayToString(t.Key[:]) }
+func (t Tag) ValueString() string { return klib.ByteArrayToString(t.Value[:]) }
+
+func (f Filter) KeyString() string   { return klib.ByteArrayToString(f.Key[:]) }
+func (f Filter) ValueString() string { return klib.ByteArrayToString(f.Value[:]) }
+
+// TagsToMap converts the used tag slots into a map
+func TagsToMap(tags []Tag) map[string]string {
+	out := make(map[string]string, len(tags))
+	for i := range tags {
+		if key := tags[i].KeyString(); key != "" {
+			out[key] = tags[i].ValueString()
+		}
+	}
+	return out
+}
+
+// TagsFromMap fills the tag slots from the map in key order, any tags beyond
+// [MaxTags] are left out
+func TagsFromMap(tags map[string]string) [MaxTags]Tag {
+	out := [MaxTags]Tag{}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for i, k := range keys[:min(len(keys), MaxTags)] {
+		out[i] = NewTag(k, tags[k])
+	}
+	return out
+}
+
+func (l *ServerListing) attribute(key string) (string, bool) {
+	switch key {
+	case TagKeyName:
+		return l.name, true
+	case TagKeyPlayers:
+		return strconv.Itoa(int(l.currentPlayers)), true
+	case TagKeyMaxPlayers:
+		return strconv.Itoa(int(l.maxPlayers)), true
+	case TagKeyFreeSlots:
+		return strconv.Itoa(max(int(l.maxPlayers)-int(l.currentPlayers), 0)), true
+	case TagKeyPassword:
+		return strconv.FormatBool(l.password != ""), true
+	}
+	v, ok := l.tags[key]
+	return v, ok
+}
+
+func (l *ServerListing) matches(filters []Filter) bool {
+	for i := range filters {
+		if filters[i].Op != FilterOpNone && !filters[i].matches(l) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) matches(l *ServerListing) bool {
+	value, ok := l.attribute(f.KeyString())
+	if !ok {
+		// A missing tag can only pass a not equal check
+		return f.Op == FilterOpNotEqual
+	}
+	want := f.ValueString()
+	if f.Op == FilterOpContains {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(want))
+	}
+	cmp := strings.Compare(value, want)
+	a, aErr := strconv.ParseFloat(value, 64)
+	b, bErr := strconv.ParseFloat(want, 64)
+	if aErr == nil && bErr == nil {
+		cmp = 0
+		if a < b {
+			cmp = -1
+		} else if a > b {
+			cmp = 1
+		}
+	}
+	switch f.Op {
+	case FilterOpEqual:
+		return cmp == 0
+	case FilterOpNotEqual:
+		return cmp != 0
+	case FilterOpLess:
+		return cmp < 0
+	case FilterOpLessEqual:
+		return cmp <= 0
+	case FilterOpGreater:
+		return cmp > 0
+	case FilterOpGreaterEqual:
+		return cmp >= 0
+	}
+	return false
+}
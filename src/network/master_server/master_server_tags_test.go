@@ -0,0 +1,136 @@
+/******************************************************************************/
+/* master_server_tags_test.go                                                 */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package master_server
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestTagsMapRoundTrip(t *testing.T) {
+	in := map[string]string{"map": "harbor", "mode": "ctf", "region": "eu"}
+	tags := TagsFromMap(in)
+	if tags[0].KeyString() != "map" || tags[1].KeyString() != "mode" {
+		t.Errorf("tags are not in key order: %q, %q", tags[0].KeyString(), tags[1].KeyString())
+	}
+	out := TagsToMap(tags[:])
+	if len(out) != len(in) {
+		t.Fatalf("len = %d, want %d", len(out), len(in))
+	}
+	for k, v := range in {
+		if out[k] != v {
+			t.Errorf("tag %q = %q, want %q", k, out[k], v)
+		}
+	}
+}
+
+func TestTagsFromMap_Limit(t *testing.T) {
+	in := map[string]string{}
+	for i := range MaxTags + 3 {
+		in[string(rune('a'+i))] = "x"
+	}
+	tags := TagsFromMap(in)
+	if got := len(TagsToMap(tags[:])); got != MaxTags {
+		t.Errorf("len = %d, want %d", got, MaxTags)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	l := ServerListing{
+		name:           "Friday Night",
+		maxPlayers:     16,
+		currentPlayers: 9,
+		tags:           map[string]string{"map": "harbor", "region": "eu"},
+	}
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{NewFilter("map", FilterOpEqual, "harbor"), true},
+		{NewFilter("map", FilterOpEqual, "desert"), false},
+		{NewFilter("map", FilterOpNotEqual, "desert"), true},
+		{NewFilter("mode", FilterOpEqual, "ctf"), false},
+		{NewFilter("mode", FilterOpNotEqual, "ctf"), true},
+		{NewFilter(TagKeyPlayers, FilterOpGreaterEqual, "9"), true},
+		{NewFilter(TagKeyPlayers, FilterOpLess, "9"), false},
+		// Numbers compare numerically rather than as text
+		{NewFilter(TagKeyMaxPlayers, FilterOpGreater, "8"), true},
+		{NewFilter(TagKeyFreeSlots, FilterOpEqual, "7"), true},
+		{NewFilter(TagKeyPassword, FilterOpEqual, "false"), true},
+		{NewFilter(TagKeyName, FilterOpContains, "night"), true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(&l); got != tt.want {
+			t.Errorf("%s %d %s = %v, want %v", tt.filter.KeyString(), tt.filter.Op,
+				tt.filter.ValueString(), got, tt.want)
+		}
+	}
+	unused := Filter{}
+	if !l.matches([]Filter{unused, NewFilter("region", FilterOpEqual, "eu")}) {
+		t.Error("unused filter slots should be ignored")
+	}
+}
+
+func TestRequestQueryRoundTrip(t *testing.T) {
+	req := Request{
+		Type:   RequestTypeServerList,
+		Offset: 20,
+		Limit:  10,
+		Tags:   TagsFromMap(map[string]string{"mode": "ctf"}),
+	}
+	req.Filters[0] = NewFilter("region", FilterOpEqual, "eu")
+	copy(req.Token[:], "secret")
+	buf := make([]byte, unsafe.Sizeof(Request{}))
+	req.Serialize(buf)
+	got := DeserializeRequest(buf)
+	if got.Offset != 20 || got.Limit != 10 {
+		t.Errorf("Offset, Limit = %d, %d, want 20, 10", got.Offset, got.Limit)
+	}
+	if got.Filters[0] != req.Filters[0] || got.Tags != req.Tags || got.Token != req.Token {
+		t.Error("the tags, filters or token did not round trip")
+	}
+}
+
+func TestResponseTagsRoundTrip(t *testing.T) {
+	res := Response{Type: ResponseTypeServerList, Offset: 30}
+	res.List[3].Tags = TagsFromMap(map[string]string{"map": "harbor"})
+	res.Error = ErrorUnauthorized
+	buf := make([]byte, maxResponseSize)
+	got := DeserializeResponse(buf[:res.serialize(buf)])
+	if got.Offset != 30 || got.List[3].Tags != res.List[3].Tags || got.Error != ErrorUnauthorized {
+		t.Errorf("response = %+v, want the offset, tags and error to round trip", got)
+	}
+}
+
+func TestResponseSerialize_OnlyWritesUsedListings(t *testing.T) {
+	res := Response{Type: ResponseTypeServerList, TotalList: 1}
+	res.List[0] = ResponseServerList{Id: 1, MaxPlayers: 8,
+		Tags: TagsFromMap(map[string]string{"map": "harbor"})}
+	copy(res.List[0].Name[:], "game")
+	buf := make([]byte, maxResponseSize)
+	empty := Response{}.serialize(buf)
+	encoded := buf[:res.serialize(buf)]
+	entrySize := gameNameSize + 8 + 2 + 2 + 1 + tagKeySize + tagValueSize
+	if len(encoded) != empty+entrySize {
+		t.Errorf("encoded size = %d, want %d for a single listing with one tag",
+			len(encoded), empty+entrySize)
+	}
+	if got := DeserializeResponse(encoded); got != res {
+		t.Errorf("response = %+v, want %+v", got, res)
+	}
+	full := Response{}
+	for i := range full.List {
+		full.List[i].Id = uint64(i + 1)
+		for j := range full.List[i].Tags {
+			copy(full.List[i].Tags[j].Key[:], "k")
+		}
+	}
+	buf = make([]byte, maxResponseSize)
+	if got := DeserializeResponse(buf[:full.serialize(buf)]); got != full {
+		t.Error("a response with every listing and tag in use should round trip")
+	}
+}
@@ -7,21 +7,59 @@
 package network
 
 import (
-	"encoding/binary"
+	"crypto/ecdh"
+	"crypto/ed25519"
 	"log/slog"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/events"
 	"kaijuengine.com/platform/concurrent"
 )
 
+// NetworkClient connects to a [NetworkServer] over UDP. Calling
+// [NetworkClient.Connect] starts the connection handshake, reliable messages
+// sent before the server accepts the connection are retried until they are
+// acknowledged, unreliable messages sent before then may be dropped.
 type NetworkClient struct {
 	NetworkUDP
 	ServerClient
 	ServerMessageQueue concurrent.MessageQueue[ClientMessage]
+	Config             ConnectionConfig
+	// ConnectPayload is sent along with the connection request and is given
+	// to the server's [NetworkServer.AcceptConnection] check. It is sent
+	// before any session keys exist, so it is never encrypted.
+	ConnectPayload []byte
+	// LocalPort is the local port to send from, 0 lets the system pick one.
+	// A known port is needed when the server hole-punches to this client.
+	LocalPort uint16
+	// Secure enables an encrypted and authenticated session with the server,
+	// the server must also have [NetworkServer.Secure] enabled. Nothing other
+	// than the handshake is sent until the session keys have been exchanged.
+	Secure bool
+	// ServerIdentity is the public half of the server's
+	// [NetworkServer.IdentityKey], it is required for a Secure connection.
+	// Accepts and rejects that aren't signed by it are ignored.
+	ServerIdentity ed25519.PublicKey
+	// OnConnected is executed on update once the server accepts the connection
+	OnConnected events.Event
+	// OnDisconnected is executed on update when the connection is closed by
+	// the server, times out, is rejected, or fails to connect
+	OnDisconnected     events.EventWithArg[DisconnectReason]
+	state              atomic.Int32
+	rejectReason       atomic.Uint32
+	connectionEvents   concurrent.MessageQueue[connectionEvent]
+	connectStartedAt   time.Time
+	lastConnectAttempt time.Time
+	lastHeartbeat      time.Time
+	sessionKey         *ecdh.PrivateKey
+	// unverifiedReject is a reject that a Secure client couldn't verify, it
+	// is only reported if the server never accepts the connection
+	unverifiedReject atomic.Uint32
 }
 
 func NewClientUDP() NetworkClient {
@@ -30,9 +68,26 @@ func NewClientUDP() NetworkClient {
 			readBuffer:  make([]byte, maxPacketSize),
 			writeBuffer: make([]byte, maxPacketSize),
 		},
+		Config: DefaultConnectionConfig(),
 	}
 }
 
+// State returns the current state of the connection to the server
+func (c *NetworkClient) State() ConnectionState {
+	return ConnectionState(c.state.Load())
+}
+
+// IsConnected returns true once the server has accepted the connection
+func (c *NetworkClient) IsConnected() bool {
+	return c.State() == ConnectionStateConnected
+}
+
+// RejectReason returns the reason the server gave for rejecting the last
+// connection attempt
+func (c *NetworkClient) RejectReason() RejectReason {
+	return RejectReason(c.rejectReason.Load())
+}
+
 func (c *NetworkClient) Connect(updater *engine.Updater, address string, port uint16) error {
 	portStr := strconv.Itoa(int(port))
 	serverAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(address, portStr))
@@ -40,65 +95,185 @@ func (c *NetworkClient) Connect(updater *engine.Updater, address string, port ui
 		slog.Error("failed to resolve the UDP host address", "error", err, "address", address, "port", port)
 		return err
 	}
-	c.conn, err = net.DialUDP("udp", nil, serverAddr)
+	var localAddr *net.UDPAddr
+	if c.LocalPort != 0 {
+		localAddr = &net.UDPAddr{Port: int(c.LocalPort)}
+	}
+	conn, err := net.DialUDP("udp", localAddr, serverAddr)
 	if err != nil {
 		slog.Error("failed to dial the UDP server", "error", err, "address", address, "port", port)
 		return err
 	}
+	c.session.Store(nil)
+	c.unverifiedReject.Store(uint32(RejectReasonNone))
+	if c.Secure {
+		if len(c.ServerIdentity) != ed25519.PublicKeySize {
+			slog.Error("the secure connection has no server identity to verify", "error", ErrMissingIdentity)
+			conn.Close()
+			return ErrMissingIdentity
+		}
+		if c.sessionKey, err = newSessionKey(); err != nil {
+			slog.Error("failed to create the secure session key", "error", err)
+			conn.Close()
+			return err
+		}
+	}
+	c.resetOrdering()
+	c.conn.Store(conn)
+	now := time.Now()
+	c.state.Store(int32(ConnectionStateConnecting))
+	c.connectStartedAt = now
+	c.lastConnectAttempt = now
+	c.markHeard(now)
+	c.isReading.Store(true)
 	c.updateId = updater.AddUpdate(c.update)
 	go c.ReadMessages()
-	return nil
+	return c.sendPacket(c.createConnect())
+}
+
+func (c *NetworkClient) createConnect() NetworkPacketUDP {
+	if !c.Secure {
+		return c.createControl(udpPacketTypeConnect, c.ConnectPayload)
+	}
+	payload := append(c.sessionKey.PublicKey().Bytes(), c.ConnectPayload...)
+	return c.createControl(udpPacketTypeConnect|udpPacketTypeEncrypted, payload)
+}
+
+// Close tells the server that the client is leaving and then closes the
+// connection. [NetworkClient.OnDisconnected] is not executed for a connection
+// that is closed this way.
+func (c *NetworkClient) Close(updater *engine.Updater) {
+	if c.IsLive() && c.State() != ConnectionStateDisconnected {
+		c.sendPacket(c.createControl(udpPacketTypeDisconnect,
+			[]byte{uint8(DisconnectReasonClosed)}))
+	}
+	c.state.Store(int32(ConnectionStateDisconnected))
+	c.NetworkUDP.Close(updater)
+	c.removePendingPacketsFor(&c.ServerClient)
+}
+
+// resetOrdering forgets the reliable messages of the last connection, the
+// server counts the orders of a new connection from 0 again
+func (c *NetworkClient) resetOrdering() {
+	c.removePendingPacketsFor(&c.ServerClient)
+	c.pendingMutex.Lock()
+	c.sendOrder = 0
+	c.pendingMutex.Unlock()
+	c.recvOrder = 0
+	c.reliableBuffer = nil
+	c.fragments.reset()
 }
 
 func (c *NetworkClient) sendPacket(packet NetworkPacketUDP) error {
+	if !c.IsLive() {
+		return ErrNotLive
+	}
+	if sess := c.session.Load(); sess != nil {
+		packet = sess.seal(packet)
+	} else if c.Secure && !isHandshakePacket(&packet) {
+		// Reliable packets will be retried once the session is established
+		return nil
+	}
 	c.writeMutex.Lock()
 	defer c.writeMutex.Unlock()
 	n, err := packetToMessage(packet, c.writeBuffer)
 	if err != nil {
 		return err
 	}
-	_, err = c.conn.Write(c.writeBuffer[:n])
-	if err != nil {
+	if err = c.write(c.writeBuffer[:n], nil); err != nil {
 		slog.Error("error writing message from client to server", "error", err, "packet", packet)
 		return err
 	}
+	c.stats.packetsSent.Add(1)
 	return nil
 }
 
 func (c *NetworkClient) SendMessageUnreliable(message []byte) error {
+	if len(message) > MaxMessageSize {
+		slog.Error("unreliable messages can not be fragmented", "size", len(message), "max", MaxMessageSize)
+		return ErrMessageTooLarge
+	}
 	return c.sendPacket(c.createUnreliable(message))
 }
 
+// SendMessageReliable sends the message to the server and retries until it is
+// acknowledged. Messages larger than [MaxMessageSize] are transparently split
+// into fragments and reassembled before they reach the server's queue.
 func (c *NetworkClient) SendMessageReliable(message []byte) error {
-	return c.sendPacket(c.createReliable(message, &c.ServerClient))
+	if len(message) <= MaxMessageSize {
+		return c.sendPacket(c.createReliable(message, &c.ServerClient))
+	}
+	packets, err := c.createReliableFragments(message, &c.ServerClient)
+	if err != nil {
+		return err
+	}
+	for i := range packets {
+		if err = c.sendPacket(packets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *NetworkClient) ReadMessages() {
 	slog.Info("UDP network client starting message read pipeline")
-	c.isReading = true
+	// The connection is closed and cleared from the update thread, which is
+	// what unblocks the read below
+	conn := c.conn.Load()
+	if conn == nil {
+		return
+	}
 	buffer := make([]byte, maxPacketSize)
-	for c.isReading {
-		//c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-		n, err := c.conn.Read(buffer)
-		if !c.isReading {
+	for c.isReading.Load() {
+		//conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buffer)
+		if !c.isReading.Load() {
 			break
 		}
 		if err != nil {
 			slog.Error("the UDP network client failed to read", "error", err)
-			c.isReading = false
+			c.isReading.Store(false)
+			if c.State() == ConnectionStateConnecting {
+				c.setDisconnected(DisconnectReasonConnectFailed)
+			} else {
+				c.setDisconnected(DisconnectReasonClosed)
+			}
 			break
 		}
 		packet := packetFromMessage(buffer[:n])
-		if packet.isAck() {
-			if uintptr(packet.messageLen) == unsafe.Sizeof(packet.timestamp) {
-				id := int64(binary.LittleEndian.Uint64(packet.message[:]))
-				c.removePendingPacket(id)
+		if isHandshakePacket(&packet) && c.session.Load() != nil {
+			// The handshake is over, anything that isn't sealed by the
+			// session can't be trusted to have come from the server
+			continue
+		}
+		if !isHandshakePacket(&packet) {
+			if sess := c.session.Load(); sess != nil {
+				if err := sess.open(&packet); err != nil {
+					continue
+				}
+			} else if c.Secure {
+				continue
 			}
+		}
+		now := time.Now()
+		c.markHeard(now)
+		c.stats.packetsReceived.Add(1)
+		if packet.isControl() {
+			c.processControl(&packet)
+			continue
+		}
+		if c.State() == ConnectionStateConnecting {
+			// The accept was lost, but the server is talking to us so it must
+			// have accepted the connection
+			c.setConnected()
+		}
+		if packet.isAck() {
+			c.acknowledge(&packet, now)
 		} else {
 			if packet.isReliable() {
 				// The ack is just the timestamp of the message it read
 				c.sendPacket(c.createAck(buffer[:unsafe.Sizeof(packet.timestamp)]))
-				if packet.order >= c.reliableOrder {
+				if packet.order >= c.recvOrder {
 					c.flushPending(packet, &c.ServerMessageQueue)
 				}
 			} else {
@@ -109,18 +284,126 @@ func (c *NetworkClient) ReadMessages() {
 	slog.Info("UDP network client stopped reading messages")
 }
 
-func (s *NetworkClient) update(deltaTime float64) {
-	if len(s.pendingPackets) == 0 {
+func (c *NetworkClient) processControl(packet *NetworkPacketUDP) {
+	switch {
+	case packet.typeFlags&udpPacketTypeAccept != 0:
+		if c.Secure && !c.establishSession(packet) {
+			// Wait for an accept that is signed by the server
+			return
+		}
+		c.setConnected()
+	case packet.typeFlags&udpPacketTypeReject != 0:
+		if c.Secure && !c.verifyReject(packet) {
+			c.unverifiedReject.Store(uint32(packet.controlReason()))
+			return
+		}
+		c.rejectReason.Store(uint32(packet.controlReason()))
+		c.setDisconnected(DisconnectReasonRejected)
+	case packet.typeFlags&udpPacketTypeDisconnect != 0:
+		reason := DisconnectReason(packet.controlReason())
+		if reason != DisconnectReasonRemoved {
+			reason = DisconnectReasonClosed
+		}
+		c.setDisconnected(reason)
+	}
+}
+
+// establishSession verifies that the accept was signed by the server's
+// identity and then derives the session keys from the public key it holds
+func (c *NetworkClient) establishSession(accept *NetworkPacketUDP) bool {
+	if c.session.Load() != nil {
+		// The accept was resent because the server didn't hear from us yet
+		return true
+	}
+	if accept.typeFlags&udpPacketTypeEncrypted == 0 || accept.messageLen != sessionAcceptSize {
+		slog.Warn("ignored an accept without a secure session")
+		c.unverifiedReject.Store(uint32(RejectReasonSecurityMismatch))
+		return false
+	}
+	serverPublic, ok := verifyHandshake(c.ServerIdentity, accept, c.sessionKey.PublicKey().Bytes())
+	if !ok {
+		slog.Warn("ignored an accept that wasn't signed by the server identity")
+		return false
+	}
+	sess, err := newSession(c.sessionKey, serverPublic, false)
+	if err != nil {
+		slog.Error("failed to establish the secure session with the server", "error", err)
+		return false
+	}
+	c.session.Store(sess)
+	return true
+}
+
+// verifyReject checks that a reject was signed by the server's identity, the
+// reject doesn't end the connection attempt otherwise
+func (c *NetworkClient) verifyReject(reject *NetworkPacketUDP) bool {
+	if reject.typeFlags&udpPacketTypeEncrypted == 0 {
+		return false
+	}
+	_, ok := verifyHandshake(c.ServerIdentity, reject, c.sessionKey.PublicKey().Bytes())
+	return ok
+}
+
+func (c *NetworkClient) setConnected() {
+	if c.state.CompareAndSwap(int32(ConnectionStateConnecting), int32(ConnectionStateConnected)) {
+		c.connectionEvents.Enqueue(connectionEvent{eventType: connectionEventConnected})
+	}
+}
+
+func (c *NetworkClient) setDisconnected(reason DisconnectReason) {
+	if ConnectionState(c.state.Swap(int32(ConnectionStateDisconnected))) == ConnectionStateDisconnected {
 		return
 	}
-	now := time.Now()
-	s.pendingMutex.RLock()
-	defer s.pendingMutex.RUnlock()
-	for i := 0; i < len(s.pendingPackets); i++ {
-		pp := &s.pendingPackets[i]
-		if pp.packet.nextRetry.Before(now) {
-			pp.packet.nextRetry = now.Add(reliableRetryDelay)
-			s.sendPacket(pp.packet)
+	c.connectionEvents.Enqueue(connectionEvent{
+		eventType: connectionEventDisconnected,
+		reason:    reason,
+	})
+}
+
+func (c *NetworkClient) updateConnection(now time.Time) {
+	c.fragments.expire(now)
+	switch c.State() {
+	case ConnectionStateConnecting:
+		if now.Sub(c.connectStartedAt) > c.Config.ConnectTimeout {
+			if reason := c.unverifiedReject.Load(); reason != uint32(RejectReasonNone) {
+				slog.Warn("the server rejected the connection", "reason", reason)
+				c.rejectReason.Store(reason)
+				c.setDisconnected(DisconnectReasonRejected)
+			} else {
+				slog.Warn("timed out waiting for the server to accept the connection")
+				c.setDisconnected(DisconnectReasonConnectFailed)
+			}
+		} else if now.Sub(c.lastConnectAttempt) >= c.Config.ConnectRetryInterval {
+			c.lastConnectAttempt = now
+			c.sendPacket(c.createConnect())
+		}
+	case ConnectionStateConnected:
+		if c.timedOut(now, c.Config.Timeout) {
+			slog.Warn("the connection to the server timed out")
+			c.setDisconnected(DisconnectReasonTimeout)
+		} else if now.Sub(c.lastHeartbeat) >= c.Config.HeartbeatInterval {
+			c.lastHeartbeat = now
+			c.sendPacket(c.createControl(udpPacketTypeHeartbeat, nil))
+		}
+	}
+	pending := c.connectionEvents.Flush()
+	for i := range pending {
+		switch pending[i].eventType {
+		case connectionEventConnected:
+			c.OnConnected.Execute()
+		case connectionEventDisconnected:
+			// setDisconnected is called from the reading goroutine, so the
+			// pending packets are dropped here on the update goroutine
+			c.removePendingPacketsFor(&c.ServerClient)
+			c.OnDisconnected.Execute(pending[i].reason)
 		}
 	}
 }
+
+func (s *NetworkClient) update(deltaTime float64) {
+	now := time.Now()
+	s.updateConnection(now)
+	s.resendPending(now, func(pp *PendingNetworkPacketUDP) {
+		s.sendPacket(pp.packet)
+	})
+}
@@ -0,0 +1,134 @@
+/******************************************************************************/
+/* network_conditioner.go                                                     */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// defaultReorderDelay is how long a reordered packet is held back when
+// [NetworkSimulatorConfig.ReorderDelay] is not set
+const defaultReorderDelay = time.Millisecond * 10
+
+// NetworkConditioner sits between a [NetworkUDP] and its socket. Every
+// datagram that is sent is handed to the conditioner along with the function
+// that puts it on the socket, the conditioner decides if, when and how many
+// times that function is called. The datagram buffer is reused once Send
+// returns, so it must be copied if it is held on to.
+type NetworkConditioner interface {
+	Send(datagram []byte, write func(datagram []byte))
+}
+
+// NetworkSimulatorConfig describes the network conditions that a
+// [NetworkSimulator] will produce. The conditions apply to the packets sent
+// from the side that the simulator is set on, so to simulate a bad connection
+// in both directions set a simulator on both the server and the client.
+type NetworkSimulatorConfig struct {
+	// Latency is the delay added to every packet
+	Latency time.Duration
+	// Jitter is the most that the latency of a single packet can randomly
+	// vary from the configured latency, in either direction
+	Jitter time.Duration
+	// PacketLoss is the chance (0-1) that a packet is dropped
+	PacketLoss float64
+	// Duplication is the chance (0-1) that a packet is sent twice
+	Duplication float64
+	// Reorder is the chance (0-1) that a packet is held back by the
+	// ReorderDelay so that the packets sent after it arrive first
+	Reorder float64
+	// ReorderDelay is the extra delay given to reordered packets
+	ReorderDelay time.Duration
+	// Seed is used to create the random source so a run can be repeated, a
+	// seed of 0 will use a random seed
+	Seed uint64
+}
+
+// NetworkSimulator is a [NetworkConditioner] that injects latency, jitter,
+// packet loss, duplication and reordering into the outgoing packets. It is
+// intended for testing how the game behaves on a bad connection.
+type NetworkSimulator struct {
+	mutex  sync.Mutex
+	config NetworkSimulatorConfig
+	random *rand.Rand
+	// after schedules a delayed write, it is replaced in tests
+	after func(delay time.Duration, f func())
+}
+
+// NewNetworkSimulator creates a simulator using the given conditions, it can
+// be set on a server or client through [NetworkUDP.Conditioner]
+func NewNetworkSimulator(config NetworkSimulatorConfig) *NetworkSimulator {
+	seed := config.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &NetworkSimulator{
+		config: config,
+		random: rand.New(rand.NewPCG(seed, seed)),
+		after: func(delay time.Duration, f func()) {
+			time.AfterFunc(delay, f)
+		},
+	}
+}
+
+// Config returns the conditions that are currently being simulated
+func (s *NetworkSimulator) Config() NetworkSimulatorConfig {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.config
+}
+
+// SetConfig changes the simulated conditions, packets that are already
+// delayed are not affected. The seed is ignored after creation.
+func (s *NetworkSimulator) SetConfig(config NetworkSimulatorConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.config = config
+}
+
+func (s *NetworkSimulator) Send(datagram []byte, write func(datagram []byte)) {
+	s.mutex.Lock()
+	if s.chance(s.config.PacketLoss) {
+		s.mutex.Unlock()
+		return
+	}
+	delays := [2]time.Duration{s.delay()}
+	count := 1
+	if s.chance(s.config.Duplication) {
+		delays[1] = s.delay()
+		count++
+	}
+	s.mutex.Unlock()
+	for i := range count {
+		if delays[i] <= 0 {
+			write(datagram)
+			continue
+		}
+		data := append([]byte{}, datagram...)
+		s.after(delays[i], func() { write(data) })
+	}
+}
+
+func (s *NetworkSimulator) chance(probability float64) bool {
+	return probability > 0 && s.random.Float64() < probability
+}
+
+func (s *NetworkSimulator) delay() time.Duration {
+	delay := s.config.Latency
+	if s.config.Jitter > 0 {
+		delay += time.Duration(s.random.Int64N(int64(s.config.Jitter)*2+1)) - s.config.Jitter
+	}
+	if s.chance(s.config.Reorder) {
+		if s.config.ReorderDelay > 0 {
+			delay += s.config.ReorderDelay
+		} else {
+			delay += defaultReorderDelay
+		}
+	}
+	return max(delay, 0)
+}
@@ -0,0 +1,190 @@
+/******************************************************************************/
+/* network_conditioner_test.go                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"kaijuengine.com/engine"
+)
+
+type scheduledWrite struct {
+	delay time.Duration
+	f     func()
+}
+
+func newTestSimulator(config NetworkSimulatorConfig) (*NetworkSimulator, *[]scheduledWrite) {
+	config.Seed = 1
+	s := NewNetworkSimulator(config)
+	scheduled := []scheduledWrite{}
+	s.after = func(delay time.Duration, f func()) {
+		scheduled = append(scheduled, scheduledWrite{delay, f})
+	}
+	return s, &scheduled
+}
+
+func TestNetworkSimulator_PassThrough(t *testing.T) {
+	s, scheduled := newTestSimulator(NetworkSimulatorConfig{})
+	writes := 0
+	s.Send([]byte("hi"), func([]byte) { writes++ })
+	if writes != 1 || len(*scheduled) != 0 {
+		t.Errorf("writes = %d, scheduled = %d, want 1 immediate write", writes, len(*scheduled))
+	}
+}
+
+func TestNetworkSimulator_Loss(t *testing.T) {
+	s, _ := newTestSimulator(NetworkSimulatorConfig{PacketLoss: 0.5})
+	writes := 0
+	for range 1000 {
+		s.Send([]byte("hi"), func([]byte) { writes++ })
+	}
+	if writes < 400 || writes > 600 {
+		t.Errorf("writes = %d, expected about half of the packets to be dropped", writes)
+	}
+	s.SetConfig(NetworkSimulatorConfig{PacketLoss: 1})
+	writes = 0
+	s.Send([]byte("hi"), func([]byte) { writes++ })
+	if writes != 0 {
+		t.Error("the packet should have been dropped")
+	}
+}
+
+func TestNetworkSimulator_Duplication(t *testing.T) {
+	s, _ := newTestSimulator(NetworkSimulatorConfig{Duplication: 1})
+	writes := 0
+	s.Send([]byte("hi"), func([]byte) { writes++ })
+	if writes != 2 {
+		t.Errorf("writes = %d, want 2", writes)
+	}
+}
+
+func TestNetworkSimulator_LatencyAndJitter(t *testing.T) {
+	const latency = time.Millisecond * 50
+	const jitter = time.Millisecond * 10
+	s, scheduled := newTestSimulator(NetworkSimulatorConfig{Latency: latency, Jitter: jitter})
+	datagram := []byte("hi")
+	var written []byte
+	for range 100 {
+		s.Send(datagram, func(d []byte) { written = d })
+	}
+	if len(*scheduled) != 100 {
+		t.Fatalf("scheduled = %d, want 100", len(*scheduled))
+	}
+	varied := false
+	for _, w := range *scheduled {
+		if w.delay < latency-jitter || w.delay > latency+jitter {
+			t.Errorf("delay %v is outside of the jitter range", w.delay)
+		}
+		varied = varied || w.delay != latency
+	}
+	if !varied {
+		t.Error("jitter did not vary the delay")
+	}
+	// The datagram buffer is reused by the caller, so it must be copied
+	datagram[0] = 'x'
+	(*scheduled)[0].f()
+	if string(written) != "hi" {
+		t.Errorf("written = %q, want %q", written, "hi")
+	}
+}
+
+func TestNetworkSimulator_Reorder(t *testing.T) {
+	s, scheduled := newTestSimulator(NetworkSimulatorConfig{
+		Reorder:      1,
+		ReorderDelay: time.Millisecond * 30,
+	})
+	s.Send([]byte("hi"), func([]byte) {})
+	if len(*scheduled) != 1 || (*scheduled)[0].delay != time.Millisecond*30 {
+		t.Errorf("scheduled = %v, want one write delayed by 30ms", *scheduled)
+	}
+}
+
+func TestConnectionStats_RoundTrip(t *testing.T) {
+	n := NetworkUDP{}
+	target := ServerClient{}
+	p := n.createReliable([]byte("hello"), &target)
+	ack := n.createAck(binary.LittleEndian.AppendUint64(nil, uint64(p.timestamp)))
+	n.acknowledge(&ack, time.UnixMicro(p.timestamp).Add(time.Millisecond*80))
+	if len(n.pendingPackets) != 0 {
+		t.Fatal("the acknowledged packet is still pending")
+	}
+	stats := target.Stats()
+	if stats.RoundTripTime != time.Millisecond*80 {
+		t.Errorf("RoundTripTime = %v, want 80ms", stats.RoundTripTime)
+	}
+	if target.retryDelay() != time.Millisecond*120 {
+		t.Errorf("retryDelay = %v, want 120ms", target.retryDelay())
+	}
+	target.stats.recordRoundTrip(time.Millisecond * 160)
+	if rtt := target.Stats().RoundTripTime; rtt != time.Millisecond*90 {
+		t.Errorf("smoothed RoundTripTime = %v, want 90ms", rtt)
+	}
+}
+
+func TestConnectionStats_Resends(t *testing.T) {
+	n := NetworkUDP{}
+	target := ServerClient{}
+	p := n.createReliable([]byte("hello"), &target)
+	n.createReliable([]byte("world"), &target)
+	sent := 0
+	n.resendPending(time.Now().Add(time.Second), func(*PendingNetworkPacketUDP) { sent++ })
+	if sent != 2 {
+		t.Errorf("resent = %d, want 2", sent)
+	}
+	ack := n.createAck(binary.LittleEndian.AppendUint64(nil, uint64(p.timestamp)))
+	n.acknowledge(&ack, time.Now().Add(time.Second))
+	stats := target.Stats()
+	if stats.RoundTripTime != 0 {
+		t.Error("resent packets should not be used to measure the round trip time")
+	}
+	if stats.ReliableSent != 2 || stats.Resends != 2 || stats.PacketLoss != 50 {
+		t.Errorf("stats = %+v, want 2 sent, 2 resends and 50%% loss", stats)
+	}
+}
+
+func TestNetworkSimulator_ReliableLoopback(t *testing.T) {
+	updater := engine.NewUpdater()
+	s := NewServerUDP()
+	s.Conditioner = NewNetworkSimulator(NetworkSimulatorConfig{
+		Latency: time.Millisecond * 2, Jitter: time.Millisecond * 2,
+		PacketLoss: 0.2, Duplication: 0.1, Reorder: 0.1, Seed: 7,
+	})
+	if err := s.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer s.Close(&updater)
+	port := uint16(s.LocalAddress().Port)
+	c := NewClientUDP()
+	c.Conditioner = NewNetworkSimulator(NetworkSimulatorConfig{
+		Latency: time.Millisecond * 2, Jitter: time.Millisecond * 2,
+		PacketLoss: 0.2, Duplication: 0.1, Reorder: 0.1, Seed: 9,
+	})
+	defer c.Close(&updater)
+	if err := c.Connect(&updater, "127.0.0.1", port); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	const count = 50
+	for i := range count {
+		c.SendMessageReliable(fmt.Appendf(nil, "message %d", i))
+	}
+	var msgs []ClientMessage
+	pumpUntil(t, &updater, func() bool {
+		msgs = append(msgs, s.ClientMessageQueue.Flush()...)
+		return len(msgs) >= count
+	})
+	for i := range msgs {
+		if want := fmt.Sprintf("message %d", i); string(msgs[i].Message()) != want {
+			t.Fatalf("message %d = %q, want %q", i, msgs[i].Message(), want)
+		}
+	}
+	if stats := c.Stats(); stats.Resends == 0 || stats.RoundTripTime == 0 {
+		t.Errorf("stats = %+v, expected resends and a round trip time", stats)
+	}
+}
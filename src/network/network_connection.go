@@ -0,0 +1,128 @@
+/******************************************************************************/
+/* network_connection.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"time"
+)
+
+// ConnectionConfig controls the handshake, keep-alive and timeout behavior of
+// a [NetworkServer] or [NetworkClient]
+type ConnectionConfig struct {
+	// HeartbeatInterval is how often a heartbeat is sent to keep the
+	// connection alive
+	HeartbeatInterval time.Duration
+	// Timeout is how long to go without hearing anything from the other side
+	// of the connection before it is considered disconnected
+	Timeout time.Duration
+	// ConnectRetryInterval is how often the client resends its connection
+	// request while waiting to be accepted
+	ConnectRetryInterval time.Duration
+	// ConnectTimeout is how long the client will wait to be accepted before
+	// giving up on the connection
+	ConnectTimeout time.Duration
+}
+
+// DefaultConnectionConfig returns the connection settings that are used when
+// a server or client is created
+func DefaultConnectionConfig() ConnectionConfig {
+	return ConnectionConfig{
+		HeartbeatInterval:    time.Second,
+		Timeout:              time.Second * 10,
+		ConnectRetryInterval: time.Millisecond * 250,
+		ConnectTimeout:       time.Second * 5,
+	}
+}
+
+// ConnectionState is the state of a [NetworkClient] connection to the server
+type ConnectionState int32
+
+const (
+	ConnectionStateDisconnected = ConnectionState(iota)
+	ConnectionStateConnecting
+	ConnectionStateConnected
+)
+
+// DisconnectReason describes why a connection was closed
+type DisconnectReason uint8
+
+const (
+	// DisconnectReasonClosed is when the other side closed the connection
+	DisconnectReasonClosed = DisconnectReason(iota)
+	// DisconnectReasonTimeout is when nothing was heard from the other side
+	// within the [ConnectionConfig.Timeout]
+	DisconnectReasonTimeout
+	// DisconnectReasonRemoved is when the server removed the client
+	DisconnectReasonRemoved
+	// DisconnectReasonRejected is when the server rejected the connection
+	DisconnectReasonRejected
+	// DisconnectReasonConnectFailed is when the server never responded to the
+	// connection request within the [ConnectionConfig.ConnectTimeout]
+	DisconnectReasonConnectFailed
+)
+
+// RejectReason is sent by the server to explain why a connection was rejected
+type RejectReason uint8
+
+const (
+	RejectReasonNone = RejectReason(iota)
+	RejectReasonServerFull
+	RejectReasonDenied
+	// RejectReasonSecurityMismatch is when only one of the client and server
+	// has [NetworkServer.Secure] / [NetworkClient.Secure] enabled
+	RejectReasonSecurityMismatch
+)
+
+// ClientDisconnect is the argument to [NetworkServer.OnClientDisconnected]
+type ClientDisconnect struct {
+	Client *ServerClient
+	Reason DisconnectReason
+}
+
+type connectionEventType int
+
+const (
+	connectionEventConnected = connectionEventType(iota)
+	connectionEventDisconnected
+)
+
+// connectionEvent is queued up from the reading goroutine so that the
+// connection events can be executed on the thread that runs the update
+type connectionEvent struct {
+	eventType connectionEventType
+	client    *ServerClient
+	reason    DisconnectReason
+}
+
+func (n *NetworkUDP) createControl(typeFlags udpPacketTypeFlags, payload []byte) NetworkPacketUDP {
+	packet := NetworkPacketUDP{
+		timestamp:  n.nextTimestamp(),
+		messageLen: uint16(min(len(payload), MaxMessageSize)),
+		typeFlags:  typeFlags,
+	}
+	copy(packet.message[:], payload)
+	return packet
+}
+
+func (p *NetworkPacketUDP) isControl() bool {
+	return p.typeFlags&udpPacketTypeControlMask != 0
+}
+
+func (p *NetworkPacketUDP) controlReason() uint8 {
+	if p.messageLen == 0 {
+		return 0
+	}
+	return p.message[0]
+}
+
+func (c *ServerClient) markHeard(now time.Time) {
+	c.lastHeard.Store(now.UnixNano())
+}
+
+func (c *ServerClient) timedOut(now time.Time, timeout time.Duration) bool {
+	return now.Sub(time.Unix(0, c.lastHeard.Load())) > timeout
+}
@@ -0,0 +1,309 @@
+/******************************************************************************/
+/* network_connection_test.go                                                 */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"kaijuengine.com/engine"
+)
+
+func newTestConnectedClient(t *testing.T, s *NetworkServer, port int) *ServerClient {
+	t.Helper()
+	p := s.createControl(udpPacketTypeConnect, nil)
+	return newTestConnectedClientFrom(t, s, port, &p)
+}
+
+func newTestConnectedClientFrom(t *testing.T, s *NetworkServer, port int, p *NetworkPacketUDP) *ServerClient {
+	t.Helper()
+	addr, _ := net.ResolveUDPAddr("udp", net.JoinHostPort("127.0.0.1", "0"))
+	addr.Port = port
+	s.processConnect(addr, p)
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	return s.clients[addr.String()]
+}
+
+func pumpUntil(t *testing.T, updater *engine.Updater, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 2)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the network to respond")
+		}
+		updater.Update(0.001)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestProcessConnect_AcceptsAndFiresEvent(t *testing.T) {
+	s := NewServerUDP()
+	var connected *ServerClient
+	s.OnClientConnected.Add(func(c *ServerClient) { connected = c })
+	client := newTestConnectedClient(t, &s, 5000)
+	if client == nil {
+		t.Fatal("expected the client to be added")
+	}
+	if connected != nil {
+		t.Error("the connected event should wait for the update")
+	}
+	s.updateConnections(time.Now())
+	if connected != client {
+		t.Error("expected OnClientConnected to be executed with the new client")
+	}
+}
+
+func TestProcessConnect_MaxClients(t *testing.T) {
+	s := NewServerUDP()
+	s.MaxClients = 1
+	newTestConnectedClient(t, &s, 5000)
+	if newTestConnectedClient(t, &s, 5001) != nil {
+		t.Error("expected the second client to be rejected")
+	}
+	if s.ClientCount() != 1 {
+		t.Errorf("client count = %d, want 1", s.ClientCount())
+	}
+}
+
+func TestProcessConnect_AcceptConnection(t *testing.T) {
+	s := NewServerUDP()
+	s.AcceptConnection = func(address string, payload []byte) RejectReason {
+		if string(payload) != "secret" {
+			return RejectReasonDenied
+		}
+		return RejectReasonNone
+	}
+	addr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:5000")
+	bad := s.createControl(udpPacketTypeConnect, []byte("wrong"))
+	s.processConnect(addr, &bad)
+	if s.ClientCount() != 0 {
+		t.Fatal("expected the client with the wrong payload to be rejected")
+	}
+	good := s.createControl(udpPacketTypeConnect, []byte("secret"))
+	s.processConnect(addr, &good)
+	if s.ClientCount() != 1 {
+		t.Error("expected the client with the right payload to be accepted")
+	}
+}
+
+func TestProcessControl_Disconnect(t *testing.T) {
+	s := NewServerUDP()
+	var got ClientDisconnect
+	s.OnClientDisconnected.Add(func(d ClientDisconnect) { got = d })
+	client := newTestConnectedClient(t, &s, 5000)
+	p := s.createControl(udpPacketTypeDisconnect, nil)
+	s.processControl(&p, client)
+	s.updateConnections(time.Now())
+	if s.ClientCount() != 0 {
+		t.Error("expected the client to be removed")
+	}
+	if got.Client != client || got.Reason != DisconnectReasonClosed {
+		t.Errorf("disconnect = %+v, want the client with reason closed", got)
+	}
+}
+
+func TestUpdateConnections_Timeout(t *testing.T) {
+	s := NewServerUDP()
+	var got ClientDisconnect
+	s.OnClientDisconnected.Add(func(d ClientDisconnect) { got = d })
+	client := newTestConnectedClient(t, &s, 5000)
+	s.createReliable([]byte("pending"), client)
+	s.updateConnections(time.Now())
+	if s.ClientCount() != 1 {
+		t.Fatal("the client should not time out right away")
+	}
+	s.updateConnections(time.Now().Add(s.Config.Timeout * 2))
+	if s.ClientCount() != 0 {
+		t.Error("expected the client to time out")
+	}
+	if got.Client != client || got.Reason != DisconnectReasonTimeout {
+		t.Errorf("disconnect = %+v, want the client with reason timeout", got)
+	}
+	if len(s.pendingPackets) != 0 {
+		t.Error("expected the pending packets for the client to be dropped")
+	}
+}
+
+func TestRemoveClient_OnlyOnce(t *testing.T) {
+	s := NewServerUDP()
+	count := 0
+	s.OnClientDisconnected.Add(func(ClientDisconnect) { count++ })
+	client := newTestConnectedClient(t, &s, 5000)
+	s.RemoveClient(client)
+	s.RemoveClient(client)
+	s.updateConnections(time.Now())
+	if count != 1 {
+		t.Errorf("disconnect executed %d times, want 1", count)
+	}
+}
+
+func TestClient_ConnectTimeout(t *testing.T) {
+	c := NewClientUDP()
+	reason := DisconnectReason(255)
+	c.OnDisconnected.Add(func(r DisconnectReason) { reason = r })
+	now := time.Now()
+	c.state.Store(int32(ConnectionStateConnecting))
+	c.connectStartedAt = now
+	c.updateConnection(now.Add(c.Config.ConnectTimeout * 2))
+	if c.State() != ConnectionStateDisconnected {
+		t.Error("expected the client to give up connecting")
+	}
+	if reason != DisconnectReasonConnectFailed {
+		t.Errorf("reason = %d, want %d", reason, DisconnectReasonConnectFailed)
+	}
+}
+
+func TestClient_ProcessControl(t *testing.T) {
+	c := NewClientUDP()
+	connected := false
+	c.OnConnected.Add(func() { connected = true })
+	c.state.Store(int32(ConnectionStateConnecting))
+	c.markHeard(time.Now())
+	accept := c.createControl(udpPacketTypeAccept, nil)
+	c.processControl(&accept)
+	c.updateConnection(time.Now())
+	if !c.IsConnected() || !connected {
+		t.Fatal("expected the client to be connected")
+	}
+	reason := DisconnectReason(255)
+	c.OnDisconnected.Add(func(r DisconnectReason) { reason = r })
+	disconnect := c.createControl(udpPacketTypeDisconnect, []byte{uint8(DisconnectReasonRemoved)})
+	c.processControl(&disconnect)
+	c.updateConnection(time.Now())
+	if c.State() != ConnectionStateDisconnected || reason != DisconnectReasonRemoved {
+		t.Errorf("state = %d reason = %d, want disconnected and removed", c.State(), reason)
+	}
+}
+
+func TestClient_Rejected(t *testing.T) {
+	c := NewClientUDP()
+	c.state.Store(int32(ConnectionStateConnecting))
+	reject := c.createControl(udpPacketTypeReject, []byte{uint8(RejectReasonServerFull)})
+	c.processControl(&reject)
+	if c.State() != ConnectionStateDisconnected {
+		t.Error("expected the client to be disconnected")
+	}
+	if c.RejectReason() != RejectReasonServerFull {
+		t.Errorf("reject reason = %d, want %d", c.RejectReason(), RejectReasonServerFull)
+	}
+}
+
+func TestConnectionLifecycle_Loopback(t *testing.T) {
+	updater := engine.NewUpdater()
+	s := NewServerUDP()
+	if err := s.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer s.Close(&updater)
+	port := uint16(s.LocalAddress().Port)
+	var connected *ServerClient
+	var disconnected ClientDisconnect
+	s.OnClientConnected.Add(func(c *ServerClient) { connected = c })
+	s.OnClientDisconnected.Add(func(d ClientDisconnect) { disconnected = d })
+
+	c := NewClientUDP()
+	clientConnected := false
+	c.OnConnected.Add(func() { clientConnected = true })
+	if err := c.Connect(&updater, "127.0.0.1", port); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	pumpUntil(t, &updater, func() bool { return connected != nil && clientConnected })
+
+	c.SendMessageReliable([]byte("hello"))
+	var msgs []ClientMessage
+	pumpUntil(t, &updater, func() bool {
+		msgs = append(msgs, s.ClientMessageQueue.Flush()...)
+		return len(msgs) > 0
+	})
+	if string(msgs[0].Message()) != "hello" || msgs[0].Client != connected {
+		t.Errorf("unexpected message %q from %v", msgs[0].Message(), msgs[0].Client)
+	}
+
+	c.Close(&updater)
+	pumpUntil(t, &updater, func() bool { return disconnected.Client != nil })
+	if disconnected.Client != connected || disconnected.Reason != DisconnectReasonClosed {
+		t.Errorf("disconnect = %+v, want the client with reason closed", disconnected)
+	}
+}
+
+func TestReliableMessages_BidirectionalLoopback(t *testing.T) {
+	updater := engine.NewUpdater()
+	s := NewServerUDP()
+	if err := s.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer s.Close(&updater)
+	port := uint16(s.LocalAddress().Port)
+	var connected *ServerClient
+	s.OnClientConnected.Add(func(c *ServerClient) { connected = c })
+	c := NewClientUDP()
+	defer c.Close(&updater)
+	if err := c.Connect(&updater, "127.0.0.1", port); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	pumpUntil(t, &updater, func() bool { return connected != nil && c.IsConnected() })
+
+	// Both sides send at the same time, so neither has read the other's
+	// messages before sending its own
+	const count = 3
+	for i := range count {
+		c.SendMessageReliable(fmt.Appendf(nil, "to server %d", i))
+		s.SendMessageReliable(fmt.Appendf(nil, "to client %d", i), connected)
+	}
+	var toServer, toClient []ClientMessage
+	pumpUntil(t, &updater, func() bool {
+		toServer = append(toServer, s.ClientMessageQueue.Flush()...)
+		toClient = append(toClient, c.ServerMessageQueue.Flush()...)
+		return len(toServer) >= count && len(toClient) >= count
+	})
+	for i := range count {
+		if want := fmt.Sprintf("to server %d", i); string(toServer[i].Message()) != want {
+			t.Errorf("server message %d = %q, want %q", i, toServer[i].Message(), want)
+		}
+		if want := fmt.Sprintf("to client %d", i); string(toClient[i].Message()) != want {
+			t.Errorf("client message %d = %q, want %q", i, toClient[i].Message(), want)
+		}
+	}
+}
+
+func TestClient_ReconnectDeliversReliableMessages(t *testing.T) {
+	updater := engine.NewUpdater()
+	c := NewClientUDP()
+	defer c.Close(&updater)
+	for round := range 2 {
+		s := NewServerUDP()
+		if err := s.Serve(&updater, 0); err != nil {
+			t.Skipf("unable to listen on a local UDP port: %v", err)
+		}
+		var connected *ServerClient
+		s.OnClientConnected.Add(func(sc *ServerClient) { connected = sc })
+		if err := c.Connect(&updater, "127.0.0.1", uint16(s.LocalAddress().Port)); err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		pumpUntil(t, &updater, func() bool { return connected != nil && c.IsConnected() })
+		// Each new server starts counting its reliable orders from 0
+		const count = 3
+		for i := range count {
+			c.SendMessageReliable(fmt.Appendf(nil, "to server %d", i))
+			s.SendMessageReliable(fmt.Appendf(nil, "to client %d", i), connected)
+		}
+		var toServer, toClient []ClientMessage
+		pumpUntil(t, &updater, func() bool {
+			toServer = append(toServer, s.ClientMessageQueue.Flush()...)
+			toClient = append(toClient, c.ServerMessageQueue.Flush()...)
+			return len(toServer) >= count && len(toClient) >= count
+		})
+		c.Close(&updater)
+		s.Close(&updater)
+		if len(c.pendingPackets) != 0 {
+			t.Errorf("round %d: closing should drop the packets waiting on the server", round)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/******************************************************************************/
+/* network_fragment.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// id + index + count + totalLen
+	fragmentHeaderSize = 4 + 2 + 2 + 4
+	fragmentDataSize   = MaxMessageSize - fragmentHeaderSize
+
+	// MaxFragmentedMessageSize is the largest message that can be sent with
+	// SendMessageReliable, messages larger than [MaxMessageSize] are split
+	// into fragments and reassembled on the receiving side
+	MaxFragmentedMessageSize = 1024 * 1024
+
+	// maxPendingFragmentBytes is the most memory that a single peer can hold
+	// in messages that are still being reassembled
+	maxPendingFragmentBytes = MaxFragmentedMessageSize * 4
+	// maxPendingFragmentedMessages is the most messages that a single peer
+	// can have in the middle of being reassembled at one time
+	maxPendingFragmentedMessages = 8
+	// fragmentTimeout is how long an incomplete message is kept waiting for
+	// its remaining fragments before it is discarded
+	fragmentTimeout = time.Second * 10
+)
+
+var (
+	ErrMessageTooLarge = errors.New("the message is too large to be sent")
+)
+
+type fragmentHeader struct {
+	id       uint32
+	index    uint16
+	count    uint16
+	totalLen uint32
+}
+
+func (h fragmentHeader) write(buffer []byte) {
+	binary.LittleEndian.PutUint32(buffer, h.id)
+	binary.LittleEndian.PutUint16(buffer[4:], h.index)
+	binary.LittleEndian.PutUint16(buffer[6:], h.count)
+	binary.LittleEndian.PutUint32(buffer[8:], h.totalLen)
+}
+
+func readFragmentHeader(buffer []byte) (fragmentHeader, bool) {
+	if len(buffer) < fragmentHeaderSize {
+		return fragmentHeader{}, false
+	}
+	return fragmentHeader{
+		id:       binary.LittleEndian.Uint32(buffer),
+		index:    binary.LittleEndian.Uint16(buffer[4:]),
+		count:    binary.LittleEndian.Uint16(buffer[6:]),
+		totalLen: binary.LittleEndian.Uint32(buffer[8:]),
+	}, true
+}
+
+func fragmentCount(messageLen int) int {
+	return (messageLen + fragmentDataSize - 1) / fragmentDataSize
+}
+
+// createReliableFragments splits a message that is too large for a single
+// packet into a series of reliable packets. Each fragment takes its own place
+// in the reliable order, so they are delivered in sequence on the other side.
+func (n *NetworkUDP) createReliableFragments(message []byte, target *ServerClient) ([]NetworkPacketUDP, error) {
+	if len(message) > MaxFragmentedMessageSize {
+		slog.Error("the reliable message is too large to be fragmented",
+			"size", len(message), "max", MaxFragmentedMessageSize)
+		return nil, ErrMessageTooLarge
+	}
+	header := fragmentHeader{
+		id:       n.nextFragmentId.Add(1),
+		count:    uint16(fragmentCount(len(message))),
+		totalLen: uint32(len(message)),
+	}
+	packets := make([]NetworkPacketUDP, 0, header.count)
+	chunk := [MaxMessageSize]byte{}
+	for i := range int(header.count) {
+		start := i * fragmentDataSize
+		end := min(start+fragmentDataSize, len(message))
+		header.index = uint16(i)
+		header.write(chunk[:])
+		l := copy(chunk[fragmentHeaderSize:], message[start:end])
+		packets = append(packets, n.createReliablePacket(chunk[:fragmentHeaderSize+l],
+			target, udpPacketTypeReliable|udpPacketTypeFragment))
+	}
+	return packets, nil
+}
+
+type fragmentedMessage struct {
+	header    fragmentHeader
+	data      []byte
+	received  uint16
+	expiresAt time.Time
+}
+
+// fragmentAssembler holds the partially received fragmented messages for a
+// single peer. The memory it can hold is bounded by the max pending message
+// count and byte limits, anything over those limits is dropped.
+type fragmentAssembler struct {
+	mutex        sync.Mutex
+	pending      map[uint32]*fragmentedMessage
+	pendingBytes int
+}
+
+// add takes in the next fragment for a message and returns the full message
+// once the final fragment has been received
+func (a *fragmentAssembler) add(packet *NetworkPacketUDP, now time.Time) ([]byte, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.evictExpired(now)
+	payload := packet.message[:packet.messageLen]
+	header, ok := readFragmentHeader(payload)
+	if !ok || header.totalLen > MaxFragmentedMessageSize || header.count == 0 ||
+		int(header.count) != fragmentCount(int(header.totalLen)) || header.index >= header.count {
+		slog.Error("received an invalid message fragment, dropping it")
+		return nil, false
+	}
+	payload = payload[fragmentHeaderSize:]
+	msg, ok := a.pending[header.id]
+	if !ok {
+		if header.index != 0 {
+			// The start of this message was dropped, so the rest is useless
+			return nil, false
+		}
+		if len(a.pending) >= maxPendingFragmentedMessages ||
+			a.pendingBytes+int(header.totalLen) > maxPendingFragmentBytes {
+			slog.Error("too many fragmented messages are pending, dropping message",
+				"size", header.totalLen, "pendingBytes", a.pendingBytes)
+			return nil, false
+		}
+		if a.pending == nil {
+			a.pending = make(map[uint32]*fragmentedMessage)
+		}
+		msg = &fragmentedMessage{
+			header: header,
+			data:   make([]byte, 0, header.totalLen),
+		}
+		a.pending[header.id] = msg
+		a.pendingBytes += int(header.totalLen)
+	}
+	if header.index != msg.received || header.count != msg.header.count ||
+		len(msg.data)+len(payload) > int(msg.header.totalLen) {
+		slog.Error("received an out of sequence message fragment, dropping message", "id", header.id)
+		a.remove(header.id)
+		return nil, false
+	}
+	msg.data = append(msg.data, payload...)
+	msg.received++
+	msg.expiresAt = now.Add(fragmentTimeout)
+	if msg.received < msg.header.count {
+		return nil, false
+	}
+	a.remove(header.id)
+	if len(msg.data) != int(msg.header.totalLen) {
+		slog.Error("the reassembled message is not the expected size, dropping message", "id", header.id)
+		return nil, false
+	}
+	return msg.data, true
+}
+
+func (a *fragmentAssembler) remove(id uint32) {
+	if msg, ok := a.pending[id]; ok {
+		a.pendingBytes -= int(msg.header.totalLen)
+		delete(a.pending, id)
+	}
+}
+
+// reset drops every partially received message
+func (a *fragmentAssembler) reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	clear(a.pending)
+	a.pendingBytes = 0
+}
+
+// expire drops any incomplete messages that have not received a fragment
+// within the fragment timeout
+func (a *fragmentAssembler) expire(now time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.evictExpired(now)
+}
+
+func (a *fragmentAssembler) evictExpired(now time.Time) {
+	for id, msg := range a.pending {
+		if msg.expiresAt.Before(now) {
+			slog.Warn("timed out waiting for message fragments, dropping message",
+				"id", id, "received", msg.received, "count", msg.header.count)
+			a.remove(id)
+		}
+	}
+}
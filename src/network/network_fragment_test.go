@@ -0,0 +1,183 @@
+/******************************************************************************/
+/* network_fragment_test.go                                                   */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func createLargeTestMessage(size int) []byte {
+	msg := make([]byte, size)
+	for i := range msg {
+		msg[i] = byte(i % 251)
+	}
+	return msg
+}
+
+func TestCreateReliableFragments_Split(t *testing.T) {
+	n := &NetworkUDP{}
+	client := &ServerClient{sendOrder: 3}
+	msg := createLargeTestMessage(MaxMessageSize*3 + 10)
+	packets, err := n.createReliableFragments(msg, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(packets) != fragmentCount(len(msg)) {
+		t.Fatalf("expected %d fragments, got %d", fragmentCount(len(msg)), len(packets))
+	}
+	for i := range packets {
+		p := &packets[i]
+		if !p.isReliable() || !p.isFragment() {
+			t.Errorf("fragment %d flags = %b, want reliable fragment", i, p.typeFlags)
+		}
+		if p.order != uint64(3+i) {
+			t.Errorf("fragment %d order = %d, want %d", i, p.order, 3+i)
+		}
+		if int(p.messageLen) > MaxMessageSize {
+			t.Errorf("fragment %d is %d bytes, larger than %d", i, p.messageLen, MaxMessageSize)
+		}
+	}
+	if len(n.pendingPackets) != len(packets) {
+		t.Errorf("expected %d pending packets, got %d", len(packets), len(n.pendingPackets))
+	}
+}
+
+func TestCreateReliableFragments_TooLarge(t *testing.T) {
+	n := &NetworkUDP{}
+	client := &ServerClient{}
+	_, err := n.createReliableFragments(make([]byte, MaxFragmentedMessageSize+1), client)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+	if client.sendOrder != 0 {
+		t.Error("expected no reliable order to be used for a rejected message")
+	}
+}
+
+func TestSendMessageUnreliable_TooLarge(t *testing.T) {
+	s := NewServerUDP()
+	err := s.SendMessageUnreliable(make([]byte, MaxMessageSize+1), &ServerClient{})
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestFragments_ReassembledThroughFlushPending(t *testing.T) {
+	sender := &NetworkUDP{}
+	s := NewServerUDP()
+	c := &ServerClient{}
+	msg := createLargeTestMessage(5000)
+	packets, _ := sender.createReliableFragments(msg, &ServerClient{})
+	// Deliver the fragments out of order, the reliable ordering puts them back
+	for _, i := range []int{2, 0, 4, 1, 3, 5} {
+		if i < len(packets) {
+			c.flushPending(packets[i], &s.ClientMessageQueue)
+		}
+	}
+	msgs := s.ClientMessageQueue.Flush()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 reassembled message, got %d", len(msgs))
+	}
+	if !bytes.Equal(msgs[0].Message(), msg) {
+		t.Error("the reassembled message does not match the original")
+	}
+	if msgs[0].Client != c {
+		t.Error("the reassembled message should reference the sending client")
+	}
+	if len(c.fragments.pending) != 0 {
+		t.Errorf("expected no pending fragments, got %d", len(c.fragments.pending))
+	}
+}
+
+func TestFragments_InterleavedWithSmallMessages(t *testing.T) {
+	sender := &NetworkUDP{}
+	target := &ServerClient{}
+	s := NewServerUDP()
+	c := &ServerClient{}
+	packets := []NetworkPacketUDP{sender.createReliable([]byte("before"), target)}
+	large := createLargeTestMessage(2500)
+	fragments, _ := sender.createReliableFragments(large, target)
+	packets = append(packets, fragments...)
+	packets = append(packets, sender.createReliable([]byte("after"), target))
+	for i := range packets {
+		c.flushPending(packets[i], &s.ClientMessageQueue)
+	}
+	msgs := s.ClientMessageQueue.Flush()
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	}
+	if string(msgs[0].Message()) != "before" || string(msgs[2].Message()) != "after" {
+		t.Errorf("unexpected message order: %q, %q", msgs[0].Message(), msgs[2].Message())
+	}
+	if !bytes.Equal(msgs[1].Message(), large) {
+		t.Error("the reassembled message does not match the original")
+	}
+}
+
+func TestFragmentAssembler_Timeout(t *testing.T) {
+	sender := &NetworkUDP{}
+	packets, _ := sender.createReliableFragments(createLargeTestMessage(3000), &ServerClient{})
+	a := fragmentAssembler{}
+	now := time.Now()
+	if _, ok := a.add(&packets[0], now); ok {
+		t.Fatal("the message should not be complete after one fragment")
+	}
+	a.expire(now.Add(fragmentTimeout / 2))
+	if len(a.pending) != 1 {
+		t.Fatal("the message should not expire before the timeout")
+	}
+	a.expire(now.Add(fragmentTimeout * 2))
+	if len(a.pending) != 0 || a.pendingBytes != 0 {
+		t.Errorf("expected the message to expire, %d pending with %d bytes", len(a.pending), a.pendingBytes)
+	}
+	// The rest of the fragments are useless without the start
+	for i := 1; i < len(packets); i++ {
+		if _, ok := a.add(&packets[i], now.Add(fragmentTimeout*2)); ok {
+			t.Error("expected the expired message to not complete")
+		}
+	}
+}
+
+func TestFragmentAssembler_BoundedPendingMessages(t *testing.T) {
+	sender := &NetworkUDP{}
+	a := fragmentAssembler{}
+	now := time.Now()
+	for range maxPendingFragmentedMessages + 2 {
+		packets, _ := sender.createReliableFragments(createLargeTestMessage(2000), &ServerClient{})
+		a.add(&packets[0], now)
+	}
+	if len(a.pending) != maxPendingFragmentedMessages {
+		t.Errorf("expected %d pending messages, got %d", maxPendingFragmentedMessages, len(a.pending))
+	}
+}
+
+func TestFragmentAssembler_InvalidHeader(t *testing.T) {
+	a := fragmentAssembler{}
+	p := createTestPacket(0, "bad")
+	p.typeFlags = udpPacketTypeReliable | udpPacketTypeFragment
+	if _, ok := a.add(&p, time.Now()); ok {
+		t.Error("expected a fragment without a valid header to be dropped")
+	}
+	if len(a.pending) != 0 {
+		t.Error("expected nothing to be pending")
+	}
+}
+
+func TestNextTimestamp_Unique(t *testing.T) {
+	n := &NetworkUDP{}
+	last := int64(0)
+	for range 1000 {
+		ts := n.nextTimestamp()
+		if ts <= last {
+			t.Fatalf("timestamp %d is not after %d", ts, last)
+		}
+		last = ts
+	}
+}
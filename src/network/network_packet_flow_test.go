@@ -87,17 +87,17 @@ func TestPacketSerializationRoundTrip_Comprehensive(t *testing.T) {
 
 func TestFlushPending_ReliableOrderInvariant(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
-	// Invariant: reliableBuffer only contains packets with order >= reliableOrder
+	// Invariant: reliableBuffer only contains packets with order >= recvOrder
 	orders := []uint64{3, 1, 4, 1, 5, 9, 2, 6}
 	for _, o := range orders {
 		p := createTestPacket(o, string(rune('A'+(o%26))))
 		c.flushPending(p, &s.ClientMessageQueue)
 
 		for i, bp := range c.reliableBuffer {
-			if bp.order < c.reliableOrder {
-				t.Errorf("buffer[%d].order (%d) < reliableOrder (%d)", i, bp.order, c.reliableOrder)
+			if bp.order < c.recvOrder {
+				t.Errorf("buffer[%d].order (%d) < recvOrder (%d)", i, bp.order, c.recvOrder)
 			}
 		}
 	}
@@ -105,35 +105,35 @@ func TestFlushPending_ReliableOrderInvariant(t *testing.T) {
 
 func TestFlushPending_BufferSortedDescending(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
-	// Send packet 0 first (matches reliableOrder, gets processed)
+	// Send packet 0 first (matches recvOrder, gets processed)
 	p0 := createTestPacket(0, "A")
 	c.flushPending(p0, &s.ClientMessageQueue)
-	// reliableOrder is now 1, buffer is empty
+	// recvOrder is now 1, buffer is empty
 
-	// Send future packets: 5, 3, 4 (all > reliableOrder=1, buffered without sorting)
+	// Send future packets: 5, 3, 4 (all > recvOrder=1, buffered without sorting)
 	c.flushPending(createTestPacket(5, "E"), &s.ClientMessageQueue)
 	c.flushPending(createTestPacket(3, "C"), &s.ClientMessageQueue)
 	c.flushPending(createTestPacket(4, "D"), &s.ClientMessageQueue)
-	// Buffer: [5, 3, 4] (not sorted yet - sort only happens when order==reliableOrder)
+	// Buffer: [5, 3, 4] (not sorted yet - sort only happens when order==recvOrder)
 
-	// Send packet 1 (matches reliableOrder=1, triggers sort and process)
+	// Send packet 1 (matches recvOrder=1, triggers sort and process)
 	c.flushPending(createTestPacket(1, "B"), &s.ClientMessageQueue)
 	// Appends to buffer [5, 3, 4, 1], sorts desc [5, 4, 3, 1], processes order 1,
-	// reliableOrder becomes 2, buffer truncated to [5, 4, 3]
+	// recvOrder becomes 2, buffer truncated to [5, 4, 3]
 
 	if len(c.reliableBuffer) != 3 {
 		t.Errorf("expected 3 remaining in buffer, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 2 {
-		t.Errorf("reliableOrder = %d, want 2", c.reliableOrder)
+	if c.recvOrder != 2 {
+		t.Errorf("recvOrder = %d, want 2", c.recvOrder)
 	}
 }
 
 func TestFlushPending_DuplicatesNotInQueue(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send 0 five times
 	for i := 0; i < 5; i++ {
@@ -149,7 +149,7 @@ func TestFlushPending_DuplicatesNotInQueue(t *testing.T) {
 
 func TestFlushPending_SliceOperationsCorrect(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send packets 2, 1, 3, 0 (out of order)
 	c.flushPending(createTestPacket(2, "C"), &s.ClientMessageQueue)
@@ -161,8 +161,8 @@ func TestFlushPending_SliceOperationsCorrect(t *testing.T) {
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected empty buffer, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 4 {
-		t.Errorf("reliableOrder = %d, want 4", c.reliableOrder)
+	if c.recvOrder != 4 {
+		t.Errorf("recvOrder = %d, want 4", c.recvOrder)
 	}
 
 	msgs := s.ClientMessageQueue.Flush()
@@ -208,7 +208,7 @@ func TestFlushPending_SortFunction(t *testing.T) {
 
 func TestFlushPending_EdgeCase_ZeroOrder(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send order 0
 	p := createTestPacket(0, "A")
@@ -218,8 +218,8 @@ func TestFlushPending_EdgeCase_ZeroOrder(t *testing.T) {
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected empty buffer after order 0, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 1 {
-		t.Errorf("reliableOrder = %d, want 1", c.reliableOrder)
+	if c.recvOrder != 1 {
+		t.Errorf("recvOrder = %d, want 1", c.recvOrder)
 	}
 
 	msgs := s.ClientMessageQueue.Flush()
@@ -233,7 +233,7 @@ func TestFlushPending_EdgeCase_ZeroOrder(t *testing.T) {
 
 func TestFlushPending_CloningBehavior(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send out-of-order packet - it should be cloned
 	original := createTestPacket(5, "Future")
@@ -14,13 +14,35 @@ import (
 	"unsafe"
 )
 
-const maxPacketSize = 1024
+const (
+	maxPacketSize = 1024
+
+	// timestamp + order + messageLen + typeFlags
+	packetHeaderSize = 8 + 8 + 2 + 4
+
+	// MaxMessageSize is the largest message that can be sent in a single
+	// packet, it is the packet size minus the space used for the header and
+	// the authentication tag of a secure session
+	MaxMessageSize = maxPacketSize - packetHeaderSize - sessionTagSize
+)
 
 type udpPacketTypeFlags = uint32
 
 const (
 	udpPacketTypeReliable = udpPacketTypeFlags(1 << 0)
 	udpPacketTypeAck      = udpPacketTypeFlags(1 << 1)
+	udpPacketTypeFragment = udpPacketTypeFlags(1 << 2)
+
+	udpPacketTypeConnect    = udpPacketTypeFlags(1 << 3)
+	udpPacketTypeAccept     = udpPacketTypeFlags(1 << 4)
+	udpPacketTypeReject     = udpPacketTypeFlags(1 << 5)
+	udpPacketTypeHeartbeat  = udpPacketTypeFlags(1 << 6)
+	udpPacketTypeDisconnect = udpPacketTypeFlags(1 << 7)
+
+	udpPacketTypeEncrypted = udpPacketTypeFlags(1 << 8)
+
+	udpPacketTypeControlMask = udpPacketTypeConnect | udpPacketTypeAccept |
+		udpPacketTypeReject | udpPacketTypeHeartbeat | udpPacketTypeDisconnect
 )
 
 type NetworkPacketUDP struct {
@@ -40,6 +62,10 @@ func (p *NetworkPacketUDP) isAck() bool {
 	return p.typeFlags&udpPacketTypeAck != 0
 }
 
+func (p *NetworkPacketUDP) isFragment() bool {
+	return p.typeFlags&udpPacketTypeFragment != 0
+}
+
 func (p *NetworkPacketUDP) clone() NetworkPacketUDP {
 	c := NetworkPacketUDP{
 		timestamp:  p.timestamp,
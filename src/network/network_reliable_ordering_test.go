@@ -21,7 +21,7 @@ func createTestPacket(order uint64, msg string) NetworkPacketUDP {
 
 func TestFlushPending_SequentialArrival(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	for i := uint64(0); i < 5; i++ {
 		p := createTestPacket(i, string(rune('A'+i)))
@@ -31,8 +31,8 @@ func TestFlushPending_SequentialArrival(t *testing.T) {
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected empty buffer after sequential flush, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 5 {
-		t.Errorf("reliableOrder = %d, want 5", c.reliableOrder)
+	if c.recvOrder != 5 {
+		t.Errorf("recvOrder = %d, want 5", c.recvOrder)
 	}
 
 	msgs := s.ClientMessageQueue.Flush()
@@ -49,7 +49,7 @@ func TestFlushPending_SequentialArrival(t *testing.T) {
 
 func TestFlushPending_GapThenFill(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send 0, then 3 (gap), then 1, then 2
 	orders := []uint64{0, 3, 1, 2}
@@ -76,7 +76,7 @@ func TestFlushPending_GapThenFill(t *testing.T) {
 
 func TestFlushPending_PartialGap(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send 0, then 3 (gap for 1,2)
 	c.flushPending(createTestPacket(0, "A"), &s.ClientMessageQueue)
@@ -96,14 +96,14 @@ func TestFlushPending_PartialGap(t *testing.T) {
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected empty buffer after filling gap, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 4 {
-		t.Errorf("reliableOrder = %d, want 4", c.reliableOrder)
+	if c.recvOrder != 4 {
+		t.Errorf("recvOrder = %d, want 4", c.recvOrder)
 	}
 }
 
 func TestFlushPending_DuplicatePacket(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	p := createTestPacket(0, "A")
 	c.flushPending(p, &s.ClientMessageQueue)
@@ -121,7 +121,7 @@ func TestFlushPending_DuplicatePacket(t *testing.T) {
 
 func TestFlushPending_DuplicateOutOfOrderPacket(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	p1 := createTestPacket(1, "B")
 	p2 := createTestPacket(3, "D")
@@ -145,9 +145,9 @@ func TestFlushPending_DuplicateOutOfOrderPacket(t *testing.T) {
 
 func TestFlushPending_FuturePacketsOnly(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
-	// Send packets 5, 6, 7 when reliableOrder is 0
+	// Send packets 5, 6, 7 when recvOrder is 0
 	for i := uint64(5); i <= 7; i++ {
 		c.flushPending(createTestPacket(i, string(rune('A'+i))), &s.ClientMessageQueue)
 	}
@@ -164,7 +164,7 @@ func TestFlushPending_FuturePacketsOnly(t *testing.T) {
 
 func TestFlushPending_StartingFromHigherOrder(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 5}
+	c := &ServerClient{recvOrder: 5}
 
 	// Send 4 (already processed), should be skipped
 	c.flushPending(createTestPacket(4, "old"), &s.ClientMessageQueue)
@@ -177,14 +177,14 @@ func TestFlushPending_StartingFromHigherOrder(t *testing.T) {
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected 0 buffer after processing 5, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 6 {
-		t.Errorf("reliableOrder = %d, want 6", c.reliableOrder)
+	if c.recvOrder != 6 {
+		t.Errorf("recvOrder = %d, want 6", c.recvOrder)
 	}
 }
 
 func TestFlushPending_LargeGap(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send 0, then jump to 20
 	c.flushPending(createTestPacket(0, "A"), &s.ClientMessageQueue)
@@ -203,8 +203,8 @@ func TestFlushPending_LargeGap(t *testing.T) {
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected empty buffer after filling gap, got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 21 {
-		t.Errorf("reliableOrder = %d, want 21", c.reliableOrder)
+	if c.recvOrder != 21 {
+		t.Errorf("recvOrder = %d, want 21", c.recvOrder)
 	}
 
 	msgs := s.ClientMessageQueue.Flush()
@@ -215,7 +215,7 @@ func TestFlushPending_LargeGap(t *testing.T) {
 
 func TestFlushPending_OrderPreservedInQueue(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// Send in order 3, 2, 1, 0
 	orders := []uint64{3, 2, 1, 0}
@@ -237,41 +237,41 @@ func TestFlushPending_OrderPreservedInQueue(t *testing.T) {
 
 func TestFlushPending_AltersReliableOrderOnlyOnFlush(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
-	// Send 0 - should be flushed, reliableOrder becomes 1
+	// Send 0 - should be flushed, recvOrder becomes 1
 	c.flushPending(createTestPacket(0, "A"), &s.ClientMessageQueue)
-	if c.reliableOrder != 1 {
-		t.Errorf("reliableOrder = %d, want 1 after flushing 0", c.reliableOrder)
+	if c.recvOrder != 1 {
+		t.Errorf("recvOrder = %d, want 1 after flushing 0", c.recvOrder)
 	}
 
 	// Send 1, then 0 again (duplicate)
 	c.flushPending(createTestPacket(1, "B"), &s.ClientMessageQueue)
 	c.flushPending(createTestPacket(0, "A-dup"), &s.ClientMessageQueue)
 
-	if c.reliableOrder != 2 {
-		t.Errorf("reliableOrder = %d, want 2 after flushing 1", c.reliableOrder)
+	if c.recvOrder != 2 {
+		t.Errorf("recvOrder = %d, want 2 after flushing 1", c.recvOrder)
 	}
 }
 
 func TestFlushPending_MultipleSequentialBatches(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 
 	// First batch: 0, 1, 2
 	for i := uint64(0); i <= 2; i++ {
 		c.flushPending(createTestPacket(i, string(rune('A'+i))), &s.ClientMessageQueue)
 	}
-	if c.reliableOrder != 3 {
-		t.Errorf("after first batch: reliableOrder = %d, want 3", c.reliableOrder)
+	if c.recvOrder != 3 {
+		t.Errorf("after first batch: recvOrder = %d, want 3", c.recvOrder)
 	}
 
 	// Second batch with gap: 5, 3, 4
 	c.flushPending(createTestPacket(5, "F"), &s.ClientMessageQueue)
 	c.flushPending(createTestPacket(3, "D"), &s.ClientMessageQueue)
 	c.flushPending(createTestPacket(4, "E"), &s.ClientMessageQueue)
-	if c.reliableOrder != 6 {
-		t.Errorf("after second batch: reliableOrder = %d, want 6", c.reliableOrder)
+	if c.recvOrder != 6 {
+		t.Errorf("after second batch: recvOrder = %d, want 6", c.recvOrder)
 	}
 	if len(c.reliableBuffer) != 0 {
 		t.Errorf("expected empty buffer, got %d", len(c.reliableBuffer))
@@ -280,17 +280,17 @@ func TestFlushPending_MultipleSequentialBatches(t *testing.T) {
 
 func TestFlushPending_SkipAlreadyProcessed(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 5}
+	c := &ServerClient{recvOrder: 5}
 
-	// Send packets 0 through 4 (all below reliableOrder)
+	// Send packets 0 through 4 (all below recvOrder)
 	for i := uint64(0); i < 5; i++ {
 		c.flushPending(createTestPacket(i, string(rune('A'+i))), &s.ClientMessageQueue)
 	}
 
 	if len(c.reliableBuffer) != 0 {
-		t.Errorf("expected 0 buffered (all below reliableOrder), got %d", len(c.reliableBuffer))
+		t.Errorf("expected 0 buffered (all below recvOrder), got %d", len(c.reliableBuffer))
 	}
-	if c.reliableOrder != 5 {
-		t.Errorf("reliableOrder should not have changed: got %d, want 5", c.reliableOrder)
+	if c.recvOrder != 5 {
+		t.Errorf("recvOrder should not have changed: got %d, want 5", c.recvOrder)
 	}
 }
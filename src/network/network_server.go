@@ -7,6 +7,7 @@
 package network
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"log/slog"
 	"net"
@@ -14,16 +15,19 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/events"
 	"kaijuengine.com/platform/concurrent"
 )
 
 type ClientMessage struct {
 	message    [maxPacketSize]byte
 	messageLen uint16
+	assembled  []byte
 	Client     *ServerClient
 }
 
@@ -40,7 +44,12 @@ func clientMessageFromPacket(packet NetworkPacketUDP, client *ServerClient) Clie
 	return cm
 }
 
-func (c *ClientMessage) Message() []byte { return c.message[:c.messageLen] }
+func (c *ClientMessage) Message() []byte {
+	if c.assembled != nil {
+		return c.assembled
+	}
+	return c.message[:c.messageLen]
+}
 
 type ServerClient struct {
 	id             int
@@ -48,19 +57,60 @@ type ServerClient struct {
 	writeBuffer    []byte
 	readBuffer     []byte
 	reliableBuffer []NetworkPacketUDP
-	reliableOrder  uint64
-	writeMutex     sync.Mutex
+	// sendOrder is the order given to the next reliable packet sent to the
+	// other side, recvOrder is the order of the next reliable packet expected
+	// from it. Both sides send reliable packets at the same time, so the two
+	// have to be counted separately.
+	sendOrder  uint64
+	recvOrder  uint64
+	writeMutex sync.Mutex
+	fragments  fragmentAssembler
+	lastHeard  atomic.Int64
+	session    atomic.Pointer[session]
+	stats      connectionStats
 }
 
+// NetworkServer listens for clients over UDP. Clients must complete a
+// connection handshake before any of their messages are accepted, after which
+// both sides exchange heartbeats. Clients that aren't heard from within the
+// [ConnectionConfig.Timeout] are removed.
 type NetworkServer struct {
 	NetworkUDP
 	ClientMessageQueue concurrent.MessageQueue[ClientMessage]
-	clients            map[string]*ServerClient
-	nextClientId       int
+	Config             ConnectionConfig
+	// Secure enables encrypted and authenticated sessions. Keys are exchanged
+	// during the connection handshake and from then on every packet to and
+	// from the client is encrypted. Clients must also have Secure enabled.
+	Secure bool
+	// IdentityKey is the long lived key that a [NetworkServer.Secure] server
+	// signs its side of the handshake with, clients are given the public half
+	// as their [NetworkClient.ServerIdentity]. Without it a client has no way
+	// to tell the server apart from someone in the middle of the connection.
+	// Create one with [ed25519.GenerateKey] and keep it with the server.
+	IdentityKey ed25519.PrivateKey
+	// MaxClients is the most clients that can be connected at one time, any
+	// further connection requests are rejected. Zero means no limit.
+	MaxClients int
+	// AcceptConnection is an optional check that is run for each connection
+	// request, it is given the payload that the client connected with. Return
+	// [RejectReasonNone] to accept the client. This is called from the
+	// goroutine that reads messages, not the update thread.
+	AcceptConnection func(address string, payload []byte) RejectReason
+	// OnClientConnected is executed on update after a client is accepted
+	OnClientConnected events.EventWithArg[*ServerClient]
+	// OnClientDisconnected is executed on update after a client disconnects,
+	// times out, or is removed from the server
+	OnClientDisconnected events.EventWithArg[ClientDisconnect]
+	clients              map[string]*ServerClient
+	clientsMutex         sync.RWMutex
+	nextClientId         int
+	connectionEvents     concurrent.MessageQueue[connectionEvent]
+	lastHeartbeat        time.Time
 }
 
 func NewServerUDP() NetworkServer {
 	return NetworkServer{
+		Config:  DefaultConnectionConfig(),
 		clients: make(map[string]*ServerClient),
 	}
 }
@@ -81,13 +131,47 @@ func (s *NetworkServer) addClient(addr *net.UDPAddr) *ServerClient {
 		writeBuffer: make([]byte, maxPacketSize),
 		readBuffer:  make([]byte, maxPacketSize),
 	}
+	s.clientsMutex.Lock()
 	s.clients[addr.String()] = client
+	s.clientsMutex.Unlock()
 	s.nextClientId++
 	return client
 }
 
+// ClientCount returns the number of clients that are currently connected
+func (s *NetworkServer) ClientCount() int {
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+	return len(s.clients)
+}
+
+// RemoveClient disconnects the client from the server, the client is told
+// that it has been disconnected and [NetworkServer.OnClientDisconnected]
+// will be executed on the next update
 func (s *NetworkServer) RemoveClient(client *ServerClient) {
-	delete(s.clients, client.addr.String())
+	if s.removeClient(client, DisconnectReasonRemoved) && s.IsLive() {
+		s.sendPacket(s.createControl(udpPacketTypeDisconnect,
+			[]byte{uint8(DisconnectReasonRemoved)}), client)
+	}
+}
+
+func (s *NetworkServer) removeClient(client *ServerClient, reason DisconnectReason) bool {
+	key := client.addr.String()
+	s.clientsMutex.Lock()
+	existing, ok := s.clients[key]
+	if ok && existing == client {
+		delete(s.clients, key)
+	}
+	s.clientsMutex.Unlock()
+	if !ok || existing != client {
+		return false
+	}
+	s.connectionEvents.Enqueue(connectionEvent{
+		eventType: connectionEventDisconnected,
+		client:    client,
+		reason:    reason,
+	})
+	return true
 }
 
 func (s *NetworkServer) HolePunchClient(address string, port uint16) (*ServerClient, error) {
@@ -104,66 +188,119 @@ func (s *NetworkServer) HolePunchClient(address string, port uint16) (*ServerCli
 	}, err
 }
 
+// SendHolePunch sends a packet to the client returned from
+// [NetworkServer.HolePunchClient] so that this side's NAT will let the
+// client's connection request through. It should be sent repeatedly while the
+// client is trying to connect, as the first packets are expected to be
+// dropped by the client's NAT.
+func (s *NetworkServer) SendHolePunch(client *ServerClient) error {
+	return s.sendPacket(s.createControl(udpPacketTypeHeartbeat, nil), client)
+}
+
 func (s *NetworkServer) Serve(updater *engine.Updater, port uint16) error {
 	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		slog.Error("failed to resolve the UDP host address", "error", err, "port", port)
 		return err
 	}
-	s.conn, err = net.ListenUDP("udp", addr)
+	conn, err := net.ListenUDP("udp", addr)
 	if err != nil {
 		slog.Error("failed to dial the UDP server", "error", err, "port", port)
 		return err
 	}
 	slog.Info("UDP server started listening", "port", port)
+	s.conn.Store(conn)
+	s.isReading.Store(true)
 	s.updateId = updater.AddUpdate(s.update)
-	go s.readMessages()
+	go s.readMessages(conn)
 	return nil
 }
 
 func (s *NetworkServer) sendPacket(packet NetworkPacketUDP, client *ServerClient) error {
+	if !s.IsLive() {
+		return ErrNotLive
+	}
+	if sess := client.session.Load(); sess != nil {
+		packet = sess.seal(packet)
+	}
 	client.writeMutex.Lock()
 	defer client.writeMutex.Unlock()
 	n, err := packetToMessage(packet, client.writeBuffer)
 	if err != nil {
 		return err
 	}
-	_, err = s.conn.WriteToUDP(client.writeBuffer[:n], client.addr)
-	if err != nil {
+	if err = s.write(client.writeBuffer[:n], client.addr); err != nil {
 		slog.Error("failed to write message to client", "error", err, "client", client)
 		return err
 	}
+	client.stats.packetsSent.Add(1)
 	return nil
 }
 
 func (c *NetworkServer) SendMessageUnreliable(message []byte, client *ServerClient) error {
+	if len(message) > MaxMessageSize {
+		slog.Error("unreliable messages can not be fragmented", "size", len(message), "max", MaxMessageSize)
+		return ErrMessageTooLarge
+	}
 	return c.sendPacket(c.createUnreliable(message), client)
 }
 
+// SendMessageReliable sends the message to the client and retries until it is
+// acknowledged. Messages larger than [MaxMessageSize] are transparently split
+// into fragments and reassembled before they reach the client's queue.
 func (c *NetworkServer) SendMessageReliable(message []byte, client *ServerClient) error {
-	return c.sendPacket(c.createReliable(message, client), client)
+	if len(message) <= MaxMessageSize {
+		return c.sendPacket(c.createReliable(message, client), client)
+	}
+	packets, err := c.createReliableFragments(message, client)
+	if err != nil {
+		return err
+	}
+	for i := range packets {
+		if err = c.sendPacket(packets[i], client); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *NetworkServer) readMessages() {
-	s.isReading = true
+func (s *NetworkServer) readMessages(conn *net.UDPConn) {
 	readBuffer := make([]byte, maxPacketSize)
-	for s.isReading {
-		n, remoteAddr, err := s.conn.ReadFromUDP(readBuffer)
-		if !s.isReading {
+	for s.isReading.Load() {
+		n, remoteAddr, err := conn.ReadFromUDP(readBuffer)
+		if !s.isReading.Load() {
 			break
 		}
 		if err != nil {
 			slog.Error("failed reading client message", "error", err)
 			continue
 		}
+		s.clientsMutex.RLock()
 		client, ok := s.clients[remoteAddr.String()]
+		s.clientsMutex.RUnlock()
 		if !ok {
-			client = s.addClient(remoteAddr)
+			// Only connection requests are accepted from unknown addresses
+			packet := packetFromMessage(readBuffer[:n])
+			if packet.typeFlags&udpPacketTypeConnect != 0 {
+				s.processConnect(remoteAddr, &packet)
+			}
+			continue
 		}
 		copy(client.readBuffer, readBuffer)
 		packet := packetFromMessage(client.readBuffer[:n])
-		if packet.isAck() {
-			s.removePendingPacket(packet.timestamp)
+		if sess := client.session.Load(); sess != nil && !isHandshakePacket(&packet) {
+			if err := sess.open(&packet); err != nil {
+				// Drop anything that didn't come from the client's session
+				continue
+			}
+		}
+		now := time.Now()
+		client.markHeard(now)
+		client.stats.packetsReceived.Add(1)
+		if packet.isControl() {
+			s.processControl(&packet, client)
+		} else if packet.isAck() {
+			s.acknowledge(&packet, now)
 		} else {
 			if packet.isReliable() {
 				// The ack is just the timestamp of the message it read
@@ -177,12 +314,89 @@ func (s *NetworkServer) readMessages() {
 	slog.Info("UDP network server stopped reading messages")
 }
 
+func (s *NetworkServer) processConnect(addr *net.UDPAddr, packet *NetworkPacketUDP) {
+	reason := RejectReasonNone
+	payload := packet.message[:packet.messageLen]
+	var peerPublic []byte
+	if packet.typeFlags&udpPacketTypeEncrypted != 0 {
+		if len(payload) >= sessionPublicKeySize {
+			peerPublic, payload = payload[:sessionPublicKeySize], payload[sessionPublicKeySize:]
+		}
+	}
+	if s.Secure != (peerPublic != nil) {
+		reason = RejectReasonSecurityMismatch
+	} else if s.Secure && len(s.IdentityKey) != ed25519.PrivateKeySize {
+		slog.Error("secure servers need an identity key to sign the handshake with", "error", ErrMissingIdentity)
+		reason = RejectReasonSecurityMismatch
+	} else if s.MaxClients > 0 && s.ClientCount() >= s.MaxClients {
+		reason = RejectReasonServerFull
+	} else if s.AcceptConnection != nil {
+		reason = s.AcceptConnection(addr.String(), payload)
+	}
+	var sess *session
+	if reason == RejectReasonNone && s.Secure {
+		key, err := newSessionKey()
+		if err == nil {
+			sess, err = newSession(key, peerPublic, true)
+		}
+		if err != nil {
+			slog.Error("failed to create the secure session for the client", "address", addr, "error", err)
+			reason = RejectReasonSecurityMismatch
+		} else {
+			sess.accept = signHandshake(s.IdentityKey, udpPacketTypeAccept, peerPublic, sess.localPublic)
+		}
+	}
+	if reason != RejectReasonNone {
+		slog.Info("rejected client connection", "address", addr, "reason", reason)
+		s.sendPacket(s.createReject(reason, peerPublic),
+			&ServerClient{addr: addr, writeBuffer: make([]byte, maxPacketSize)})
+		return
+	}
+	client := s.addClient(addr)
+	client.markHeard(time.Now())
+	client.session.Store(sess)
+	s.sendPacket(s.createAccept(client), client)
+	s.connectionEvents.Enqueue(connectionEvent{
+		eventType: connectionEventConnected,
+		client:    client,
+	})
+}
+
+func (s *NetworkServer) createAccept(client *ServerClient) NetworkPacketUDP {
+	sess := client.session.Load()
+	if sess == nil {
+		return s.createControl(udpPacketTypeAccept, nil)
+	}
+	return s.createControl(udpPacketTypeAccept|udpPacketTypeEncrypted, sess.accept)
+}
+
+// createReject signs the reject for secure clients when the server is able
+// to, so that a client can tell it apart from a spoofed reject
+func (s *NetworkServer) createReject(reason RejectReason, peerPublic []byte) NetworkPacketUDP {
+	payload := []byte{uint8(reason)}
+	if peerPublic == nil || !s.Secure || len(s.IdentityKey) != ed25519.PrivateKeySize {
+		return s.createControl(udpPacketTypeReject, payload)
+	}
+	return s.createControl(udpPacketTypeReject|udpPacketTypeEncrypted,
+		signHandshake(s.IdentityKey, udpPacketTypeReject, peerPublic, payload))
+}
+
+func (s *NetworkServer) processControl(packet *NetworkPacketUDP, client *ServerClient) {
+	switch {
+	case packet.typeFlags&udpPacketTypeConnect != 0:
+		// The client didn't get the accept, so send it again
+		s.sendPacket(s.createAccept(client), client)
+	case packet.typeFlags&udpPacketTypeDisconnect != 0:
+		s.removeClient(client, DisconnectReasonClosed)
+	}
+}
+
 func (client *ServerClient) flushPending(p NetworkPacketUDP, messageQueue *concurrent.MessageQueue[ClientMessage]) {
-	if p.order < client.reliableOrder {
+	if p.order < client.recvOrder {
 		// We already have processed this packet
 		return
 	}
-	if p.order == client.reliableOrder {
+	if p.order == client.recvOrder {
 		client.reliableBuffer = append(client.reliableBuffer, p)
 		// Reverse the list so that the lowest id (the one we're on) is at the end
 		sort.Slice(client.reliableBuffer, func(i, j int) bool {
@@ -191,10 +405,10 @@ func (client *ServerClient) flushPending(p NetworkPacketUDP, messageQueue *concu
 		// Go backwards through the list until we hit an id we're not ready for
 		end := len(client.reliableBuffer) - 1
 		for ; end >= 0; end-- {
-			if client.reliableBuffer[end].order == client.reliableOrder {
-				messageQueue.Enqueue(clientMessageFromPacket(client.reliableBuffer[end], client))
+			if client.reliableBuffer[end].order == client.recvOrder {
+				client.enqueueReliable(&client.reliableBuffer[end], messageQueue)
 				// Go to the next reliable message id
-				client.reliableOrder++
+				client.recvOrder++
 			} else {
 				break
 			}
@@ -212,18 +426,76 @@ func (client *ServerClient) flushPending(p NetworkPacketUDP, messageQueue *concu
 	}
 }
 
-func (s *NetworkServer) update(deltaTime float64) {
-	if len(s.pendingPackets) == 0 {
+// enqueueReliable pushes an in-order reliable packet onto the message queue,
+// fragments are held on to until the whole message has been reassembled
+func (client *ServerClient) enqueueReliable(p *NetworkPacketUDP, messageQueue *concurrent.MessageQueue[ClientMessage]) {
+	if !p.isFragment() {
+		messageQueue.Enqueue(clientMessageFromPacket(*p, client))
 		return
 	}
-	now := time.Now()
-	s.pendingMutex.RLock()
-	defer s.pendingMutex.RUnlock()
-	for i := 0; i < len(s.pendingPackets); i++ {
-		pp := &s.pendingPackets[i]
-		if pp.packet.nextRetry.Before(now) {
-			pp.packet.nextRetry = now.Add(reliableRetryDelay)
-			s.sendPacket(pp.packet, pp.target)
+	if msg, ok := client.fragments.add(p, time.Now()); ok {
+		messageQueue.Enqueue(ClientMessage{
+			assembled: msg,
+			Client:    client,
+		})
+	}
+}
+
+// Close tells all of the connected clients that the server is closing and
+// then stops the server
+func (s *NetworkServer) Close(updater *engine.Updater) {
+	if s.IsLive() {
+		s.clientsMutex.RLock()
+		for _, c := range s.clients {
+			s.sendPacket(s.createControl(udpPacketTypeDisconnect,
+				[]byte{uint8(DisconnectReasonClosed)}), c)
+		}
+		s.clientsMutex.RUnlock()
+	}
+	s.NetworkUDP.Close(updater)
+}
+
+func (s *NetworkServer) updateConnections(now time.Time) {
+	timedOut := []*ServerClient{}
+	sendHeartbeat := now.Sub(s.lastHeartbeat) >= s.Config.HeartbeatInterval
+	if sendHeartbeat {
+		s.lastHeartbeat = now
+	}
+	s.clientsMutex.RLock()
+	for _, c := range s.clients {
+		c.fragments.expire(now)
+		if c.timedOut(now, s.Config.Timeout) {
+			timedOut = append(timedOut, c)
+		} else if sendHeartbeat && s.IsLive() {
+			s.sendPacket(s.createControl(udpPacketTypeHeartbeat, nil), c)
+		}
+	}
+	s.clientsMutex.RUnlock()
+	for _, c := range timedOut {
+		slog.Info("client connection timed out", "address", c.Address())
+		s.removeClient(c, DisconnectReasonTimeout)
+	}
+	pending := s.connectionEvents.Flush()
+	for i := range pending {
+		switch pending[i].eventType {
+		case connectionEventConnected:
+			s.OnClientConnected.Execute(pending[i].client)
+		case connectionEventDisconnected:
+			// Cleaned up here rather than in removeClient so that the pending
+			// packets are only ever changed from the update goroutine
+			s.removePendingPacketsFor(pending[i].client)
+			s.OnClientDisconnected.Execute(ClientDisconnect{
+				Client: pending[i].client,
+				Reason: pending[i].reason,
+			})
 		}
 	}
 }
+
+func (s *NetworkServer) update(deltaTime float64) {
+	now := time.Now()
+	s.updateConnections(now)
+	s.resendPending(now, func(pp *PendingNetworkPacketUDP) {
+		s.sendPacket(pp.packet, pp.target)
+	})
+}
@@ -13,7 +13,7 @@ import (
 
 func TestFlushPending1(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 	orders := []uint64{1, 3, 4, 0}
 	lens := []int{1, 2, 3, 2}
 	msg := []byte("test")
@@ -33,7 +33,7 @@ func TestFlushPending1(t *testing.T) {
 
 func TestFlushPending2(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 	orders := []uint64{1, 3, 4, 2, 0}
 	lens := []int{1, 2, 3, 4, 0}
 	msg := []byte("test")
@@ -53,7 +53,7 @@ func TestFlushPending2(t *testing.T) {
 
 func TestReliableMessageQueue(t *testing.T) {
 	s := NewServerUDP()
-	c := &ServerClient{reliableOrder: 0}
+	c := &ServerClient{recvOrder: 0}
 	orders := []uint64{1, 3, 4, 2, 0}
 	lens := []int{1, 2, 3, 4, 0}
 	for i := range orders {
@@ -0,0 +1,227 @@
+/******************************************************************************/
+/* network_session.go                                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+const (
+	sessionPublicKeySize = 32
+	sessionKeySize       = 32
+	sessionTagSize       = 16
+	sessionNonceSize     = 12
+	sessionKeyInfo       = "kaiju network session v1"
+	sessionSignContext   = "kaiju network handshake v1"
+	sessionAcceptSize    = sessionPublicKeySize + ed25519.SignatureSize
+
+	// sessionReplayWindow is how far back (in microseconds) an unreliable
+	// packet can be from the newest one received before it is considered a
+	// replay. Reliable packets are protected by their order instead.
+	sessionReplayWindow = int64(5 * 1000 * 1000)
+
+	sessionDirectionClientToServer = uint32(0x43325300) // "C2S"
+	sessionDirectionServerToClient = uint32(0x53324300) // "S2C"
+)
+
+var (
+	ErrSessionAuthFailed = errors.New("the packet failed session authentication")
+	ErrSessionReplay     = errors.New("the packet was already received")
+	ErrMissingIdentity   = errors.New("secure connections need the server's identity key")
+)
+
+// session holds the keys that are established during the connection
+// handshake. Once a session exists every packet, other than the handshake
+// packets themselves, is encrypted and authenticated with AES-GCM. The
+// packet header is not encrypted, but it is authenticated as additional data.
+// The nonce is built from the direction and the packet timestamp, which is
+// unique for every packet a [NetworkUDP] creates.
+type session struct {
+	send        cipher.AEAD
+	recv        cipher.AEAD
+	sendDir     uint32
+	recvDir     uint32
+	localPublic []byte
+	// accept is the payload of the server's accept, its public key followed
+	// by the identity signature of the handshake
+	accept       []byte
+	replayMutex  sync.Mutex
+	newest       int64
+	seen         map[int64]struct{}
+	replayPrunes int
+}
+
+func newSessionKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// newSession performs the key agreement with the peer's public key and
+// derives a separate key for each direction of the connection
+func newSession(local *ecdh.PrivateKey, peerPublic []byte, isServer bool) (*session, error) {
+	peer, err := ecdh.X25519().NewPublicKey(peerPublic)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := local.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	localPublic := local.PublicKey().Bytes()
+	clientPublic, serverPublic := localPublic, peerPublic
+	if isServer {
+		clientPublic, serverPublic = peerPublic, localPublic
+	}
+	salt := append(append([]byte{}, clientPublic...), serverPublic...)
+	keys, err := hkdf.Key(sha256.New, shared, salt, sessionKeyInfo, sessionKeySize*2)
+	if err != nil {
+		return nil, err
+	}
+	c2s, err := newSessionCipher(keys[:sessionKeySize])
+	if err != nil {
+		return nil, err
+	}
+	s2c, err := newSessionCipher(keys[sessionKeySize:])
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		localPublic: localPublic,
+		seen:        make(map[int64]struct{}),
+	}
+	if isServer {
+		s.send, s.sendDir = s2c, sessionDirectionServerToClient
+		s.recv, s.recvDir = c2s, sessionDirectionClientToServer
+	} else {
+		s.send, s.sendDir = c2s, sessionDirectionClientToServer
+		s.recv, s.recvDir = s2c, sessionDirectionServerToClient
+	}
+	return s, nil
+}
+
+func newSessionCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// handshakeTranscript is what the server signs with its identity key so that
+// the client knows the handshake wasn't answered by someone in the middle.
+// The client's public key is new for every connection attempt, so a signed
+// accept or reject can't be replayed to a later attempt.
+func handshakeTranscript(typeFlags udpPacketTypeFlags, clientPublic, payload []byte) []byte {
+	out := make([]byte, 0, len(sessionSignContext)+4+len(clientPublic)+len(payload))
+	out = append(out, sessionSignContext...)
+	out = binary.LittleEndian.AppendUint32(out, typeFlags&udpPacketTypeControlMask)
+	out = append(out, clientPublic...)
+	return append(out, payload...)
+}
+
+// signHandshake appends the identity signature of the handshake to payload
+func signHandshake(identity ed25519.PrivateKey, typeFlags udpPacketTypeFlags, clientPublic, payload []byte) []byte {
+	sig := ed25519.Sign(identity, handshakeTranscript(typeFlags, clientPublic, payload))
+	return append(append([]byte{}, payload...), sig...)
+}
+
+// verifyHandshake checks the identity signature at the end of a handshake
+// packet's payload and returns the payload without it
+func verifyHandshake(identity ed25519.PublicKey, packet *NetworkPacketUDP, clientPublic []byte) ([]byte, bool) {
+	signed := packet.message[:packet.messageLen]
+	if len(identity) != ed25519.PublicKeySize || len(signed) < ed25519.SignatureSize {
+		return nil, false
+	}
+	payload, sig := signed[:len(signed)-ed25519.SignatureSize], signed[len(signed)-ed25519.SignatureSize:]
+	if !ed25519.Verify(identity, handshakeTranscript(packet.typeFlags, clientPublic, payload), sig) {
+		return nil, false
+	}
+	return payload, true
+}
+
+func isHandshakePacket(packet *NetworkPacketUDP) bool {
+	return packet.typeFlags&(udpPacketTypeConnect|udpPacketTypeAccept|udpPacketTypeReject) != 0
+}
+
+func sessionNonce(direction uint32, timestamp int64) []byte {
+	nonce := make([]byte, sessionNonceSize)
+	binary.LittleEndian.PutUint32(nonce, direction)
+	binary.LittleEndian.PutUint64(nonce[4:], uint64(timestamp))
+	return nonce
+}
+
+func sessionAdditionalData(packet *NetworkPacketUDP) []byte {
+	ad := make([]byte, 0, 8+8+4)
+	ad = binary.LittleEndian.AppendUint64(ad, uint64(packet.timestamp))
+	ad = binary.LittleEndian.AppendUint64(ad, packet.order)
+	return binary.LittleEndian.AppendUint32(ad, packet.typeFlags)
+}
+
+// seal returns an encrypted copy of the packet, handshake packets are left as
+// they are since the session keys are not known to the other side yet
+func (s *session) seal(packet NetworkPacketUDP) NetworkPacketUDP {
+	if isHandshakePacket(&packet) {
+		return packet
+	}
+	packet.typeFlags |= udpPacketTypeEncrypted
+	nonce := sessionNonce(s.sendDir, packet.timestamp)
+	out := s.send.Seal(nil, nonce, packet.message[:packet.messageLen], sessionAdditionalData(&packet))
+	packet.messageLen = uint16(copy(packet.message[:], out))
+	return packet
+}
+
+// open decrypts the packet in place and verifies that it has not been
+// tampered with or replayed
+func (s *session) open(packet *NetworkPacketUDP) error {
+	if packet.typeFlags&udpPacketTypeEncrypted == 0 || packet.messageLen < sessionTagSize {
+		return ErrSessionAuthFailed
+	}
+	nonce := sessionNonce(s.recvDir, packet.timestamp)
+	out, err := s.recv.Open(nil, nonce, packet.message[:packet.messageLen], sessionAdditionalData(packet))
+	if err != nil {
+		return ErrSessionAuthFailed
+	}
+	if !packet.isReliable() && !s.acceptTimestamp(packet.timestamp) {
+		return ErrSessionReplay
+	}
+	packet.typeFlags &^= udpPacketTypeEncrypted
+	packet.messageLen = uint16(copy(packet.message[:], out))
+	return nil
+}
+
+func (s *session) acceptTimestamp(timestamp int64) bool {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+	if timestamp <= s.newest-sessionReplayWindow {
+		return false
+	}
+	if _, ok := s.seen[timestamp]; ok {
+		return false
+	}
+	s.seen[timestamp] = struct{}{}
+	if timestamp > s.newest {
+		s.newest = timestamp
+	}
+	s.replayPrunes++
+	if s.replayPrunes >= 256 {
+		s.replayPrunes = 0
+		for t := range s.seen {
+			if t <= s.newest-sessionReplayWindow {
+				delete(s.seen, t)
+			}
+		}
+	}
+	return true
+}
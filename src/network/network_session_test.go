@@ -0,0 +1,318 @@
+/******************************************************************************/
+/* network_session_test.go                                                    */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"kaijuengine.com/engine"
+)
+
+func newTestSessionPair(t *testing.T) (client, server *session) {
+	t.Helper()
+	clientKey, err := newSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey, err := newSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server, err = newSession(serverKey, clientKey.PublicKey().Bytes(), true); err != nil {
+		t.Fatal(err)
+	}
+	if client, err = newSession(clientKey, serverKey.PublicKey().Bytes(), false); err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestSession_SealOpen(t *testing.T) {
+	client, server := newTestSessionPair(t)
+	n := NetworkUDP{}
+	msg := []byte("secret message")
+	sealed := client.seal(n.createUnreliable(msg))
+	if sealed.typeFlags&udpPacketTypeEncrypted == 0 {
+		t.Fatal("the sealed packet is not marked as encrypted")
+	}
+	if bytes.Contains(sealed.message[:sealed.messageLen], msg) {
+		t.Fatal("the sealed packet contains the plain text message")
+	}
+	if int(sealed.messageLen) != len(msg)+sessionTagSize {
+		t.Errorf("sealed length = %d, want %d", sealed.messageLen, len(msg)+sessionTagSize)
+	}
+	if err := server.open(&sealed); err != nil {
+		t.Fatalf("failed to open the packet: %v", err)
+	}
+	if !bytes.Equal(sealed.message[:sealed.messageLen], msg) {
+		t.Errorf("opened message = %q, want %q", sealed.message[:sealed.messageLen], msg)
+	}
+}
+
+func TestSession_RejectsTampering(t *testing.T) {
+	client, server := newTestSessionPair(t)
+	n := NetworkUDP{}
+	sealed := client.seal(n.createUnreliable([]byte("hello")))
+	body := sealed
+	body.message[0] ^= 1
+	if err := server.open(&body); err != ErrSessionAuthFailed {
+		t.Errorf("tampered body error = %v, want %v", err, ErrSessionAuthFailed)
+	}
+	header := sealed
+	header.order++
+	if err := server.open(&header); err != ErrSessionAuthFailed {
+		t.Errorf("tampered header error = %v, want %v", err, ErrSessionAuthFailed)
+	}
+	plain := n.createUnreliable([]byte("hello"))
+	if err := server.open(&plain); err != ErrSessionAuthFailed {
+		t.Errorf("plain packet error = %v, want %v", err, ErrSessionAuthFailed)
+	}
+}
+
+func TestSession_RejectsReflection(t *testing.T) {
+	client, _ := newTestSessionPair(t)
+	n := NetworkUDP{}
+	sealed := client.seal(n.createUnreliable([]byte("hello")))
+	// A packet sent by the client must not be accepted by the client
+	if err := client.open(&sealed); err != ErrSessionAuthFailed {
+		t.Errorf("reflected packet error = %v, want %v", err, ErrSessionAuthFailed)
+	}
+}
+
+func TestSession_Replay(t *testing.T) {
+	client, server := newTestSessionPair(t)
+	n := NetworkUDP{}
+	sealed := client.seal(n.createUnreliable([]byte("hello")))
+	first, replay := sealed, sealed
+	if err := server.open(&first); err != nil {
+		t.Fatalf("failed to open the packet: %v", err)
+	}
+	if err := server.open(&replay); err != ErrSessionReplay {
+		t.Errorf("replayed packet error = %v, want %v", err, ErrSessionReplay)
+	}
+	// Reliable packets are retried, duplicates are filtered by their order
+	target := ServerClient{}
+	reliable := client.seal(n.createReliable([]byte("hello"), &target))
+	first, retry := reliable, reliable
+	if err := server.open(&first); err != nil {
+		t.Fatalf("failed to open the reliable packet: %v", err)
+	}
+	if err := server.open(&retry); err != nil {
+		t.Errorf("failed to open the retried reliable packet: %v", err)
+	}
+}
+
+func TestSession_HandshakeNotSealed(t *testing.T) {
+	client, _ := newTestSessionPair(t)
+	n := NetworkUDP{}
+	p := n.createControl(udpPacketTypeAccept, []byte{1, 2, 3})
+	sealed := client.seal(p)
+	if sealed.typeFlags != p.typeFlags || sealed.messageLen != p.messageLen {
+		t.Error("handshake packets should not be sealed")
+	}
+}
+
+func TestProcessConnect_SecurityMismatch(t *testing.T) {
+	s := NewServerUDP()
+	s.Secure = true
+	if c := newTestConnectedClient(t, &s, 5000); c != nil {
+		t.Error("an insecure client was accepted by a secure server")
+	}
+}
+
+func newTestIdentity(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+// newTestConnectingClient is a secure client that has sent its connection
+// request, without any socket behind it
+func newTestConnectingClient(t *testing.T, identity ed25519.PublicKey) *NetworkClient {
+	t.Helper()
+	c := NewClientUDP()
+	c.Secure = true
+	c.ServerIdentity = identity
+	key, err := newSessionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.sessionKey = key
+	c.state.Store(int32(ConnectionStateConnecting))
+	return &c
+}
+
+func TestSecureConnection_Loopback(t *testing.T) {
+	updater := engine.NewUpdater()
+	s := NewServerUDP()
+	s.Secure = true
+	identity, identityKey := newTestIdentity(t)
+	s.IdentityKey = identityKey
+	var payload []byte
+	s.AcceptConnection = func(address string, p []byte) RejectReason {
+		payload = append([]byte{}, p...)
+		return RejectReasonNone
+	}
+	if err := s.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer s.Close(&updater)
+	port := uint16(s.LocalAddress().Port)
+	var connected *ServerClient
+	s.OnClientConnected.Add(func(c *ServerClient) { connected = c })
+
+	c := NewClientUDP()
+	c.Secure = true
+	c.ServerIdentity = identity
+	c.ConnectPayload = []byte("token")
+	defer c.Close(&updater)
+	if err := c.Connect(&updater, "127.0.0.1", port); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	pumpUntil(t, &updater, func() bool { return connected != nil && c.IsConnected() })
+	if string(payload) != "token" {
+		t.Errorf("connect payload = %q, want %q", payload, "token")
+	}
+	if connected.session.Load() == nil || c.session.Load() == nil {
+		t.Fatal("the secure session was not established")
+	}
+
+	c.SendMessageReliable([]byte("to server"))
+	var msgs []ClientMessage
+	pumpUntil(t, &updater, func() bool {
+		msgs = append(msgs, s.ClientMessageQueue.Flush()...)
+		return len(msgs) > 0
+	})
+	if string(msgs[0].Message()) != "to server" {
+		t.Errorf("server received %q, want %q", msgs[0].Message(), "to server")
+	}
+
+	s.SendMessageReliable([]byte("to client"), connected)
+	msgs = msgs[:0]
+	pumpUntil(t, &updater, func() bool {
+		msgs = append(msgs, c.ServerMessageQueue.Flush()...)
+		return len(msgs) > 0
+	})
+	if string(msgs[0].Message()) != "to client" {
+		t.Errorf("client received %q, want %q", msgs[0].Message(), "to client")
+	}
+}
+
+func TestSecureConnection_ClientMismatch(t *testing.T) {
+	updater := engine.NewUpdater()
+	s := NewServerUDP()
+	if err := s.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer s.Close(&updater)
+	port := uint16(s.LocalAddress().Port)
+	c := NewClientUDP()
+	c.Secure = true
+	c.ServerIdentity, _ = newTestIdentity(t)
+	// The insecure server can't sign its reject, so it is only reported once
+	// the connection attempt times out
+	c.Config.ConnectTimeout = time.Millisecond * 200
+	defer c.Close(&updater)
+	if err := c.Connect(&updater, "127.0.0.1", port); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	pumpUntil(t, &updater, func() bool { return c.State() == ConnectionStateDisconnected })
+	if c.RejectReason() != RejectReasonSecurityMismatch {
+		t.Errorf("reject reason = %v, want %v", c.RejectReason(), RejectReasonSecurityMismatch)
+	}
+}
+
+func TestSecureConnection_WrongIdentity(t *testing.T) {
+	updater := engine.NewUpdater()
+	s := NewServerUDP()
+	s.Secure = true
+	_, s.IdentityKey = newTestIdentity(t)
+	if err := s.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer s.Close(&updater)
+	c := NewClientUDP()
+	c.Secure = true
+	// The client expects a different server, like it would if someone in the
+	// middle answered the handshake with their own keys
+	c.ServerIdentity, _ = newTestIdentity(t)
+	c.Config.ConnectTimeout = time.Millisecond * 200
+	var reason DisconnectReason
+	c.OnDisconnected.Add(func(r DisconnectReason) { reason = r })
+	defer c.Close(&updater)
+	if err := c.Connect(&updater, "127.0.0.1", uint16(s.LocalAddress().Port)); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	pumpUntil(t, &updater, func() bool { return c.State() == ConnectionStateDisconnected })
+	if reason != DisconnectReasonConnectFailed || c.session.Load() != nil {
+		t.Errorf("disconnect reason = %v, want the connection to fail without a session", reason)
+	}
+}
+
+func TestSecureConnection_MissingIdentity(t *testing.T) {
+	updater := engine.NewUpdater()
+	c := NewClientUDP()
+	c.Secure = true
+	if err := c.Connect(&updater, "127.0.0.1", 1); err != ErrMissingIdentity {
+		t.Errorf("connect error = %v, want %v", err, ErrMissingIdentity)
+	}
+	s := NewServerUDP()
+	s.Secure = true
+	key, _ := newSessionKey()
+	p := s.createControl(udpPacketTypeConnect|udpPacketTypeEncrypted, key.PublicKey().Bytes())
+	if c := newTestConnectedClientFrom(t, &s, 5000, &p); c != nil {
+		t.Error("a secure server without an identity key accepted a client")
+	}
+}
+
+func TestSecureClient_IgnoresSpoofedReject(t *testing.T) {
+	identity, identityKey := newTestIdentity(t)
+	c := newTestConnectingClient(t, identity)
+	n := NetworkUDP{}
+	spoofed := n.createControl(udpPacketTypeReject, []byte{uint8(RejectReasonDenied)})
+	c.processControl(&spoofed)
+	_, otherKey := newTestIdentity(t)
+	forged := n.createControl(udpPacketTypeReject|udpPacketTypeEncrypted,
+		signHandshake(otherKey, udpPacketTypeReject, c.sessionKey.PublicKey().Bytes(),
+			[]byte{uint8(RejectReasonDenied)}))
+	c.processControl(&forged)
+	if c.State() != ConnectionStateConnecting {
+		t.Fatal("an unsigned reject ended the secure connection attempt")
+	}
+	signed := n.createControl(udpPacketTypeReject|udpPacketTypeEncrypted,
+		signHandshake(identityKey, udpPacketTypeReject, c.sessionKey.PublicKey().Bytes(),
+			[]byte{uint8(RejectReasonServerFull)}))
+	c.processControl(&signed)
+	if c.State() != ConnectionStateDisconnected || c.RejectReason() != RejectReasonServerFull {
+		t.Errorf("state = %v, reason = %v, want the signed reject to end the attempt",
+			c.State(), c.RejectReason())
+	}
+}
+
+func TestSecureClient_IgnoresForeignAccept(t *testing.T) {
+	identity, _ := newTestIdentity(t)
+	c := newTestConnectingClient(t, identity)
+	s := NewServerUDP()
+	s.Secure = true
+	_, s.IdentityKey = newTestIdentity(t)
+	p := s.createControl(udpPacketTypeConnect|udpPacketTypeEncrypted, c.sessionKey.PublicKey().Bytes())
+	client := newTestConnectedClientFrom(t, &s, 5000, &p)
+	if client == nil {
+		t.Fatal("the secure server did not accept the client")
+	}
+	accept := s.createAccept(client)
+	c.processControl(&accept)
+	if c.State() != ConnectionStateConnecting || c.session.Load() != nil {
+		t.Error("the client accepted a handshake signed by a different identity")
+	}
+}
@@ -0,0 +1,80 @@
+/******************************************************************************/
+/* network_stats.go                                                           */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package network
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats is a snapshot of the statistics for a single connection,
+// it is returned from [ServerClient.Stats]
+type ConnectionStats struct {
+	// RoundTripTime is the smoothed time it takes for a reliable packet to be
+	// acknowledged, it is 0 until the first acknowledgement is received
+	RoundTripTime time.Duration
+	// PacketsSent is the number of packets written to the connection,
+	// including resends, acks and heartbeats
+	PacketsSent uint64
+	// PacketsReceived is the number of packets read from the connection
+	PacketsReceived uint64
+	// ReliableSent is the number of reliable packets that have been created
+	ReliableSent uint64
+	// Resends is the number of times a reliable packet was sent again because
+	// it was not acknowledged in time
+	Resends uint64
+	// PacketLoss is the estimated percentage (0-100) of packets that are lost,
+	// it is based on how many reliable packets needed to be resent
+	PacketLoss float64
+}
+
+type connectionStats struct {
+	packetsSent     atomic.Uint64
+	packetsReceived atomic.Uint64
+	reliableSent    atomic.Uint64
+	resends         atomic.Uint64
+	roundTrip       atomic.Int64
+}
+
+// Stats returns the current statistics for the connection
+func (c *ServerClient) Stats() ConnectionStats {
+	stats := ConnectionStats{
+		RoundTripTime:   time.Duration(c.stats.roundTrip.Load()),
+		PacketsSent:     c.stats.packetsSent.Load(),
+		PacketsReceived: c.stats.packetsReceived.Load(),
+		ReliableSent:    c.stats.reliableSent.Load(),
+		Resends:         c.stats.resends.Load(),
+	}
+	if total := stats.ReliableSent + stats.Resends; total > 0 {
+		stats.PacketLoss = float64(stats.Resends) / float64(total) * 100
+	}
+	return stats
+}
+
+// recordRoundTrip folds the sample into the smoothed round trip time, using
+// the same 1/8 gain that TCP uses
+func (s *connectionStats) recordRoundTrip(sample time.Duration) {
+	sample = max(sample, 0)
+	for {
+		last := s.roundTrip.Load()
+		next := int64(sample)
+		if last != 0 {
+			next = last + (int64(sample)-last)/8
+		}
+		if s.roundTrip.CompareAndSwap(last, next) {
+			return
+		}
+	}
+}
+
+// retryDelay is how long to wait for an ack before resending a reliable
+// packet. It is stretched out on slow connections so that packets which are
+// still in flight are not needlessly sent again.
+func (c *ServerClient) retryDelay() time.Duration {
+	rtt := time.Duration(c.stats.roundTrip.Load())
+	return max(reliableRetryDelay, rtt+rtt/2)
+}
@@ -6,9 +6,21 @@
 
 package network
 
+import "net"
+
 // NewClientMessageFromBytes creates a ClientMessage from raw bytes for testing.
 func NewClientMessageFromBytes(data []byte) ClientMessage {
+	if len(data) > maxPacketSize {
+		// Large messages arrive as reassembled fragments
+		return ClientMessage{assembled: append([]byte{}, data...)}
+	}
 	cm := ClientMessage{messageLen: uint16(len(data))}
 	copy(cm.message[:], data)
 	return cm
 }
+
+// NewServerClientForTest creates a ServerClient with the given id and address
+// for testing code that tracks clients without a live connection.
+func NewServerClientForTest(id int, addr *net.UDPAddr) *ServerClient {
+	return &ServerClient{id: id, addr: addr}
+}
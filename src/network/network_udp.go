@@ -7,9 +7,14 @@
 package network
 
 import (
+	"encoding/binary"
+	"errors"
 	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unsafe"
 
 	"kaijuengine.com/engine"
 	"kaijuengine.com/klib"
@@ -17,44 +22,147 @@ import (
 
 const reliableRetryDelay = time.Millisecond * 15
 
+var ErrNotLive = errors.New("the network connection is not live")
+
 type PendingNetworkPacketUDP struct {
-	target *ServerClient
-	packet NetworkPacketUDP
+	target  *ServerClient
+	packet  NetworkPacketUDP
+	resends int
 }
 
 type NetworkUDP struct {
-	conn           *net.UDPConn
+	// Conditioner, when set, is given every outgoing packet before it is
+	// written to the socket. See [NetworkSimulator] for simulating a bad
+	// connection.
+	Conditioner    NetworkConditioner
+	conn           atomic.Pointer[net.UDPConn]
 	pendingPackets []PendingNetworkPacketUDP
-	pendingMutex   sync.RWMutex
+	pendingMutex   sync.Mutex
 	updateId       engine.UpdateId
-	isReading      bool
+	isReading      atomic.Bool
+	lastTimestamp  atomic.Int64
+	nextFragmentId atomic.Uint32
 }
 
-func (n *NetworkUDP) IsLive() bool { return n.conn != nil }
+func (n *NetworkUDP) IsLive() bool { return n.conn.Load() != nil }
+
+// LocalAddress returns the local address of the socket, or nil if it is not
+// live
+func (n *NetworkUDP) LocalAddress() *net.UDPAddr {
+	conn := n.conn.Load()
+	if conn == nil {
+		return nil
+	}
+	addr, _ := conn.LocalAddr().(*net.UDPAddr)
+	return addr
+}
 
 func (n *NetworkUDP) Close(updater *engine.Updater) {
-	n.isReading = false
-	if n.conn != nil {
-		n.conn.Close()
-		n.conn = nil
+	n.isReading.Store(false)
+	if conn := n.conn.Swap(nil); conn != nil {
+		conn.Close()
 	}
 	updater.RemoveUpdate(&n.updateId)
 }
 
-func (n *NetworkUDP) removePendingPacket(id int64) {
+func (n *NetworkUDP) removePendingPacket(id int64) (PendingNetworkPacketUDP, bool) {
 	n.pendingMutex.Lock()
 	defer n.pendingMutex.Unlock()
 	for i := range n.pendingPackets {
 		if n.pendingPackets[i].packet.timestamp == id {
+			pp := n.pendingPackets[i]
 			n.pendingPackets = klib.RemoveUnordered(n.pendingPackets, i)
-			break
+			return pp, true
+		}
+	}
+	return PendingNetworkPacketUDP{}, false
+}
+
+// acknowledge removes the reliable packet that the ack is for and samples the
+// round trip time of the target. Packets that were resent are not sampled as
+// there is no way to know which of the sends the ack is for.
+func (n *NetworkUDP) acknowledge(ack *NetworkPacketUDP, now time.Time) {
+	if uintptr(ack.messageLen) != unsafe.Sizeof(ack.timestamp) {
+		return
+	}
+	id := int64(binary.LittleEndian.Uint64(ack.message[:]))
+	pp, ok := n.removePendingPacket(id)
+	if ok && pp.resends == 0 {
+		pp.target.stats.recordRoundTrip(now.Sub(time.UnixMicro(id)))
+	}
+}
+
+// resendPending sends any reliable packets again which have not been
+// acknowledged within the target's retry delay
+func (n *NetworkUDP) resendPending(now time.Time, send func(pp *PendingNetworkPacketUDP)) {
+	// The retry and resend counts are changed below, so this needs the write
+	// lock even though no packets are added or removed
+	n.pendingMutex.Lock()
+	defer n.pendingMutex.Unlock()
+	for i := 0; i < len(n.pendingPackets); i++ {
+		pp := &n.pendingPackets[i]
+		if pp.packet.nextRetry.Before(now) {
+			pp.packet.nextRetry = now.Add(pp.target.retryDelay())
+			pp.resends++
+			pp.target.stats.resends.Add(1)
+			send(pp)
+		}
+	}
+}
+
+// write puts the datagram on the socket, by way of the [NetworkConditioner]
+// if one is set. The address is nil when the socket is connected to a single
+// peer. Errors from writes that the conditioner delays are dropped, the same
+// as a packet that was lost on the network.
+func (n *NetworkUDP) write(datagram []byte, addr *net.UDPAddr) error {
+	conn := n.conn.Load()
+	if conn == nil {
+		return ErrNotLive
+	}
+	if n.Conditioner == nil {
+		return writeSocket(conn, datagram, addr)
+	}
+	n.Conditioner.Send(datagram, func(d []byte) { writeSocket(conn, d, addr) })
+	return nil
+}
+
+func writeSocket(conn *net.UDPConn, datagram []byte, addr *net.UDPAddr) error {
+	var err error
+	if addr == nil {
+		_, err = conn.Write(datagram)
+	} else {
+		_, err = conn.WriteToUDP(datagram, addr)
+	}
+	return err
+}
+
+// nextTimestamp returns the current time in microseconds, the timestamp is
+// also used to identify which packet an ack is for, so it is bumped forward
+// when multiple packets are created within the same microsecond
+func (n *NetworkUDP) nextTimestamp() int64 {
+	now := time.Now().UTC().UnixMicro()
+	for {
+		last := n.lastTimestamp.Load()
+		next := max(now, last+1)
+		if n.lastTimestamp.CompareAndSwap(last, next) {
+			return next
 		}
 	}
 }
 
+// removePendingPacketsFor drops all of the reliable packets that are waiting
+// to be acknowledged by the given target, used when the target goes away
+func (n *NetworkUDP) removePendingPacketsFor(target *ServerClient) {
+	n.pendingMutex.Lock()
+	defer n.pendingMutex.Unlock()
+	n.pendingPackets = slices.DeleteFunc(n.pendingPackets, func(p PendingNetworkPacketUDP) bool {
+		return p.target == target
+	})
+}
+
 func (n *NetworkUDP) createUnreliable(message []byte) NetworkPacketUDP {
 	packet := NetworkPacketUDP{
-		timestamp:  time.Now().UTC().UnixMicro(),
+		timestamp:  n.nextTimestamp(),
 		messageLen: uint16(len(message)),
 	}
 	copy(packet.message[:], message)
@@ -62,16 +170,21 @@ func (n *NetworkUDP) createUnreliable(message []byte) NetworkPacketUDP {
 }
 
 func (n *NetworkUDP) createReliable(message []byte, target *ServerClient) NetworkPacketUDP {
+	return n.createReliablePacket(message, target, udpPacketTypeReliable)
+}
+
+func (n *NetworkUDP) createReliablePacket(message []byte, target *ServerClient, typeFlags udpPacketTypeFlags) NetworkPacketUDP {
 	packet := NetworkPacketUDP{
-		timestamp:  time.Now().UTC().UnixMicro(),
-		order:      target.reliableOrder,
+		timestamp:  n.nextTimestamp(),
 		messageLen: uint16(len(message)),
-		typeFlags:  udpPacketTypeReliable,
-		nextRetry:  time.Now().Add(reliableRetryDelay),
+		typeFlags:  typeFlags,
+		nextRetry:  time.Now().Add(target.retryDelay()),
 	}
-	target.reliableOrder++
+	target.stats.reliableSent.Add(1)
 	copy(packet.message[:], message)
 	n.pendingMutex.Lock()
+	packet.order = target.sendOrder
+	target.sendOrder++
 	n.pendingPackets = append(n.pendingPackets, PendingNetworkPacketUDP{
 		target: target,
 		packet: packet,
@@ -82,7 +195,7 @@ func (n *NetworkUDP) createReliable(message []byte, target *ServerClient) Networ
 
 func (n *NetworkUDP) createAck(fromTimestamp []byte) NetworkPacketUDP {
 	packet := NetworkPacketUDP{
-		timestamp:  time.Now().UTC().UnixMicro(),
+		timestamp:  n.nextTimestamp(),
 		messageLen: uint16(len(fromTimestamp)),
 		typeFlags:  udpPacketTypeAck,
 	}
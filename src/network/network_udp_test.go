@@ -44,15 +44,15 @@ func TestCreateUnreliablePacket(t *testing.T) {
 
 func TestCreateReliablePacket_IncrementsOrder(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 	msg := []byte("reliable msg")
 
 	_ = n.createReliable(msg, client)
 	_ = n.createReliable(msg, client)
 	_ = n.createReliable(msg, client)
 
-	if client.reliableOrder != 3 {
-		t.Errorf("reliableOrder = %d, want 3", client.reliableOrder)
+	if client.sendOrder != 3 {
+		t.Errorf("sendOrder = %d, want 3", client.sendOrder)
 	}
 	if len(n.pendingPackets) != 3 {
 		t.Errorf("pendingPackets count = %d, want 3", len(n.pendingPackets))
@@ -61,7 +61,7 @@ func TestCreateReliablePacket_IncrementsOrder(t *testing.T) {
 
 func TestCreateReliablePacket_HasReliableFlag(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 	msg := []byte("test")
 
 	packet := n.createReliable(msg, client)
@@ -88,7 +88,7 @@ func TestCreateReliablePacket_HasReliableFlag(t *testing.T) {
 
 func TestCreateReliablePacket_StartingOrder(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 10}
+	client := &ServerClient{sendOrder: 10}
 	msg := []byte("test")
 
 	packet := n.createReliable(msg, client)
@@ -96,8 +96,8 @@ func TestCreateReliablePacket_StartingOrder(t *testing.T) {
 	if packet.order != 10 {
 		t.Errorf("packet order = %d, want 10", packet.order)
 	}
-	if client.reliableOrder != 11 {
-		t.Errorf("client reliableOrder = %d, want 11", client.reliableOrder)
+	if client.sendOrder != 11 {
+		t.Errorf("client sendOrder = %d, want 11", client.sendOrder)
 	}
 }
 
@@ -126,7 +126,7 @@ func TestCreateAckPacket(t *testing.T) {
 
 func TestRemovePendingPacket(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 
 	_ = n.createReliable([]byte("a"), client)
 	time.Sleep(time.Millisecond)
@@ -164,7 +164,7 @@ func TestRemovePendingPacket_EmptyList(t *testing.T) {
 
 func TestRemovePendingPacket_NonExistentTimestamp(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 	_ = n.createReliable([]byte("a"), client)
 
 	n.removePendingPacket(999999)
@@ -175,7 +175,7 @@ func TestRemovePendingPacket_NonExistentTimestamp(t *testing.T) {
 
 func TestRemovePendingPacket_FirstElement(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 
 	p1 := n.createReliable([]byte("a"), client)
 	p2 := n.createReliable([]byte("b"), client)
@@ -192,7 +192,7 @@ func TestRemovePendingPacket_FirstElement(t *testing.T) {
 
 func TestRemovePendingPacket_LastElement(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 
 	p1 := n.createReliable([]byte("a"), client)
 	p2 := n.createReliable([]byte("b"), client)
@@ -225,7 +225,7 @@ func TestReliableRetryDelay(t *testing.T) {
 
 func TestPendingPacketTargetReference(t *testing.T) {
 	n := NetworkUDP{}
-	client := &ServerClient{reliableOrder: 0}
+	client := &ServerClient{sendOrder: 0}
 
 	_ = n.createReliable([]byte("test"), client)
 
@@ -251,8 +251,8 @@ func TestCreateUnreliable_EmptyMessage(t *testing.T) {
 
 func TestCreateReliable_MultipleClients(t *testing.T) {
 	n := NetworkUDP{}
-	client1 := &ServerClient{reliableOrder: 0}
-	client2 := &ServerClient{reliableOrder: 5}
+	client1 := &ServerClient{sendOrder: 0}
+	client2 := &ServerClient{sendOrder: 5}
 
 	p1 := n.createReliable([]byte("for client1"), client1)
 	p2 := n.createReliable([]byte("for client2"), client2)
@@ -263,11 +263,11 @@ func TestCreateReliable_MultipleClients(t *testing.T) {
 	if p2.order != 5 {
 		t.Errorf("p2 order = %d, want 5", p2.order)
 	}
-	if client1.reliableOrder != 1 {
-		t.Errorf("client1 reliableOrder = %d, want 1", client1.reliableOrder)
+	if client1.sendOrder != 1 {
+		t.Errorf("client1 sendOrder = %d, want 1", client1.sendOrder)
 	}
-	if client2.reliableOrder != 6 {
-		t.Errorf("client2 reliableOrder = %d, want 6", client2.reliableOrder)
+	if client2.sendOrder != 6 {
+		t.Errorf("client2 sendOrder = %d, want 6", client2.sendOrder)
 	}
 	if len(n.pendingPackets) != 2 {
 		t.Errorf("pendingPackets = %d, want 2", len(n.pendingPackets))
@@ -0,0 +1,189 @@
+/******************************************************************************/
+/* prediction.go                                                              */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package prediction
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"kaijuengine.com/network"
+)
+
+// Tick is the number of the fixed simulation step that an input was sampled
+// on. Ticks are counted by each client, the server processes a client's
+// inputs in tick order and tells the client the last tick it processed.
+type Tick uint32
+
+// DefaultTickRate is the number of simulation ticks per second that is used
+// when a non-positive tick rate is given
+const DefaultTickRate = 60
+
+// messageMagic prefixes every prediction message so that they can be told
+// apart from game messages that share the same message queue
+const messageMagic = uint32(0x4452504B) // "KPRD"
+
+const (
+	messageKindInput = uint8(iota + 1)
+	messageKindState
+)
+
+const (
+	// magic + kind
+	messageHeaderSize = 4 + 1
+	// header + count
+	inputHeaderSize = messageHeaderSize + 1
+	// tick + length
+	inputEntryHeaderSize = 4 + 2
+	// header + ack tick + length
+	stateHeaderSize = messageHeaderSize + 4 + 2
+)
+
+var (
+	ErrMessageTooLarge   = errors.New("the prediction message is too large to be sent")
+	errMalformedMessage  = errors.New("malformed prediction message")
+	errNotFixedSizedData = errors.New("the type is not fixed size and needs a custom codec")
+)
+
+// Codec encodes and decodes the inputs or state that are sent over the
+// network. Encode appends the value to the buffer and returns the result.
+type Codec[T any] interface {
+	Encode(buffer []byte, value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// BinaryCodec is the default [Codec], it uses [encoding/binary] and so only
+// supports fixed size data such as structs of numbers and arrays (no slices,
+// strings or maps)
+type BinaryCodec[T any] struct{}
+
+func (BinaryCodec[T]) Encode(buffer []byte, value T) ([]byte, error) {
+	if binary.Size(value) < 0 {
+		return buffer, errNotFixedSizedData
+	}
+	return binary.Append(buffer, binary.LittleEndian, value)
+}
+
+func (BinaryCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	_, err := binary.Decode(data, binary.LittleEndian, &value)
+	return value, err
+}
+
+// IsPredictionMessage returns true if the message was produced by a
+// prediction [Client] or [Server] and should be handed to its ProcessMessage
+func IsPredictionMessage(message []byte) bool {
+	return len(message) >= messageHeaderSize &&
+		binary.LittleEndian.Uint32(message) == messageMagic
+}
+
+type tickedInput[I any] struct {
+	tick  Tick
+	input I
+}
+
+func writeMessageHeader(buffer []byte, kind uint8) []byte {
+	buffer = binary.LittleEndian.AppendUint32(buffer, messageMagic)
+	return append(buffer, kind)
+}
+
+func messageKind(message []byte) uint8 {
+	if !IsPredictionMessage(message) {
+		return 0
+	}
+	return message[4]
+}
+
+// writeInputs encodes the inputs, newest last. When they don't all fit in a
+// single message the oldest are left out since they are the most likely to
+// have already reached the server.
+func writeInputs[I any](codec Codec[I], inputs []tickedInput[I]) ([]byte, error) {
+	inputs = inputs[max(0, len(inputs)-math.MaxUint8):]
+	entries := make([][]byte, len(inputs))
+	size := inputHeaderSize
+	first := len(inputs)
+	for i := len(inputs) - 1; i >= 0; i-- {
+		data, err := codec.Encode(nil, inputs[i].input)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > math.MaxUint16 || size+inputEntryHeaderSize+len(data) > network.MaxMessageSize {
+			break
+		}
+		entries[i] = data
+		size += inputEntryHeaderSize + len(data)
+		first = i
+	}
+	if first == len(inputs) {
+		return nil, ErrMessageTooLarge
+	}
+	buffer := make([]byte, 0, size)
+	buffer = writeMessageHeader(buffer, messageKindInput)
+	buffer = append(buffer, uint8(len(inputs)-first))
+	for i := first; i < len(inputs); i++ {
+		buffer = binary.LittleEndian.AppendUint32(buffer, uint32(inputs[i].tick))
+		buffer = binary.LittleEndian.AppendUint16(buffer, uint16(len(entries[i])))
+		buffer = append(buffer, entries[i]...)
+	}
+	return buffer, nil
+}
+
+func readInputs[I any](codec Codec[I], message []byte) ([]tickedInput[I], error) {
+	if messageKind(message) != messageKindInput || len(message) < inputHeaderSize {
+		return nil, errMalformedMessage
+	}
+	count := int(message[messageHeaderSize])
+	message = message[inputHeaderSize:]
+	inputs := make([]tickedInput[I], 0, count)
+	for range count {
+		if len(message) < inputEntryHeaderSize {
+			return nil, errMalformedMessage
+		}
+		tick := Tick(binary.LittleEndian.Uint32(message))
+		l := int(binary.LittleEndian.Uint16(message[4:]))
+		message = message[inputEntryHeaderSize:]
+		if len(message) < l {
+			return nil, errMalformedMessage
+		}
+		input, err := codec.Decode(message[:l])
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, tickedInput[I]{tick, input})
+		message = message[l:]
+	}
+	return inputs, nil
+}
+
+func writeState[S any](codec Codec[S], ack Tick, state S) ([]byte, error) {
+	buffer := writeMessageHeader(make([]byte, 0, network.MaxMessageSize), messageKindState)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(ack))
+	buffer = binary.LittleEndian.AppendUint16(buffer, 0)
+	buffer, err := codec.Encode(buffer, state)
+	if err != nil {
+		return nil, err
+	}
+	if len(buffer) > network.MaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	binary.LittleEndian.PutUint16(buffer[stateHeaderSize-2:], uint16(len(buffer)-stateHeaderSize))
+	return buffer, nil
+}
+
+func readState[S any](codec Codec[S], message []byte) (Tick, S, error) {
+	var state S
+	if messageKind(message) != messageKindState || len(message) < stateHeaderSize {
+		return 0, state, errMalformedMessage
+	}
+	ack := Tick(binary.LittleEndian.Uint32(message[messageHeaderSize:]))
+	l := int(binary.LittleEndian.Uint16(message[messageHeaderSize+4:]))
+	if len(message)-stateHeaderSize < l {
+		return 0, state, errMalformedMessage
+	}
+	state, err := codec.Decode(message[stateHeaderSize : stateHeaderSize+l])
+	return ack, state, err
+}
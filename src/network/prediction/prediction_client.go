@@ -0,0 +1,207 @@
+/******************************************************************************/
+/* prediction_client.go                                                       */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package prediction
+
+import (
+	"log/slog"
+	"math"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/network"
+)
+
+const (
+	// DefaultRedundantInputs is the number of unacknowledged inputs that are
+	// sent along with each new input, so that a lost packet doesn't lose input
+	DefaultRedundantInputs = 8
+	// maxHistory is the number of unacknowledged ticks kept for replaying,
+	// anything older can no longer be reconciled
+	maxHistory = 256
+	// maxCatchUpTicks is the most ticks that will run in a single update, a
+	// long frame beyond this slows down the simulation rather than spiraling
+	maxCatchUpTicks = 5
+)
+
+type predictedTick[I, S any] struct {
+	tick  Tick
+	input I
+	state S
+}
+
+// Client predicts the result of the local player's input so that it can be
+// shown right away rather than waiting on the server. Every tick the input is
+// sampled, simulated locally and sent to the server. When the authoritative
+// state arrives it is compared to what was predicted for that tick, and on a
+// mismatch the state is restored and every input the server hasn't processed
+// yet is replayed on top of it.
+type Client[I, S any] struct {
+	// SampleInput reads the local input for the given tick
+	SampleInput func(tick Tick) I
+	// Simulate advances the local simulation by one tick using the input.
+	// It is called both for new ticks and when replaying inputs.
+	Simulate func(input I, deltaTime float64)
+	// Capture returns the current state of the local simulation
+	Capture func() S
+	// Restore sets the local simulation to the given state
+	Restore func(state S)
+	// Equal reports if the predicted state is close enough to the state from
+	// the server that no correction is needed. If nil, every state received
+	// from the server is reconciled.
+	Equal func(predicted, authoritative S) bool
+	// RedundantInputs is the number of previous unacknowledged inputs that
+	// are sent along with each new input
+	RedundantInputs int
+	InputCodec      Codec[I]
+	StateCodec      Codec[S]
+
+	updater      *engine.Updater
+	updateId     engine.UpdateId
+	tick         Tick
+	lastAck      Tick
+	tickInterval float64
+	accumulator  float64
+	history      []predictedTick[I, S]
+	corrections  int
+	send         func(message []byte) error
+}
+
+// NewClient creates a prediction client that runs the simulation on the
+// updater at the given rate (ticks per second) and sends its inputs through
+// the supplied [network.NetworkClient]. If the tick rate is not positive,
+// [DefaultTickRate] is used. The callbacks must be set before the first update.
+func NewClient[I, S any](updater *engine.Updater, client *network.NetworkClient, tickRate float64) *Client[I, S] {
+	if tickRate <= 0 {
+		tickRate = DefaultTickRate
+	}
+	c := &Client[I, S]{
+		RedundantInputs: DefaultRedundantInputs,
+		InputCodec:      BinaryCodec[I]{},
+		StateCodec:      BinaryCodec[S]{},
+		updater:         updater,
+		tickInterval:    1.0 / tickRate,
+		send:            client.SendMessageUnreliable,
+	}
+	c.updateId = updater.AddUpdate(c.update)
+	return c
+}
+
+// Close stops the client from running the simulation
+func (c *Client[I, S]) Close() {
+	c.updater.RemoveUpdate(&c.updateId)
+}
+
+// Tick returns the last tick that was simulated
+func (c *Client[I, S]) Tick() Tick { return c.tick }
+
+// TickInterval returns the number of seconds that each tick simulates
+func (c *Client[I, S]) TickInterval() float64 { return c.tickInterval }
+
+// LastAcknowledged returns the last tick the server has processed
+func (c *Client[I, S]) LastAcknowledged() Tick { return c.lastAck }
+
+// Corrections returns the number of times the prediction was wrong and had to
+// be reconciled with the state from the server
+func (c *Client[I, S]) Corrections() int { return c.corrections }
+
+// Alpha returns how far (0-1) the time is between the last tick and the next
+// one, used to smooth the rendering of the simulated state
+func (c *Client[I, S]) Alpha() float64 {
+	return c.accumulator / c.tickInterval
+}
+
+// Step runs a single tick of the simulation. It is called automatically at
+// the tick rate, but can be called manually when the simulation is driven by
+// some other fixed step.
+func (c *Client[I, S]) Step() {
+	c.tick++
+	input := c.SampleInput(c.tick)
+	c.Simulate(input, c.tickInterval)
+	if len(c.history) == maxHistory {
+		copy(c.history, c.history[1:])
+		c.history = c.history[:len(c.history)-1]
+	}
+	c.history = append(c.history, predictedTick[I, S]{
+		tick:  c.tick,
+		input: input,
+		state: c.Capture(),
+	})
+	c.sendInputs()
+}
+
+func (c *Client[I, S]) sendInputs() {
+	count := min(len(c.history), max(c.RedundantInputs, 0)+1)
+	inputs := make([]tickedInput[I], count)
+	for i, h := range c.history[len(c.history)-count:] {
+		inputs[i] = tickedInput[I]{h.tick, h.input}
+	}
+	msg, err := writeInputs(c.InputCodec, inputs)
+	if err != nil {
+		slog.Error("failed to write the prediction inputs", "error", err)
+		return
+	}
+	if err = c.send(msg); err != nil {
+		slog.Error("failed to send the prediction inputs", "error", err)
+	}
+}
+
+// ProcessMessage reconciles the prediction with the message if it is the
+// authoritative state from the server. It will return false if the message
+// is not a prediction message so that the caller can continue to process it
+// as a game message.
+func (c *Client[I, S]) ProcessMessage(msg network.ClientMessage) bool {
+	buffer := msg.Message()
+	if !IsPredictionMessage(buffer) {
+		return false
+	}
+	ack, state, err := readState(c.StateCodec, buffer)
+	if err != nil {
+		slog.Error("failed to read the prediction state", "error", err)
+		return true
+	}
+	c.reconcile(ack, state)
+	return true
+}
+
+func (c *Client[I, S]) reconcile(ack Tick, state S) {
+	if ack <= c.lastAck || ack > c.tick {
+		// States are sent unreliably, so an older one may arrive late
+		return
+	}
+	c.lastAck = ack
+	i := 0
+	for i < len(c.history) && c.history[i].tick < ack {
+		i++
+	}
+	matched := i < len(c.history) && c.history[i].tick == ack &&
+		c.Equal != nil && c.Equal(c.history[i].state, state)
+	if i < len(c.history) && c.history[i].tick == ack {
+		i++
+	}
+	c.history = c.history[:copy(c.history, c.history[i:])]
+	if matched {
+		return
+	}
+	c.corrections++
+	c.Restore(state)
+	for j := range c.history {
+		c.Simulate(c.history[j].input, c.tickInterval)
+		c.history[j].state = c.Capture()
+	}
+}
+
+func (c *Client[I, S]) update(deltaTime float64) {
+	c.accumulator += deltaTime
+	for range maxCatchUpTicks {
+		if c.accumulator < c.tickInterval {
+			return
+		}
+		c.accumulator -= c.tickInterval
+		c.Step()
+	}
+	// Too far behind to catch up, drop the time that couldn't be simulated
+	c.accumulator = math.Mod(c.accumulator, c.tickInterval)
+}
@@ -0,0 +1,194 @@
+/******************************************************************************/
+/* prediction_server.go                                                       */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package prediction
+
+import (
+	"log/slog"
+	"math"
+	"slices"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/network"
+)
+
+const (
+	// DefaultMaxInputsPerTick is the most inputs from a single client that
+	// are processed in one server tick, letting a client that fell behind
+	// catch up without letting it speed up its simulation indefinitely
+	DefaultMaxInputsPerTick = 3
+	// maxPendingInputs is the most inputs buffered for a single client,
+	// inputs beyond this are dropped
+	maxPendingInputs = 64
+)
+
+type predictionClient[I any] struct {
+	client        *network.ServerClient
+	pending       []tickedInput[I]
+	lastProcessed Tick
+}
+
+// Server is the authoritative side of client prediction. It buffers the
+// inputs sent by each [Client], simulates them in tick order on its own fixed
+// tick, and replies with the resulting state along with the last input tick
+// that was processed so the client can reconcile its prediction.
+type Server[I, S any] struct {
+	// Simulate advances the authoritative simulation for the client by one
+	// tick using the client's input
+	Simulate func(client *network.ServerClient, input I, deltaTime float64)
+	// Capture returns the authoritative state for the client, it is sent to
+	// the client after its inputs are processed
+	Capture func(client *network.ServerClient) S
+	// MaxInputsPerTick is the most inputs processed for a client each tick
+	MaxInputsPerTick int
+	InputCodec       Codec[I]
+	StateCodec       Codec[S]
+
+	updater      *engine.Updater
+	updateId     engine.UpdateId
+	clients      []*predictionClient[I]
+	tickInterval float64
+	accumulator  float64
+	send         func(message []byte, client *network.ServerClient) error
+}
+
+// NewServer creates a prediction server that processes client inputs on the
+// updater at the given rate (ticks per second), which should match the rate
+// given to the clients. If the tick rate is not positive, [DefaultTickRate]
+// is used. The callbacks must be set before the first update.
+func NewServer[I, S any](updater *engine.Updater, server *network.NetworkServer, tickRate float64) *Server[I, S] {
+	if tickRate <= 0 {
+		tickRate = DefaultTickRate
+	}
+	s := &Server[I, S]{
+		MaxInputsPerTick: DefaultMaxInputsPerTick,
+		InputCodec:       BinaryCodec[I]{},
+		StateCodec:       BinaryCodec[S]{},
+		updater:          updater,
+		tickInterval:     1.0 / tickRate,
+		send:             server.SendMessageUnreliable,
+	}
+	s.updateId = updater.AddUpdate(s.update)
+	return s
+}
+
+// Close stops the server from processing inputs
+func (s *Server[I, S]) Close() {
+	s.updater.RemoveUpdate(&s.updateId)
+}
+
+// TickInterval returns the number of seconds that each tick simulates
+func (s *Server[I, S]) TickInterval() float64 { return s.tickInterval }
+
+// AddClient starts accepting predicted inputs from the client, this is
+// typically called from [network.NetworkServer.OnClientConnected]
+func (s *Server[I, S]) AddClient(client *network.ServerClient) {
+	if s.findClient(client) < 0 {
+		s.clients = append(s.clients, &predictionClient[I]{client: client})
+	}
+}
+
+// RemoveClient stops processing inputs from the client
+func (s *Server[I, S]) RemoveClient(client *network.ServerClient) {
+	if i := s.findClient(client); i >= 0 {
+		s.clients = slices.Delete(s.clients, i, i+1)
+	}
+}
+
+// LastProcessed returns the last input tick that was processed for the client
+func (s *Server[I, S]) LastProcessed(client *network.ServerClient) Tick {
+	if i := s.findClient(client); i >= 0 {
+		return s.clients[i].lastProcessed
+	}
+	return 0
+}
+
+func (s *Server[I, S]) findClient(client *network.ServerClient) int {
+	return slices.IndexFunc(s.clients, func(c *predictionClient[I]) bool {
+		return c.client == client
+	})
+}
+
+// ProcessMessage buffers the inputs if the message was sent by a prediction
+// [Client]. It will return false if the message is not a prediction message
+// so that the caller can continue to process it as a game message.
+func (s *Server[I, S]) ProcessMessage(msg network.ClientMessage) bool {
+	buffer := msg.Message()
+	if !IsPredictionMessage(buffer) {
+		return false
+	}
+	i := s.findClient(msg.Client)
+	if i < 0 {
+		return true
+	}
+	inputs, err := readInputs(s.InputCodec, buffer)
+	if err != nil {
+		slog.Error("failed to read the prediction inputs", "error", err)
+		return true
+	}
+	s.clients[i].buffer(inputs)
+	return true
+}
+
+// buffer adds the inputs that have not been seen yet, in tick order. The same
+// input is sent multiple times to survive packet loss, so most are repeats.
+func (c *predictionClient[I]) buffer(inputs []tickedInput[I]) {
+	for _, in := range inputs {
+		if in.tick <= c.lastProcessed {
+			continue
+		}
+		idx, found := slices.BinarySearchFunc(c.pending, in.tick, func(e tickedInput[I], t Tick) int {
+			return int(int64(e.tick) - int64(t))
+		})
+		if found {
+			continue
+		}
+		if len(c.pending) >= maxPendingInputs {
+			slog.Warn("too many prediction inputs are pending, dropping input", "tick", in.tick)
+			continue
+		}
+		c.pending = slices.Insert(c.pending, idx, in)
+	}
+}
+
+// Step processes the pending inputs for every client and sends each client
+// whose inputs were processed its new state. It is called automatically at
+// the tick rate, but can be called manually when the simulation is driven by
+// some other fixed step.
+func (s *Server[I, S]) Step() {
+	for _, c := range s.clients {
+		count := min(len(c.pending), max(s.MaxInputsPerTick, 1))
+		if count == 0 {
+			continue
+		}
+		for _, in := range c.pending[:count] {
+			s.Simulate(c.client, in.input, s.tickInterval)
+			c.lastProcessed = in.tick
+		}
+		c.pending = c.pending[:copy(c.pending, c.pending[count:])]
+		msg, err := writeState(s.StateCodec, c.lastProcessed, s.Capture(c.client))
+		if err != nil {
+			slog.Error("failed to write the prediction state", "error", err)
+			continue
+		}
+		if err = s.send(msg, c.client); err != nil {
+			slog.Error("failed to send the prediction state", "error", err)
+		}
+	}
+}
+
+func (s *Server[I, S]) update(deltaTime float64) {
+	s.accumulator += deltaTime
+	for range maxCatchUpTicks {
+		if s.accumulator < s.tickInterval {
+			return
+		}
+		s.accumulator -= s.tickInterval
+		s.Step()
+	}
+	// Too far behind to catch up, drop the time that couldn't be simulated
+	s.accumulator = math.Mod(s.accumulator, s.tickInterval)
+}
@@ -0,0 +1,266 @@
+/******************************************************************************/
+/* prediction_test.go                                                         */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package prediction
+
+import (
+	"testing"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/network"
+)
+
+type testInput struct {
+	Move int32
+}
+
+type testState struct {
+	Position int32
+}
+
+type testGame struct {
+	updater  engine.Updater
+	client   *Client[testInput, testState]
+	server   *Server[testInput, testState]
+	peer     *network.ServerClient
+	local    testState
+	remote   testState
+	move     int32
+	limit    int32
+	toServer [][]byte
+	toClient [][]byte
+}
+
+// newTestGame creates a client and server that simulate a position moving by
+// the input each tick. The server clamps the position to the limit, which the
+// client doesn't know about, so that mispredictions can be produced.
+func newTestGame() *testGame {
+	g := &testGame{updater: engine.NewUpdater(), peer: &network.ServerClient{}, move: 1, limit: 1000}
+	nc := network.NewClientUDP()
+	g.client = NewClient[testInput, testState](&g.updater, &nc, 10)
+	g.client.SampleInput = func(Tick) testInput { return testInput{g.move} }
+	g.client.Simulate = func(in testInput, _ float64) { g.local.Position += in.Move }
+	g.client.Capture = func() testState { return g.local }
+	g.client.Restore = func(s testState) { g.local = s }
+	g.client.Equal = func(a, b testState) bool { return a == b }
+	g.client.send = func(m []byte) error {
+		g.toServer = append(g.toServer, m)
+		return nil
+	}
+	ns := network.NewServerUDP()
+	g.server = NewServer[testInput, testState](&g.updater, &ns, 10)
+	g.server.Simulate = func(_ *network.ServerClient, in testInput, _ float64) {
+		g.remote.Position = min(g.remote.Position+in.Move, g.limit)
+	}
+	g.server.Capture = func(*network.ServerClient) testState { return g.remote }
+	g.server.send = func(m []byte, _ *network.ServerClient) error {
+		g.toClient = append(g.toClient, m)
+		return nil
+	}
+	g.server.AddClient(g.peer)
+	return g
+}
+
+func (g *testGame) deliverToServer() {
+	for _, m := range g.toServer {
+		msg := network.NewClientMessageFromBytes(m)
+		msg.Client = g.peer
+		g.server.ProcessMessage(msg)
+	}
+	g.toServer = g.toServer[:0]
+}
+
+func (g *testGame) deliverToClient() {
+	for _, m := range g.toClient {
+		g.client.ProcessMessage(network.NewClientMessageFromBytes(m))
+	}
+	g.toClient = g.toClient[:0]
+}
+
+func TestInputsRoundTrip(t *testing.T) {
+	in := []tickedInput[testInput]{{3, testInput{1}}, {4, testInput{-2}}}
+	msg, err := writeInputs(BinaryCodec[testInput]{}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := readInputs(BinaryCodec[testInput]{}, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0] != in[0] || out[1] != in[1] {
+		t.Errorf("inputs = %v, want %v", out, in)
+	}
+	if _, err = readInputs(BinaryCodec[testInput]{}, msg[:len(msg)-1]); err == nil {
+		t.Error("expected an error reading truncated inputs")
+	}
+}
+
+func TestWriteInputs_DropsOldestWhenTooLarge(t *testing.T) {
+	type bigInput struct{ Data [300]byte }
+	in := make([]tickedInput[bigInput], 10)
+	for i := range in {
+		in[i].tick = Tick(i + 1)
+	}
+	msg, err := writeInputs(BinaryCodec[bigInput]{}, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg) > network.MaxMessageSize {
+		t.Fatalf("message of %d bytes exceeds the max of %d", len(msg), network.MaxMessageSize)
+	}
+	out, _ := readInputs(BinaryCodec[bigInput]{}, msg)
+	if len(out) == 0 || out[len(out)-1].tick != 10 {
+		t.Errorf("the newest input should always be sent, got %d inputs", len(out))
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	msg, err := writeState(BinaryCodec[testState]{}, 42, testState{7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, state, err := readState(BinaryCodec[testState]{}, msg)
+	if err != nil || ack != 42 || state.Position != 7 {
+		t.Errorf("state = %d, %v, %v; want 42, {7}, nil", ack, state, err)
+	}
+}
+
+func TestBinaryCodec_RequiresFixedSize(t *testing.T) {
+	if _, err := (BinaryCodec[string]{}).Encode(nil, "hello"); err == nil {
+		t.Error("expected an error encoding a string")
+	}
+}
+
+func TestIsPredictionMessage(t *testing.T) {
+	if IsPredictionMessage([]byte("hello world")) {
+		t.Error("a game message was detected as a prediction message")
+	}
+	msg, _ := writeState(BinaryCodec[testState]{}, 1, testState{})
+	if !IsPredictionMessage(msg) {
+		t.Error("the state message was not detected as a prediction message")
+	}
+}
+
+func TestServer_BuffersInputsInOrder(t *testing.T) {
+	g := newTestGame()
+	c := g.server.clients[0]
+	c.buffer([]tickedInput[testInput]{{3, testInput{}}, {1, testInput{}}})
+	c.buffer([]tickedInput[testInput]{{1, testInput{}}, {2, testInput{}}, {3, testInput{}}})
+	if len(c.pending) != 3 {
+		t.Fatalf("pending = %d, want 3", len(c.pending))
+	}
+	for i := range c.pending {
+		if c.pending[i].tick != Tick(i+1) {
+			t.Errorf("pending[%d].tick = %d, want %d", i, c.pending[i].tick, i+1)
+		}
+	}
+	g.server.MaxInputsPerTick = 2
+	g.server.Step()
+	if g.server.LastProcessed(g.peer) != 2 || len(c.pending) != 1 {
+		t.Errorf("last processed = %d with %d pending, want 2 with 1 pending",
+			g.server.LastProcessed(g.peer), len(c.pending))
+	}
+	c.buffer([]tickedInput[testInput]{{2, testInput{}}})
+	if len(c.pending) != 1 {
+		t.Error("an input that was already processed was buffered again")
+	}
+}
+
+func TestClient_FixedTicks(t *testing.T) {
+	g := newTestGame()
+	g.server.Close()
+	g.updater.Update(0.25)
+	if g.client.Tick() != 2 {
+		t.Errorf("tick = %d, want 2", g.client.Tick())
+	}
+	if a := g.client.Alpha(); a < 0.49 || a > 0.51 {
+		t.Errorf("alpha = %f, want 0.5", a)
+	}
+	g.updater.Update(10)
+	if g.client.Tick() != 2+maxCatchUpTicks {
+		t.Errorf("tick = %d, want %d", g.client.Tick(), 2+maxCatchUpTicks)
+	}
+	if len(g.toServer) != int(g.client.Tick()) {
+		t.Errorf("sent %d input messages, want one per tick", len(g.toServer))
+	}
+}
+
+func TestPrediction_NoCorrectionWhenInAgreement(t *testing.T) {
+	g := newTestGame()
+	for range 5 {
+		g.client.Step()
+		g.deliverToServer()
+		g.server.Step()
+		g.deliverToClient()
+	}
+	if g.client.Tick() != 5 || g.client.LastAcknowledged() != 5 {
+		t.Errorf("tick = %d, ack = %d, want 5 and 5", g.client.Tick(), g.client.LastAcknowledged())
+	}
+	if g.client.Corrections() != 0 {
+		t.Errorf("corrections = %d, want 0", g.client.Corrections())
+	}
+	if g.local != g.remote {
+		t.Errorf("local = %v, remote = %v", g.local, g.remote)
+	}
+	if len(g.client.history) != 0 {
+		t.Errorf("history = %d, want all ticks acknowledged", len(g.client.history))
+	}
+}
+
+func TestPrediction_ReconcilesAndReplays(t *testing.T) {
+	g := newTestGame()
+	g.limit = 1
+	g.server.MaxInputsPerTick = 1
+	// The client runs ahead of the server by 3 ticks before hearing back
+	for range 3 {
+		g.client.Step()
+	}
+	g.deliverToServer()
+	g.server.Step()
+	g.server.Step()
+	if g.local.Position != 3 {
+		t.Fatalf("predicted position = %d, want 3", g.local.Position)
+	}
+	// More input is predicted while the server's state is in flight
+	g.limit = 100
+	g.client.Step()
+	g.client.Step()
+	g.deliverToClient()
+	// Tick 1 was predicted correctly, but the server acknowledged tick 2 with
+	// a clamped position of 1, so ticks 3 to 5 are replayed on top of it
+	if g.client.Corrections() != 1 {
+		t.Errorf("corrections = %d, want 1", g.client.Corrections())
+	}
+	if g.local.Position != 4 {
+		t.Errorf("reconciled position = %d, want 4", g.local.Position)
+	}
+	if len(g.client.history) != 3 || g.client.history[0].tick != 3 {
+		t.Errorf("history = %v, want ticks 3 to 5", g.client.history)
+	}
+}
+
+func TestPrediction_IgnoresStaleState(t *testing.T) {
+	g := newTestGame()
+	g.client.Step()
+	g.client.Step()
+	stale, _ := writeState(BinaryCodec[testState]{}, 1, testState{100})
+	fresh, _ := writeState(BinaryCodec[testState]{}, 2, testState{2})
+	g.client.ProcessMessage(network.NewClientMessageFromBytes(fresh))
+	g.client.ProcessMessage(network.NewClientMessageFromBytes(stale))
+	if g.local.Position != 2 || g.client.LastAcknowledged() != 2 {
+		t.Errorf("position = %d, ack = %d, want 2 and 2", g.local.Position, g.client.LastAcknowledged())
+	}
+}
+
+func TestClient_IgnoresGameMessages(t *testing.T) {
+	g := newTestGame()
+	if g.client.ProcessMessage(network.NewClientMessageFromBytes([]byte("hello"))) {
+		t.Error("the client should not consume game messages")
+	}
+	if g.server.ProcessMessage(network.NewClientMessageFromBytes([]byte("hello"))) {
+		t.Error("the server should not consume game messages")
+	}
+}
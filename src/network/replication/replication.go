@@ -0,0 +1,243 @@
+/******************************************************************************/
+/* replication.go                                                             */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replication
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/network"
+)
+
+// ReplicaId is the network-wide identifier for a replicated entity. It is
+// assigned by the [Server] and is the same on every connected client.
+type ReplicaId uint32
+
+// TransformFlags selects which parts of an entity transform are replicated
+type TransformFlags uint8
+
+const (
+	TransformPosition = TransformFlags(1 << iota)
+	TransformRotation
+	TransformScale
+
+	TransformNone = TransformFlags(0)
+	TransformAll  = TransformPosition | TransformRotation | TransformScale
+)
+
+// messageMagic prefixes every replication message so that they can be told
+// apart from game messages that share the same message queue
+const messageMagic = uint32(0x4C50524B) // "KRPL"
+
+const (
+	messageKindSnapshot = uint8(iota + 1)
+)
+
+const (
+	entryFlagPosition = uint8(TransformPosition)
+	entryFlagRotation = uint8(TransformRotation)
+	entryFlagScale    = uint8(TransformScale)
+	entryFlagSpawn    = uint8(1 << 5)
+	entryFlagFields   = uint8(1 << 6)
+	entryFlagDespawn  = uint8(1 << 7)
+)
+
+const (
+	// magic + kind + tick + tickInterval + entryCount
+	snapshotHeaderSize = 4 + 1 + 4 + 4 + 2
+	// id + flags
+	entryHeaderSize = 4 + 1
+	vec3Size        = 3 * 4
+	maxSpawnKeySize = math.MaxUint8
+	maxFieldCount   = math.MaxUint8
+	maxSnapshotSize = network.MaxMessageSize
+)
+
+var (
+	errMalformedSnapshot = errors.New("malformed replication snapshot")
+)
+
+// IsReplicationMessage returns true if the message was produced by a
+// replication [Server] and should be handed to [Client.ProcessMessage]
+func IsReplicationMessage(message []byte) bool {
+	return len(message) >= snapshotHeaderSize &&
+		binary.LittleEndian.Uint32(message) == messageMagic
+}
+
+type snapshotHeader struct {
+	tick         uint32
+	tickInterval float32
+	entryCount   uint16
+}
+
+type snapshotEntry struct {
+	id       ReplicaId
+	flags    uint8
+	key      string
+	position matrix.Vec3
+	rotation matrix.Vec3
+	scale    matrix.Vec3
+	fields   []fieldDelta
+}
+
+type fieldDelta struct {
+	index uint8
+	data  []byte
+}
+
+func (e *snapshotEntry) encodedSize() int {
+	size := entryHeaderSize
+	if e.flags&entryFlagSpawn != 0 {
+		size += 1 + len(e.key)
+	}
+	for _, f := range []uint8{entryFlagPosition, entryFlagRotation, entryFlagScale} {
+		if e.flags&f != 0 {
+			size += vec3Size
+		}
+	}
+	if e.flags&entryFlagFields != 0 {
+		size++
+		for i := range e.fields {
+			size += 1 + 2 + len(e.fields[i].data)
+		}
+	}
+	return size
+}
+
+func writeSnapshotHeader(buffer []byte, header snapshotHeader) []byte {
+	buffer = binary.LittleEndian.AppendUint32(buffer, messageMagic)
+	buffer = append(buffer, messageKindSnapshot)
+	buffer = binary.LittleEndian.AppendUint32(buffer, header.tick)
+	buffer = binary.LittleEndian.AppendUint32(buffer, math.Float32bits(header.tickInterval))
+	return binary.LittleEndian.AppendUint16(buffer, header.entryCount)
+}
+
+func setSnapshotEntryCount(buffer []byte, count uint16) {
+	binary.LittleEndian.PutUint16(buffer[snapshotHeaderSize-2:], count)
+}
+
+func readSnapshotHeader(buffer []byte) (snapshotHeader, []byte, error) {
+	if !IsReplicationMessage(buffer) || buffer[4] != messageKindSnapshot {
+		return snapshotHeader{}, buffer, errMalformedSnapshot
+	}
+	header := snapshotHeader{
+		tick:         binary.LittleEndian.Uint32(buffer[5:]),
+		tickInterval: math.Float32frombits(binary.LittleEndian.Uint32(buffer[9:])),
+		entryCount:   binary.LittleEndian.Uint16(buffer[13:]),
+	}
+	return header, buffer[snapshotHeaderSize:], nil
+}
+
+func toFloat32s(v matrix.Vec3) [3]float32 {
+	return [3]float32{float32(v[0]), float32(v[1]), float32(v[2])}
+}
+
+func writeVec3(buffer []byte, v matrix.Vec3) []byte {
+	for i := range v {
+		buffer = binary.LittleEndian.AppendUint32(buffer, math.Float32bits(float32(v[i])))
+	}
+	return buffer
+}
+
+func readVec3(buffer []byte) (matrix.Vec3, []byte, error) {
+	var v matrix.Vec3
+	if len(buffer) < vec3Size {
+		return v, buffer, errMalformedSnapshot
+	}
+	for i := range v {
+		v[i] = matrix.Float(math.Float32frombits(binary.LittleEndian.Uint32(buffer[i*4:])))
+	}
+	return v, buffer[vec3Size:], nil
+}
+
+func writeSnapshotEntry(buffer []byte, e *snapshotEntry) []byte {
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(e.id))
+	buffer = append(buffer, e.flags)
+	if e.flags&entryFlagSpawn != 0 {
+		buffer = append(buffer, uint8(len(e.key)))
+		buffer = append(buffer, e.key...)
+	}
+	if e.flags&entryFlagPosition != 0 {
+		buffer = writeVec3(buffer, e.position)
+	}
+	if e.flags&entryFlagRotation != 0 {
+		buffer = writeVec3(buffer, e.rotation)
+	}
+	if e.flags&entryFlagScale != 0 {
+		buffer = writeVec3(buffer, e.scale)
+	}
+	if e.flags&entryFlagFields != 0 {
+		buffer = append(buffer, uint8(len(e.fields)))
+		for i := range e.fields {
+			buffer = append(buffer, e.fields[i].index)
+			buffer = binary.LittleEndian.AppendUint16(buffer, uint16(len(e.fields[i].data)))
+			buffer = append(buffer, e.fields[i].data...)
+		}
+	}
+	return buffer
+}
+
+func readSnapshotEntry(buffer []byte) (snapshotEntry, []byte, error) {
+	e := snapshotEntry{}
+	if len(buffer) < entryHeaderSize {
+		return e, buffer, errMalformedSnapshot
+	}
+	e.id = ReplicaId(binary.LittleEndian.Uint32(buffer))
+	e.flags = buffer[4]
+	buffer = buffer[entryHeaderSize:]
+	var err error
+	if e.flags&entryFlagSpawn != 0 {
+		if len(buffer) < 1 || len(buffer) < 1+int(buffer[0]) {
+			return e, buffer, errMalformedSnapshot
+		}
+		keyLen := int(buffer[0])
+		e.key = string(buffer[1 : 1+keyLen])
+		buffer = buffer[1+keyLen:]
+	}
+	if e.flags&entryFlagPosition != 0 {
+		if e.position, buffer, err = readVec3(buffer); err != nil {
+			return e, buffer, err
+		}
+	}
+	if e.flags&entryFlagRotation != 0 {
+		if e.rotation, buffer, err = readVec3(buffer); err != nil {
+			return e, buffer, err
+		}
+	}
+	if e.flags&entryFlagScale != 0 {
+		if e.scale, buffer, err = readVec3(buffer); err != nil {
+			return e, buffer, err
+		}
+	}
+	if e.flags&entryFlagFields != 0 {
+		if len(buffer) < 1 {
+			return e, buffer, errMalformedSnapshot
+		}
+		count := int(buffer[0])
+		buffer = buffer[1:]
+		e.fields = make([]fieldDelta, 0, count)
+		for range count {
+			if len(buffer) < 3 {
+				return e, buffer, errMalformedSnapshot
+			}
+			idx := buffer[0]
+			dataLen := int(binary.LittleEndian.Uint16(buffer[1:]))
+			buffer = buffer[3:]
+			if len(buffer) < dataLen {
+				return e, buffer, errMalformedSnapshot
+			}
+			e.fields = append(e.fields, fieldDelta{
+				index: idx,
+				data:  buffer[:dataLen],
+			})
+			buffer = buffer[dataLen:]
+		}
+	}
+	return e, buffer, nil
+}
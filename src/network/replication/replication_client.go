@@ -0,0 +1,313 @@
+/******************************************************************************/
+/* replication_client.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replication
+
+import (
+	"errors"
+	"log/slog"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/network"
+)
+
+const (
+	// DefaultInterpolationDelay is how far behind the latest snapshot (in
+	// seconds) the client renders, giving time for the next snapshot to arrive
+	DefaultInterpolationDelay = 0.1
+	// maxClockDrift is how far the interpolation clock can drift from where it
+	// should be before it is snapped rather than smoothly interpolated
+	maxClockDrift = 0.25
+	// interpolationBufferSize is the number of snapshot samples kept for each
+	// replicated entity
+	interpolationBufferSize = 32
+)
+
+type interpolationSample struct {
+	time     float64
+	position matrix.Vec3
+	rotation matrix.Vec3
+	scale    matrix.Vec3
+}
+
+type clientReplica struct {
+	id        ReplicaId
+	entity    *engine.Entity
+	transform TransformFlags
+	fields    []replicatedField
+	samples   []interpolationSample
+}
+
+// Client applies the snapshots produced by a replication [Server]. Transforms
+// are buffered and interpolated between snapshots using a clock that trails the
+// newest snapshot by [Client.InterpolationDelay]. Replicated entity data fields
+// are applied as soon as they are received.
+type Client struct {
+	// Spawn is called when the server starts replicating an entity that this
+	// client does not yet know about. The key is the one given to
+	// [Server.Replicate]. Returning nil will ignore the replica.
+	Spawn func(id ReplicaId, key string) *engine.Entity
+	// Despawn is called when the server stops replicating an entity
+	Despawn func(id ReplicaId, entity *engine.Entity)
+	// InterpolationDelay is the number of seconds behind the newest snapshot
+	// that entities are displayed at
+	InterpolationDelay float64
+
+	updater  *engine.Updater
+	updateId engine.UpdateId
+	replicas map[ReplicaId]*clientReplica
+	lastTick uint32
+	latest   float64
+	clock    float64
+	started  bool
+}
+
+// NewClient creates a replication client that interpolates the transforms of
+// the replicated entities on every update of the given updater
+func NewClient(updater *engine.Updater) *Client {
+	c := &Client{
+		InterpolationDelay: DefaultInterpolationDelay,
+		updater:            updater,
+		replicas:           make(map[ReplicaId]*clientReplica),
+	}
+	c.updateId = updater.AddUpdate(c.update)
+	return c
+}
+
+// Close stops the client from interpolating the replicated entities
+func (c *Client) Close() {
+	c.updater.RemoveUpdate(&c.updateId)
+}
+
+// Entity returns the entity that was spawned for the given replica id
+func (c *Client) Entity(id ReplicaId) (*engine.Entity, bool) {
+	r, ok := c.replicas[id]
+	if !ok {
+		return nil, false
+	}
+	return r.entity, true
+}
+
+// LastTick returns the tick of the newest snapshot that has been received
+func (c *Client) LastTick() uint32 { return c.lastTick }
+
+// BindField binds a field on an entity data struct to the replicated field at
+// the same position as the call to [Server.ReplicateField] on the server. This
+// is typically called from within the [Client.Spawn] callback.
+func (c *Client) BindField(id ReplicaId, data any, fieldName string) error {
+	r, ok := c.replicas[id]
+	if !ok {
+		return errors.New("the replica does not exist")
+	}
+	f, err := newReplicatedField(data, fieldName)
+	if err != nil {
+		return err
+	}
+	r.fields = append(r.fields, f)
+	return nil
+}
+
+// ProcessMessage applies the message if it is a replication snapshot. It will
+// return false if the message is not a replication message so that the caller
+// can continue to process it as a game message.
+func (c *Client) ProcessMessage(msg network.ClientMessage) bool {
+	buffer := msg.Message()
+	if !IsReplicationMessage(buffer) {
+		return false
+	}
+	if err := c.applySnapshot(buffer); err != nil {
+		slog.Error("failed to apply the replication snapshot", "error", err)
+	}
+	return true
+}
+
+func (c *Client) applySnapshot(buffer []byte) error {
+	header, buffer, err := readSnapshotHeader(buffer)
+	if err != nil {
+		return err
+	}
+	if header.tick < c.lastTick {
+		return nil
+	}
+	if header.tick > c.lastTick {
+		c.beginTick(header)
+	}
+	for range header.entryCount {
+		var e snapshotEntry
+		if e, buffer, err = readSnapshotEntry(buffer); err != nil {
+			return err
+		}
+		c.applyEntry(&e)
+	}
+	return nil
+}
+
+// beginTick carries every replica's latest sample forward to the new tick so
+// that entities which didn't change still have a sample to interpolate to
+func (c *Client) beginTick(header snapshotHeader) {
+	c.lastTick = header.tick
+	c.latest = float64(header.tick) * float64(header.tickInterval)
+	for _, r := range c.replicas {
+		if len(r.samples) == 0 {
+			continue
+		}
+		next := r.samples[len(r.samples)-1]
+		next.time = c.latest
+		r.pushSample(next)
+	}
+	if !c.started {
+		c.started = true
+		c.clock = c.latest - c.InterpolationDelay
+	}
+}
+
+func (c *Client) applyEntry(e *snapshotEntry) {
+	if e.flags&entryFlagDespawn != 0 {
+		c.despawn(e.id)
+		return
+	}
+	r, ok := c.replicas[e.id]
+	if !ok {
+		if e.flags&entryFlagSpawn == 0 {
+			return
+		}
+		if r = c.spawn(e); r == nil {
+			return
+		}
+	}
+	if len(r.samples) == 0 {
+		t := &r.entity.Transform
+		r.pushSample(interpolationSample{
+			time:     c.latest,
+			position: t.Position(),
+			rotation: t.Rotation(),
+			scale:    t.Scale(),
+		})
+	}
+	s := &r.samples[len(r.samples)-1]
+	if e.flags&entryFlagPosition != 0 {
+		s.position = e.position
+		r.transform |= TransformPosition
+	}
+	if e.flags&entryFlagRotation != 0 {
+		s.rotation = e.rotation
+		r.transform |= TransformRotation
+	}
+	if e.flags&entryFlagScale != 0 {
+		s.scale = e.scale
+		r.transform |= TransformScale
+	}
+	if e.flags&entryFlagSpawn != 0 {
+		// There is nothing to interpolate from on spawn, so snap into place
+		r.apply(*s)
+	}
+	for i := range e.fields {
+		idx := int(e.fields[i].index)
+		if idx >= len(r.fields) {
+			continue
+		}
+		if err := r.fields[idx].decode(e.fields[i].data); err != nil {
+			slog.Error("failed to decode the replicated field", "id", e.id, "field", idx, "error", err)
+		}
+	}
+}
+
+func (c *Client) spawn(e *snapshotEntry) *clientReplica {
+	if c.Spawn == nil {
+		return nil
+	}
+	r := &clientReplica{id: e.id}
+	// The replica is registered before calling Spawn so that fields can be
+	// bound from within the callback
+	c.replicas[e.id] = r
+	r.entity = c.Spawn(e.id, e.key)
+	if r.entity == nil {
+		delete(c.replicas, e.id)
+		return nil
+	}
+	return r
+}
+
+func (c *Client) despawn(id ReplicaId) {
+	r, ok := c.replicas[id]
+	if !ok {
+		return
+	}
+	delete(c.replicas, id)
+	if c.Despawn != nil {
+		c.Despawn(id, r.entity)
+	}
+}
+
+func (c *Client) update(deltaTime float64) {
+	if !c.started {
+		return
+	}
+	c.clock += deltaTime
+	target := c.latest - c.InterpolationDelay
+	if drift := c.clock - target; drift > maxClockDrift || drift < -maxClockDrift {
+		c.clock = target
+	}
+	for _, r := range c.replicas {
+		if len(r.samples) > 0 {
+			r.apply(r.sampleAt(c.clock))
+		}
+	}
+}
+
+func (r *clientReplica) pushSample(s interpolationSample) {
+	if len(r.samples) == interpolationBufferSize {
+		copy(r.samples, r.samples[1:])
+		r.samples = r.samples[:len(r.samples)-1]
+	}
+	r.samples = append(r.samples, s)
+}
+
+func (r *clientReplica) sampleAt(time float64) interpolationSample {
+	if time <= r.samples[0].time {
+		return r.samples[0]
+	}
+	for i := 1; i < len(r.samples); i++ {
+		to := &r.samples[i]
+		if time > to.time {
+			continue
+		}
+		from := &r.samples[i-1]
+		span := to.time - from.time
+		if span <= 0 {
+			return *to
+		}
+		t := matrix.Float((time - from.time) / span)
+		out := interpolationSample{
+			time:     time,
+			position: matrix.Vec3Lerp(from.position, to.position, t),
+			rotation: from.rotation,
+			scale:    matrix.Vec3Lerp(from.scale, to.scale, t),
+		}
+		// Euler angles don't interpolate well, so go through quaternions
+		if from.rotation != to.rotation {
+			out.rotation = matrix.QuaternionSlerp(
+				matrix.QuaternionFromEuler(from.rotation),
+				matrix.QuaternionFromEuler(to.rotation), t).ToEuler()
+		}
+		return out
+	}
+	return r.samples[len(r.samples)-1]
+}
+
+func (r *clientReplica) apply(s interpolationSample) {
+	t := &r.entity.Transform
+	if r.transform&TransformPosition != 0 {
+		t.SetPosition(s.position)
+	}
+	if r.transform&TransformRotation != 0 {
+		t.SetRotation(s.rotation)
+	}
+	if r.transform&TransformScale != 0 {
+		t.SetScale(s.scale)
+	}
+}
@@ -0,0 +1,61 @@
+/******************************************************************************/
+/* replication_field.go                                                       */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// replicatedField is a reference to a single exported field on an entity data
+// struct. Fields are encoded with [binary.Write], so any fixed size value (bool,
+// numbers, matrix vectors, arrays of those) is supported along with strings.
+type replicatedField struct {
+	value reflect.Value
+}
+
+func newReplicatedField(data any, fieldName string) (replicatedField, error) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return replicatedField{}, fmt.Errorf("replicated data must be a pointer to a struct, got %T", data)
+	}
+	f := v.Elem().FieldByName(fieldName)
+	if !f.IsValid() {
+		return replicatedField{}, fmt.Errorf("the field %q does not exist on %T", fieldName, data)
+	}
+	if !f.CanSet() {
+		return replicatedField{}, fmt.Errorf("the field %q on %T is not exported", fieldName, data)
+	}
+	if f.Kind() != reflect.String && binary.Size(f.Interface()) < 0 {
+		return replicatedField{}, fmt.Errorf("the field %q on %T is not a fixed size type", fieldName, data)
+	}
+	return replicatedField{value: f}, nil
+}
+
+func (f replicatedField) encode() []byte {
+	if f.value.Kind() == reflect.String {
+		return []byte(f.value.String())
+	}
+	buff := bytes.Buffer{}
+	binary.Write(&buff, binary.LittleEndian, f.value.Interface())
+	return buff.Bytes()
+}
+
+func (f replicatedField) decode(data []byte) error {
+	if f.value.Kind() == reflect.String {
+		f.value.SetString(string(data))
+		return nil
+	}
+	ptr := reflect.New(f.value.Type())
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, ptr.Interface()); err != nil {
+		return err
+	}
+	f.value.Set(ptr.Elem())
+	return nil
+}
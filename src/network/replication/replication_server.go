@@ -0,0 +1,293 @@
+/******************************************************************************/
+/* replication_server.go                                                      */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replication
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/engine/systems/events"
+	"kaijuengine.com/network"
+)
+
+const DefaultTickRate = 20
+
+type replica struct {
+	id        ReplicaId
+	key       string
+	entity    *engine.Entity
+	transform TransformFlags
+	fields    []replicatedField
+	onDestroy events.Id
+}
+
+type replicaState struct {
+	position [3]float32
+	rotation [3]float32
+	scale    [3]float32
+	fields   [][]byte
+}
+
+type replicationClient struct {
+	client   *network.ServerClient
+	baseline map[ReplicaId]*replicaState
+}
+
+// Server tracks the entities that are replicated to every connected client.
+// Each tick it captures the replicated state of every entity and sends each
+// client only what changed since the last snapshot that client was sent.
+// Snapshots are sent as reliable messages, so the last sent state is always
+// the state the client will end up with and can be used as the delta base.
+type Server struct {
+	server       *network.NetworkServer
+	updater      *engine.Updater
+	updateId     engine.UpdateId
+	replicas     map[ReplicaId]*replica
+	order        []ReplicaId
+	clients      []*replicationClient
+	nextId       ReplicaId
+	tick         uint32
+	tickInterval float64
+	accumulator  float64
+	send         func(message []byte, client *network.ServerClient) error
+}
+
+// NewServer creates a replication server that will send snapshots through the
+// supplied [network.NetworkServer] at the given rate (ticks per second). If
+// the tick rate is not positive, [DefaultTickRate] is used.
+func NewServer(updater *engine.Updater, server *network.NetworkServer, tickRate float64) *Server {
+	if tickRate <= 0 {
+		tickRate = DefaultTickRate
+	}
+	s := &Server{
+		server:       server,
+		updater:      updater,
+		replicas:     make(map[ReplicaId]*replica),
+		tickInterval: 1.0 / tickRate,
+		send:         server.SendMessageReliable,
+	}
+	s.updateId = updater.AddUpdate(s.update)
+	return s
+}
+
+// Tick returns the number of snapshots that have been produced so far
+func (s *Server) Tick() uint32 { return s.tick }
+
+// TickInterval returns the number of seconds between each snapshot
+func (s *Server) TickInterval() float64 { return s.tickInterval }
+
+// Close stops the server from producing snapshots
+func (s *Server) Close() {
+	s.updater.RemoveUpdate(&s.updateId)
+}
+
+// Replicate marks the transform of the given entity as replicated and returns
+// the id that identifies it across the network. The key is sent to clients
+// when the entity is first replicated to them so that they know what kind of
+// entity to spawn (it is typically a template or content id).
+func (s *Server) Replicate(entity *engine.Entity, key string, transform TransformFlags) (ReplicaId, error) {
+	if len(key) > maxSpawnKeySize {
+		return 0, errors.New("the replication key is too long")
+	}
+	s.nextId++
+	id := s.nextId
+	r := &replica{
+		id:        id,
+		key:       key,
+		entity:    entity,
+		transform: transform,
+	}
+	r.onDestroy = entity.OnDestroy.Add(func() { s.Forget(id) })
+	s.replicas[id] = r
+	s.order = append(s.order, id)
+	return id, nil
+}
+
+// ReplicateField marks a field on an entity data struct as replicated for the
+// given replica. The data must be a pointer to the struct that owns the field.
+// Clients must bind their fields in the same order using [Client.BindField].
+func (s *Server) ReplicateField(id ReplicaId, data any, fieldName string) error {
+	r, ok := s.replicas[id]
+	if !ok {
+		return errors.New("the replica does not exist")
+	}
+	if len(r.fields) == maxFieldCount {
+		return errors.New("the replica has too many replicated fields")
+	}
+	f, err := newReplicatedField(data, fieldName)
+	if err != nil {
+		return err
+	}
+	r.fields = append(r.fields, f)
+	return nil
+}
+
+// Forget stops replicating the entity for the given id, clients that have
+// received the entity are told to despawn it on the next snapshot
+func (s *Server) Forget(id ReplicaId) {
+	r, ok := s.replicas[id]
+	if !ok {
+		return
+	}
+	r.entity.OnDestroy.Remove(r.onDestroy)
+	delete(s.replicas, id)
+	s.order = slices.DeleteFunc(s.order, func(o ReplicaId) bool { return o == id })
+}
+
+// AddClient starts replicating to the given client, the first snapshot it is
+// sent will contain the full state of every replicated entity
+func (s *Server) AddClient(client *network.ServerClient) {
+	if s.findClient(client) >= 0 {
+		return
+	}
+	s.clients = append(s.clients, &replicationClient{
+		client:   client,
+		baseline: make(map[ReplicaId]*replicaState),
+	})
+}
+
+// RemoveClient stops replicating to the given client
+func (s *Server) RemoveClient(client *network.ServerClient) {
+	if idx := s.findClient(client); idx >= 0 {
+		s.clients = slices.Delete(s.clients, idx, idx+1)
+	}
+}
+
+func (s *Server) findClient(client *network.ServerClient) int {
+	return slices.IndexFunc(s.clients, func(c *replicationClient) bool {
+		return c.client == client
+	})
+}
+
+func (s *Server) update(deltaTime float64) {
+	s.accumulator += deltaTime
+	if s.accumulator < s.tickInterval {
+		return
+	}
+	// Snapshots carry absolute state, so there is no need to catch up on
+	// ticks that were missed during a long frame
+	s.accumulator = 0
+	s.SendSnapshots()
+}
+
+// SendSnapshots captures the current state of all replicated entities and
+// sends each client a delta against the state it was last sent. This is
+// called automatically at the tick rate, but can be called manually to force
+// a snapshot to be sent.
+func (s *Server) SendSnapshots() {
+	s.tick++
+	current := make(map[ReplicaId]*replicaState, len(s.replicas))
+	for _, id := range s.order {
+		current[id] = s.replicas[id].capture()
+	}
+	for _, c := range s.clients {
+		for _, msg := range s.buildSnapshot(c, current) {
+			if err := s.send(msg, c.client); err != nil {
+				slog.Error("failed to send the replication snapshot", "error", err)
+			}
+		}
+	}
+}
+
+func (s *Server) buildSnapshot(c *replicationClient, current map[ReplicaId]*replicaState) [][]byte {
+	header := snapshotHeader{
+		tick:         s.tick,
+		tickInterval: float32(s.tickInterval),
+	}
+	messages := [][]byte{}
+	msg := writeSnapshotHeader(make([]byte, 0, maxSnapshotSize), header)
+	count := uint16(0)
+	appendEntry := func(e *snapshotEntry) bool {
+		if e.encodedSize()+snapshotHeaderSize > maxSnapshotSize {
+			slog.Error("the replicated entity state is too large to fit in a snapshot", "id", e.id)
+			return false
+		}
+		if len(msg)+e.encodedSize() > maxSnapshotSize {
+			setSnapshotEntryCount(msg, count)
+			messages = append(messages, msg)
+			msg = writeSnapshotHeader(make([]byte, 0, maxSnapshotSize), header)
+			count = 0
+		}
+		msg = writeSnapshotEntry(msg, e)
+		count++
+		return true
+	}
+	for _, id := range s.order {
+		r := s.replicas[id]
+		state := current[id]
+		last, known := c.baseline[id]
+		entry := r.delta(state, last)
+		// A dropped entry leaves the baseline alone so that the client is
+		// sent the state again, rather than never being sent it at all
+		if entry.flags != 0 && !appendEntry(&entry) {
+			continue
+		}
+		if !known {
+			last = &replicaState{}
+			c.baseline[id] = last
+		}
+		*last = *state
+	}
+	for id := range c.baseline {
+		if _, ok := current[id]; !ok {
+			if appendEntry(&snapshotEntry{id: id, flags: entryFlagDespawn}) {
+				delete(c.baseline, id)
+			}
+		}
+	}
+	// The final message is always sent, even if empty, so that the client
+	// receives a sample for every tick to interpolate between
+	setSnapshotEntryCount(msg, count)
+	return append(messages, msg)
+}
+
+func (r *replica) capture() *replicaState {
+	state := &replicaState{}
+	t := &r.entity.Transform
+	state.position = toFloat32s(t.Position())
+	state.rotation = toFloat32s(t.Rotation())
+	state.scale = toFloat32s(t.Scale())
+	state.fields = make([][]byte, len(r.fields))
+	for i := range r.fields {
+		state.fields[i] = r.fields[i].encode()
+	}
+	return state
+}
+
+func (r *replica) delta(state, last *replicaState) snapshotEntry {
+	e := snapshotEntry{id: r.id}
+	full := last == nil
+	if full {
+		e.flags |= entryFlagSpawn
+		e.key = r.key
+	}
+	t := &r.entity.Transform
+	if r.transform&TransformPosition != 0 && (full || state.position != last.position) {
+		e.flags |= entryFlagPosition
+		e.position = t.Position()
+	}
+	if r.transform&TransformRotation != 0 && (full || state.rotation != last.rotation) {
+		e.flags |= entryFlagRotation
+		e.rotation = t.Rotation()
+	}
+	if r.transform&TransformScale != 0 && (full || state.scale != last.scale) {
+		e.flags |= entryFlagScale
+		e.scale = t.Scale()
+	}
+	for i := range state.fields {
+		if full || i >= len(last.fields) || !bytes.Equal(state.fields[i], last.fields[i]) {
+			e.fields = append(e.fields, fieldDelta{index: uint8(i), data: state.fields[i]})
+		}
+	}
+	if len(e.fields) > 0 {
+		e.flags |= entryFlagFields
+	}
+	return e
+}
@@ -0,0 +1,415 @@
+/******************************************************************************/
+/* replication_test.go                                                        */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package replication
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kaijuengine.com/engine"
+	"kaijuengine.com/matrix"
+	"kaijuengine.com/network"
+)
+
+type testHealthData struct {
+	Health float32
+	Name   string
+	Team   int32
+}
+
+type sentMessage struct {
+	message []byte
+	client  *network.ServerClient
+}
+
+func newTestServer(t *testing.T) (*Server, *[]sentMessage) {
+	t.Helper()
+	u := engine.NewUpdater()
+	ns := network.NewServerUDP()
+	s := NewServer(&u, &ns, 20)
+	sent := []sentMessage{}
+	s.send = func(message []byte, client *network.ServerClient) error {
+		sent = append(sent, sentMessage{append([]byte{}, message...), client})
+		return nil
+	}
+	return s, &sent
+}
+
+func newTestClient() *Client {
+	u := engine.NewUpdater()
+	c := NewClient(&u)
+	c.Spawn = func(id ReplicaId, key string) *engine.Entity {
+		return engine.NewEntity(nil)
+	}
+	return c
+}
+
+func deliver(c *Client, sent *[]sentMessage) {
+	for _, m := range *sent {
+		if !c.ProcessMessage(network.NewClientMessageFromBytes(m.message)) {
+			panic("expected a replication message")
+		}
+	}
+	*sent = (*sent)[:0]
+}
+
+func TestSnapshotEntryRoundTrip(t *testing.T) {
+	in := snapshotEntry{
+		id:       42,
+		flags:    entryFlagSpawn | entryFlagPosition | entryFlagScale | entryFlagFields,
+		key:      "player",
+		position: matrix.NewVec3(1, 2, 3),
+		scale:    matrix.NewVec3(4, 5, 6),
+		fields:   []fieldDelta{{index: 2, data: []byte("abc")}},
+	}
+	buff := writeSnapshotEntry(nil, &in)
+	if len(buff) != in.encodedSize() {
+		t.Fatalf("encoded %d bytes, expected %d", len(buff), in.encodedSize())
+	}
+	out, rest, err := readSnapshotEntry(buff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("expected the whole buffer to be read, %d bytes left", len(rest))
+	}
+	if out.id != in.id || out.flags != in.flags || out.key != in.key {
+		t.Errorf("header mismatch: got %+v", out)
+	}
+	if out.position != in.position || out.scale != in.scale {
+		t.Errorf("transform mismatch: got %v %v", out.position, out.scale)
+	}
+	if len(out.fields) != 1 || out.fields[0].index != 2 || string(out.fields[0].data) != "abc" {
+		t.Errorf("fields mismatch: got %+v", out.fields)
+	}
+}
+
+func TestReadSnapshotEntry_Truncated(t *testing.T) {
+	in := snapshotEntry{id: 1, flags: entryFlagPosition}
+	buff := writeSnapshotEntry(nil, &in)
+	if _, _, err := readSnapshotEntry(buff[:len(buff)-1]); err == nil {
+		t.Error("expected an error for a truncated entry")
+	}
+}
+
+func TestIsReplicationMessage(t *testing.T) {
+	msg := writeSnapshotHeader(nil, snapshotHeader{tick: 1})
+	if !IsReplicationMessage(msg) {
+		t.Error("expected the snapshot header to be a replication message")
+	}
+	if IsReplicationMessage([]byte("hello world, this is a game message")) {
+		t.Error("expected a game message to not be a replication message")
+	}
+}
+
+func TestReplicateField_Validation(t *testing.T) {
+	s, _ := newTestServer(t)
+	e := engine.NewEntity(nil)
+	id, _ := s.Replicate(e, "thing", TransformAll)
+	data := &testHealthData{}
+	if err := s.ReplicateField(id, data, "Health"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := s.ReplicateField(id, *data, "Health"); err == nil {
+		t.Error("expected an error for a non-pointer struct")
+	}
+	if err := s.ReplicateField(id, data, "Missing"); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+	if err := s.ReplicateField(id+1, data, "Health"); err == nil {
+		t.Error("expected an error for a missing replica")
+	}
+}
+
+func TestServer_FullThenDelta(t *testing.T) {
+	s, sent := newTestServer(t)
+	client := &network.ServerClient{}
+	s.AddClient(client)
+	e := engine.NewEntity(nil)
+	e.Transform.SetPosition(matrix.NewVec3(1, 2, 3))
+	s.Replicate(e, "thing", TransformAll)
+
+	s.SendSnapshots()
+	if len(*sent) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(*sent))
+	}
+	header, rest, _ := readSnapshotHeader((*sent)[0].message)
+	if header.entryCount != 1 {
+		t.Fatalf("expected 1 entry, got %d", header.entryCount)
+	}
+	entry, _, _ := readSnapshotEntry(rest)
+	if entry.flags&entryFlagSpawn == 0 || entry.flags&entryFlagPosition == 0 {
+		t.Errorf("expected a full spawn entry, got flags %b", entry.flags)
+	}
+
+	*sent = (*sent)[:0]
+	s.SendSnapshots()
+	header, _, _ = readSnapshotHeader((*sent)[0].message)
+	if header.entryCount != 0 {
+		t.Errorf("expected no entries when nothing changed, got %d", header.entryCount)
+	}
+
+	*sent = (*sent)[:0]
+	e.Transform.SetPosition(matrix.NewVec3(4, 5, 6))
+	s.SendSnapshots()
+	header, rest, _ = readSnapshotHeader((*sent)[0].message)
+	if header.entryCount != 1 {
+		t.Fatalf("expected 1 entry, got %d", header.entryCount)
+	}
+	entry, _, _ = readSnapshotEntry(rest)
+	if entry.flags != entryFlagPosition {
+		t.Errorf("expected only the position to be sent, got flags %b", entry.flags)
+	}
+}
+
+func TestServer_LateClientGetsFullState(t *testing.T) {
+	s, sent := newTestServer(t)
+	first := &network.ServerClient{}
+	s.AddClient(first)
+	s.Replicate(engine.NewEntity(nil), "thing", TransformAll)
+	s.SendSnapshots()
+	*sent = (*sent)[:0]
+	late := &network.ServerClient{}
+	s.AddClient(late)
+	s.SendSnapshots()
+	for _, m := range *sent {
+		header, rest, _ := readSnapshotHeader(m.message)
+		if m.client == first && header.entryCount != 0 {
+			t.Errorf("expected no entries for the existing client, got %d", header.entryCount)
+		}
+		if m.client == late {
+			entry, _, _ := readSnapshotEntry(rest)
+			if entry.flags&entryFlagSpawn == 0 {
+				t.Error("expected the late client to receive a spawn entry")
+			}
+		}
+	}
+}
+
+func TestServer_SplitsLargeSnapshots(t *testing.T) {
+	s, sent := newTestServer(t)
+	s.AddClient(&network.ServerClient{})
+	const count = 200
+	for range count {
+		s.Replicate(engine.NewEntity(nil), "thing", TransformAll)
+	}
+	s.SendSnapshots()
+	if len(*sent) < 2 {
+		t.Fatalf("expected the snapshot to be split, got %d message(s)", len(*sent))
+	}
+	total := 0
+	for _, m := range *sent {
+		if len(m.message) > network.MaxMessageSize {
+			t.Errorf("message of %d bytes exceeds the max of %d", len(m.message), network.MaxMessageSize)
+		}
+		header, _, _ := readSnapshotHeader(m.message)
+		total += int(header.entryCount)
+	}
+	if total != count {
+		t.Errorf("expected %d entries across all messages, got %d", count, total)
+	}
+	c := newTestClient()
+	deliver(c, sent)
+	if len(c.replicas) != count {
+		t.Errorf("expected the client to spawn %d replicas, got %d", count, len(c.replicas))
+	}
+}
+
+func TestServer_ResendsDroppedEntry(t *testing.T) {
+	s, sent := newTestServer(t)
+	s.AddClient(&network.ServerClient{})
+	data := &testHealthData{Name: strings.Repeat("x", maxSnapshotSize)}
+	id, _ := s.Replicate(engine.NewEntity(nil), "thing", TransformAll)
+	s.ReplicateField(id, data, "Name")
+
+	s.SendSnapshots()
+	header, _, _ := readSnapshotHeader((*sent)[0].message)
+	if header.entryCount != 0 {
+		t.Fatalf("expected the oversized entry to be dropped, got %d entries", header.entryCount)
+	}
+	*sent = (*sent)[:0]
+	data.Name = "Kaiju"
+	s.SendSnapshots()
+	header, rest, _ := readSnapshotHeader((*sent)[0].message)
+	if header.entryCount != 1 {
+		t.Fatalf("expected the dropped entry to be sent again, got %d entries", header.entryCount)
+	}
+	if entry, _, _ := readSnapshotEntry(rest); entry.flags&entryFlagSpawn == 0 {
+		t.Errorf("expected the client to still be sent the spawn, got flags %b", entry.flags)
+	}
+}
+
+func TestServer_Loopback(t *testing.T) {
+	updater := engine.NewUpdater()
+	ns := network.NewServerUDP()
+	if err := ns.Serve(&updater, 0); err != nil {
+		t.Skipf("unable to listen on a local UDP port: %v", err)
+	}
+	defer ns.Close(&updater)
+	var connected *network.ServerClient
+	ns.OnClientConnected.Add(func(c *network.ServerClient) { connected = c })
+	nc := network.NewClientUDP()
+	defer nc.Close(&updater)
+	if err := nc.Connect(&updater, "127.0.0.1", uint16(ns.LocalAddress().Port)); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	s := NewServer(&updater, &ns, 20)
+	defer s.Close()
+	c := NewClient(&updater)
+	defer c.Close()
+	serverData := &testHealthData{Health: 75, Name: "Kaiju"}
+	clientData := &testHealthData{}
+	c.Spawn = func(id ReplicaId, key string) *engine.Entity {
+		c.BindField(id, clientData, "Health")
+		c.BindField(id, clientData, "Name")
+		return engine.NewEntity(nil)
+	}
+	despawned := false
+	c.Despawn = func(ReplicaId, *engine.Entity) { despawned = true }
+	pump := func(done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second * 2)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the network to respond")
+			}
+			updater.Update(0.001)
+			for _, msg := range nc.ServerMessageQueue.Flush() {
+				c.ProcessMessage(msg)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	pump(func() bool { return connected != nil && nc.IsConnected() })
+
+	s.AddClient(connected)
+	e := engine.NewEntity(nil)
+	e.Transform.SetPosition(matrix.NewVec3(1, 2, 3))
+	id, _ := s.Replicate(e, "monster", TransformAll)
+	s.ReplicateField(id, serverData, "Health")
+	s.ReplicateField(id, serverData, "Name")
+	s.SendSnapshots()
+	pump(func() bool { return *clientData == *serverData })
+	ce, ok := c.Entity(id)
+	if !ok {
+		t.Fatal("expected the client to have the replicated entity")
+	}
+	if !ce.Transform.Position().Equals(matrix.NewVec3(1, 2, 3)) {
+		t.Errorf("spawned position = %v, want (1, 2, 3)", ce.Transform.Position())
+	}
+
+	serverData.Health = 10
+	s.SendSnapshots()
+	pump(func() bool { return clientData.Health == 10 })
+	s.Forget(id)
+	s.SendSnapshots()
+	pump(func() bool { return despawned })
+}
+
+func TestClient_SpawnFieldsAndDespawn(t *testing.T) {
+	s, sent := newTestServer(t)
+	s.AddClient(&network.ServerClient{})
+	e := engine.NewEntity(nil)
+	e.Transform.SetPosition(matrix.NewVec3(1, 2, 3))
+	serverData := &testHealthData{Health: 75, Name: "Kaiju", Team: 2}
+	id, _ := s.Replicate(e, "monster", TransformAll)
+	s.ReplicateField(id, serverData, "Health")
+	s.ReplicateField(id, serverData, "Name")
+	s.ReplicateField(id, serverData, "Team")
+
+	c := newTestClient()
+	clientData := &testHealthData{}
+	spawnedKey := ""
+	c.Spawn = func(id ReplicaId, key string) *engine.Entity {
+		spawnedKey = key
+		c.BindField(id, clientData, "Health")
+		c.BindField(id, clientData, "Name")
+		c.BindField(id, clientData, "Team")
+		return engine.NewEntity(nil)
+	}
+	despawned := false
+	c.Despawn = func(ReplicaId, *engine.Entity) { despawned = true }
+
+	s.SendSnapshots()
+	deliver(c, sent)
+	if spawnedKey != "monster" {
+		t.Errorf("spawned key = %q, want %q", spawnedKey, "monster")
+	}
+	if *clientData != *serverData {
+		t.Errorf("client data = %+v, want %+v", *clientData, *serverData)
+	}
+	ce, ok := c.Entity(id)
+	if !ok {
+		t.Fatal("expected the client to have the replicated entity")
+	}
+	if !ce.Transform.Position().Equals(matrix.NewVec3(1, 2, 3)) {
+		t.Errorf("spawned position = %v, want (1, 2, 3)", ce.Transform.Position())
+	}
+
+	serverData.Health = 10
+	s.SendSnapshots()
+	deliver(c, sent)
+	if clientData.Health != 10 {
+		t.Errorf("client health = %v, want 10", clientData.Health)
+	}
+
+	s.Forget(id)
+	s.SendSnapshots()
+	deliver(c, sent)
+	if !despawned {
+		t.Error("expected the client to despawn the replica")
+	}
+	if _, ok := c.Entity(id); ok {
+		t.Error("expected the replica to be removed from the client")
+	}
+}
+
+func TestClient_IgnoresGameMessages(t *testing.T) {
+	c := newTestClient()
+	if c.ProcessMessage(network.NewClientMessageFromBytes([]byte("chat: hello there friend"))) {
+		t.Error("expected a game message to not be consumed")
+	}
+}
+
+func TestClient_Interpolation(t *testing.T) {
+	s, sent := newTestServer(t)
+	s.AddClient(&network.ServerClient{})
+	e := engine.NewEntity(nil)
+	id, _ := s.Replicate(e, "thing", TransformPosition)
+	c := newTestClient()
+	c.InterpolationDelay = s.TickInterval()
+
+	s.SendSnapshots()
+	deliver(c, sent)
+	e.Transform.SetPosition(matrix.NewVec3(10, 0, 0))
+	s.SendSnapshots()
+	deliver(c, sent)
+
+	ce, _ := c.Entity(id)
+	// The client clock started one tick behind the first snapshot, advancing
+	// a tick and a half puts it half way between the two snapshots
+	c.update(s.TickInterval() * 1.5)
+	if !ce.Transform.Position().Equals(matrix.NewVec3(5, 0, 0)) {
+		t.Errorf("interpolated position = %v, want (5, 0, 0)", ce.Transform.Position())
+	}
+	c.update(s.TickInterval())
+	if !ce.Transform.Position().Equals(matrix.NewVec3(10, 0, 0)) {
+		t.Errorf("final position = %v, want (10, 0, 0)", ce.Transform.Position())
+	}
+}
+
+func TestClient_IgnoresStaleTicks(t *testing.T) {
+	c := newTestClient()
+	newer := writeSnapshotHeader(nil, snapshotHeader{tick: 5, tickInterval: 0.05})
+	older := writeSnapshotHeader(nil, snapshotHeader{tick: 4, tickInterval: 0.05})
+	c.ProcessMessage(network.NewClientMessageFromBytes(newer))
+	c.ProcessMessage(network.NewClientMessageFromBytes(older))
+	if c.LastTick() != 5 {
+		t.Errorf("last tick = %d, want 5", c.LastTick())
+	}
+}
@@ -0,0 +1,187 @@
+/******************************************************************************/
+/* snapshot.go                                                                */
+/******************************************************************************/
+/* MIT License, Copyright (c) 2015-present Brent Farris, (John 4:13-14)       */
+/******************************************************************************/
+
+package hid
+
+import "time"
+
+// KeyboardSnapshot is the raw state of every key. The snapshots of the
+// devices are made of fixed size fields so they can be compared with == and
+// written with encoding/binary, this is what input recording is built on.
+type KeyboardSnapshot struct {
+	States [KeyboardKeyMaximum]KeyState
+}
+
+// MouseSnapshot is the raw state of the mouse, including the flags that are
+// cleared at the end of each frame
+type MouseSnapshot struct {
+	X, Y             float32
+	SX, SY           float32
+	CX, CY           float32
+	ScrollX, ScrollY float32
+	Buttons          [MouseButtonLast]int32
+	Moved            bool
+	ButtonChanged    bool
+	ScrollPending    bool
+}
+
+// ControllerDeviceSnapshot is the raw state of a single controller, the id is
+// -1 when the controller is not connected
+type ControllerDeviceSnapshot struct {
+	Id      int32
+	Buttons [ControllerButtonMax]int32
+	Axis    [ControllerAxisMax]float32
+}
+
+// ControllerSnapshot is the raw state of all of the controller slots
+type ControllerSnapshot struct {
+	Devices [ControllerMaxDevices]ControllerDeviceSnapshot
+}
+
+// TouchPointerSnapshot is the raw state of a pointer in the touch pool
+type TouchPointerSnapshot struct {
+	Pressure float32
+	X, Y     float32
+	SX, SY   float32
+	State    int32
+	Id       int64
+}
+
+// TouchSnapshot is the raw state of the touch pool along with the order of the
+// active pointers, Order holds the pool index of the first Count pointers
+type TouchSnapshot struct {
+	Pool  [MaxTouchPointersAvailable]TouchPointerSnapshot
+	Order [MaxTouchPointersAvailable]int8
+	Count int8
+}
+
+// Snapshot returns the current state of the keys
+func (k *Keyboard) Snapshot() KeyboardSnapshot {
+	return KeyboardSnapshot{States: k.keyStates}
+}
+
+// Restore replaces the state of the keys with the snapshot. The key callbacks
+// are executed for the keys that change to down, up or pressed-and-released,
+// the same as they would be when set by the window.
+func (k *Keyboard) Restore(s KeyboardSnapshot) {
+	for i := range KeyboardKeyMaximum {
+		if k.keyStates[i] == s.States[i] {
+			continue
+		}
+		k.keyStates[i] = s.States[i]
+		switch s.States[i] {
+		case KeyStateDown:
+			k.lastClicked[i] = time.Now()
+			k.doKeyCallbacks(i, s.States[i])
+		case KeyStateUp, KeyStatePressedAndReleased:
+			k.doKeyCallbacks(i, s.States[i])
+		}
+	}
+}
+
+// Snapshot returns the current state of the mouse
+func (m *Mouse) Snapshot() MouseSnapshot {
+	s := MouseSnapshot{
+		X: m.X, Y: m.Y,
+		SX: m.SX, SY: m.SY,
+		CX: m.CX, CY: m.CY,
+		ScrollX: m.ScrollX, ScrollY: m.ScrollY,
+		Moved:         m.moved,
+		ButtonChanged: m.buttonChanged,
+		ScrollPending: m.scrollPending,
+	}
+	for i := range m.buttonStates {
+		s.Buttons[i] = int32(m.buttonStates[i])
+	}
+	return s
+}
+
+// Restore replaces the state of the mouse with the snapshot
+func (m *Mouse) Restore(s MouseSnapshot) {
+	m.X, m.Y = s.X, s.Y
+	m.SX, m.SY = s.SX, s.SY
+	m.CX, m.CY = s.CX, s.CY
+	m.ScrollX, m.ScrollY = s.ScrollX, s.ScrollY
+	m.moved = s.Moved
+	m.buttonChanged = s.ButtonChanged
+	m.scrollPending = s.ScrollPending
+	for i := range m.buttonStates {
+		m.buttonStates[i] = int(s.Buttons[i])
+	}
+}
+
+// Snapshot returns the current state of all of the controllers
+func (c *Controller) Snapshot() ControllerSnapshot {
+	s := ControllerSnapshot{}
+	for i := range c.devices {
+		d := &c.devices[i]
+		s.Devices[i].Id = int32(d.id)
+		s.Devices[i].Axis = d.axis
+		for j := range d.buttons {
+			s.Devices[i].Buttons[j] = int32(d.buttons[j])
+		}
+	}
+	return s
+}
+
+// Restore replaces the state of all of the controllers with the snapshot
+func (c *Controller) Restore(s ControllerSnapshot) {
+	for i := range c.devices {
+		d := &c.devices[i]
+		d.id = int(s.Devices[i].Id)
+		d.axis = s.Devices[i].Axis
+		for j := range d.buttons {
+			d.buttons[j] = int(s.Devices[i].Buttons[j])
+		}
+	}
+}
+
+// Snapshot returns the current state of the touch pointers
+func (t *Touch) Snapshot() TouchSnapshot {
+	s := TouchSnapshot{}
+	for i := range t.Pool {
+		p := &t.Pool[i]
+		s.Pool[i] = TouchPointerSnapshot{
+			Pressure: p.Pressure,
+			X:        p.X,
+			Y:        p.Y,
+			SX:       p.SX,
+			SY:       p.SY,
+			State:    int32(p.State),
+			Id:       p.Id,
+		}
+	}
+	for _, p := range t.Pointers {
+		for i := range t.Pool {
+			if p == &t.Pool[i] {
+				s.Order[s.Count] = int8(i)
+				s.Count++
+				break
+			}
+		}
+	}
+	return s
+}
+
+// Restore replaces the state of the touch pointers with the snapshot
+func (t *Touch) Restore(s TouchSnapshot) {
+	for i := range t.Pool {
+		p := &s.Pool[i]
+		t.Pool[i] = TouchPointer{
+			Pressure: p.Pressure,
+			X:        p.X,
+			Y:        p.Y,
+			SX:       p.SX,
+			SY:       p.SY,
+			State:    TouchAction(p.State),
+			Id:       p.Id,
+		}
+	}
+	t.Pointers = t.Pointers[:0]
+	for i := range s.Count {
+		t.Pointers = append(t.Pointers, &t.Pool[s.Order[i]])
+	}
+}
@@ -524,7 +524,7 @@ func (d *DrawInstanceGroup) UpdateDataForView(device *GPUDevice, frame int, ligh
 				}
 			}
 			to := unsafe.Pointer(uintptr(base) + offset)
-			klib.Memcpy(to, instanceBase.DataPointer(), uint64(d.instanceSize))
+			klib.Memcpy(to, instance.DataPointer(), uint64(d.instanceSize))
 			offset += uintptr(d.instanceSize + state.rawData.padding)
 			state.visibleCount++
 			instanceIndex++
@@ -589,7 +589,7 @@ func (d *DrawInstanceGroup) CaptureDataForView(lights LightsForRender, view Rend
 		start := len(state.frameData.raw)
 		state.frameData.raw = append(state.frameData.raw, make([]byte, stride)...)
 		copyFromPointer(state.frameData.raw[start:start+d.instanceSize],
-			instanceBase.DataPointer(), d.instanceSize)
+			instance.DataPointer(), d.instanceSize)
 		state.visibleCount++
 	}
 	if count < len(d.Instances) {
@@ -28,6 +28,19 @@ func (d *testDrawInstance) UpdateBoundData() bool            { return true }
 func (d *testDrawInstance) BoundDataPointer() unsafe.Pointer { return unsafe.Pointer(&d.boundData[0]) }
 func (d *testDrawInstance) InstanceBoundDataSize() int       { return int(unsafe.Sizeof(d.boundData)) }
 
+// testOverrideInstance has instance data that is not the model of its base,
+// like shader data that writes a filtered copy of itself for the GPU
+type testOverrideInstance struct {
+	ShaderDataBase
+	override [16]float32
+}
+
+func (d *testOverrideInstance) Size() int { return int(unsafe.Sizeof(d.override)) }
+
+func (d *testOverrideInstance) DataPointer() unsafe.Pointer {
+	return unsafe.Pointer(&d.override[0])
+}
+
 type testViewCuller struct {
 	inView      bool
 	viewChanged bool
@@ -259,6 +272,32 @@ func TestDrawInstanceGroupUpdateDataIsPerRenderView(t *testing.T) {
 	}
 }
 
+func TestDrawInstanceGroupCopiesOverriddenDataPointer(t *testing.T) {
+	mesh := NewMesh("mesh", testVerts(), []uint32{0, 1})
+	inst := &testOverrideInstance{ShaderDataBase: NewShaderDataBase()}
+	for i := range inst.override {
+		inst.override[i] = float32(i + 1)
+	}
+	group := NewDrawInstanceGroup(mesh, inst.Size(), nil)
+	group.MaterialInstance = &Material{}
+	group.AddInstance(inst)
+	view := newRenderView(RenderViewOptions{
+		Name:   "default",
+		Camera: &testViewCuller{inView: true, viewChanged: true},
+	}, 0)
+	var mapped [16]float32
+	group.viewStateForView(view).rawData.byteMapping[0] = unsafe.Pointer(&mapped[0])
+	group.UpdateDataForView(&GPUDevice{}, 0, LightsForRender{}, view)
+	if mapped != inst.override {
+		t.Errorf("uploaded data = %v, want the instance's own data %v", mapped, inst.override)
+	}
+	group.CaptureDataForView(LightsForRender{}, newRenderViewFrame(view))
+	raw := group.viewStateForView(view).frameData.raw
+	if captured := *(*[16]float32)(unsafe.Pointer(&raw[0])); captured != inst.override {
+		t.Errorf("captured data = %v, want the instance's own data %v", captured, inst.override)
+	}
+}
+
 func TestDrawInstanceGroupUIUsesFallbackCuller(t *testing.T) {
 	mesh := NewMesh("mesh", testVerts(), []uint32{0, 1})
 	inst := newTestDrawInstance()
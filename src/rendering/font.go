@@ -586,7 +586,7 @@ func (cache *FontCache) RenderMeshesWithLetterSpacing(caches RenderCaches,
 				}
 			}
 		}
-		cx = x
+		cx = x * inverseWidth
 		cy -= lineAdvanceNormalized
 		//lenLeft -= charLen;
 		current += charLen
@@ -6,7 +6,11 @@
 
 package rendering
 
-import "testing"
+import (
+	"testing"
+
+	"kaijuengine.com/matrix"
+)
 
 func TestMSDFAtlasPxRangeMatchesGeneratedFonts(t *testing.T) {
 	got := msdfAtlasPxRange()
@@ -14,3 +18,35 @@ func TestMSDFAtlasPxRangeMatchesGeneratedFonts(t *testing.T) {
 		t.Fatalf("msdfAtlasPxRange = %v, want %v on both axes", got, distanceFieldRange)
 	}
 }
+
+func TestRenderMeshesStartsEveryLineAtX(t *testing.T) {
+	meshCache := NewMeshCache(nil, nil)
+	face := FontFace("test")
+	cache := FontCache{
+		textOrthoMaterial: &Material{Instances: make(map[string]*Material)},
+		fontFaces: map[string]fontBin{face.string(): {
+			texture: &Texture{Key: "test"},
+			width:   1,
+			height:  1,
+			metrics: fontBinMetrics{LineHeight: 1},
+			letters: map[rune]fontBinChar{
+				'a': {letter: 'a', advance: 1, planeBounds: [4]float32{0, 0, 1, 1}},
+			},
+		}},
+	}
+	drawings := cache.RenderMeshes(combinedTargetTestCaches{meshCache: &meshCache},
+		"a\na", 10, 0, 0, 1, 100, matrix.ColorWhite(), matrix.ColorBlack(),
+		FontJustifyLeft, FontBaselineTop, matrix.NewVec3(100, 100, 1), false,
+		false, face, 0, nil)
+	if len(drawings) != 2 {
+		t.Fatalf("expected a drawing for each letter, got %d", len(drawings))
+	}
+	first := drawings[0].Mesh.pendingVerts[0].Position
+	second := drawings[1].Mesh.pendingVerts[0].Position
+	if !matrix.Approx(first.X(), second.X()) {
+		t.Errorf("the second line starts at x %v, want %v like the first line", second.X(), first.X())
+	}
+	if second.Y() >= first.Y() {
+		t.Errorf("the second line y %v should be below the first line y %v", second.Y(), first.Y())
+	}
+}